@@ -1,5 +1,10 @@
 package blockingpool
 
+import (
+	"context"
+	"time"
+)
+
 // BlockingPool is a generic, channel-based object pool that provides blocking
 // semantics for both acquiring and returning objects.
 //
@@ -7,18 +12,22 @@ package blockingpool
 // scenarios where you want to limit the number of concurrently allocated
 // resources and enforce strict back-pressure:
 //
-//   - Get() blocks until an object is available in the pool (or the caller’s
-//     context is canceled if used with select).
+//   - Get() blocks until an object is available in the pool. GetCtx() also
+//     returns when the caller's context is canceled.
 //   - Put() blocks until there is space in the pool (i.e., the number of
-//     outstanding objects is below the capacity).
+//     outstanding objects is below the capacity). PutCtx() also returns when
+//     the caller's context is canceled.
 //
 // Important characteristics:
 //   - Get() will block indefinitely if the pool is empty or until an new item
 //     is .Put() into the pool.
 //   - Put() will block indefinitely if the pool is at full capacity or until
 //     an item is .Get() from the pool.
+//
+// Every pool counts how often and how long callers waited on it, see Stats.
 type BlockingPool[T any] struct {
-	pool chan T
+	pool     chan T
+	counters *poolCounters
 }
 
 // NewBlockingPool creates a new BlockingPool with the specified capacity.
@@ -27,7 +36,8 @@ type BlockingPool[T any] struct {
 // out" simultaneously (i.e., the maximum number of outstanding Get() calls
 // without corresponding Put() calls).
 func NewBlockingPool[T any](capacity int) BlockingPool[T] {
-	return BlockingPool[T]{pool: make(chan T, capacity)}
+	return BlockingPool[T]{pool: make(chan T, capacity),
+		counters: &poolCounters{}}
 }
 
 // Get acquires an object from the pool, blocking until one is available.
@@ -38,7 +48,10 @@ func NewBlockingPool[T any](capacity int) BlockingPool[T] {
 //
 // It is the caller's responsibility to eventually call .Put() with the
 // returned object (or a replacement) to release it back to the pool.
-func (p *BlockingPool[T]) Get() T { return <-p.pool }
+func (p *BlockingPool[T]) Get() T {
+	obj, _ := p.GetCtx(context.Background())
+	return obj
+}
 
 // Put returns an object to the pool, blocking until there is space available.
 //
@@ -46,4 +59,59 @@ func (p *BlockingPool[T]) Get() T { return <-p.pool }
 // goroutine calls .Get().
 //
 // After a successful Put(), the object becomes available for .Get() calls.
-func (p *BlockingPool[T]) Put(obj T) { p.pool <- obj }
+func (p *BlockingPool[T]) Put(obj T) { _ = p.PutCtx(context.Background(), obj) }
+
+// GetCtx acquires an object from the pool like Get, but gives up when ctx is
+// done and returns ctx.Err() instead.
+func (p *BlockingPool[T]) GetCtx(ctx context.Context) (T, error) {
+	if obj, ok := p.TryGet(); ok {
+		return obj, nil
+	}
+
+	start := time.Now()
+	select {
+	case obj := <-p.pool:
+		p.recordGet(time.Since(start))
+		return obj, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// PutCtx returns an object to the pool like Put, but gives up when ctx is done
+// and returns ctx.Err() instead. The object is then not in the pool.
+func (p *BlockingPool[T]) PutCtx(ctx context.Context, obj T) error {
+	select {
+	case p.pool <- obj:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	select {
+	case p.pool <- obj:
+		p.recordPut(time.Since(start))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryGet acquires an object from the pool without blocking. It returns false
+// if the pool is empty.
+func (p *BlockingPool[T]) TryGet() (T, bool) {
+	select {
+	case obj := <-p.pool:
+		p.recordGet(0)
+		return obj, true
+	default:
+		var zero T
+		return zero, false
+	}
+}
+
+// Len returns the number of objects currently available in the pool. The
+// value may be stale by the time it is used when other goroutines share the
+// pool.
+func (p *BlockingPool[T]) Len() int { return len(p.pool) }
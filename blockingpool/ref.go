@@ -0,0 +1,62 @@
+package blockingpool
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Ref is a reference counted handle to an object of a BlockingPool. It lets
+// several consumers share one object without copying it: every consumer past
+// the first takes its own reference with Retain, every consumer drops its
+// reference with Release, and the object goes back to the pool when the last
+// reference is released.
+//
+// The object must not be used after the caller's reference is released, as
+// the pool may already have handed it out again.
+type Ref[T any] struct {
+	value T
+	refs  atomic.Int32
+	pool  *BlockingPool[T]
+}
+
+// GetRef acquires an object from the pool like Get, blocking until one is
+// available, and wraps it in a Ref holding one reference.
+func (p *BlockingPool[T]) GetRef() *Ref[T] {
+	return p.NewRef(p.Get())
+}
+
+// NewRef wraps obj, which must belong to the pool, in a Ref holding one
+// reference. Releasing the last reference Puts obj into the pool.
+func (p *BlockingPool[T]) NewRef(obj T) *Ref[T] {
+	r := &Ref[T]{value: obj, pool: p}
+	r.refs.Store(1)
+	return r
+}
+
+// Value returns the referenced object. It is only valid while the caller
+// holds a reference.
+func (r *Ref[T]) Value() T { return r.value }
+
+// Retain takes another reference to the object and returns r, so it can be
+// handed to another consumer. It panics if every reference has already been
+// released.
+func (r *Ref[T]) Retain() *Ref[T] {
+	if r.refs.Add(1) <= 1 {
+		panic("blockingpool: Retain of a released Ref")
+	}
+	return r
+}
+
+// Release drops one reference. Releasing the last reference returns the
+// object to its pool, blocking like Put if the pool is full. It panics if
+// called more often than the object was referenced, which would otherwise
+// put the same object into the pool twice.
+func (r *Ref[T]) Release() {
+	switch refs := r.refs.Add(-1); {
+	case refs == 0:
+		r.pool.Put(r.value)
+	case refs < 0:
+		panic(fmt.Sprintf("blockingpool: Ref released %d times too often",
+			-refs))
+	}
+}
@@ -0,0 +1,80 @@
+package blockingpool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the instrumentation of a BlockingPool, see
+// BlockingPool.Stats.
+type Stats struct {
+	// Capacity is the number of objects the pool holds when none is checked
+	// out.
+	Capacity int
+	// Gets is the number of objects acquired with Get, GetCtx and TryGet.
+	Gets int64
+	// Starved is the number of Gets that found the pool empty and had to
+	// wait. Failed TryGets are not counted.
+	Starved int64
+	// GetWait is the total time spent waiting in Get and GetCtx, summed over
+	// concurrent callers.
+	GetWait time.Duration
+	// PutWait is the total time spent waiting in Put and PutCtx for the pool
+	// to have room.
+	PutWait time.Duration
+	// HighWater is the most objects that were checked out at once.
+	HighWater int
+}
+
+// poolCounters accumulates the Stats of a pool. It is shared by every copy of
+// the pool.
+type poolCounters struct {
+	gets, starved    atomic.Int64
+	getWait, putWait atomic.Int64
+	highWater        atomic.Int64
+}
+
+// Stats returns a snapshot of the instrumentation of the pool. It may be
+// called while the pool is in use.
+func (p *BlockingPool[T]) Stats() Stats {
+	if p.counters == nil {
+		return Stats{Capacity: cap(p.pool)}
+	}
+
+	c := p.counters
+	return Stats{Capacity: cap(p.pool), Gets: c.gets.Load(),
+		Starved:   c.starved.Load(),
+		GetWait:   time.Duration(c.getWait.Load()),
+		PutWait:   time.Duration(c.putWait.Load()),
+		HighWater: int(c.highWater.Load())}
+}
+
+// recordGet counts one acquired object that took waited to get, 0 if it was
+// available at once.
+func (p *BlockingPool[T]) recordGet(waited time.Duration) {
+	c := p.counters
+	if c == nil {
+		return
+	}
+
+	c.gets.Add(1)
+	if waited > 0 {
+		c.starved.Add(1)
+		c.getWait.Add(int64(waited))
+	}
+
+	out := int64(cap(p.pool) - len(p.pool))
+	for {
+		high := c.highWater.Load()
+		if out <= high || c.highWater.CompareAndSwap(high, out) {
+			return
+		}
+	}
+}
+
+// recordPut counts the time a Put waited for room in the pool.
+func (p *BlockingPool[T]) recordPut(waited time.Duration) {
+	if p.counters != nil && waited > 0 {
+		p.counters.putWait.Add(int64(waited))
+	}
+}
@@ -0,0 +1,15 @@
+//go:build cgo && !nocgo && ffms2_nopkgconfig
+
+package libavpixfmts
+
+// Fallback flags for systems without pkg-config, matching those of
+// c/libffms2. Anything else should be passed through CGO_CFLAGS and
+// CGO_LDFLAGS.
+
+//#cgo LDFLAGS: -lavutil
+//#cgo linux CFLAGS: -I/usr/include
+//#cgo darwin,arm64 CFLAGS: -I/opt/homebrew/include
+//#cgo darwin,arm64 LDFLAGS: -L/opt/homebrew/lib
+//#cgo darwin,amd64 CFLAGS: -I/usr/local/include
+//#cgo darwin,amd64 LDFLAGS: -L/usr/local/lib
+import "C"
@@ -0,0 +1,10 @@
+//go:build cgo && !nocgo && !ffms2_nopkgconfig
+
+package libavpixfmts
+
+// libavutil ships a libavutil.pc file wherever FFmpeg is packaged. Build with
+// -tags ffms2_nopkgconfig to use the fixed paths in cgo_nopkgconfig.go
+// instead, like c/libffms2.
+
+//#cgo pkg-config: libavutil
+import "C"
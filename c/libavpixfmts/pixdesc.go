@@ -1,7 +1,8 @@
+//go:build cgo && !nocgo
+
 package libavpixfmts
 
 /*
-#cgo pkg-config: libavutil
 #include <libavutil/pixdesc.h>
 #include <stdlib.h>
 */
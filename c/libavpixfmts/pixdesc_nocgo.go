@@ -0,0 +1,307 @@
+//go:build nocgo || !cgo
+
+package libavpixfmts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Pure Go subset of pixdesc.go used when building without cgo. Descriptors
+// are only available for the formats defined in pixfmt_nocgo.go.
+
+type PixFmtFlag int
+
+const (
+	PixFmtFlagBigEndian PixFmtFlag = 1 << 0  // Pixel format is big-endian.
+	PixFmtFlagPAL       PixFmtFlag = 1 << 1  // Pixel format has a palette in data[1], values are indexes in this palette.
+	PixFmtFlagBitstream PixFmtFlag = 1 << 2  // All values of a component are bit-wise packed end to end.
+	PixFmtFlagHWAccel   PixFmtFlag = 1 << 3  // Pixel format is an HW accelerated format.
+	PixFmtFlagPlanar    PixFmtFlag = 1 << 4  // At least one pixel component is not in the first data plane.
+	PixFmtFlagRGB       PixFmtFlag = 1 << 5  // The pixel format contains RGB-like data (as opposed to YUV/grayscale).
+	PixFmtFlagAlpha     PixFmtFlag = 1 << 7  //
+	PixFmtFlagBayer     PixFmtFlag = 1 << 8  // The pixel format is following a Bayer pattern
+	PixFmtFlagFloat     PixFmtFlag = 1 << 9  // The pixel format contains IEEE-754 floating point values.
+	PixFmtFlagXYZ       PixFmtFlag = 1 << 10 // The pixel format contains XYZ-like data (as opposed to YUV/RGB/grayscale).
+)
+
+// PixFmtDescRef is a Descriptor that unambiguously describes how the bits of a
+// pixel are stored in the up to 4 data planes of an image. It also stores the
+// subsampling factors and number of components.
+type PixFmtDescRef struct {
+	id          PixelFormat
+	name        string
+	log2ChromaW int
+	log2ChromaH int
+	flags       uint64
+	comp        []ComponentDescriptor
+}
+
+// ComponentDescriptor is a Descriptor of one of the 4 planes within a
+// PixFmtDescRef.
+type ComponentDescriptor struct {
+	Plane  int
+	Step   int
+	Offset int
+	Shift  int
+	Depth  int
+}
+
+var (
+	ErrUnknownPixelFormat = errors.New("unknown pixel format")
+	ErrInvalidArgument    = errors.New("invalid argument")
+)
+
+// planarDesc builds the descriptor of a little-endian planar format with one
+// component per plane.
+func planarDesc(id PixelFormat, name string, components, depth, log2W,
+	log2H int, flags PixFmtFlag) *PixFmtDescRef {
+	step := 1
+	if depth > 8 {
+		step = 2
+	}
+
+	desc := &PixFmtDescRef{id: id, name: name, log2ChromaW: log2W,
+		log2ChromaH: log2H, flags: uint64(flags)}
+	for i := range components {
+		desc.comp = append(desc.comp, ComponentDescriptor{Plane: i,
+			Step: step, Depth: depth})
+	}
+
+	// GBR formats store green in plane 0, but component 0 is red.
+	if flags&PixFmtFlagRGB != 0 {
+		desc.comp[0].Plane, desc.comp[1].Plane, desc.comp[2].Plane = 2, 0, 1
+	}
+
+	return desc
+}
+
+// packedDesc builds the descriptor of a little-endian packed RGB format.
+// offsets holds the sample index of R, G, B and optionally A within a pixel.
+func packedDesc(id PixelFormat, name string, depth int,
+	offsets ...int) *PixFmtDescRef {
+	sampleSize := 1
+	if depth > 8 {
+		sampleSize = 2
+	}
+
+	flags := PixFmtFlagRGB
+	if len(offsets) == 4 {
+		flags |= PixFmtFlagAlpha
+	}
+
+	desc := &PixFmtDescRef{id: id, name: name, flags: uint64(flags)}
+	for _, offset := range offsets {
+		desc.comp = append(desc.comp, ComponentDescriptor{
+			Step: sampleSize * len(offsets), Offset: sampleSize * offset,
+			Depth: depth})
+	}
+
+	return desc
+}
+
+var pixFmtDescriptors = func() map[PixelFormat]*PixFmtDescRef {
+	const yuv, rgb = PixFmtFlagPlanar, PixFmtFlagPlanar | PixFmtFlagRGB
+	const yuva, rgba = yuv | PixFmtFlagAlpha, rgb | PixFmtFlagAlpha
+
+	descs := []*PixFmtDescRef{
+		planarDesc(PixFmtYUV420P, "yuv420p", 3, 8, 1, 1, yuv),
+		planarDesc(PixFmtYUV422P, "yuv422p", 3, 8, 1, 0, yuv),
+		planarDesc(PixFmtYUV444P, "yuv444p", 3, 8, 0, 0, yuv),
+		planarDesc(PixFmtGray8, "gray", 1, 8, 0, 0, 0),
+		planarDesc(PixFmtYUVJ420P, "yuvj420p", 3, 8, 1, 1, yuv),
+		planarDesc(PixFmtYUVJ422, "yuvj422p", 3, 8, 1, 0, yuv),
+		planarDesc(PixFmtYUVJ444P, "yuvj444p", 3, 8, 0, 0, yuv),
+		planarDesc(PixFmtGray16LE, "gray16le", 1, 16, 0, 0, 0),
+		planarDesc(PixFmtYUVA420P, "yuva420p", 4, 8, 1, 1, yuva),
+		planarDesc(PixFmtYUV420P16LE, "yuv420p16le", 3, 16, 1, 1, yuv),
+		planarDesc(PixFmtYUV422P16LE, "yuv422p16le", 3, 16, 1, 0, yuv),
+		planarDesc(PixFmYUV444P16LE, "yuv444p16le", 3, 16, 0, 0, yuv),
+		planarDesc(PixFmtYUV420P9LE, "yuv420p9le", 3, 9, 1, 1, yuv),
+		planarDesc(PixFmtYUV420P10LE, "yuv420p10le", 3, 10, 1, 1, yuv),
+		planarDesc(PixFmtYUV422P10LE, "yuv422p10le", 3, 10, 1, 0, yuv),
+		planarDesc(PixFmtYUV444P9LE, "yuv444p9le", 3, 9, 0, 0, yuv),
+		planarDesc(PixFmtYUV444P10LE, "yuv444p10le", 3, 10, 0, 0, yuv),
+		planarDesc(PixFmtYUV422P9LE, "yuv422p9le", 3, 9, 1, 0, yuv),
+		planarDesc(PixFmtGBRP, "gbrp", 3, 8, 0, 0, rgb),
+		planarDesc(PixFmtGBRP9LE, "gbrp9le", 3, 9, 0, 0, rgb),
+		planarDesc(PixFmtGBRP10LE, "gbrp10le", 3, 10, 0, 0, rgb),
+		planarDesc(PixFmtGBRP16LE, "gbrp16le", 3, 16, 0, 0, rgb),
+		planarDesc(PixFmtYUVA444P, "yuva444p", 4, 8, 0, 0, yuva),
+		planarDesc(PixFmtGBRAP, "gbrap", 4, 8, 0, 0, rgba),
+		planarDesc(PixFmtGBRAP16LE, "gbrap16le", 4, 16, 0, 0, rgba),
+		packedDesc(PixFmtRGB24, "rgb24", 8, 0, 1, 2),
+		packedDesc(PixFmtBGR24, "bgr24", 8, 2, 1, 0),
+		packedDesc(PixFmtARGB, "argb", 8, 1, 2, 3, 0),
+		packedDesc(PixFmtRGBA, "rgba", 8, 0, 1, 2, 3),
+		packedDesc(PixFmtARGR, "abgr", 8, 3, 2, 1, 0),
+		packedDesc(PixFmtBGRA, "bgra", 8, 2, 1, 0, 3),
+		packedDesc(PixFmtRGB48LE, "rgb48le", 16, 0, 1, 2),
+		packedDesc(PixFmtRGBA64LE, "rgba64le", 16, 0, 1, 2, 3),
+		planarDesc(PixFmtYUV420P12LE, "yuv420p12le", 3, 12, 1, 1, yuv),
+		planarDesc(PixFmtYUV420P14LE, "yuv420p14le", 3, 14, 1, 1, yuv),
+		planarDesc(PixFmtYUV422P12LE, "yuv422p12le", 3, 12, 1, 0, yuv),
+		planarDesc(PixFmtYUV422P14LE, "yuv422p14le", 3, 14, 1, 0, yuv),
+		planarDesc(PixFmtYUV444P12LE, "yuv444p12le", 3, 12, 0, 0, yuv),
+		planarDesc(PixFmtYUV444P14LE, "yuv444p14le", 3, 14, 0, 0, yuv),
+		planarDesc(PixFmtGBRP12LE, "gbrp12le", 3, 12, 0, 0, rgb),
+		planarDesc(PixFmtGBRP14LE, "gbrp14le", 3, 14, 0, 0, rgb),
+	}
+
+	table := make(map[PixelFormat]*PixFmtDescRef, len(descs))
+	for _, desc := range descs {
+		table[desc.id] = desc
+	}
+	return table
+}()
+
+// ---------------- Descriptor acquisition ----------------
+
+// PixFmtDescGet returns a description of the given pixel format.
+//
+// If the pixel format is unknown, or not part of the subset available without
+// cgo, the function returns ErrUnknownPixelFormat.
+func PixFmtDescGet(pf PixelFormat) (*PixFmtDescRef, error) {
+	desc, ok := pixFmtDescriptors[pf]
+	if !ok {
+		return nil, ErrUnknownPixelFormat
+	}
+	return desc, nil
+}
+
+// PixFmtDescID returns the PixelFormat described by this descriptor.
+func (desc *PixFmtDescRef) PixFmtDescID() (PixelFormat, error) {
+	if desc == nil {
+		return PixFmtNone, ErrInvalidArgument
+	}
+	return desc.id, nil
+}
+
+// Name returns the canonical name of the pixel format, e.g. "yuv420p". A nil
+// descriptor returns an empty string.
+func (r *PixFmtDescRef) Name() string {
+	if r == nil {
+		return ""
+	}
+	return r.name
+}
+
+// NbComponents returns the number of color components in this pixel format.
+func (r *PixFmtDescRef) NbComponents() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.comp)
+}
+
+// Log2ChromaW returns the horizontal chroma subsampling factor in base-2.
+func (r *PixFmtDescRef) Log2ChromaW() int {
+	if r == nil {
+		return 0
+	}
+	return r.log2ChromaW
+}
+
+// Log2ChromaH returns the vertical chroma subsampling factor in base-2.
+func (r *PixFmtDescRef) Log2ChromaH() int {
+	if r == nil {
+		return 0
+	}
+	return r.log2ChromaH
+}
+
+// Flags returns a bitmask of PixFmtFlag values describing this pixel format.
+func (r *PixFmtDescRef) Flags() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.flags
+}
+
+// Component returns the descriptor for the i-th color component of this pixel
+// format. The index i must be between 0 and 3.
+func (r *PixFmtDescRef) Component(i int) (ComponentDescriptor, error) {
+	var zero ComponentDescriptor
+	if r == nil {
+		return zero, ErrInvalidArgument
+	}
+	if i < 0 || i >= 4 {
+		return zero, fmt.Errorf("component index out of range: %d", i)
+	}
+	if i >= len(r.comp) {
+		return zero, nil
+	}
+	return r.comp[i], nil
+}
+
+// Alias always returns an empty string, none of the formats available without
+// cgo have one.
+func (r *PixFmtDescRef) Alias() string {
+	return ""
+}
+
+// GetBitsPerPixel returns the number of meaningful bits per pixel for this
+// format.
+func GetBitsPerPixel(r *PixFmtDescRef) (int, error) {
+	if r == nil {
+		return 0, ErrInvalidArgument
+	}
+
+	var bits int
+	for i, comp := range r.comp {
+		shift := 0
+		if i == 1 || i == 2 {
+			shift = r.log2ChromaW + r.log2ChromaH
+		}
+		bits += comp.Depth << (r.log2ChromaW + r.log2ChromaH - shift)
+	}
+	return bits >> (r.log2ChromaW + r.log2ChromaH), nil
+}
+
+// PixFmtGetChromaSubSample returns the horizontal and vertical chroma shift
+// factors for the given pixel format.
+func PixFmtGetChromaSubSample(pf PixelFormat) (hShift int, vShift int, err error) {
+	desc, err := PixFmtDescGet(pf)
+	if err != nil {
+		return 0, 0, err
+	}
+	return desc.log2ChromaW, desc.log2ChromaH, nil
+}
+
+// PixFmtCountPlanes returns the number of image planes used by the given pixel
+// format.
+func PixFmtCountPlanes(pf PixelFormat) (int, error) {
+	desc, err := PixFmtDescGet(pf)
+	if err != nil {
+		return 0, err
+	}
+
+	var planes int
+	for _, comp := range desc.comp {
+		planes = max(planes, comp.Plane+1)
+	}
+	return planes, nil
+}
+
+// GetPixFmt returns the PixelFormat with the given canonical name.
+func GetPixFmt(name string) (PixelFormat, error) {
+	if name == "" {
+		return PixFmtNone, ErrInvalidArgument
+	}
+	for id, desc := range pixFmtDescriptors {
+		if desc.name == name {
+			return id, nil
+		}
+	}
+	return PixFmtNone, fmt.Errorf("pixel format not found: %s", name)
+}
+
+// GetPixFmtName returns the canonical FFmpeg name for a given PixelFormat
+// enumeration value, or an empty string if it is unknown.
+func GetPixFmtName(pf PixelFormat) string {
+	desc, err := PixFmtDescGet(pf)
+	if err != nil {
+		return ""
+	}
+	return desc.name
+}
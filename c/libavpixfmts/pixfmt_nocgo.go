@@ -0,0 +1,167 @@
+//go:build nocgo || !cgo
+
+package libavpixfmts
+
+// Pure Go subset of pixfmt.go used when building without cgo. Only the planar
+// YUV, RGB and gray formats, with or without alpha, and the common packed RGB
+// formats a comparison can actually consume are defined. The
+// values mirror libavutil's so they are interchangeable with a cgo build.
+
+const (
+	PaletteSiz     int = 1024
+	PaletteCount   int = 256
+	VideoMaxPlanes int = 4
+)
+
+type PixelFormat int
+
+const (
+	PixFmtNone        PixelFormat = -1  //
+	PixFmtYUV420P     PixelFormat = 0   // planar YUV 4:2:0, 12bpp, (1 Cr & Cb sample per 2x2 Y samples)
+	PixFmtRGB24       PixelFormat = 2   // packed RGB 8:8:8, 24bpp, RGBRGB...
+	PixFmtBGR24       PixelFormat = 3   // packed RGB 8:8:8, 24bpp, BGRBGR...
+	PixFmtYUV422P     PixelFormat = 4   // planar YUV 4:2:2, 16bpp, (1 Cr & Cb sample per 2x1 Y samples)
+	PixFmtYUV444P     PixelFormat = 5   // planar YUV 4:4:4, 24bpp, (1 Cr & Cb sample per 1x1 Y samples)
+	PixFmtGray8       PixelFormat = 8   //        Y        ,  8bpp
+	PixFmtYUVJ420P    PixelFormat = 12  // planar YUV 4:2:0, 12bpp, full scale (JPEG)
+	PixFmtYUVJ422     PixelFormat = 13  // planar YUV 4:2:2, 16bpp, full scale (JPEG)
+	PixFmtYUVJ444P    PixelFormat = 14  // planar YUV 4:4:4, 24bpp, full scale (JPEG)
+	PixFmtARGB        PixelFormat = 25  // packed ARGB 8:8:8:8, 32bpp, ARGBARGB...
+	PixFmtRGBA        PixelFormat = 26  // packed RGBA 8:8:8:8, 32bpp, RGBARGBA...
+	PixFmtARGR        PixelFormat = 27  // packed ABGR 8:8:8:8, 32bpp, ABGRABGR... (sic, matches pixfmt.go)
+	PixFmtBGRA        PixelFormat = 28  // packed BGRA 8:8:8:8, 32bpp, BGRABGRA...
+	PixFmtGray16LE    PixelFormat = 30  //        Y        , 16bpp, little-endian
+	PixFmtYUVA420P    PixelFormat = 33  // planar YUV 4:2:0, 20bpp, (1 Cr & Cb sample per 2x2 Y & A samples)
+	PixFmtRGB48LE     PixelFormat = 35  // packed RGB 16:16:16, 48bpp, little-endian
+	PixFmtYUV420P16LE PixelFormat = 45  // planar YUV 4:2:0, 24bpp, little-endian
+	PixFmtYUV422P16LE PixelFormat = 47  // planar YUV 4:2:2, 32bpp, little-endian
+	PixFmYUV444P16LE  PixelFormat = 49  // planar YUV 4:4:4, 48bpp, little-endian (sic, matches pixfmt.go)
+	PixFmtYUV420P9LE  PixelFormat = 60  // planar YUV 4:2:0, 13.5bpp, little-endian
+	PixFmtYUV420P10LE PixelFormat = 62  // planar YUV 4:2:0, 15bpp, little-endian
+	PixFmtYUV422P10LE PixelFormat = 64  // planar YUV 4:2:2, 20bpp, little-endian
+	PixFmtYUV444P9LE  PixelFormat = 66  // planar YUV 4:4:4, 27bpp, little-endian
+	PixFmtYUV444P10LE PixelFormat = 68  // planar YUV 4:4:4, 30bpp, little-endian
+	PixFmtYUV422P9LE  PixelFormat = 70  // planar YUV 4:2:2, 18bpp, little-endian
+	PixFmtGBRP        PixelFormat = 71  // planar GBR 4:4:4 24bpp
+	PixFmtGBRP9LE     PixelFormat = 73  // planar GBR 4:4:4 27bpp, little-endian
+	PixFmtGBRP10LE    PixelFormat = 75  // planar GBR 4:4:4 30bpp, little-endian
+	PixFmtGBRP16LE    PixelFormat = 77  // planar GBR 4:4:4 48bpp, little-endian
+	PixFmtYUVA444P    PixelFormat = 79  // planar YUV 4:4:4 32bpp, (1 Cr & Cb sample per 1x1 Y & A samples)
+	PixFmtRGBA64LE    PixelFormat = 105 // packed RGBA 16:16:16:16, 64bpp, little-endian
+	PixFmtGBRAP       PixelFormat = 111 // planar GBRA 4:4:4:4 32bpp
+	PixFmtGBRAP16LE   PixelFormat = 113 // planar GBRA 4:4:4:4 64bpp, little-endian
+	PixFmtYUV420P12LE PixelFormat = 123 // planar YUV 4:2:0, 18bpp, little-endian
+	PixFmtYUV420P14LE PixelFormat = 125 // planar YUV 4:2:0, 21bpp, little-endian
+	PixFmtYUV422P12LE PixelFormat = 127 // planar YUV 4:2:2, 24bpp, little-endian
+	PixFmtYUV422P14LE PixelFormat = 129 // planar YUV 4:2:2, 28bpp, little-endian
+	PixFmtYUV444P12LE PixelFormat = 131 // planar YUV 4:4:4, 36bpp, little-endian
+	PixFmtYUV444P14LE PixelFormat = 133 // planar YUV 4:4:4, 42bpp, little-endian
+	PixFmtGBRP12LE    PixelFormat = 135 // planar GBR 4:4:4 36bpp, little-endian
+	PixFmtGBRP14LE    PixelFormat = 137 // planar GBR 4:4:4 42bpp, little-endian
+)
+
+// Chromaticity coordinates of the source primaries.
+//
+// These values match the ones defined by ISO/IEC 23091-2_2019 subclause 8.1 and ITU-T H.273.
+type ColorPrimaries int
+
+const (
+	ColorPrimariesReserved0    ColorPrimaries = 0
+	ColorPrimariesBT709        ColorPrimaries = 1
+	ColorPrimariesUnspecified  ColorPrimaries = 2
+	ColorPrimariesReserved     ColorPrimaries = 3
+	ColorPrimariesBT470M       ColorPrimaries = 4
+	ColorPrimariesBT470BG      ColorPrimaries = 5
+	ColorPrimariesSMPTE170M    ColorPrimaries = 6
+	ColorPrimariesSMPTE240M    ColorPrimaries = 7
+	ColorPrimariesFilm         ColorPrimaries = 8
+	ColorPrimariesBT2020       ColorPrimaries = 9
+	ColorPrimariesSMPTE428     ColorPrimaries = 10
+	ColorPrimariesSMPTEST428_1 ColorPrimaries = 10
+	ColorPrimariesSMPTE431     ColorPrimaries = 11
+	ColorPrimariesSMPTE432     ColorPrimaries = 12
+	ColorPrimariesEBU3213      ColorPrimaries = 22
+	ColorPrimariesJEDEC_P22    ColorPrimaries = 22
+	ColorPrimariesNB           ColorPrimaries = 23
+)
+
+// Color Transfer Characteristic.
+//
+// These values match the ones defined by ISO/IEC 23091-2_2019 subclause 8.2.
+type ColorTransferCharacteristic int
+
+const (
+	ColorTransferCharacteristicReserved0    ColorTransferCharacteristic = 0
+	ColorTransferCharacteristicBT709        ColorTransferCharacteristic = 1
+	ColorTransferCharacteristicUnspecified  ColorTransferCharacteristic = 2
+	ColorTransferCharacteristicReserved     ColorTransferCharacteristic = 3
+	ColorTransferCharacteristicGamma22      ColorTransferCharacteristic = 4
+	ColorTransferCharacteristicGamma28      ColorTransferCharacteristic = 5
+	ColorTransferCharacteristicSMPTE170M    ColorTransferCharacteristic = 6
+	ColorTransferCharacteristicSMPTE240M    ColorTransferCharacteristic = 7
+	ColorTransferCharacteristicLinear       ColorTransferCharacteristic = 8
+	ColorTransferCharacteristicLog          ColorTransferCharacteristic = 9
+	ColorTransferCharacteristicLogSqrt      ColorTransferCharacteristic = 10
+	ColorTransferCharacteristicIEC61966_2_4 ColorTransferCharacteristic = 11
+	ColorTransferCharacteristicBT1361_ECG   ColorTransferCharacteristic = 12
+	ColorTransferCharacteristicIEC61966_2_1 ColorTransferCharacteristic = 13
+	ColorTransferCharacteristicBT2020_10    ColorTransferCharacteristic = 14
+	ColorTransferCharacteristicBT2020_12    ColorTransferCharacteristic = 15
+	ColorTransferCharacteristicSMPTE2084    ColorTransferCharacteristic = 16
+	ColorTransferCharacteristicSMPTEST2084  ColorTransferCharacteristic = 16
+	ColorTransferCharacteristicSMPTE428     ColorTransferCharacteristic = 17
+	ColorTransferCharacteristicSMPTEST428_1 ColorTransferCharacteristic = 17
+	ColorTransferCharacteristicARIB_STD_B67 ColorTransferCharacteristic = 18
+	ColorTransferCharacteristicNB           ColorTransferCharacteristic = 19
+)
+
+// YUV colorspace type.
+//
+// These values match the ones defined by ISO/IEC 23091-2_2019 subclause 8.3.
+type ColorSpace int
+
+const (
+	ColorSpaceRGB                ColorSpace = 0
+	ColorSpaceBT709              ColorSpace = 1
+	ColorSpaceUnspecified        ColorSpace = 2
+	ColorSpaceReserved           ColorSpace = 3
+	ColorSpaceFCC                ColorSpace = 4
+	ColorSpaceBT470BG            ColorSpace = 5
+	ColorSpaceSMPTE170M          ColorSpace = 6
+	ColorSpaceSMPTE240M          ColorSpace = 7
+	ColorSpaceYCGCO              ColorSpace = 8
+	ColorSpaceYCOCG              ColorSpace = 8
+	ColorSpaceBT2020_NCL         ColorSpace = 9
+	ColorSpaceBT2020_CL          ColorSpace = 10
+	ColorSpaceSMPTE2085          ColorSpace = 11
+	ColorSpaceCHROMA_DERIVED_NCL ColorSpace = 12
+	ColorSpaceCHROMA_DERIVED_CL  ColorSpace = 13
+	ColorSpaceICTCP              ColorSpace = 14
+	ColorSpaceIPT_C2             ColorSpace = 15
+	ColorSpaceYCGCO_RE           ColorSpace = 16
+	ColorSpaceYCGCO_RO           ColorSpace = 17
+	ColorSpaceNB                 ColorSpace = 18
+)
+
+// Visual content value range. See pixfmt.go for the full description.
+type ColorRange int
+
+const (
+	ColorRangeUnspecified ColorRange = 0
+	ColorRangeMPEG        ColorRange = 1 // Narrow or limited range content.
+	ColorRangeJPEG        ColorRange = 2 // Full range content.
+)
+
+// Location of chroma samples. See pixfmt.go for the full description.
+type ChromaLocation int
+
+const (
+	ChromaLocationUnspecified ChromaLocation = 0
+	ChromaLocationLeft        ChromaLocation = 1
+	ChromaLocationCenter      ChromaLocation = 2
+	ChromaLocationTopLeft     ChromaLocation = 3
+	ChromaLocationTop         ChromaLocation = 4
+	ChromaLocationBottomleft  ChromaLocation = 5
+	ChromaLocationBottom      ChromaLocation = 6
+	ChromaLocationNB          ChromaLocation = 7
+)
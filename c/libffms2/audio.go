@@ -1,3 +1,165 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-// TODO: implement FFMS_AudioSource
+//#include <ffms.h>
+//#include <stdlib.h>
+import "C"
+import (
+	"errors"
+	"runtime"
+	"unsafe"
+)
+
+var (
+	ErrInvalidOrNilAudioSource error = errors.New("audio source was consumed, failed to create, or was destroyed")
+	ErrAudioRangeOutOfBounds         = errors.New("requested audio samples are outside of the audio track")
+	ErrGetAudioFailed                = errors.New("ffms failed to decode the requested audio. check error info for more info")
+)
+
+// Creates an audio source from the given track of an index. The index must
+// have been created with the audio track enabled, see
+// Indexer.TrackTypeIndexSettings.
+//
+// delayMode controls how the first sample is placed in time. With
+// DelayFirstVideoTrack sample 0 lines up with the first frame of the first
+// video track, which is what is wanted when comparing audio against video.
+func CreateAudioSource(sourceFile string, index *Index, track int,
+	delayMode AudioDelayMode) (*AudioSource, *ErrorInfo, error) {
+
+	if err := index.checkValidity(); err != nil {
+		return nil, nil, err
+	}
+
+	var sourceFileC *C.char = C.CString(sourceFile)
+	defer safeFree(sourceFileC)
+
+	fn := func(c *C.FFMS_ErrorInfo) *C.FFMS_AudioSource {
+		return C.FFMS_CreateAudioSource(sourceFileC, C.int(track), index.index,
+			C.int(delayMode), c)
+	}
+
+	res, info, err := withErrorInfo(fn)
+	runtime.KeepAlive(index)
+	if err != nil {
+		return nil, info, err
+	}
+
+	cAudioProperties := C.FFMS_GetAudioProperties(res)
+	if cAudioProperties == nil {
+		C.FFMS_DestroyAudioSource(res)
+		return nil, info, ErrFFmsNilPtrReturn
+	}
+
+	as := &AudioSource{source: res,
+		props: ffmsAudioPropertiesFromC(cAudioProperties)}
+	as.cleanup = registerCleanup(as, res, "AudioSource",
+		func(ptr *C.FFMS_AudioSource) { C.FFMS_DestroyAudioSource(ptr) })
+
+	return as, info, nil
+}
+
+func (as *AudioSource) GetAudioProperties() (AudioProperties, error) {
+	if err := as.checkValidity(); err != nil {
+		return AudioProperties{}, err
+	}
+
+	return as.props, nil
+}
+
+// SampleSize returns the size in bytes of one sample of one channel for the
+// sources sample format.
+func (as *AudioSource) SampleSize() (int, error) {
+	if err := as.checkValidity(); err != nil {
+		return 0, err
+	}
+
+	switch SampleFormat(as.props.SampleFormat) {
+	case FmtU8:
+		return 1, nil
+	case FmtS16:
+		return 2, nil
+	case FmtS32, FmtFlt:
+		return 4, nil
+	case FmtDbl:
+		return 8, nil
+	default:
+		return 0, errors.New("unknown audio sample format")
+	}
+}
+
+// Decodes count samples starting at sample start and returns them as
+// interleaved bytes in the sources sample format. A sample here is one sample
+// for every channel, so the returned slice is count * Channels * SampleSize()
+// bytes long.
+func (as *AudioSource) GetAudio(start, count int64) ([]byte, *ErrorInfo,
+	error) {
+	if err := as.checkValidity(); err != nil {
+		return nil, nil, err
+	}
+
+	if start < 0 || count <= 0 || start+count > as.props.NumSamples {
+		return nil, nil, ErrAudioRangeOutOfBounds
+	}
+
+	sampleSize, err := as.SampleSize()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := uint(count) * uint(as.props.Channels) * uint(sampleSize)
+
+	// Decode into C memory for the same reason withErrorInfo does.
+	buffer, err := safeMalloc[byte](size)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer safeFree(buffer)
+
+	res, info, err := withErrorInfo(func(c *C.FFMS_ErrorInfo) C.int {
+		return C.FFMS_GetAudio(as.source, unsafe.Pointer(buffer),
+			C.int64_t(start), C.int64_t(count), c)
+	})
+	runtime.KeepAlive(as)
+	if err != nil {
+		return nil, info, err
+	}
+	if res != 0 {
+		return nil, info, ErrGetAudioFailed
+	}
+
+	return C.GoBytes(unsafe.Pointer(buffer), C.int(size)), info, nil
+}
+
+func (as *AudioSource) GetTrack() (Track, error) {
+	if err := as.checkValidity(); err != nil {
+		return Track{}, err
+	}
+
+	var ptr *C.FFMS_Track = C.FFMS_GetTrackFromAudio(as.source)
+	if ptr == nil {
+		return Track{}, ErrFFmsNilPtrReturn
+	}
+
+	return Track{ptr}, nil
+}
+
+func (as AudioSource) checkValidity() error {
+	if as.source == nil {
+		return ErrInvalidOrNilAudioSource
+	}
+
+	return nil
+}
+
+func (as *AudioSource) Close() error {
+	if err := as.checkValidity(); err != nil {
+		return err
+	}
+
+	as.cleanup.Stop()
+	C.FFMS_DestroyAudioSource(as.source)
+	as.source = nil
+
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build cgo && !nocgo && ffms2_nopkgconfig
+
+package libffms2
+
+// Fallback flags for systems without pkg-config. These cover the default
+// install prefixes of each platform; anything else should be passed through
+// CGO_CFLAGS and CGO_LDFLAGS.
+
+//#cgo LDFLAGS: -lffms2 -lavutil
+//#cgo linux CFLAGS: -I/usr/include
+//#cgo darwin,arm64 CFLAGS: -I/opt/homebrew/include
+//#cgo darwin,arm64 LDFLAGS: -L/opt/homebrew/lib
+//#cgo darwin,amd64 CFLAGS: -I/usr/local/include
+//#cgo darwin,amd64 LDFLAGS: -L/usr/local/lib
+import "C"
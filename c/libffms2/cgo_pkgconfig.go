@@ -0,0 +1,12 @@
+//go:build cgo && !nocgo && !ffms2_nopkgconfig
+
+package libffms2
+
+// FFMS2 ships an ffms2.pc file on every platform it is packaged for (Linux
+// distributions, Homebrew, MSYS2 and vcpkg), so pkg-config is the default way
+// of locating it. libavutil is linked directly for the log callback. Build
+// with -tags ffms2_nopkgconfig to use the fixed paths in cgo_nopkgconfig.go
+// instead.
+
+//#cgo pkg-config: ffms2 libavutil
+import "C"
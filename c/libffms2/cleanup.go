@@ -0,0 +1,120 @@
+//go:build cgo && !nocgo
+
+package libffms2
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
+)
+
+// LeakReport describes a C-backed object that was garbage collected without
+// its Close method being called.
+type LeakReport struct {
+	// Type is the name of the leaked Go type, e.g. "VideoSource".
+	Type string
+	// Stack is the goroutine stack at the time the object was created. It is
+	// only populated for objects created while leak tracking was enabled.
+	Stack string
+}
+
+// LeakReporter is called once for every leaked object found while leak
+// tracking is enabled. It is called from the runtime's cleanup goroutine and
+// must not block.
+type LeakReporter func(LeakReport)
+
+var (
+	leakTracking   atomic.Bool
+	leakReporterMu sync.Mutex
+	leakReporter   LeakReporter
+)
+
+// EnableLeakTracking turns on leak reporting for Index, Indexer and
+// VideoSource objects. Objects created after this call record their creation
+// stack, and reporter is called for every one of them that is garbage
+// collected without Close. A nil reporter logs the leak with the standard
+// logger.
+//
+// Regardless of this setting, leaked objects are always freed by a runtime
+// cleanup as a safety net. This is not a replacement for Close: Go does not
+// guarantee cleanups ever run, and C memory is invisible to the garbage
+// collector so it will not be pressured into running them.
+func EnableLeakTracking(reporter LeakReporter) {
+	leakReporterMu.Lock()
+	leakReporter = reporter
+	leakReporterMu.Unlock()
+	leakTracking.Store(true)
+}
+
+// DisableLeakTracking turns leak reporting back off. Leaked objects are still
+// freed.
+func DisableLeakTracking() {
+	leakTracking.Store(false)
+}
+
+// cleanupArg holds everything a cleanup needs to free a leaked object. It must
+// never reference the Go object itself, otherwise the object can never become
+// unreachable.
+type cleanupArg[P any] struct {
+	ptr   P
+	kind  string
+	stack string
+}
+
+// trackedCleanup is the runtime cleanup of a live object, counted in the
+// native resources of the process by kind, see accounting.
+type trackedCleanup struct {
+	cleanup runtime.Cleanup
+	kind    string
+}
+
+// Stop cancels the cleanup and stops counting the object, which the caller
+// frees.
+func (c trackedCleanup) Stop() {
+	c.cleanup.Stop()
+	accounting.Add(c.kind, -1)
+}
+
+// registerCleanup attaches a runtime cleanup to obj that calls destroy on ptr
+// when obj is garbage collected. The returned cleanup must be stopped by
+// Close (or anything else that frees ptr) to avoid a double free.
+func registerCleanup[T, P any](obj *T, ptr P, kind string,
+	destroy func(P)) trackedCleanup {
+	arg := cleanupArg[P]{ptr: ptr, kind: kind}
+	if leakTracking.Load() {
+		arg.stack = string(debug.Stack())
+	}
+
+	accounting.Add("ffms2 "+kind, 1)
+	return trackedCleanup{cleanup: runtime.AddCleanup(obj,
+		func(arg cleanupArg[P]) {
+			destroy(arg.ptr)
+			accounting.Add("ffms2 "+arg.kind, -1)
+			reportLeak(arg.kind, arg.stack)
+		}, arg), kind: "ffms2 " + kind}
+}
+
+// reportLeak forwards a leak to the configured reporter when leak tracking is
+// enabled.
+func reportLeak(kind, stack string) {
+	if !leakTracking.Load() {
+		return
+	}
+
+	leakReporterMu.Lock()
+	reporter := leakReporter
+	leakReporterMu.Unlock()
+
+	report := LeakReport{Type: kind, Stack: stack}
+	if reporter != nil {
+		reporter(report)
+		return
+	}
+
+	log.Printf("libffms2: %s garbage collected without Close\n%s", report.Type,
+		report.Stack)
+}
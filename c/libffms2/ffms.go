@@ -1,7 +1,7 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 //#include <stdlib.h>
 import "C"
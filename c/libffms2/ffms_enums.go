@@ -1,9 +1,10 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 import "C"
+import "strconv"
 
 type Errors int
 
@@ -58,6 +59,23 @@ const (
 	SeekAggressive
 )
 
+func (m SeekMode) String() string {
+	switch m {
+	case SeekLinearNoRw:
+		return "linear-no-rw"
+	case SeekLinear:
+		return "linear"
+	case SeekNormal:
+		return "normal"
+	case SeekUnsafe:
+		return "unsafe"
+	case SeekAggressive:
+		return "aggressive"
+	default:
+		return "SeekMode(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
 type IndexErrorHandling int
 
 const (
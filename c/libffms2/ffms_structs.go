@@ -1,7 +1,7 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 import "C"
 
@@ -9,6 +9,10 @@ import "C"
 // properties listed.
 type AudioSource struct {
 	source *C.FFMS_AudioSource
+	// props is cached on creation as GetAudio needs it for every call.
+	props AudioProperties
+	// cleanup frees the source if it is garbage collected without Close.
+	cleanup trackedCleanup
 }
 
 // A struct representing a FFMS track from an Index, Audio, or Video source.
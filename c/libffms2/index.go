@@ -1,7 +1,7 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 //#include <stdlib.h>
 import "C"
@@ -12,6 +12,8 @@ import (
 
 type Index struct {
 	index *C.FFMS_Index
+	// cleanup frees the index if it is garbage collected without Close.
+	cleanup trackedCleanup
 }
 
 var (
@@ -20,7 +22,55 @@ var (
 
 // CreateIndex creates an Index from a C.FFMS_Index pointer
 func newIndexFromIndexPtr(indexPtr *C.FFMS_Index) *Index {
-	return &Index{index: indexPtr}
+	idx := &Index{index: indexPtr}
+	idx.cleanup = registerCleanup(idx, indexPtr, "Index",
+		func(ptr *C.FFMS_Index) { C.FFMS_DestroyIndex(ptr) })
+	return idx
+}
+
+// Reads an Index from an index file written by WriteIndex or ffmsindex.
+//
+// IndexFile represents the path of the index file to read.
+//
+// Indexes written by an incompatible version of ffms2 are rejected with an
+// error.
+func ReadIndex(IndexFile string) (*Index, *ErrorInfo, error) {
+	var IndexFileC *C.char = (*C.char)(C.CString(IndexFile))
+	defer safeFree(IndexFileC)
+
+	indexPtr, errorInfo, err := withErrorInfo(func(c *C.FFMS_ErrorInfo) *C.FFMS_Index {
+		return C.FFMS_ReadIndex(IndexFileC, c)
+	})
+	if err != nil {
+		return nil, errorInfo, err
+	}
+
+	return newIndexFromIndexPtr(indexPtr), errorInfo, nil
+}
+
+// Reads an Index from memory holding the output of WriteIndexToByteBuffer.
+//
+// Indexes written by an incompatible version of ffms2 are rejected with an
+// error.
+func ReadIndexFromBuffer(buffer []byte) (*Index, *ErrorInfo, error) {
+	if len(buffer) == 0 {
+		return nil, nil, errors.New("cannot read an index from an empty buffer")
+	}
+
+	// Copy into C memory instead of passing a go ptr to avoid issues with
+	// memory pinning.
+	bufferC := C.CBytes(buffer)
+	defer C.free(bufferC)
+
+	indexPtr, errorInfo, err := withErrorInfo(func(c *C.FFMS_ErrorInfo) *C.FFMS_Index {
+		return C.FFMS_ReadIndexFromBuffer((*C.uint8_t)(bufferC),
+			C.size_t(len(buffer)), c)
+	})
+	if err != nil {
+		return nil, errorInfo, err
+	}
+
+	return newIndexFromIndexPtr(indexPtr), errorInfo, nil
 }
 
 // Returns the total number of tracks in the media file represented by the
@@ -206,12 +256,14 @@ func (idx *Index) checkValidity() error {
 // Note: This must be called to avoid memory leaks as the index exists within C
 // allocated memory. Therefore it will not be automatically cleaned up by GO!
 // once the object leaves scope. (Nor does GO! ever guarentee any finalizer
-// will ever be called).
+// will ever be called). A runtime cleanup frees leaked indexes as a safety
+// net, see EnableLeakTracking.
 func (idx *Index) Close() error {
 	if err := idx.checkValidity(); err != nil {
 		return err
 	}
 
+	idx.cleanup.Stop()
 	C.FFMS_DestroyIndex(idx.index)
 	idx.index = nil
 
@@ -0,0 +1,11 @@
+#include "index_callback.h"
+
+extern int goIndexCallback(int64_t Current, int64_t Total, uintptr_t Handle);
+
+static int cIndexingCallback(int64_t Current, int64_t Total, void *ICPrivate) {
+    return goIndexCallback(Current, Total, (uintptr_t)ICPrivate);
+}
+
+void cSetProgressCallback(FFMS_Indexer *Indexer, uintptr_t Handle) {
+    FFMS_SetProgressCallback(Indexer, cIndexingCallback, (void *)Handle);
+}
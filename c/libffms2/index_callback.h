@@ -0,0 +1,4 @@
+#include <stdint.h>
+#include <ffms.h>
+
+void cSetProgressCallback(FFMS_Indexer *Indexer, uintptr_t Handle);
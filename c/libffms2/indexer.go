@@ -1,13 +1,15 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 //#include <stdlib.h>
-//#include "indexer/indexer.h"
+//#include "index_callback.h"
 import "C"
 import (
+	"context"
 	"errors"
+	"runtime/cgo"
 	"unsafe"
 )
 
@@ -15,32 +17,59 @@ var (
 	ErrInvalidorNilIndexer error = errors.New("indexer was consumed, failed to create, or was destroyed")
 )
 
-var callbackMap map[uintptr]IndexerCallbackFunction = make(
-	map[uintptr]IndexerCallbackFunction)
-
 // See Indexer.SetProgressCallback for how the callback works.
 type IndexerCallbackFunction func(current, total int64) int
 
-// Private hidden function to help manage removing a indexer callback from
-// callbackMap once an indexer is closed or destroyed.
-func (i *Indexer) removeCallback() {
-	var ICPrivate uintptr = (uintptr)(unsafe.Pointer(i))
-	delete(callbackMap, (uintptr)(ICPrivate))
+// indexerCallbacks holds the callbacks of one Indexer. FFMS2 is handed a
+// cgo.Handle of it rather than the address of the Indexer, which the runtime
+// may reuse for another object once the Indexer is collected.
+type indexerCallbacks struct {
+	progress IndexerCallbackFunction
+	// ctx cancels indexing once done, before progress is called. It is set
+	// by DoIndexingContext.
+	ctx context.Context
 }
 
 // Private method called by C to call back into GO! to execute the Indexers
 // IndexerCallbackFunction.
 
 //export goIndexCallback
-func goIndexCallback(current, total C.int64_t, ICPriv unsafe.Pointer) C.int {
-	if fn, ok := callbackMap[(uintptr)(ICPriv)]; ok {
-		return C.int(fn(int64(current), int64(total)))
+func goIndexCallback(current, total C.int64_t, handle C.uintptr_t) C.int {
+	callbacks := cgo.Handle(handle).Value().(*indexerCallbacks)
+	if callbacks.ctx != nil && callbacks.ctx.Err() != nil {
+		return 1
+	}
+	if callbacks.progress != nil {
+		return C.int(callbacks.progress(int64(current), int64(total)))
 	}
 	return 0
 }
 
 type Indexer struct {
 	indexer *C.FFMS_Indexer
+	// handle refers to the indexerCallbacks passed to FFMS2. It is deleted,
+	// and zeroed, once the indexer is closed or consumed.
+	handle    cgo.Handle
+	callbacks *indexerCallbacks
+	// cleanup cancels the indexer if it is garbage collected without Close or
+	// DoIndexing.
+	cleanup trackedCleanup
+}
+
+// indexerCleanupArg is the state needed to free a leaked Indexer without
+// referencing it.
+type indexerCleanupArg struct {
+	indexer *C.FFMS_Indexer
+	handle  cgo.Handle
+}
+
+// releaseCallbacks deletes the handle of the callbacks once FFMS2 can no
+// longer call them.
+func (i *Indexer) releaseCallbacks() {
+	if i.handle != 0 {
+		i.handle.Delete()
+		i.handle = 0
+	}
 }
 
 // Creates a Indexer object for the given SourceFile and returns a pointer to
@@ -57,7 +86,17 @@ func CreateIndexer(sourceFile string) (*Indexer, *ErrorInfo, error) {
 		return nil, errorInfo, err
 	}
 
-	return &Indexer{res}, errorInfo, nil
+	callbacks := &indexerCallbacks{}
+	indexer := &Indexer{indexer: res, handle: cgo.NewHandle(callbacks),
+		callbacks: callbacks}
+	cleanupArg := indexerCleanupArg{res, indexer.handle}
+	indexer.cleanup = registerCleanup(indexer, cleanupArg, "Indexer",
+		func(arg indexerCleanupArg) {
+			C.FFMS_CancelIndexing(arg.indexer)
+			arg.handle.Delete()
+		})
+
+	return indexer, errorInfo, nil
 }
 
 // Returns the total number of tracks in the media file represented by the
@@ -123,6 +162,25 @@ func (i *Indexer) GetFormatName() (string, error) {
 	return C.GoString(formatName), nil
 }
 
+// Enables or disables indexing of every track of the given type. By default
+// only video tracks are indexed, so audio must be enabled here before
+// DoIndexing if an AudioSource is to be created from the resulting Index.
+func (i *Indexer) TrackTypeIndexSettings(trackType TrackType,
+	index bool) error {
+	if err := i.checkValidity(); err != nil {
+		return err
+	}
+
+	var indexC C.int
+	if index {
+		indexC = 1
+	}
+
+	C.FFMS_TrackTypeIndexSettings(i.indexer, C.int(trackType), indexC, 0)
+
+	return nil
+}
+
 // If you supply a progress callback, FFMS2 will call it regularly during
 // indexing to report progress and give you the chance to interrupt indexing.
 //
@@ -139,19 +197,10 @@ func (i *Indexer) SetProgressCallback(fn IndexerCallbackFunction) error {
 		return err
 	}
 
-	// GO!'s C header parser is unable to parse the `TIndexCallback` from the
-	// ffms.h header. So this weird type reflection is required.
-
-	var ICPrivate unsafe.Pointer = unsafe.Pointer(i)
-	var callback *[0]byte = (*[0]byte)(C.cIndexingCallback)
-
 	// Registers the c wrapper function for GO! callbacks with the FFMS
-	// object.
-	C.FFMS_SetProgressCallback(i.indexer, callback, ICPrivate)
-
-	// Stores the callback for the c wrapper functions to call into later via
-	// goIndexCallback.
-	callbackMap[(uintptr)(ICPrivate)] = fn
+	// object, which calls fn through goIndexCallback.
+	i.callbacks.progress = fn
+	C.cSetProgressCallback(i.indexer, C.uintptr_t(i.handle))
 	return nil
 }
 
@@ -167,16 +216,42 @@ func (i *Indexer) DoIndexing(errorHandling IndexErrorHandling) (*Index,
 
 	// FFMS_DoIndexing2 always destorys the Indexer no matter the result. Mark
 	// it as invalid as soon as possible.
+	i.cleanup.Stop()
 	res, info, err := withErrorInfo(func(c *C.FFMS_ErrorInfo) *C.FFMS_Index {
 		return C.FFMS_DoIndexing2(i.indexer, C.int(errorHandling), c)
 	})
 	i.indexer = nil // invalid
+	i.releaseCallbacks()
 
 	if err != nil {
 		return nil, info, err
 	}
 
-	return &Index{res}, info, nil
+	return newIndexFromIndexPtr(res), info, nil
+}
+
+// Runs the passed indexer like DoIndexing, but cancels indexing as soon as ctx
+// is done. FFMS2 checks for cancellation every time it reports progress, which
+// happens many times a second. Any progress callback set with
+// SetProgressCallback is still called.
+//
+// If indexing was cancelled by ctx the returned error is ctx.Err().
+func (i *Indexer) DoIndexingContext(ctx context.Context,
+	errorHandling IndexErrorHandling) (*Index, *ErrorInfo, error) {
+	if err := i.checkValidity(); err != nil {
+		return nil, nil, err
+	}
+
+	// The progress callback stays in place, goIndexCallback checks ctx
+	// before calling it.
+	i.callbacks.ctx = ctx
+	C.cSetProgressCallback(i.indexer, C.uintptr_t(i.handle))
+
+	index, info, err := i.DoIndexing(errorHandling)
+	if err != nil && ctx.Err() != nil {
+		return nil, info, ctx.Err()
+	}
+	return index, info, err
 }
 
 // checkValidity simply checks if the c ptr to the wrapped *C.FFMS_Indexer is
@@ -201,9 +276,10 @@ func (i *Indexer) checkValidity() error {
 func (i *Indexer) Close() {
 
 	if i.indexer != nil {
+		i.cleanup.Stop()
 		C.FFMS_CancelIndexing(i.indexer)
 		i.indexer = nil
 	}
 
-	i.removeCallback()
+	i.releaseCallbacks()
 }
@@ -1,3 +0,0 @@
-#include <stdint.h>
-
-int cIndexingCallback(int64_t Current, int64_t Total, void *ICPrivate);
\ No newline at end of file
@@ -0,0 +1,84 @@
+//go:build cgo && !nocgo
+
+package libffms2
+
+//#include "log_callback.h"
+import "C"
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// LogMessage is a single log line emitted by FFMS2 or one of the libav
+// libraries it uses while indexing or decoding.
+type LogMessage struct {
+	// The severity of the message. Only messages at or below GetLogLevel()
+	// are delivered.
+	Level LogLevel
+	// The formatted message including libav's "[codec @ 0x...]" prefix, with
+	// the trailing newline removed.
+	Message string
+	// The frame number being requested from VideoSource.GetFrame when the
+	// message was emitted, or -1 if it is unknown.
+	//
+	// Note: With more than one decoder thread libav may log from its own
+	// worker threads, in which case the frame is always -1.
+	Frame int
+}
+
+// LogHandler receives every LogMessage emitted while it is installed. It is
+// called synchronously from within the decoder and must not call back into
+// this package.
+type LogHandler func(LogMessage)
+
+var (
+	logHandlerMu sync.RWMutex
+	logHandler   LogHandler
+)
+
+func init() { SetLogHandler(DefaultLogHandler) }
+
+// DefaultLogHandler writes messages to the standard logger, tagged with the
+// frame number when it is known. It is installed when the package is loaded.
+func DefaultLogHandler(msg LogMessage) {
+	if msg.Frame < 0 {
+		log.Printf("libffms2: %s", msg.Message)
+		return
+	}
+
+	log.Printf("libffms2: frame %d: %s", msg.Frame, msg.Message)
+}
+
+// SetLogHandler routes all FFMS2 and libav log output to handler instead of
+// libav printing it to stderr. A nil handler restores libav's default stderr
+// output.
+//
+// The amount of output is still controlled by SetLogLevel.
+func SetLogHandler(handler LogHandler) {
+	logHandlerMu.Lock()
+	defer logHandlerMu.Unlock()
+
+	if handler == nil {
+		C.cRemoveLogCallback()
+	} else if logHandler == nil {
+		C.cInstallLogCallback()
+	}
+
+	logHandler = handler
+}
+
+//export goLogCallback
+func goLogCallback(level C.int, line *C.char, frame C.int) {
+	logHandlerMu.RLock()
+	handler := logHandler
+	logHandlerMu.RUnlock()
+
+	message := strings.TrimRight(C.GoString(line), "\n")
+	if handler == nil || message == "" {
+		return
+	}
+
+	handler(LogMessage{Level: LogLevel(level), Message: message,
+		Frame: int(frame)})
+}
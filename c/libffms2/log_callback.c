@@ -0,0 +1,39 @@
+#include <libavutil/log.h>
+
+#include "log_callback.h"
+
+extern void goLogCallback(int Level, char *Line, int Frame);
+
+// The frame currently being requested through cGetFrameWithContext on this
+// thread, or -1 if none.
+static _Thread_local int currentFrame = -1;
+
+// Whether the next line formatted on this thread starts a new message and
+// should get libav's "[codec @ 0x...]" prefix.
+static _Thread_local int printPrefix = 1;
+
+static void cLogCallback(void *avcl, int level, const char *fmt, va_list vl) {
+    char line[1024];
+
+    if (level > av_log_get_level())
+        return;
+
+    av_log_format_line2(avcl, level, fmt, vl, line, sizeof(line),
+                        &printPrefix);
+    goLogCallback(level, line, currentFrame);
+}
+
+void cInstallLogCallback(void) { av_log_set_callback(cLogCallback); }
+
+void cRemoveLogCallback(void) { av_log_set_callback(av_log_default_callback); }
+
+const FFMS_Frame *cGetFrameWithContext(FFMS_VideoSource *V, int n,
+                                 FFMS_ErrorInfo *ErrorInfo) {
+    const FFMS_Frame *frame;
+
+    currentFrame = n;
+    frame = FFMS_GetFrame(V, n, ErrorInfo);
+    currentFrame = -1;
+
+    return frame;
+}
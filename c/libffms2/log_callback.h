@@ -0,0 +1,6 @@
+#include <ffms.h>
+
+void cInstallLogCallback(void);
+void cRemoveLogCallback(void);
+const FFMS_Frame *cGetFrameWithContext(FFMS_VideoSource *V, int n,
+                                 FFMS_ErrorInfo *ErrorInfo);
@@ -1,3 +1,111 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-// TODO: implement track for all types
+//#include <ffms.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidOrNilTrack error = errors.New("track was not retrieved or its parent object was destroyed")
+)
+
+// checkValidity simply checks if the c ptr to the wrapped *C.FFMS_Track is nil
+// or not. Any other checks that need to be preformed before the type can be
+// used should be added here.
+//
+// Note: A Track is owned by the Index, VideoSource or AudioSource it was
+// retrieved from. It is only valid for as long as that parent object is.
+func (t *Track) checkValidity() error {
+	if t.track == nil {
+		return ErrInvalidOrNilTrack
+	}
+
+	return nil
+}
+
+// Returns the TrackType of the track.
+func (t *Track) GetType() (TrackType, error) {
+	if err := t.checkValidity(); err != nil {
+		return TypeUnknown, err
+	}
+
+	return TrackType(C.FFMS_GetTrackType(t.track)), nil
+}
+
+// Returns the number of frames in the track. Note that for an audio track
+// this is the number of packets.
+//
+// A return value of 0 means the track has not been indexed.
+func (t *Track) GetNumFrames() (int, error) {
+	if err := t.checkValidity(); err != nil {
+		return 0, err
+	}
+
+	return int(C.FFMS_GetNumFrames(t.track)), nil
+}
+
+// Gets the FrameInfo for the given frame number in the track. The FrameInfo
+// contains the decoding timestamp, keyframe flag and RFF flag of the frame.
+//
+// Note: Frame numbers outside of [0, GetNumFrames()) return an error instead
+// of the undefined behavior FFMS2 would otherwise exhibit.
+func (t *Track) GetFrameInfo(frame int) (FrameInfo, error) {
+	numFrames, err := t.GetNumFrames()
+	if err != nil {
+		return FrameInfo{}, err
+	}
+
+	if frame < 0 || frame >= numFrames {
+		return FrameInfo{}, fmt.Errorf("frame %d out of range [0, %d)", frame,
+			numFrames)
+	}
+
+	cInfo := C.FFMS_GetFrameInfo(t.track, C.int(frame))
+	if cInfo == nil {
+		return FrameInfo{}, ErrFFmsNilPtrReturn
+	}
+
+	return ffmsFrameInfoFromC(cInfo), nil
+}
+
+// Returns the basic time unit of the track. See TrackTimeBase for how to
+// convert FrameInfo.PTS into wallclock time.
+func (t *Track) GetTimeBase() (TrackTimeBase, error) {
+	if err := t.checkValidity(); err != nil {
+		return TrackTimeBase{}, err
+	}
+
+	cBase := C.FFMS_GetTimeBase(t.track)
+	if cBase == nil {
+		return TrackTimeBase{}, ErrFFmsNilPtrReturn
+	}
+
+	return ffmsTrackTimeBaseFromC(cBase), nil
+}
+
+// Returns the indices of every frame in the track flagged as a keyframe, in
+// increasing order.
+func (t *Track) GetKeyFrames() ([]int, error) {
+	numFrames, err := t.GetNumFrames()
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFrames []int
+
+	for i := range numFrames {
+		info, err := t.GetFrameInfo(i)
+		if err != nil {
+			return nil, err
+		}
+		if info.KeyFrame != 0 {
+			keyFrames = append(keyFrames, i)
+		}
+	}
+
+	return keyFrames, nil
+}
@@ -1,12 +1,14 @@
+//go:build cgo && !nocgo
+
 package libffms2
 
-//#cgo LDFLAGS: -lffms2
-//#cgo CFLAGS: -I/usr/include
 //#include <ffms.h>
 //#include <stdlib.h>
+//#include "log_callback.h"
 import "C"
 import (
 	"errors"
+	"runtime"
 	"unsafe"
 
 	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
@@ -20,6 +22,8 @@ var (
 // properties listed.
 type VideoSource struct {
 	source *C.FFMS_VideoSource
+	// cleanup frees the source if it is garbage collected without Close.
+	cleanup trackedCleanup
 }
 
 func CreateVideoSource(sourceFile string, index *Index, track,
@@ -38,11 +42,16 @@ func CreateVideoSource(sourceFile string, index *Index, track,
 	}
 
 	res, info, err := withErrorInfo(fn)
+	runtime.KeepAlive(index)
 	if err != nil {
 		return nil, info, err
 	}
 
-	return &VideoSource{res}, info, nil
+	vs := &VideoSource{source: res}
+	vs.cleanup = registerCleanup(vs, res, "VideoSource",
+		func(ptr *C.FFMS_VideoSource) { C.FFMS_DestroyVideoSource(ptr) })
+
+	return vs, info, nil
 }
 
 func (vs *VideoSource) GetVideoProperties() (VideoProperties, error) {
@@ -67,11 +76,12 @@ func (vs *VideoSource) GetFrame(frameNumber int) (Frame, *ErrorInfo, error) {
 	}
 
 	res, info, err := withErrorInfo(func(c *C.FFMS_ErrorInfo) *C.FFMS_Frame {
-		return C.FFMS_GetFrame(vs.source, C.int(frameNumber), c)
+		return C.cGetFrameWithContext(vs.source, C.int(frameNumber), c)
 	})
 
 	var frame Frame
 	frame.fromCFrame(res)
+	runtime.KeepAlive(vs)
 
 	return frame, info, err
 }
@@ -87,6 +97,7 @@ func (vs *VideoSource) GetFrameByTime(timeStamp float64) (Frame, *ErrorInfo, err
 
 	var frame Frame
 	frame.fromCFrame(res)
+	runtime.KeepAlive(vs)
 
 	return frame, info, err
 }
@@ -147,6 +158,23 @@ func (vs *VideoSource) ResetInputFormat() error {
 	return nil
 }
 
+// Gets the Track of the VideoSource. This is the safe way of retrieving frame
+// info and timebases once the source has been opened.
+//
+// Note: The returned Track is only valid until the VideoSource is closed.
+func (vs *VideoSource) GetTrack() (Track, error) {
+	if err := vs.checkValidity(); err != nil {
+		return Track{}, err
+	}
+
+	var ptr *C.FFMS_Track = C.FFMS_GetTrackFromVideo(vs.source)
+	if ptr == nil {
+		return Track{}, ErrFFmsNilPtrReturn
+	}
+
+	return Track{ptr}, nil
+}
+
 // checkValidity simply checks if the c ptr to the wrapped *C.FFMS_VideoSource
 // is nil or not. Any other checks that need to be preformed before the type
 // can be used should be added here.
@@ -164,12 +192,14 @@ func (vs VideoSource) checkValidity() error {
 // Note: This must be called to avoid memory leaks as the VideoSource exists
 // within C allocated memory. Therefore it will not be automatically cleaned up
 // by GO! once the object leaves scope. (Nor does GO! ever guarentee any
-// finalizer will ever be called).
+// finalizer will ever be called). A runtime cleanup frees leaked sources as a
+// safety net, see EnableLeakTracking.
 func (vs *VideoSource) Close() error {
 	if err := vs.checkValidity(); err != nil {
 		return err
 	}
 
+	vs.cleanup.Stop()
 	C.FFMS_DestroyVideoSource(vs.source)
 	vs.source = nil
 
@@ -0,0 +1,13 @@
+//go:build cgo && !nocgo
+
+package libvship
+
+// The kinds of native resources counted in the accounting of the process,
+// see internal/accounting.
+const (
+	butteraugliHandlers = "vship ButteraugliHandler"
+	ssimu2Handlers      = "vship SSIMU2Handler"
+	cvvdpHandlers       = "vship CVVDPHandler"
+	pinnedBuffers       = "vship pinned buffers"
+	pinnedBytes         = "vship pinned bytes"
+)
@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship
 
 /*
@@ -6,7 +8,12 @@ package libvship
 #include "flattened.h"
 */
 import "C"
-import "unsafe"
+import (
+	"time"
+	"unsafe"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
+)
 
 // ButteraugliHandler evaluates visual differences between two images using the
 // Butteraugli perceptual metric.
@@ -18,8 +25,9 @@ import "unsafe"
 // Each score is computed independently. The handler does not accumulate
 // history and does not retain information between calls to ComputeScore.
 type ButteraugliHandler struct {
-	ptr  *C.Vship_ButteraugliHandler
-	init bool
+	ptr      *C.Vship_ButteraugliHandler
+	init     bool
+	counters callCounters
 }
 
 // ButteraugliScore contains the results of a Butteraugli comparison.
@@ -66,6 +74,7 @@ func NewButteraugliHandler(src, dst *Colorspace, Qnorm int,
 
 	handler.ptr = &h
 	handler.init = true
+	accounting.Add(butteraugliHandlers, 1)
 	return &handler, code
 }
 
@@ -99,6 +108,7 @@ func (handler *ButteraugliHandler) ComputeScore(
 
 	dstPtr := planePtr(dst)
 
+	started := time.Now()
 	code := C.ComputeButteraugli_flat(
 		(*C.Vship_ButteraugliHandler)(unsafe.Pointer(handler.ptr)),
 		&cScore,
@@ -111,6 +121,7 @@ func (handler *ButteraugliHandler) ComputeScore(
 		C.int64_t(srcLineSize2[0]), C.int64_t(srcLineSize2[1]),
 		C.int64_t(srcLineSize2[2]),
 	)
+	handler.counters.record(started, src1, src2, len(dst))
 
 	if code == 0 {
 		*score = ButteraugliScore{float64(cScore.normQ), float64(cScore.norm3),
@@ -120,6 +131,11 @@ func (handler *ButteraugliHandler) ComputeScore(
 	return ExceptionCode(code)
 }
 
+// Stats returns the calls the handler made into vship so far.
+func (handler *ButteraugliHandler) Stats() CallStats {
+	return handler.counters.stats()
+}
+
 // Close releases the resources associated with the handler.
 //
 // After Close is called, the handler must not be used again. Calling Close
@@ -129,6 +145,7 @@ func (handler *ButteraugliHandler) Close() ExceptionCode {
 		handler.init = false
 		code := ExceptionCode(C.Vship_ButteraugliFree(*handler.ptr))
 		handler.ptr = nil
+		accounting.Add(butteraugliHandlers, -1)
 		return code
 	}
 	return ExceptionCodeNoError
@@ -0,0 +1,51 @@
+//go:build cgo && !nocgo
+
+package libvship
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CallStats sums up the calls a handler made into vship. Every call uploads
+// the frame planes, runs the metric kernels and downloads the score and any
+// distortion map back to back, and vship does not time the steps apart, so
+// only their total is known. Together with the bytes moved it still tells
+// calls limited by the host to device link, whose time follows the bytes,
+// from those limited by the kernels.
+type CallStats struct {
+	Calls int64
+	// Time is the time spent in vship, summed over calls.
+	Time time.Duration
+	// UploadBytes counts the bytes of the frame planes passed in and
+	// DownloadBytes those of the distortion maps written back.
+	UploadBytes, DownloadBytes int64
+}
+
+// callCounters accumulates the CallStats of a handler. Handlers may be shared
+// by goroutines, so the counters are atomic.
+type callCounters struct {
+	calls, nanos, upload, download atomic.Int64
+}
+
+// record adds a call that started at started and passed the planes of src
+// and dst in and download bytes out.
+func (c *callCounters) record(started time.Time, src, dst [3][]byte,
+	download int) {
+	c.calls.Add(1)
+	c.nanos.Add(int64(time.Since(started)))
+	c.upload.Add(int64(planeBytes(src) + planeBytes(dst)))
+	c.download.Add(int64(download))
+}
+
+func (c *callCounters) stats() CallStats {
+	return CallStats{Calls: c.calls.Load(),
+		Time:          time.Duration(c.nanos.Load()),
+		UploadBytes:   c.upload.Load(),
+		DownloadBytes: c.download.Load()}
+}
+
+// planeBytes returns the bytes of planes.
+func planeBytes(planes [3][]byte) int {
+	return len(planes[0]) + len(planes[1]) + len(planes[2])
+}
@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship
 
 /*
@@ -6,11 +8,17 @@ package libvship
 #include "flattened.h"
 */
 import "C"
-import "unsafe"
+import (
+	"time"
+	"unsafe"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
+)
 
 type CVVDPHandler struct {
-	ptr  *C.Vship_CVVDPHandler
-	init bool
+	ptr      *C.Vship_CVVDPHandler
+	init     bool
+	counters callCounters
 }
 
 // NewCVVDPHandler initializes a new CVVDP handler using a built-in display
@@ -52,6 +60,7 @@ func NewCVVDPHandler(src, dst *Colorspace, fps float32, resizeToDisplay bool,
 
 	h.ptr = &cHandler
 	h.init = true
+	accounting.Add(cvvdpHandlers, 1)
 	return &h, code
 }
 
@@ -96,6 +105,7 @@ func NewCVVDPHandlerWithConfig(
 
 	h.ptr = &cHandler
 	h.init = true
+	accounting.Add(cvvdpHandlers, 1)
 	return &h, code
 }
 
@@ -152,7 +162,8 @@ func (h *CVVDPHandler) LoadTemporal(src, dst [3][]byte, srcLineSize,
 	d1 := planePtr(dst[1])
 	d2 := planePtr(dst[2])
 
-	return ExceptionCode(C.LoadTemporalCVVDP_flat(
+	started := time.Now()
+	code := C.LoadTemporalCVVDP_flat(
 		(*C.Vship_CVVDPHandler)(unsafe.Pointer(h.ptr)),
 		s0, s1, s2,
 		d0, d1, d2,
@@ -160,7 +171,9 @@ func (h *CVVDPHandler) LoadTemporal(src, dst [3][]byte, srcLineSize,
 		C.int64_t(srcLineSize[2]),
 		C.int64_t(dstLineSize[0]), C.int64_t(dstLineSize[1]),
 		C.int64_t(dstLineSize[2]),
-	))
+	)
+	h.counters.record(started, src, dst, 0)
+	return ExceptionCode(code)
 }
 
 // ComputeScore submits the current frame(s) to CVVDP and returns the
@@ -192,6 +205,7 @@ func (h *CVVDPHandler) ComputeScore(
 	var score C.double
 	dstPtr := planePtr(dst)
 
+	started := time.Now()
 	code := C.ComputeCVVDP_flat(
 		(*C.Vship_CVVDPHandler)(unsafe.Pointer(h.ptr)),
 		&score,
@@ -204,9 +218,16 @@ func (h *CVVDPHandler) ComputeScore(
 		C.int64_t(dstLineSize[0]), C.int64_t(dstLineSize[1]),
 		C.int64_t(dstLineSize[2]),
 	)
+	h.counters.record(started, src, distorted, len(dst))
 	return float64(score), ExceptionCode(code)
 }
 
+// Stats returns the calls the handler made into vship so far, LoadTemporal
+// included.
+func (h *CVVDPHandler) Stats() CallStats {
+	return h.counters.stats()
+}
+
 // Close releases all native resources associated with the CVVDP handler.
 //
 // After Close is called, the handler must not be used again. Calling Close
@@ -216,6 +237,7 @@ func (h *CVVDPHandler) Close() ExceptionCode {
 		h.init = false
 		code := ExceptionCode(C.Vship_CVVDPFree(*h.ptr))
 		h.ptr = nil
+		accounting.Add(cvvdpHandlers, -1)
 		return code
 	}
 	return ExceptionCodeNoError
@@ -0,0 +1,175 @@
+//go:build cgo && !nocgo
+
+package libvship
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// Display presets for common viewing conditions beyond the CVVDP reference
+// ones. Luminance, contrast and reflectance follow typical measurements of
+// each class of display; sizes and distances follow common usage.
+var (
+	// DisplayModelPresetSDROffice models a 27-inch 1440p SDR monitor on a
+	// desk in a brightly lit office.
+	DisplayModelPresetSDROffice DisplayModel = DisplayModel{
+		Name: "27-inch 1440p monitor, peak luminance 300 cd/m^2, viewed " +
+			"under bright office light levels (500 lux) from 0.6 m",
+		ColorSpace:                      DisplayModelColorspaceSDR,
+		DisplayWidth:                    2560,
+		DisplayHeight:                   1440,
+		DisplayMaxLuminance:             300,
+		DisplayDiagonalSizeInches:       27,
+		ViewingDistanceMeters:           0.6,
+		MonitorContrastRatio:            1000,
+		AmbientLightLevel:               500,
+		AmbientLightReflectionOnDisplay: 0.005,
+		Exposure:                        1,
+	}
+
+	// DisplayModelPresetOLEDTVDarkRoom models a 65-inch 4K OLED TV watched
+	// from a couch with the lights off.
+	DisplayModelPresetOLEDTVDarkRoom DisplayModel = DisplayModel{
+		Name: "65-inch 4K OLED TV, peak luminance 800 cd/m^2, viewed in a " +
+			"dark room (5 lux) from 2.5 m",
+		ColorSpace:                      DisplayModelColorspaceHDR,
+		DisplayWidth:                    3840,
+		DisplayHeight:                   2160,
+		DisplayMaxLuminance:             800,
+		DisplayDiagonalSizeInches:       65,
+		ViewingDistanceMeters:           2.5,
+		MonitorContrastRatio:            1000000,
+		AmbientLightLevel:               5,
+		AmbientLightReflectionOnDisplay: 0.01,
+		Exposure:                        1,
+	}
+
+	// DisplayModelPresetPhoneOutdoors models a 6-inch phone held at arms
+	// length in daylight, where glare washes out the shadows.
+	DisplayModelPresetPhoneOutdoors DisplayModel = DisplayModel{
+		Name: "6.1-inch 1080p phone, peak luminance 1000 cd/m^2, viewed " +
+			"outdoors in daylight (10000 lux) from 0.35 m",
+		ColorSpace:                      DisplayModelColorspaceSDR,
+		DisplayWidth:                    2340,
+		DisplayHeight:                   1080,
+		DisplayMaxLuminance:             1000,
+		DisplayDiagonalSizeInches:       6.1,
+		ViewingDistanceMeters:           0.35,
+		MonitorContrastRatio:            1000000,
+		AmbientLightLevel:               10000,
+		AmbientLightReflectionOnDisplay: 0.04,
+		Exposure:                        1,
+	}
+
+	// DisplayModelPresetReferenceGrading models a 31-inch reference HDR
+	// monitor in a grading suite with the dim surround of ITU-R BT.2100.
+	DisplayModelPresetReferenceGrading DisplayModel = DisplayModel{
+		Name: "31-inch 4K reference HDR monitor, peak luminance 1000 " +
+			"cd/m^2, viewed in a grading suite (5 lux) from 3 x display height",
+		ColorSpace:                      DisplayModelColorspaceHDR,
+		DisplayWidth:                    4096,
+		DisplayHeight:                   2160,
+		DisplayMaxLuminance:             1000,
+		DisplayDiagonalSizeInches:       31,
+		ViewingDistanceMeters:           1.1,
+		MonitorContrastRatio:            1000000,
+		AmbientLightLevel:               5,
+		AmbientLightReflectionOnDisplay: 0.005,
+		Exposure:                        1,
+	}
+)
+
+// displayModelPresets maps the short names accepted by DisplayModelPreset to
+// the built-in presets.
+var displayModelPresets = map[string]DisplayModel{
+	"standard-4k":       DisplayModelPresetStandard4K,
+	"standard-fhd":      DisplayModelPresetStandardFHD,
+	"standard-hdr":      DisplayModelPresetStandardHDR,
+	"standard-hdr-dark": DisplayModelPresetStandardHDRDarkRoom,
+	"sdr-office":        DisplayModelPresetSDROffice,
+	"oled-tv-dark":      DisplayModelPresetOLEDTVDarkRoom,
+	"phone-outdoors":    DisplayModelPresetPhoneOutdoors,
+	"reference-grading": DisplayModelPresetReferenceGrading,
+}
+
+// DisplayModelPreset returns the built-in preset with the given short name,
+// e.g. "oled-tv-dark". See DisplayModelPresetNames for every name.
+func DisplayModelPreset(name string) (DisplayModel, error) {
+	model, ok := displayModelPresets[name]
+	if !ok {
+		return DisplayModel{}, fmt.Errorf("unknown display preset %q, "+
+			"expected one of %v", name, DisplayModelPresetNames())
+	}
+	return model, nil
+}
+
+// DisplayModelPresetNames returns the short names of every built-in preset in
+// alphabetical order.
+func DisplayModelPresetNames() []string {
+	return slices.Sorted(maps.Keys(displayModelPresets))
+}
+
+// DisplayModelsFromCVVDPJSON parses a CVVDP display model configuration, such
+// as one written by DisplayModelsToCVVDPJSON, into DisplayModels keyed by
+// their display identifier.
+//
+// Fields missing from an entry are left zero, except Exposure which defaults
+// to 1.
+func DisplayModelsFromCVVDPJSON(data []byte) (map[string]DisplayModel,
+	error) {
+	var in map[string]cvvdpDisplayJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]DisplayModel, len(in))
+	for key, m := range in {
+		model := DisplayModel{
+			Name:                            m.Name,
+			ColorSpace:                      DisplayModelColorspace(m.ColorSpace),
+			DisplayWidth:                    m.Resolution[0],
+			DisplayHeight:                   m.Resolution[1],
+			DisplayMaxLuminance:             m.MaxLuminance,
+			DisplayDiagonalSizeInches:       m.DiagonalSizeInches,
+			ViewingDistanceMeters:           m.ViewingDistanceMeters,
+			MonitorContrastRatio:            int(m.Contrast),
+			AmbientLightLevel:               int(m.EAmbient),
+			AmbientLightReflectionOnDisplay: m.KRefl,
+			Exposure:                        m.Exposure,
+		}
+		if model.Name == "" {
+			model.Name = key
+		}
+		if model.Exposure == 0 {
+			model.Exposure = 1
+		}
+
+		switch model.ColorSpace {
+		case DisplayModelColorspaceHDR, DisplayModelColorspaceSDR:
+		case "":
+			model.ColorSpace = DisplayModelColorspaceSDR
+		default:
+			return nil, fmt.Errorf("display %q: unknown colorspace %q", key,
+				m.ColorSpace)
+		}
+
+		models[key] = model
+	}
+
+	return models, nil
+}
+
+// DisplayModelsFromCVVDPJSONFile reads a CVVDP display model configuration
+// file, see DisplayModelsFromCVVDPJSON.
+func DisplayModelsFromCVVDPJSONFile(filePath string) (
+	map[string]DisplayModel, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return DisplayModelsFromCVVDPJSON(data)
+}
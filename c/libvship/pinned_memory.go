@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship
 
 /*
@@ -8,6 +10,8 @@ package libvship
 import "C"
 import (
 	"unsafe"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
 )
 
 // PinnedMalloc allocates a block of memory that is page-locked (pinned) in
@@ -32,6 +36,8 @@ func PinnedMalloc(size int) ([]byte, ExceptionCode) {
 	if !code.IsNone() {
 		return nil, code
 	}
+	accounting.Add(pinnedBuffers, 1)
+	accounting.Add(pinnedBytes, int64(size))
 	return unsafe.Slice((*byte)(ptr), size), code
 }
 
@@ -52,6 +58,8 @@ func PinnedFree(data []byte) ExceptionCode {
 	if !code.IsNone() {
 		return code
 	}
+	accounting.Add(pinnedBuffers, -1)
+	accounting.Add(pinnedBytes, -int64(len(data)))
 
 	data = nil
 
@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship
 
 // #include <VshipAPI.h>
@@ -5,7 +7,10 @@ package libvship
 // #include "flattened.h"
 import "C"
 import (
+	"time"
 	"unsafe"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
 )
 
 // SSIMU2Handler evaluates structural similarity between two images using the
@@ -18,8 +23,9 @@ import (
 // Each score is computed independently. The handler does not accumulate
 // history and does not retain information between calls to ComputeScore.
 type SSIMU2Handler struct {
-	ptr  *C.Vship_SSIMU2Handler
-	init bool
+	ptr      *C.Vship_SSIMU2Handler
+	init     bool
+	counters callCounters
 }
 
 // NewSSIMU2Handler creates a new SSIMU2Handler for the given source and
@@ -40,10 +46,13 @@ func NewSSIMU2Handler(source, distortion *Colorspace) (*SSIMU2Handler,
 		source.toC(), distortion.toC()))
 
 	if !code.IsNone() {
-		handler.Close()
+		C.free(unsafe.Pointer(handler.ptr))
+		handler.ptr = nil
+		return &handler, code
 	}
 
 	handler.init = true
+	accounting.Add(ssimu2Handlers, 1)
 
 	return &handler, code
 }
@@ -68,6 +77,7 @@ func (handler *SSIMU2Handler) ComputeScore(sourceData, distortedData [3][]byte,
 
 	var score C.double
 
+	started := time.Now()
 	var code C.Vship_Exception = C.ComputeSSIMU2_flat(
 		(*C.Vship_SSIMU2Handler)(unsafe.Pointer(handler.ptr)),
 		&score,
@@ -78,10 +88,16 @@ func (handler *SSIMU2Handler) ComputeScore(sourceData, distortedData [3][]byte,
 		C.int64_t(distortedLineSize[0]), C.int64_t(distortedLineSize[1]),
 		C.int64_t(distortedLineSize[2]),
 	)
+	handler.counters.record(started, sourceData, distortedData, 0)
 
 	return float64(score), ExceptionCode(code)
 }
 
+// Stats returns the calls the handler made into vship so far.
+func (handler *SSIMU2Handler) Stats() CallStats {
+	return handler.counters.stats()
+}
+
 // Close frees all resources associated with the SSIMU2Handler.
 //
 // After calling Close, the handler should no longer be used. Returns an
@@ -90,7 +106,9 @@ func (handler *SSIMU2Handler) Close() ExceptionCode {
 	if handler.ptr != nil && handler.init {
 		handler.init = false
 		code := ExceptionCode(C.Vship_SSIMU2Free(*handler.ptr))
+		C.free(unsafe.Pointer(handler.ptr))
 		handler.ptr = nil
+		accounting.Add(ssimu2Handlers, -1)
 		return code
 	}
 	return ExceptionCodeNoError
@@ -1,7 +1,14 @@
+//go:build cgo && !nocgo
+
 package libvship
 
 //#cgo LDFLAGS: -lvship
-//#cgo CFLAGS: -I/usr/include -I./c
+//#cgo CFLAGS: -I./c
+//#cgo linux CFLAGS: -I/usr/include
+//#cgo darwin,arm64 CFLAGS: -I/opt/homebrew/include
+//#cgo darwin,arm64 LDFLAGS: -L/opt/homebrew/lib
+//#cgo darwin,amd64 CFLAGS: -I/usr/local/include
+//#cgo darwin,amd64 LDFLAGS: -L/usr/local/lib
 // #include <VshipAPI.h>
 // #include <stdlib.h>
 import "C"
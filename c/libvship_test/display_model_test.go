@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship_test
 
 import (
@@ -35,3 +37,45 @@ func Test_DisplayModelsToCVVDPJSON_Print(t *testing.T) {
 
 	fmt.Println(string(jsonBytes))
 }
+
+func Test_DisplayModelsFromCVVDPJSON_RoundTrip(t *testing.T) {
+	models := []vship.DisplayModel{vship.DisplayModelPresetOLEDTVDarkRoom,
+		vship.DisplayModelPresetPhoneOutdoors}
+
+	jsonBytes, err := vship.DisplayModelsToCVVDPJSON(models)
+	if err != nil {
+		t.Fatalf("failed to generate JSON: %v", err)
+	}
+
+	parsed, err := vship.DisplayModelsFromCVVDPJSON(jsonBytes)
+	if err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+
+	if len(parsed) != len(models) {
+		t.Fatalf("expected %d models, got %d", len(models), len(parsed))
+	}
+
+	for _, model := range models {
+		if got := parsed[model.Name]; got != model {
+			t.Errorf("round trip of %q changed the model:\n%+v\n%+v",
+				model.Name, model, got)
+		}
+	}
+}
+
+func Test_DisplayModelPreset(t *testing.T) {
+	for _, name := range vship.DisplayModelPresetNames() {
+		model, err := vship.DisplayModelPreset(name)
+		if err != nil {
+			t.Fatalf("preset %q: %v", name, err)
+		}
+		if model.DisplayWidth <= 0 || model.DisplayMaxLuminance <= 0 {
+			t.Errorf("preset %q is incomplete: %+v", name, model)
+		}
+	}
+
+	if _, err := vship.DisplayModelPreset("no-such-display"); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
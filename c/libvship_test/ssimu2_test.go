@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package libvship_test
 
 import (
@@ -57,4 +59,12 @@ func Test_SSIMU2Handler_ComputeScore(t *testing.T) {
 	}
 
 	t.Log(score)
+
+	stats := handler.Stats()
+	wantBytes := int64(2 * (ySize + 2*uvSize))
+	if stats.Calls != 1 || stats.UploadBytes != wantBytes ||
+		stats.DownloadBytes != 0 {
+		t.Errorf("Stats() = %+v, want 1 call uploading %d bytes", stats,
+			wantBytes)
+	}
 }
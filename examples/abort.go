@@ -0,0 +1,85 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// abortConditions parses every --abort-if condition.
+func abortConditions() ([]comparator.AbortCondition, error) {
+	conditions := make([]comparator.AbortCondition, 0, len(settings.abortIf))
+	for _, text := range settings.abortIf {
+		condition, err := parseAbortCondition(text)
+		if err != nil {
+			return nil, fmt.Errorf("--abort-if %q: %w", text, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+// parseAbortCondition parses a condition of the form
+// <score key>:<mean|frame><'<'|'>'|-worse=><threshold>[@<frames>], e.g.
+// Ssimulacra2:mean<40@500. The -worse= tests compare in the direction of the
+// score key, so Butteraugli_3Norm:frame-worse=4 is frame>4.
+func parseAbortCondition(text string) (comparator.AbortCondition, error) {
+	var condition comparator.AbortCondition
+
+	metric, test, ok := strings.Cut(text, ":")
+	if !ok || metric == "" {
+		return condition, fmt.Errorf("expected <score key>:<test>")
+	}
+	condition.Metric = metric
+
+	test, frames, ok := strings.Cut(test, "@")
+	if ok {
+		n, err := strconv.Atoi(frames)
+		if err != nil || n < 0 {
+			return condition, fmt.Errorf("invalid frame count %q", frames)
+		}
+		condition.MinFrames = n
+	}
+
+	kinds := map[string]comparator.AbortKind{
+		"mean<":  comparator.AbortMeanBelow,
+		"mean>":  comparator.AbortMeanAbove,
+		"frame<": comparator.AbortFrameBelow,
+		"frame>": comparator.AbortFrameAbove,
+	}
+	switch direction := scoreDirection(metric); direction {
+	case video.HigherIsBetter:
+		kinds["mean-worse="] = comparator.AbortMeanBelow
+		kinds["frame-worse="] = comparator.AbortFrameBelow
+	case video.LowerIsBetter:
+		kinds["mean-worse="] = comparator.AbortMeanAbove
+		kinds["frame-worse="] = comparator.AbortFrameAbove
+	default:
+		if strings.Contains(test, "-worse=") {
+			return condition, fmt.Errorf("%s has no known score direction, "+
+				"use < or >", metric)
+		}
+	}
+
+	for prefix, kind := range kinds {
+		threshold, ok := strings.CutPrefix(test, prefix)
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return condition, fmt.Errorf("invalid threshold %q", threshold)
+		}
+		condition.Kind, condition.Threshold = kind, value
+		return condition, nil
+	}
+
+	return condition, fmt.Errorf("unknown test %q, expected mean<, mean>, "+
+		"mean-worse=, frame<, frame> or frame-worse=", test)
+}
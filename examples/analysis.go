@@ -0,0 +1,402 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// syncEnvelopeRate is the number of audio envelope values per second used by
+// --av-sync, giving lags in steps of 10ms before sub-step refinement.
+const syncEnvelopeRate = 100
+
+// frameReport holds the results of the frame analysis.
+type frameReport struct {
+	events     []analysis.Event
+	sync       []analysis.SyncPoint
+	complexity []analysis.Complexity
+	// Per-frame metadata of the reference and distortion.
+	metadata [2][]video.FrameMetadata
+	// Scores of every metric per GOP of the distortion.
+	gops map[string][]analysis.GOP
+	// The --worst-segments of every metric.
+	segments map[string][]analysis.Segment
+	// Scores of every metric per chapter from --chapters.
+	chapters map[string][]analysis.ChapterScore
+	// frames holds the reference frame of every score when --two-pass left
+	// frames unscored or --frame-map reordered them, and regions the densely
+	// scored regions ranked by the regionMetric score key.
+	frames       []int
+	regions      []analysis.Region
+	regionMetric string
+	// imputed holds the source frames whose scores --quick-reject-psnr
+	// imputed.
+	imputed []int
+	// masked holds the source frames that had subtitles masked by
+	// --subtitle-mask.
+	masked []int
+	// The loudness of every audio stream of both files from --audio-qc.
+	audio []analysis.StreamLoudness
+	// The frames --frame-map left out of the comparison.
+	unmapped *results.UnmappedFrames
+}
+
+// frameRecorders holds the recorders observing the compared frames. Any is
+// nil when no flag needs it.
+type frameRecorders struct {
+	signatures *analysis.Recorder
+	complexity *analysis.ComplexityRecorder
+	metadata   *analysis.MetadataRecorder
+}
+
+// frameAnalysisEnabled returns true if any flag needs to observe the compared
+// frames.
+func frameAnalysisEnabled() bool {
+	return signaturesEnabled() || settings.complexity ||
+		settings.frameMetadata
+}
+
+// signaturesEnabled returns true if any flag needs frame signatures.
+func signaturesEnabled() bool {
+	return settings.detectCadence || settings.blackFreeze != "off" ||
+		settings.avSync
+}
+
+// newFrameRecorders registers the recorders needed by the requested frame
+// analysis with comp.
+func newFrameRecorders(comp *comparator.Comparator, reference,
+	distortion video.Source, keyFrameMode comparator.KeyFrameMode) (
+	frameRecorders, error) {
+	switch settings.blackFreeze {
+	case "off", "flag", "exclude":
+	default:
+		return frameRecorders{}, fmt.Errorf("unsupported black/freeze "+
+			"mode: %s", settings.blackFreeze)
+	}
+
+	if !frameAnalysisEnabled() {
+		return frameRecorders{}, nil
+	}
+
+	if keyFrameMode != comparator.KeyFrameModeOff &&
+		(signaturesEnabled() || settings.complexity) {
+		return frameRecorders{}, errors.New("--detect-cadence, " +
+			"--black-freeze, --av-sync and --complexity need every frame " +
+			"and cannot be combined with --keyframe-mode")
+	}
+
+	var recorders frameRecorders
+	var err error
+	numFrames := len(comp.FrameIndices())
+
+	if signaturesEnabled() {
+		recorders.signatures, err = analysis.NewRecorder(
+			reference.GetColorProps(), distortion.GetColorProps(), numFrames)
+		if err != nil {
+			return frameRecorders{}, err
+		}
+	}
+
+	if settings.complexity {
+		recorders.complexity, err = analysis.NewComplexityRecorder(
+			reference.GetColorProps(), numFrames,
+			analysis.ComplexityOptions{})
+		if err != nil {
+			return frameRecorders{}, err
+		}
+	}
+
+	if settings.frameMetadata {
+		recorders.metadata = analysis.NewMetadataRecorder(numFrames)
+	}
+
+	comp.SetFrameObserver(recorders.observe)
+	return recorders, nil
+}
+
+// observe passes the compared frame pair to every recorder.
+func (r frameRecorders) observe(index int, a, b *video.Frame) {
+	if r.signatures != nil {
+		r.signatures.Observe(index, a, b)
+	}
+	if r.complexity != nil {
+		r.complexity.Observe(index, a, b)
+	}
+	if r.metadata != nil {
+		r.metadata.Observe(index, a, b)
+	}
+}
+
+// analyseFrames runs the requested detectors over the recorded signatures.
+// frameRate is the frame rate of the compared video.
+func analyseFrames(ctx context.Context, recorders frameRecorders,
+	frameRate float64) (frameReport, error) {
+	var report frameReport
+	if recorders.complexity != nil {
+		report.complexity = recorders.complexity.Features()
+	}
+	if recorders.metadata != nil {
+		report.metadata[0], report.metadata[1] =
+			recorders.metadata.Metadata()
+	}
+
+	if recorders.signatures == nil {
+		return report, nil
+	}
+
+	reference, distortion := recorders.signatures.Signatures()
+	// Both sides of --no-reference are the distortion, whose events are
+	// only reported once.
+	if settings.noReference {
+		reference = nil
+	}
+
+	if settings.detectCadence {
+		report.events = append(report.events, analysis.DetectCadence(
+			reference, distortion, analysis.CadenceOptions{})...)
+	}
+	if settings.blackFreeze != "off" {
+		report.events = append(report.events, analysis.DetectSegments(
+			reference, distortion, analysis.SegmentOptions{})...)
+	}
+	if settings.avSync {
+		var err error
+		report.sync, err = estimateSync(ctx, reference, distortion,
+			frameRate)
+		if err != nil {
+			return frameReport{}, err
+		}
+	}
+
+	return report, nil
+}
+
+// estimateSync reads the audio of both files and estimates the sync drift of
+// the distortion, using the recorded signatures to account for video that is
+// itself offset from the reference.
+func estimateSync(ctx context.Context, reference,
+	distortion []analysis.Signature, frameRate float64) ([]analysis.SyncPoint,
+	error) {
+	referenceEnvelope, err := sources.ReadAudioEnvelope(ctx,
+		settings.referenceVideo, syncEnvelopeRate)
+	if err != nil {
+		return nil, fmt.Errorf("reference audio: %w", err)
+	}
+
+	distortionEnvelope, err := sources.ReadAudioEnvelope(ctx,
+		settings.distortionVideo, syncEnvelopeRate)
+	if err != nil {
+		return nil, fmt.Errorf("distortion audio: %w", err)
+	}
+
+	offsets := analysis.VideoOffsets(reference, distortion,
+		analysis.CadenceOptions{})
+
+	return analysis.EstimateSync(referenceEnvelope, distortionEnvelope,
+		offsets, analysis.SyncOptions{EnvelopeRate: syncEnvelopeRate,
+			FrameRate: frameRate})
+}
+
+// gopScores aggregates the scores of every metric per GOP of the distortion,
+// or per scene of --scene-list, for --worst-gops. It returns nil if the flag is off.
+func gopScores(distortion video.Source,
+	scores map[string][]float64) (map[string][]analysis.GOP, error) {
+	if settings.worstGOPs <= 0 {
+		return nil, nil
+	}
+	if settings.keyFrameMode != "off" || settings.twoPassStride > 0 {
+		return nil, errors.New("--worst-gops needs every frame and cannot " +
+			"be combined with --keyframe-mode or --two-pass")
+	}
+
+	keyFrames, err := sceneCuts(distortion)
+	if err != nil {
+		return nil, fmt.Errorf("--worst-gops: %w", err)
+	}
+
+	frameRate := float64(distortion.GetFrameRate())
+	gops := make(map[string][]analysis.GOP, len(scores))
+	for name, values := range scores {
+		gops[name] = analysis.GOPScores(values, keyFrames, frameRate,
+			higherIsWorse(name))
+	}
+	return gops, nil
+}
+
+// worstSegments finds the --worst-segments of every metric, with the
+// excluded frames not counted. It returns nil if the flag is empty or the
+// scores are not in frame order.
+func worstSegments(distortion video.Source, scores map[string][]float64,
+	frames, excluded []int) (map[string][]analysis.Segment, error) {
+	if len(settings.worstSegments) == 0 || frames != nil {
+		return nil, nil
+	}
+
+	frameRate := float64(distortion.GetFrameRate())
+	segments := make(map[string][]analysis.Segment, len(scores))
+	for name, values := range scores {
+		if len(excluded) > 0 {
+			values = slices.Clone(values)
+			for _, frame := range excluded {
+				if frame < len(values) {
+					values[frame] = math.NaN()
+				}
+			}
+		}
+
+		for _, duration := range settings.worstSegments {
+			segment, ok, err := analysis.WorstSegment(values, frameRate,
+				analysis.WorstSegmentOptions{
+					Duration:      duration,
+					Percentile:    settings.worstSegmentPercentile,
+					HigherIsWorse: higherIsWorse(name),
+				})
+			if err != nil {
+				return nil, fmt.Errorf("--worst-segments: %w", err)
+			}
+			if ok {
+				segments[name] = append(segments[name], segment)
+			}
+		}
+	}
+	return segments, nil
+}
+
+// chapterScores aggregates the scores of every metric per chapter for
+// --chapters. It returns nil if the flag is off.
+func chapterScores(ctx context.Context, reference, distortion video.Source,
+	scores map[string][]float64, frames []int) (
+	map[string][]analysis.ChapterScore, error) {
+	if settings.chapters == "" {
+		return nil, nil
+	}
+	if settings.keyFrameMode != "off" {
+		return nil, errors.New("--chapters cannot be combined with " +
+			"--keyframe-mode")
+	}
+	if settings.chapters == "distortion" && settings.frameMap != "" {
+		return nil, errors.New("--chapters distortion cannot be combined " +
+			"with --frame-map, the scores follow the reference")
+	}
+
+	var chapters []analysis.Chapter
+	var err error
+	switch settings.chapters {
+	case "reference":
+		chapters, err = analysis.ProbeChapters(ctx, settings.ffprobePath,
+			settings.referenceVideo)
+	case "distortion":
+		chapters, err = analysis.ProbeChapters(ctx, settings.ffprobePath,
+			settings.distortionVideo)
+	default:
+		var file *os.File
+		if file, err = os.Open(settings.chapters); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		chapters, err = analysis.ReadChapters(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("--chapters: %w", err)
+	}
+	if len(chapters) == 0 {
+		log.Printf("--chapters: no chapters found")
+		return nil, nil
+	}
+
+	source := reference
+	if settings.chapters == "distortion" {
+		source = distortion
+	}
+	frameRate := float64(source.GetFrameRate())
+	numFrames := min(reference.GetNumFrames(), distortion.GetNumFrames())
+	if settings.frameMap != "" {
+		// frames holds reference frames, which the map may leave out.
+		numFrames = loadedFrameMap.numFrames[0]
+	}
+
+	perChapter := make(map[string][]analysis.ChapterScore, len(scores))
+	for name, values := range scores {
+		perChapter[name] = analysis.ChapterScores(chapters, values, frames,
+			numFrames, frameRate, higherIsWorse(name))
+	}
+	return perChapter, nil
+}
+
+// excludedFrames returns the frames left out of the summary by
+// --black-freeze exclude, in increasing order.
+func excludedFrames(events []analysis.Event,
+	scores map[string][]float64) []int {
+	if settings.blackFreeze != "exclude" {
+		return nil
+	}
+
+	var numFrames int
+	for _, values := range scores {
+		numFrames = max(numFrames, len(values))
+	}
+
+	mask := analysis.ExcludedFrames(events, numFrames, analysis.EventBlack,
+		analysis.EventFreeze)
+
+	var excluded []int
+	for i, skip := range mask {
+		if skip {
+			excluded = append(excluded, i)
+		}
+	}
+	return excluded
+}
+
+// withoutFrames returns scores with the given frames, which must be in
+// increasing order, removed.
+func withoutFrames(scores map[string][]float64,
+	excluded []int) map[string][]float64 {
+	if len(excluded) == 0 {
+		return scores
+	}
+
+	filtered := make(map[string][]float64, len(scores))
+	for name, values := range scores {
+		kept := make([]float64, 0, len(values))
+		next := 0
+		for i, v := range values {
+			if next < len(excluded) && excluded[next] == i {
+				next++
+				continue
+			}
+			kept = append(kept, v)
+		}
+		filtered[name] = kept
+	}
+	return filtered
+}
+
+// logSeekFallbacks logs the compared frames of each video that only decoded
+// in a fallback seek mode, as recorded by --frame-metadata.
+func logSeekFallbacks(metadata [2][]video.FrameMetadata) {
+	for i, name := range []string{"reference", "distortion"} {
+		var frames []int
+		for n, meta := range metadata[i] {
+			if _, ok := meta[video.MetaSeekFallback]; ok {
+				frames = append(frames, n)
+			}
+		}
+		if len(frames) > 0 {
+			log.Printf("%s: %d frames only decoded in a fallback seek "+
+				"mode: %v", name, len(frames), frames)
+		}
+	}
+}
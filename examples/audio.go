@@ -0,0 +1,74 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// audioLoudness measures the loudness and clipping of every audio stream of
+// both files for --audio-qc, or of the distortion alone for --no-reference.
+// It returns nil if the flag is off.
+func audioLoudness(ctx context.Context) ([]analysis.StreamLoudness, error) {
+	if !settings.audioQC {
+		return nil, nil
+	}
+
+	var reference []analysis.Loudness
+	var err error
+	if !settings.noReference {
+		reference, err = streamLoudness(ctx, settings.referenceVideo)
+		if err != nil {
+			return nil, fmt.Errorf("reference audio: %w", err)
+		}
+	}
+
+	distortion, err := streamLoudness(ctx, settings.distortionVideo)
+	if err != nil {
+		return nil, fmt.Errorf("distortion audio: %w", err)
+	}
+
+	return analysis.PairLoudness(reference, distortion), nil
+}
+
+// streamLoudness returns the loudness of every audio stream of the file at
+// path, none if it has no audio.
+func streamLoudness(ctx context.Context, path string) ([]analysis.Loudness,
+	error) {
+	var meters []*analysis.LoudnessMeter
+	var meterErr error
+
+	err := sources.DecodeAudioStreams(ctx, path,
+		func(stream sources.AudioStream) func([]float64) {
+			meter, err := analysis.NewLoudnessMeter(stream.SampleRate,
+				stream.Channels, stream.ChannelNames,
+				analysis.LoudnessOptions{})
+			if err != nil {
+				meterErr = errors.Join(meterErr,
+					fmt.Errorf("audio stream %d: %w", stream.Stream, err))
+				return func([]float64) {}
+			}
+
+			meters = append(meters, meter)
+			return meter.Write
+		})
+	switch {
+	case errors.Is(err, sources.ErrNoAudioTrack):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case meterErr != nil:
+		return nil, meterErr
+	}
+
+	loudness := make([]analysis.Loudness, len(meters))
+	for i, meter := range meters {
+		loudness[i] = meter.Loudness()
+	}
+	return loudness, nil
+}
@@ -0,0 +1,119 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/av1an"
+)
+
+// runChunk scores the --distortion chunk against its frames of --reference
+// for the target-quality loop of a chunked encoder. The pooled score of
+// --probing-metric is printed alone to stdout, the pooled score of every key
+// to stderr, and the per-frame scores are written to --output if set.
+func runChunk(ctx context.Context) error {
+	if settings.butteraugliDistMapPath != "" || settings.cvvdpDistMapPath != "" {
+		return errors.New("heat map output cannot be combined with chunk")
+	}
+	if settings.twoPassStride > 0 || settings.parallelChunks > 1 ||
+		len(settings.workers) > 0 {
+		return errors.New("--two-pass, --parallel-chunks and --workers " +
+			"cannot be combined with chunk")
+	}
+
+	stat, err := av1an.ParseStat(settings.probingStat)
+	if err != nil {
+		return err
+	}
+
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, settings.referenceVideo, settings.distortionVideo)
+	if err != nil {
+		return err
+	}
+	defer reference.Close()
+	defer distortion.Close()
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return err
+	}
+
+	frameRate := settings.frameRate
+	if frameRate < 0 {
+		frameRate = reference.GetFrameRate()
+	}
+
+	count := min(distortion.GetNumFrames(),
+		reference.GetNumFrames()-settings.chunkStart)
+	result, err := av1an.ScoreChunk(ctx, av1an.Chunk{
+		Reference:  reference,
+		Frames:     video.FrameRange{Start: settings.chunkStart, Count: count},
+		Distortion: distortion,
+	}, av1an.Options{
+		NewMetrics: func(chunkReference, chunkDistortion video.Source) (
+			[]video.Metric, error) {
+			var metricHandlers []video.Metric
+			for _, metric := range settings.metrics {
+				metricHandler, _, err := createMetricAndWriter(metric,
+					chunkReference.GetColorProps(),
+					chunkDistortion.GetColorProps(), &referenceColorSpace,
+					&distortionColorSpace, frameRate)
+				if err != nil {
+					for _, created := range metricHandlers {
+						created.Close()
+					}
+					return nil, err
+				}
+				metricHandlers = append(metricHandlers, metricHandler)
+			}
+			return metricHandlers, nil
+		},
+		Stat:         stat,
+		ProbingRate:  settings.probingRate,
+		FrameThreads: settings.frameThreads,
+	})
+	if err != nil {
+		return err
+	}
+
+	score, err := result.Score(settings.probingMetric)
+	if err != nil {
+		return err
+	}
+
+	printChunk(result, stat)
+	fmt.Println(score)
+
+	if settings.outputPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settings.outputPath, data, 0o644)
+}
+
+// printChunk prints the pooled score of every key of a chunk.
+func printChunk(result *av1an.Result, stat av1an.Stat) {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Chunk Scores")
+	fmt.Fprintln(os.Stderr, "============")
+	fmt.Fprintf(os.Stderr, "%d frames pooled with %s\n",
+		len(result.FrameIndices), stat)
+
+	for _, key := range slices.Sorted(maps.Keys(result.Scores)) {
+		fmt.Fprintf(os.Stderr, "%-24s %.6f\n", key, result.Scores[key])
+	}
+}
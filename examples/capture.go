@@ -0,0 +1,161 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// captureSettings holds the --capture-* flags, which configure the capture
+// of --reference and --distortion when they name capture devices.
+type captureSettings struct {
+	size        string
+	pixelFormat string
+	frameRate   float32
+	duration    time.Duration
+	delay       time.Duration
+	args        []string
+}
+
+// isCapturePath reports whether path names a capture device rather than a
+// file.
+func isCapturePath(path string) bool {
+	_, _, ok := sources.ParseCapturePath(path)
+	return ok
+}
+
+// openPair opens the sources of --reference and --distortion, capturing the
+// frames of both live when they name capture devices, e.g. the program feed
+// and the output of the encoder under test, so the comparison runs as a live
+// QC monitor.
+func openPair(ctx context.Context, referencePath, distortionPath string,
+	opts sources.FFms2Options) (video.Source, video.Source, error) {
+	referenceLive := isCapturePath(referencePath)
+	distortionLive := isCapturePath(distortionPath)
+	if !referenceLive && !distortionLive {
+		return sources.OpenPair(ctx, referencePath, distortionPath, opts)
+	}
+	if !referenceLive || !distortionLive {
+		return nil, nil, errors.New("a capture device can only be " +
+			"compared with another, as a file cannot keep in step with it")
+	}
+	if err := checkCaptureFlags(); err != nil {
+		return nil, nil, err
+	}
+
+	captureOpts, err := captureOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The distortion shows the program feed --capture-delay late, so as many
+	// frames of the reference are dropped to pair the same pictures.
+	referenceOpts := captureOpts
+	referenceOpts.Skip = int(math.Round(settings.capture.delay.Seconds() *
+		float64(captureOpts.FrameRate)))
+
+	reference, err := openCapture(referencePath, referenceOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reference video: %w", err)
+	}
+	distortion, err := openCapture(distortionPath, captureOpts)
+	if err != nil {
+		reference.Close()
+		return nil, nil, fmt.Errorf("distorted video: %w", err)
+	}
+
+	log.Printf("capture: comparing %d frames of %s and %s, skipping the "+
+		"first %d frames of the reference", captureOpts.Frames, referencePath,
+		distortionPath, referenceOpts.Skip)
+	return reference, distortion, nil
+}
+
+// checkCaptureFlags rejects the flags that read the sources more than once or
+// out of order, which a capture device cannot.
+func checkCaptureFlags() error {
+	switch {
+	case settings.twoPassStride > 0 || len(settings.workers) > 0 ||
+		settings.parallelChunks > 1:
+		return errors.New("capture devices cannot be compared with " +
+			"--two-pass, --workers or --parallel-chunks")
+	case settings.keyFrameMode != "off" || settings.patchOptions.Dir != "" ||
+		settings.autoCrop || settings.frameMap != "":
+		return errors.New("capture devices cannot be compared with " +
+			"--keyframe-mode, --export-patches, --auto-crop or --frame-map, " +
+			"which seek")
+	case settings.realtime:
+		return errors.New("--realtime simulates a live feed, capture " +
+			"devices deliver one")
+	}
+	return nil
+}
+
+// captureOptions returns the options of both captures from the --capture-*
+// flags. The color description of the frames is left unspecified, for the
+// --assume-* flags or the inference of the color plan to fill in.
+func captureOptions() (sources.CaptureOptions, error) {
+	capture := settings.capture
+
+	width, height, ok := strings.Cut(capture.size, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if !ok || errW != nil || errH != nil || w < 1 || h < 1 {
+		return sources.CaptureOptions{}, fmt.Errorf("invalid --capture-size "+
+			"%q, want WIDTHxHEIGHT", capture.size)
+	}
+
+	pixelFormat, err := pixfmts.GetPixFmt(capture.pixelFormat)
+	if err != nil {
+		return sources.CaptureOptions{}, fmt.Errorf("--capture-pix-fmt: %w",
+			err)
+	}
+
+	if capture.frameRate <= 0 {
+		return sources.CaptureOptions{}, errors.New("capture devices need " +
+			"the --capture-rate they are compared at")
+	}
+	frames := int(math.Round(capture.duration.Seconds() *
+		float64(capture.frameRate)))
+	if frames < 1 {
+		return sources.CaptureOptions{}, fmt.Errorf("--capture-duration %v "+
+			"holds no frame", capture.duration)
+	}
+
+	return sources.CaptureOptions{
+		Props: video.ColorProperties{Width: w, Height: h,
+			PixelFormat:    pixelFormat,
+			ColorRange:     pixfmts.ColorRangeUnspecified,
+			ColorSpace:     pixfmts.ColorSpaceUnspecified,
+			ColorTransfer:  pixfmts.ColorTransferCharacteristicUnspecified,
+			ColorPrimaries: pixfmts.ColorPrimariesUnspecified},
+		FrameRate: capture.frameRate, Frames: frames,
+		InputArgs: capture.args,
+	}, nil
+}
+
+// openCapture starts capturing from the device path names.
+func openCapture(path string, opts sources.CaptureOptions) (video.Source,
+	error) {
+	api, device, _ := sources.ParseCapturePath(path)
+	source, err := sources.OpenCapture(api, device, opts)
+	if err != nil {
+		return nil, err
+	}
+	planar, err := sources.Planarize(source)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
+	return planar, nil
+}
@@ -0,0 +1,115 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/schollz/progressbar/v3"
+)
+
+// runChunked compares the sources with --parallel-chunks independent
+// pipelines. reference and distortion are used for the first pipeline, every
+// other one reopens the videos so it gets its own decoders.
+func runChunked(ctx context.Context, reference, distortion video.Source,
+	referenceColorSpace, distortionColorSpace *vship.Colorspace) (
+	map[string][]float64, error) {
+	if settings.keyFrameMode != "off" {
+		return nil, errors.New("--keyframe-mode cannot be combined with " +
+			"--parallel-chunks")
+	}
+	if settings.butteraugliDistMapPath != "" || settings.cvvdpDistMapPath != "" {
+		return nil, errors.New("heat map output cannot be combined with " +
+			"--parallel-chunks")
+	}
+	if frameAnalysisEnabled() {
+		return nil, errors.New("--detect-cadence, --black-freeze, " +
+			"--av-sync, --complexity and --frame-metadata cannot be " +
+			"combined with --parallel-chunks")
+	}
+	if settings.patchOptions.Dir != "" {
+		return nil, errors.New("--export-patches cannot be combined with " +
+			"--parallel-chunks")
+	}
+	if settings.streamScores || len(settings.abortIf) > 0 {
+		return nil, errors.New("--stream-scores and --abort-if cannot be " +
+			"combined with --parallel-chunks")
+	}
+	if settings.subtitleMask != "" {
+		return nil, errors.New("--subtitle-mask cannot be combined with " +
+			"--parallel-chunks")
+	}
+
+	opened := false
+	open := func() (video.Source, video.Source, error) {
+		if !opened {
+			opened = true
+			return reference, distortion, nil
+		}
+		a, b, _, _, err := openSources(ctx, settings.referenceVideo,
+			settings.distortionVideo)
+		return a, b, err
+	}
+
+	newMetrics := func(a, b video.Source) ([]video.Metric, error) {
+		var metricHandlers []video.Metric
+		for _, metric := range settings.metrics {
+			metricHandler, _, err := createMetricAndWriter(metric,
+				a.GetColorProps(), b.GetColorProps(),
+				referenceColorSpace, distortionColorSpace, settings.frameRate)
+			if err != nil {
+				return nil, err
+			}
+			metricHandlers = append(metricHandlers, metricHandler)
+		}
+		return metricHandlers, nil
+	}
+
+	// Without a --scene-list RunChunked follows the keyframes of the
+	// reference itself.
+	var chunks []video.FrameRange
+	if settings.sceneListPath != "" {
+		cuts, err := sceneCuts(reference)
+		if err != nil {
+			return nil, err
+		}
+		chunks = comparator.SceneChunks(cuts, reference.GetNumFrames(),
+			settings.chunkFrames)
+	}
+
+	cache, err := scoreCache()
+	if err != nil {
+		return nil, err
+	}
+
+	bar := progressbar.NewOptions(
+		reference.GetNumFrames(),
+		progressbar.OptionSetDescription("Computing metrics"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+	)
+	progress := func(done, total int) {
+		_ = bar.Add(1)
+		scoredFrames.Add(1)
+	}
+
+	return comparator.RunChunked(ctx,
+		comparator.ChunkedOptions{
+			Open:            open,
+			NewMetrics:      newMetrics,
+			Parallel:        settings.parallelChunks,
+			FrameThreads:    settings.frameThreads,
+			MinChunkFrames:  settings.chunkFrames,
+			Chunks:          chunks,
+			Progress:        progress,
+			SkipIdentical:   settings.skipIdentical,
+			QuickRejectPSNR: settings.quickRejectPSNR,
+			CheckFrames:     settings.checkFrames,
+			ScoreCache:      cache,
+			MemoryBudget:    settings.memoryBudget,
+		})
+}
@@ -1,11 +1,17 @@
+//go:build cgo && !nocgo
+
 package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
 	"github.com/spf13/pflag"
 )
 
@@ -13,6 +19,23 @@ const flagGroupAnnotation = "group"
 
 func cliUsage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s --references <label=path,...> "+
+		"--distortion <path> [flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s --reference v4l2:<device> "+
+		"--distortion decklink:<device> --capture-rate <fps> [flags]\n",
+		filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s --no-reference --distortion <path> "+
+		"[flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s validate --mos <csv> [flags]\n",
+		filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s chunk --chunk-start <frame> [flags]\n",
+		filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s extend --output <json> --metrics "+
+		"<metrics> [flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s image <reference> <distortion> "+
+		"[flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(os.Stderr, "       %s frames --extract-frames <frames> "+
+		"[flags]\n\n", filepath.Base(os.Args[0]))
 
 	// Group flags by annotation, default to "General Options"
 	helpGroupLists := make(map[string][]*pflag.Flag)
@@ -52,9 +75,74 @@ func cliUsage() {
 		fmt.Fprint(os.Stderr, "\n")
 	}
 
+	printMetrics()
 	fmt.Fprintln(os.Stderr)
 }
 
+// printMetrics documents the built-in metrics from their capabilities.
+func printMetrics() {
+	names := []string{metrics.SSIMulacra2Name, metrics.ButteraugliName,
+		metrics.CVVDPName, metrics.BlockinessName, metrics.BandingName}
+	var longestName int
+	for _, name := range names {
+		longestName = max(longestName, len(name)+1)
+	}
+
+	fmt.Fprint(os.Stderr, colorText(hiYellow, "Metrics:\n"))
+	for _, name := range names {
+		capabilities, _ := metrics.BuiltinCapabilities(name)
+		padding := strings.Repeat(" ", longestName-len(name))
+		fmt.Fprintf(os.Stderr, "\t%s %s\n", colorText(cyan, name+padding),
+			colorText(green, describeCapabilities(capabilities)))
+	}
+}
+
+// describeCapabilities summarizes capabilities in one line of help text.
+func describeCapabilities(capabilities video.Capabilities) string {
+	parts := []string{capabilities.Direction.String(),
+		describeScoreRange(capabilities.MinScore, capabilities.MaxScore)}
+
+	if capabilities.BitDepths != nil {
+		depths := make([]string, len(capabilities.BitDepths))
+		for i, depth := range capabilities.BitDepths {
+			depths[i] = strconv.Itoa(depth)
+		}
+		parts = append(parts, strings.Join(depths, "/")+"-bit input")
+	}
+	if capabilities.MixedBitDepths {
+		parts = append(parts, "mixed bit depths")
+	}
+	if capabilities.DistortionMap {
+		parts = append(parts, "distortion maps")
+	}
+	if capabilities.Sequential {
+		parts = append(parts, "frames in order")
+	}
+	if capabilities.GPU {
+		parts = append(parts, "needs a GPU")
+	}
+	if capabilities.NoReference {
+		parts = append(parts, "no reference")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// describeScoreRange describes the range of scores from lowest to highest,
+// either of which may be infinite.
+func describeScoreRange(lowest, highest float64) string {
+	switch {
+	case math.IsInf(lowest, -1) && math.IsInf(highest, 1):
+		return "unbounded scores"
+	case math.IsInf(highest, 1):
+		return fmt.Sprintf("scores from %g", lowest)
+	case math.IsInf(lowest, -1):
+		return fmt.Sprintf("scores up to %g", highest)
+	default:
+		return fmt.Sprintf("scores from %g to %g", lowest, highest)
+	}
+}
+
 func printFormattedFlag(f *pflag.Flag, maxFlagName, maxHelpText, maxDef int) {
 	defaultValue := getDefaultString(f)
 	defaultValuePadding := strings.Repeat(" ", maxDef-len(defaultValue))
@@ -1,32 +1,139 @@
+//go:build cgo && !nocgo
+
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/dataset"
 	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/plugin"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
 	"github.com/spf13/pflag"
 )
 
 type cliSettings struct {
 	referenceVideo, distortionVideo string
+	references                      map[string]string
+	noReference                     bool
+	referenceDir                    string
+	referenceSamples                int
 	metrics                         []string
+	pluginDirs                      []string
 	frameThreads                    int
+	metricWorkers                   map[string]int
+	parallelChunks                  int
+	decodeShards                    int
+	decodeThreads                   int
+	seekMode                        sources.SeekMode
+	indexErrors                     sources.IndexErrorMode
+	indexCache                      string
+	indexCacheSize                  int64
+	memoryBudget                    int64
+	pcieBandwidth                   float64
+	realtime                        bool
+	capture                         captureSettings
+	realtimeLatency                 time.Duration
+	deterministic                   bool
+	skipIdentical                   bool
+	quickRejectPSNR                 float64
+	checkFrames                     bool
+	scoreCache                      string
+	soak                            int
+	detectCadence                   bool
+	blackFreeze                     string
+	avSync                          bool
+	normalize                       bool
+	complexity                      bool
+	frameMetadata                   bool
+	startOffset                     string
+	worstGOPs                       int
+	worstSegments                   []float64
+	worstSegmentPercentile          float64
+	sceneListPath                   string
+	exportSceneList                 string
+	sceneListFormat                 string
+	chapters                        string
+	subtitleMask                    string
+	subtitleBand                    float64
+	autoCrop                        bool
+	frameRateMatch                  string
+	frameMap                        string
+	fieldMode                       string
+	requantize                      string
+	proxy                           [2]int
+	resolutions                     []analysisResolution
+	audioQC                         bool
+	ffprobePath                     string
+	chunkFrames                     int
 	frameRate                       float32
 	compareWidth, compareHeight     int
+	keyFrameMode                    string
+	twoPassStride                   int
+	twoPassMetric                   string
+	twoPassFraction                 float64
+	orientationMode                 string
+
+	workers      []string
+	workerListen string
+	workerSlots  int
+
+	vsListen string
+
+	datasetDir string
+	mosPath    string
+
+	chunkStart    int
+	probingStat   string
+	probingRate   int
+	probingMetric string
+
+	extractFrames []int
+	extractTimes  []float64
+	extractDir    string
+
+	toneMap        bool
+	toneMapOptions vcolor.ToneMapOptions
+
+	strictColor   bool
+	inference     vcolor.Inference
+	colorMismatch string
+
+	outputPath   string
+	streamScores bool
+	abortIf      []string
+
+	patchSelection string
+	patchOptions   dataset.Options
 
 	butteraugliDistMapPath string
 	butteraugliClipping    float32
 	cvvdpDistMapPath       string
 	cvvdpClipping          float32
+	heatmapFFmpeg          metrics.FFmpegOptions
+	heatmapBurnIn          bool
+	heatmapAutoRange       metrics.AutoRange
+	// stillImage is set by the image subcommand, whose .png heat maps hold
+	// the map of the single frame pair.
+	stillImage bool
 
 	butteraugliQnormValue int
+	butteraugliMaxCLL     bool
+	// butteraugliIntensity overrides the display nits for Butteraugli when
+	// positive, see --butteraugli-maxcll.
+	butteraugliIntensity float32
 
 	cvvdpUseTemporalScore bool
-	cvvdpReizeToDisplay   bool
+	cvvdpResizeToDisplay  bool
+	cvvdpAmbientSweep     []int
+	cvvdpDistanceSweep    []float32
 
 	displayModel vship.DisplayModel
 }
@@ -41,42 +148,243 @@ func init() {
 	// General Flags
 	pflag.StringVarP(&settings.referenceVideo, "reference", "r", "", "The reference video path the distorted video will be compared against")
 	pflag.StringVarP(&settings.distortionVideo, "distortion", "d", "", "The distorted video path that will be compared to the reference")
-	cliMetrics := pflag.String("metrics", metrics.SSIMulacra2Name, fmt.Sprintf("Comma seperated list of metrics that will be used [%s, %s, %s]", metrics.SSIMulacra2Name, metrics.ButteraugliName, metrics.CVVDPName))
-	pflag.IntVar(&settings.frameThreads, "frame-threads", 3, "Number of frames to process in parallel. Set to 2 or 1 with 2 or more metrics")
+	pflag.BoolVar(&settings.noReference, "no-reference", false, "Score the distorted video alone, without a reference, with the no-reference metrics, Blockiness and Banding unless --metrics names some, and --black-freeze, --complexity, --audio-qc and the other analysis of a single video")
+	pflag.StringToStringVar(&settings.references, "references", nil, "Compare the distorted video against several references at once instead of --reference, as label=path pairs e.g. pre-grade=a.mkv,post-grade=b.mkv. The distortion is decoded once, frames are paired by number and every score is marked with the label of its reference, e.g. Ssimulacra2@pre-grade, and the reference scoring closest is reported by metric")
+	pflag.StringVar(&settings.referenceDir, "reference-dir", "", "Pick the reference out of the videos in this directory instead of --reference, when file names cannot be trusted. A few frames of every video are compared cheaply with the distortion and the closest one is compared in full")
+	pflag.IntVar(&settings.referenceSamples, "reference-samples", 8, "Frames of every video sampled by --reference-dir")
+	cliMetrics := pflag.String("metrics", metrics.SSIMulacra2Name, fmt.Sprintf("Comma seperated list of metrics that will be used [%s, %s, %s, %s, %s]", metrics.SSIMulacra2Name, metrics.ButteraugliName, metrics.CVVDPName, metrics.BlockinessName, metrics.BandingName))
+	pflag.StringSliceVar(&settings.pluginDirs, "plugin-dir", nil, fmt.Sprintf("Comma seperated list of directories searched for metric plugins. A plugin named %s<name> is selected with --metrics <name>", plugin.ExecutablePrefix))
+	pflag.IntVar(&settings.frameThreads, "frame-threads", 3, "Number of frames to process in parallel. 0 uses the largest --metric-workers value")
+	pflag.StringToIntVar(&settings.metricWorkers, "metric-workers", nil, fmt.Sprintf("Workers per metric e.g. %s=4,%s=1. Each metric only computes this many frames at once. Unlisted metrics use --frame-threads", metrics.SSIMulacra2Name, metrics.ButteraugliName))
+	pflag.IntVar(&settings.parallelChunks, "parallel-chunks", 1, "Split the videos into scene chunks and compare this many at once, each with its own decoders")
+	pflag.Float64Var(&settings.pcieBandwidth, "pcie-bandwidth", 12, "Bandwidth of the link to the GPU in GB/s, about 12 for PCIe 3.0 x16 and 25 for PCIe 4.0 x16. The metric throughput report calls GPU metrics moving frames at over half of it transfer bound")
+	pflag.BoolVar(&settings.realtime, "realtime", false, "Deliver the frames of both videos at the frame rate of the reference, as a live feed would, and report whether the metrics keep up with it on this machine")
+	pflag.DurationVar(&settings.realtimeLatency, "realtime-latency", 2*time.Second, "The longest --realtime lets a frame pair take from its arrival to its scores for the metrics to count as keeping up")
+	pflag.StringVar(&settings.capture.size, "capture-size", "1920x1080", "The WIDTHxHEIGHT frames are captured at when --reference and --distortion name capture devices, v4l2:/dev/video0 or decklink:<device name>")
+	pflag.StringVar(&settings.capture.pixelFormat, "capture-pix-fmt", "yuv422p10le", "The pixel format frames are captured in from capture devices")
+	pflag.Float32Var(&settings.capture.frameRate, "capture-rate", 0, "The frame rate of the capture devices, required to compare them")
+	pflag.DurationVar(&settings.capture.duration, "capture-duration", time.Minute, "How long to compare capture devices for")
+	pflag.DurationVar(&settings.capture.delay, "capture-delay", 0, "How much later the distortion device shows a picture than the reference device, e.g. the latency of the encoder under test. As many frames of the reference are skipped")
+	pflag.StringArrayVar(&settings.capture.args, "capture-arg", nil, "An ffmpeg input option of both capture devices, e.g. -format_code for DeckLink, repeat once per argument")
+	memoryBudgetMiB := pflag.Int64("memory-budget", 0, "Cap the memory used for frame buffers in MiB, lowering queue depths and --frame-threads to fit. 0 means no cap")
+	pflag.IntVar(&settings.decodeShards, "decode-shards", 1, "Decode each video with this many decoders working on keyframe aligned frame ranges in parallel. Best suited to full sequential comparisons")
+	pflag.IntVar(&settings.decodeThreads, "decode-threads", 0, "Decoder threads of every decoder. 0 divides the CPUs among the --decode-shards decoders")
+	seekMode := pflag.String("seek-mode", "normal", "How the decoders seek [linear-no-rewind, linear, normal, unsafe, aggressive]. Frames that fail to decode are retried in the safer modes. linear is slowest and most robust on broken containers")
+	indexErrors := pflag.String("index-errors", "abort", "What indexing does about undecodable packets [abort, clear-track, stop-track, ignore]. stop-track ends the video at the error, ignore skips the packets")
+	pflag.StringVar(&settings.indexCache, "index-cache", "", "Keep the ffms2 indexes of the videos in this directory, keyed by their content, so no video is indexed twice across runs. Safe to share between concurrent runs")
+	indexCacheSizeMiB := pflag.Int64("index-cache-size", 4096, "Remove the least recently used indexes once --index-cache holds more than this many MiB. 0 means no limit")
 	pflag.Float32VarP(&settings.frameRate, "fps", "f", -1, "Overide the fps that will be used for temporal scaling. Default is the reference fps")
 	pflag.IntVar(&settings.compareWidth, "width", -1, "Overide the resolution to compare at width. -1 defaults to the largest source")
 	pflag.IntVar(&settings.compareHeight, "height", -1, "Overide the resolution to compare at height. -1 defaults to the largest source")
+	pflag.StringVar(&settings.keyFrameMode, "keyframe-mode", "off", "Only compare a subset of frames for fast smoke tests [off, keyframes, gop]")
+	pflag.IntVar(&settings.twoPassStride, "two-pass", 0, "Score every Nth frame, then score every frame around the worst of those samples. 0 disables it")
+	pflag.StringVar(&settings.twoPassMetric, "two-pass-metric", "", "Score key ranking the --two-pass samples, e.g. Ssimulacra2. Defaults to the first score key in sorted order")
+	pflag.Float64Var(&settings.twoPassFraction, "two-pass-fraction", 0.1, "Share of the --two-pass samples, worst first, whose surroundings are scored densely")
+	pflag.StringVar(&settings.orientationMode, "orientation", "check", "How to handle rotation and flip metadata [check, apply, ignore]. check errors if the sources differ")
+	pflag.StringArrayVar(&settings.abortIf, "abort-if", nil, "Stop early and report the frames scored so far when a condition on a score key is met, e.g. Ssimulacra2:mean<40@500 for a running mean below 40 after 500 frames or Ssimulacra2:frame<10 for any frame below 10. mean-worse= and frame-worse= compare in the direction of the metric, e.g. Butteraugli_3Norm:frame-worse=4 for any frame above 4. Repeat for several conditions")
+	pflag.BoolVar(&settings.skipIdentical, "skip-identical", false, "Hash decoded frames and give bit-identical frame pairs a perfect score without running the metrics")
+	pflag.Float64Var(&settings.quickRejectPSNR, "quick-reject-psnr", 0, "Give frame pairs with at least this PSNR in dB a perfect score without running the metrics, e.g. 50 for near-lossless encodes. 0 disables it")
+	pflag.IntVar(&settings.soak, "soak", 0, "Repeat the comparison this many times after a first run, failing if any run leaves pinned buffers, metric handlers or decoder handles behind, for testing long running services. Scores are neither summarized nor written. 0 disables it")
+	pflag.BoolVar(&settings.checkFrames, "check-frames", false, "Check that every decoded frame matches the geometry of its pixel format and holds no stray high bits or NaN samples before scoring it, failing with the source and frame instead of passing a corrupt buffer to the metrics")
+	pflag.StringVar(&settings.scoreCache, "score-cache", "", "Keep the metric scores in this file, keyed by the hashes of the frame pairs and the metric settings, so re-running a comparison only scores frame pairs and metrics it has not seen. Metrics writing heatmaps or with temporal state are not cached")
+	pflag.BoolVar(&settings.detectCadence, "detect-cadence", false, "Report runs of duplicated frames and dropped frames in the distorted video")
+	pflag.StringVar(&settings.blackFreeze, "black-freeze", "off", "Detect black frames and frozen video in either source [off, flag, exclude]. exclude leaves them out of the summary")
+	pflag.BoolVar(&settings.avSync, "av-sync", false, "Estimate how far the distorted audio drifts out of sync with its video compared to the reference")
+	pflag.BoolVar(&settings.audioQC, "audio-qc", false, "Measure the EBU R128 loudness, true peak and clipping of every audio stream of both videos and compare them")
+	pflag.BoolVar(&settings.frameMetadata, "frame-metadata", false, "Record the timestamp, picture type, keyframe flag and any seek mode fallback of every compared frame of both sources and summarize the scores by picture type of the distortion")
+	pflag.StringVar(&settings.startOffset, "start-offset", "auto", "How to pair frames of videos whose first frames have different timestamps, e.g. from MP4 edit lists [auto, ignore]. auto skips the frames shown before the later video starts, ignore pairs frames by number")
+	pflag.IntVar(&settings.worstGOPs, "worst-gops", 0, "Aggregate the scores per GOP of the distortion and report this many GOPs with the worst mean score. 0 disables it")
+	pflag.Float64SliceVar(&settings.worstSegments, "worst-segments", []float64{1, 5}, "Durations in seconds of the contiguous segments with the worst pooled score to report per metric in the summary. Empty disables it")
+	pflag.Float64Var(&settings.worstSegmentPercentile, "worst-segment-percentile", 10, "Percentile of the frame scores of a segment, counted from the worst, that pools them for --worst-segments")
+	pflag.StringVar(&settings.sceneListPath, "scene-list", "", "x264 QP file or ffprobe output listing the scene cuts of the videos. Replaces the keyframes of the sources for --worst-gops, --parallel-chunks and --workers")
+	pflag.StringVar(&settings.exportSceneList, "export-scene-list", "", "Write the scene cuts of the reference, its keyframes or those of --scene-list, to this path in --scene-list-format")
+	pflag.StringVar(&settings.sceneListFormat, "scene-list-format", "qpfile", "Format --export-scene-list writes [qpfile, ffprobe]. --scene-list detects the format")
+	pflag.StringVar(&settings.chapters, "chapters", "", "Report the scores per chapter: reference or distortion reads the chapters of that video with ffprobe, any other value is a file of ffprobe -show_chapters -of json output. Empty disables it")
+	pflag.StringVar(&settings.subtitleMask, "subtitle-mask", "", "Blank burned-in subtitles of the distortion in both videos before scoring: detect finds text the reference lacks, a .srt, .vtt or .ass file blanks the --subtitle-band while its subtitles are shown. Empty disables it")
+	pflag.Float64Var(&settings.subtitleBand, "subtitle-band", 0.35, "Share of the frame height, from the bottom, --subtitle-mask searches or blanks")
+	pflag.StringVar(&settings.frameRateMatch, "frame-rate-match", "none", "How to compare videos whose frame counts differ: none, ivtc undoes hard 3:2 pulldown of the video with 5 frames for every 4 of the other, map repeats or drops distorted frames to match the reference by time")
+	pflag.StringVar(&settings.frameMap, "frame-map", "", "File mapping reference frames to distorted frames, one \"<ref> <dist>\" or \"<first>-<last> <first>-<last>\" pair per line, to compare an edited or trimmed distortion against its master. Unmapped frames are skipped and reported")
+	pflag.StringVar(&settings.fieldMode, "fields", "off", "Score the fields of interlaced videos as separate half-height frames, in the order off, auto from the frame flags, tff or bff, to catch field blending hidden at frame level")
+	pflag.StringVar(&settings.requantize, "requantize", "off", "When the bit depths differ, e.g. a 10-bit reference against an 8-bit encode, lower the deeper video to the other's depth before scoring: off leaves it to the metrics, round rounds every sample, error-diffusion dithers with Floyd-Steinberg. The policy is recorded in the results")
+	proxy := pflag.String("proxy", "", "Downscale both videos to this WIDTHxHEIGHT proxy resolution, e.g. 960x540, before scoring for fast relative comparisons. Halves them on the CPU while they stay at least that large and records the scale in the results")
+	resolutions := pflag.StringSlice("resolutions", nil, "Score every metric at each of these analysis resolutions from one decode, e.g. native,1080p,540p or 1280x720, keyed as <metric>@540p. A height alone keeps the aspect ratio of the reference")
+	pflag.BoolVar(&settings.autoCrop, "auto-crop", false, "Detect letterbox and pillarbox bars in both videos and crop them before scoring, where both have them")
+	pflag.StringVar(&settings.ffprobePath, "ffprobe-path", "ffprobe", "The ffprobe executable --chapters reads chapters with")
+	pflag.BoolVar(&settings.complexity, "complexity", false, "Extract per-frame spatial information, temporal information and edge density of the reference and correlate them with the scores")
+	pflag.BoolVar(&settings.normalize, "normalize", false, "Also report every metric on a common 0-100 quality scale under <metric>_norm keys")
+	pflag.BoolVar(&settings.deterministic, "deterministic", false, "Use one frame thread, pin chunks to workers and leave the timestamp out of the results file so identical inputs give identical results")
 	printHelp := pflag.BoolP("help", "h", false, "Show this help message")
 
+	// Color Settings
+	var colorSectionName string = "Color Options"
+	pflag.BoolVar(&settings.toneMap, "tonemap", false, "Tone-map the HDR source to SDR when comparing HDR against SDR")
+	addFlagToHelpGroup("tonemap", colorSectionName)
+
+	pflag.Float64Var(&settings.toneMapOptions.SourcePeak, "tonemap-source-peak", 1000, "Peak brightness of the HDR source in nits")
+	addFlagToHelpGroup("tonemap-source-peak", colorSectionName)
+
+	pflag.Float64Var(&settings.toneMapOptions.TargetPeak, "tonemap-target-peak", 100, "Peak brightness of the SDR target in nits")
+	addFlagToHelpGroup("tonemap-target-peak", colorSectionName)
+
+	pflag.BoolVar(&settings.strictColor, "strict-color", false, "Error on untagged matrix, transfer, primaries or range instead of assuming BT.709 limited")
+	addFlagToHelpGroup("strict-color", colorSectionName)
+
+	assumeMatrix := pflag.String("assume-matrix", "", "Matrix to assume for untagged sources e.g. bt709, bt601, bt2020")
+	addFlagToHelpGroup("assume-matrix", colorSectionName)
+
+	assumeTransfer := pflag.String("assume-transfer", "", "Transfer to assume for untagged sources e.g. bt709, srgb, pq, hlg")
+	addFlagToHelpGroup("assume-transfer", colorSectionName)
+
+	assumePrimaries := pflag.String("assume-primaries", "", "Primaries to assume for untagged sources e.g. bt709, bt601, bt2020")
+	addFlagToHelpGroup("assume-primaries", colorSectionName)
+
+	assumeRange := pflag.String("assume-range", "", "Range to assume for untagged sources [limited, full]")
+	addFlagToHelpGroup("assume-range", colorSectionName)
+
+	pflag.StringVar(&settings.colorMismatch, "color-mismatch", "warn", "What to do when the sources color properties differ [warn, error, ignore]")
+	addFlagToHelpGroup("color-mismatch", colorSectionName)
+
+	// Distributed Settings
+	var distributedSectionName string = "Distributed Options"
+	pflag.IntVar(&settings.chunkFrames, "chunk-frames", 240, "The smallest scene chunk used by --parallel-chunks and --workers")
+	addFlagToHelpGroup("chunk-frames", distributedSectionName)
+
+	pflag.StringSliceVar(&settings.workers, "workers", nil, "Comma seperated list of worker addresses to farm chunks out to e.g. 10.0.0.2:7878. The video paths must be valid on every worker")
+	addFlagToHelpGroup("workers", distributedSectionName)
+
+	pflag.StringVar(&settings.workerListen, "worker-listen", "", "Run as a worker serving chunks on this address e.g. :7878. Color, display and metric option flags must match the coordinator")
+	addFlagToHelpGroup("worker-listen", distributedSectionName)
+
+	pflag.IntVar(&settings.workerSlots, "worker-slots", 1, "Chunks a worker compares at once. Coordinators send this many chunks to each worker")
+	addFlagToHelpGroup("worker-slots", distributedSectionName)
+
+	// VapourSynth Settings
+	var vapourSynthSectionName string = "VapourSynth Options"
+	pflag.StringVar(&settings.vsListen, "vs-listen", "", "Serve the selected metrics to VapourSynth scripts on this address e.g. 127.0.0.1:7879, see video/vsbridge/gometrics.py. Color, display and metric option flags apply to every session")
+	addFlagToHelpGroup("vs-listen", vapourSynthSectionName)
+
+	// Validation Settings
+	var validationSectionName string = "Validation Options (validate subcommand)"
+	pflag.StringVar(&settings.datasetDir, "dataset", ".", "Directory the clip paths of the --mos file are relative to")
+	addFlagToHelpGroup("dataset", validationSectionName)
+
+	pflag.StringVar(&settings.mosPath, "mos", "", "CSV file with distortion, mos and optional reference columns. Clips without a reference use --reference")
+	addFlagToHelpGroup("mos", validationSectionName)
+
+	// Chunk Settings
+	var chunkSectionName string = "Chunk Options (chunk subcommand)"
+	pflag.IntVar(&settings.chunkStart, "chunk-start", 0, "Reference frame the --distortion chunk was encoded from. The chunk covers as many reference frames as it has")
+	addFlagToHelpGroup("chunk-start", chunkSectionName)
+
+	pflag.StringVar(&settings.probingStat, "probing-stat", "mean", "How per-frame scores are pooled, as av1an's --probing-stat: mean, harmonic, root-mean-square, median, percentile=<p>, minimum or maximum")
+	addFlagToHelpGroup("probing-stat", chunkSectionName)
+
+	pflag.IntVar(&settings.probingRate, "probing-rate", 1, "Score every Nth frame of the chunk, as av1an's --probing-rate")
+	addFlagToHelpGroup("probing-rate", chunkSectionName)
+
+	pflag.StringVar(&settings.probingMetric, "probing-metric", "", "Score key printed to stdout, e.g. Ssimulacra2. May be left empty when the metrics report a single score")
+	addFlagToHelpGroup("probing-metric", chunkSectionName)
+
+	// Frames Settings
+	var framesSectionName string = "Frames Options (frames subcommand)"
+	pflag.IntSliceVar(&settings.extractFrames, "extract-frames", nil, "Comma separated frame numbers to write side by side from both videos, e.g. the worst frames of a report")
+	addFlagToHelpGroup("extract-frames", framesSectionName)
+
+	pflag.Float64SliceVar(&settings.extractTimes, "extract-times", nil, "Comma separated times in seconds from the first frame whose frames are written like --extract-frames")
+	addFlagToHelpGroup("extract-times", framesSectionName)
+
+	pflag.StringVar(&settings.extractDir, "extract-dir", "frames", "Directory the frame_<n>.png images of --extract-frames and --extract-times are written to")
+	addFlagToHelpGroup("extract-dir", framesSectionName)
+
 	// Output Settings
 	var outputsSectionString string = "Output Options"
-	pflag.StringVar(&settings.butteraugliDistMapPath, "butteraugli-video-path", "", "Output path for Butterauglis heat map. Empty disables output")
+	pflag.StringVarP(&settings.outputPath, "output", "o", "", "Output path for a JSON results file with per-frame scores and run metadata. Empty disables output")
+	addFlagToHelpGroup("output", outputsSectionString)
+
+	pflag.BoolVar(&settings.streamScores, "stream-scores", false, "Print one NDJSON object per compared frame to stdout as it completes, with its index, source frame number and scores")
+	addFlagToHelpGroup("stream-scores", outputsSectionString)
+
+	pflag.StringVar(&settings.butteraugliDistMapPath, "butteraugli-video-path", "", "Output path for Butterauglis heat map: a video encoded with ffmpeg, a .y4m file of 16-bit grayscale maps, a .png path for max and mean projections over the run written to <name>_max.png and <name>_mean.png, or tcp://host:port or unix:///path to stream raw float32 maps. Empty disables output")
 	addFlagToHelpGroup("butteraugli-video-path", outputsSectionString)
 
 	pflag.Float32Var(&settings.butteraugliClipping, "butteraugli-clipping-value", 15, "The clipping value for Butterauglis distortion map.")
 	addFlagToHelpGroup("butteraugli-clipping-value", outputsSectionString)
 
-	pflag.StringVar(&settings.cvvdpDistMapPath, "cvvdp-video-path", "", "Output path for CVVDPs heat map. Empty disables output")
+	pflag.StringVar(&settings.cvvdpDistMapPath, "cvvdp-video-path", "", "Output path for CVVDPs heat map: a video encoded with ffmpeg, a .y4m file of 16-bit grayscale maps, a .png path for max and mean projections over the run written to <name>_max.png and <name>_mean.png, or tcp://host:port or unix:///path to stream raw float32 maps. Empty disables output")
 	addFlagToHelpGroup("cvvdp-video-path", outputsSectionString)
 
 	pflag.Float32Var(&settings.cvvdpClipping, "cvvdp-clipping-value", 0.75, "The clipping value for CVVDPs distortion map.")
 	addFlagToHelpGroup("cvvdp-clipping-value", outputsSectionString)
 
+	pflag.BoolVar(&settings.heatmapBurnIn, "heatmap-burn-in", false, "Overlay the frame index, timestamp and score of every frame as text on its heat map. Ignored for .png projections")
+	addFlagToHelpGroup("heatmap-burn-in", outputsSectionString)
+
+	pflag.Float64Var(&settings.heatmapAutoRange.Percentile, "heatmap-auto-range", 0, "Scale heat maps by this percentile of the recent maps instead of the clipping values, e.g. 99. 0 uses the fixed clipping values")
+	addFlagToHelpGroup("heatmap-auto-range", outputsSectionString)
+
+	pflag.IntVar(&settings.heatmapAutoRange.Window, "heatmap-auto-range-window", 48, "Number of recent heat maps whose percentiles are pooled by their maximum for --heatmap-auto-range")
+	addFlagToHelpGroup("heatmap-auto-range-window", outputsSectionString)
+
+	pflag.StringVar(&settings.heatmapFFmpeg.Binary, "ffmpeg-path", "ffmpeg", "The ffmpeg executable used to encode heat map videos")
+	addFlagToHelpGroup("ffmpeg-path", outputsSectionString)
+
+	pflag.StringVar(&settings.heatmapFFmpeg.Encoder, "heatmap-encoder", "libx264", "Video encoder for heat maps, e.g. libx264, h264_nvenc or hevc_nvenc")
+	addFlagToHelpGroup("heatmap-encoder", outputsSectionString)
+
+	pflag.StringArrayVar(&settings.heatmapFFmpeg.OutputArgs, "heatmap-ffmpeg-arg", nil, "Replaces every ffmpeg argument after the heat map input, repeat once per argument. {output}, {width}, {height} and {fps} are substituted")
+	addFlagToHelpGroup("heatmap-ffmpeg-arg", outputsSectionString)
+
+	pflag.StringVar(&settings.patchOptions.Dir, "export-patches", "", "Directory to write webdataset tar shards of paired reference/distorted crops with their scores to. Empty disables export")
+	addFlagToHelpGroup("export-patches", outputsSectionString)
+
+	pflag.StringVar(&settings.patchSelection, "patch-selection", "random", "How crops are chosen: random, or worst to take the worst scoring frames of --patch-metric and their most distorted areas")
+	addFlagToHelpGroup("patch-selection", outputsSectionString)
+
+	pflag.StringVar(&settings.patchOptions.Metric, "patch-metric", "", "Score key ranking frames for --patch-selection worst, e.g. Ssimulacra2")
+	addFlagToHelpGroup("patch-metric", outputsSectionString)
+
+	pflag.IntVar(&settings.patchOptions.Size, "patch-size", 256, "Width and height of exported crops in luma samples")
+	addFlagToHelpGroup("patch-size", outputsSectionString)
+
+	pflag.IntVar(&settings.patchOptions.Frames, "patch-frames", 100, "Number of frames to export crops from")
+	addFlagToHelpGroup("patch-frames", outputsSectionString)
+
+	pflag.IntVar(&settings.patchOptions.PatchesPerFrame, "patches-per-frame", 4, "Number of crops exported per frame")
+	addFlagToHelpGroup("patches-per-frame", outputsSectionString)
+
+	pflag.Uint64Var(&settings.patchOptions.Seed, "patch-seed", 0, "Seed of the random crop selection")
+	addFlagToHelpGroup("patch-seed", outputsSectionString)
+
 	// butteraugli settings
 	var butteraugliSectionName string = "Butteraugli Options"
 	pflag.IntVar(&settings.butteraugliQnormValue, "butteraugli-qnorm", 5, "QNorm value to use for frame quality aggergation")
 	addFlagToHelpGroup("butteraugli-qnorm", butteraugliSectionName)
 
+	pflag.BoolVar(&settings.butteraugliMaxCLL, "butteraugli-maxcll", false, "Use the reference's MaxCLL as Butteraugli's intensity target instead of --display-nits when the stream carries one. Not applied by validate or workers")
+	addFlagToHelpGroup("butteraugli-maxcll", butteraugliSectionName)
+
 	// CVVDP settings
 	var cvvdpSectionName string = "CVVDP Options"
 	pflag.BoolVar(&settings.cvvdpUseTemporalScore, "no-cvvdp-temporal", false, "Disable temporal motion for calculating frame scores")
 	addFlagToHelpGroup("no-cvvdp-temporal", cvvdpSectionName)
 
-	pflag.BoolVar(&settings.cvvdpReizeToDisplay, "no-resize-to-display", false, "Disable resizing videos to display models resolution")
+	pflag.BoolVar(&settings.cvvdpResizeToDisplay, "no-resize-to-display", false, "Disable resizing videos to display models resolution")
 	addFlagToHelpGroup("no-resize-to-display", cvvdpSectionName)
 
+	pflag.IntSliceVar(&settings.cvvdpAmbientSweep, "cvvdp-ambient-sweep", nil, "Score CVVDP once per ambient light level in lux e.g. 5,250,1000, keyed as CVVDP@250lux")
+	addFlagToHelpGroup("cvvdp-ambient-sweep", cvvdpSectionName)
+
+	pflag.Float32SliceVar(&settings.cvvdpDistanceSweep, "cvvdp-distance-sweep", nil, "Score CVVDP once per viewing distance in meters e.g. 0.5,1,2, keyed as CVVDP@1m")
+	addFlagToHelpGroup("cvvdp-distance-sweep", cvvdpSectionName)
+
 	// Display Model
 	var displayModelSectionName string = "Display Model Options"
+	displayPreset := pflag.String("display-preset", "", fmt.Sprintf("Start from a named display model %v, or one defined in --display-file. The other display flags override it when given", vship.DisplayModelPresetNames()))
+	addFlagToHelpGroup("display-preset", displayModelSectionName)
+
+	displayFile := pflag.String("display-file", "", "Load display models from a CVVDP display JSON file. --display-preset picks one by key if it defines more than one")
+	addFlagToHelpGroup("display-file", displayModelSectionName)
+
 	pflag.Float32Var(&settings.displayModel.DisplayMaxLuminance, "display-nits", 203, "The target displays brightness in nits (Used by CVVDP and Butteraugli)")
 	addFlagToHelpGroup("display-nits", displayModelSectionName)
 
@@ -101,7 +409,7 @@ func init() {
 	pflag.Parse()
 
 	settings.cvvdpUseTemporalScore = !settings.cvvdpUseTemporalScore
-	settings.cvvdpReizeToDisplay = !settings.cvvdpReizeToDisplay
+	settings.cvvdpResizeToDisplay = !settings.cvvdpResizeToDisplay
 
 	if *printHelp {
 		cliUsage()
@@ -109,11 +417,181 @@ func init() {
 	}
 
 	settings.metrics = strings.Split(*cliMetrics, ",")
+	settings.memoryBudget = *memoryBudgetMiB << 20
+	settings.indexCacheSize = *indexCacheSizeMiB << 20
 
-	if settings.frameThreads > 1 && settings.cvvdpUseTemporalScore {
-		var cvvdp bool = slices.Contains(settings.metrics, metrics.CVVDPName)
-		if cvvdp {
-			panic("cannot use more than 1 frame thread while using cvvdp with temporal weighting.")
+	var err error
+	if settings.seekMode, err = sources.ParseSeekMode(*seekMode); err != nil {
+		panic(err)
+	}
+	settings.indexErrors, err = sources.ParseIndexErrorMode(*indexErrors)
+	if err != nil {
+		panic(err)
+	}
+
+	err = parseInference(*assumeMatrix, *assumeTransfer, *assumePrimaries,
+		*assumeRange)
+	if err != nil {
+		panic(err)
+	}
+
+	if err = applyDisplayPreset(*displayPreset, *displayFile); err != nil {
+		panic(err)
+	}
+
+	if err = parseProxy(*proxy); err != nil {
+		panic(err)
+	}
+
+	if err = parseResolutions(*resolutions); err != nil {
+		panic(err)
+	}
+
+	if err = checkMetricWorkers(); err != nil {
+		panic(err)
+	}
+
+	if settings.deterministic {
+		settings.frameThreads = 1
+	}
+}
+
+// checkMetricWorkers validates --metric-workers against the selected metrics.
+func checkMetricWorkers() error {
+	for name, workers := range settings.metricWorkers {
+		if !slices.Contains(settings.metrics, name) {
+			return fmt.Errorf("--metric-workers: %s is not one of the "+
+				"selected metrics %v", name, settings.metrics)
+		}
+		if workers < 1 {
+			return fmt.Errorf("--metric-workers: %s needs at least 1 "+
+				"worker", name)
+		}
+	}
+
+	if settings.frameThreads < 0 {
+		return errors.New("--frame-threads must not be negative")
+	}
+
+	return nil
+}
+
+// displayFlags maps each display flag to the DisplayModel field it sets.
+var displayFlags = map[string]func(dst, src *vship.DisplayModel){
+	"display-nits": func(dst, src *vship.DisplayModel) {
+		dst.DisplayMaxLuminance = src.DisplayMaxLuminance
+	},
+	"display-width": func(dst, src *vship.DisplayModel) {
+		dst.DisplayWidth = src.DisplayWidth
+	},
+	"display-height": func(dst, src *vship.DisplayModel) {
+		dst.DisplayHeight = src.DisplayHeight
+	},
+	"display-size": func(dst, src *vship.DisplayModel) {
+		dst.DisplayDiagonalSizeInches = src.DisplayDiagonalSizeInches
+	},
+	"display-distance": func(dst, src *vship.DisplayModel) {
+		dst.ViewingDistanceMeters = src.ViewingDistanceMeters
+	},
+	"display-ratio": func(dst, src *vship.DisplayModel) {
+		dst.MonitorContrastRatio = src.MonitorContrastRatio
+	},
+	"room-brightness": func(dst, src *vship.DisplayModel) {
+		dst.AmbientLightLevel = src.AmbientLightLevel
+	},
+}
+
+// applyDisplayPreset replaces settings.displayModel with the model selected
+// by --display-preset and --display-file, keeping any display flag given on
+// the command line. Without either flag the display flags are used as is.
+func applyDisplayPreset(preset, file string) error {
+	if preset == "" && file == "" {
+		return nil
+	}
+
+	var model vship.DisplayModel
+	var err error
+
+	if file != "" {
+		model, err = displayModelFromFile(file, preset)
+	} else {
+		model, err = vship.DisplayModelPreset(preset)
+	}
+	if err != nil {
+		return err
+	}
+
+	for name, set := range displayFlags {
+		if pflag.CommandLine.Changed(name) {
+			set(&model, &settings.displayModel)
+		}
+	}
+
+	settings.displayModel = model
+	return nil
+}
+
+// displayModelFromFile returns the model with the given key from a CVVDP
+// display JSON file. key may be empty if the file defines a single model.
+func displayModelFromFile(file, key string) (vship.DisplayModel, error) {
+	models, err := vship.DisplayModelsFromCVVDPJSONFile(file)
+	if err != nil {
+		return vship.DisplayModel{}, fmt.Errorf("--display-file: %w", err)
+	}
+
+	if key == "" {
+		if len(models) != 1 {
+			return vship.DisplayModel{}, fmt.Errorf("--display-file %s "+
+				"defines %d models, pick one with --display-preset", file,
+				len(models))
+		}
+		for _, model := range models {
+			return model, nil
 		}
 	}
+
+	model, ok := models[key]
+	if !ok {
+		return vship.DisplayModel{}, fmt.Errorf("--display-file %s has no "+
+			"model %q", file, key)
+	}
+	return model, nil
+}
+
+// parseInference builds settings.inference from --strict-color and the
+// --assume-* flags. Empty flags keep the mode's default.
+func parseInference(matrix, transfer, primaries, colorRange string) error {
+	settings.inference = vcolor.PermissiveInference()
+	if settings.strictColor {
+		settings.inference = vcolor.StrictInference()
+	}
+
+	var err error
+
+	if matrix != "" {
+		settings.inference.Matrix, err = vcolor.ParseMatrix(matrix)
+		if err != nil {
+			return err
+		}
+	}
+	if transfer != "" {
+		settings.inference.Transfer, err = vcolor.ParseTransfer(transfer)
+		if err != nil {
+			return err
+		}
+	}
+	if primaries != "" {
+		settings.inference.Primaries, err = vcolor.ParsePrimaries(primaries)
+		if err != nil {
+			return err
+		}
+	}
+	if colorRange != "" {
+		settings.inference.Range, err = vcolor.ParseRange(colorRange)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
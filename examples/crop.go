@@ -0,0 +1,113 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// detectedCrops caches the --auto-crop rects of the reference and distortion
+// by their paths, so sources opened again, e.g. by --parallel-chunks, are
+// cropped without another detection pass and the results file can report
+// them.
+var detectedCrops = struct {
+	sync.Mutex
+	rects map[[2]string][2]video.Rect
+}{rects: make(map[[2]string][2]video.Rect)}
+
+// autoCrop crops the black bars of the sources for --auto-crop. Bars are
+// detected in both and only cropped where both have them, so the sources
+// keep covering the same picture.
+func autoCrop(referencePath, distortionPath string, reference,
+	distortion video.Source) (video.Source, video.Source, error) {
+	key := [2]string{referencePath, distortionPath}
+
+	detectedCrops.Lock()
+	rects, ok := detectedCrops.rects[key]
+	detectedCrops.Unlock()
+
+	if !ok {
+		var err error
+		if rects, err = detectCrops(reference, distortion); err != nil {
+			return nil, nil, err
+		}
+
+		detectedCrops.Lock()
+		detectedCrops.rects[key] = rects
+		detectedCrops.Unlock()
+
+		log.Printf("auto crop: reference %s, distortion %s",
+			formatRect(rects[0]), formatRect(rects[1]))
+	}
+
+	reference, err := sources.Crop(reference, rects[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("reference: %w", err)
+	}
+	distortion, err = sources.Crop(distortion, rects[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("distortion: %w", err)
+	}
+	return reference, distortion, nil
+}
+
+// detectCrops returns the aligned crop of the reference and the distortion.
+func detectCrops(reference, distortion video.Source) ([2]video.Rect, error) {
+	var rects [2]video.Rect
+
+	referenceRect, err := analysis.DetectCrop(reference, analysis.CropOptions{})
+	if err != nil {
+		return rects, fmt.Errorf("reference: %w", err)
+	}
+	distortionRect, err := analysis.DetectCrop(distortion,
+		analysis.CropOptions{})
+	if err != nil {
+		return rects, fmt.Errorf("distortion: %w", err)
+	}
+
+	a, b := reference.GetColorProps(), distortion.GetColorProps()
+	distortionRect = distortionRect.Scale(b.Width, b.Height, a.Width,
+		a.Height)
+	union := video.Rect{
+		X: min(referenceRect.X, distortionRect.X),
+		Y: min(referenceRect.Y, distortionRect.Y),
+	}
+	union.Width = max(referenceRect.X+referenceRect.Width,
+		distortionRect.X+distortionRect.Width) - union.X
+	union.Height = max(referenceRect.Y+referenceRect.Height,
+		distortionRect.Y+distortionRect.Height) - union.Y
+
+	if rects[0], err = sources.AlignCrop(union, a); err != nil {
+		return rects, fmt.Errorf("reference: %w", err)
+	}
+	rects[1], err = sources.AlignCrop(rects[0].Scale(a.Width, a.Height,
+		b.Width, b.Height), b)
+	if err != nil {
+		return rects, fmt.Errorf("distortion: %w", err)
+	}
+	return rects, nil
+}
+
+// croppedRects returns the --auto-crop rects of the sources at the given
+// paths, or nil if they were not cropped.
+func croppedRects(referencePath, distortionPath string) [2]*video.Rect {
+	detectedCrops.Lock()
+	defer detectedCrops.Unlock()
+
+	rects, ok := detectedCrops.rects[[2]string{referencePath, distortionPath}]
+	if !ok {
+		return [2]*video.Rect{}
+	}
+	return [2]*video.Rect{&rects[0], &rects[1]}
+}
+
+// formatRect formats r like ffmpeg's crop filter arguments.
+func formatRect(r video.Rect) string {
+	return fmt.Sprintf("%d:%d:%d:%d", r.Width, r.Height, r.X, r.Y)
+}
@@ -0,0 +1,75 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+)
+
+// pluginCapabilities holds the capabilities plugins reported, by metric name.
+// Their scores are keyed by that name or start with it.
+var pluginCapabilities = struct {
+	sync.Mutex
+	byName map[string]video.Capabilities
+}{byName: make(map[string]video.Capabilities)}
+
+// recordPluginCapabilities remembers the score direction and format of a
+// plugin metric.
+func recordPluginCapabilities(metric video.Metric) {
+	pluginCapabilities.Lock()
+	defer pluginCapabilities.Unlock()
+
+	pluginCapabilities.byName[metric.Name()] =
+		video.MetricCapabilities(metric)
+}
+
+// pluginCapabilitiesFor returns the capabilities of the plugin whose scores
+// key is one of, or false if no plugin reported it.
+func pluginCapabilitiesFor(key string) (video.Capabilities, bool) {
+	pluginCapabilities.Lock()
+	defer pluginCapabilities.Unlock()
+
+	// Prefer the longest name, so a plugin named after the prefix of
+	// another does not claim its keys.
+	var capabilities video.Capabilities
+	longest := -1
+	for name, c := range pluginCapabilities.byName {
+		if strings.HasPrefix(key, name) && len(name) > longest {
+			capabilities, longest = c, len(name)
+		}
+	}
+	return capabilities, longest >= 0
+}
+
+// scoreDirection returns the direction of the scores under key, or
+// video.DirectionUnknown for plugins that do not report one.
+func scoreDirection(key string) video.ScoreDirection {
+	if direction := metrics.ScoreDirection(key); direction !=
+		video.DirectionUnknown {
+		return direction
+	}
+
+	capabilities, _ := pluginCapabilitiesFor(key)
+	return capabilities.Direction
+}
+
+// scoreFormat returns the format of the scores under key, the zero format for
+// plugins that do not report one.
+func scoreFormat(key string) video.ScoreFormat {
+	if format := metrics.ScoreFormat(key); format != (video.ScoreFormat{}) {
+		return format
+	}
+
+	capabilities, _ := pluginCapabilitiesFor(key)
+	return capabilities.Format
+}
+
+// higherIsWorse returns true for scores where higher is worse, such as
+// Butteraugli distances. Scores of unknown direction count as qualities.
+func higherIsWorse(key string) bool {
+	return scoreDirection(key) == video.LowerIsBetter
+}
@@ -0,0 +1,181 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/distributed"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"github.com/schollz/progressbar/v3"
+)
+
+// runDistributed splits the comparison into scene chunks and sends them to
+// the --workers. reference is only used to plan the chunks.
+func runDistributed(ctx context.Context, reference video.Source) (map[string][]float64, error) {
+	if settings.keyFrameMode != "off" {
+		return nil, errors.New("--keyframe-mode cannot be combined with " +
+			"--workers")
+	}
+	if settings.butteraugliDistMapPath != "" || settings.cvvdpDistMapPath != "" {
+		return nil, errors.New("heat map output cannot be combined with " +
+			"--workers")
+	}
+	if frameAnalysisEnabled() {
+		return nil, errors.New("--detect-cadence, --black-freeze, " +
+			"--av-sync, --complexity and --frame-metadata cannot be " +
+			"combined with --workers")
+	}
+	if settings.patchOptions.Dir != "" {
+		return nil, errors.New("--export-patches cannot be combined with " +
+			"--workers")
+	}
+	if settings.streamScores || len(settings.abortIf) > 0 {
+		return nil, errors.New("--stream-scores and --abort-if cannot be " +
+			"combined with --workers")
+	}
+	if settings.subtitleMask != "" {
+		return nil, errors.New("--subtitle-mask cannot be combined with " +
+			"--workers")
+	}
+
+	referencePath, err := filepath.Abs(settings.referenceVideo)
+	if err != nil {
+		return nil, err
+	}
+	distortionPath, err := filepath.Abs(settings.distortionVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	numFrames := reference.GetNumFrames()
+
+	keyFrames, err := sceneCuts(reference)
+	if err != nil && !errors.Is(err, errNoSceneCuts) {
+		return nil, err
+	}
+	chunks := comparator.SceneChunks(keyFrames, numFrames, settings.chunkFrames)
+
+	bar := progressbar.NewOptions(
+		numFrames,
+		progressbar.OptionSetDescription("Computing metrics"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+	)
+	progress := func(done, total int) {
+		_ = bar.Set(done)
+		scoredFrames.Store(int64(done))
+	}
+
+	coordinator := distributed.Coordinator{
+		Workers:        settings.workers,
+		SlotsPerWorker: settings.workerSlots,
+		Progress:       progress,
+		Deterministic:  settings.deterministic,
+	}
+
+	job := distributed.Job{
+		Reference:     referencePath,
+		Distortion:    distortionPath,
+		Metrics:       settings.metrics,
+		FrameRate:     settings.frameRate,
+		Deterministic: settings.deterministic,
+	}
+
+	return coordinator.Run(ctx, job, chunks, numFrames)
+}
+
+// runWorker serves chunks to coordinators on --worker-listen until the
+// server fails.
+func runWorker() error {
+	if settings.butteraugliDistMapPath != "" || settings.cvvdpDistMapPath != "" {
+		return errors.New("heat map output cannot be combined with " +
+			"--worker-listen")
+	}
+
+	worker := distributed.NewWorker(runJob, settings.workerSlots)
+	log.Printf("worker listening on %s with %d slots", settings.workerListen,
+		settings.workerSlots)
+	return http.ListenAndServe(settings.workerListen, worker)
+}
+
+// runJob compares one chunk sent by a coordinator.
+func runJob(ctx context.Context, job distributed.Job) (map[string][]float64,
+	error) {
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, job.Reference, job.Distortion)
+	if err != nil {
+		return nil, err
+	}
+	// The slices below share these sources, so they are closed here rather
+	// than by the comparator.
+	defer reference.Close()
+	defer distortion.Close()
+
+	if reference, err = sources.Slice(reference, job.Range.Start,
+		job.Range.Count); err != nil {
+		return nil, err
+	}
+	if distortion, err = sources.Slice(distortion, job.Range.Start,
+		job.Range.Count); err != nil {
+		return nil, err
+	}
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	var metricHandlers []video.Metric
+	closeMetrics := func() {
+		for _, metric := range metricHandlers {
+			metric.Close()
+		}
+	}
+	for _, metric := range job.Metrics {
+		metricHandler, _, err := createMetricAndWriter(metric,
+			reference.GetColorProps(), distortion.GetColorProps(),
+			&referenceColorSpace, &distortionColorSpace, job.FrameRate)
+		if err != nil {
+			closeMetrics()
+			return nil, err
+		}
+		metricHandlers = append(metricHandlers, metricHandler)
+	}
+
+	frameThreads := settings.frameThreads
+	if job.Deterministic {
+		frameThreads = 1
+	}
+
+	comp, err := comparator.NewComparator(reference, distortion,
+		metricHandlers, frameThreads, job.Range.Count,
+		comparator.WithMemoryBudget(settings.memoryBudget))
+	if err != nil {
+		closeMetrics()
+		return nil, err
+	}
+	defer comp.Close()
+
+	if err = comp.SetIdentityShortCircuit(settings.skipIdentical); err != nil {
+		return nil, err
+	}
+	if err = comp.SetQuickReject(settings.quickRejectPSNR); err != nil {
+		return nil, err
+	}
+	if err = comp.SetFrameChecks(settings.checkFrames); err != nil {
+		return nil, err
+	}
+	if err = setScoreCache(&comp); err != nil {
+		return nil, err
+	}
+
+	return comp.Run(ctx)
+}
@@ -0,0 +1,58 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/dataset"
+)
+
+// patchOptions returns the options of --export-patches, so that bad flags are
+// reported before the comparison runs.
+func patchOptions() (dataset.Options, error) {
+	opts := settings.patchOptions
+
+	var err error
+	if opts.Selection, err = dataset.ParseSelection(
+		settings.patchSelection); err != nil {
+		return dataset.Options{}, err
+	}
+	if opts.Selection == dataset.SelectWorst && opts.Metric == "" {
+		return dataset.Options{}, errors.New("--patch-selection worst " +
+			"needs --patch-metric")
+	}
+
+	opts.HigherIsWorse = higherIsWorse(opts.Metric)
+	return opts, nil
+}
+
+// exportPatches writes the crops requested by --export-patches, if any.
+// frames holds the source frame of every score.
+func exportPatches(ctx context.Context, reference, distortion video.Source,
+	scores map[string][]float64, frames []int, opts dataset.Options) error {
+	if opts.Dir == "" {
+		return nil
+	}
+
+	seekableReference, ok := video.As[video.SeekableSource](reference)
+	if !ok {
+		return errors.New("reference source cannot seek to export patches")
+	}
+	seekableDistortion, ok := video.As[video.SeekableSource](distortion)
+	if !ok {
+		return errors.New("distortion source cannot seek to export patches")
+	}
+
+	samples, err := dataset.Export(ctx, seekableReference, seekableDistortion,
+		scores, frames, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("exported %d patches to %s", samples, opts.Dir)
+	return nil
+}
@@ -0,0 +1,225 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+)
+
+// runExtend scores the sources of the --output results file with the
+// selected metrics it lacks and adds their scores to it, so metrics can be
+// added to a comparison one at a time without scoring the others again. The
+// sources default to the paths the file records and must still hash the
+// same. Flags that change which frames are paired, e.g. --frame-map, must
+// match those of the run that wrote the file.
+//
+// The summaries and the --worst-gops, --worst-segments and --chapters
+// summaries of the new metrics are added, the summaries by picture type of
+// --frame-metadata are not.
+func runExtend(ctx context.Context) error {
+	if settings.outputPath == "" {
+		return errors.New("extend needs an --output results file")
+	}
+	if frameAnalysisEnabled() {
+		return errors.New("--detect-cadence, --black-freeze, --av-sync, " +
+			"--complexity and --frame-metadata cannot be combined with " +
+			"extend")
+	}
+	if settings.twoPassStride > 0 || len(settings.abortIf) > 0 {
+		return errors.New("--two-pass and --abort-if cannot be combined " +
+			"with extend")
+	}
+
+	file, err := results.DecodeFile(settings.outputPath)
+	if err != nil {
+		return err
+	}
+	run, err := file.Run()
+	if err != nil {
+		return fmt.Errorf("%s: %w", settings.outputPath, err)
+	}
+
+	var recorded []string
+	if names := run.Options["metrics"]; names != "" {
+		recorded = strings.Split(names, ",")
+	}
+	var missing []string
+	for _, metric := range settings.metrics {
+		if !slices.Contains(recorded, metric) {
+			missing = append(missing, metric)
+		}
+	}
+	if len(missing) == 0 {
+		log.Printf("%s already holds the scores of %s", settings.outputPath,
+			strings.Join(settings.metrics, ", "))
+		return nil
+	}
+	settings.metrics = missing
+
+	if run.Reference == nil {
+		return errors.New("extend cannot add metrics to the results of " +
+			"--no-reference")
+	}
+	if settings.referenceVideo == "" {
+		settings.referenceVideo = run.Reference.Path
+	}
+	if settings.distortionVideo == "" {
+		settings.distortionVideo = run.Distortion.Path
+	}
+	if err = checkRecordedSource(settings.referenceVideo,
+		*run.Reference); err != nil {
+		return fmt.Errorf("reference: %w", err)
+	}
+	if err = checkRecordedSource(settings.distortionVideo,
+		run.Distortion); err != nil {
+		return fmt.Errorf("distortion: %w", err)
+	}
+
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, settings.referenceVideo, settings.distortionVideo)
+	if err != nil {
+		return err
+	}
+	defer reference.Close()
+	defer distortion.Close()
+
+	log.Printf("scoring %s missing from %s", strings.Join(missing, ", "),
+		settings.outputPath)
+	scores, report, err := scoreSources(ctx, reference, distortion,
+		referencePlan, distortionPlan)
+	if err != nil {
+		return err
+	}
+
+	frames, _ := mapFrames(report.frames)
+	if err = checkExtension(run, scores, frames); err != nil {
+		return err
+	}
+
+	gops, err := gopScores(distortion, scores)
+	if err != nil {
+		return err
+	}
+	segments, err := worstSegments(distortion, scores, frames, run.Excluded)
+	if err != nil {
+		return err
+	}
+	chapters, err := chapterScores(ctx, reference, distortion, scores, frames)
+	if err != nil {
+		return err
+	}
+
+	printSummary(withoutFrames(scores, run.Excluded), segments)
+
+	vshipVersion := getLibraryVersions().Vship
+	file.MetricVersions = initMap(file.MetricVersions)
+	for name, frameScores := range scores {
+		file.Scores[name] = frameScores
+		file.MetricVersions[name] = metricVersion(name, vshipVersion)
+	}
+	file.GOPs = mergeMetricMaps(file.GOPs, gops)
+	file.WorstSegments = mergeMetricMaps(file.WorstSegments, segments)
+	file.Chapters = mergeMetricMaps(file.Chapters, chapters)
+	file.Options = initMap(file.Options)
+	file.Options["metrics"] = strings.Join(append(recorded, missing...), ",")
+
+	if run, err = file.Run(); err != nil {
+		return err
+	}
+	file.Summaries, file.Scenes, err = summarizeRun(reference, run)
+	if err != nil {
+		return err
+	}
+
+	return replaceResults(settings.outputPath, file)
+}
+
+// checkRecordedSource fails if the file at path is not the source a results
+// file recorded.
+func checkRecordedSource(path string, recorded results.Source) error {
+	size, hash, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	if size != recorded.Size || hash != recorded.SHA256 {
+		return fmt.Errorf("%s is not the file the results were computed on, "+
+			"%s with SHA-256 %s", path, recorded.Path, recorded.SHA256)
+	}
+	return nil
+}
+
+// checkExtension fails if scores cannot be added to run, as they name keys
+// it already holds or score other frames.
+func checkExtension(run *results.Run, scores map[string][]float64,
+	frames []int) error {
+	keys := run.Keys()
+	for _, name := range slices.Sorted(maps.Keys(scores)) {
+		if slices.Contains(keys, name) {
+			return fmt.Errorf("the results already hold scores for %s",
+				name)
+		}
+		if len(scores[name]) != len(run.Frames) {
+			return fmt.Errorf("%s scored %d frames where the results hold "+
+				"%d, pass the flags of the run that wrote them", name,
+				len(scores[name]), len(run.Frames))
+		}
+	}
+
+	scored := results.NewRun(scores, frames).FrameNumbers()
+	if !slices.Equal(scored, run.FrameNumbers()) {
+		return errors.New("the frames scored differ from those of the " +
+			"results, pass the flags of the run that wrote them")
+	}
+	return nil
+}
+
+// initMap returns m, or an empty map if m is nil.
+func initMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return make(map[string]V)
+	}
+	return m
+}
+
+// mergeMetricMaps adds the entries of added to recorded.
+func mergeMetricMaps[V any](recorded, added map[string]V) map[string]V {
+	if len(added) == 0 {
+		return recorded
+	}
+	recorded = initMap(recorded)
+	maps.Copy(recorded, added)
+	return recorded
+}
+
+// replaceResults writes out to path through a temporary file, so the
+// results are not lost if writing fails.
+func replaceResults(path string, out *results.File) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".gometrics-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err = out.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Chmod(0o644); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,112 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// loadedFrameMap holds the --frame-map file, read once so sources opened
+// again, e.g. by --parallel-chunks, are remapped without reading it again, or
+// the map of the --start-offset alignment, and the frame counts of the
+// reference and distortion it maps.
+var loadedFrameMap = struct {
+	sync.Mutex
+	frameMap  *analysis.FrameMap
+	numFrames [2]int
+}{}
+
+// applyFrameMap remaps the sources for --frame-map, so that frame k of both
+// shows the k-th mapped pair. On error the sources are returned as passed
+// in, for the caller to close.
+func applyFrameMap(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	if settings.frameMap == "" {
+		return reference, distortion, nil
+	}
+	if settings.frameRateMatch != "none" {
+		return reference, distortion, errors.New("--frame-map cannot be " +
+			"combined with --frame-rate-match")
+	}
+	if len(settings.workers) > 0 {
+		return reference, distortion, errors.New("--frame-map cannot be " +
+			"combined with --workers")
+	}
+
+	numFrames := [2]int{reference.GetNumFrames(), distortion.GetNumFrames()}
+	m, err := frameMap(numFrames)
+	if err != nil {
+		return reference, distortion, err
+	}
+
+	// The remapped sources own nothing but a frame buffer, so dropping them
+	// on error and closing the sources passed in releases everything.
+	remappedReference, err := sources.Remap(reference, m.Reference)
+	if err != nil {
+		return reference, distortion, fmt.Errorf("reference: %w", err)
+	}
+	remappedDistortion, err := sources.Remap(distortion, m.Distortion)
+	if err != nil {
+		return reference, distortion, fmt.Errorf("distortion: %w", err)
+	}
+	return remappedReference, remappedDistortion, nil
+}
+
+// frameMap returns the --frame-map file, reading it and checking it against
+// the frame counts of the reference and distortion on first use.
+func frameMap(numFrames [2]int) (*analysis.FrameMap, error) {
+	loadedFrameMap.Lock()
+	defer loadedFrameMap.Unlock()
+
+	if loadedFrameMap.frameMap == nil {
+		m, err := analysis.ReadFrameMapFile(settings.frameMap)
+		if err != nil {
+			return nil, fmt.Errorf("--frame-map: %w", err)
+		}
+		if err = m.Check(numFrames[0], numFrames[1]); err != nil {
+			return nil, fmt.Errorf("--frame-map: %w", err)
+		}
+		loadedFrameMap.frameMap = &m
+		loadedFrameMap.numFrames = numFrames
+	}
+	return loadedFrameMap.frameMap, nil
+}
+
+// mapFrames translates the frame of every score, frames or every frame if
+// nil, to the reference frame --frame-map or the --start-offset alignment
+// paired it with, and reports the frames the map left out. It returns the
+// frames unchanged and nil if the sources were not remapped.
+func mapFrames(frames []int) ([]int, *results.UnmappedFrames) {
+	loadedFrameMap.Lock()
+	m, numFrames := loadedFrameMap.frameMap, loadedFrameMap.numFrames
+	loadedFrameMap.Unlock()
+
+	if m == nil {
+		return frames, nil
+	}
+
+	mapped := m.Reference
+	if frames != nil {
+		mapped = make([]int, len(frames))
+		for i, frame := range frames {
+			mapped[i] = m.Reference[frame]
+		}
+	}
+
+	var unmapped results.UnmappedFrames
+	unmapped.Reference, unmapped.Distortion = m.Unmapped(numFrames[0],
+		numFrames[1])
+	log.Printf("frame map: compared %d frame pairs, skipped %d of %d "+
+		"reference and %d of %d distorted frames", len(m.Reference),
+		len(unmapped.Reference), numFrames[0], len(unmapped.Distortion),
+		numFrames[1])
+	return mapped, &unmapped
+}
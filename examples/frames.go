@@ -0,0 +1,50 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/snapshot"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// runFrames writes the --extract-frames and --extract-times frames of
+// --reference and --distortion side by side as PNG images to --extract-dir
+// and prints their paths. Frames are paired by number as decoded, before any
+// frame mapping, cropping or color preparation.
+func runFrames(ctx context.Context) error {
+	if len(settings.extractFrames) == 0 && len(settings.extractTimes) == 0 {
+		return errors.New("frames needs --extract-frames or --extract-times")
+	}
+
+	decodeOptions := sources.FFms2Options{
+		DecodeThreads: settings.decodeThreads, SeekMode: settings.seekMode,
+		IndexErrors: settings.indexErrors}
+	if settings.indexCache != "" {
+		cache, err := sources.OpenIndexCache(settings.indexCache,
+			settings.indexCacheSize)
+		if err != nil {
+			return err
+		}
+		decodeOptions.IndexCache = cache
+	}
+
+	reference, distortion, err := sources.OpenPair(ctx,
+		settings.referenceVideo, settings.distortionVideo, decodeOptions)
+	if err != nil {
+		return err
+	}
+	defer reference.Close()
+	defer distortion.Close()
+
+	paths, err := snapshot.Extract(reference, distortion, snapshot.Options{
+		Dir: settings.extractDir, Frames: settings.extractFrames,
+		Times: settings.extractTimes})
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+	return err
+}
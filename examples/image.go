@@ -0,0 +1,141 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"github.com/spf13/pflag"
+)
+
+// imageResults is the JSON the image subcommand writes to --output.
+type imageResults struct {
+	Reference  string             `json:"reference"`
+	Distortion string             `json:"distortion"`
+	Width      int                `json:"width"`
+	Height     int                `json:"height"`
+	Libraries  results.Libraries  `json:"libraries"`
+	Scores     map[string]float64 `json:"scores"`
+}
+
+// runImage compares two PNG or JPEG still images given as arguments with the
+// selected metrics, as a single frame pair with no temporal pooling. The
+// scores are printed to stdout and written to --output if set, and the heat
+// maps are written to the .png paths of the heat map flags.
+func runImage(ctx context.Context) error {
+	if pflag.NArg() != 3 {
+		return errors.New("image needs a reference and a distorted image")
+	}
+	referencePath, distortionPath := pflag.Arg(1), pflag.Arg(2)
+
+	reference, err := sources.OpenImage(referencePath)
+	if err != nil {
+		return err
+	}
+	distortion, err := sources.OpenImage(distortionPath)
+	if err != nil {
+		reference.Close()
+		return err
+	}
+	defer reference.Close()
+	defer distortion.Close()
+
+	reference, referencePlan, err := vcolor.Prepare(reference,
+		vcolor.VshipBackend, settings.inference)
+	if err != nil {
+		return colorPlanError("reference", err)
+	}
+	distortion, distortionPlan, err := vcolor.Prepare(distortion,
+		vcolor.VshipBackend, settings.inference)
+	if err != nil {
+		return colorPlanError("distortion", err)
+	}
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return err
+	}
+
+	// A single frame has no temporal component to weigh.
+	settings.cvvdpUseTemporalScore = false
+	settings.stillImage = true
+
+	var metricHandlers []video.Metric
+	var heatmapWriters []*metrics.HeatmapWriter
+	closeAll := func() {
+		for _, metric := range metricHandlers {
+			metric.Close()
+		}
+	}
+	for _, metric := range settings.metrics {
+		metricHandler, heatmapWriter, err := createMetricAndWriter(metric,
+			reference.GetColorProps(), distortion.GetColorProps(),
+			&referenceColorSpace, &distortionColorSpace,
+			reference.GetFrameRate())
+		if err != nil {
+			closeAll()
+			return err
+		}
+		metricHandlers = append(metricHandlers, metricHandler)
+		if heatmapWriter != nil {
+			heatmapWriters = append(heatmapWriters, heatmapWriter)
+		}
+	}
+
+	comp, err := comparator.NewComparator(reference, distortion,
+		metricHandlers, 1, 1, comparator.WithSharedSources())
+	if err != nil {
+		closeAll()
+		return err
+	}
+	defer comp.Close()
+
+	frameScores, err := comp.Run(ctx)
+	if err != nil {
+		return err
+	}
+	for _, writer := range heatmapWriters {
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("heat map: %w", err)
+		}
+	}
+
+	if settings.normalize {
+		metrics.AddNormalizedScores(frameScores)
+	}
+
+	scores := make(map[string]float64, len(frameScores))
+	for _, name := range slices.Sorted(maps.Keys(frameScores)) {
+		if len(frameScores[name]) == 0 {
+			continue
+		}
+		scores[name] = frameScores[name][0]
+		fmt.Printf("%s: %g\n", name, scores[name])
+	}
+
+	if settings.outputPath == "" {
+		return nil
+	}
+
+	props := reference.GetColorProps()
+	data, err := json.MarshalIndent(imageResults{Reference: referencePath,
+		Distortion: distortionPath, Width: props.Width, Height: props.Height,
+		Libraries: getLibraryVersions(), Scores: scores}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settings.outputPath, data, 0o644)
+}
@@ -1,68 +1,248 @@
+//go:build cgo && !nocgo
+
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
 	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
 	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
 	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
 	"github.com/GreatValueCreamSoda/gometrics/video/sources"
 	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/pflag"
 )
 
 func main() {
-	reference, err := sources.NewFFms2Reader(settings.referenceVideo)
-	if err != nil {
-		panic(err)
+	defer closeScoreCache()
+
+	if settings.workerListen != "" {
+		if err := runWorker(); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if settings.vsListen != "" {
+		if err := runVSBridge(); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	// The first Ctrl-C cancels the comparison, which stops within a frame or
+	// so. Cancelling restores the default handling, so a second one exits.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if pflag.Arg(0) == "validate" {
+		if err := runValidate(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if pflag.Arg(0) == "chunk" {
+		if err := runChunk(ctx); err != nil {
+			panic(err)
+		}
+		return
 	}
 
-	distortion, err := sources.NewFFms2Reader(settings.distortionVideo)
+	if pflag.Arg(0) == "image" {
+		if err := runImage(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if pflag.Arg(0) == "frames" {
+		if err := runFrames(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if pflag.Arg(0) == "extend" {
+		if err := runExtend(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if settings.noReference {
+		if err := runNoReference(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if len(settings.references) > 0 {
+		if err := runMultiReference(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if settings.referenceDir != "" {
+		if err := pickReference(ctx); err != nil {
+			panic(err)
+		}
+	}
+
+	if settings.soak > 0 {
+		if err := runSoak(ctx); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	monitor := startResourceMonitor()
+
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, settings.referenceVideo, settings.distortionVideo)
 	if err != nil {
 		panic(err)
 	}
 
-	var referenceColorSpace, distortionColorSpace vship.Colorspace
-	referenceColorSpace.SetDefaults(0, 0, 0)
-	distortionColorSpace.SetDefaults(0, 0, 0)
+	if err = exportSceneList(reference); err != nil {
+		panic(err)
+	}
 
-	if settings.compareHeight > 0 && settings.compareWidth > 0 {
-		referenceColorSpace.TargetHeight = settings.compareHeight
-		referenceColorSpace.TargetWidth = settings.compareWidth
-		distortionColorSpace.TargetHeight = settings.compareHeight
-		distortionColorSpace.TargetWidth = settings.compareWidth
-	} else {
-		referenceColorSpace.TargetHeight = settings.compareHeight
-		referenceColorSpace.TargetWidth = settings.compareWidth
-		distortionColorSpace.TargetHeight = settings.compareHeight
-		distortionColorSpace.TargetWidth = settings.compareWidth
+	mismatches, err := checkColorMismatch(referencePlan, distortionPlan)
+	if err != nil {
+		panic(err)
+	}
 
+	pacedReference, pacedDistortion, err := paceSources(reference,
+		distortion)
+	if err != nil {
+		panic(err)
 	}
 
-	err = reference.GetColorProps().ToVsHipColorspace(&referenceColorSpace)
+	scores, report, err := scoreSources(ctx, pacedReference,
+		pacedDistortion, referencePlan, distortionPlan)
 	if err != nil {
 		panic(err)
 	}
 
-	err = distortion.GetColorProps().ToVsHipColorspace(&distortionColorSpace)
+	excluded := excludedFrames(report.events, scores)
+	report.frames, report.unmapped = mapFrames(report.frames)
+
+	if report.gops, err = gopScores(distortion, scores); err != nil {
+		panic(err)
+	}
+	report.segments, err = worstSegments(distortion, scores, report.frames,
+		excluded)
 	if err != nil {
 		panic(err)
 	}
+	report.chapters, err = chapterScores(ctx, reference, distortion, scores,
+		report.frames)
+	if err != nil {
+		panic(err)
+	}
+
+	if report.audio, err = audioLoudness(ctx); err != nil {
+		panic(err)
+	}
+
+	printSummary(withoutFrames(scores, excluded), report.segments)
+	printColorMismatches(mismatches)
+	printEvents(report.events)
+	printSync(report.sync)
+	printComplexity(report.complexity, scores)
+	printPictTypeStats(pictTypeStats(scores, report.metadata[1]))
+	logSeekFallbacks(report.metadata)
+	printWorstGOPs(report.gops)
+	printChapters(report.chapters)
+	printAudio(report.audio)
+	printRegions(report.regions, report.regionMetric)
+
+	if settings.outputPath != "" {
+		err = writeResults(settings.outputPath, scores, report, excluded,
+			sourceInputs(reference, distortion, referencePlan,
+				distortionPlan), monitor.usage())
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// scoreSources scores the sources with every selected metric, sampled by
+// --two-pass or split across --workers or --parallel-chunks if set.
+func scoreSources(ctx context.Context, reference, distortion video.Source,
+	referencePlan, distortionPlan *vcolor.Plan) (map[string][]float64,
+	frameReport, error) {
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return nil, frameReport{}, err
+	}
 
 	if settings.frameRate < 0 {
 		settings.frameRate = reference.GetFrameRate()
 	}
 
+	if hdr := reference.GetColorProps().HDR; settings.butteraugliMaxCLL &&
+		hdr.HasContentLightLevel && hdr.MaxCLL > 0 {
+		settings.butteraugliIntensity = float32(hdr.MaxCLL)
+		log.Printf("butteraugli intensity target set to the reference "+
+			"MaxCLL of %d nits", hdr.MaxCLL)
+	}
+
+	var scores map[string][]float64
+	var report frameReport
+
+	switch {
+	case settings.twoPassStride > 0:
+		scores, report, err = runTwoPass(ctx, reference, distortion,
+			&referenceColorSpace, &distortionColorSpace)
+	case len(settings.workers) > 0:
+		scores, err = runDistributed(ctx, reference)
+	case settings.parallelChunks > 1:
+		scores, err = runChunked(ctx, reference, distortion,
+			&referenceColorSpace, &distortionColorSpace)
+	default:
+		scores, report, err = runComparison(ctx, reference, distortion,
+			&referenceColorSpace, &distortionColorSpace)
+	}
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	if settings.normalize {
+		metrics.AddNormalizedScores(scores)
+	}
+	return scores, report, nil
+}
+
+// runComparison compares the sources with a single Comparator, writing any
+// requested heat maps and running the requested frame analysis.
+func runComparison(ctx context.Context, reference, distortion video.Source,
+	referenceColorSpace, distortionColorSpace *vship.Colorspace) (map[string][]float64,
+	frameReport, error) {
 	var metricHandlers []video.Metric
 	var heatmapWriters []*metrics.HeatmapWriter
 
 	for _, metric := range settings.metrics {
-		metricHandler, heatmapWriter, err := createMetricAndWriter(
-			metric, &referenceColorSpace, &distortionColorSpace)
+		metricHandler, heatmapWriter, err := createMetricAndWriter(metric,
+			reference.GetColorProps(), distortion.GetColorProps(),
+			referenceColorSpace, distortionColorSpace, settings.frameRate)
 		if err != nil {
-			panic(err)
+			return nil, frameReport{}, err
 		}
 		metricHandlers = append(metricHandlers, metricHandler)
 		if heatmapWriter != nil {
@@ -72,62 +252,489 @@ func main() {
 
 	comp, err := comparator.NewComparator(
 		reference, distortion, metricHandlers, settings.frameThreads,
-		reference.GetNumFrames())
+		reference.GetNumFrames(),
+		comparator.WithMemoryBudget(settings.memoryBudget),
+		comparator.WithSharedSources())
 	if err != nil {
-		panic(err)
+		return nil, frameReport{}, err
 	}
+	defer comp.Close()
 
-	bar := progressbar.NewOptions(
-		reference.GetNumFrames(),
+	keyFrameMode, err := parseKeyFrameMode(settings.keyFrameMode)
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	if err = comp.SetKeyFrameMode(keyFrameMode); err != nil {
+		return nil, frameReport{}, err
+	}
+
+	exportOptions, err := patchOptions()
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	if err = comp.SetIdentityShortCircuit(settings.skipIdentical); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = comp.SetQuickReject(settings.quickRejectPSNR); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = comp.SetFrameChecks(settings.checkFrames); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = setScoreCache(&comp); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = setSubtitleMask(&comp, reference, distortion); err != nil {
+		return nil, frameReport{}, err
+	}
+
+	conditions, err := abortConditions()
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+	comp.SetAbortConditions(conditions...)
+
+	recorders, err := newFrameRecorders(&comp, reference, distortion,
+		keyFrameMode)
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	barOptions := []progressbar.Option{
 		progressbar.OptionSetDescription("Computing metrics"),
 		progressbar.OptionShowCount(),
 		progressbar.OptionShowIts(),
-	)
+	}
+	var scoresCallback comparator.ScoresCallback
+	if settings.streamScores {
+		// Keep stdout for the NDJSON stream.
+		barOptions = append(barOptions, progressbar.OptionSetWriter(os.Stderr))
+		scoresCallback = scoreStreamer(os.Stdout, comp.FrameIndices())
+	}
+	comp.SetScoresCallback(recordLatencies(scoresCallback))
+	bar := progressbar.NewOptions(len(comp.FrameIndices()), barOptions...)
 
-	comp.SetProgressCallback(func(done, total int) {
+	var described time.Time
+	comp.SetStatsCallback(func(done, total int,
+		stats []comparator.MetricStats) {
 		_ = bar.Add(1)
+		scoredFrames.Add(1)
+		if time.Since(described) >= time.Second {
+			bar.Describe(describeStats(stats))
+			described = time.Now()
+		}
 	})
 
-	var scores map[string][]float64
-
-	if scores, err = comp.Run(context.Background()); err != nil {
-		panic(err)
+	started := time.Now()
+	scores, err := comp.Run(ctx)
+	var abort *comparator.AbortError
+	var panicked *comparator.MetricPanicError
+	switch {
+	case errors.As(err, &panicked):
+		log.Printf("%s panicked:\n%s", panicked.Metric, panicked.Stack)
+		return nil, frameReport{}, err
+	case err != nil && !errors.As(err, &abort):
+		return nil, frameReport{}, err
 	}
 
+	printMetricStats(comp.MetricStats())
+	printPoolStats(comp.PoolStats(), time.Since(started))
+	printRealtime()
+
 	for _, writer := range heatmapWriters {
 		if err := writer.Close(); err != nil {
 			log.Fatal("Failed to finalize video:", err)
 		}
 	}
 
-	printSummary(scores)
+	if cached := comp.CachedScores(); cached > 0 {
+		log.Printf("%d metric results were taken from --score-cache", cached)
+	}
+
+	if settings.skipIdentical {
+		log.Printf("%d of %d frame pairs were bit-identical",
+			comp.IdenticalFrames(), len(comp.FrameIndices()))
+	}
+
+	imputed := markedFrames(comp.QuickRejected(), comp.FrameIndices())
+	if settings.quickRejectPSNR > 0 {
+		log.Printf("%d of %d frame pairs reached %g dB PSNR and were "+
+			"imputed a perfect score", len(imputed),
+			len(comp.FrameIndices()), settings.quickRejectPSNR)
+	}
+
+	masked := markedFrames(comp.Masked(), comp.FrameIndices())
+	if settings.subtitleMask != "" {
+		log.Printf("%d of %d frame pairs had subtitles masked", len(masked),
+			len(comp.FrameIndices()))
+	}
+
+	if abort != nil {
+		// Frame analysis and patch export expect every frame to be scored.
+		var scored int
+		for _, values := range scores {
+			scored = max(scored, len(values))
+		}
+		log.Printf("%v, reporting the first %d frame pairs", abort, scored)
+		return scores, frameReport{}, nil
+	}
+
+	report, err := analyseFrames(ctx, recorders,
+		float64(reference.GetFrameRate()))
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	if err = exportPatches(ctx, reference, distortion, scores,
+		comp.FrameIndices(), exportOptions); err != nil {
+		return nil, frameReport{}, err
+	}
+
+	report.imputed, report.masked = imputed, masked
+	return scores, report, nil
 }
 
-func createMetricAndWriter(metricName string, ref, dist *vship.Colorspace) (
-	video.Metric, *metrics.HeatmapWriter, error) {
+// markedFrames returns the source frames, out of frames, of the marked
+// pairs, such as those whose scores the quick reject imputed.
+func markedFrames(marked []bool, frames []int) []int {
+	var sourceFrames []int
+	for i, set := range marked {
+		if set {
+			sourceFrames = append(sourceFrames, frames[i])
+		}
+	}
+	return sourceFrames
+}
+
+// describeStats returns the progress bar description showing the throughput
+// of every metric.
+func describeStats(stats []comparator.MetricStats) string {
+	parts := make([]string, len(stats))
+	for i, s := range stats {
+		parts[i] = fmt.Sprintf("%s %.1f fps", s.Name, s.FPS)
+	}
+	return "Computing metrics (" + strings.Join(parts, ", ") + ")"
+}
+
+// openSources opens both videos and runs them through the frame rate
+// matching, orientation, tone-mapping, cropping and color preparation
+// selected on the command line.
+func openSources(ctx context.Context, referencePath, distortionPath string) (
+	reference, distortion video.Source, referencePlan,
+	distortionPlan *vcolor.Plan, err error) {
+	decodeOptions := sources.FFms2Options{DecodeShards: settings.decodeShards,
+		DecodeThreads: settings.decodeThreads, SeekMode: settings.seekMode,
+		IndexErrors: settings.indexErrors}
+	if settings.indexCache != "" {
+		decodeOptions.IndexCache, err = sources.OpenIndexCache(
+			settings.indexCache, settings.indexCacheSize)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	reference, distortion, err = openPair(ctx, referencePath,
+		distortionPath, decodeOptions)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// apply runs one step on the sources, closing them if it fails. The steps
+	// return the sources unchanged or wrapped in sources that close them.
+	apply := func(step func(reference, distortion video.Source) (
+		video.Source, video.Source, error)) error {
+		r, d, err := step(reference, distortion)
+		if err != nil {
+			reference.Close()
+			distortion.Close()
+			return err
+		}
+		reference, distortion = r, d
+		return nil
+	}
+
+	if err = apply(applyFrameMap); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err = apply(alignStartTimes); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err = apply(matchFrameRates); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err = apply(separateFields); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if err = apply(handleOrientation); err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if settings.toneMap {
+		err = apply(func(reference, distortion video.Source) (video.Source,
+			video.Source, error) {
+			return vcolor.ToneMapIfNeeded(reference, distortion,
+				settings.toneMapOptions)
+		})
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	if settings.autoCrop {
+		err = apply(func(reference, distortion video.Source) (video.Source,
+			video.Source, error) {
+			return autoCrop(referencePath, distortionPath, reference,
+				distortion)
+		})
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("auto crop: %w", err)
+		}
+	}
+
+	if err = apply(downscaleToProxy); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("proxy: %w", err)
+	}
+
+	err = apply(func(reference, distortion video.Source) (video.Source,
+		video.Source, error) {
+		return requantizeSources(referencePath, distortionPath, reference,
+			distortion)
+	})
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("requantize: %w", err)
+	}
+
+	err = apply(func(reference, distortion video.Source) (video.Source,
+		video.Source, error) {
+		prepared, plan, err := vcolor.Prepare(reference, vcolor.VshipBackend,
+			settings.inference)
+		if err != nil {
+			return nil, nil, colorPlanError("reference", err)
+		}
+		referencePlan = plan
+		return prepared, distortion, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	err = apply(func(reference, distortion video.Source) (video.Source,
+		video.Source, error) {
+		prepared, plan, err := vcolor.Prepare(distortion,
+			vcolor.VshipBackend, settings.inference)
+		if err != nil {
+			return nil, nil, colorPlanError("distortion", err)
+		}
+		distortionPlan = plan
+		return reference, prepared, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return reference, distortion, referencePlan, distortionPlan, nil
+}
+
+// newColorSpaces returns the vship colorspaces for the prepared sources,
+// scaled to the --width and --height comparison resolution.
+func newColorSpaces(referencePlan, distortionPlan *vcolor.Plan) (reference,
+	distortion vship.Colorspace, err error) {
+	reference.SetDefaults(0, 0, 0)
+	distortion.SetDefaults(0, 0, 0)
+
+	reference.TargetHeight = settings.compareHeight
+	reference.TargetWidth = settings.compareWidth
+	distortion.TargetHeight = settings.compareHeight
+	distortion.TargetWidth = settings.compareWidth
+
+	if err = referencePlan.ToVship(&reference); err != nil {
+		return reference, distortion, err
+	}
+
+	err = distortionPlan.ToVship(&distortion)
+	return reference, distortion, err
+}
+
+// checkColorMismatch compares the interpreted color properties of both sources
+// and handles differences according to --color-mismatch. The mismatches are
+// returned so they can be reported alongside the scores.
+func checkColorMismatch(ref, dist *vcolor.Plan) (vcolor.Mismatches, error) {
+	if settings.colorMismatch == "ignore" {
+		return nil, nil
+	}
+
+	mismatches, err := vcolor.CompareProperties(ref.Resolved(),
+		dist.Resolved())
+	if err != nil {
+		return nil, err
+	}
+
+	switch settings.colorMismatch {
+	case "error":
+		return nil, mismatches.Err()
+	case "warn":
+		for _, m := range mismatches {
+			log.Printf("warning: color mismatch, %s", m)
+		}
+		return mismatches, nil
+	default:
+		return nil, fmt.Errorf("unsupported color mismatch mode: %s",
+			settings.colorMismatch)
+	}
+}
+
+// separateFields splits both sources into their fields for --fields, pairing
+// the fields of both in display order.
+func separateFields(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	var order sources.FieldOrder
+	switch settings.fieldMode {
+	case "off":
+		return reference, distortion, nil
+	case "auto":
+		order = sources.FieldOrderAuto
+	case "tff":
+		order = sources.TopFieldFirst
+	case "bff":
+		order = sources.BottomFieldFirst
+	default:
+		return nil, nil, fmt.Errorf("unsupported field mode: %s",
+			settings.fieldMode)
+	}
+	if settings.frameMap != "" {
+		return nil, nil, errors.New("--fields cannot be combined with " +
+			"--frame-map")
+	}
+
+	reference, err := sources.SeparateFields(reference, order)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reference: %w", err)
+	}
+	distortion, err = sources.SeparateFields(distortion, order)
+	if err != nil {
+		return nil, nil, fmt.Errorf("distortion: %w", err)
+	}
+	return reference, distortion, nil
+}
+
+// handleOrientation applies or checks the sources' rotation and flip metadata
+// according to --orientation.
+func handleOrientation(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	switch settings.orientationMode {
+	case "ignore":
+		return reference, distortion, nil
+	case "check":
+		err := sources.CheckOrientation(reference, distortion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w. Pass --orientation apply to "+
+				"rotate both upright", err)
+		}
+		return reference, distortion, nil
+	case "apply":
+		reference, err := sources.Orient(reference)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reference: %w", err)
+		}
+
+		distortion, err := sources.Orient(distortion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("distortion: %w", err)
+		}
+
+		return reference, distortion, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported orientation mode: %s",
+			settings.orientationMode)
+	}
+}
+
+func parseKeyFrameMode(mode string) (comparator.KeyFrameMode, error) {
+	switch mode {
+	case "off":
+		return comparator.KeyFrameModeOff, nil
+	case "keyframes":
+		return comparator.KeyFrameModeKeyFrames, nil
+	case "gop":
+		return comparator.KeyFrameModeGOP, nil
+	default:
+		return 0, fmt.Errorf("unsupported keyframe mode: %s", mode)
+	}
+}
+
+// metricWorkers returns the number of workers to create for a metric, from
+// --metric-workers or else --frame-threads.
+func metricWorkers(name string) int {
+	if workers, ok := settings.metricWorkers[name]; ok {
+		return workers
+	}
+	return max(settings.frameThreads, 1)
+}
+
+func createMetricAndWriter(metricName string, reference,
+	distortion *video.ColorProperties, ref, dist *vship.Colorspace,
+	frameRate float32) (video.Metric, *metrics.HeatmapWriter, error) {
+	// No-reference metrics read the distortion as decoded, at any
+	// resolution the others compare at.
+	if isNoReferenceMetric(metricName) {
+		return newNoReferenceMetric(metricName, distortion)
+	}
+	if len(settings.resolutions) > 0 {
+		return newMultiResolution(metricName, reference, distortion, ref, dist,
+			frameRate)
+	}
+	return newMetricAndWriter(metricName, reference, distortion, ref, dist,
+		frameRate)
+}
+
+// newMetricAndWriter creates metricName at the resolution of the colorspaces.
+func newMetricAndWriter(metricName string, reference,
+	distortion *video.ColorProperties, ref, dist *vship.Colorspace,
+	frameRate float32) (video.Metric, *metrics.HeatmapWriter, error) {
 	switch metricName {
 	case metrics.ButteraugliName:
-		return newButteraugli(ref, dist)
+		return newButteraugli(ref, dist, frameRate)
 	case metrics.SSIMulacra2Name:
 		return newSSIMULACRA2(ref, dist)
 	case metrics.CVVDPName:
-		return newCVVDP(ref, dist)
+		return newCVVDP(ref, dist, frameRate)
 	default:
-		return nil, nil, fmt.Errorf("unsupported metric: %s", metricName)
+		return newPluginMetric(metricName, reference, distortion)
 	}
 }
 
-func newCVVDP(ref, dist *vship.Colorspace) (video.Metric,
+func newCVVDP(ref, dist *vship.Colorspace, frameRate float32) (video.Metric,
 	*metrics.HeatmapWriter, error) {
-	handler, err := metrics.NewCVVDPHandler(settings.frameThreads, ref, dist,
-		settings.cvvdpUseTemporalScore, settings.cvvdpReizeToDisplay,
-		settings.displayModel, settings.frameRate)
+	// Temporal weighting and heat maps run on the comparator's ordered lane,
+	// which only ever uses one worker.
+	workers := metricWorkers(metrics.CVVDPName)
+	if settings.cvvdpUseTemporalScore || settings.cvvdpDistMapPath != "" {
+		workers = 1
+	}
+
+	opts := metrics.CVVDPOptions{
+		Workers:         workers,
+		Reference:       ref,
+		Distortion:      dist,
+		UseTemporal:     settings.cvvdpUseTemporalScore,
+		ResizeToDisplay: settings.cvvdpResizeToDisplay,
+		Display:         settings.displayModel,
+		FrameRate:       frameRate,
+	}
+
+	if conditions := cvvdpConditions(); conditions != nil {
+		return newCVVDPSweep(opts, conditions)
+	}
+
+	handler, err := metrics.NewCVVDPHandler(opts)
 	if err != nil {
 		return nil, nil, fmt.Errorf("cvvdp  creation failed: %w", err)
 	}
 
 	writer, err := createHeatmapWriterIfRequested(handler,
-		settings.cvvdpDistMapPath, settings.cvvdpClipping)
+		settings.cvvdpDistMapPath, settings.cvvdpClipping, frameRate)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -135,9 +742,44 @@ func newCVVDP(ref, dist *vship.Colorspace) (video.Metric,
 	return video.Metric(handler), writer, nil
 }
 
+// cvvdpConditions returns the viewing conditions of --cvvdp-ambient-sweep or
+// --cvvdp-distance-sweep, or nil if neither was given.
+func cvvdpConditions() []metrics.ViewingCondition {
+	var conditions []metrics.ViewingCondition
+	if len(settings.cvvdpAmbientSweep) > 0 {
+		conditions = append(conditions, metrics.AmbientSweep(
+			settings.displayModel, settings.cvvdpAmbientSweep)...)
+	}
+	if len(settings.cvvdpDistanceSweep) > 0 {
+		conditions = append(conditions, metrics.DistanceSweep(
+			settings.displayModel, settings.cvvdpDistanceSweep)...)
+	}
+	return conditions
+}
+
+func newCVVDPSweep(opts metrics.CVVDPOptions,
+	conditions []metrics.ViewingCondition) (video.Metric,
+	*metrics.HeatmapWriter, error) {
+	if settings.cvvdpDistMapPath != "" {
+		return nil, nil, errors.New("--cvvdp-video-path cannot be combined " +
+			"with a CVVDP sweep")
+	}
+
+	sweep, err := metrics.NewCVVDPSweep(opts, conditions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cvvdp sweep creation failed: %w", err)
+	}
+
+	return sweep, nil, nil
+}
+
 func newSSIMULACRA2(ref, dist *vship.Colorspace) (video.Metric,
 	*metrics.HeatmapWriter, error) {
-	handler, err := metrics.NewSSIMU2Handler(settings.frameThreads, ref, dist)
+	handler, err := metrics.NewSSIMU2Handler(metrics.SSIMU2Options{
+		Workers:    metricWorkers(metrics.SSIMulacra2Name),
+		Reference:  ref,
+		Distortion: dist,
+	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("ssimulacra2 creation failed: %w", err)
 	}
@@ -145,18 +787,32 @@ func newSSIMULACRA2(ref, dist *vship.Colorspace) (video.Metric,
 	return video.Metric(handler), nil, nil
 }
 
-func newButteraugli(ref, dist *vship.Colorspace) (video.Metric,
-	*metrics.HeatmapWriter, error) {
-	handler, err := metrics.NewButterHandler(settings.frameThreads, ref, dist,
-		settings.butteraugliQnormValue,
-		settings.displayModel.DisplayMaxLuminance,
-	)
+func newButteraugli(ref, dist *vship.Colorspace, frameRate float32) (
+	video.Metric, *metrics.HeatmapWriter, error) {
+	workers := metricWorkers(metrics.ButteraugliName)
+	if settings.butteraugliDistMapPath != "" {
+		workers = 1
+	}
+
+	intensity := settings.displayModel.DisplayMaxLuminance
+	if settings.butteraugliIntensity > 0 {
+		intensity = settings.butteraugliIntensity
+	}
+
+	handler, err := metrics.NewButterHandler(metrics.ButteraugliOptions{
+		Workers:          workers,
+		Reference:        ref,
+		Distortion:       dist,
+		QNorm:            settings.butteraugliQnormValue,
+		DisplayIntensity: intensity,
+	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("butteraugli creation failed: %w", err)
 	}
 
 	writer, err := createHeatmapWriterIfRequested(handler,
-		settings.butteraugliDistMapPath, settings.butteraugliClipping)
+		settings.butteraugliDistMapPath, settings.butteraugliClipping,
+		frameRate)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -165,17 +821,110 @@ func newButteraugli(ref, dist *vship.Colorspace) (video.Metric,
 }
 
 func createHeatmapWriterIfRequested(metric metrics.MetricWithDistortionMap,
-	outputPath string, clipping float32) (*metrics.HeatmapWriter, error) {
+	outputPath string, clipping, frameRate float32) (*metrics.HeatmapWriter,
+	error) {
 	if outputPath == "" {
 		return nil, nil
 	}
+	if !video.MetricCapabilities(metric).DistortionMap {
+		return nil, fmt.Errorf("%s cannot write distortion maps",
+			metric.Name())
+	}
+
+	sink, err := newHeatmapSink(metric, outputPath, frameRate)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to create heatmap writer for %s: %w", outputPath, err)
+	}
 
-	writer, err := metrics.WriteDistMapToVideo(metric, settings.frameRate,
-		nil, outputPath, clipping)
+	writer, err := metrics.NewHeatmapWriter(metric, sink, clipping)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"failed to create heatmap writer for %s: %w", outputPath, err)
 	}
 
+	if settings.heatmapBurnIn &&
+		!strings.EqualFold(filepath.Ext(outputPath), ".png") {
+		if err := writer.EnableBurnIn(frameRate); err != nil {
+			_ = writer.Close()
+			return nil, fmt.Errorf("%s: %w", outputPath, err)
+		}
+	}
+
+	if settings.heatmapAutoRange.Percentile > 0 {
+		if err := writer.EnableAutoRange(settings.heatmapAutoRange); err != nil {
+			_ = writer.Close()
+			return nil, fmt.Errorf("%s: %w", outputPath, err)
+		}
+	}
+
 	return writer, nil
 }
+
+// newHeatmapSink picks the heat map sink from the output path: tcp://host:port
+// and unix:///path stream raw float32 maps to a socket, a .y4m path writes
+// 16-bit grayscale Y4M, a .png path writes the max and mean projections of
+// every map to <name>_max.png and <name>_mean.png, or the map itself for the
+// image subcommand, and anything else is encoded to video with ffmpeg.
+func newHeatmapSink(metric metrics.MetricWithDistortionMap, outputPath string,
+	frameRate float32) (metrics.HeatmapSink, error) {
+	width, height, err := metric.GetDistMapResolution()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, network := range []string{"tcp", "unix"} {
+		address, ok := strings.CutPrefix(outputPath, network+"://")
+		if !ok {
+			continue
+		}
+
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("streaming %dx%d float32 heat maps to %s", width, height,
+			outputPath)
+		return metrics.NewRawHeatmapSink(conn), nil
+	}
+
+	if strings.EqualFold(filepath.Ext(outputPath), ".y4m") {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := metrics.NewY4MHeatmapSink(file, width, height, frameRate)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return sink, nil
+	}
+
+	if settings.stillImage {
+		if !strings.EqualFold(filepath.Ext(outputPath), ".png") {
+			return nil, errors.New("heat maps of images are written as .png")
+		}
+		return metrics.NewPNGHeatmapSink(width, height, outputPath)
+	}
+
+	if ext := filepath.Ext(outputPath); strings.EqualFold(ext, ".png") {
+		base := strings.TrimSuffix(outputPath, ext)
+		return metrics.NewAggregateHeatmapSink(width, height,
+			base+"_max"+ext, base+"_mean"+ext)
+	}
+
+	return metrics.NewFFmpegHeatmapSink(width, height, frameRate,
+		settings.heatmapFFmpeg, outputPath)
+}
+
+// colorPlanError points the user at the --assume-* flags when strict color
+// inference rejects an untagged source.
+func colorPlanError(name string, err error) error {
+	if errors.Is(err, vcolor.ErrUnspecified) {
+		return fmt.Errorf("%s: %w. Tag the source or pass the matching "+
+			"--assume-matrix, --assume-transfer, --assume-primaries or "+
+			"--assume-range flag", name, err)
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
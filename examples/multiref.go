@@ -0,0 +1,365 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/pflag"
+)
+
+// multiReferenceResults is the JSON written to --output with --references.
+type multiReferenceResults struct {
+	// Left out with --deterministic.
+	Created *time.Time        `json:"created,omitempty"`
+	Args    []string          `json:"args"`
+	Options map[string]string `json:"options"`
+
+	Libraries results.Libraries `json:"libraries"`
+
+	// The references by label.
+	References map[string]results.Source `json:"references"`
+	Distortion results.Source            `json:"distortion"`
+
+	// Per-frame scores of every metric against every reference in frame
+	// order, keyed by comparator.ReferenceKey.
+	Scores    map[string][]float64    `json:"scores"`
+	Summaries []results.MetricSummary `json:"summaries"`
+	// The label of the reference the distortion scored best against, by
+	// metric, for metrics with a known direction.
+	Closest map[string]string `json:"closest"`
+}
+
+// multiReference is one reference of --references with the color plans of
+// it and the distortion prepared against it.
+type multiReference struct {
+	label, path                      string
+	source                           video.Source
+	plan                             *vcolor.Plan
+	colorSpace, distortionColorSpace vship.Colorspace
+}
+
+// runMultiReference compares --distortion against every reference of
+// --references in one pass, decoding the distortion once, and reports which
+// reference it scores closest to by every metric, e.g. to tell whether a
+// delivery was made from the master before or after grading.
+func runMultiReference(ctx context.Context) error {
+	if err := checkMultiReferenceFlags(); err != nil {
+		return err
+	}
+	// Frames are paired by number, as every reference would need its own
+	// offset skipping frames of the shared distortion.
+	settings.startOffset = "ignore"
+
+	references, distortion, distortionPlan, err := openReferences(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, reference := range references {
+			reference.source.Close()
+		}
+	}()
+
+	if settings.frameRate < 0 {
+		settings.frameRate = distortion.GetFrameRate()
+	}
+
+	cache, err := scoreCache()
+	if err != nil {
+		distortion.Close()
+		return err
+	}
+
+	byLabel := make(map[string]*multiReference, len(references))
+	options := comparator.MultiReferenceOptions{
+		Distortion:    distortion,
+		FrameThreads:  settings.frameThreads,
+		SkipIdentical: settings.skipIdentical,
+		CheckFrames:   settings.checkFrames,
+		ScoreCache:    cache,
+	}
+	var total int
+	for _, reference := range references {
+		byLabel[reference.label] = reference
+		options.References = append(options.References, comparator.Reference{
+			Label: reference.label, Source: reference.source})
+		total += min(reference.source.GetNumFrames(),
+			distortion.GetNumFrames())
+	}
+
+	options.NewMetrics = func(label string, a, b video.Source) (
+		[]video.Metric, error) {
+		reference := byLabel[label]
+		var metricHandlers []video.Metric
+		for _, metric := range settings.metrics {
+			metricHandler, _, err := createMetricAndWriter(metric,
+				a.GetColorProps(), b.GetColorProps(), &reference.colorSpace,
+				&reference.distortionColorSpace, settings.frameRate)
+			if err != nil {
+				for _, created := range metricHandlers {
+					created.Close()
+				}
+				return nil, err
+			}
+			metricHandlers = append(metricHandlers, metricHandler)
+		}
+		return metricHandlers, nil
+	}
+
+	// The distortion is closed by the comparison, so the sources are
+	// described first.
+	var described *multiReferenceResults
+	if settings.outputPath != "" {
+		if described, err = describeReferences(references, distortion,
+			distortionPlan); err != nil {
+			distortion.Close()
+			return err
+		}
+	}
+
+	bar := progressbar.NewOptions(total,
+		progressbar.OptionSetDescription("Computing metrics"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+	)
+	options.Progress = func(done, total int) { _ = bar.Add(1) }
+
+	scores, err := comparator.RunMultiReference(ctx, options)
+	if err != nil {
+		return err
+	}
+	if settings.normalize {
+		metrics.AddNormalizedScores(scores)
+	}
+
+	summaries := results.NewRun(scores, nil).Summaries(scoreDirection)
+	closest := closestReferences(summaries, slices.Collect(maps.Keys(byLabel)))
+	printSummary(scores, nil)
+	printClosestReferences(closest, summaries)
+
+	if settings.outputPath == "" {
+		return nil
+	}
+	described.Scores, described.Summaries = scores, summaries
+	described.Closest = closest
+	return writeMultiReferenceResults(settings.outputPath, described)
+}
+
+// checkMultiReferenceFlags returns an error for the flags --references does
+// not support, those pairing frames or preparing the distortion differently
+// for each reference and the comparison modes built around a single pair.
+func checkMultiReferenceFlags() error {
+	if settings.referenceVideo != "" {
+		return errors.New("--reference cannot be combined with --references")
+	}
+	if settings.distortionVideo == "" {
+		return errors.New("--references needs a --distortion")
+	}
+
+	conflicts := []struct {
+		set  bool
+		flag string
+	}{
+		{settings.frameMap != "", "--frame-map"},
+		{settings.frameRateMatch != "none", "--frame-rate-match"},
+		{settings.autoCrop, "--auto-crop"},
+		{settings.toneMap, "--tonemap"},
+		{settings.requantize != "off", "--requantize"},
+		{settings.twoPassStride > 0, "--two-pass"},
+		{len(settings.workers) > 0, "--workers"},
+		{settings.parallelChunks > 1, "--parallel-chunks"},
+		{settings.keyFrameMode != "off", "--keyframe-mode"},
+		{settings.butteraugliDistMapPath != "" ||
+			settings.cvvdpDistMapPath != "", "heat map output"},
+		{frameAnalysisEnabled(), "frame analysis"},
+		{settings.patchOptions.Dir != "", "--export-patches"},
+		{settings.streamScores, "--stream-scores"},
+		{len(settings.abortIf) > 0, "--abort-if"},
+		{settings.quickRejectPSNR > 0, "--quick-reject-psnr"},
+		{settings.subtitleMask != "", "--subtitle-mask"},
+	}
+	for _, conflict := range conflicts {
+		if conflict.set {
+			return fmt.Errorf("%s cannot be combined with --references",
+				conflict.flag)
+		}
+	}
+	return nil
+}
+
+// openReferences opens every reference of --references, in label order,
+// and the distortion once. Every reference is prepared against its own
+// opening of the distortion, of which only the first is kept, so nothing
+// but indexing is repeated.
+func openReferences(ctx context.Context) ([]*multiReference, video.Source,
+	*vcolor.Plan, error) {
+	var references []*multiReference
+	var distortion video.Source
+	var distortionPlan *vcolor.Plan
+	closeAll := func() {
+		for _, reference := range references {
+			reference.source.Close()
+		}
+		if distortion != nil {
+			distortion.Close()
+		}
+	}
+
+	for _, label := range slices.Sorted(maps.Keys(settings.references)) {
+		if label == "" || strings.ContainsAny(label, "@") {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("invalid reference label %q, "+
+				"labels must be non-empty and cannot contain @", label)
+		}
+		path := settings.references[label]
+
+		source, pairedDistortion, plan, pairedPlan, err := openSources(ctx,
+			path, settings.distortionVideo)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("reference %s: %w", label, err)
+		}
+		if distortion == nil {
+			distortion, distortionPlan = pairedDistortion, pairedPlan
+		} else {
+			pairedDistortion.Close()
+		}
+
+		reference := &multiReference{label: label, path: path,
+			source: source, plan: plan}
+		references = append(references, reference)
+
+		if _, err = checkColorMismatch(plan, distortionPlan); err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("reference %s: %w", label, err)
+		}
+		reference.colorSpace, reference.distortionColorSpace, err =
+			newColorSpaces(plan, distortionPlan)
+		if err != nil {
+			closeAll()
+			return nil, nil, nil, fmt.Errorf("reference %s: %w", label, err)
+		}
+	}
+	return references, distortion, distortionPlan, nil
+}
+
+// closestReferences returns the label of the reference with the best mean
+// score by every metric with a known direction, from summaries keyed by
+// comparator.ReferenceKey.
+func closestReferences(summaries []results.MetricSummary,
+	labels []string) map[string]string {
+	best := make(map[string]results.MetricSummary)
+	closest := make(map[string]string)
+
+	for _, summary := range summaries {
+		if summary.Frames == 0 ||
+			strings.HasSuffix(summary.Key, metrics.NormSuffix) {
+			continue
+		}
+		cut := strings.LastIndex(summary.Key, "@")
+		if cut < 0 || !slices.Contains(labels, summary.Key[cut+1:]) {
+			continue
+		}
+		key, label := summary.Key[:cut], summary.Key[cut+1:]
+
+		direction := scoreDirection(key)
+		if direction == video.DirectionUnknown {
+			continue
+		}
+		previous, ok := best[key]
+		if ok && !(direction == video.HigherIsBetter &&
+			summary.Mean > previous.Mean ||
+			direction == video.LowerIsBetter && summary.Mean < previous.Mean) {
+			continue
+		}
+		best[key], closest[key] = summary, label
+	}
+	return closest
+}
+
+// printClosestReferences prints the reference every metric scored best
+// against, with the mean score against it.
+func printClosestReferences(closest map[string]string,
+	summaries []results.MetricSummary) {
+	if len(closest) == 0 {
+		return
+	}
+
+	means := make(map[string]float64, len(summaries))
+	for _, summary := range summaries {
+		means[summary.Key] = summary.Mean
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Closest reference")
+	fmt.Fprintln(os.Stderr, "=================")
+	for _, key := range slices.Sorted(maps.Keys(closest)) {
+		label := closest[key]
+		mean := means[comparator.ReferenceKey(key, label)]
+		fmt.Fprintf(os.Stderr, "%s: %s, mean %s\n", displayName(key), label,
+			scoreFormat(key).FormatValue(mean))
+	}
+}
+
+// describeReferences returns the results file describing the run and its
+// sources, for the scores to be added to.
+func describeReferences(references []*multiReference,
+	distortion video.Source, distortionPlan *vcolor.Plan) (
+	*multiReferenceResults, error) {
+	out := &multiReferenceResults{
+		Args:       os.Args,
+		Options:    make(map[string]string),
+		Libraries:  getLibraryVersions(),
+		References: make(map[string]results.Source, len(references)),
+	}
+	if !settings.deterministic {
+		created := time.Now().UTC()
+		out.Created = &created
+	}
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		out.Options[f.Name] = f.Value.String()
+	})
+
+	for _, reference := range references {
+		metadata, err := describeSource(sourceInput{path: reference.path,
+			source: reference.source, plan: reference.plan})
+		if err != nil {
+			return nil, fmt.Errorf("reference %s: %w", reference.label, err)
+		}
+		out.References[reference.label] = metadata
+	}
+	var err error
+	out.Distortion, err = describeSource(sourceInput{
+		path: settings.distortionVideo, source: distortion,
+		plan: distortionPlan})
+	if err != nil {
+		return nil, fmt.Errorf("distortion: %w", err)
+	}
+	return out, nil
+}
+
+// writeMultiReferenceResults writes results as JSON to path.
+func writeMultiReferenceResults(path string,
+	results *multiReferenceResults) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,154 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"github.com/spf13/pflag"
+)
+
+// noReferenceAhead is how many frames the comparator may read of one side of
+// the single source before the other.
+const noReferenceAhead = 8
+
+// isNoReferenceMetric reports whether the built-in metric name scores the
+// distortion alone.
+func isNoReferenceMetric(name string) bool {
+	capabilities, ok := metrics.BuiltinCapabilities(name)
+	return ok && capabilities.NoReference
+}
+
+// newNoReferenceMetric creates the no-reference metric name for frames
+// described by props.
+func newNoReferenceMetric(name string, props *video.ColorProperties) (
+	video.Metric, *metrics.HeatmapWriter, error) {
+	switch name {
+	case metrics.BlockinessName:
+		metric, err := metrics.NewBlockiness(props)
+		return metric, nil, err
+	case metrics.BandingName:
+		metric, err := metrics.NewBanding(props)
+		return metric, nil, err
+	default:
+		return nil, nil, fmt.Errorf("%s is not a no-reference metric", name)
+	}
+}
+
+// runNoReference scores --distortion alone for --no-reference, with the
+// no-reference metrics and the frame analysis that needs no reference, and
+// reports the results like a comparison. The comparator reads the source on
+// both sides through a Tee, so scoring, progress, summaries and the results
+// file are those of every other run. The results have no reference.
+func runNoReference(ctx context.Context) error {
+	if err := checkNoReferenceFlags(); err != nil {
+		return err
+	}
+
+	monitor := startResourceMonitor()
+
+	decodeOptions := sources.FFms2Options{DecodeShards: settings.decodeShards,
+		DecodeThreads: settings.decodeThreads, SeekMode: settings.seekMode,
+		IndexErrors: settings.indexErrors}
+	opened, err := openPlanar(ctx, settings.distortionVideo, decodeOptions)
+	if err != nil {
+		return fmt.Errorf("distorted video: %w", err)
+	}
+	source, plan, err := vcolor.Prepare(opened, vcolor.VshipBackend,
+		settings.inference)
+	if err != nil {
+		opened.Close()
+		return colorPlanError("distortion", err)
+	}
+
+	readers, err := sources.Tee(source, 2, noReferenceAhead)
+	if err != nil {
+		source.Close()
+		return err
+	}
+	defer readers[0].Close()
+	defer readers[1].Close()
+
+	scores, report, err := scoreSources(ctx, readers[0], readers[1], plan,
+		plan)
+	if err != nil {
+		return err
+	}
+
+	excluded := excludedFrames(report.events, scores)
+	report.segments, err = worstSegments(source, scores, report.frames,
+		excluded)
+	if err != nil {
+		return err
+	}
+	report.chapters, err = chapterScores(ctx, source, source, scores,
+		report.frames)
+	if err != nil {
+		return err
+	}
+	if report.audio, err = audioLoudness(ctx); err != nil {
+		return err
+	}
+
+	printSummary(withoutFrames(scores, excluded), report.segments)
+	printEvents(report.events)
+	printComplexity(report.complexity, scores)
+	printPictTypeStats(pictTypeStats(scores, report.metadata[1]))
+	logSeekFallbacks(report.metadata)
+	printChapters(report.chapters)
+	printAudio(report.audio)
+
+	if settings.outputPath == "" {
+		return nil
+	}
+	// The source stands in for the reference where the results need one,
+	// e.g. to cut --scene-list scenes, and is only described once.
+	inputs := [2]sourceInput{{source: source, plan: plan},
+		{settings.distortionVideo, source, plan, nil}}
+	return writeResults(settings.outputPath, scores, report, excluded,
+		inputs, monitor.usage())
+}
+
+// checkNoReferenceFlags rejects the flags that need a reference or read the
+// source out of order, and selects every no-reference metric unless
+// --metrics names some.
+func checkNoReferenceFlags() error {
+	switch {
+	case settings.referenceVideo != "":
+		return errors.New("--no-reference scores --distortion alone, " +
+			"drop --reference")
+	case settings.distortionVideo == "":
+		return errors.New("--no-reference needs the --distortion to score")
+	case settings.detectCadence || settings.avSync ||
+		settings.chapters == "reference":
+		return errors.New("--detect-cadence, --av-sync and --chapters " +
+			"reference need a reference")
+	case settings.twoPassStride > 0 || len(settings.workers) > 0 ||
+		settings.parallelChunks > 1:
+		return errors.New("--no-reference cannot be combined with " +
+			"--two-pass, --workers or --parallel-chunks")
+	case settings.keyFrameMode != "off" || settings.patchOptions.Dir != "" ||
+		settings.autoCrop || settings.frameMap != "":
+		return errors.New("--no-reference cannot be combined with " +
+			"--keyframe-mode, --export-patches, --auto-crop or --frame-map")
+	}
+
+	if !pflag.CommandLine.Changed("metrics") {
+		settings.metrics = []string{metrics.BlockinessName,
+			metrics.BandingName}
+	}
+	for _, name := range settings.metrics {
+		if !isNoReferenceMetric(name) {
+			return fmt.Errorf("%s needs a reference, --no-reference runs "+
+				"only %s and %s", name, metrics.BlockinessName,
+				metrics.BandingName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Command plugin is an example metric plugin serving the PSNR of the first
+// plane, luma or for planar RGB green. It only depends on the pure Go parts
+// of gometrics. Build it into a plugin directory and select it by name:
+//
+//	CGO_ENABLED=0 go build -o plugins/gometrics-metric-psnr ./examples/plugin
+//	go run ./examples -r ref.mkv -d dist.mkv --plugin-dir plugins --metrics psnr
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/plugin"
+)
+
+// name is the metric name, which must match the executable name after
+// plugin.ExecutablePrefix.
+const name = "psnr"
+
+// maxPSNR is the score of identical planes, which have infinite PSNR.
+const maxPSNR = 100
+
+// psnr implements plugin.Metric.
+type psnr struct {
+	width, height int
+	wide          bool
+	peak          float64
+}
+
+func (p *psnr) Name() string { return name }
+func (p *psnr) Close()       {}
+
+// Capabilities describes PSNR in dB, higher is better.
+func (p *psnr) Capabilities() video.Capabilities {
+	return video.Capabilities{
+		MinScore:  0,
+		MaxScore:  maxPSNR,
+		Direction: video.HigherIsBetter,
+		Format:    video.ScoreFormat{Unit: "dB", Decimals: 3},
+	}
+}
+
+// Init checks that both sources are planar and share a size and bit depth.
+func (p *psnr) Init(a, b *video.ColorProperties) error {
+	for _, props := range []*video.ColorProperties{a, b} {
+		layout, err := props.Layout()
+		if err != nil {
+			return err
+		}
+		if layout == video.LayoutPacked {
+			return fmt.Errorf("%s needs planar frames", name)
+		}
+	}
+	if a.Width != b.Width || a.Height != b.Height {
+		return fmt.Errorf("frame sizes differ: %dx%d and %dx%d", a.Width,
+			a.Height, b.Width, b.Height)
+	}
+
+	depthA, err := a.BitDepth()
+	if err != nil {
+		return err
+	}
+	depthB, err := b.BitDepth()
+	if err != nil {
+		return err
+	}
+	if depthA != depthB {
+		return fmt.Errorf("bit depths differ: %d and %d", depthA, depthB)
+	}
+
+	p.width, p.height = a.Width, a.Height
+	p.wide = depthA > 8
+	p.peak = float64(int(1)<<depthA - 1)
+	return nil
+}
+
+// Compute returns the PSNR of the first planes of a and b.
+func (p *psnr) Compute(a, b video.Frame) (map[string]float64, error) {
+	planeA, strideA := a.PlaneData(0), a.PlaneLineSize(0)
+	planeB, strideB := b.PlaneData(0), b.PlaneLineSize(0)
+
+	var sum float64
+	for y := range p.height {
+		rowA, rowB := planeA[y*strideA:], planeB[y*strideB:]
+		for x := range p.width {
+			var d float64
+			if p.wide {
+				d = float64(int(rowA[2*x])|int(rowA[2*x+1])<<8) -
+					float64(int(rowB[2*x])|int(rowB[2*x+1])<<8)
+			} else {
+				d = float64(rowA[x]) - float64(rowB[x])
+			}
+			sum += d * d
+		}
+	}
+
+	score := float64(maxPSNR)
+	if mse := sum / float64(p.width*p.height); mse > 0 {
+		score = min(score, 10*math.Log10(p.peak*p.peak/mse))
+	}
+	return map[string]float64{name: score}, nil
+}
+
+func main() {
+	if err := plugin.Serve(&psnr{}); err != nil {
+		log.Fatal(err)
+	}
+}
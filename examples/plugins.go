@@ -0,0 +1,34 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/plugin"
+)
+
+// newPluginMetric launches the plugin serving the metric name from the
+// --plugin-dir directories. Plugins cannot write heat maps.
+func newPluginMetric(name string, reference,
+	distortion *video.ColorProperties) (video.Metric, *metrics.HeatmapWriter,
+	error) {
+	plugins, err := plugin.Discover(settings.pluginDirs...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover plugins: %w", err)
+	}
+
+	path, ok := plugins[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported metric: %s", name)
+	}
+
+	client, err := plugin.Open(path, reference, distortion)
+	if err != nil {
+		return nil, nil, err
+	}
+	recordPluginCapabilities(client)
+	return client, nil, nil
+}
@@ -0,0 +1,96 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// appliedProxy holds the --proxy resolution of the first sources opened, for
+// the results file. Sources opened again, e.g. by --parallel-chunks, report
+// their downscaling only once.
+var appliedProxy struct {
+	once       sync.Once
+	resolution *results.Proxy
+}
+
+// parseProxy parses the WIDTHxHEIGHT of --proxy and makes it the resolution
+// the metrics compare at.
+func parseProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	if settings.compareWidth > 0 || settings.compareHeight > 0 {
+		return fmt.Errorf("--proxy cannot be combined with --width or " +
+			"--height")
+	}
+
+	width, height, ok := strings.Cut(proxy, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if !ok || errW != nil || errH != nil || w < 1 || h < 1 {
+		return fmt.Errorf("invalid --proxy %q, want WIDTHxHEIGHT", proxy)
+	}
+
+	settings.proxy = [2]int{w, h}
+	settings.compareWidth, settings.compareHeight = w, h
+	return nil
+}
+
+// downscaleToProxy halves both sources on the CPU for --proxy as long as they
+// stay at least as large as the proxy resolution. The metric backend scales
+// what remains to the proxy resolution itself.
+func downscaleToProxy(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	if settings.proxy == [2]int{} {
+		return reference, distortion, nil
+	}
+
+	referenceWidth := reference.GetColorProps().Width
+	reference, referenceHalvings, err := halveToProxy(reference)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reference: %w", err)
+	}
+	distortion, distortionHalvings, err := halveToProxy(distortion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("distortion: %w", err)
+	}
+
+	appliedProxy.once.Do(func() {
+		appliedProxy.resolution = &results.Proxy{Width: settings.proxy[0],
+			Height: settings.proxy[1],
+			Scale:  float64(settings.proxy[0]) / float64(referenceWidth)}
+		log.Printf("proxy: scoring at %dx%d, halved the reference %d and "+
+			"the distortion %d times first", settings.proxy[0],
+			settings.proxy[1], referenceHalvings, distortionHalvings)
+	})
+	return reference, distortion, nil
+}
+
+// halveToProxy halves source while the result covers the proxy resolution,
+// returning how often it did.
+func halveToProxy(source video.Source) (video.Source, int, error) {
+	var halvings int
+	for {
+		props := source.GetColorProps()
+		if props.Width/2 < settings.proxy[0] ||
+			props.Height/2 < settings.proxy[1] || !sources.CanHalve(props) {
+			return source, halvings, nil
+		}
+
+		halved, err := sources.Halve(source)
+		if err != nil {
+			return nil, 0, err
+		}
+		source = halved
+		halvings++
+	}
+}
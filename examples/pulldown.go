@@ -0,0 +1,96 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// pulldownLogged makes sources opened again, e.g. by --parallel-chunks,
+// report their pulldown only once.
+var pulldownLogged sync.Once
+
+// matchFrameRates reports the pulldown of both sources and, for
+// --frame-rate-match, converts one of them so their frames correspond.
+func matchFrameRates(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	mismatch := analysis.CompareFrameCounts(reference.GetNumFrames(),
+		distortion.GetNumFrames())
+
+	pulldownLogged.Do(func() {
+		for _, side := range []struct {
+			name   string
+			source video.Source
+		}{{"reference", reference}, {"distortion", distortion}} {
+			if pulldown := sourcePulldown(side.source); pulldown.Repeated > 0 {
+				log.Printf("%s pulldown: %v, frames are compared as coded",
+					side.name, pulldown)
+			}
+		}
+
+		if mismatch != analysis.FrameRatesMatch {
+			log.Printf("%v: reference has %d frames at %.3f fps, distortion "+
+				"%d at %.3f fps", mismatch, reference.GetNumFrames(),
+				reference.GetFrameRate(), distortion.GetNumFrames(),
+				distortion.GetFrameRate())
+		}
+	})
+
+	switch settings.frameRateMatch {
+	case "none":
+		return reference, distortion, nil
+	case "ivtc":
+		var err error
+		switch mismatch {
+		case analysis.FrameRatesMatch:
+		case analysis.DistortionTelecined:
+			distortion, err = sources.InverseTelecine(distortion)
+			if err != nil {
+				return nil, nil, fmt.Errorf("distortion: %w", err)
+			}
+		case analysis.ReferenceTelecined:
+			reference, err = sources.InverseTelecine(reference)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reference: %w", err)
+			}
+		default:
+			return nil, nil, fmt.Errorf("--frame-rate-match ivtc needs 5 "+
+				"frames of one video for every 4 of the other, got %d and %d",
+				reference.GetNumFrames(), distortion.GetNumFrames())
+		}
+		return reference, distortion, nil
+	case "map":
+		if mismatch == analysis.FrameRatesMatch {
+			return reference, distortion, nil
+		}
+		distortion, err := sources.Retime(distortion,
+			reference.GetNumFrames())
+		if err != nil {
+			return nil, nil, fmt.Errorf("distortion: %w", err)
+		}
+		return reference, distortion, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported frame rate match mode: %s",
+			settings.frameRateMatch)
+	}
+}
+
+// sourcePulldown returns the pulldown of source from its repeat field flags,
+// or none if it does not report them.
+func sourcePulldown(source video.Source) analysis.Pulldown {
+	repeatSource, ok := video.As[video.FieldRepeatSource](source)
+	if !ok {
+		return analysis.Pulldown{}
+	}
+	repeats, err := repeatSource.GetRepeatFields()
+	if err != nil {
+		return analysis.Pulldown{}
+	}
+	return analysis.DetectPulldown(repeats)
+}
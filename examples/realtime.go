@@ -0,0 +1,123 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// realtimeRun holds the clock --realtime paces the sources with and the
+// latency of every frame pair scored.
+var realtimeRun struct {
+	sync.Mutex
+	pacer     *sources.Pacer
+	frameRate float64
+	// latencies holds the time from the arrival of every frame pair to its
+	// scores, in the order they completed.
+	latencies []time.Duration
+	last      time.Time
+}
+
+// paceSources delivers the frames of both sources at the frame rate of the
+// reference for --realtime, as a live feed would, so the report tells
+// whether the metrics keep up with one on this machine.
+func paceSources(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	if !settings.realtime {
+		return reference, distortion, nil
+	}
+	switch {
+	case settings.twoPassStride > 0 || len(settings.workers) > 0 ||
+		settings.parallelChunks > 1:
+		return nil, nil, errors.New("--realtime cannot be combined with " +
+			"--two-pass, --workers or --parallel-chunks")
+	case settings.keyFrameMode != "off" || settings.patchOptions.Dir != "":
+		return nil, nil, errors.New("--realtime cannot be combined with " +
+			"--keyframe-mode or --export-patches, which seek")
+	}
+
+	frameRate := float64(reference.GetFrameRate())
+	pacer, err := sources.NewPacer(frameRate)
+	if err != nil {
+		return nil, nil, err
+	}
+	realtimeRun.pacer, realtimeRun.frameRate = pacer, frameRate
+	return sources.Pace(reference, pacer), sources.Pace(distortion, pacer),
+		nil
+}
+
+// recordLatencies wraps next, which may be nil, to record the latency of
+// every frame pair when --realtime is set.
+func recordLatencies(next comparator.ScoresCallback) comparator.ScoresCallback {
+	if realtimeRun.pacer == nil {
+		return next
+	}
+	return func(index int, scores map[string]float64) error {
+		now := time.Now()
+		realtimeRun.Lock()
+		realtimeRun.latencies = append(realtimeRun.latencies,
+			now.Sub(realtimeRun.pacer.Due(index)))
+		realtimeRun.last = now
+		realtimeRun.Unlock()
+
+		if next == nil {
+			return nil
+		}
+		return next(index, scores)
+	}
+}
+
+// printRealtime reports whether the metrics kept up with the paced sources:
+// they do if every frame pair was scored within --realtime-latency of
+// arriving.
+func printRealtime() {
+	realtimeRun.Lock()
+	defer realtimeRun.Unlock()
+	if realtimeRun.pacer == nil || len(realtimeRun.latencies) == 0 {
+		return
+	}
+
+	latencies := slices.Clone(realtimeRun.latencies)
+	slices.Sort(latencies)
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(
+			time.Millisecond)
+	}
+
+	var late int
+	for _, latency := range latencies {
+		if latency > settings.realtimeLatency {
+			late++
+		}
+	}
+	elapsed := realtimeRun.last.Sub(realtimeRun.pacer.Due(0)).Seconds()
+	sustained := float64(len(latencies)) / elapsed
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Real-time simulation")
+	fmt.Fprintln(os.Stderr, "====================")
+	fmt.Fprintf(os.Stderr, "  feed: %.3f fps  scored: %.3f fps  frames: %d\n",
+		realtimeRun.frameRate, sustained, len(latencies))
+	fmt.Fprintf(os.Stderr, "  latency: p50 %s  p99 %s  max %s  read lag: "+
+		"%s\n", percentile(0.5), percentile(0.99), percentile(1),
+		realtimeRun.pacer.MaxLag().Round(time.Millisecond))
+
+	if late == 0 {
+		fmt.Fprintf(os.Stderr, "  The metrics keep up with a live feed on "+
+			"this machine: every frame pair was scored within %s.\n",
+			settings.realtimeLatency)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  The metrics fall behind a live feed on this "+
+		"machine: %d of %d frame pairs took over %s to score.\n", late,
+		len(latencies), settings.realtimeLatency)
+}
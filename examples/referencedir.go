@@ -0,0 +1,141 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// pickReference sets --reference to the file of --reference-dir that the
+// distortion matches best, by the signatures of --reference-samples frames
+// of each, so the full comparison runs against it. Files that cannot be
+// opened as video are skipped.
+func pickReference(ctx context.Context) error {
+	if settings.referenceVideo != "" {
+		return errors.New("--reference cannot be combined with " +
+			"--reference-dir")
+	}
+
+	paths, err := candidateReferences(settings.referenceDir,
+		settings.distortionVideo)
+	if err != nil {
+		return err
+	}
+
+	decodeOptions := sources.FFms2Options{
+		DecodeThreads: settings.decodeThreads, SeekMode: settings.seekMode,
+		IndexErrors: settings.indexErrors}
+	if settings.indexCache != "" {
+		cache, err := sources.OpenIndexCache(settings.indexCache,
+			settings.indexCacheSize)
+		if err != nil {
+			return err
+		}
+		decodeOptions.IndexCache = cache
+	}
+
+	distortion, err := openPlanar(ctx, settings.distortionVideo,
+		decodeOptions)
+	if err != nil {
+		return fmt.Errorf("distortion: %w", err)
+	}
+	defer distortion.Close()
+
+	var opened []string
+	var candidates []video.Source
+	defer func() {
+		for _, candidate := range candidates {
+			candidate.Close()
+		}
+	}()
+	for _, path := range paths {
+		candidate, err := openPlanar(ctx, path, decodeOptions)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("reference dir: skipping %s: %v", path, err)
+			continue
+		}
+		opened = append(opened, path)
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no video in --reference-dir %s",
+			settings.referenceDir)
+	}
+
+	matches, err := analysis.RankReferences(distortion, candidates,
+		analysis.MatchOptions{Samples: settings.referenceSamples})
+	if err != nil {
+		return fmt.Errorf("reference dir: %w", err)
+	}
+
+	for i, match := range matches {
+		log.Printf("reference dir: %d. %s, signature distance %.4f", i+1,
+			opened[match.Index], match.Distance)
+	}
+	// Signatures of the same content differ by well under 0.01, so a close
+	// runner-up means the candidates hold the same pictures.
+	if len(matches) > 1 && matches[1].Distance-matches[0].Distance < 0.005 {
+		log.Printf("warning: %s and %s match the distortion about equally, "+
+			"the pick between them is unreliable", opened[matches[0].Index],
+			opened[matches[1].Index])
+	}
+
+	settings.referenceVideo = opened[matches[0].Index]
+	log.Printf("reference dir: comparing against %s", settings.referenceVideo)
+	return nil
+}
+
+// candidateReferences returns the paths of the regular, non-hidden files of
+// dir other than the distortion, in name order.
+func candidateReferences(dir, distortionPath string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reference dir: %w", err)
+	}
+	distortion, err := filepath.Abs(distortionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if abs, err := filepath.Abs(path); err == nil && abs == distortion {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// openPlanar opens the first video track of path with planar frames, as
+// frame signatures need.
+func openPlanar(ctx context.Context, path string,
+	opts sources.FFms2Options) (video.Source, error) {
+	source, err := sources.NewFFms2ReaderContext(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	planar, err := sources.Planarize(source)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
+	return planar, nil
+}
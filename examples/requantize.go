@@ -0,0 +1,91 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+)
+
+// requantized holds the requantization of every source by path, so the
+// results file can report it.
+var requantized = struct {
+	sync.Mutex
+	byPath map[string]results.Requantization
+}{byPath: make(map[string]results.Requantization)}
+
+// requantizeSources brings the source with the higher bit depth down to that
+// of the other for --requantize, rounding as the flag selects, so both carry
+// the same quantization when they reach the metrics.
+func requantizeSources(referencePath, distortionPath string, reference,
+	distortion video.Source) (video.Source, video.Source, error) {
+	if settings.requantize == "off" {
+		return reference, distortion, nil
+	}
+	dither, err := vcolor.ParseDither(settings.requantize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unsupported requantize mode: %s",
+			settings.requantize)
+	}
+
+	referenceDepth, err := reference.GetColorProps().BitDepth()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reference: %w", err)
+	}
+	distortionDepth, err := distortion.GetColorProps().BitDepth()
+	if err != nil {
+		return nil, nil, fmt.Errorf("distortion: %w", err)
+	}
+
+	switch {
+	case referenceDepth > distortionDepth:
+		reference, err = vcolor.Requantize(reference, distortionDepth, dither)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reference: %w", err)
+		}
+		recordRequantization("reference", referencePath, referenceDepth,
+			distortionDepth, dither)
+	case distortionDepth > referenceDepth:
+		distortion, err = vcolor.Requantize(distortion, referenceDepth,
+			dither)
+		if err != nil {
+			return nil, nil, fmt.Errorf("distortion: %w", err)
+		}
+		recordRequantization("distortion", distortionPath, distortionDepth,
+			referenceDepth, dither)
+	}
+	return reference, distortion, nil
+}
+
+// recordRequantization remembers the requantization of the source at path,
+// logging it the first time.
+func recordRequantization(name, path string, from, to int,
+	dither vcolor.Dither) {
+	requantized.Lock()
+	defer requantized.Unlock()
+
+	if _, ok := requantized.byPath[path]; !ok {
+		log.Printf("requantizing the %s from %d to %d bits with %v", name,
+			from, to, dither)
+	}
+	requantized.byPath[path] = results.Requantization{FromDepth: from,
+		ToDepth: to, Dither: dither.String()}
+}
+
+// sourceRequantization returns the requantization of the source at path, or
+// nil if it kept its bit depth.
+func sourceRequantization(path string) *results.Requantization {
+	requantized.Lock()
+	defer requantized.Unlock()
+
+	r, ok := requantized.byPath[path]
+	if !ok {
+		return nil
+	}
+	return &r
+}
@@ -0,0 +1,112 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+)
+
+// analysisResolution is one resolution of --resolutions. A height only
+// resolution takes its width from the aspect ratio of the reference.
+type analysisResolution struct {
+	label         string
+	width, height int
+}
+
+// parseResolutions parses the native, <height>p and WIDTHxHEIGHT entries of
+// --resolutions.
+func parseResolutions(entries []string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	switch {
+	case settings.compareWidth > 0 || settings.compareHeight > 0 ||
+		settings.proxy != [2]int{}:
+		return fmt.Errorf("--resolutions cannot be combined with --width, " +
+			"--height or --proxy")
+	case settings.butteraugliDistMapPath != "" ||
+		settings.cvvdpDistMapPath != "":
+		return fmt.Errorf("--resolutions cannot be combined with heat maps")
+	}
+
+	for _, entry := range entries {
+		resolution := analysisResolution{label: entry}
+
+		switch {
+		case entry == "native":
+		case strings.HasSuffix(entry, "p"):
+			h, err := strconv.Atoi(strings.TrimSuffix(entry, "p"))
+			if err != nil || h < 1 {
+				return fmt.Errorf("invalid resolution %q", entry)
+			}
+			resolution.height = h
+		default:
+			width, height, ok := strings.Cut(entry, "x")
+			w, errW := strconv.Atoi(width)
+			h, errH := strconv.Atoi(height)
+			if !ok || errW != nil || errH != nil || w < 1 || h < 1 {
+				return fmt.Errorf("invalid resolution %q, want native, "+
+					"<height>p or WIDTHxHEIGHT", entry)
+			}
+			resolution.width, resolution.height = w, h
+		}
+
+		settings.resolutions = append(settings.resolutions, resolution)
+	}
+	return nil
+}
+
+// metricResolutions returns the --resolutions for a reference described by
+// props.
+func metricResolutions(props *video.ColorProperties) []metrics.Resolution {
+	resolutions := make([]metrics.Resolution, len(settings.resolutions))
+	for i, r := range settings.resolutions {
+		resolutions[i] = metrics.Resolution{Label: r.label, Width: r.width,
+			Height: r.height}
+		if r.height > 0 && r.width == 0 {
+			// Round to an even width, which every chroma layout accepts.
+			width := float64(r.height) * float64(props.Width) /
+				float64(props.Height)
+			resolutions[i].Width = 2 * int(width/2+0.5)
+		}
+	}
+	return resolutions
+}
+
+// newMultiResolution creates one metricName metric per --resolutions entry,
+// each with the colorspaces scaled to its resolution, from one decode.
+func newMultiResolution(metricName string, reference,
+	distortion *video.ColorProperties, ref, dist *vship.Colorspace,
+	frameRate float32) (video.Metric, *metrics.HeatmapWriter, error) {
+	switch metricName {
+	case metrics.ButteraugliName, metrics.SSIMulacra2Name, metrics.CVVDPName:
+	default:
+		return nil, nil, fmt.Errorf("--resolutions does not support the %s "+
+			"metric", metricName)
+	}
+
+	metric, err := metrics.NewMultiResolution(metricResolutions(reference),
+		func(resolution metrics.Resolution) (video.Metric, error) {
+			scaledRef, scaledDist := *ref, *dist
+			if resolution.Width > 0 {
+				scaledRef.TargetWidth = resolution.Width
+				scaledRef.TargetHeight = resolution.Height
+				scaledDist.TargetWidth = resolution.Width
+				scaledDist.TargetHeight = resolution.Height
+			}
+
+			metric, _, err := newMetricAndWriter(metricName, reference,
+				distortion, &scaledRef, &scaledDist, frameRate)
+			return metric, err
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return metric, nil, nil
+}
@@ -0,0 +1,131 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+)
+
+// vramPollInterval is how often the VRAM use of the process is sampled.
+const vramPollInterval = time.Second
+
+// resourceMonitor measures the resources of a run from its start.
+type resourceMonitor struct {
+	started time.Time
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	peakVRAM *int64
+	timeline []analysis.GPUSample
+}
+
+// startResourceMonitor starts measuring the resources of the run. Stop it
+// with usage. VRAM and the GPU clocks are only sampled when the results file
+// reports them.
+func startResourceMonitor() *resourceMonitor {
+	ctx, stop := context.WithCancel(context.Background())
+	m := &resourceMonitor{started: time.Now(), stop: stop}
+
+	if settings.outputPath == "" || settings.deterministic {
+		return m
+	}
+
+	m.wg.Add(1)
+	go m.pollGPU(ctx)
+	if vship.GetVersion().Backend == vship.BackendCuda {
+		m.wg.Add(1)
+		go m.pollVRAM(ctx)
+	}
+
+	return m
+}
+
+// usage stops the monitor and returns the resources used since it started.
+func (m *resourceMonitor) usage() results.Resources {
+	m.stop()
+	m.wg.Wait()
+
+	usage := results.Resources{WallTime: time.Since(m.started).Seconds()}
+	usage.UserTime, usage.SystemTime, usage.PeakRSS = processUsage()
+	_, usage.PeakPinned = comparator.PinnedMemory()
+
+	m.mu.Lock()
+	usage.PeakVRAM = m.peakVRAM
+	usage.Timeline = m.timeline
+	m.mu.Unlock()
+
+	usage.Throttling = analysis.ThrottleEvents(usage.Timeline)
+	logThrottling(usage.Throttling)
+	return usage
+}
+
+// pollVRAM samples the VRAM use of the process with nvidia-smi until ctx is
+// done. It gives up at the first failed sample, as nvidia-smi is then
+// missing or cannot see the process.
+func (m *resourceMonitor) pollVRAM(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(vramPollInterval)
+	defer ticker.Stop()
+
+	for {
+		used, err := processVRAM(ctx)
+		if err != nil {
+			return
+		}
+
+		m.mu.Lock()
+		if m.peakVRAM == nil || used > *m.peakVRAM {
+			m.peakVRAM = &used
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processVRAM returns the VRAM in bytes nvidia-smi reports the process using
+// over all GPUs.
+func processVRAM(ctx context.Context) (int64, error) {
+	output, err := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-compute-apps=pid,used_memory",
+		"--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	pid := strconv.Itoa(os.Getpid())
+	var used int64
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) != pid {
+			continue
+		}
+		// used_memory is in MiB.
+		mib, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		used += mib << 20
+	}
+	return used, nil
+}
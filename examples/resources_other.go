@@ -0,0 +1,8 @@
+//go:build cgo && !nocgo && !unix
+
+package main
+
+// processUsage reports nothing on platforms without getrusage.
+func processUsage() (user, system float64, peakRSS int64) {
+	return 0, 0, 0
+}
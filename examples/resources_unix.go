@@ -0,0 +1,27 @@
+//go:build cgo && !nocgo && unix
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// processUsage returns the CPU time of the process in seconds and its peak
+// resident set size in bytes.
+func processUsage() (user, system float64, peakRSS int64) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0, 0
+	}
+
+	peakRSS = int64(usage.Maxrss)
+	// Linux and the BSDs report the peak in KiB, macOS in bytes.
+	if runtime.GOOS != "darwin" {
+		peakRSS <<= 10
+	}
+
+	return time.Duration(usage.Utime.Nano()).Seconds(),
+		time.Duration(usage.Stime.Nano()).Seconds(), peakRSS
+}
@@ -0,0 +1,247 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"github.com/spf13/pflag"
+)
+
+// sourceInput is what writeResults needs to describe one of the sources.
+type sourceInput struct {
+	path   string
+	source video.Source
+	plan   *vcolor.Plan
+	crop   *video.Rect
+}
+
+// sourceInputs describes the sources opened from --reference and
+// --distortion for writeResults.
+func sourceInputs(reference, distortion video.Source, referencePlan,
+	distortionPlan *vcolor.Plan) [2]sourceInput {
+	crops := croppedRects(settings.referenceVideo, settings.distortionVideo)
+	return [2]sourceInput{
+		{settings.referenceVideo, reference, referencePlan, crops[0]},
+		{settings.distortionVideo, distortion, distortionPlan, crops[1]},
+	}
+}
+
+// writeResults writes the scores and the run metadata as JSON to path.
+func writeResults(path string, scores map[string][]float64,
+	report frameReport, excluded []int, inputs [2]sourceInput,
+	usage results.Resources) error {
+	libraries := getLibraryVersions()
+
+	run := results.NewRun(scores, report.frames)
+	run.Excluded = excluded
+	run.Options = make(map[string]string)
+	run.MetricVersions = make(map[string]string)
+	if !settings.deterministic {
+		created := time.Now().UTC()
+		run.Created = &created
+	}
+
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		run.Options[f.Name] = f.Value.String()
+	})
+
+	for name := range scores {
+		run.MetricVersions[name] = metricVersion(name, libraries.Vship)
+	}
+
+	if !settings.noReference {
+		reference, err := describeSource(inputs[0])
+		if err != nil {
+			return fmt.Errorf("reference: %w", err)
+		}
+		run.Reference = &reference
+	}
+	var err error
+	if run.Distortion, err = describeSource(inputs[1]); err != nil {
+		return fmt.Errorf("distortion: %w", err)
+	}
+
+	out := results.NewFile(run)
+	out.Deterministic = settings.deterministic
+	out.Args = os.Args
+	out.Libraries = libraries
+	out.Events = report.events
+	out.Sync = report.sync
+	out.Complexity = report.complexity
+	out.GOPs = report.gops
+	out.Chapters = report.chapters
+	out.WorstSegments = report.segments
+	out.Audio = report.audio
+	out.Regions, out.RegionMetric = report.regions, report.regionMetric
+	out.ImputedFrames = report.imputed
+	out.MaskedFrames = report.masked
+	out.Proxy = appliedProxy.resolution
+	out.StartOffset = appliedStartOffset.offset
+	out.UnmappedFrames = report.unmapped
+
+	if report.metadata[0] != nil {
+		out.FrameMetadata = &results.FrameMetadata{
+			Reference: report.metadata[0], Distortion: report.metadata[1]}
+		out.ScoresByPictType = pictTypeStats(scores, report.metadata[1])
+	}
+	if !settings.deterministic {
+		out.Resources = &usage
+	}
+
+	out.Summaries, out.Scenes, err = summarizeRun(inputs[0].source, run)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = out.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// summarizeRun pools the scores of run over the run and, with --scene-list,
+// per scene of reference.
+func summarizeRun(reference video.Source, run *results.Run) (
+	[]results.MetricSummary, []results.SceneSummary, error) {
+	summaries := run.Summaries(metrics.ScoreDirection)
+	if settings.sceneListPath == "" {
+		return summaries, nil, nil
+	}
+
+	cuts, err := sceneCuts(reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	return summaries, run.SceneSummaries(cuts, metrics.ScoreDirection), nil
+}
+
+func getLibraryVersions() results.Libraries {
+	vshipVersion := vship.GetVersion()
+	backend := "hip"
+	if vshipVersion.Backend == vship.BackendCuda {
+		backend = "cuda"
+	}
+
+	// FFMS_GetVersion packs major, minor, micro and bump into one byte each.
+	ffmsVersion := ffms.GetVersion()
+
+	versions := results.Libraries{
+		Vship: fmt.Sprintf("%d.%d.%d", vshipVersion.Major,
+			vshipVersion.Minor, vshipVersion.MinorMinor),
+		VshipBackend: backend,
+		FFms2: fmt.Sprintf("%d.%d.%d.%d", ffmsVersion>>24&0xff,
+			ffmsVersion>>16&0xff, ffmsVersion>>8&0xff, ffmsVersion&0xff),
+	}
+
+	if device, code := vship.GetDeviceInfo(0); code.IsNone() {
+		versions.Device = device.Name
+	}
+
+	return versions
+}
+
+func describeSource(input sourceInput) (results.Source, error) {
+	absPath, size, hash, info, err := identifySource(input.path)
+	if err != nil {
+		return results.Source{}, err
+	}
+
+	props := input.plan.Input
+
+	pixelFormat := fmt.Sprintf("%v", props.PixelFormat)
+	if desc, err := pixfmts.PixFmtDescGet(props.PixelFormat); err == nil {
+		pixelFormat = desc.Name()
+	}
+
+	return results.Source{
+		Path:           absPath,
+		Size:           size,
+		SHA256:         hash,
+		Container:      info.Format,
+		Codec:          info.Codec,
+		Width:          props.Width,
+		Height:         props.Height,
+		PixelFormat:    pixelFormat,
+		Frames:         input.source.GetNumFrames(),
+		FrameRate:      input.source.GetFrameRate(),
+		Orientation:    props.Orientation.String(),
+		Crop:           input.crop,
+		Requantized:    sourceRequantization(input.path),
+		Matrix:         vcolor.MatrixName(props.ColorSpace),
+		Transfer:       vcolor.TransferName(props.ColorTransfer),
+		Primaries:      vcolor.PrimariesName(props.ColorPrimaries),
+		Range:          vcolor.RangeName(props.ColorRange),
+		ChromaLocation: vcolor.ChromaLocationName(props.ChromaLocation),
+		ColorPlan:      input.plan.String(),
+		HDR:            results.NewHDR(props.HDR),
+	}, nil
+}
+
+// metricVersion returns the library implementing the metric scored under
+// key with its version. The no-reference metrics run on the CPU in gometrics
+// itself.
+func metricVersion(key, vshipVersion string) string {
+	if isNoReferenceMetric(key) {
+		return "gometrics"
+	}
+	return "vship " + vshipVersion
+}
+
+// identifySource returns the absolute path, size, SHA-256 and container of the
+// file at path. Capture devices have no file, their container is the capture
+// API and the codec the raw frames ffmpeg delivers.
+func identifySource(path string) (absPath string, size int64, hash string,
+	info sources.FileInfo, err error) {
+	if api, _, ok := sources.ParseCapturePath(path); ok {
+		return path, 0, "", sources.FileInfo{Format: api, Codec: "raw video"},
+			nil
+	}
+
+	if absPath, err = filepath.Abs(path); err != nil {
+		return "", 0, "", sources.FileInfo{}, err
+	}
+	if size, hash, err = hashFile(absPath); err != nil {
+		return "", 0, "", sources.FileInfo{}, err
+	}
+	if info, err = sources.ProbeFile(absPath); err != nil {
+		return "", 0, "", sources.FileInfo{}, err
+	}
+	return absPath, size, hash, info, nil
+}
+
+// hashFile returns the size and hex encoded SHA-256 of the file at path.
+func hashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,60 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+// errNoSceneCuts is returned by sceneCuts for sources that cannot report
+// their keyframes when no --scene-list is given.
+var errNoSceneCuts = errors.New("the source cannot report its keyframes, " +
+	"pass a --scene-list")
+
+// sceneCuts returns the frames starting a scene of source: those of
+// --scene-list if set, its keyframes otherwise.
+func sceneCuts(source video.Source) ([]int, error) {
+	if settings.sceneListPath != "" {
+		cuts, err := analysis.ReadSceneListFile(settings.sceneListPath,
+			float64(source.GetFrameRate()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the scene list: %w", err)
+		}
+		return cuts, nil
+	}
+
+	keyFrameSource, ok := video.As[video.KeyFrameSource](source)
+	if !ok {
+		return nil, errNoSceneCuts
+	}
+	keyFrames, err := keyFrameSource.GetKeyFrames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keyframes: %w", err)
+	}
+	return keyFrames, nil
+}
+
+// exportSceneList writes the scene cuts of reference to --export-scene-list
+// if set.
+func exportSceneList(reference video.Source) error {
+	if settings.exportSceneList == "" {
+		return nil
+	}
+
+	format, err := analysis.ParseSceneListFormat(settings.sceneListFormat)
+	if err != nil {
+		return err
+	}
+
+	cuts, err := sceneCuts(reference)
+	if err != nil {
+		return err
+	}
+
+	return analysis.WriteSceneListFile(settings.exportSceneList, cuts, format,
+		float64(reference.GetFrameRate()))
+}
@@ -0,0 +1,49 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// openedScoreCache is the --score-cache file, opened by the first comparison
+// and shared by every comparator of the run.
+var openedScoreCache struct {
+	once  sync.Once
+	cache *comparator.ScoreCache
+	err   error
+}
+
+// scoreCache returns the --score-cache file, or nil if it is not set.
+func scoreCache() (*comparator.ScoreCache, error) {
+	if settings.scoreCache == "" {
+		return nil, nil
+	}
+	openedScoreCache.once.Do(func() {
+		openedScoreCache.cache, openedScoreCache.err =
+			comparator.OpenScoreCache(settings.scoreCache)
+	})
+	return openedScoreCache.cache, openedScoreCache.err
+}
+
+// setScoreCache makes comp reuse and add to the --score-cache file.
+func setScoreCache(comp *comparator.Comparator) error {
+	cache, err := scoreCache()
+	if err != nil {
+		return err
+	}
+	return comp.SetScoreCache(cache)
+}
+
+// closeScoreCache writes the scores added to the --score-cache file.
+func closeScoreCache() {
+	if openedScoreCache.cache == nil {
+		return
+	}
+	if err := openedScoreCache.cache.Close(); err != nil {
+		log.Println("warning:", err)
+	}
+}
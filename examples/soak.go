@@ -0,0 +1,66 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// runSoak runs the comparison --soak more times after a first one, checking
+// after each that the native resources the process holds are back to what
+// the first run left, see video.DebugLeakReport. The first run is not
+// checked, as it also sets up what lives as long as the process, such as the
+// GPU context.
+func runSoak(ctx context.Context) error {
+	var baseline video.LeakReport
+
+	for run := range settings.soak + 1 {
+		if err := soakRun(ctx); err != nil {
+			return fmt.Errorf("soak run %d: %w", run+1, err)
+		}
+
+		report := video.DebugLeakReport()
+		if run == 0 {
+			baseline = report
+			continue
+		}
+
+		if leaked := report.Since(baseline); len(leaked) > 0 {
+			return fmt.Errorf("soak run %d did not release:\n%s", run+1,
+				leaked)
+		}
+		log.Printf("soak run %d of %d released everything it held", run,
+			settings.soak)
+	}
+
+	return nil
+}
+
+// soakRun opens the sources, compares them and closes them again.
+func soakRun(ctx context.Context) error {
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, settings.referenceVideo, settings.distortionVideo)
+	if err != nil {
+		return err
+	}
+	defer reference.Close()
+	defer distortion.Close()
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return err
+	}
+
+	if settings.frameRate < 0 {
+		settings.frameRate = reference.GetFrameRate()
+	}
+
+	_, _, err = runComparison(ctx, reference, distortion,
+		&referenceColorSpace, &distortionColorSpace)
+	return err
+}
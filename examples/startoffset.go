@@ -0,0 +1,128 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// appliedStartOffset holds the offset of the first sources opened. Sources
+// opened again, e.g. by --parallel-chunks, report their offset only once.
+var appliedStartOffset struct {
+	once   sync.Once
+	offset *results.StartOffset
+}
+
+// alignStartTimes pairs the frames of the sources by presentation time for
+// --start-offset auto, so that container edit lists or start offsets that
+// make one video start a few frames later do not pair every frame with the
+// wrong one. The frames shown before the later video starts are skipped,
+// through the frame map that mapFrames reports scores with. On error the
+// sources are returned as passed in, for the caller to close.
+func alignStartTimes(reference, distortion video.Source) (video.Source,
+	video.Source, error) {
+	if settings.startOffset == "ignore" || settings.frameMap != "" {
+		return reference, distortion, nil
+	}
+	if settings.startOffset != "auto" {
+		return reference, distortion, fmt.Errorf("unsupported start "+
+			"offset mode: %s", settings.startOffset)
+	}
+
+	offset, ok := startTimeOffset(reference, distortion)
+	frameRate := float64(reference.GetFrameRate())
+	if !ok || math.Round(offset*frameRate) == 0 {
+		return reference, distortion, nil
+	}
+
+	if settings.frameRateMatch != "none" || len(settings.workers) > 0 {
+		appliedStartOffset.once.Do(func() {
+			log.Printf("warning: the distortion starts %.3fs after the "+
+				"reference, frames are paired by number as --start-offset "+
+				"cannot be combined with --frame-rate-match or --workers",
+				offset)
+		})
+		return reference, distortion, nil
+	}
+
+	m, err := offsetFrameMap(reference, distortion, offset, frameRate)
+	if err != nil {
+		return reference, distortion, fmt.Errorf("start offset: %w", err)
+	}
+
+	remappedReference, err := sources.Remap(reference, m.Reference)
+	if err != nil {
+		return reference, distortion, fmt.Errorf("reference: %w", err)
+	}
+	remappedDistortion, err := sources.Remap(distortion, m.Distortion)
+	if err != nil {
+		return reference, distortion, fmt.Errorf("distortion: %w", err)
+	}
+	reference, distortion = remappedReference, remappedDistortion
+
+	appliedStartOffset.once.Do(func() {
+		skipped := max(m.Reference[0], m.Distortion[0])
+		appliedStartOffset.offset = &results.StartOffset{Seconds: offset,
+			Frames: skipped}
+		first := "reference"
+		if offset < 0 {
+			first = "distortion"
+		}
+		log.Printf("start offset: the distortion starts %.3fs after the "+
+			"reference, skipping the first %d frames of the %s", offset,
+			skipped, first)
+	})
+	return reference, distortion, nil
+}
+
+// startTimeOffset returns the seconds the distortion starts after the
+// reference, or false if either source does not report its start time.
+func startTimeOffset(reference, distortion video.Source) (float64, bool) {
+	referenceTimes, ok := video.As[video.StartTimeSource](reference)
+	if !ok {
+		return 0, false
+	}
+	distortionTimes, ok := video.As[video.StartTimeSource](distortion)
+	if !ok {
+		return 0, false
+	}
+
+	referenceStart, err := referenceTimes.GetStartTime()
+	if err != nil {
+		return 0, false
+	}
+	distortionStart, err := distortionTimes.GetStartTime()
+	if err != nil {
+		return 0, false
+	}
+	return distortionStart - referenceStart, true
+}
+
+// offsetFrameMap returns the frame map compensating offset and makes it the
+// map mapFrames reports frames with.
+func offsetFrameMap(reference, distortion video.Source, offset,
+	frameRate float64) (*analysis.FrameMap, error) {
+	loadedFrameMap.Lock()
+	defer loadedFrameMap.Unlock()
+
+	if loadedFrameMap.frameMap == nil {
+		numFrames := [2]int{reference.GetNumFrames(),
+			distortion.GetNumFrames()}
+		m, err := analysis.OffsetFrameMap(numFrames[0], numFrames[1], offset,
+			frameRate)
+		if err != nil {
+			return nil, err
+		}
+		loadedFrameMap.frameMap = &m
+		loadedFrameMap.numFrames = numFrames
+	}
+	return loadedFrameMap.frameMap, nil
+}
@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package main
 
 import (
@@ -6,118 +8,492 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
-	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
 )
 
-const (
-	jodA   = 0.0439569391310215
-	jodExp = 0.9302042722702026
-)
+// displayName returns key with the unit of its scores, e.g. "CVVDP [JOD]".
+func displayName(key string) string {
+	if unit := scoreFormat(key).Unit; unit != "" {
+		return key + " [" + unit + "]"
+	}
+	return key
+}
+
+func printSummary(scores map[string][]float64,
+	segments map[string][]analysis.Segment) {
+	if len(scores) == 0 {
+		fmt.Fprintln(os.Stderr, "No scores to report")
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Metric summary")
+	fmt.Fprintln(os.Stderr, "==============")
+
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := scores[name]
+		if len(values) == 0 {
+			continue
+		}
+		printMetricSummary(name, values, segments[name])
+	}
 
-func jod(a float64) float64 {
-	return 10.0 - jodA*math.Pow(a, jodExp)
+	if len(names) > 1 {
+		methods := defaultCorrelationMethods()
+		printCorrelations(scores, names, methods)
+	}
 }
 
-func inverseJOD(a float64) float64 {
-	return math.Pow((10.0-a)/jodA, 1.0/jodExp)
+func printColorMismatches(mismatches vcolor.Mismatches) {
+	if len(mismatches) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Color mismatches")
+	fmt.Fprintln(os.Stderr, "================")
+
+	for _, m := range mismatches {
+		fmt.Fprintf(os.Stderr, "  %-12s reference: %-12s distortion: %s\n",
+			m.Attribute, m.Reference, m.Distortion)
+	}
 }
 
-// ────────────────────────────────────────────────────────────────────────────────
-// Metric presentation abstraction
-// ────────────────────────────────────────────────────────────────────────────────
+func printEvents(events []analysis.Event) {
+	if len(events) == 0 {
+		return
+	}
 
-type MetricPresenter interface {
-	DisplayName() string
-	// TransformForStats: space in which min/avg/median/stddev are computed
-	TransformForStats(v float64) float64
-	// TransformForDisplay: space in which values are shown to the user
-	TransformForDisplay(v float64) float64
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Frame events")
+	fmt.Fprintln(os.Stderr, "============")
+
+	for _, e := range events {
+		fmt.Fprintf(os.Stderr, "  %-10s frame %-8d count: %-6d %s\n",
+			e.Kind, e.Frame, e.Count, e.Source)
+	}
 }
 
-type DefaultPresenter struct {
-	name string
+func printSync(points []analysis.SyncPoint) {
+	if len(points) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Audio/video sync (ms, positive is late)")
+	fmt.Fprintln(os.Stderr, "=======================================")
+
+	var worst float64
+	for _, p := range points {
+		fmt.Fprintf(os.Stderr, "  %8.1fs  audio: %8.1f  video: %8.1f  "+
+			"drift: %8.1f  correlation: %.2f\n", p.Time, p.AudioLag*1000,
+			p.VideoLag*1000, p.Drift*1000, p.Correlation)
+		if math.Abs(p.Drift) > math.Abs(worst) {
+			worst = p.Drift
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "  Largest drift: %.1f ms\n", worst*1000)
 }
 
-func (p DefaultPresenter) DisplayName() string {
-	return p.name
+// printComplexity prints the mean and peak of every complexity feature of the
+// reference and how strongly each metric correlates with it.
+func printComplexity(features []analysis.Complexity,
+	scores map[string][]float64) {
+	if len(features) == 0 {
+		return
+	}
+
+	series := map[string][]float64{"SI": nil, "TI": nil, "Edge density": nil}
+	for _, f := range features {
+		series["SI"] = append(series["SI"], f.SI)
+		series["TI"] = append(series["TI"], f.TI)
+		series["Edge density"] = append(series["Edge density"], f.EdgeDensity)
+	}
+	featureNames := []string{"SI", "TI", "Edge density"}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Reference complexity")
+	fmt.Fprintln(os.Stderr, "====================")
+
+	for _, name := range featureNames {
+		values := series[name]
+		var sum, peak float64
+		for _, v := range values {
+			sum += v
+			peak = max(peak, v)
+		}
+		fmt.Fprintf(os.Stderr, "  %-12s average: %10.4f  max: %10.4f\n", name,
+			sum/float64(len(values)), peak)
+	}
+
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "  Correlation with scores (Pearson / Spearman)")
+	for _, name := range names {
+		if len(scores[name]) != len(features) {
+			continue
+		}
+		for _, feature := range featureNames {
+			fmt.Fprintf(os.Stderr, "  %-24s ↔ %-12s : % .4f / % .4f\n", name,
+				feature, pearsonCorrelation(scores[name], series[feature]),
+				spearmanCorrelation(scores[name], series[feature]))
+		}
+	}
 }
 
-func (p DefaultPresenter) TransformForStats(v float64) float64 {
-	return v
+// pictTypeStats returns the summary statistics of every metric split by the
+// picture type of the distortion, keyed by metric and then picture type. It
+// returns nil without frame metadata.
+func pictTypeStats(scores map[string][]float64,
+	metadata []video.FrameMetadata) map[string]map[string]results.Stats {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	out := make(map[string]map[string]results.Stats, len(scores))
+	for name, values := range scores {
+		groups := analysis.GroupScores(values, metadata, video.MetaPictType)
+		if len(groups) == 0 {
+			continue
+		}
+
+		out[name] = make(map[string]results.Stats, len(groups))
+		for pictType, groupValues := range groups {
+			if stats, ok := summarize(name, groupValues); ok {
+				out[name][pictType] = stats
+			}
+		}
+	}
+	return out
 }
 
-func (p DefaultPresenter) TransformForDisplay(v float64) float64 {
-	return v
+// printPictTypeStats prints the statistics of pictTypeStats, one row per
+// metric and picture type, to show e.g. how much worse B frames score than I
+// frames.
+func printPictTypeStats(stats map[string]map[string]results.Stats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Scores by picture type")
+	fmt.Fprintln(os.Stderr, "======================")
+
+	for _, name := range names {
+		types := make([]string, 0, len(stats[name]))
+		for pictType := range stats[name] {
+			types = append(types, pictType)
+		}
+		sort.Strings(types)
+
+		title, format := displayName(name), scoreFormat(name)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, title)
+		fmt.Fprintln(os.Stderr, strings.Repeat("-", len(title)))
+		fmt.Fprintf(os.Stderr, "  %-4s %8s %12s %12s %12s %12s %12s\n",
+			"type", "frames", "min", "max", "average", "median", "stddev")
+
+		for _, pictType := range types {
+			s := stats[name][pictType]
+			fmt.Fprintf(os.Stderr, "  %-4s %8d %12s %12s %12s %12s %12s\n",
+				pictType, s.Frames, format.FormatValue(s.Min),
+				format.FormatValue(s.Max), format.FormatValue(s.Average),
+				format.FormatValue(s.Median), format.FormatValue(s.StdDev))
+		}
+	}
 }
 
-type CVVDPPresenter struct{}
+// printWorstGOPs prints the --worst-gops GOPs of every metric with the worst
+// mean score.
+func printWorstGOPs(gops map[string][]analysis.GOP) {
+	if len(gops) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(gops))
+	for name := range gops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-func (p CVVDPPresenter) DisplayName() string {
-	return metrics.CVVDPName
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Worst GOPs")
+	fmt.Fprintln(os.Stderr, "==========")
+
+	for _, name := range names {
+		title, format := displayName(name), scoreFormat(name)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, title)
+		fmt.Fprintln(os.Stderr, strings.Repeat("-", len(title)))
+
+		for _, g := range analysis.WorstGOPs(gops[name], settings.worstGOPs,
+			higherIsWorse(name)) {
+			fmt.Fprintf(os.Stderr, "  frame %-8d at %s  frames: %-5d "+
+				"mean: %10s  worst: %10s\n", g.Start,
+				formatTimestamp(g.StartTime), g.Frames,
+				format.FormatValue(g.Mean), format.FormatValue(g.Worst))
+		}
+	}
 }
 
-func (p CVVDPPresenter) TransformForStats(v float64) float64 {
-	return inverseJOD(v)
+// printChapters prints the mean and worst score of every metric in every
+// chapter from --chapters.
+func printChapters(chapters map[string][]analysis.ChapterScore) {
+	if len(chapters) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(chapters))
+	for name := range chapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Chapters")
+	fmt.Fprintln(os.Stderr, "========")
+
+	for _, name := range names {
+		title, format := displayName(name), scoreFormat(name)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, title)
+		fmt.Fprintln(os.Stderr, strings.Repeat("-", len(title)))
+
+		for _, c := range chapters[name] {
+			fmt.Fprintf(os.Stderr, "  %-24s at %s  frames: %-6d "+
+				"mean: %10s  worst: %10s\n", c.Title,
+				formatTimestamp(c.StartTime), c.Frames,
+				format.FormatValue(c.Mean), format.FormatValue(c.Worst))
+		}
+	}
 }
 
-func (p CVVDPPresenter) TransformForDisplay(v float64) float64 {
-	return jod(v)
+// printAudio prints the loudness and clipping of every audio stream from
+// --audio-qc and how the distortion differs from the reference.
+func printAudio(streams []analysis.StreamLoudness) {
+	if len(streams) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Audio Loudness (LUFS, LU, dBTP)")
+	fmt.Fprintln(os.Stderr, "===============================")
+
+	printLoudness := func(side string, l *analysis.Loudness) {
+		if l == nil {
+			fmt.Fprintf(os.Stderr, "    %-11s missing\n", side+":")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "    %-11s integrated: %6.1f  range: %5.1f  "+
+			"true peak: %6.1f  clipped: %d (%d events)\n", side+":",
+			l.Integrated, l.Range, l.TruePeak, l.ClippedSamples,
+			l.ClipEvents)
+	}
+
+	for _, stream := range streams {
+		fmt.Fprintf(os.Stderr, "  Stream %d\n", stream.Stream)
+		if !settings.noReference {
+			printLoudness("reference", stream.Reference)
+		}
+		printLoudness("distortion", stream.Distortion)
+		if d := stream.Delta; d != nil {
+			fmt.Fprintf(os.Stderr, "    %-11s integrated: %+6.1f  range: "+
+				"%+5.1f  true peak: %+6.1f\n", "difference:", d.Integrated,
+				d.Range, d.TruePeak)
+		}
+	}
 }
 
-// ────────────────────────────────────────────────────────────────────────────────
-// Main printing logic
-// ────────────────────────────────────────────────────────────────────────────────
+// printRegions prints the regions --two-pass scored densely with the mean
+// and worst score of metric in each.
+func printRegions(regions []analysis.Region, metric string) {
+	if len(regions) == 0 {
+		return
+	}
 
-func getPresenter(name string) MetricPresenter {
-	if name == metrics.CVVDPName {
-		return CVVDPPresenter{}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Problem Regions")
+	fmt.Fprintln(os.Stderr, "===============")
+	title, format := displayName(metric), scoreFormat(metric)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, title)
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", len(title)))
+
+	for _, r := range regions {
+		fmt.Fprintf(os.Stderr, "  frame %-8d at %s  frames: %-5d "+
+			"mean: %10s  worst: %10s\n", r.Start,
+			formatTimestamp(r.StartTime), r.Frames,
+			format.FormatValue(r.Mean), format.FormatValue(r.Worst))
 	}
-	return DefaultPresenter{name: name}
 }
 
-func printSummary(scores map[string][]float64) {
-	if len(scores) == 0 {
-		fmt.Fprintln(os.Stderr, "No scores to report")
+// formatTimestamp formats seconds as h:mm:ss.mmm.
+func formatTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60,
+		ms/1000%60, ms%1000)
+}
+
+// printMetricStats prints the throughput of every metric and its share of the
+// total metric time, to show which metric dominates the runtime.
+func printMetricStats(stats []comparator.MetricStats) {
+	var total time.Duration
+	for _, s := range stats {
+		total += s.Busy
+	}
+	if total == 0 {
 		return
 	}
 
 	fmt.Fprintln(os.Stderr)
-	fmt.Fprintln(os.Stderr, "Metric summary")
-	fmt.Fprintln(os.Stderr, "==============")
+	fmt.Fprintln(os.Stderr, "Metric throughput")
+	fmt.Fprintln(os.Stderr, "=================")
+
+	for _, s := range stats {
+		fmt.Fprintf(os.Stderr, "  %-12s %8.2f fps  busy: %10s  share: %5.1f%%\n",
+			s.Name, s.FPS, s.Busy.Round(time.Millisecond),
+			100*float64(s.Busy)/float64(total))
+		printGPUStats(s.GPU)
+	}
+}
 
-	names := make([]string, 0, len(scores))
-	for name := range scores {
-		names = append(names, name)
+// transferBoundShare is the share of --pcie-bandwidth at which the calls of a
+// GPU metric count as bound by the transfers rather than the kernels.
+const transferBoundShare = 0.5
+
+// printGPUStats prints what the calls of a GPU metric cost per frame pair
+// and whether moving the frames or the kernels limited them. vship does not
+// time the upload, kernels and download of a call apart, so this goes by the
+// bandwidth the bytes moved over the call time add up to.
+func printGPUStats(stats *video.GPUStats) {
+	if stats == nil || stats.Calls == 0 {
+		return
 	}
-	sort.Strings(names)
 
-	for _, name := range names {
-		values := scores[name]
-		if len(values) == 0 {
-			continue
+	calls := float64(stats.Calls)
+	bandwidth := stats.Bandwidth()
+	bound := "compute bound"
+	if bandwidth >= transferBoundShare*settings.pcieBandwidth*1e9 {
+		bound = "transfer bound"
+	}
+	fmt.Fprintf(os.Stderr, "  %-12s %8.2f ms/call  up: %.1f MB/call  "+
+		"down: %.1f MB/call  %.2f GB/s, %s\n", "",
+		stats.Time.Seconds()*1e3/calls,
+		float64(stats.UploadBytes)/calls/1e6,
+		float64(stats.DownloadBytes)/calls/1e6, bandwidth/1e9, bound)
+}
+
+// poolWaitBound is the share of the run the frame readers must spend waiting
+// for free buffers for the metrics to be reported as the bottleneck.
+const poolWaitBound = 0.1
+
+// printPoolStats prints how the frame pools were used during a run of the
+// given wall time and whether the metrics or decoding limited throughput.
+func printPoolStats(stats []comparator.PoolStats, wall time.Duration) {
+	if wall <= 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Frame pools")
+	fmt.Fprintln(os.Stderr, "===========")
+
+	var maxWait time.Duration
+	for _, s := range stats {
+		var starved float64
+		if s.Gets > 0 {
+			starved = 100 * float64(s.Starved) / float64(s.Gets)
 		}
-		printMetricSummary(name, values)
+		fmt.Fprintf(os.Stderr, "  %-12s waits: %5.1f%%  waited: %10s  "+
+			"peak: %d/%d buffers\n", s.Name, starved,
+			s.GetWait.Round(time.Millisecond), s.HighWater, s.Capacity)
+		maxWait = max(maxWait, s.GetWait)
 	}
 
-	if len(names) > 1 {
-		methods := defaultCorrelationMethods()
-		printCorrelations(scores, names, methods)
+	if float64(maxWait) >= poolWaitBound*float64(wall) {
+		fmt.Fprintln(os.Stderr, "  Readers waited on the metrics for "+
+			"free buffers: metric speed limits throughput.")
+	} else {
+		fmt.Fprintln(os.Stderr, "  Readers rarely waited for free "+
+			"buffers: decoding limits throughput.")
+	}
+}
+
+func printMetricSummary(name string, rawValues []float64,
+	segments []analysis.Segment) {
+	stats, ok := summarize(name, rawValues)
+	if !ok {
+		return
+	}
+
+	title, format := displayName(name), scoreFormat(name)
+	if stats.Direction != "" {
+		title += " (" + stats.Direction + ")"
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, title)
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", len(title)))
+
+	fmt.Fprintf(os.Stderr, "  min     : %s\n", format.FormatValue(stats.Min))
+	fmt.Fprintf(os.Stderr, "  max     : %s\n", format.FormatValue(stats.Max))
+	if stats.Worst != nil {
+		fmt.Fprintf(os.Stderr, "  worst   : %s\n",
+			format.FormatValue(*stats.Worst))
+	}
+	fmt.Fprintf(os.Stderr, "  average : %s\n",
+		format.FormatValue(stats.Average))
+	fmt.Fprintf(os.Stderr, "  median  : %s\n",
+		format.FormatValue(stats.Median))
+	fmt.Fprintf(os.Stderr, "  stddev  : %s\n",
+		format.FormatValue(stats.StdDev))
+
+	for _, s := range segments {
+		fmt.Fprintf(os.Stderr, "  worst %gs: frame %-8d at %s  frames: %-5d "+
+			"p%g: %10s  mean: %10s\n", s.Duration, s.Start,
+			formatTimestamp(s.StartTime), s.Frames,
+			settings.worstSegmentPercentile, format.FormatValue(s.Pooled),
+			format.FormatValue(s.Mean))
 	}
 }
 
-func printMetricSummary(name string, rawValues []float64) {
-	presenter := getPresenter(name)
+// summarize computes the summary statistics of the scores of the named
+// metric. It returns false if there are no scores.
+func summarize(name string, rawValues []float64) (results.Stats, bool) {
+	scale := scoreFormat(name).Scale
 
-	// Transform all values into the space where we want statistics
+	// Transform all values onto the scale they are pooled on
 	values := make([]float64, len(rawValues))
 	for i, v := range rawValues {
-		values[i] = presenter.TransformForStats(v)
+		values[i] = scale.ToPooling(v)
 	}
 
 	n := len(values)
 	if n == 0 {
-		return
+		return results.Stats{}, false
 	}
 
 	sorted := make([]float64, n)
@@ -148,16 +524,30 @@ func printMetricSummary(name string, rawValues []float64) {
 	variance /= float64(n) // population stddev; use n-1 for sample if preferred
 	stddev := math.Sqrt(variance)
 
-	// Output ─ all displayed values go through TransformForDisplay
-	fmt.Fprintln(os.Stderr)
-	fmt.Fprintln(os.Stderr, presenter.DisplayName())
-	fmt.Fprintln(os.Stderr, strings.Repeat("-", len(presenter.DisplayName())))
-
-	fmt.Fprintf(os.Stderr, "  min     : %.6f\n", presenter.TransformForDisplay(min))
-	fmt.Fprintf(os.Stderr, "  max     : %.6f\n", presenter.TransformForDisplay(max))
-	fmt.Fprintf(os.Stderr, "  average : %.6f\n", presenter.TransformForDisplay(avg))
-	fmt.Fprintf(os.Stderr, "  median  : %.6f\n", presenter.TransformForDisplay(median))
-	fmt.Fprintf(os.Stderr, "  stddev  : %.6f\n", presenter.TransformForDisplay(stddev))
+	// All reported values are mapped back to scores. Scales such as JOD
+	// decrease as the pooled value grows, which swaps the extremes.
+	lowest, highest := scale.FromPooling(min), scale.FromPooling(max)
+	if lowest > highest {
+		lowest, highest = highest, lowest
+	}
+	stats := results.Stats{
+		Frames:  n,
+		Min:     lowest,
+		Max:     highest,
+		Average: scale.FromPooling(avg),
+		Median:  scale.FromPooling(median),
+		StdDev:  scale.FromPooling(stddev),
+	}
+
+	worst := stats.Min
+	switch direction := scoreDirection(name); direction {
+	case video.LowerIsBetter:
+		worst = stats.Max
+		fallthrough
+	case video.HigherIsBetter:
+		stats.Direction, stats.Worst = direction.String(), &worst
+	}
+	return stats, true
 }
 
 func defaultCorrelationMethods() []CorrelationMethod {
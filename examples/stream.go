@@ -0,0 +1,46 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// streamedScores is one line of --stream-scores.
+type streamedScores struct {
+	// Index is the position of the frame pair in the per-frame scores and
+	// Frame its source frame number.
+	Index  int                 `json:"index"`
+	Frame  int                 `json:"frame"`
+	Scores map[string]*float64 `json:"scores"`
+}
+
+// scoreStreamer returns a ScoresCallback writing the scores of every frame
+// pair to w as one NDJSON object per line, as they complete. frames maps the
+// pair index to its source frame number, see Comparator.FrameIndices. Scores
+// that are not finite are written as null.
+func scoreStreamer(w io.Writer, frames []int) comparator.ScoresCallback {
+	encoder := json.NewEncoder(w)
+
+	return func(index int, scores map[string]float64) error {
+		line := streamedScores{Index: index, Frame: index,
+			Scores: make(map[string]*float64, len(scores))}
+		if index < len(frames) {
+			line.Frame = frames[index]
+		}
+
+		for name, score := range scores {
+			if math.IsNaN(score) || math.IsInf(score, 0) {
+				line.Scores[name] = nil
+				continue
+			}
+			line.Scores[name] = &score
+		}
+
+		return encoder.Encode(line)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// setSubtitleMask sets the frame mask of comp for --subtitle-mask, if set.
+func setSubtitleMask(comp *comparator.Comparator, reference,
+	distortion video.Source) error {
+	if settings.subtitleMask == "" {
+		return nil
+	}
+	if settings.subtitleBand <= 0 || settings.subtitleBand > 1 {
+		return fmt.Errorf("--subtitle-band must be in (0, 1], got %g",
+			settings.subtitleBand)
+	}
+
+	if settings.subtitleMask == "detect" {
+		detector, err := analysis.NewSubtitleDetector(
+			reference.GetColorProps(), distortion.GetColorProps(),
+			analysis.SubtitleOptions{Band: settings.subtitleBand})
+		if err != nil {
+			return fmt.Errorf("--subtitle-mask: %w", err)
+		}
+		return comp.SetFrameMask(func(_ int, a, b *video.Frame) []video.Rect {
+			return detector.Detect(a, b)
+		})
+	}
+
+	cues, err := analysis.ReadCuesFile(settings.subtitleMask)
+	if err != nil {
+		return fmt.Errorf("--subtitle-mask: %w", err)
+	}
+
+	props := reference.GetColorProps()
+	top := int(math.Floor(float64(props.Height) * (1 - settings.subtitleBand)))
+	band := []video.Rect{{X: 0, Y: top, Width: props.Width,
+		Height: props.Height - top}}
+	frameRate := float64(reference.GetFrameRate())
+
+	return comp.SetFrameMask(func(frame int, _, _ *video.Frame) []video.Rect {
+		if !cues.Covers(float64(frame) / frameRate) {
+			return nil
+		}
+		return band
+	})
+}
@@ -0,0 +1,232 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+// gpuPollInterval is how often the clocks and temperature of the GPU are
+// sampled.
+const gpuPollInterval = 2 * time.Second
+
+// scoredFrames counts the frame pairs scored so far, for the throughput
+// timeline. The progress callbacks of every comparison mode update it.
+var scoredFrames atomic.Int64
+
+// gpuState is what a sample reads from the GPU.
+type gpuState struct {
+	clock, temperature int
+	reasons            []string
+}
+
+// queryGPU returns the state of gpu, as vshipGPU names it, from nvidia-smi
+// or rocm-smi, depending on the backend of vship.
+func queryGPU(ctx context.Context, gpu string) (gpuState, error) {
+	if vship.GetVersion().Backend == vship.BackendCuda {
+		return queryNvidiaGPU(ctx, gpu)
+	}
+	return queryROCmGPU(ctx, gpu)
+}
+
+// vshipGPU returns how nvidia-smi -i or rocm-smi -d name the GPU vship runs
+// on, device 0 of its runtime. The runtime only numbers the GPUs
+// CUDA_VISIBLE_DEVICES or HIP_VISIBLE_DEVICES lets it see, and HIP numbers
+// them in the order of rocm-smi. CUDA puts the fastest GPU first unless
+// CUDA_DEVICE_ORDER is PCI_BUS_ID, the order of nvidia-smi, so without a UUID
+// or that order the first GPU nvidia-smi lists under the name vship reports
+// is taken. Of several identical GPUs that is the first on the bus, which
+// may not be the one CUDA picked; set CUDA_DEVICE_ORDER=PCI_BUS_ID to sample
+// the right one.
+func vshipGPU(ctx context.Context) string {
+	if vship.GetVersion().Backend != vship.BackendCuda {
+		first, _, _ := strings.Cut(os.Getenv("HIP_VISIBLE_DEVICES"), ",")
+		if first = strings.TrimSpace(first); first != "" {
+			return first
+		}
+		return "0"
+	}
+
+	first, _, _ := strings.Cut(os.Getenv("CUDA_VISIBLE_DEVICES"), ",")
+	first = strings.TrimSpace(first)
+	switch {
+	case strings.HasPrefix(first, "GPU-") || strings.HasPrefix(first, "MIG-"):
+		return first
+	case os.Getenv("CUDA_DEVICE_ORDER") == "PCI_BUS_ID":
+		if first != "" {
+			return first
+		}
+		return "0"
+	}
+
+	device, code := vship.GetDeviceInfo(0)
+	if !code.IsNone() {
+		return "0"
+	}
+	output, err := exec.CommandContext(ctx, "nvidia-smi",
+		"--query-gpu=index,name", "--format=csv,noheader").Output()
+	if err != nil {
+		return "0"
+	}
+	for line := range strings.Lines(string(output)) {
+		index, name, _ := strings.Cut(line, ",")
+		if strings.TrimSpace(name) == device.Name {
+			return strings.TrimSpace(index)
+		}
+	}
+	return "0"
+}
+
+// nvidiaThrottleReasons names the bits of nvidia-smi's
+// clocks_throttle_reasons.active that mean the GPU was slowed down. Idle and
+// application clock settings are left out, as they are not throttling.
+var nvidiaThrottleReasons = []struct {
+	bit  uint64
+	name string
+}{
+	{0x4, "power cap"},
+	{0x8, "hardware slowdown"},
+	{0x20, "thermal slowdown"},
+	{0x40, "hardware thermal slowdown"},
+	{0x80, "power brake"},
+}
+
+func queryNvidiaGPU(ctx context.Context, gpu string) (gpuState, error) {
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "-i", gpu,
+		"--query-gpu=clocks.sm,temperature.gpu,"+
+			"clocks_throttle_reasons.active",
+		"--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return gpuState{}, err
+	}
+
+	fields := strings.Split(strings.TrimSpace(string(output)), ",")
+	if len(fields) != 3 {
+		return gpuState{}, fmt.Errorf("unexpected nvidia-smi output %q",
+			output)
+	}
+	var state gpuState
+	if state.clock, err = strconv.Atoi(strings.TrimSpace(
+		fields[0])); err != nil {
+		return gpuState{}, err
+	}
+	if state.temperature, err = strconv.Atoi(strings.TrimSpace(
+		fields[1])); err != nil {
+		return gpuState{}, err
+	}
+	mask, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(
+		fields[2]), "0x"), 16, 64)
+	if err != nil {
+		return gpuState{}, err
+	}
+	for _, reason := range nvidiaThrottleReasons {
+		if mask&reason.bit != 0 {
+			state.reasons = append(state.reasons, reason.name)
+		}
+	}
+	return state, nil
+}
+
+// queryROCmGPU reads the shader clock and edge temperature of gpu from
+// rocm-smi, which does not report why the clocks changed.
+func queryROCmGPU(ctx context.Context, gpu string) (gpuState, error) {
+	output, err := exec.CommandContext(ctx, "rocm-smi", "-d", gpu,
+		"--showclocks", "--showtemp", "--json").Output()
+	if err != nil {
+		return gpuState{}, err
+	}
+
+	var cards map[string]map[string]string
+	if err = json.Unmarshal(output, &cards); err != nil {
+		return gpuState{}, fmt.Errorf("rocm-smi: %w", err)
+	}
+
+	var state gpuState
+	var found [2]bool
+	for _, card := range cards {
+		for key, value := range card {
+			switch {
+			case strings.HasPrefix(key, "sclk clock speed"):
+				// e.g. "(1500Mhz)".
+				value = strings.Trim(value, "()")
+				value = strings.TrimSuffix(strings.ToLower(value), "mhz")
+				state.clock, err = strconv.Atoi(value)
+				found[0] = err == nil
+			case strings.HasPrefix(key, "Temperature (Sensor edge)"):
+				var temperature float64
+				temperature, err = strconv.ParseFloat(value, 64)
+				state.temperature = int(temperature)
+				found[1] = err == nil
+			}
+		}
+		break
+	}
+	if !found[0] || !found[1] {
+		return gpuState{}, fmt.Errorf("unexpected rocm-smi output %q", output)
+	}
+	return state, nil
+}
+
+// pollGPU samples the GPU vship runs on until ctx is done and keeps the
+// timeline. It gives up at the first failed sample, as the tool is then
+// missing or cannot see the GPU.
+func (m *resourceMonitor) pollGPU(ctx context.Context) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(gpuPollInterval)
+	defer ticker.Stop()
+
+	gpu := vshipGPU(ctx)
+	last, lastFrames := time.Now(), scoredFrames.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		state, err := queryGPU(ctx, gpu)
+		if err != nil {
+			return
+		}
+
+		now, frames := time.Now(), scoredFrames.Load()
+		sample := analysis.GPUSample{Time: now.Sub(m.started).Seconds(),
+			FPS:   float64(frames-lastFrames) / now.Sub(last).Seconds(),
+			Clock: state.clock, Temperature: state.temperature,
+			Reasons: state.reasons, Throttled: len(state.reasons) > 0}
+		last, lastFrames = now, frames
+
+		m.mu.Lock()
+		m.timeline = append(m.timeline, sample)
+		m.mu.Unlock()
+	}
+}
+
+// logThrottling warns about every stretch the GPU spent throttled.
+func logThrottling(events []analysis.ThrottleEvent) {
+	for _, event := range events {
+		cause := "clocks dropped"
+		if len(event.Reasons) > 0 {
+			cause = strings.Join(event.Reasons, ", ")
+		}
+		log.Printf("warning: the GPU throttled (%s) from %s to %s, at up to "+
+			"%d°C and down to %d MHz, throughput went from %.1f to %.1f fps. "+
+			"Slowdowns in that stretch come from the GPU, not the "+
+			"comparison", cause, formatTimestamp(event.Start),
+			formatTimestamp(event.End), event.MaxTemperature,
+			event.MinClock, event.FPSBefore, event.FPSDuring)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/schollz/progressbar/v3"
+)
+
+// runTwoPass scores every --two-pass-th frame, then densely scores the
+// regions around the worst of those samples. The returned scores hold the
+// samples and the region frames in frame order, report.frames maps them back
+// to source frames and report.regions summarizes the regions.
+func runTwoPass(ctx context.Context, reference, distortion video.Source,
+	referenceColorSpace, distortionColorSpace *vship.Colorspace) (
+	map[string][]float64, frameReport, error) {
+	if err := checkTwoPassFlags(); err != nil {
+		return nil, frameReport{}, err
+	}
+
+	var metricHandlers []video.Metric
+	for _, metric := range settings.metrics {
+		metricHandler, _, err := createMetricAndWriter(metric,
+			reference.GetColorProps(), distortion.GetColorProps(),
+			referenceColorSpace, distortionColorSpace, settings.frameRate)
+		if err != nil {
+			return nil, frameReport{}, err
+		}
+		metricHandlers = append(metricHandlers, metricHandler)
+	}
+
+	numFrames := reference.GetNumFrames()
+	comp, err := comparator.NewComparator(
+		reference, distortion, metricHandlers, settings.frameThreads,
+		numFrames,
+		comparator.WithMemoryBudget(settings.memoryBudget),
+		comparator.WithSharedSources())
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+	defer comp.Close()
+
+	if err = comp.SetIdentityShortCircuit(settings.skipIdentical); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = comp.SetQuickReject(settings.quickRejectPSNR); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = comp.SetFrameChecks(settings.checkFrames); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = setScoreCache(&comp); err != nil {
+		return nil, frameReport{}, err
+	}
+	if err = setSubtitleMask(&comp, reference, distortion); err != nil {
+		return nil, frameReport{}, err
+	}
+
+	var samples []int
+	for frame := 0; frame < numFrames; frame += settings.twoPassStride {
+		samples = append(samples, frame)
+	}
+
+	coarse, err := runPass(ctx, &comp, samples, "Coarse pass")
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	metric, err := twoPassMetric(coarse)
+	if err != nil {
+		return nil, frameReport{}, err
+	}
+
+	frameRate := float64(reference.GetFrameRate())
+	regions := analysis.ProblemRegions(coarse[metric], samples, numFrames,
+		frameRate, analysis.RegionOptions{
+			Fraction:      settings.twoPassFraction,
+			Radius:        settings.twoPassStride,
+			HigherIsWorse: higherIsWorse(metric),
+		})
+
+	// The samples inside the regions are already scored.
+	dense := slices.DeleteFunc(analysis.RegionFrames(regions),
+		func(frame int) bool {
+			_, sampled := slices.BinarySearch(samples, frame)
+			return sampled
+		})
+
+	fine := map[string][]float64{}
+	if len(dense) > 0 {
+		if err = comp.Reset(reference, distortion, numFrames); err != nil {
+			return nil, frameReport{}, err
+		}
+		if fine, err = runPass(ctx, &comp, dense, "Dense pass"); err != nil {
+			return nil, frameReport{}, err
+		}
+	}
+
+	scores, frames := mergePasses(coarse, samples, fine, dense)
+	analysis.RegionScores(regions, scores[metric], frames,
+		higherIsWorse(metric))
+
+	log.Printf("two-pass scored %d of %d frames, %d in %d regions of "+
+		"%s", len(frames), numFrames, len(dense), len(regions), metric)
+
+	return scores, frameReport{frames: frames, regions: regions,
+		regionMetric: metric}, nil
+}
+
+// checkTwoPassFlags rejects the flags --two-pass cannot honour, as its scores
+// do not cover every frame and come from two runs.
+func checkTwoPassFlags() error {
+	switch {
+	case settings.twoPassStride < 2:
+		return errors.New("--two-pass must sample every second frame or " +
+			"fewer")
+	case len(settings.workers) > 0 || settings.parallelChunks > 1:
+		return errors.New("--two-pass cannot be combined with " +
+			"--parallel-chunks or --workers")
+	case settings.keyFrameMode != "off":
+		return errors.New("--two-pass cannot be combined with " +
+			"--keyframe-mode")
+	case settings.butteraugliDistMapPath != "" ||
+		settings.cvvdpDistMapPath != "":
+		return errors.New("heat map output cannot be combined with " +
+			"--two-pass")
+	case frameAnalysisEnabled():
+		return errors.New("--detect-cadence, --black-freeze, --av-sync, " +
+			"--complexity and --frame-metadata cannot be combined with " +
+			"--two-pass")
+	case settings.patchOptions.Dir != "":
+		return errors.New("--export-patches cannot be combined with " +
+			"--two-pass")
+	case settings.streamScores || len(settings.abortIf) > 0:
+		return errors.New("--stream-scores and --abort-if cannot be " +
+			"combined with --two-pass")
+	}
+	return nil
+}
+
+// runPass compares the given frames with comp, showing a progress bar
+// described by description.
+func runPass(ctx context.Context, comp *comparator.Comparator, frames []int,
+	description string) (map[string][]float64, error) {
+	if err := comp.SetFrameIndices(frames); err != nil {
+		return nil, err
+	}
+
+	bar := progressbar.NewOptions(len(frames),
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+	)
+	comp.SetProgressCallback(func(done, total int) {
+		_ = bar.Add(1)
+		scoredFrames.Add(1)
+	})
+
+	return comp.Run(ctx)
+}
+
+// twoPassMetric returns the score key ranking the samples, --two-pass-metric
+// or else the first key of scores in sorted order.
+func twoPassMetric(scores map[string][]float64) (string, error) {
+	if settings.twoPassMetric == "" {
+		keys := slices.Sorted(maps.Keys(scores))
+		if len(keys) == 0 {
+			return "", errors.New("the coarse pass produced no scores")
+		}
+		return keys[0], nil
+	}
+
+	if _, ok := scores[settings.twoPassMetric]; !ok {
+		return "", fmt.Errorf("--two-pass-metric %q is not a score key of "+
+			"the selected metrics", settings.twoPassMetric)
+	}
+	return settings.twoPassMetric, nil
+}
+
+// mergePasses interleaves the scores of two passes over disjoint, increasing
+// frames into one set of scores in frame order. It also returns the frame of
+// every merged score.
+func mergePasses(a map[string][]float64, aFrames []int,
+	b map[string][]float64, bFrames []int) (map[string][]float64, []int) {
+	frames := make([]int, 0, len(aFrames)+len(bFrames))
+	frames = append(append(frames, aFrames...), bFrames...)
+	slices.Sort(frames)
+
+	merged := make(map[string][]float64, len(a))
+	for key, values := range a {
+		out := make([]float64, 0, len(frames))
+		i, j := 0, 0
+		for len(out) < len(frames) {
+			if j >= len(bFrames) || (i < len(aFrames) &&
+				aFrames[i] < bFrames[j]) {
+				out = append(out, values[i])
+				i++
+				continue
+			}
+			out = append(out, b[key][j])
+			j++
+		}
+		merged[key] = out
+	}
+	return merged, frames
+}
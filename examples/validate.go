@@ -0,0 +1,168 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/metrics"
+	"github.com/GreatValueCreamSoda/gometrics/video/validation"
+	"github.com/schollz/progressbar/v3"
+)
+
+// runValidate runs the selected metrics over every clip of the --mos file and
+// reports how well each one predicts the subjective scores. The report is
+// written to --output if set.
+func runValidate(ctx context.Context) error {
+	if settings.mosPath == "" {
+		return errors.New("validate needs a --mos file")
+	}
+	if settings.butteraugliDistMapPath != "" || settings.cvvdpDistMapPath != "" {
+		return errors.New("heat map output cannot be combined with validate")
+	}
+	if frameAnalysisEnabled() {
+		return errors.New("--detect-cadence, --black-freeze, --av-sync, " +
+			"--complexity and --frame-metadata cannot be combined with " +
+			"validate")
+	}
+	if settings.patchOptions.Dir != "" {
+		return errors.New("--export-patches cannot be combined with validate")
+	}
+	if settings.streamScores || len(settings.abortIf) > 0 {
+		return errors.New("--stream-scores and --abort-if cannot be " +
+			"combined with validate")
+	}
+	if settings.subtitleMask != "" {
+		return errors.New("--subtitle-mask cannot be combined with validate")
+	}
+
+	clips, err := validation.ReadMOSFile(settings.mosPath, settings.datasetDir)
+	if err != nil {
+		return err
+	}
+	for i := range clips {
+		if clips[i].Reference == "" {
+			clips[i].Reference = settings.referenceVideo
+		}
+	}
+
+	bar := progressbar.NewOptions(len(clips),
+		progressbar.OptionSetDescription("Validating clips"),
+		progressbar.OptionShowCount(),
+	)
+
+	report, err := validation.Run(ctx, validation.Options{
+		Clips:    clips,
+		Compare:  compareClip,
+		Progress: func(done, total int) { _ = bar.Set(done) },
+	})
+	if err != nil {
+		return err
+	}
+
+	printValidation(report.Correlations)
+
+	if settings.outputPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settings.outputPath, data, 0o644)
+}
+
+// compareClip compares one dataset clip against its reference with the
+// selected metrics.
+func compareClip(ctx context.Context, referencePath, distortionPath string) (
+	map[string][]float64, error) {
+	reference, distortion, referencePlan, distortionPlan, err := openSources(
+		ctx, referencePath, distortionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var metricHandlers []video.Metric
+	// Until the comparator exists, the sources and metrics are closed here.
+	closeAll := func() {
+		for _, metric := range metricHandlers {
+			metric.Close()
+		}
+		reference.Close()
+		distortion.Close()
+	}
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	frameRate := settings.frameRate
+	if frameRate < 0 {
+		frameRate = reference.GetFrameRate()
+	}
+
+	for _, metric := range settings.metrics {
+		metricHandler, _, err := createMetricAndWriter(metric,
+			reference.GetColorProps(), distortion.GetColorProps(),
+			&referenceColorSpace, &distortionColorSpace, frameRate)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		metricHandlers = append(metricHandlers, metricHandler)
+	}
+
+	numFrames := min(reference.GetNumFrames(), distortion.GetNumFrames())
+	comp, err := comparator.NewComparator(reference, distortion,
+		metricHandlers, settings.frameThreads, numFrames,
+		comparator.WithMemoryBudget(settings.memoryBudget))
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	defer comp.Close()
+
+	if err = comp.SetIdentityShortCircuit(settings.skipIdentical); err != nil {
+		return nil, err
+	}
+	if err = comp.SetQuickReject(settings.quickRejectPSNR); err != nil {
+		return nil, err
+	}
+	if err = comp.SetFrameChecks(settings.checkFrames); err != nil {
+		return nil, err
+	}
+
+	scores, err := comp.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.normalize {
+		metrics.AddNormalizedScores(scores)
+	}
+	return scores, nil
+}
+
+// printValidation prints the agreement of every metric with the subjective
+// scores.
+func printValidation(correlations []validation.Correlation) {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Agreement with subjective scores")
+	fmt.Fprintln(os.Stderr, "================================")
+
+	for _, c := range correlations {
+		fmt.Fprintf(os.Stderr, "  %-24s clips: %5d  PLCC: % .4f  "+
+			"SROCC: % .4f  RMSE: %.4f\n", c.Metric, c.Clips, c.PLCC, c.SROCC,
+			c.RMSE)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build cgo && !nocgo
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/vsbridge"
+)
+
+// runVSBridge serves the selected metrics to VapourSynth scripts on
+// --vs-listen until the process is stopped.
+func runVSBridge() error {
+	server := vsbridge.NewServer(newVSMetrics)
+	defer server.Close()
+
+	log.Printf("vapoursynth bridge listening on %s", settings.vsListen)
+	return http.ListenAndServe(settings.vsListen, server)
+}
+
+// newVSMetrics creates the selected metrics for a session of the VapourSynth
+// bridge. The clips must already be in a format vship reads, as there is no
+// source to convert frames on the CPU.
+func newVSMetrics(reference, distortion *video.ColorProperties,
+	frameRate float32) ([]video.Metric, error) {
+	referencePlan, err := newVSPlan("reference", reference)
+	if err != nil {
+		return nil, err
+	}
+	distortionPlan, err := newVSPlan("distortion", distortion)
+	if err != nil {
+		return nil, err
+	}
+
+	referenceColorSpace, distortionColorSpace, err := newColorSpaces(
+		referencePlan, distortionPlan)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.frameRate > 0 || frameRate <= 0 {
+		frameRate = settings.frameRate
+	}
+
+	var metricHandlers []video.Metric
+	for _, metric := range settings.metrics {
+		metricHandler, _, err := createMetricAndWriter(metric,
+			&referencePlan.Output, &distortionPlan.Output,
+			&referenceColorSpace, &distortionColorSpace, frameRate)
+		if err != nil {
+			for _, created := range metricHandlers {
+				created.Close()
+			}
+			return nil, err
+		}
+		metricHandlers = append(metricHandlers, metricHandler)
+	}
+	return metricHandlers, nil
+}
+
+// newVSPlan returns the color plan of a clip of the VapourSynth bridge.
+func newVSPlan(name string, props *video.ColorProperties) (*vcolor.Plan,
+	error) {
+	plan, err := vcolor.NewPlan(*props, vcolor.VshipBackend,
+		settings.inference)
+	if err != nil {
+		return nil, colorPlanError(name, err)
+	}
+	if plan.NeedsCPU() {
+		return nil, fmt.Errorf("%s: convert the clip in the script, vship "+
+			"cannot read it as is:\n%s", name, plan)
+	}
+	return plan, nil
+}
@@ -5,6 +5,7 @@ go 1.25.5
 require golang.org/x/sync v0.19.0
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/schollz/progressbar/v3 v3.19.0
 	github.com/spf13/pflag v1.0.10
 )
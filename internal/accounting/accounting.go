@@ -0,0 +1,32 @@
+// Package accounting counts the native resources the process holds, such as
+// pinned buffers, metric backend handlers and decoder handles, by kind. The
+// packages allocating them report every allocation and release, so services
+// running many comparisons can check that each one gives back what it took,
+// see video.DebugLeakReport.
+package accounting
+
+import (
+	"maps"
+	"sync"
+)
+
+var (
+	mu     sync.Mutex
+	counts = make(map[string]int64)
+)
+
+// Add changes the count of kind by delta.
+func Add(kind string, delta int64) {
+	mu.Lock()
+	counts[kind] += delta
+	mu.Unlock()
+}
+
+// Snapshot returns the count of every kind that was ever reported, including
+// kinds back at 0.
+func Snapshot() map[string]int64 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return maps.Clone(counts)
+}
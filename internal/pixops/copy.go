@@ -0,0 +1,20 @@
+package pixops
+
+// CopyPlane copies rows rows of rowBytes bytes from src to dst, whose rows
+// start every srcStride and dstStride bytes. Planes with matching strides
+// covering whole rows are copied in one go.
+func CopyPlane(dst []byte, dstStride int, src []byte, srcStride, rowBytes,
+	rows int) {
+	if rows <= 0 {
+		return
+	}
+	if dstStride == srcStride && rowBytes == srcStride {
+		copy(dst[:rows*rowBytes], src[:rows*rowBytes])
+		return
+	}
+
+	for y := range rows {
+		copy(dst[y*dstStride:y*dstStride+rowBytes],
+			src[y*srcStride:y*srcStride+rowBytes])
+	}
+}
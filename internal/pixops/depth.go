@@ -0,0 +1,26 @@
+package pixops
+
+// Narrow converts the 16 bit samples of src to the 8 bit samples of dst,
+// shifting them right by shift bits with rounding and saturating at 255.
+// dst holds len(src)/2 samples.
+func Narrow(dst, src []byte, shift uint) {
+	n := len(src) / 2
+	dst, src = dst[:n], src[:2*n]
+	round := uint32(1) << shift >> 1
+
+	for i := range dst {
+		v := (uint32(src[2*i]) | uint32(src[2*i+1])<<8 + round) >> shift
+		dst[i] = byte(min(v, 255))
+	}
+}
+
+// Widen converts the 8 bit samples of src to the 16 bit samples of dst,
+// shifting them left by shift bits. dst holds 2*len(src) bytes.
+func Widen(dst, src []byte, shift uint) {
+	dst = dst[:2*len(src)]
+
+	for i, s := range src {
+		v := uint16(s) << shift
+		dst[2*i], dst[2*i+1] = byte(v), byte(v>>8)
+	}
+}
@@ -0,0 +1,87 @@
+package pixops
+
+// SAD returns the sum of absolute differences of the 8 bit samples of a and
+// b, over the length of the shorter row.
+func SAD(a, b []byte) uint64 {
+	n := min(len(a), len(b))
+	return sad(a[:n], b[:n])
+}
+
+// SADWide is SAD for rows of 16 bit samples.
+func SADWide(a, b []byte) uint64 {
+	n := min(len(a), len(b)) / 2
+	a, b = a[:2*n], b[:2*n]
+
+	var sum uint64
+	for i := range n {
+		d := int64(uint16(a[2*i])|uint16(a[2*i+1])<<8) -
+			int64(uint16(b[2*i])|uint16(b[2*i+1])<<8)
+		sum += uint64(max(d, -d))
+	}
+	return sum
+}
+
+// SSE returns the sum of squared differences of the 8 bit samples of a and
+// b, over the length of the shorter row.
+func SSE(a, b []byte) uint64 {
+	n := min(len(a), len(b))
+	a, b = a[:n], b[:n]
+
+	// Four independent sums let the loop run without waiting on each add.
+	var s0, s1, s2, s3 uint64
+	i := 0
+	for ; i+4 <= n; i += 4 {
+		d0 := int64(a[i]) - int64(b[i])
+		d1 := int64(a[i+1]) - int64(b[i+1])
+		d2 := int64(a[i+2]) - int64(b[i+2])
+		d3 := int64(a[i+3]) - int64(b[i+3])
+		s0 += uint64(d0 * d0)
+		s1 += uint64(d1 * d1)
+		s2 += uint64(d2 * d2)
+		s3 += uint64(d3 * d3)
+	}
+	for ; i < n; i++ {
+		d := int64(a[i]) - int64(b[i])
+		s0 += uint64(d * d)
+	}
+	return s0 + s1 + s2 + s3
+}
+
+// SSEWide is SSE for rows of 16 bit samples.
+func SSEWide(a, b []byte) uint64 {
+	n := min(len(a), len(b)) / 2
+	a, b = a[:2*n], b[:2*n]
+
+	var sum uint64
+	for i := range n {
+		d := int64(uint16(a[2*i])|uint16(a[2*i+1])<<8) -
+			int64(uint16(b[2*i])|uint16(b[2*i+1])<<8)
+		sum += uint64(d * d)
+	}
+	return sum
+}
+
+// sadGeneric is the portable SAD of two rows of equal length.
+func sadGeneric(a, b []byte) uint64 {
+	b = b[:len(a)]
+
+	var s0, s1, s2, s3 uint64
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += absDiff(a[i], b[i])
+		s1 += absDiff(a[i+1], b[i+1])
+		s2 += absDiff(a[i+2], b[i+2])
+		s3 += absDiff(a[i+3], b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += absDiff(a[i], b[i])
+	}
+	return s0 + s1 + s2 + s3
+}
+
+func absDiff(a, b byte) uint64 {
+	if a > b {
+		return uint64(a - b)
+	}
+	return uint64(b - a)
+}
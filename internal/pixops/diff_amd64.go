@@ -0,0 +1,9 @@
+//go:build !purego
+
+package pixops
+
+// sad returns the SAD of two rows of equal length with PSADBW, 16 samples at
+// a time.
+//
+//go:noescape
+func sad(a, b []byte) uint64
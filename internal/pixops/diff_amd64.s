@@ -0,0 +1,49 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func sad(a, b []byte) uint64
+TEXT ·sad(SB), NOSPLIT, $0-56
+	MOVQ a_base+0(FP), SI
+	MOVQ a_len+8(FP), CX
+	MOVQ b_base+24(FP), DI
+	PXOR X0, X0
+	XORQ AX, AX
+
+blocks:
+	CMPQ CX, $16
+	JB   tail
+	MOVOU (SI), X1
+	MOVOU (DI), X2
+	PSADBW X2, X1
+	PADDQ X1, X0
+	ADDQ $16, SI
+	ADDQ $16, DI
+	SUBQ $16, CX
+	JMP  blocks
+
+tail:
+	TESTQ CX, CX
+	JZ    done
+	MOVBQZX (SI), DX
+	MOVBQZX (DI), BX
+	SUBQ BX, DX
+	MOVQ DX, BX
+	SARQ $63, BX
+	XORQ BX, DX
+	SUBQ BX, DX
+	ADDQ DX, AX
+	INCQ SI
+	INCQ DI
+	DECQ CX
+	JMP  tail
+
+done:
+	// PSADBW leaves one partial sum in each quadword.
+	MOVQ   X0, DX
+	PSHUFD $0x4e, X0, X1
+	MOVQ   X1, BX
+	ADDQ   DX, AX
+	ADDQ   BX, AX
+	MOVQ   AX, ret+48(FP)
+	RET
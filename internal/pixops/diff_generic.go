@@ -0,0 +1,5 @@
+//go:build !amd64 || purego
+
+package pixops
+
+func sad(a, b []byte) uint64 { return sadGeneric(a, b) }
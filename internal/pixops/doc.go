@@ -0,0 +1,11 @@
+// Package pixops implements the sample loops shared by the CPU metrics and
+// preprocessing stages: plane copies, bit depth conversion, 2x2 subsampling
+// and sums of absolute and squared differences.
+//
+// Every function works on rows of native little endian samples, one byte per
+// sample up to 8 bits and two beyond, and leaves bounds checking of the row
+// lengths to its documentation rather than returning errors, as callers size
+// rows from the frame layout once. The hot loops are written so the compiler
+// can drop bounds checks, and SAD uses SSE2 on amd64 unless built with the
+// purego tag.
+package pixops
@@ -0,0 +1,30 @@
+package pixops
+
+// Subsample2x2 writes the rounded mean of every 2x2 block of the 8 bit rows
+// above and below into dst, which holds half as many samples. A trailing odd
+// sample is left out.
+func Subsample2x2(dst, above, below []byte) {
+	n := min(len(above), len(below)) / 2
+	dst, above, below = dst[:n], above[:2*n], below[:2*n]
+
+	for i := range dst {
+		sum := uint(above[2*i]) + uint(above[2*i+1]) + uint(below[2*i]) +
+			uint(below[2*i+1])
+		dst[i] = byte((sum + 2) >> 2)
+	}
+}
+
+// Subsample2x2Wide is Subsample2x2 for rows of 16 bit samples.
+func Subsample2x2Wide(dst, above, below []byte) {
+	n := min(len(above), len(below)) / 4
+	dst, above, below = dst[:2*n], above[:4*n], below[:4*n]
+
+	for i := range n {
+		sum := uint(above[4*i]) | uint(above[4*i+1])<<8
+		sum += uint(above[4*i+2]) | uint(above[4*i+3])<<8
+		sum += uint(below[4*i]) | uint(below[4*i+1])<<8
+		sum += uint(below[4*i+2]) | uint(below[4*i+3])<<8
+		v := (sum + 2) >> 2
+		dst[2*i], dst[2*i+1] = byte(v), byte(v>>8)
+	}
+}
@@ -0,0 +1,140 @@
+package pixops_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/pixops"
+)
+
+// sadReference is the plain loop SAD must match, whether it runs the SSE2
+// assembly or the portable code.
+func sadReference(a, b []byte) uint64 {
+	var sum uint64
+	for i := range min(len(a), len(b)) {
+		sum += uint64(max(int(a[i])-int(b[i]), int(b[i])-int(a[i])))
+	}
+	return sum
+}
+
+func Test_SAD(t *testing.T) {
+	// The samples cover the whole 8 bit range in both directions, so every
+	// lane of PSADBW sees differences up to 255.
+	const size = 80
+	bufA, bufB := make([]byte, size), make([]byte, size)
+	for i := range size {
+		bufA[i] = byte(i * 37)
+		bufB[i] = byte(255 - i*53)
+	}
+
+	for _, n := range []int{0, 1, 2, 3, 7, 15, 16, 17, 31, 32, 33, 63, 64} {
+		for _, offset := range []int{0, 1, 3, 7} {
+			a, b := bufA[offset:offset+n], bufB[size-n-offset:size-offset]
+			name := fmt.Sprintf("n=%d/offset=%d", n, offset)
+			t.Run(name, func(t *testing.T) {
+				got, want := pixops.SAD(a, b), sadReference(a, b)
+				if got != want {
+					t.Errorf("got %d, want %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func Test_SADShorterRow(t *testing.T) {
+	a := []byte{10, 20, 30, 40}
+	b := []byte{13, 15}
+	if got := pixops.SAD(a, b); got != 8 {
+		t.Errorf("got %d, want 8", got)
+	}
+}
+
+func Test_SADExtremes(t *testing.T) {
+	a := bytes.Repeat([]byte{255}, 33)
+	b := make([]byte, 33)
+	if got := pixops.SAD(a, b); got != 33*255 {
+		t.Errorf("got %d, want %d", got, 33*255)
+	}
+	if got := pixops.SAD(b, a); got != 33*255 {
+		t.Errorf("swapped: got %d, want %d", got, 33*255)
+	}
+}
+
+func Test_Narrow(t *testing.T) {
+	// 10 bit samples 0, 1, 2, 513, 1020 and 1023, and 1 extra byte.
+	src := []byte{0, 0, 1, 0, 2, 0, 0x01, 0x02, 0xfc, 0x03, 0xff, 0x03, 9}
+	dst := make([]byte, 6)
+	pixops.Narrow(dst, src, 2)
+
+	// Values round half up: 2>>2 rounds to 1, 1023 to 256 saturates.
+	want := []byte{0, 0, 1, 128, 255, 255}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func Test_NarrowNoShift(t *testing.T) {
+	src := []byte{200, 0, 0, 1}
+	dst := make([]byte, 2)
+	pixops.Narrow(dst, src, 0)
+
+	if want := []byte{200, 255}; !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func Test_Widen(t *testing.T) {
+	src := []byte{0, 1, 128, 255}
+	dst := make([]byte, 8)
+	pixops.Widen(dst, src, 2)
+
+	// 0, 4, 512 and 1020, little endian.
+	want := []byte{0, 0, 4, 0, 0x00, 0x02, 0xfc, 0x03}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func Test_WidenNarrowRoundTrip(t *testing.T) {
+	src := make([]byte, 256)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	wide := make([]byte, 512)
+	narrow := make([]byte, 256)
+
+	for _, shift := range []uint{0, 2, 4, 8} {
+		pixops.Widen(wide, src, shift)
+		pixops.Narrow(narrow, wide, shift)
+		if !bytes.Equal(narrow, src) {
+			t.Errorf("shift %d: round trip changed the samples", shift)
+		}
+	}
+}
+
+func Test_Subsample2x2(t *testing.T) {
+	above := []byte{0, 1, 10, 20, 255, 255, 7}
+	below := []byte{1, 1, 30, 40, 255, 254, 7}
+	dst := make([]byte, 3)
+	pixops.Subsample2x2(dst, above, below)
+
+	// 3/4 rounds to 1, 100/4 is 25, 1019/4 rounds to 255. The trailing odd
+	// sample is left out.
+	if want := []byte{1, 25, 255}; !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
+
+func Test_Subsample2x2Wide(t *testing.T) {
+	// 16 bit samples 1000, 1001 above and 1002, 1003 below, then 0, 0, 0, 1.
+	above := []byte{0xe8, 0x03, 0xe9, 0x03, 0, 0, 0, 0}
+	below := []byte{0xea, 0x03, 0xeb, 0x03, 0, 0, 1, 0}
+	dst := make([]byte, 4)
+	pixops.Subsample2x2Wide(dst, above, below)
+
+	// 4006/4 rounds to 1002, 1/4 to 0.
+	if want := []byte{0xea, 0x03, 0, 0}; !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+}
@@ -0,0 +1,226 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+)
+
+// EventKind names a kind of Event.
+type EventKind string
+
+const (
+	// EventDuplicate is a run of distorted frames repeating the previous
+	// frame while the reference moves on.
+	EventDuplicate EventKind = "duplicate"
+	// EventDrop is a point where the distorted stream skips ahead of the
+	// reference, i.e. frames are missing from it.
+	EventDrop EventKind = "drop"
+	// EventBlack is a run of black frames in one source.
+	EventBlack EventKind = "black"
+	// EventFreeze is a run of frames in one source that all show the same
+	// picture.
+	EventFreeze EventKind = "freeze"
+)
+
+// The sources an Event can be found in.
+const (
+	SourceReference  = "reference"
+	SourceDistortion = "distortion"
+)
+
+// Event is something found in the compared frames that explains a change in
+// scores without being a quality problem of the encode itself.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	// The compared frame the event starts at.
+	Frame int `json:"frame"`
+	// The number of frames the event covers, e.g. duplicated or dropped
+	// frames.
+	Count int `json:"count"`
+	// The source the event was found in, for events that concern a single
+	// source. Empty for events relating the distortion to the reference.
+	Source string `json:"source,omitempty"`
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s of %d frame(s) at frame %d", e.Kind, e.Count,
+		e.Frame)
+	if e.Source != "" {
+		s += " in the " + e.Source
+	}
+	return s
+}
+
+// CadenceOptions configures DetectCadence.
+type CadenceOptions struct {
+	// Signatures closer than this are taken to show the same picture.
+	// Defaults to 0.002.
+	SameThreshold float64
+	// A reference frame differing from the previous one by more than this
+	// counts as motion. Duplicates are only reported during motion, as a
+	// static scene repeats frames legitimately. Defaults to 0.004.
+	MotionThreshold float64
+	// How many frames either side of a distorted frame are searched for its
+	// reference frame. Defaults to 5.
+	Window int
+	// How many frames a new alignment must hold before a drop is reported.
+	// Defaults to 3.
+	MinRun int
+}
+
+func (o *CadenceOptions) setDefaults() {
+	if o.SameThreshold <= 0 {
+		o.SameThreshold = 0.002
+	}
+	if o.MotionThreshold <= 0 {
+		o.MotionThreshold = 0.004
+	}
+	if o.Window < 1 {
+		o.Window = 5
+	}
+	if o.MinRun < 1 {
+		o.MinRun = 3
+	}
+}
+
+// DetectCadence compares the signatures of a reference and a distorted stream,
+// compared frame by frame, and reports runs of duplicated frames and points
+// where frames were dropped. Both slices must cover the same compared frames.
+//
+// A duplicate is a distorted frame showing the same picture as the one before
+// it while the reference moves. A drop is found by aligning every distorted
+// frame with the nearest reference frame showing the same picture: when that
+// alignment jumps ahead and holds, the frames in between are missing.
+func DetectCadence(reference, distortion []Signature,
+	opts CadenceOptions) []Event {
+	opts.setDefaults()
+	numFrames := min(len(reference), len(distortion))
+
+	var events []Event
+	events = append(events, detectDuplicates(reference[:numFrames],
+		distortion[:numFrames], &opts)...)
+	events = append(events, detectDrops(reference[:numFrames],
+		distortion[:numFrames], &opts)...)
+
+	sortEvents(events)
+	return events
+}
+
+func detectDuplicates(reference, distortion []Signature,
+	opts *CadenceOptions) []Event {
+	var events []Event
+	start := -1
+
+	for i := 1; i <= len(distortion); i++ {
+		duplicate := i < len(distortion) &&
+			distortion[i].Distance(&distortion[i-1]) < opts.SameThreshold &&
+			reference[i].Distance(&reference[i-1]) > opts.MotionThreshold
+
+		switch {
+		case duplicate && start < 0:
+			start = i
+		case !duplicate && start >= 0:
+			events = append(events, Event{Kind: EventDuplicate, Frame: start,
+				Count: i - start})
+			start = -1
+		}
+	}
+
+	return events
+}
+
+func detectDrops(reference, distortion []Signature,
+	opts *CadenceOptions) []Event {
+	var events []Event
+	previous := 0
+
+	for i, offset := range videoOffsets(reference, distortion, opts) {
+		if offset > previous {
+			events = append(events, Event{Kind: EventDrop, Frame: i,
+				Count: offset - previous})
+		}
+		previous = offset
+	}
+
+	return events
+}
+
+// VideoOffsets aligns every distorted frame with the reference the same way
+// DetectCadence does and returns, for each compared frame, how many frames the
+// distortion is ahead of the reference. Offsets only change once a new
+// alignment holds for MinRun frames, so single mismatched frames are ignored.
+func VideoOffsets(reference, distortion []Signature,
+	opts CadenceOptions) []int {
+	opts.setDefaults()
+	numFrames := min(len(reference), len(distortion))
+	return videoOffsets(reference[:numFrames], distortion[:numFrames], &opts)
+}
+
+func videoOffsets(reference, distortion []Signature,
+	opts *CadenceOptions) []int {
+	aligned := make([]int, len(distortion))
+	for i := range distortion {
+		aligned[i] = alignFrame(reference, &distortion[i], i, opts)
+	}
+
+	offsets := make([]int, len(aligned))
+	current := 0
+
+	for i := 0; i < len(aligned); i++ {
+		next := aligned[i]
+		if next == current || !holds(aligned[i:], next, opts.MinRun) {
+			offsets[i] = current
+			continue
+		}
+
+		current = next
+		for j := i; j < min(i+opts.MinRun, len(aligned)); j++ {
+			offsets[j] = current
+		}
+		i += opts.MinRun - 1
+	}
+
+	return offsets
+}
+
+// alignFrame returns the offset from index of the reference frame that best
+// matches sig, preferring the smallest offset unless a further one is closer
+// by more than the same-picture threshold.
+func alignFrame(reference []Signature, sig *Signature, index int,
+	opts *CadenceOptions) int {
+	best, bestDistance := 0, sig.Distance(&reference[index])
+
+	for distance := 1; distance <= opts.Window; distance++ {
+		for _, offset := range [2]int{distance, -distance} {
+			j := index + offset
+			if j < 0 || j >= len(reference) {
+				continue
+			}
+			if d := sig.Distance(&reference[j]); d+opts.SameThreshold <
+				bestDistance {
+				best, bestDistance = offset, d
+			}
+		}
+	}
+
+	return best
+}
+
+// holds returns true if the first n offsets, or all of them if fewer remain,
+// equal offset.
+func holds(offsets []int, offset, n int) bool {
+	for _, o := range offsets[:min(n, len(offsets))] {
+		if o != offset {
+			return false
+		}
+	}
+	return true
+}
+
+// sortEvents orders events by frame, keeping the order of events that start
+// on the same frame.
+func sortEvents(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return a.Frame - b.Frame
+	})
+}
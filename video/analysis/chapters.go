@@ -0,0 +1,156 @@
+package analysis
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Chapter is a named span of a video, in seconds from its start.
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// ffprobeChapters is the JSON output of ffprobe -show_chapters -of json.
+type ffprobeChapters struct {
+	Chapters []struct {
+		StartTime string            `json:"start_time"`
+		EndTime   string            `json:"end_time"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"chapters"`
+}
+
+// ReadChapters reads the chapters from the JSON output of
+// ffprobe -show_chapters -of json, ordered by start time. Chapters without a
+// title are named by their number in that order, counting from 1.
+func ReadChapters(r io.Reader) ([]Chapter, error) {
+	var probed ffprobeChapters
+	if err := json.NewDecoder(r).Decode(&probed); err != nil {
+		return nil, fmt.Errorf("malformed ffprobe chapters: %w", err)
+	}
+
+	chapters := make([]Chapter, 0, len(probed.Chapters))
+	for i, c := range probed.Chapters {
+		start, err := strconv.ParseFloat(c.StartTime, 64)
+		if err != nil {
+			return nil, fmt.Errorf("chapter %d: invalid start time %q", i+1,
+				c.StartTime)
+		}
+		end, err := strconv.ParseFloat(c.EndTime, 64)
+		if err != nil {
+			return nil, fmt.Errorf("chapter %d: invalid end time %q", i+1,
+				c.EndTime)
+		}
+
+		chapters = append(chapters, Chapter{Title: c.Tags["title"],
+			Start: start, End: end})
+	}
+
+	slices.SortStableFunc(chapters, func(a, b Chapter) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	for i := range chapters {
+		if chapters[i].Title == "" {
+			chapters[i].Title = fmt.Sprintf("Chapter %d", i+1)
+		}
+	}
+	return chapters, nil
+}
+
+// ProbeChapters runs the ffprobe executable at binary, ffprobe from PATH if
+// empty, on the video at path and returns its chapters, see ReadChapters.
+func ProbeChapters(ctx context.Context, binary, path string) ([]Chapter,
+	error) {
+	if binary == "" {
+		binary = "ffprobe"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "-v", "error", "-show_chapters",
+		"-of", "json", path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if log := strings.TrimSpace(stderr.String()); log != "" {
+			err = fmt.Errorf("%w: %s", err, log)
+		}
+		return nil, fmt.Errorf("ffprobe failed on %s: %w", path, err)
+	}
+
+	return ReadChapters(&stdout)
+}
+
+// ChapterScore summarizes the scores of one chapter.
+type ChapterScore struct {
+	Title string `json:"title"`
+	// First frame of the chapter and its time in seconds.
+	Start     int     `json:"start"`
+	StartTime float64 `json:"start_time"`
+	Frames    int     `json:"frames"`
+	// Scored is the number of frames with a score. Mean and Worst are 0
+	// when no frame was scored.
+	Scored int     `json:"scored"`
+	Mean   float64 `json:"mean"`
+	// Worst is the worst score of any frame of the chapter.
+	Worst float64 `json:"worst"`
+}
+
+// ChapterScores aggregates per-frame scores per chapter. Score i belongs to
+// source frame frames[i], or to frame i if frames is nil, and frames must be
+// increasing. Frame n is taken to be shown at n / frameRate seconds, and
+// chapters are cut off at numFrames. NaN scores are not counted.
+// higherIsWorse is set for metrics scoring distances, such as Butteraugli.
+func ChapterScores(chapters []Chapter, scores []float64, frames []int,
+	numFrames int, frameRate float64, higherIsWorse bool) []ChapterScore {
+	if frameRate <= 0 {
+		return nil
+	}
+	if frames == nil {
+		frames = make([]int, len(scores))
+		for i := range frames {
+			frames[i] = i
+		}
+	}
+
+	summaries := make([]ChapterScore, 0, len(chapters))
+	for _, chapter := range chapters {
+		start := min(int(math.Round(chapter.Start*frameRate)), numFrames)
+		end := max(min(int(math.Round(chapter.End*frameRate)), numFrames),
+			start)
+		summary := ChapterScore{Title: chapter.Title, Start: start,
+			StartTime: chapter.Start, Frames: end - start}
+
+		first, _ := slices.BinarySearch(frames, start)
+		last, _ := slices.BinarySearch(frames, end)
+
+		var sum float64
+		for _, score := range scores[min(first, len(scores)):min(last,
+			len(scores))] {
+			if math.IsNaN(score) {
+				continue
+			}
+			if summary.Scored == 0 || worse(score, summary.Worst,
+				higherIsWorse) {
+				summary.Worst = score
+			}
+			sum += score
+			summary.Scored++
+		}
+		if summary.Scored > 0 {
+			summary.Mean = sum / float64(summary.Scored)
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
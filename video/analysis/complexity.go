@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"math"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// complexitySamples caps how many luma samples per row and column are read
+// when extracting complexity features, so large frames are subsampled.
+const complexitySamples = 512
+
+// Complexity holds the content complexity features of one frame, following
+// the spatial and temporal information of ITU-T P.910. Luma is scaled to 8-bit
+// code values first, so features are comparable across bit depths and ranges.
+//
+// Frames wider or taller than 512 samples are subsampled before filtering,
+// which lowers SI on fine detail compared to a full resolution measurement.
+type Complexity struct {
+	// SI is the standard deviation of the Sobel gradient magnitude of luma.
+	SI float64 `json:"si"`
+	// TI is the standard deviation of the luma difference to the previous
+	// frame. It is 0 for the first frame.
+	TI float64 `json:"ti"`
+	// EdgeDensity is the fraction of samples whose Sobel gradient magnitude
+	// exceeds ComplexityOptions.EdgeThreshold, in [0, 1].
+	EdgeDensity float64 `json:"edge_density"`
+}
+
+// ComplexityOptions configures a ComplexityRecorder.
+type ComplexityOptions struct {
+	// Sobel gradient magnitude, in 8-bit code values, above which a sample
+	// counts as an edge. Defaults to 64.
+	EdgeThreshold float64
+}
+
+func (o *ComplexityOptions) setDefaults() {
+	if o.EdgeThreshold <= 0 {
+		o.EdgeThreshold = 64
+	}
+}
+
+// ComplexityRecorder extracts the Complexity of every compared frame of video
+// A, the reference. Observe matches comparator.FrameObserver and may be called
+// concurrently and out of order for different frames.
+//
+// TI needs the previous frame, so the subsampled luma of a frame is held until
+// its successor has been observed. Only frames in flight are held at a time.
+type ComplexityRecorder struct {
+	signer   *Signer
+	opts     ComplexityOptions
+	features []Complexity
+
+	mu sync.Mutex
+	// luma holds the subsampled luma of frames whose neighbours still need it.
+	luma map[int][]float32
+	// hasTI marks the frames whose TI is known.
+	hasTI []bool
+}
+
+// NewComplexityRecorder returns a ComplexityRecorder for numFrames frames of a
+// source with the given color properties, which must use a planar pixel
+// format. The first plane is taken as luma, for planar RGB that is green.
+func NewComplexityRecorder(props *video.ColorProperties, numFrames int,
+	opts ComplexityOptions) (*ComplexityRecorder, error) {
+	signer, err := NewSigner(props)
+	if err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	r := &ComplexityRecorder{signer: signer, opts: opts,
+		features: make([]Complexity, numFrames),
+		luma:     make(map[int][]float32), hasTI: make([]bool, numFrames)}
+	return r, nil
+}
+
+// Observe extracts the features of the index-th frame of video A.
+func (r *ComplexityRecorder) Observe(index int, a, _ *video.Frame) {
+	if index < 0 || index >= len(r.features) {
+		return
+	}
+
+	width, height, luma := r.sampleLuma(a)
+	si, edges := r.spatial(width, height, luma)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.features[index].SI, r.features[index].EdgeDensity = si, edges
+	r.luma[index] = luma
+
+	if index == 0 {
+		r.hasTI[0] = true
+	} else if previous, ok := r.luma[index-1]; ok {
+		r.features[index].TI = temporal(previous, luma)
+		r.hasTI[index] = true
+	}
+	if next, ok := r.luma[index+1]; ok {
+		r.features[index+1].TI = temporal(luma, next)
+		r.hasTI[index+1] = true
+	}
+
+	// A frame's luma is needed for its own TI and that of its successor.
+	for _, i := range [3]int{index - 1, index, index + 1} {
+		if _, ok := r.luma[i]; ok && r.hasTI[i] &&
+			(i+1 >= len(r.hasTI) || r.hasTI[i+1]) {
+			delete(r.luma, i)
+		}
+	}
+}
+
+// Features returns the recorded features of every frame.
+func (r *ComplexityRecorder) Features() []Complexity {
+	return r.features
+}
+
+// sampleLuma returns the luma of frame in 8-bit code values, subsampled to
+// at most complexitySamples per row and column.
+func (r *ComplexityRecorder) sampleLuma(frame *video.Frame) (width,
+	height int, luma []float32) {
+	s := r.signer
+	plane, stride := frame.PlaneData(0), frame.PlaneLineSize(0)
+	stepX := max((s.width+complexitySamples-1)/complexitySamples, 1)
+	stepY := max((s.height+complexitySamples-1)/complexitySamples, 1)
+	width, height = (s.width+stepX-1)/stepX, (s.height+stepY-1)/stepY
+
+	luma = make([]float32, 0, width*height)
+	for y := 0; y < s.height; y += stepY {
+		for x := 0; x < s.width; x += stepX {
+			var code int
+			if s.wide {
+				offset := y*stride + 2*x
+				code = int(plane[offset]) | int(plane[offset+1])<<8
+			} else {
+				code = int(plane[y*stride+x])
+			}
+			luma = append(luma,
+				float32((float64(code)-s.offset)/s.scale*255))
+		}
+	}
+
+	return width, height, luma
+}
+
+// spatial returns the SI and edge density of luma, filtering the samples
+// that have all eight neighbours.
+func (r *ComplexityRecorder) spatial(width, height int, luma []float32) (si,
+	edgeDensity float64) {
+	if width < 3 || height < 3 {
+		return 0, 0
+	}
+
+	var sum, sumSquares float64
+	var edges, count int
+
+	for y := 1; y < height-1; y++ {
+		above, row, below := luma[(y-1)*width:], luma[y*width:],
+			luma[(y+1)*width:]
+		for x := 1; x < width-1; x++ {
+			gx := float64(above[x+1] + 2*row[x+1] + below[x+1] -
+				above[x-1] - 2*row[x-1] - below[x-1])
+			gy := float64(below[x-1] + 2*below[x] + below[x+1] -
+				above[x-1] - 2*above[x] - above[x+1])
+			magnitude := math.Sqrt(gx*gx + gy*gy)
+
+			sum += magnitude
+			sumSquares += magnitude * magnitude
+			if magnitude > r.opts.EdgeThreshold {
+				edges++
+			}
+			count++
+		}
+	}
+
+	return deviation(sum, sumSquares, count), float64(edges) / float64(count)
+}
+
+// temporal returns the TI of the frame with luma current following previous.
+func temporal(previous, current []float32) float64 {
+	var sum, sumSquares float64
+	for i := range current {
+		d := float64(current[i] - previous[i])
+		sum += d
+		sumSquares += d * d
+	}
+	return deviation(sum, sumSquares, len(current))
+}
+
+// deviation returns the population standard deviation of count values with
+// the given sum and sum of squares.
+func deviation(sum, sumSquares float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	mean := sum / float64(count)
+	return math.Sqrt(max(sumSquares/float64(count)-mean*mean, 0))
+}
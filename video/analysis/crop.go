@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// CropOptions configures DetectCrop.
+type CropOptions struct {
+	// Number of frames sampled, spread evenly over the source. Defaults to
+	// 12.
+	Samples int
+	// Normalized luma a row or column must exceed on average to count as
+	// picture rather than black bar. Defaults to 0.09, about 24 of 255 like
+	// ffmpeg's cropdetect.
+	Limit float64
+}
+
+func (o *CropOptions) setDefaults() {
+	if o.Samples < 1 {
+		o.Samples = 12
+	}
+	if o.Limit <= 0 {
+		o.Limit = 0.09
+	}
+}
+
+// DetectCrop finds the letterbox and pillarbox bars of source, in the spirit
+// of ffmpeg's cropdetect, and returns the rect of its frames holding the
+// picture. It is the union of the picture area of every sampled frame, so a
+// bar is only cropped if it is black in all of them, and frames that are black
+// all over are skipped. The whole frame is returned if no sample has any
+// picture.
+//
+// source must be seekable and is seeked back to its first frame afterwards.
+// Its pixel format must have a luma plane.
+func DetectCrop(source video.Source, opts CropOptions) (video.Rect, error) {
+	opts.setDefaults()
+
+	seekable, ok := video.As[video.SeekableSource](source)
+	if !ok {
+		return video.Rect{}, errors.New("crop detection needs a seekable " +
+			"source")
+	}
+
+	props := source.GetColorProps()
+	luma, err := newLumaReader(props)
+	if err != nil {
+		return video.Rect{}, err
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		return video.Rect{}, err
+	}
+
+	numFrames := source.GetNumFrames()
+	samples := min(opts.Samples, numFrames)
+	x0, y0, x1, y1 := props.Width, props.Height, 0, 0
+
+	for i := range samples {
+		n := (2*i + 1) * numFrames / (2 * samples)
+		if err = seekable.SeekFrame(n); err != nil {
+			return video.Rect{}, err
+		}
+		if err = source.GetFrame(frame); err != nil {
+			return video.Rect{}, fmt.Errorf("frame %d: %w", n, err)
+		}
+
+		picture, ok := luma.picture(&frame, opts.Limit)
+		if !ok {
+			continue
+		}
+		x0, y0 = min(x0, picture.X), min(y0, picture.Y)
+		x1 = max(x1, picture.X+picture.Width)
+		y1 = max(y1, picture.Y+picture.Height)
+	}
+
+	if err = seekable.SeekFrame(0); err != nil {
+		return video.Rect{}, err
+	}
+
+	if x0 >= x1 || y0 >= y1 {
+		return video.Rect{Width: props.Width, Height: props.Height}, nil
+	}
+	return video.Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, nil
+}
+
+// picture returns the bounding rect of the rows and columns of frame whose
+// mean normalized luma exceeds limit, and false if there is none.
+func (r *lumaReader) picture(frame *video.Frame, limit float64) (video.Rect,
+	bool) {
+	threshold := r.black + limit*(r.white-r.black)
+
+	rowSums := make([]float64, r.height)
+	colSums := make([]float64, r.width)
+	for y := range r.height {
+		for x := range r.width {
+			sample := float64(r.sample(frame, x, y))
+			rowSums[y] += sample
+			colSums[x] += sample
+		}
+	}
+
+	first := func(sums []float64, n int) int {
+		for i, sum := range sums {
+			if sum/float64(n) > threshold {
+				return i
+			}
+		}
+		return -1
+	}
+	last := func(sums []float64, n int) int {
+		for i := len(sums) - 1; i >= 0; i-- {
+			if sums[i]/float64(n) > threshold {
+				return i
+			}
+		}
+		return -1
+	}
+
+	y0 := first(rowSums, r.width)
+	if y0 < 0 {
+		return video.Rect{}, false
+	}
+	y1 := last(rowSums, r.width)
+	x0, x1 := first(colSums, r.height), last(colSums, r.height)
+
+	return video.Rect{X: x0, Y: y0, Width: x1 - x0 + 1,
+		Height: y1 - y0 + 1}, true
+}
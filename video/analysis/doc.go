@@ -0,0 +1,30 @@
+// Package analysis inspects the decoded frames of a comparison for timing
+// problems that show up as unexplained score dips, such as duplicated or
+// dropped frames in the distorted stream.
+//
+// Frames are reduced to small luma Signatures while the comparison runs, see
+// Recorder, and analysed once it finishes. EstimateSync combines the video
+// alignment found this way with the audio of both files to measure how far
+// the distorted audio drifts out of sync.
+//
+// ComplexityRecorder extracts the spatial and temporal complexity of the
+// reference in the same pass, so scores can be related to the content, and
+// MetadataRecorder keeps the per-frame metadata of both sources so scores can
+// be split by frame type with GroupScores. GOPScores aggregates scores per
+// group of pictures to find the GOPs an encoder handled worst, WorstSegment
+// finds the seconds of a clip with the worst pooled score for spot checks, and
+// ProblemRegions picks the frames around the worst samples of a sparse pass
+// to score densely in a second one. ReadSceneList and WriteSceneList exchange
+// scene cuts with encoders as x264 QP files or ffprobe output, and
+// ChapterScores aggregates scores per chapter for episodic and film QC.
+// SubtitleDetector and Cues find the burned-in subtitles to mask out of a
+// comparison, see comparator.Comparator.SetFrameMask. DetectCrop finds
+// letterbox and pillarbox bars to crop, and DetectPulldown and
+// CompareFrameCounts tell telecined streams and frame rate mismatches apart.
+// ReadFrameMap reads the frame pairs of an edited distortion and its master,
+// for sources.Remap, and RankReferences picks the master a distortion was
+// encoded from out of several candidates.
+// LoudnessMeter measures the EBU R128 loudness and clipping of audio streams
+// for QC beyond the video, and ThrottleEvents finds the stretches of a run
+// the GPU spent throttled.
+package analysis
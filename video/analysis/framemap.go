@@ -0,0 +1,203 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// FrameMap maps reference frames to the distorted frames showing them, for
+// distortions that were edited or trimmed relative to the reference.
+type FrameMap struct {
+	// Reference and Distortion hold the mapped frame pairs in increasing
+	// reference order.
+	Reference, Distortion []int
+}
+
+// ReadFrameMap reads a frame map from r, one mapping per line:
+//
+//	<reference frame> <distorted frame>
+//	<first>-<last> <first>-<last>
+//	<reference frame> -
+//
+// Frames are separated by spaces, a comma or "->". A range maps the
+// reference frames first to last to as many distorted frames, and a "-"
+// marks reference frames the distortion lacks, which is the same as leaving
+// them out. Text after a '#' is a comment. Every reference frame may be
+// mapped once, while a distorted frame may show several reference frames.
+func ReadFrameMap(r io.Reader) (FrameMap, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return FrameMap{}, err
+	}
+
+	var pairs [][2]int
+	lineNumber := 0
+	for line := range strings.Lines(string(data)) {
+		lineNumber++
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.NewReplacer("->", " ", ",", " ").Replace(line)
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return FrameMap{}, fmt.Errorf("line %d: expected <reference> "+
+				"<distortion>, got %q", lineNumber, strings.TrimSpace(line))
+		}
+
+		reference, err := parseFrameRange(fields[0])
+		if err != nil {
+			return FrameMap{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if fields[1] == "-" {
+			continue
+		}
+		distortion, err := parseFrameRange(fields[1])
+		if err != nil {
+			return FrameMap{}, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if reference.Count != distortion.Count {
+			return FrameMap{}, fmt.Errorf("line %d: maps %d reference "+
+				"frames to %d distorted frames", lineNumber, reference.Count,
+				distortion.Count)
+		}
+
+		for i := range reference.Count {
+			pairs = append(pairs, [2]int{reference.Start + i,
+				distortion.Start + i})
+		}
+	}
+
+	slices.SortFunc(pairs, func(a, b [2]int) int { return a[0] - b[0] })
+
+	m := FrameMap{Reference: make([]int, len(pairs)),
+		Distortion: make([]int, len(pairs))}
+	for i, pair := range pairs {
+		if i > 0 && pair[0] == pairs[i-1][0] {
+			return FrameMap{}, fmt.Errorf("reference frame %d is mapped "+
+				"more than once", pair[0])
+		}
+		m.Reference[i], m.Distortion[i] = pair[0], pair[1]
+	}
+	return m, nil
+}
+
+// ReadFrameMapFile reads the frame map at path, see ReadFrameMap.
+func ReadFrameMapFile(path string) (FrameMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FrameMap{}, err
+	}
+	defer file.Close()
+
+	return ReadFrameMap(file)
+}
+
+// parseFrameRange parses the inclusive range "<first>-<last>" or a single
+// frame number.
+func parseFrameRange(text string) (video.FrameRange, error) {
+	first, last, isRange := strings.Cut(text, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return video.FrameRange{}, fmt.Errorf("invalid frame number %q",
+			text)
+	}
+	if !isRange {
+		return video.FrameRange{Start: start, Count: 1}, nil
+	}
+
+	end, err := strconv.Atoi(last)
+	if err != nil || end < start {
+		return video.FrameRange{}, fmt.Errorf("invalid frame range %q", text)
+	}
+	return video.FrameRange{Start: start, Count: end - start + 1}, nil
+}
+
+// OffsetFrameMap returns the map pairing the frames of a reference and
+// distortion with the given frame counts whose first frames are shown offset
+// seconds apart, positive if the distortion starts later. The frames one
+// video shows before the other starts are left out, as are those past the end
+// of the shorter. The offset is rounded to whole frames at frameRate, and it
+// returns an error if no frames remain to pair.
+func OffsetFrameMap(referenceFrames, distortionFrames int, offset,
+	frameRate float64) (FrameMap, error) {
+	if frameRate <= 0 {
+		return FrameMap{}, fmt.Errorf("offsetting frames needs a positive "+
+			"frame rate, got %g", frameRate)
+	}
+
+	var referenceStart, distortionStart int
+	if shift := int(math.Round(offset * frameRate)); shift > 0 {
+		referenceStart = shift
+	} else {
+		distortionStart = -shift
+	}
+
+	count := min(referenceFrames-referenceStart,
+		distortionFrames-distortionStart)
+	if count <= 0 {
+		return FrameMap{}, fmt.Errorf("a %gs offset leaves no frames of the "+
+			"%d frame reference and %d frame distortion to pair", offset,
+			referenceFrames, distortionFrames)
+	}
+
+	m := FrameMap{Reference: make([]int, count),
+		Distortion: make([]int, count)}
+	for i := range count {
+		m.Reference[i] = referenceStart + i
+		m.Distortion[i] = distortionStart + i
+	}
+	return m, nil
+}
+
+// Check returns an error if the map points past the last frame of a
+// reference or distortion with the given frame counts, or maps no frames.
+func (m FrameMap) Check(referenceFrames, distortionFrames int) error {
+	if len(m.Reference) == 0 {
+		return errors.New("the frame map maps no frames")
+	}
+	if last := m.Reference[len(m.Reference)-1]; last >= referenceFrames {
+		return fmt.Errorf("the frame map maps reference frame %d, the "+
+			"reference has %d frames", last, referenceFrames)
+	}
+	if last := slices.Max(m.Distortion); last >= distortionFrames {
+		return fmt.Errorf("the frame map maps distorted frame %d, the "+
+			"distortion has %d frames", last, distortionFrames)
+	}
+	return nil
+}
+
+// Unmapped returns the frames of a reference and distortion with the given
+// frame counts that the map leaves out, in increasing order.
+func (m FrameMap) Unmapped(referenceFrames, distortionFrames int) (
+	reference, distortion []int) {
+	return missingFrames(m.Reference, referenceFrames),
+		missingFrames(m.Distortion, distortionFrames)
+}
+
+// missingFrames returns the frames below numFrames not in frames.
+func missingFrames(frames []int, numFrames int) []int {
+	mapped := make([]bool, numFrames)
+	for _, frame := range frames {
+		if frame < numFrames {
+			mapped[frame] = true
+		}
+	}
+
+	var missing []int
+	for frame, ok := range mapped {
+		if !ok {
+			missing = append(missing, frame)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// GOP summarizes the scores of one group of pictures, the frames from a
+// keyframe up to the next.
+type GOP struct {
+	// First frame of the GOP and its time in seconds.
+	Start     int     `json:"start"`
+	StartTime float64 `json:"start_time"`
+	Frames    int     `json:"frames"`
+	// Scored is the number of frames with a score. Mean and Worst are 0
+	// when no frame was scored.
+	Scored int     `json:"scored"`
+	Mean   float64 `json:"mean"`
+	// Worst is the worst score of any frame of the GOP.
+	Worst float64 `json:"worst"`
+}
+
+// GOPScores splits per-frame scores, where score i belongs to frame i, into
+// GOPs starting at keyFrames, which must be increasing. Frames before the
+// first keyframe form a GOP of their own. NaN scores, e.g. of frames left
+// unscored, are not counted. higherIsWorse is set for metrics scoring distances, such as
+// Butteraugli.
+func GOPScores(scores []float64, keyFrames []int, frameRate float64,
+	higherIsWorse bool) []GOP {
+	starts := []int{0}
+	for _, keyFrame := range keyFrames {
+		if keyFrame > starts[len(starts)-1] && keyFrame < len(scores) {
+			starts = append(starts, keyFrame)
+		}
+	}
+
+	gops := make([]GOP, 0, len(starts))
+	for i, start := range starts {
+		end := len(scores)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if start >= end {
+			continue
+		}
+
+		gop := GOP{Start: start, Frames: end - start}
+		if frameRate > 0 {
+			gop.StartTime = float64(start) / frameRate
+		}
+
+		var sum float64
+		for _, score := range scores[start:end] {
+			if math.IsNaN(score) {
+				continue
+			}
+			if gop.Scored == 0 || worse(score, gop.Worst, higherIsWorse) {
+				gop.Worst = score
+			}
+			sum += score
+			gop.Scored++
+		}
+		if gop.Scored > 0 {
+			gop.Mean = sum / float64(gop.Scored)
+		}
+
+		gops = append(gops, gop)
+	}
+
+	return gops
+}
+
+// WorstGOPs returns up to n GOPs with the worst mean score, worst first. GOPs
+// without scored frames are left out.
+func WorstGOPs(gops []GOP, n int, higherIsWorse bool) []GOP {
+	worst := slices.DeleteFunc(slices.Clone(gops), func(g GOP) bool {
+		return g.Scored == 0
+	})
+
+	slices.SortStableFunc(worst, func(a, b GOP) int {
+		if higherIsWorse {
+			return cmp.Compare(b.Mean, a.Mean)
+		}
+		return cmp.Compare(a.Mean, b.Mean)
+	})
+
+	return worst[:min(n, len(worst))]
+}
+
+func worse(a, b float64, higherIsWorse bool) bool {
+	if higherIsWorse {
+		return a > b
+	}
+	return a < b
+}
@@ -0,0 +1,412 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// Levels of silence, which has no finite loudness or peak. Loudness is
+// reported at the absolute gate of EBU R128 and peaks at silencePeak.
+const (
+	absoluteGate = -70.0
+	silencePeak  = -144.0
+)
+
+// LoudnessOptions configures a LoudnessMeter.
+type LoudnessOptions struct {
+	// The level relative to full scale at or above which a sample counts as
+	// clipped. Defaults to 32767/32768, the largest positive 16-bit sample.
+	ClipLevel float64
+	// The number of consecutive clipped samples of a channel that make a
+	// clipping event. Defaults to 3, as lone full scale samples are common
+	// in mastered audio.
+	ClipRun int
+}
+
+func (o *LoudnessOptions) setDefaults() {
+	if o.ClipLevel <= 0 {
+		o.ClipLevel = 32767.0 / 32768
+	}
+	if o.ClipRun < 1 {
+		o.ClipRun = 3
+	}
+}
+
+// Loudness is the EBU R128 loudness and the clipping of an audio stream.
+// Loudness values are in LUFS and peaks in dB relative to full scale.
+type Loudness struct {
+	// The gated loudness of the whole stream.
+	Integrated float64 `json:"integrated"`
+	// The loudness range in LU, see EBU Tech 3342.
+	Range float64 `json:"range"`
+	// The loudest 400 ms and 3 s windows.
+	MaxMomentary float64 `json:"max_momentary"`
+	MaxShortTerm float64 `json:"max_short_term"`
+	// The largest sample, and the largest level between samples estimated
+	// by 4x oversampling, which exceeds 0 dBTP when a lossy encode or a DAC
+	// clips.
+	SamplePeak float64 `json:"sample_peak"`
+	TruePeak   float64 `json:"true_peak"`
+	// The number of clipped samples, and of runs of
+	// LoudnessOptions.ClipRun or more of them in a channel.
+	ClippedSamples int `json:"clipped_samples"`
+	ClipEvents     int `json:"clip_events"`
+	// The length of the stream in seconds.
+	Duration float64 `json:"duration"`
+}
+
+// LoudnessDelta is how the loudness of a distorted stream differs from its
+// reference, distortion minus reference.
+type LoudnessDelta struct {
+	Integrated float64 `json:"integrated"`
+	Range      float64 `json:"range"`
+	TruePeak   float64 `json:"true_peak"`
+}
+
+// CompareLoudness returns how distortion differs from reference.
+func CompareLoudness(reference, distortion Loudness) LoudnessDelta {
+	return LoudnessDelta{
+		Integrated: distortion.Integrated - reference.Integrated,
+		Range:      distortion.Range - reference.Range,
+		TruePeak:   distortion.TruePeak - reference.TruePeak,
+	}
+}
+
+// StreamLoudness pairs the loudness of an audio stream of the reference with
+// that of the distortion, by their order among the audio tracks of each file.
+type StreamLoudness struct {
+	Stream int `json:"stream"`
+	// nil if the file has fewer audio streams.
+	Reference  *Loudness `json:"reference,omitempty"`
+	Distortion *Loudness `json:"distortion,omitempty"`
+	// Set when both files have the stream.
+	Delta *LoudnessDelta `json:"delta,omitempty"`
+}
+
+// PairLoudness pairs the loudness of the audio streams of the reference and
+// the distortion.
+func PairLoudness(reference, distortion []Loudness) []StreamLoudness {
+	pairs := make([]StreamLoudness, max(len(reference), len(distortion)))
+	for i := range pairs {
+		pairs[i].Stream = i
+		if i < len(reference) {
+			pairs[i].Reference = &reference[i]
+		}
+		if i < len(distortion) {
+			pairs[i].Distortion = &distortion[i]
+		}
+		if pairs[i].Reference != nil && pairs[i].Distortion != nil {
+			delta := CompareLoudness(reference[i], distortion[i])
+			pairs[i].Delta = &delta
+		}
+	}
+	return pairs
+}
+
+// ChannelWeight returns the ITU-R BS.1770 weight of the channel with the
+// given FFmpeg name: 0 for LFE, 1.41 for surround channels behind or beside
+// the listener and 1 for the rest, including unknown channels.
+func ChannelWeight(name string) float64 {
+	switch name {
+	case "LFE", "LFE2":
+		return 0
+	case "BL", "BR", "BC", "SL", "SR", "TBL", "TBC", "TBR":
+		return 1.41
+	default:
+		return 1
+	}
+}
+
+// biquad is a second order IIR filter in direct form II transposed.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two stages of the BS.1770 K-weighting filter for the
+// sample rate: a high shelf modelling the head and a high pass. The
+// coefficients are derived from the analog prototype like libebur128 does, so
+// they match the 48 kHz ones of the standard.
+func kWeighting(sampleRate float64) [2]biquad {
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / sampleRate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / sampleRate)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+
+	return [2]biquad{shelf, highPass}
+}
+
+// Oversampling of the true peak estimate, and taps of each phase of its
+// interpolation filter.
+const (
+	truePeakFactor = 4
+	truePeakTaps   = 12
+)
+
+// truePeakFilter returns the polyphase interpolation filter of the true peak
+// estimate, a Hann windowed sinc: phase p holds the taps producing the value
+// p/truePeakFactor samples after the newest one in the history.
+func truePeakFilter() [truePeakFactor][truePeakTaps]float64 {
+	var phases [truePeakFactor][truePeakTaps]float64
+	const length = truePeakFactor * truePeakTaps
+	for n := range length {
+		t := float64(n) - float64(length-1)/2
+		x := t / truePeakFactor
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(n)+0.5)/length)
+		phases[n%truePeakFactor][n/truePeakFactor] = sinc * window
+	}
+	return phases
+}
+
+// loudnessChannel is the filter state of one channel.
+type loudnessChannel struct {
+	weight float64
+	stages [2]biquad
+	// history holds the newest samples for the true peak filter, newest
+	// last.
+	history [truePeakTaps]float64
+	// run is the number of consecutive clipped samples so far.
+	run int
+}
+
+// LoudnessMeter measures the EBU R128 loudness, true peak and clipping of an
+// audio stream, see ITU-R BS.1770-4 and EBU Tech 3341 and 3342.
+type LoudnessMeter struct {
+	opts       LoudnessOptions
+	sampleRate float64
+	channels   []loudnessChannel
+	truePeak   [truePeakFactor][truePeakTaps]float64
+
+	// blocks holds the mean weighted energy of every complete 100 ms block.
+	blocks      []float64
+	blockLength int
+	// energy and filled are the sums of the block being filled.
+	energy float64
+	filled int
+
+	samples             int
+	samplePeak, peak    float64
+	clipped, clipEvents int
+}
+
+// NewLoudnessMeter returns a LoudnessMeter for a stream with the given sample
+// rate and number of channels. channels names them as in FFmpeg, to weigh
+// them with ChannelWeight, and nil weighs every channel as a front channel.
+func NewLoudnessMeter(sampleRate, numChannels int, channels []string,
+	opts LoudnessOptions) (*LoudnessMeter, error) {
+	if sampleRate <= 0 || numChannels <= 0 {
+		return nil, errors.New("loudness needs a sample rate and channels")
+	}
+	if channels != nil && len(channels) != numChannels {
+		return nil, errors.New("channel names do not match the channel count")
+	}
+	opts.setDefaults()
+
+	m := &LoudnessMeter{opts: opts, sampleRate: float64(sampleRate),
+		channels:    make([]loudnessChannel, numChannels),
+		truePeak:    truePeakFilter(),
+		blockLength: max(sampleRate/10, 1)}
+	for i := range m.channels {
+		m.channels[i].weight = 1
+		if channels != nil {
+			m.channels[i].weight = ChannelWeight(channels[i])
+		}
+		m.channels[i].stages = kWeighting(m.sampleRate)
+	}
+	return m, nil
+}
+
+// Write adds interleaved samples normalized to [-1, 1]. A trailing partial
+// sample of all channels is ignored.
+func (m *LoudnessMeter) Write(samples []float64) {
+	numChannels := len(m.channels)
+	for start := 0; start+numChannels <= len(samples); start += numChannels {
+		for i := range m.channels {
+			m.addSample(&m.channels[i], samples[start+i])
+		}
+
+		m.samples++
+		m.filled++
+		if m.filled == m.blockLength {
+			m.blocks = append(m.blocks, m.energy/float64(m.blockLength))
+			m.energy, m.filled = 0, 0
+		}
+	}
+}
+
+func (m *LoudnessMeter) addSample(c *loudnessChannel, x float64) {
+	y := c.stages[1].filter(c.stages[0].filter(x))
+	m.energy += c.weight * y * y
+
+	level := math.Abs(x)
+	m.samplePeak = max(m.samplePeak, level)
+	if level >= m.opts.ClipLevel {
+		m.clipped++
+		c.run++
+		if c.run == m.opts.ClipRun {
+			m.clipEvents++
+		}
+	} else {
+		c.run = 0
+	}
+
+	copy(c.history[:], c.history[1:])
+	c.history[truePeakTaps-1] = x
+	for _, taps := range m.truePeak {
+		var v float64
+		for k, tap := range taps {
+			v += tap * c.history[k]
+		}
+		m.peak = max(m.peak, math.Abs(v))
+	}
+}
+
+// Loudness returns the loudness of the samples written so far.
+func (m *LoudnessMeter) Loudness() Loudness {
+	// Momentary loudness uses 400 ms windows and short-term loudness 3 s
+	// windows, both moving in steps of one block.
+	momentary := windowEnergies(m.blocks, 4)
+	shortTerm := windowEnergies(m.blocks, 30)
+
+	return Loudness{
+		Integrated:     gatedLoudness(momentary, -10),
+		Range:          loudnessRange(shortTerm),
+		MaxMomentary:   maxLoudness(momentary),
+		MaxShortTerm:   maxLoudness(shortTerm),
+		SamplePeak:     peakLevel(m.samplePeak),
+		TruePeak:       peakLevel(max(m.peak, m.samplePeak)),
+		ClippedSamples: m.clipped,
+		ClipEvents:     m.clipEvents,
+		Duration:       float64(m.samples) / m.sampleRate,
+	}
+}
+
+// windowEnergies returns the mean energy of every run of size consecutive
+// blocks.
+func windowEnergies(blocks []float64, size int) []float64 {
+	if len(blocks) < size {
+		return nil
+	}
+
+	energies := make([]float64, 0, len(blocks)-size+1)
+	var sum float64
+	for i, energy := range blocks {
+		sum += energy
+		if i >= size {
+			sum -= blocks[i-size]
+		}
+		if i >= size-1 {
+			energies = append(energies, max(sum, 0)/float64(size))
+		}
+	}
+	return energies
+}
+
+// energyLoudness returns the loudness of a mean weighted energy.
+func energyLoudness(energy float64) float64 {
+	if energy <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// gatedWindows returns the energies of the windows above the absolute gate
+// and the relative gate, which is relative LU below the loudness of those
+// above the absolute one.
+func gatedWindows(energies []float64, relative float64) []float64 {
+	var loud []float64
+	var sum float64
+	for _, energy := range energies {
+		if energyLoudness(energy) > absoluteGate {
+			loud = append(loud, energy)
+			sum += energy
+		}
+	}
+	if len(loud) == 0 {
+		return nil
+	}
+
+	gate := energyLoudness(sum/float64(len(loud))) + relative
+	return slices.DeleteFunc(loud, func(energy float64) bool {
+		return energyLoudness(energy) <= gate
+	})
+}
+
+// gatedLoudness returns the loudness of the windows passing both gates, see
+// gatedWindows.
+func gatedLoudness(energies []float64, relative float64) float64 {
+	gated := gatedWindows(energies, relative)
+	if len(gated) == 0 {
+		return absoluteGate
+	}
+
+	var sum float64
+	for _, energy := range gated {
+		sum += energy
+	}
+	return energyLoudness(sum / float64(len(gated)))
+}
+
+// loudnessRange returns the spread between the 10th and 95th percentile of
+// the gated short-term loudness, as EBU Tech 3342 defines it.
+func loudnessRange(shortTerm []float64) float64 {
+	gated := gatedWindows(shortTerm, -20)
+	if len(gated) == 0 {
+		return 0
+	}
+
+	levels := make([]float64, len(gated))
+	for i, energy := range gated {
+		levels[i] = energyLoudness(energy)
+	}
+	slices.Sort(levels)
+
+	at := func(p float64) float64 {
+		return levels[int(math.Round(p*float64(len(levels)-1)))]
+	}
+	return at(0.95) - at(0.10)
+}
+
+// maxLoudness returns the loudness of the loudest window, at least the
+// absolute gate.
+func maxLoudness(energies []float64) float64 {
+	loudest := absoluteGate
+	for _, energy := range energies {
+		loudest = max(loudest, energyLoudness(energy))
+	}
+	return loudest
+}
+
+// peakLevel returns a peak in dB relative to full scale.
+func peakLevel(peak float64) float64 {
+	if peak <= 0 {
+		return silencePeak
+	}
+	return max(20*math.Log10(peak), silencePeak)
+}
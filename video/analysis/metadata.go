@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// MetadataRecorder keeps a copy of the metadata of every compared frame of
+// both sources. Observe matches comparator.FrameObserver and may be called
+// concurrently for different frames.
+type MetadataRecorder struct {
+	a, b []video.FrameMetadata
+}
+
+// NewMetadataRecorder returns a MetadataRecorder for numFrames frame pairs.
+func NewMetadataRecorder(numFrames int) *MetadataRecorder {
+	return &MetadataRecorder{a: make([]video.FrameMetadata, numFrames),
+		b: make([]video.FrameMetadata, numFrames)}
+}
+
+// Observe records the metadata of the index-th frame pair.
+func (r *MetadataRecorder) Observe(index int, a, b *video.Frame) {
+	if index < 0 || index >= len(r.a) {
+		return
+	}
+	r.a[index] = maps.Clone(a.Metadata())
+	r.b[index] = maps.Clone(b.Metadata())
+}
+
+// Metadata returns the recorded metadata of video A and B.
+func (r *MetadataRecorder) Metadata() (a, b []video.FrameMetadata) {
+	return r.a, r.b
+}
+
+// GroupScores splits per-frame scores by the value of a metadata key, e.g.
+// video.MetaPictType to compare I, P and B frames. Frames without the key are
+// left out. scores and metadata are indexed alike.
+func GroupScores(scores []float64, metadata []video.FrameMetadata,
+	key string) map[string][]float64 {
+	groups := make(map[string][]float64)
+
+	for i, score := range scores {
+		if i >= len(metadata) {
+			break
+		}
+		value, ok := metadata[i][key]
+		if !ok {
+			continue
+		}
+		group := fmt.Sprint(value)
+		groups[group] = append(groups[group], score)
+	}
+
+	return groups
+}
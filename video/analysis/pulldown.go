@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// Pulldown summarizes the repeat field flags of a stream, see
+// video.FieldRepeatSource.
+type Pulldown struct {
+	// The number of coded frames.
+	Frames int
+	// The number of fields they are displayed for.
+	Fields int
+	// The number of frames displayed for more than two fields.
+	Repeated int
+}
+
+// DetectPulldown returns the Pulldown of a stream with the given repeat field
+// flags.
+func DetectPulldown(repeatFields []int) Pulldown {
+	p := Pulldown{Frames: len(repeatFields)}
+	for _, repeat := range repeatFields {
+		p.Fields += 2 + max(repeat, 0)
+		if repeat > 0 {
+			p.Repeated++
+		}
+	}
+	return p
+}
+
+// DisplayRatio returns how many frames are displayed per coded frame: 1 for
+// progressive streams and 1.25 for soft 3:2 pulldown.
+func (p Pulldown) DisplayRatio() float64 {
+	if p.Frames == 0 {
+		return 1
+	}
+	return float64(p.Fields) / float64(2*p.Frames)
+}
+
+// Soft32 reports whether the stream is soft telecined with 3:2 pulldown,
+// i.e. displays 5 frames for every 4 it codes.
+func (p Pulldown) Soft32() bool {
+	return math.Abs(p.DisplayRatio()-1.25) < 0.01
+}
+
+func (p Pulldown) String() string {
+	switch {
+	case p.Repeated == 0:
+		return "none"
+	case p.Soft32():
+		return fmt.Sprintf("soft 3:2, %d of %d frames repeat a field",
+			p.Repeated, p.Frames)
+	default:
+		return fmt.Sprintf("%d of %d frames repeat a field", p.Repeated,
+			p.Frames)
+	}
+}
+
+// FrameRateMismatch tells how the frames of a distorted stream relate to
+// those of its reference, judged by their frame counts as both show the same
+// content.
+type FrameRateMismatch int
+
+const (
+	// FrameRatesMatch means every distorted frame shows one reference frame.
+	FrameRatesMatch FrameRateMismatch = iota
+	// DistortionTelecined means the distortion has 5 frames for every 4 of
+	// the reference, as hard 3:2 pulldown of a 23.976 fps reference to
+	// 29.97 fps gives.
+	DistortionTelecined
+	// ReferenceTelecined is DistortionTelecined with the roles swapped, as
+	// for an inverse telecined encode of a telecined reference.
+	ReferenceTelecined
+	// FrameRatesDiffer means the frame counts differ by any other ratio.
+	FrameRatesDiffer
+)
+
+func (m FrameRateMismatch) String() string {
+	switch m {
+	case FrameRatesMatch:
+		return "frame rates match"
+	case DistortionTelecined:
+		return "distortion is telecined"
+	case ReferenceTelecined:
+		return "reference is telecined"
+	default:
+		return "frame rates differ"
+	}
+}
+
+// CompareFrameCounts returns the FrameRateMismatch of a reference and
+// distortion with the given frame counts. Counts within 0.5%, or one frame,
+// of the expected ratio are accepted, as encodes often trim or pad a few
+// frames at the ends.
+func CompareFrameCounts(reference, distortion int) FrameRateMismatch {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= max(1, 0.005*b)
+	}
+
+	a, b := float64(reference), float64(distortion)
+	switch {
+	case near(b, a):
+		return FrameRatesMatch
+	case near(b, a*5/4):
+		return DistortionTelecined
+	case near(a, b*5/4):
+		return ReferenceTelecined
+	default:
+		return FrameRatesDiffer
+	}
+}
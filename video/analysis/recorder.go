@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Recorder stores the signature of every compared frame of both sources.
+// Observe matches comparator.FrameObserver and may be called concurrently for
+// different frames.
+type Recorder struct {
+	signerA, signerB *Signer
+	a, b             []Signature
+}
+
+// NewRecorder returns a Recorder for numFrames frame pairs of sources with
+// the given color properties.
+func NewRecorder(a, b *video.ColorProperties, numFrames int) (*Recorder,
+	error) {
+	signerA, err := NewSigner(a)
+	if err != nil {
+		return nil, err
+	}
+
+	signerB, err := NewSigner(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{signerA: signerA, signerB: signerB,
+		a: make([]Signature, numFrames), b: make([]Signature, numFrames)}, nil
+}
+
+// Observe records the signatures of the index-th frame pair.
+func (r *Recorder) Observe(index int, a, b *video.Frame) {
+	if index < 0 || index >= len(r.a) {
+		return
+	}
+	r.a[index] = r.signerA.Sign(a)
+	r.b[index] = r.signerB.Sign(b)
+}
+
+// Signatures returns the recorded signatures of video A and B.
+func (r *Recorder) Signatures() (a, b []Signature) {
+	return r.a, r.b
+}
@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// MatchOptions configures RankReferences.
+type MatchOptions struct {
+	// Number of frames sampled from every source, spread evenly over it.
+	// Defaults to 8.
+	Samples int
+}
+
+func (o *MatchOptions) setDefaults() {
+	if o.Samples < 1 {
+		o.Samples = 8
+	}
+}
+
+// ReferenceMatch is how closely a candidate reference matches a distortion.
+type ReferenceMatch struct {
+	// Index of the candidate in the candidates passed to RankReferences.
+	Index int
+	// Distance is the mean Signature distance of the sampled frame pairs,
+	// 0 for the same pictures.
+	Distance float64
+}
+
+// RankReferences finds which of the candidates a distortion was most likely
+// encoded from, e.g. when the file names of the masters cannot be trusted,
+// by comparing the Signatures of a few frames of each at the same relative
+// positions. Signatures are cheap and tolerate differences in resolution,
+// bit depth, range and compression, so the actual master ranks first with a
+// distance near 0 while other content ranks far behind. Candidates of the
+// same content, such as the masters before and after grading, are told apart
+// less reliably.
+//
+// Returns the matches of every candidate, closest first. All sources must be
+// seekable and are seeked back to their first frame afterwards. Their pixel
+// formats must be planar, see NewSigner.
+func RankReferences(distortion video.Source, candidates []video.Source,
+	opts MatchOptions) ([]ReferenceMatch, error) {
+	opts.setDefaults()
+
+	want, err := sampleSignatures(distortion, opts.Samples)
+	if err != nil {
+		return nil, fmt.Errorf("distortion: %w", err)
+	}
+
+	matches := make([]ReferenceMatch, len(candidates))
+	for i, candidate := range candidates {
+		got, err := sampleSignatures(candidate, opts.Samples)
+		if err != nil {
+			return nil, fmt.Errorf("candidate %d: %w", i, err)
+		}
+
+		// A candidate shorter than the samples has fewer of them, they are
+		// paired by relative position all the same.
+		n := min(len(want), len(got))
+		var sum float64
+		for j := range n {
+			sum += want[j*len(want)/n].Distance(&got[j*len(got)/n])
+		}
+		matches[i] = ReferenceMatch{Index: i, Distance: sum / float64(n)}
+	}
+
+	slices.SortStableFunc(matches, func(a, b ReferenceMatch) int {
+		switch {
+		case a.Distance < b.Distance:
+			return -1
+		case a.Distance > b.Distance:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return matches, nil
+}
+
+// sampleSignatures returns the Signatures of samples frames spread evenly
+// over source, fewer if it is shorter, and seeks it back to its first frame.
+func sampleSignatures(source video.Source, samples int) ([]Signature,
+	error) {
+	seekable, ok := video.As[video.SeekableSource](source)
+	if !ok {
+		return nil, errors.New("reference matching needs a seekable source")
+	}
+
+	signer, err := NewSigner(source.GetColorProps())
+	if err != nil {
+		return nil, err
+	}
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		return nil, err
+	}
+
+	numFrames := source.GetNumFrames()
+	samples = min(samples, numFrames)
+	if samples < 1 {
+		return nil, errors.New("source has no frames")
+	}
+
+	signatures := make([]Signature, samples)
+	for i := range samples {
+		n := (2*i + 1) * numFrames / (2 * samples)
+		if err = seekable.SeekFrame(n); err != nil {
+			return nil, err
+		}
+		if err = source.GetFrame(frame); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", n, err)
+		}
+		signatures[i] = signer.Sign(&frame)
+	}
+
+	if err = seekable.SeekFrame(0); err != nil {
+		return nil, err
+	}
+	return signatures, nil
+}
@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// RegionOptions configures ProblemRegions.
+type RegionOptions struct {
+	// The share of the scored samples, worst first, that flag a region.
+	// Defaults to 0.1. At least one sample is flagged.
+	Fraction float64
+	// The number of frames either side of a flagged sample that belong to
+	// its region. Defaults to 12, usually set to the sampling stride so the
+	// region reaches the neighbouring samples.
+	Radius int
+	// HigherIsWorse is set for metrics scoring distances, such as
+	// Butteraugli.
+	HigherIsWorse bool
+}
+
+func (o *RegionOptions) setDefaults() {
+	if o.Fraction <= 0 || o.Fraction > 1 {
+		o.Fraction = 0.1
+	}
+	if o.Radius <= 0 {
+		o.Radius = 12
+	}
+}
+
+// Region is a run of frames around poorly scoring samples of a sparse first
+// pass, worth scoring densely in a second one.
+type Region struct {
+	// First frame of the region and its time in seconds.
+	Start     int     `json:"start"`
+	StartTime float64 `json:"start_time"`
+	Frames    int     `json:"frames"`
+	// Scored is the number of frames of the region with a score, see
+	// RegionScores. Mean and Worst are 0 when no frame was scored.
+	Scored int     `json:"scored"`
+	Mean   float64 `json:"mean"`
+	Worst  float64 `json:"worst"`
+}
+
+// ProblemRegions returns the regions around the worst scoring samples of a
+// sparse pass, in increasing order. scores[i] is the score of frame
+// frames[i]. Regions are clipped to numFrames and overlapping or touching
+// regions are merged. NaN scores are not counted. frameRate sets StartTime
+// when positive.
+func ProblemRegions(scores []float64, frames []int, numFrames int,
+	frameRate float64, opts RegionOptions) []Region {
+	opts.setDefaults()
+
+	var samples []int
+	for i, score := range scores[:min(len(scores), len(frames))] {
+		if !math.IsNaN(score) {
+			samples = append(samples, i)
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	slices.SortStableFunc(samples, func(a, b int) int {
+		if opts.HigherIsWorse {
+			return cmp.Compare(scores[b], scores[a])
+		}
+		return cmp.Compare(scores[a], scores[b])
+	})
+	flagged := max(int(math.Ceil(opts.Fraction*float64(len(samples)))), 1)
+
+	regions := make([]Region, 0, flagged)
+	for _, sample := range samples[:flagged] {
+		start := max(frames[sample]-opts.Radius, 0)
+		end := min(frames[sample]+opts.Radius+1, numFrames)
+		if start < end {
+			regions = append(regions, Region{Start: start,
+				Frames: end - start})
+		}
+	}
+
+	slices.SortFunc(regions, func(a, b Region) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	merged := regions[:0]
+	for _, region := range regions {
+		if n := len(merged); n > 0 &&
+			region.Start <= merged[n-1].Start+merged[n-1].Frames {
+			last := &merged[n-1]
+			last.Frames = max(last.Frames, region.Start+region.Frames-
+				last.Start)
+			continue
+		}
+		merged = append(merged, region)
+	}
+
+	if frameRate > 0 {
+		for i := range merged {
+			merged[i].StartTime = float64(merged[i].Start) / frameRate
+		}
+	}
+	return merged
+}
+
+// RegionFrames returns every frame of regions, which must be in increasing
+// order and not overlap, in increasing order.
+func RegionFrames(regions []Region) []int {
+	var frames []int
+	for _, region := range regions {
+		for frame := region.Start; frame < region.Start+region.Frames; frame++ {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// RegionScores fills Scored, Mean and Worst of every region from scores,
+// where scores[i] is the score of frame frames[i] and frames is increasing.
+// NaN scores are not counted.
+func RegionScores(regions []Region, scores []float64, frames []int,
+	higherIsWorse bool) {
+	for i := range regions {
+		region := &regions[i]
+		region.Scored, region.Mean, region.Worst = 0, 0, 0
+
+		first, _ := slices.BinarySearch(frames, region.Start)
+		last, _ := slices.BinarySearch(frames, region.Start+region.Frames)
+
+		var sum float64
+		for _, score := range scores[min(first, len(scores)):min(last,
+			len(scores))] {
+			if math.IsNaN(score) {
+				continue
+			}
+			if region.Scored == 0 || worse(score, region.Worst,
+				higherIsWorse) {
+				region.Worst = score
+			}
+			sum += score
+			region.Scored++
+		}
+		if region.Scored > 0 {
+			region.Mean = sum / float64(region.Scored)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package analysis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SceneListFormat is a file format listing the scene cuts of a video.
+type SceneListFormat int
+
+const (
+	// SceneListQPFile is an x264 QP file, one "<frame> <type> [qp]" line
+	// per forced frame. Frames of type I, i and K are read as cuts, and cuts
+	// are written as IDR frames with an automatic QP.
+	SceneListQPFile SceneListFormat = iota
+	// SceneListFFprobe is the output of ffprobe listing frames or packets,
+	// e.g. -show_entries frame=key_frame,pts_time, in the default, compact
+	// or csv writer with keys. Records marked as keyframes by key_frame,
+	// flags or pict_type are cuts, and records with nothing but a timestamp,
+	// such as the frames picked by a select='gt(scene,0.4)' filter, are cuts
+	// too. Cuts are written as keyframe records.
+	SceneListFFprobe
+)
+
+// ParseSceneListFormat parses "qpfile" or "ffprobe".
+func ParseSceneListFormat(text string) (SceneListFormat, error) {
+	switch text {
+	case "qpfile":
+		return SceneListQPFile, nil
+	case "ffprobe":
+		return SceneListFFprobe, nil
+	default:
+		return 0, fmt.Errorf("unknown scene list format %q, expected "+
+			"qpfile or ffprobe", text)
+	}
+}
+
+func (f SceneListFormat) String() string {
+	if f == SceneListFFprobe {
+		return "ffprobe"
+	}
+	return "qpfile"
+}
+
+// ReadSceneList reads the scene cuts listed in r and returns their frame
+// numbers in increasing order, without duplicates. The format is detected
+// from the content. frameRate converts the timestamps of ffprobe records to
+// frame numbers; without it, or for records without a timestamp, records are
+// numbered in the order they appear.
+func ReadSceneList(r io.Reader, frameRate float64) ([]int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cuts []int
+	if isFFprobeOutput(string(data)) {
+		cuts, err = readFFprobeCuts(string(data), frameRate)
+	} else {
+		cuts, err = readQPFileCuts(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(cuts)
+	return slices.Compact(cuts), nil
+}
+
+// ReadSceneListFile reads the scene list at path, see ReadSceneList.
+func ReadSceneListFile(path string, frameRate float64) ([]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadSceneList(file, frameRate)
+}
+
+// WriteSceneList writes the scene cuts at the given frame numbers to w in
+// format. ffprobe records carry the timestamp of the cut, so frameRate must
+// be set for SceneListFFprobe.
+func WriteSceneList(w io.Writer, cuts []int, format SceneListFormat,
+	frameRate float64) error {
+	if format == SceneListFFprobe && frameRate <= 0 {
+		return errors.New("ffprobe scene lists need a frame rate")
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, cut := range cuts {
+		if format == SceneListQPFile {
+			fmt.Fprintf(buf, "%d I -1\n", cut)
+		} else {
+			fmt.Fprintf(buf, "[FRAME]\nkey_frame=1\npict_type=I\n"+
+				"pts_time=%f\n[/FRAME]\n", float64(cut)/frameRate)
+		}
+	}
+	return buf.Flush()
+}
+
+// WriteSceneListFile writes the scene list to path, see WriteSceneList.
+func WriteSceneListFile(path string, cuts []int, format SceneListFormat,
+	frameRate float64) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = WriteSceneList(file, cuts, format, frameRate); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// isFFprobeOutput reports whether the first line that is not blank looks
+// like ffprobe output rather than a QP file line.
+func isFFprobeOutput(data string) bool {
+	for line := range strings.Lines(data) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		return strings.HasPrefix(line, "[") ||
+			strings.ContainsAny(line, "=|,")
+	}
+	return false
+}
+
+func readQPFileCuts(data string) ([]int, error) {
+	var cuts []int
+	lineNumber := 0
+
+	for line := range strings.Lines(data) {
+		lineNumber++
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected <frame> <type> [qp], "+
+				"got %q", lineNumber, strings.TrimSpace(line))
+		}
+
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 0 {
+			return nil, fmt.Errorf("line %d: invalid frame number %q",
+				lineNumber, fields[0])
+		}
+
+		switch fields[1] {
+		case "I", "i", "K":
+			cuts = append(cuts, frame)
+		case "P", "B", "b":
+		default:
+			return nil, fmt.Errorf("line %d: unknown frame type %q",
+				lineNumber, fields[1])
+		}
+	}
+
+	return cuts, nil
+}
+
+// ffprobeTimeKeys are the timestamp fields of ffprobe records, in the order
+// they are preferred.
+var ffprobeTimeKeys = []string{"pts_time", "best_effort_timestamp_time",
+	"pkt_pts_time", "pkt_dts_time", "dts_time"}
+
+// readFFprobeCuts reads the records of ffprobe output. A record is either a
+// [SECTION] ... [/SECTION] block of key=value lines from the default writer,
+// whose nested sections such as [SIDE_DATA] are skipped, or a single line of
+// fields separated by '|' or ',' from the compact and csv writers.
+func readFFprobeCuts(data string, frameRate float64) ([]int, error) {
+	var cuts []int
+	var record map[string]string
+	var section string
+	depth, index := 0, 0
+
+	finish := func() error {
+		if record == nil {
+			return nil
+		}
+		frame, cut, err := ffprobeRecordCut(record, index, frameRate)
+		record = nil
+		index++
+		if err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+		if cut {
+			cuts = append(cuts, frame)
+		}
+		return nil
+	}
+
+	for line := range strings.Lines(data) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[/"):
+			if depth--; depth > 0 || line != "[/"+section+"]" {
+				continue
+			}
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "["):
+			if depth++; depth == 1 {
+				record = make(map[string]string)
+				section = strings.TrimSuffix(line[1:], "]")
+			}
+		case depth > 1:
+		case record != nil:
+			key, value, _ := strings.Cut(line, "=")
+			record[key] = value
+		default:
+			record = make(map[string]string)
+			for field := range strings.FieldsFuncSeq(line, func(r rune) bool {
+				return r == '|' || r == ','
+			}) {
+				if key, value, ok := strings.Cut(field, "="); ok {
+					record[key] = value
+				}
+			}
+			if err := finish(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cuts, nil
+}
+
+// ffprobeRecordCut returns the frame number of the index-th record and
+// whether it is a scene cut.
+func ffprobeRecordCut(record map[string]string, index int,
+	frameRate float64) (int, bool, error) {
+	frame := index
+	timed := false
+	if frameRate > 0 {
+		for _, key := range ffprobeTimeKeys {
+			value, ok := record[key]
+			if !ok || value == "N/A" {
+				continue
+			}
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				return 0, false, fmt.Errorf("invalid %s %q", key, value)
+			}
+			frame, timed = int(math.Round(seconds*frameRate)), true
+			break
+		}
+	}
+
+	if keyFrame, ok := record["key_frame"]; ok {
+		return frame, keyFrame == "1", nil
+	}
+	if flags, ok := record["flags"]; ok {
+		return frame, strings.HasPrefix(flags, "K"), nil
+	}
+	if pictType, ok := record["pict_type"]; ok {
+		return frame, pictType == "I", nil
+	}
+	if !timed {
+		return 0, false, errors.New("no keyframe flag or timestamp")
+	}
+	return frame, true, nil
+}
@@ -0,0 +1,131 @@
+package analysis
+
+import "slices"
+
+// SegmentOptions configures DetectSegments.
+type SegmentOptions struct {
+	// A frame is black when the mean normalized luma of every signature cell
+	// is below this. Defaults to 0.1.
+	BlackThreshold float64
+	// Signatures closer than this are taken to show the same picture.
+	// Defaults to 0.002.
+	SameThreshold float64
+	// The shortest run of black frames reported. Defaults to 1.
+	MinBlack int
+	// The shortest run of identical frames reported as a freeze, counting the
+	// first frame of the run. Defaults to 12, half a second at 24 fps.
+	MinFreeze int
+}
+
+func (o *SegmentOptions) setDefaults() {
+	if o.BlackThreshold <= 0 {
+		o.BlackThreshold = 0.1
+	}
+	if o.SameThreshold <= 0 {
+		o.SameThreshold = 0.002
+	}
+	if o.MinBlack < 1 {
+		o.MinBlack = 1
+	}
+	if o.MinFreeze < 2 {
+		o.MinFreeze = 12
+	}
+}
+
+// DetectSegments reports runs of black frames and frozen video in either
+// source. Leaders, slates and encoder stalls score very differently from the
+// program and skew summaries, see ExcludedFrames to leave them out.
+//
+// A black frame that is also part of a freeze is only reported as black.
+func DetectSegments(reference, distortion []Signature,
+	opts SegmentOptions) []Event {
+	opts.setDefaults()
+
+	var events []Event
+	events = append(events, detectSegments(reference, SourceReference,
+		&opts)...)
+	events = append(events, detectSegments(distortion, SourceDistortion,
+		&opts)...)
+
+	sortEvents(events)
+	return events
+}
+
+func detectSegments(signatures []Signature, source string,
+	opts *SegmentOptions) []Event {
+	black := make([]bool, len(signatures))
+	for i := range signatures {
+		black[i] = isBlack(&signatures[i], opts.BlackThreshold)
+	}
+
+	events := findRuns(black, EventBlack, source, opts.MinBlack)
+
+	// start is the first frame of the current run of identical pictures.
+	start := 0
+	for i := 1; i <= len(signatures); i++ {
+		same := i < len(signatures) && !black[i] && !black[i-1] &&
+			signatures[i].Distance(&signatures[i-1]) < opts.SameThreshold
+		if same {
+			continue
+		}
+
+		if i-start >= opts.MinFreeze {
+			events = append(events, Event{Kind: EventFreeze, Frame: start,
+				Count: i - start, Source: source})
+		}
+		start = i
+	}
+
+	return events
+}
+
+func isBlack(sig *Signature, threshold float64) bool {
+	for _, v := range sig {
+		if float64(v) >= threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// findRuns returns an event for every run of at least minLength set flags.
+func findRuns(flags []bool, kind EventKind, source string,
+	minLength int) []Event {
+	var events []Event
+	start := -1
+
+	for i := 0; i <= len(flags); i++ {
+		set := i < len(flags) && flags[i]
+		switch {
+		case set && start < 0:
+			start = i
+		case !set && start >= 0:
+			if i-start >= minLength {
+				events = append(events, Event{Kind: kind, Frame: start,
+					Count: i - start, Source: source})
+			}
+			start = -1
+		}
+	}
+
+	return events
+}
+
+// ExcludedFrames returns a mask over numFrames compared frames that is set
+// for every frame covered by an event of one of the given kinds. Drop events
+// cover no frames of their own and never exclude anything.
+func ExcludedFrames(events []Event, numFrames int,
+	kinds ...EventKind) []bool {
+	mask := make([]bool, numFrames)
+
+	for _, e := range events {
+		if e.Kind == EventDrop || !slices.Contains(kinds, e.Kind) {
+			continue
+		}
+		for i := max(e.Frame, 0); i < min(e.Frame+e.Count, numFrames); i++ {
+			mask[i] = true
+		}
+	}
+
+	return mask
+}
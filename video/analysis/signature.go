@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SignatureSize is the width and height of the luma grid in a Signature.
+const SignatureSize = 16
+
+// maxSamples caps how many samples per row and column are read when building
+// a signature, so large frames are subsampled.
+const maxSamples = 256
+
+// Signature is a SignatureSize by SignatureSize grid of the mean normalized
+// luma of a frame, in [0, 1]. Signatures of the same picture are close even
+// when the frames differ in resolution, bit depth or range, or carry
+// compression noise.
+type Signature [SignatureSize * SignatureSize]float32
+
+// Distance returns the mean absolute difference between two signatures.
+func (s *Signature) Distance(other *Signature) float64 {
+	var sum float64
+	for i := range s {
+		sum += math.Abs(float64(s[i] - other[i]))
+	}
+	return sum / float64(len(s))
+}
+
+// Signer builds the Signature of frames with a given set of ColorProperties.
+// It is safe for concurrent use.
+type Signer struct {
+	width, height int
+	wide          bool
+	// offset and scale normalize luma codes to [0, 1].
+	offset, scale float64
+}
+
+// NewSigner returns a Signer for frames described by props, which must use a
+// planar pixel format. The first plane is taken as luma, for planar RGB that
+// is green.
+func NewSigner(props *video.ColorProperties) (*Signer, error) {
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+	if props.Width < 1 || props.Height < 1 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", props.Width,
+			props.Height)
+	}
+
+	layout, err := props.Layout()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Signer{width: props.Width, height: props.Height, wide: depth > 8,
+		scale: float64(int(1)<<depth - 1)}
+
+	if props.ColorRange != pixfmts.ColorRangeJPEG &&
+		layout != video.LayoutRGB && layout != video.LayoutRGBA {
+		shift := float64(int(1) << (depth - 8))
+		s.offset, s.scale = 16*shift, 219*shift
+	}
+
+	return s, nil
+}
+
+// Sign returns the signature of frame.
+func (s *Signer) Sign(frame *video.Frame) Signature {
+	plane, stride := frame.PlaneData(0), frame.PlaneLineSize(0)
+	stepX, stepY := max(s.width/maxSamples, 1), max(s.height/maxSamples, 1)
+
+	var sums, counts [SignatureSize * SignatureSize]float64
+
+	for y := 0; y < s.height; y += stepY {
+		row := y * SignatureSize / s.height * SignatureSize
+		for x := 0; x < s.width; x += stepX {
+			var code int
+			if s.wide {
+				offset := y*stride + 2*x
+				code = int(plane[offset]) | int(plane[offset+1])<<8
+			} else {
+				code = int(plane[y*stride+x])
+			}
+
+			cell := row + x*SignatureSize/s.width
+			sums[cell] += float64(code)
+			counts[cell]++
+		}
+	}
+
+	var sig Signature
+	for i := range sig {
+		if counts[i] == 0 {
+			continue
+		}
+		v := (sums[i]/counts[i] - s.offset) / s.scale
+		sig[i] = float32(min(max(v, 0), 1))
+	}
+
+	return sig
+}
@@ -0,0 +1,364 @@
+package analysis
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SubtitleOptions configures a SubtitleDetector.
+type SubtitleOptions struct {
+	// Fraction of the frame height, from the bottom, searched for text.
+	// Defaults to 0.35. 1 searches the whole frame.
+	Band float64
+	// Width and height of the cells the band is split into, in luma samples
+	// of frame a. Defaults to 16.
+	CellSize int
+	// Normalized luma at or above which a sample counts as text. Defaults to
+	// 0.75.
+	Brightness float64
+	// Share of the samples of a cell that must be bright in frame b but not
+	// in frame a for the cell to hold text. Defaults to 0.08.
+	Coverage float64
+	// Cells added around every text area to cover outlines and shadows.
+	// Defaults to 1, negative values add none.
+	Padding int
+}
+
+func (o *SubtitleOptions) setDefaults() {
+	if o.Band <= 0 || o.Band > 1 {
+		o.Band = 0.35
+	}
+	if o.CellSize < 1 {
+		o.CellSize = 16
+	}
+	if o.Brightness <= 0 {
+		o.Brightness = 0.75
+	}
+	if o.Coverage <= 0 {
+		o.Coverage = 0.08
+	}
+	switch {
+	case o.Padding == 0:
+		o.Padding = 1
+	case o.Padding < 0:
+		o.Padding = 0
+	}
+}
+
+// lumaReader reads normalized luma samples of frames with one
+// ColorProperties.
+type lumaReader struct {
+	step, offset, shift int
+	wide, bigEndian     bool
+	mask                uint32
+	// black and white are the sample values of black and white.
+	black, white  float64
+	width, height int
+}
+
+func newLumaReader(props *video.ColorProperties) (*lumaReader, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, fmt.Errorf("pixel format %d: %w", props.PixelFormat, err)
+	}
+
+	flags := pixfmts.PixFmtFlag(pixFmtDesc.Flags())
+	if flags&(pixfmts.PixFmtFlagRGB|pixfmts.PixFmtFlagPAL|
+		pixfmts.PixFmtFlagBitstream|pixfmts.PixFmtFlagFloat) != 0 {
+		return nil, fmt.Errorf("%s frames have no integer luma plane",
+			pixFmtDesc.Name())
+	}
+
+	comp, err := pixFmtDesc.Component(0)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &lumaReader{step: comp.Step, offset: comp.Offset,
+		shift: comp.Shift, wide: comp.Depth+comp.Shift > 8,
+		bigEndian: flags&pixfmts.PixFmtFlagBigEndian != 0,
+		mask:      1<<comp.Depth - 1, width: props.Width,
+		height: props.Height, white: float64(uint32(1)<<comp.Depth - 1)}
+	if props.ColorRange != pixfmts.ColorRangeJPEG {
+		r.black = float64(uint32(16) << (comp.Depth - 8))
+		r.white = float64(uint32(235) << (comp.Depth - 8))
+	}
+	return r, nil
+}
+
+// brightShare returns the share of the samples of frame inside [x0, x1) x
+// [y0, y1) whose normalized luma reaches brightness.
+func (r *lumaReader) brightShare(frame *video.Frame, x0, y0, x1, y1 int,
+	brightness float64) float64 {
+	threshold := r.black + brightness*(r.white-r.black)
+
+	var bright int
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if float64(r.sample(frame, x, y)) >= threshold {
+				bright++
+			}
+		}
+	}
+	return float64(bright) / float64((x1-x0)*(y1-y0))
+}
+
+// sample returns the luma sample at x, y of frame.
+func (r *lumaReader) sample(frame *video.Frame, x, y int) uint32 {
+	data := frame.PlaneData(0)
+	offset := y*frame.PlaneLineSize(0) + x*r.step + r.offset
+
+	var sample uint32
+	switch {
+	case !r.wide:
+		sample = uint32(data[offset])
+	case r.bigEndian:
+		sample = uint32(binary.BigEndian.Uint16(data[offset:]))
+	default:
+		sample = uint32(binary.LittleEndian.Uint16(data[offset:]))
+	}
+	return sample >> r.shift & r.mask
+}
+
+// SubtitleDetector finds text burned into frame b of a pair but missing from
+// frame a, such as hard subtitles in an encode of a clean master, by looking
+// for cells that gained bright samples. It is safe for concurrent use.
+type SubtitleDetector struct {
+	a, b *lumaReader
+	opts SubtitleOptions
+}
+
+// NewSubtitleDetector returns a SubtitleDetector for frames a and b described
+// by propsA and propsB, which must have a luma plane. Their sizes may differ.
+func NewSubtitleDetector(propsA, propsB *video.ColorProperties,
+	opts SubtitleOptions) (*SubtitleDetector, error) {
+	opts.setDefaults()
+
+	a, err := newLumaReader(propsA)
+	if err != nil {
+		return nil, fmt.Errorf("frame a: %w", err)
+	}
+	b, err := newLumaReader(propsB)
+	if err != nil {
+		return nil, fmt.Errorf("frame b: %w", err)
+	}
+
+	return &SubtitleDetector{a: a, b: b, opts: opts}, nil
+}
+
+// Detect returns the text areas of b missing from a, in luma samples of a.
+// Every connected group of text cells gives one rect.
+func (d *SubtitleDetector) Detect(a, b *video.Frame) []video.Rect {
+	size := d.opts.CellSize
+	cols := (d.a.width + size - 1) / size
+	firstRow := int(float64(d.a.height)*(1-d.opts.Band)) / size
+	rows := (d.a.height+size-1)/size - firstRow
+
+	text := make([]bool, rows*cols)
+	for row := range rows {
+		for col := range cols {
+			x0, y0 := col*size, (firstRow+row)*size
+			x1, y1 := min(x0+size, d.a.width), min(y0+size, d.a.height)
+			rect := video.Rect{X: x0, Y: y0, Width: x1 - x0,
+				Height: y1 - y0}.Scale(d.a.width, d.a.height, d.b.width,
+				d.b.height)
+
+			gained := d.b.brightShare(b, rect.X, rect.Y,
+				min(rect.X+rect.Width, d.b.width),
+				min(rect.Y+rect.Height, d.b.height), d.opts.Brightness) -
+				d.a.brightShare(a, x0, y0, x1, y1, d.opts.Brightness)
+			text[row*cols+col] = gained >= d.opts.Coverage
+		}
+	}
+
+	var rects []video.Rect
+	for _, cells := range connectedCells(text, cols) {
+		pad := d.opts.Padding
+		x0 := max(cells.X-pad, 0) * size
+		y0 := max(firstRow+cells.Y-pad, 0) * size
+		x1 := min((cells.X+cells.Width+pad)*size, d.a.width)
+		y1 := min((firstRow+cells.Y+cells.Height+pad)*size, d.a.height)
+		rects = append(rects, video.Rect{X: x0, Y: y0, Width: x1 - x0,
+			Height: y1 - y0})
+	}
+	return rects
+}
+
+// connectedCells returns the bounding box, in cells, of every group of set
+// cells connected horizontally or vertically in a grid of the given width.
+func connectedCells(set []bool, width int) []video.Rect {
+	seen := make([]bool, len(set))
+	var boxes []video.Rect
+
+	for start := range set {
+		if !set[start] || seen[start] {
+			continue
+		}
+
+		x0, y0 := start%width, start/width
+		x1, y1 := x0, y0
+		seen[start] = true
+		stack := []int{start}
+		for len(stack) > 0 {
+			cell := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := cell%width, cell/width
+			x0, y0, x1, y1 = min(x0, x), min(y0, y), max(x1, x), max(y1, y)
+
+			for _, next := range [4]int{cell - width, cell + width,
+				cell - 1, cell + 1} {
+				if next < 0 || next >= len(set) || !set[next] || seen[next] ||
+					(next == cell-1 && x == 0) ||
+					(next == cell+1 && x == width-1) {
+					continue
+				}
+				seen[next] = true
+				stack = append(stack, next)
+			}
+		}
+
+		boxes = append(boxes, video.Rect{X: x0, Y: y0, Width: x1 - x0 + 1,
+			Height: y1 - y0 + 1})
+	}
+	return boxes
+}
+
+// Cues are the times subtitles are shown, as sorted and disjoint [start, end)
+// intervals in seconds.
+type Cues [][2]float64
+
+// Covers reports whether a subtitle is shown at seconds.
+func (c Cues) Covers(seconds float64) bool {
+	i, _ := slices.BinarySearchFunc(c, seconds,
+		func(cue [2]float64, t float64) int {
+			return cmp.Compare(cue[0], t)
+		})
+	// i is the first cue starting after seconds, or at it.
+	if i < len(c) && c[i][0] == seconds {
+		return true
+	}
+	return i > 0 && seconds < c[i-1][1]
+}
+
+// ReadCues reads the times of the subtitles in r, a SubRip, WebVTT or
+// SubStation Alpha (ASS/SSA) file. Styling and positions are ignored.
+func ReadCues(r io.Reader) (Cues, error) {
+	var cues Cues
+	// start and end are the columns of the Start and End fields of ASS
+	// dialogue lines, as set by the Format line of its events.
+	start, end := 1, 2
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.Contains(line, "-->"):
+			from, to, _ := strings.Cut(line, "-->")
+			// WebVTT puts cue settings after the end time.
+			to, _, _ = strings.Cut(strings.TrimSpace(to), " ")
+			cue, err := parseCue(from, to)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			cues = append(cues, cue)
+		case strings.HasPrefix(line, "Format:"):
+			for i, field := range strings.Split(line[len("Format:"):], ",") {
+				switch strings.TrimSpace(field) {
+				case "Start":
+					start = i
+				case "End":
+					end = i
+				}
+			}
+		case strings.HasPrefix(line, "Dialogue:"):
+			fields := strings.SplitN(line[len("Dialogue:"):], ",",
+				max(start, end)+2)
+			if len(fields) <= max(start, end) {
+				return nil, fmt.Errorf("line %d: dialogue without times",
+					lineNumber)
+			}
+			cue, err := parseCue(fields[start], fields[end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			cues = append(cues, cue)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, errors.New("no subtitles found")
+	}
+
+	slices.SortFunc(cues, func(a, b [2]float64) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	merged := cues[:1]
+	for _, cue := range cues[1:] {
+		last := &merged[len(merged)-1]
+		if cue[0] <= last[1] {
+			last[1] = max(last[1], cue[1])
+		} else {
+			merged = append(merged, cue)
+		}
+	}
+	return merged, nil
+}
+
+// ReadCuesFile reads the subtitles at path, see ReadCues.
+func ReadCuesFile(path string) (Cues, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadCues(file)
+}
+
+func parseCue(from, to string) ([2]float64, error) {
+	start, err := parseSubtitleTime(from)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	end, err := parseSubtitleTime(to)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	return [2]float64{start, end}, nil
+}
+
+// parseSubtitleTime parses [hh:]mm:ss[.,]fff timestamps into seconds.
+func parseSubtitleTime(text string) (float64, error) {
+	text = strings.TrimSpace(text)
+	parts := strings.Split(strings.ReplaceAll(text, ",", "."), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", text)
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", text)
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, nil
+}
@@ -0,0 +1,202 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrInvalidSyncOptions = errors.New("sync estimation needs an envelope " +
+		"rate, and a frame rate when video offsets are given")
+)
+
+// SyncOptions configures EstimateSync.
+type SyncOptions struct {
+	// The number of envelope values per second of both audio envelopes.
+	// Required.
+	EnvelopeRate float64
+	// The frame rate of the compared video, used to turn video offsets into
+	// seconds. Required when video offsets are given.
+	FrameRate float64
+	// The length in seconds of the audio windows a lag is estimated for.
+	// Defaults to 10.
+	Window float64
+	// The largest lag in seconds searched for in either direction. Defaults
+	// to 1.
+	MaxLag float64
+	// Windows whose best correlation falls below this are skipped, as they
+	// are too quiet or too different to align reliably. Defaults to 0.6.
+	MinCorrelation float64
+}
+
+func (o *SyncOptions) setDefaults() {
+	if o.Window <= 0 {
+		o.Window = 10
+	}
+	if o.MaxLag <= 0 {
+		o.MaxLag = 1
+	}
+	if o.MinCorrelation <= 0 {
+		o.MinCorrelation = 0.6
+	}
+}
+
+// SyncPoint is the estimated audio/video sync of the distortion relative to
+// the reference over one audio window. Lags are in seconds and positive when
+// the distortion is late.
+type SyncPoint struct {
+	// The start of the window in seconds from the first compared frame.
+	Time float64 `json:"time"`
+	// How late the distorted audio is compared to the reference audio.
+	AudioLag float64 `json:"audio_lag"`
+	// How late the distorted video is compared to the reference video.
+	VideoLag float64 `json:"video_lag"`
+	// AudioLag minus VideoLag: how late the distorted audio is relative to
+	// its own video, beyond any offset already present in the reference.
+	Drift float64 `json:"drift"`
+	// The correlation of the audio envelopes at AudioLag, between -1 and 1.
+	Correlation float64 `json:"correlation"`
+}
+
+// silenceFloor is the RMS level envelopes are clamped to before taking the
+// logarithm, about -100 dBFS.
+const silenceFloor = 1e-5
+
+// EstimateSync estimates how the sync between audio and video of the
+// distortion differs from the reference, over time.
+//
+// reference and distortion are RMS loudness envelopes of the two audio
+// tracks, see sources.ReadAudioEnvelope, starting at the first compared
+// frame. The envelopes are split into windows and each window of the
+// reference is cross-correlated with the distorted envelope to find the audio
+// lag. Levels are compared in the log domain, so a change in loudness between
+// the two does not affect the result.
+//
+// videoOffsets holds the per-frame result of VideoOffsets. When it is nil the
+// video is taken to be aligned frame by frame and Drift equals AudioLag.
+// Windows past the last compared frame are not reported.
+func EstimateSync(reference, distortion []float32, videoOffsets []int,
+	opts SyncOptions) ([]SyncPoint, error) {
+	if opts.EnvelopeRate <= 0 || (videoOffsets != nil && opts.FrameRate <= 0) {
+		return nil, ErrInvalidSyncOptions
+	}
+	opts.setDefaults()
+
+	ref, dist := logEnvelope(reference), logEnvelope(distortion)
+	windowLen := max(int(math.Round(opts.Window*opts.EnvelopeRate)), 2)
+	maxLag := int(math.Round(opts.MaxLag * opts.EnvelopeRate))
+
+	var points []SyncPoint
+
+	for start := 0; start+windowLen <= len(ref); start += windowLen {
+		seconds := float64(start) / opts.EnvelopeRate
+
+		var videoLag float64
+		if videoOffsets != nil {
+			frame := int((seconds + opts.Window/2) * opts.FrameRate)
+			if frame >= len(videoOffsets) {
+				break
+			}
+			videoLag = -float64(videoOffsets[frame]) / opts.FrameRate
+		}
+
+		lag, correlation, ok := bestLag(ref[start:start+windowLen], dist,
+			start, maxLag)
+		if !ok || correlation < opts.MinCorrelation {
+			continue
+		}
+
+		audioLag := lag / opts.EnvelopeRate
+		points = append(points, SyncPoint{Time: seconds, AudioLag: audioLag,
+			VideoLag: videoLag, Drift: audioLag - videoLag,
+			Correlation: correlation})
+	}
+
+	return points, nil
+}
+
+func logEnvelope(envelope []float32) []float64 {
+	out := make([]float64, len(envelope))
+	for i, v := range envelope {
+		out[i] = math.Log10(max(float64(v), silenceFloor))
+	}
+	return out
+}
+
+// bestLag cross-correlates window, which starts at start, with dist at every
+// lag within maxLag and returns the lag with the highest correlation, refined
+// to a fraction of an envelope value. ok is false if window is flat, such as
+// during silence, or no lag fits inside dist.
+func bestLag(window, dist []float64, start, maxLag int) (lag,
+	correlation float64, ok bool) {
+	mean, deviation := meanDeviation(window)
+	if deviation == 0 {
+		return 0, 0, false
+	}
+
+	correlations := make([]float64, 2*maxLag+1)
+	best := -1
+
+	for i := range correlations {
+		offset := start + i - maxLag
+		if offset < 0 || offset+len(window) > len(dist) {
+			correlations[i] = math.NaN()
+			continue
+		}
+
+		candidate := dist[offset : offset+len(window)]
+		candidateMean, candidateDeviation := meanDeviation(candidate)
+		if candidateDeviation == 0 {
+			correlations[i] = math.NaN()
+			continue
+		}
+
+		var sum float64
+		for j, v := range window {
+			sum += (v - mean) * (candidate[j] - candidateMean)
+		}
+		correlations[i] = sum / (deviation * candidateDeviation)
+
+		if best < 0 || correlations[i] > correlations[best] {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return 0, 0, false
+	}
+
+	return float64(best-maxLag) + parabolicPeak(correlations, best),
+		correlations[best], true
+}
+
+// meanDeviation returns the mean of values and the square root of the sum of
+// squared differences from it.
+func meanDeviation(values []float64) (mean, deviation float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	for _, v := range values {
+		deviation += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(deviation)
+}
+
+// parabolicPeak fits a parabola through the peak at i and its neighbours and
+// returns the offset of its vertex from i, between -0.5 and 0.5.
+func parabolicPeak(values []float64, i int) float64 {
+	if i == 0 || i == len(values)-1 ||
+		math.IsNaN(values[i-1]) || math.IsNaN(values[i+1]) {
+		return 0
+	}
+
+	left, peak, right := values[i-1], values[i], values[i+1]
+	denominator := left - 2*peak + right
+	if denominator == 0 {
+		return 0
+	}
+
+	return min(max(0.5*(left-right)/denominator, -0.5), 0.5)
+}
@@ -0,0 +1,95 @@
+package analysis
+
+import "slices"
+
+// throttleClockShare is the share of the highest clock of a run below which
+// the GPU counts as throttled, for GPUs that do not report why they slowed
+// down.
+const throttleClockShare = 0.85
+
+// GPUSample is one point of the throughput timeline of a run.
+type GPUSample struct {
+	// Seconds since the run started.
+	Time float64 `json:"time"`
+	// Frame pairs scored per second since the previous sample.
+	FPS float64 `json:"fps"`
+	// The shader clock in MHz and the temperature in °C.
+	Clock       int `json:"clock"`
+	Temperature int `json:"temperature"`
+	// Why the driver lowered the clocks, as reported by nvidia-smi.
+	Reasons []string `json:"reasons,omitempty"`
+	// Throttled is set if the GPU was slowed down by its power or thermal
+	// limits when the sample was taken.
+	Throttled bool `json:"throttled"`
+}
+
+// ThrottleEvent is a stretch of a run the GPU spent throttled, so a drop in
+// throughput there is not taken for a slowdown of the software.
+type ThrottleEvent struct {
+	// Start and End in seconds since the run started.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// The lowest clock in MHz and the highest temperature in °C of the
+	// stretch.
+	MinClock       int      `json:"min_clock"`
+	MaxTemperature int      `json:"max_temperature"`
+	Reasons        []string `json:"reasons,omitempty"`
+	// Throughput before and during the stretch, in frame pairs per second.
+	FPSBefore float64 `json:"fps_before"`
+	FPSDuring float64 `json:"fps_during"`
+}
+
+// ThrottleEvents marks the samples of timeline taken while the GPU ran below
+// 85% of its highest clock of the run, for GPUs that do not report why, and
+// returns the stretches of throttled samples. FPSBefore is the throughput of
+// every unthrottled sample before the stretch, 0 for a stretch starting the
+// run.
+func ThrottleEvents(timeline []GPUSample) []ThrottleEvent {
+	var peakClock int
+	for _, sample := range timeline {
+		peakClock = max(peakClock, sample.Clock)
+	}
+	for i := range timeline {
+		// A GPU with nothing to do lowers its clocks as well.
+		if timeline[i].FPS > 0 && float64(timeline[i].Clock) <
+			throttleClockShare*float64(peakClock) {
+			timeline[i].Throttled = true
+		}
+	}
+
+	var events []ThrottleEvent
+	var fpsSum float64
+	var fpsCount int
+	for i := 0; i < len(timeline); i++ {
+		if !timeline[i].Throttled {
+			fpsSum += timeline[i].FPS
+			fpsCount++
+			continue
+		}
+
+		event := ThrottleEvent{Start: timeline[i].Time,
+			MinClock: timeline[i].Clock}
+		if fpsCount > 0 {
+			event.FPSBefore = fpsSum / float64(fpsCount)
+		}
+		var during float64
+		j := i
+		for ; j < len(timeline) && timeline[j].Throttled; j++ {
+			sample := timeline[j]
+			event.End = sample.Time
+			event.MinClock = min(event.MinClock, sample.Clock)
+			event.MaxTemperature = max(event.MaxTemperature,
+				sample.Temperature)
+			for _, reason := range sample.Reasons {
+				if !slices.Contains(event.Reasons, reason) {
+					event.Reasons = append(event.Reasons, reason)
+				}
+			}
+			during += sample.FPS
+		}
+		event.FPSDuring = during / float64(j-i)
+		events = append(events, event)
+		i = j - 1
+	}
+	return events
+}
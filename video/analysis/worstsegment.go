@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// WorstSegmentOptions configures WorstSegment.
+type WorstSegmentOptions struct {
+	// Duration of the segments in seconds.
+	Duration float64
+	// The percentile of the scores of a segment, counted from its worst
+	// score, that segments are ranked by. Defaults to 10, so a segment is as
+	// bad as its worst tenth of frames: a short glitch still counts but a
+	// single outlier frame does not decide the ranking.
+	Percentile float64
+	// HigherIsWorse is set for metrics scoring distances, such as
+	// Butteraugli.
+	HigherIsWorse bool
+}
+
+func (o *WorstSegmentOptions) setDefaults() {
+	if o.Percentile <= 0 {
+		o.Percentile = 10
+	}
+}
+
+func (o *WorstSegmentOptions) validate() error {
+	switch {
+	case o.Duration <= 0:
+		return fmt.Errorf("segment duration must be positive, got %g",
+			o.Duration)
+	case o.Percentile > 100:
+		return fmt.Errorf("segment percentile must be in (0, 100], got %g",
+			o.Percentile)
+	}
+	return nil
+}
+
+// Segment summarizes the scores of a contiguous run of frames.
+type Segment struct {
+	// Duration in seconds the segment was chosen for.
+	Duration float64 `json:"duration"`
+	// First frame of the segment and its time in seconds.
+	Start     int     `json:"start"`
+	StartTime float64 `json:"start_time"`
+	Frames    int     `json:"frames"`
+	Scored    int     `json:"scored"`
+	// Pooled is the percentile of WorstSegmentOptions of the scores of the
+	// segment.
+	Pooled float64 `json:"pooled"`
+	Mean   float64 `json:"mean"`
+	Worst  float64 `json:"worst"`
+}
+
+// WorstSegment returns the run of frames lasting opts.Duration at frameRate
+// with the worst pooled score, the earliest of equally bad runs. Scores
+// shorter than the duration form a single segment. NaN scores, e.g. of
+// excluded frames, are not counted. It returns false if no frame was scored.
+func WorstSegment(scores []float64, frameRate float64,
+	opts WorstSegmentOptions) (Segment, bool, error) {
+	opts.setDefaults()
+	if err := opts.validate(); err != nil {
+		return Segment{}, false, err
+	}
+	if frameRate <= 0 {
+		return Segment{}, false, fmt.Errorf("segments need a positive "+
+			"frame rate, got %g", frameRate)
+	}
+
+	length := max(int(math.Round(opts.Duration*frameRate)), 1)
+	length = min(length, len(scores))
+
+	// window holds the scored frames of the current run, sorted, so the
+	// pooled score of every run costs one insertion and one removal.
+	window := make([]float64, 0, length)
+	best := Segment{Start: -1}
+
+	for i, score := range scores {
+		if !math.IsNaN(score) {
+			at, _ := slices.BinarySearch(window, score)
+			window = slices.Insert(window, at, score)
+		}
+		if i >= length {
+			if old := scores[i-length]; !math.IsNaN(old) {
+				at, _ := slices.BinarySearch(window, old)
+				window = slices.Delete(window, at, at+1)
+			}
+		}
+		if i < length-1 || len(window) == 0 {
+			continue
+		}
+
+		pooled := pooledScore(window, &opts)
+		if best.Start < 0 || worse(pooled, best.Pooled, opts.HigherIsWorse) {
+			best.Start, best.Pooled = i-length+1, pooled
+		}
+	}
+	if best.Start < 0 {
+		return Segment{}, false, nil
+	}
+
+	best.Duration, best.Frames = opts.Duration, length
+	best.StartTime = float64(best.Start) / frameRate
+
+	var sum float64
+	for _, score := range scores[best.Start : best.Start+length] {
+		if math.IsNaN(score) {
+			continue
+		}
+		if best.Scored == 0 || worse(score, best.Worst, opts.HigherIsWorse) {
+			best.Worst = score
+		}
+		sum += score
+		best.Scored++
+	}
+	best.Mean = sum / float64(best.Scored)
+
+	return best, true, nil
+}
+
+// pooledScore returns the percentile of opts of sorted, counted from the
+// worst score and interpolated linearly between the closest ranks.
+func pooledScore(sorted []float64, opts *WorstSegmentOptions) float64 {
+	p := opts.Percentile
+	if opts.HigherIsWorse {
+		p = 100 - p
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := min(lower+1, len(sorted)-1)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,136 @@
+package analysis_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+const loudnessRate = 48000
+
+// sine returns seconds of an interleaved sine of freq Hz whose peak is level
+// dBFS, in every channel with a true entry in active and silence elsewhere.
+func sine(freq, level, phase, seconds float64, active ...bool) []float64 {
+	amplitude := math.Pow(10, level/20)
+	n := int(seconds * loudnessRate)
+	samples := make([]float64, 0, n*len(active))
+	for i := range n {
+		v := amplitude * math.Sin(2*math.Pi*freq*float64(i)/loudnessRate+
+			phase)
+		for _, on := range active {
+			if on {
+				samples = append(samples, v)
+			} else {
+				samples = append(samples, 0)
+			}
+		}
+	}
+	return samples
+}
+
+func measure(t *testing.T, channels []string, numChannels int,
+	samples ...[]float64) analysis.Loudness {
+	meter, err := analysis.NewLoudnessMeter(loudnessRate, numChannels,
+		channels, analysis.LoudnessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range samples {
+		meter.Write(s)
+	}
+	return meter.Loudness()
+}
+
+func within(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// Test_LoudnessTech3341 runs the stereo 1 kHz tones of EBU Tech 3341 test
+// cases 1 and 2, which read their level in LUFS within 0.1 LU.
+func Test_LoudnessTech3341(t *testing.T) {
+	for _, level := range []float64{-23, -33} {
+		loudness := measure(t, nil, 2, sine(1000, level, 0, 20, true, true))
+
+		if !within(loudness.Integrated, level, 0.1) {
+			t.Errorf("%g dBFS: integrated %.2f LUFS, want %g", level,
+				loudness.Integrated, level)
+		}
+		if !within(loudness.MaxMomentary, level, 0.1) ||
+			!within(loudness.MaxShortTerm, level, 0.1) {
+			t.Errorf("%g dBFS: momentary %.2f, short-term %.2f LUFS, want "+
+				"%g", level, loudness.MaxMomentary, loudness.MaxShortTerm,
+				level)
+		}
+		if !within(loudness.SamplePeak, level, 0.01) {
+			t.Errorf("%g dBFS: sample peak %.2f dBFS", level,
+				loudness.SamplePeak)
+		}
+		if !within(loudness.Duration, 20, 1e-9) {
+			t.Errorf("%g dBFS: duration %g s, want 20", level,
+				loudness.Duration)
+		}
+	}
+}
+
+// Test_LoudnessRangeTech3342 runs EBU Tech 3342 test case 1, 20 s at -20
+// dBFS followed by 20 s at -30 dBFS, whose loudness range is 10 LU.
+func Test_LoudnessRangeTech3342(t *testing.T) {
+	loudness := measure(t, nil, 2, sine(1000, -20, 0, 20, true, true),
+		sine(1000, -30, 0, 20, true, true))
+
+	if !within(loudness.Range, 10, 1) {
+		t.Errorf("loudness range %.2f LU, want 10", loudness.Range)
+	}
+}
+
+// Test_TruePeak samples a full scale 12 kHz sine 45 degrees off its peaks, so
+// no sample exceeds -3 dBFS while the signal reaches 0 dBTP.
+func Test_TruePeak(t *testing.T) {
+	loudness := measure(t, nil, 1, sine(12000, 0, math.Pi/4, 1, true))
+
+	if !within(loudness.SamplePeak, -3.01, 0.01) {
+		t.Errorf("sample peak %.2f dBFS, want -3.01", loudness.SamplePeak)
+	}
+	if !within(loudness.TruePeak, 0, 0.5) {
+		t.Errorf("true peak %.2f dBTP, want 0", loudness.TruePeak)
+	}
+}
+
+func Test_LoudnessChannelWeights(t *testing.T) {
+	// A tone in the LFE channel alone is not heard.
+	loudness := measure(t, []string{"FL", "LFE"}, 2,
+		sine(1000, -23, 0, 5, false, true))
+	if loudness.Integrated != -70 {
+		t.Errorf("LFE tone: integrated %.2f LUFS, want the -70 gate",
+			loudness.Integrated)
+	}
+
+	// A surround channel weighs 1.41, 1.5 dB louder than a front one.
+	front := measure(t, []string{"FL"}, 1, sine(1000, -23, 0, 5, true))
+	surround := measure(t, []string{"SL"}, 1, sine(1000, -23, 0, 5, true))
+	if delta := surround.Integrated - front.Integrated; !within(delta,
+		10*math.Log10(1.41), 0.01) {
+		t.Errorf("surround is %.2f LU louder than front, want 1.49", delta)
+	}
+}
+
+func Test_LoudnessClipping(t *testing.T) {
+	samples := []float64{1, 1, 1, 1, 1, 0, -1, -1, 0, -1, -1, -1}
+	loudness := measure(t, nil, 1, samples)
+
+	// Runs of 5 and 3 clipped samples make events, the run of 2 does not.
+	if loudness.ClippedSamples != 10 || loudness.ClipEvents != 2 {
+		t.Errorf("%d clipped samples in %d events, want 10 in 2",
+			loudness.ClippedSamples, loudness.ClipEvents)
+	}
+}
+
+func Test_LoudnessSilence(t *testing.T) {
+	loudness := measure(t, nil, 2, make([]float64, 2*loudnessRate))
+
+	if loudness.Integrated != -70 || loudness.Range != 0 ||
+		loudness.SamplePeak != -144 || loudness.TruePeak != -144 {
+		t.Errorf("silence: got %+v", loudness)
+	}
+}
@@ -0,0 +1,55 @@
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+func Test_DetectPulldown(t *testing.T) {
+	// Soft 3:2 pulldown repeats a field of every other frame, showing 4
+	// coded frames as 10 fields.
+	soft := analysis.DetectPulldown([]int{1, 0, 1, 0, 1, 0, 1, 0})
+	if soft.Frames != 8 || soft.Fields != 20 || soft.Repeated != 4 {
+		t.Errorf("soft 3:2: got %+v", soft)
+	}
+	if !soft.Soft32() || soft.DisplayRatio() != 1.25 {
+		t.Errorf("soft 3:2: Soft32() = %v, DisplayRatio() = %g",
+			soft.Soft32(), soft.DisplayRatio())
+	}
+
+	progressive := analysis.DetectPulldown([]int{0, 0, 0, 0})
+	if progressive.Soft32() || progressive.String() != "none" {
+		t.Errorf("progressive: Soft32() = %v, String() = %q",
+			progressive.Soft32(), progressive.String())
+	}
+
+	// A single repeated field is not a cadence.
+	if analysis.DetectPulldown([]int{0, 1, 0, 0}).Soft32() {
+		t.Error("one repeated field of 4 frames detected as 3:2")
+	}
+}
+
+func Test_CompareFrameCounts(t *testing.T) {
+	tests := []struct {
+		reference, distortion int
+		want                  analysis.FrameRateMismatch
+	}{
+		{1000, 1000, analysis.FrameRatesMatch},
+		{1000, 1004, analysis.FrameRatesMatch},
+		{1000, 1250, analysis.DistortionTelecined},
+		{1000, 1247, analysis.DistortionTelecined},
+		{1250, 1000, analysis.ReferenceTelecined},
+		{1000, 2000, analysis.FrameRatesDiffer},
+		{1000, 1100, analysis.FrameRatesDiffer},
+		{4, 5, analysis.FrameRatesMatch},
+	}
+
+	for _, tt := range tests {
+		got := analysis.CompareFrameCounts(tt.reference, tt.distortion)
+		if got != tt.want {
+			t.Errorf("%d and %d frames: got %v, want %v", tt.reference,
+				tt.distortion, got, tt.want)
+		}
+	}
+}
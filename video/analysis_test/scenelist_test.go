@@ -0,0 +1,130 @@
+package analysis_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+func Test_ReadSceneList(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      string
+		frameRate float64
+		want      []int
+	}{
+		{"qpfile", "0 I -1\n48 K\n12 P 20\n\n24 i\n48 I\n", 0,
+			[]int{0, 24, 48}},
+		{"ffprobe default", "[FRAME]\nkey_frame=1\npts_time=0.000000\n" +
+			"[SIDE_DATA]\nkey_frame=0\n[/SIDE_DATA]\n[/FRAME]\n" +
+			"[FRAME]\nkey_frame=0\npts_time=0.041667\n[/FRAME]\n" +
+			"[FRAME]\nkey_frame=1\npts_time=2.000000\n[/FRAME]\n", 24,
+			[]int{0, 48}},
+		{"ffprobe compact", "frame|key_frame=1|pts_time=1.0\n" +
+			"frame|key_frame=0|pts_time=1.5\n" +
+			"frame|key_frame=1|pts_time=N/A|pkt_dts_time=3.0\n", 24,
+			[]int{24, 72}},
+		{"ffprobe csv flags", "packet,pts_time=0.5,flags=K__\n" +
+			"packet,pts_time=1.0,flags=___\n", 24, []int{12}},
+		{"ffprobe pict_type", "frame|pict_type=I\nframe|pict_type=B\n" +
+			"frame|pict_type=I\n", 0, []int{0, 2}},
+		{"ffprobe scene select", "frame|pts_time=4.0\nframe|pts_time=2.0\n",
+			25, []int{50, 100}},
+		{"empty", "", 24, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cuts, err := analysis.ReadSceneList(strings.NewReader(tt.list),
+				tt.frameRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cuts, tt.want) {
+				t.Errorf("got %v, want %v", cuts, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ReadSceneListErrors(t *testing.T) {
+	for _, list := range []string{
+		"12\n",
+		"-1 I\n",
+		"12 X\n",
+		"1 I 2 3\n",
+		"frame|pts_time=abc\n",
+		"frame|pts_time=-1\n",
+		"[FRAME]\nwidth=1920\n[/FRAME]\n",
+	} {
+		if _, err := analysis.ReadSceneList(strings.NewReader(list),
+			24); err == nil {
+			t.Errorf("%q: no error", list)
+		}
+	}
+}
+
+func Test_WriteSceneList(t *testing.T) {
+	var qpfile bytes.Buffer
+	err := analysis.WriteSceneList(&qpfile, []int{0, 24},
+		analysis.SceneListQPFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0 I -1\n24 I -1\n"; qpfile.String() != want {
+		t.Errorf("qpfile: got %q, want %q", qpfile.String(), want)
+	}
+
+	var ffprobe bytes.Buffer
+	err = analysis.WriteSceneList(&ffprobe, []int{48},
+		analysis.SceneListFFprobe, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[FRAME]\nkey_frame=1\npict_type=I\npts_time=2.000000\n[/FRAME]\n"
+	if ffprobe.String() != want {
+		t.Errorf("ffprobe: got %q, want %q", ffprobe.String(), want)
+	}
+
+	if err := analysis.WriteSceneList(&ffprobe, []int{0},
+		analysis.SceneListFFprobe, 0); err == nil {
+		t.Error("ffprobe list without a frame rate: no error")
+	}
+}
+
+func Test_SceneListRoundTrip(t *testing.T) {
+	cuts := []int{0, 37, 120, 1001}
+	for _, format := range []analysis.SceneListFormat{
+		analysis.SceneListQPFile, analysis.SceneListFFprobe} {
+		path := filepath.Join(t.TempDir(), "scenes.txt")
+		err := analysis.WriteSceneListFile(path, cuts, format, 23.976)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := analysis.ReadSceneListFile(path, 23.976)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, cuts) {
+			t.Errorf("%v: got %v, want %v", format, got, cuts)
+		}
+	}
+}
+
+func Test_ParseSceneListFormat(t *testing.T) {
+	for _, format := range []analysis.SceneListFormat{
+		analysis.SceneListQPFile, analysis.SceneListFFprobe} {
+		parsed, err := analysis.ParseSceneListFormat(format.String())
+		if err != nil || parsed != format {
+			t.Errorf("%v: got %v, %v", format, parsed, err)
+		}
+	}
+	if _, err := analysis.ParseSceneListFormat("edl"); err == nil {
+		t.Error("edl: no error")
+	}
+}
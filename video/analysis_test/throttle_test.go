@@ -0,0 +1,81 @@
+package analysis_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+// sample returns a GPU sample at second t, whose temperature follows its
+// clock.
+func sample(t, fps float64, clock int,
+	reasons ...string) analysis.GPUSample {
+	return analysis.GPUSample{Time: t, FPS: fps, Clock: clock,
+		Temperature: clock / 30, Reasons: reasons,
+		Throttled: len(reasons) > 0}
+}
+
+func Test_ThrottleEvents(t *testing.T) {
+	// Throttled stretches at the start, in the middle and at the end. The
+	// middle one reports why, the others are found by their clocks, below
+	// 85% of the 2000 MHz peak. The idle sample at 1000 MHz is not
+	// throttling.
+	timeline := []analysis.GPUSample{
+		sample(2, 50, 1500),
+		sample(4, 60, 2000),
+		sample(6, 80, 2000),
+		sample(8, 0, 1000),
+		sample(10, 40, 1950, "thermal slowdown"),
+		sample(12, 30, 1950, "power cap", "thermal slowdown"),
+		sample(14, 100, 2000),
+		sample(16, 20, 1200),
+		sample(18, 10, 1100),
+	}
+
+	events := analysis.ThrottleEvents(timeline)
+	want := []analysis.ThrottleEvent{
+		{Start: 2, End: 2, MinClock: 1500, MaxTemperature: 50,
+			FPSBefore: 0, FPSDuring: 50},
+		{Start: 10, End: 12, MinClock: 1950, MaxTemperature: 65,
+			Reasons:   []string{"thermal slowdown", "power cap"},
+			FPSBefore: (60 + 80 + 0) / 3.0, FPSDuring: 35},
+		{Start: 16, End: 18, MinClock: 1100, MaxTemperature: 40,
+			FPSBefore: (60 + 80 + 0 + 100) / 4.0, FPSDuring: 15},
+	}
+
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(events), events,
+			len(want))
+	}
+	for i, w := range want {
+		got := events[i]
+		if got.Start != w.Start || got.End != w.End ||
+			got.MinClock != w.MinClock ||
+			got.MaxTemperature != w.MaxTemperature ||
+			!slices.Equal(got.Reasons, w.Reasons) ||
+			got.FPSBefore != w.FPSBefore || got.FPSDuring != w.FPSDuring {
+			t.Errorf("event %d: got %+v, want %+v", i, got, w)
+		}
+	}
+
+	// The samples found by their clocks are marked as throttled.
+	for i, throttled := range []bool{true, false, false, false, true, true,
+		false, true, true} {
+		if timeline[i].Throttled != throttled {
+			t.Errorf("sample %d: throttled %v, want %v", i,
+				timeline[i].Throttled, throttled)
+		}
+	}
+}
+
+func Test_ThrottleEventsSteady(t *testing.T) {
+	timeline := []analysis.GPUSample{sample(2, 50, 2000),
+		sample(4, 50, 1900)}
+	if events := analysis.ThrottleEvents(timeline); len(events) != 0 {
+		t.Errorf("got %+v, want no events", events)
+	}
+	if events := analysis.ThrottleEvents(nil); len(events) != 0 {
+		t.Errorf("empty timeline: got %+v", events)
+	}
+}
@@ -0,0 +1,172 @@
+package av1an
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// Chunk is a frame range of the reference and the encode of that range.
+type Chunk struct {
+	// The whole reference video. It must be seekable unless the range
+	// starts at its first frame and covers all of it.
+	Reference video.Source
+	// The frames of Reference the chunk was encoded from.
+	Frames video.FrameRange
+	// The encoded chunk, whose first frame matches Frames.Start.
+	Distortion video.Source
+}
+
+// Options configures ScoreChunk.
+type Options struct {
+	// NewMetrics returns the metrics a chunk is scored with, given the
+	// reference range and the encoded chunk. They are closed once the chunk
+	// is scored.
+	NewMetrics func(reference, distortion video.Source) ([]video.Metric,
+		error)
+	// Stat pools the per-frame scores. Defaults to the mean.
+	Stat Stat
+	// Score every ProbingRate-th frame only, like av1an's --probing-rate.
+	// Defaults to 1. Above 1 both sources must be seekable.
+	ProbingRate int
+	// Frame threads for the comparator. Defaults to 1.
+	FrameThreads int
+}
+
+func (o *Options) setDefaults() {
+	if o.ProbingRate < 1 {
+		o.ProbingRate = 1
+	}
+	if o.FrameThreads < 1 {
+		o.FrameThreads = 1
+	}
+}
+
+func (o *Options) validate() error {
+	if o.NewMetrics == nil {
+		return errors.New("no metric constructor was given")
+	}
+	return nil
+}
+
+// Result holds the scores of a chunk.
+type Result struct {
+	// The pooled score of every metric key.
+	Scores map[string]float64 `json:"scores"`
+	// The per-frame scores of every metric key, one per scored frame.
+	Frames map[string][]float64 `json:"frames"`
+	// The chunk-relative number of every scored frame.
+	FrameIndices []int `json:"frame_indices"`
+}
+
+// Score returns the pooled score of key, or the only pooled score if key is
+// empty and the metrics reported a single key.
+func (r *Result) Score(key string) (float64, error) {
+	if key == "" {
+		if len(r.Scores) != 1 {
+			return 0, fmt.Errorf("the chunk has %d scores, pick one of %v",
+				len(r.Scores), slices.Sorted(maps.Keys(r.Scores)))
+		}
+		for _, score := range r.Scores {
+			return score, nil
+		}
+	}
+
+	score, ok := r.Scores[key]
+	if !ok {
+		return 0, fmt.Errorf("no score %q, pick one of %v", key,
+			slices.Sorted(maps.Keys(r.Scores)))
+	}
+	return score, nil
+}
+
+// ScoreChunk compares chunk.Distortion against its range of chunk.Reference
+// and pools the per-frame scores of every metric with opts.Stat. Only the
+// frames both have are compared, so a chunk missing trailing frames is still
+// scored.
+func ScoreChunk(ctx context.Context, chunk Chunk, opts Options) (*Result,
+	error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	reference, err := chunkReference(chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	numFrames := min(reference.GetNumFrames(),
+		chunk.Distortion.GetNumFrames())
+	if numFrames < 1 {
+		return nil, errors.New("the chunk has no frames")
+	}
+
+	metrics, err := opts.NewMetrics(reference, chunk.Distortion)
+	if err != nil {
+		return nil, err
+	}
+
+	comp, err := comparator.NewComparator(reference, chunk.Distortion,
+		metrics, opts.FrameThreads, numFrames, comparator.WithSharedSources())
+	if err != nil {
+		for _, metric := range metrics {
+			metric.Close()
+		}
+		return nil, err
+	}
+	defer comp.Close()
+
+	if opts.ProbingRate > 1 {
+		var indices []int
+		for i := 0; i < numFrames; i += opts.ProbingRate {
+			indices = append(indices, i)
+		}
+		if err = comp.SetFrameIndices(indices); err != nil {
+			return nil, fmt.Errorf("probing rate %d: %w", opts.ProbingRate,
+				err)
+		}
+	}
+
+	frames, err := comp.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Scores: make(map[string]float64, len(frames)),
+		Frames: frames, FrameIndices: comp.FrameIndices()}
+	for key, scores := range frames {
+		if result.Scores[key], err = opts.Stat.Pool(scores); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return result, nil
+}
+
+// chunkReference returns the frames of the reference the chunk was encoded
+// from.
+func chunkReference(chunk Chunk) (video.Source, error) {
+	if chunk.Reference == nil || chunk.Distortion == nil {
+		return nil, errors.New("a chunk needs a reference and a distortion")
+	}
+
+	total := chunk.Reference.GetNumFrames()
+	frames := chunk.Frames
+	if frames.Start < 0 || frames.Count < 1 ||
+		frames.Start+frames.Count > total {
+		return nil, fmt.Errorf("frames [%d, %d) are outside of the %d "+
+			"reference frames", frames.Start, frames.Start+frames.Count, total)
+	}
+
+	if frames.Start == 0 && frames.Count == total {
+		return chunk.Reference, nil
+	}
+	return sources.Slice(chunk.Reference, frames.Start, frames.Count)
+}
@@ -0,0 +1,7 @@
+// Package av1an scores encoded chunks for the target-quality loops of
+// chunked encoders such as av1an. A chunk is a frame range of the reference
+// and its encode: ScoreChunk compares the two with any gometrics metric, vship
+// backed or not, and pools the per-frame scores with the statistics av1an's
+// --probing-stat accepts, so an encoder can use the pooled score wherever it
+// would otherwise run its own metric.
+package av1an
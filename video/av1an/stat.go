@@ -0,0 +1,132 @@
+package av1an
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StatKind selects how a Stat pools per-frame scores.
+type StatKind int
+
+const (
+	StatMean StatKind = iota
+	StatHarmonicMean
+	StatRootMeanSquare
+	StatMedian
+	StatPercentile
+	StatMinimum
+	StatMaximum
+)
+
+// Stat pools the per-frame scores of a chunk into one score, like av1an's
+// --probing-stat.
+type Stat struct {
+	Kind StatKind
+	// Percentile in [0, 100] for StatPercentile, counted from the lowest
+	// score.
+	Percentile float64
+}
+
+// ParseStat parses a statistic in av1an's --probing-stat syntax: mean,
+// harmonic, root-mean-square, median, percentile=<p>, minimum or maximum.
+func ParseStat(text string) (Stat, error) {
+	kinds := map[string]StatKind{
+		"mean":             StatMean,
+		"harmonic":         StatHarmonicMean,
+		"root-mean-square": StatRootMeanSquare,
+		"median":           StatMedian,
+		"minimum":          StatMinimum,
+		"maximum":          StatMaximum,
+	}
+	if kind, ok := kinds[text]; ok {
+		return Stat{Kind: kind}, nil
+	}
+
+	value, ok := strings.CutPrefix(text, "percentile=")
+	if !ok {
+		return Stat{}, fmt.Errorf("unknown probing stat %q", text)
+	}
+	percentile, err := strconv.ParseFloat(value, 64)
+	if err != nil || percentile < 0 || percentile > 100 {
+		return Stat{}, fmt.Errorf("percentile must be in [0, 100], got %q",
+			value)
+	}
+	return Stat{Kind: StatPercentile, Percentile: percentile}, nil
+}
+
+func (s Stat) String() string {
+	switch s.Kind {
+	case StatHarmonicMean:
+		return "harmonic"
+	case StatRootMeanSquare:
+		return "root-mean-square"
+	case StatMedian:
+		return "median"
+	case StatPercentile:
+		return "percentile=" + strconv.FormatFloat(s.Percentile, 'g', -1, 64)
+	case StatMinimum:
+		return "minimum"
+	case StatMaximum:
+		return "maximum"
+	default:
+		return "mean"
+	}
+}
+
+// Pool returns the statistic of scores. NaN scores are left out, and an
+// error is returned when no score is left. The harmonic mean is only defined
+// for positive scores and returns an error otherwise.
+func (s Stat) Pool(scores []float64) (float64, error) {
+	values := slices.DeleteFunc(slices.Clone(scores), math.IsNaN)
+	if len(values) == 0 {
+		return 0, errors.New("no score to pool")
+	}
+
+	switch s.Kind {
+	case StatHarmonicMean:
+		var sum float64
+		for _, v := range values {
+			if v <= 0 {
+				return 0, fmt.Errorf("the harmonic mean needs positive "+
+					"scores, got %g", v)
+			}
+			sum += 1 / v
+		}
+		return float64(len(values)) / sum, nil
+	case StatRootMeanSquare:
+		var sum float64
+		for _, v := range values {
+			sum += v * v
+		}
+		return math.Sqrt(sum / float64(len(values))), nil
+	case StatMedian:
+		return percentile(values, 50), nil
+	case StatPercentile:
+		return percentile(values, s.Percentile), nil
+	case StatMinimum:
+		return slices.Min(values), nil
+	case StatMaximum:
+		return slices.Max(values), nil
+	default:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values)), nil
+	}
+}
+
+// percentile returns the p-th percentile of values, interpolating linearly
+// between the closest ranks. values is sorted in place.
+func percentile(values []float64, p float64) float64 {
+	slices.Sort(values)
+
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := min(lower+1, len(values)-1)
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,56 @@
+package av1an_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video/av1an"
+)
+
+func Test_StatPool(t *testing.T) {
+	scores := []float64{4, math.NaN(), 1, 2}
+	tests := []struct {
+		stat string
+		want float64
+	}{
+		{"mean", 7.0 / 3},
+		{"harmonic", 3 / 1.75},
+		{"root-mean-square", math.Sqrt(7)},
+		{"median", 2},
+		{"percentile=25", 1.5},
+		{"minimum", 1},
+		{"maximum", 4},
+	}
+	for _, test := range tests {
+		stat, err := av1an.ParseStat(test.stat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := stat.Pool(scores)
+		if err != nil {
+			t.Errorf("%s: %v", test.stat, err)
+		} else if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: Pool() = %g, want %g", test.stat, got, test.want)
+		}
+	}
+}
+
+func Test_StatPoolUndefined(t *testing.T) {
+	tests := []struct {
+		name   string
+		stat   av1an.Stat
+		scores []float64
+	}{
+		{"empty", av1an.Stat{}, nil},
+		{"only NaN", av1an.Stat{}, []float64{math.NaN()}},
+		{"harmonic zero", av1an.Stat{Kind: av1an.StatHarmonicMean},
+			[]float64{3, 0}},
+		{"harmonic negative", av1an.Stat{Kind: av1an.StatHarmonicMean},
+			[]float64{-1, 2}},
+	}
+	for _, test := range tests {
+		if got, err := test.stat.Pool(test.scores); err == nil {
+			t.Errorf("%s: Pool() = %g, want an error", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// ScoreDirection tells which way the scores of a metric improve.
+type ScoreDirection int
+
+const (
+	// DirectionUnknown is the direction of metrics that do not report one.
+	DirectionUnknown ScoreDirection = iota
+	// HigherIsBetter is the direction of quality scores such as SSIMULACRA2.
+	HigherIsBetter
+	// LowerIsBetter is the direction of distances such as Butteraugli.
+	LowerIsBetter
+)
+
+func (d ScoreDirection) String() string {
+	switch d {
+	case DirectionUnknown:
+		return "unknown"
+	case HigherIsBetter:
+		return "higher is better"
+	case LowerIsBetter:
+		return "lower is better"
+	default:
+		return fmt.Sprintf("ScoreDirection(%d)", int(d))
+	}
+}
+
+// Capabilities describes what a metric can do and what it needs, so callers
+// can validate a comparison before running it and document the metric
+// without computing a frame.
+type Capabilities struct {
+	// DistortionMap is set if the metric can produce per-pixel distortion
+	// maps.
+	DistortionMap bool
+	// Sequential is set if the metric must see every frame pair in order,
+	// see SequentialMetric.
+	Sequential bool
+	// BitDepths lists the bit depths the metric reads, nil for any.
+	BitDepths []int
+	// MixedBitDepths is set if the metric can compare sources of different
+	// bit depths, see BitDepthChecker.
+	MixedBitDepths bool
+	// GPU is set if the metric needs a GPU.
+	GPU bool
+	// NoReference is set if the metric scores the distortion alone, so it
+	// can score a single source.
+	NoReference bool
+	// MinScore and MaxScore bound the scores of the metric, infinite for an
+	// open end.
+	MinScore, MaxScore float64
+	// Direction tells which way the scores improve.
+	Direction ScoreDirection
+	// Format tells how scores are pooled and displayed.
+	Format ScoreFormat
+}
+
+// SupportsBitDepth reports whether the metric reads depth-bit samples.
+func (c Capabilities) SupportsBitDepth(depth int) bool {
+	return c.BitDepths == nil || slices.Contains(c.BitDepths, depth)
+}
+
+// CapabilityReporter is implemented by metrics that describe their
+// Capabilities.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// MetricCapabilities returns the capabilities of metric. Metrics that do not
+// implement CapabilityReporter are described by the optional interfaces they
+// implement, with unbounded scores of unknown direction.
+func MetricCapabilities(metric Metric) Capabilities {
+	if reporter, ok := metric.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+
+	capabilities := Capabilities{MinScore: math.Inf(-1),
+		MaxScore: math.Inf(1)}
+	if sequential, ok := metric.(SequentialMetric); ok {
+		capabilities.Sequential = sequential.Sequential()
+	}
+	return capabilities
+}
@@ -0,0 +1,155 @@
+package color
+
+import (
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// lumaCoefficients returns the Kr and Kb coefficients of a YUV matrix.
+func lumaCoefficients(cs pixfmts.ColorSpace) (kr, kb float64, err error) {
+	switch cs {
+	case pixfmts.ColorSpaceBT709:
+		return 0.2126, 0.0722, nil
+	case pixfmts.ColorSpaceBT470BG, pixfmts.ColorSpaceSMPTE170M:
+		return 0.299, 0.114, nil
+	case pixfmts.ColorSpaceFCC:
+		return 0.30, 0.11, nil
+	case pixfmts.ColorSpaceSMPTE240M:
+		return 0.212, 0.087, nil
+	case pixfmts.ColorSpaceBT2020_NCL:
+		return 0.2627, 0.0593, nil
+	default:
+		return 0, 0, fmt.Errorf("no CPU conversion for matrix %s",
+			MatrixName(cs))
+	}
+}
+
+// pixelFunc converts one normalized Y'CbCr sample of the input into R'G'B' in
+// the output's transfer and primaries.
+type pixelFunc func(y, cb, cr float64) (r, g, b float64)
+
+// yuvConverter runs a pixelFunc over every sample of a planar YUV frame and
+// re-encodes the result with the output matrix and range. The pixel format
+// and dimensions are unchanged. It is safe for concurrent use.
+type yuvConverter struct {
+	in, out video.ColorProperties
+
+	depth        int
+	wide         bool
+	log2W, log2H int
+
+	// Output YUV matrix coefficients.
+	outKr, outKb float64
+
+	pixel pixelFunc
+}
+
+func newYUVConverter(in, out video.ColorProperties, pixel pixelFunc) (
+	*yuvConverter, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(in.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+	if pixFmtDesc.Flags()&uint64(pixfmts.PixFmtFlagRGB) != 0 ||
+		pixFmtDesc.NbComponents() < 3 {
+		return nil, fmt.Errorf("CPU color conversion requires planar YUV "+
+			"input, got %s", pixFmtDesc.Name())
+	}
+
+	depth, err := in.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &yuvConverter{in: in, out: out, depth: depth, wide: depth > 8,
+		log2W: pixFmtDesc.Log2ChromaW(), log2H: pixFmtDesc.Log2ChromaH(),
+		pixel: pixel}
+
+	if c.outKr, c.outKb, err = lumaCoefficients(out.ColorSpace); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// decodeYUV converts a normalized Y'CbCr sample to R'G'B' with the given
+// matrix coefficients.
+func decodeYUV(y, cb, cr, kr, kb float64) (r, g, b float64) {
+	r = y + 2*(1-kr)*cr
+	b = y + 2*(1-kb)*cb
+	g = (y - kr*r - kb*b) / (1 - kr - kb)
+	return r, g, b
+}
+
+// convert writes the converted src into dst. Each output chroma sample is the
+// average of the chroma of the converted luma samples it covers. An alpha
+// plane is copied unchanged.
+func (c *yuvConverter) convert(dst, src *video.Frame) {
+	inQ := newQuantizer(c.depth, c.in.ColorRange)
+	outQ := newQuantizer(c.depth, c.out.ColorRange)
+
+	srcY, srcU, srcV := src.PlaneData(0), src.PlaneData(1), src.PlaneData(2)
+	dstY, dstU, dstV := dst.PlaneData(0), dst.PlaneData(1), dst.PlaneData(2)
+
+	sampleSize := 1
+	if c.wide {
+		sampleSize = 2
+	}
+	srcYStride, srcCStride := src.PlaneLineSize(0)/sampleSize,
+		src.PlaneLineSize(1)/sampleSize
+	dstYStride, dstCStride := dst.PlaneLineSize(0)/sampleSize,
+		dst.PlaneLineSize(1)/sampleSize
+
+	kr, kb := c.outKr, c.outKb
+	kg := 1 - kr - kb
+
+	chromaW := (c.in.Width + 1<<c.log2W - 1) >> c.log2W
+	chromaH := (c.in.Height + 1<<c.log2H - 1) >> c.log2H
+
+	for cy := range chromaH {
+		for cx := range chromaW {
+			srcC := cy*srcCStride + cx
+			cb := inQ.chroma(readSample(srcU, srcC, c.wide))
+			cr := inQ.chroma(readSample(srcV, srcC, c.wide))
+
+			var sumCb, sumCr float64
+			var count int
+
+			for dy := range 1 << c.log2H {
+				y := cy<<c.log2H + dy
+				if y >= c.in.Height {
+					break
+				}
+				for dx := range 1 << c.log2W {
+					x := cx<<c.log2W + dx
+					if x >= c.in.Width {
+						break
+					}
+
+					luma := inQ.luma(readSample(srcY, y*srcYStride+x, c.wide))
+					r, g, b := c.pixel(luma, cb, cr)
+
+					outY := kr*r + kg*g + kb*b
+					sumCb += (b - outY) / (2 * (1 - kb))
+					sumCr += (r - outY) / (2 * (1 - kr))
+					count++
+
+					writeSample(dstY, y*dstYStride+x, c.wide,
+						outQ.lumaCode(outY))
+				}
+			}
+
+			dstC := cy*dstCStride + cx
+			writeSample(dstU, dstC, c.wide,
+				outQ.chromaCode(sumCb/float64(count)))
+			writeSample(dstV, dstC, c.wide,
+				outQ.chromaCode(sumCr/float64(count)))
+		}
+	}
+
+	if src.NumPlanes() > 3 {
+		copy(dst.PlaneData(3), src.PlaneData(3))
+	}
+}
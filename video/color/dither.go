@@ -0,0 +1,282 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/internal/pixops"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// Dither selects how Requantize rounds samples to the lower bit depth.
+type Dither int
+
+const (
+	// DitherRound rounds every sample to the nearest code, the way a
+	// straight bit depth conversion bands smooth gradients.
+	DitherRound Dither = iota
+	// DitherErrorDiffusion spreads the rounding error of every sample over
+	// its unvisited neighbours with Floyd-Steinberg weights, the way encoders
+	// fed by a dithering scaler see their input. It is deterministic.
+	DitherErrorDiffusion
+)
+
+func (d Dither) String() string {
+	switch d {
+	case DitherRound:
+		return "round"
+	case DitherErrorDiffusion:
+		return "error-diffusion"
+	default:
+		return fmt.Sprintf("Dither(%d)", int(d))
+	}
+}
+
+// ParseDither parses the name of a Dither as returned by its String method.
+func ParseDither(name string) (Dither, error) {
+	switch name {
+	case "round":
+		return DitherRound, nil
+	case "error-diffusion":
+		return DitherErrorDiffusion, nil
+	default:
+		return 0, fmt.Errorf("unknown dither %q, want round or "+
+			"error-diffusion", name)
+	}
+}
+
+// requantizedSource wraps a planar source and delivers its frames at a lower
+// bit depth.
+type requantizedSource struct {
+	sources.Passthrough
+	props  video.ColorProperties
+	dither Dither
+
+	numPlanes int
+	// The samples per row and rows of each plane.
+	widths, rows [video.MaxPlanes]int
+	// Every plane maps an input code c to the output code scale*c + offset
+	// before rounding, and clamps it to [0, maxCode].
+	scale, offset [video.MaxPlanes]float64
+	maxCode       float64
+	inWide        bool
+	outWide       bool
+	// narrow is set for planes whose codes only lose their low shift bits,
+	// which DitherRound converts with pixops.Narrow.
+	narrow [video.MaxPlanes]bool
+	shift  uint
+
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// scratch receives the frame from source, and errs holds the diffused
+	// error of the current and next row for DitherErrorDiffusion.
+	scratch video.Frame
+	errs    [2][]float64
+}
+
+// Requantize returns a source delivering the frames of source at depth bits
+// per sample, so a high bit depth reference carries the same quantization as
+// a lower bit depth encode instead of relying on how the metric backend
+// scales it. Samples keep their meaning: limited range codes are shifted and
+// full range codes scaled to the new maximum, then rounded as dither selects.
+// source is returned unchanged if it already has depth bits.
+//
+// Returns an error for packed formats, which must go through Planarize first,
+// and if depth is above that of source or has no matching pixel format.
+func Requantize(source video.Source, depth int, dither Dither) (video.Source,
+	error) {
+	in := *source.GetColorProps()
+
+	inDepth, err := in.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+	if depth == inDepth {
+		return source, nil
+	}
+	if depth > inDepth || depth < 8 {
+		return nil, fmt.Errorf("cannot requantize %d bit samples to %d bits",
+			inDepth, depth)
+	}
+
+	layout, err := in.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout == video.LayoutPacked {
+		return nil, errors.New("cannot requantize packed frames, planarize " +
+			"them first")
+	}
+
+	out := in
+	if out.PixelFormat, err = pixelFormatAtDepth(in.PixelFormat,
+		depth); err != nil {
+		return nil, err
+	}
+
+	s := &requantizedSource{Passthrough: sources.Passthrough{Source: source},
+		props: out, dither: dither, numPlanes: layout.NumPlanes(),
+		maxCode: float64(int(1)<<depth - 1), inWide: inDepth > 8,
+		outWide: depth > 8, shift: uint(inDepth - depth)}
+
+	inQ, outQ := newQuantizer(inDepth, in.ColorRange),
+		newQuantizer(depth, in.ColorRange)
+	rowBytes, rows, _, err := out.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+	yuv := layout == video.LayoutYUV || layout == video.LayoutYUVA
+	for i := range s.numPlanes {
+		switch {
+		case i == 3 || i == 1 && layout == video.LayoutGray:
+			// Alpha is always full range.
+			s.scale[i] = outQ.maxCode / inQ.maxCode
+		case (i == 1 || i == 2) && yuv:
+			s.scale[i] = outQ.cScale / inQ.cScale
+			s.offset[i] = outQ.cOffset - inQ.cOffset*s.scale[i]
+		default:
+			s.scale[i] = outQ.yScale / inQ.yScale
+			s.offset[i] = outQ.yOffset - inQ.yOffset*s.scale[i]
+		}
+
+		s.narrow[i] = !s.outWide && s.offset[i] == 0 &&
+			s.scale[i] == 1/float64(int(1)<<s.shift)
+
+		s.rows[i] = rows[i]
+		s.widths[i] = rowBytes[i]
+		if s.outWide {
+			s.widths[i] /= 2
+		}
+		s.planeStrides[i] = rowBytes[i]
+		s.planeSizes[i] = rowBytes[i] * rows[i]
+	}
+
+	if s.scratch, err = video.NewFrameFor(source); err != nil {
+		return nil, err
+	}
+	if dither == DitherErrorDiffusion {
+		for i := range s.errs {
+			s.errs[i] = make([]float64, s.widths[0]+2)
+		}
+	}
+	return s, nil
+}
+
+// depthSuffix matches the bit depth and endianness at the end of the name of
+// a pixel format wider than 8 bits, such as "10le" in "yuv420p10le".
+var depthSuffix = regexp.MustCompile(`^(.*[a-z])\d+(le|be)$`)
+
+// pixelFormatAtDepth returns the pixel format with the layout of pf and depth
+// bits per sample, little endian.
+func pixelFormatAtDepth(pf pixfmts.PixelFormat, depth int) (
+	pixfmts.PixelFormat, error) {
+	desc, err := pixfmts.PixFmtDescGet(pf)
+	if err != nil {
+		return 0, err
+	}
+
+	name := desc.Name()
+	if match := depthSuffix.FindStringSubmatch(name); match != nil {
+		name = match[1]
+	}
+	if depth > 8 {
+		name += strconv.Itoa(depth) + "le"
+	}
+
+	out, err := pixfmts.GetPixFmt(name)
+	if err != nil {
+		return 0, fmt.Errorf("no %d bit variant of %s: %w", depth,
+			desc.Name(), err)
+	}
+	return out, nil
+}
+
+func (s *requantizedSource) GetFrame(frame video.Frame) error {
+	if err := s.Source.GetFrame(s.scratch); err != nil {
+		return err
+	}
+
+	for plane := range s.numPlanes {
+		if dst := frame.PlaneData(plane); len(dst) < s.planeSizes[plane] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", plane, s.planeSizes[plane], len(dst))
+		}
+
+		switch {
+		case s.dither == DitherErrorDiffusion:
+			s.diffuse(&frame, plane)
+			continue
+		case s.narrow[plane]:
+			for y := range s.rows[plane] {
+				pixops.Narrow(frame.Row(plane, y),
+					s.scratch.Row(plane, y)[:2*s.widths[plane]], s.shift)
+			}
+			continue
+		}
+		for y := range s.rows[plane] {
+			src, dst := s.scratch.Row(plane, y), frame.Row(plane, y)
+			for x := range s.widths[plane] {
+				writeSample(dst, x, s.outWide,
+					s.code(s.value(plane, src, x)))
+			}
+		}
+	}
+
+	frame.CopyMetadataFrom(&s.scratch)
+	return nil
+}
+
+// diffuse requantizes a plane into frame with Floyd-Steinberg error
+// diffusion, scanning every row left to right.
+func (s *requantizedSource) diffuse(frame *video.Frame, plane int) {
+	cur, next := s.errs[0][:s.widths[plane]+2], s.errs[1][:s.widths[plane]+2]
+	clear(cur)
+
+	for y := range s.rows[plane] {
+		clear(next)
+		src, dst := s.scratch.Row(plane, y), frame.Row(plane, y)
+		for x := range s.widths[plane] {
+			// Index x+1 of the error rows is sample x.
+			value := s.value(plane, src, x) + cur[x+1]
+			code := s.code(value)
+			writeSample(dst, x, s.outWide, code)
+
+			e := value - float64(code)
+			cur[x+2] += e * 7 / 16
+			next[x] += e * 3 / 16
+			next[x+1] += e * 5 / 16
+			next[x+2] += e * 1 / 16
+		}
+		cur, next = next, cur
+	}
+}
+
+// value returns sample x of a row of the wrapped source as an unrounded code
+// of the output depth.
+func (s *requantizedSource) value(plane int, row []byte, x int) float64 {
+	return float64(readSample(row, x, s.inWide))*s.scale[plane] +
+		s.offset[plane]
+}
+
+// code rounds value to the nearest output code.
+func (s *requantizedSource) code(value float64) int {
+	return int(min(max(math.Round(value), 0), s.maxCode))
+}
+
+func (s *requantizedSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *requantizedSource) GetNumFrames() int                     { return s.Source.GetNumFrames() }
+func (s *requantizedSource) GetFrameRate() float32                 { return s.Source.GetFrameRate() }
+
+func (s *requantizedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close closes the wrapped source.
+func (s *requantizedSource) Close() error { return s.Source.Close() }
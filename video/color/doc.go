@@ -0,0 +1,13 @@
+// Package color maps a source's color properties onto what a metric backend
+// can consume. NewPlan builds an explicit conversion plan per attribute
+// (matrix, transfer, primaries, range and chroma location), relabeling
+// equivalent values, converting frames on the CPU when the backend cannot,
+// and reporting unsupported combinations as errors. The package also provides
+// BT.2390 tone-mapping for comparing HDR against SDR, and Requantize for
+// comparing a high bit depth reference against a lower bit depth encode with
+// a defined rounding policy.
+//
+// Convert, Converter, ToLinear and FromLinear expose the CPU conversion to
+// R'G'B' and linear light, so metrics computed on the CPU share one
+// implementation of every matrix and transfer function.
+package color
@@ -0,0 +1,104 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+var (
+	ErrUnspecified = errors.New("color metadata is unspecified")
+)
+
+// Inference holds the values NewPlan assumes for attributes a source leaves
+// unspecified. A field left at its Unspecified value makes NewPlan return
+// ErrUnspecified instead of guessing. Attributes the source does specify are
+// never overridden.
+//
+// The zero value is not useful as the zero matrix is RGB. Start from
+// PermissiveInference or StrictInference and override individual fields.
+type Inference struct {
+	Matrix         pixfmts.ColorSpace
+	Transfer       pixfmts.ColorTransferCharacteristic
+	Primaries      pixfmts.ColorPrimaries
+	Range          pixfmts.ColorRange
+	ChromaLocation pixfmts.ChromaLocation
+}
+
+// PermissiveInference assumes BT.709, limited range and left chroma siting,
+// which is what untagged content most commonly is.
+func PermissiveInference() Inference {
+	return Inference{
+		Matrix:         pixfmts.ColorSpaceBT709,
+		Transfer:       pixfmts.ColorTransferCharacteristicBT709,
+		Primaries:      pixfmts.ColorPrimariesBT709,
+		Range:          pixfmts.ColorRangeMPEG,
+		ChromaLocation: pixfmts.ChromaLocationLeft,
+	}
+}
+
+// StrictInference assumes nothing about the matrix, transfer, primaries and
+// range. Chroma location is still assumed to be left, as almost no content
+// tags it and a wrong guess only shifts chroma by half a sample.
+func StrictInference() Inference {
+	return Inference{
+		Matrix:         pixfmts.ColorSpaceUnspecified,
+		Transfer:       pixfmts.ColorTransferCharacteristicUnspecified,
+		Primaries:      pixfmts.ColorPrimariesUnspecified,
+		Range:          pixfmts.ColorRangeUnspecified,
+		ChromaLocation: pixfmts.ChromaLocationLeft,
+	}
+}
+
+// resolve fills the unspecified attributes of in. The returned set holds the
+// names of the attributes that were assumed. The matrix of an RGB pixel format
+// is never needed, so it is not required even in strict mode.
+func (i Inference) resolve(in video.ColorProperties, rgb bool) (
+	video.ColorProperties, map[string]bool, error) {
+	assumed := make(map[string]bool)
+
+	var missing []string
+	fill := func(attribute string, unspecified, assumption bool) bool {
+		if !unspecified {
+			return false
+		}
+		if !assumption {
+			missing = append(missing, attribute)
+			return false
+		}
+		assumed[attribute] = true
+		return true
+	}
+
+	if fill("matrix", in.ColorSpace == pixfmts.ColorSpaceUnspecified && !rgb,
+		i.Matrix != pixfmts.ColorSpaceUnspecified) {
+		in.ColorSpace = i.Matrix
+	}
+	if fill("transfer",
+		in.ColorTransfer == pixfmts.ColorTransferCharacteristicUnspecified,
+		i.Transfer != pixfmts.ColorTransferCharacteristicUnspecified) {
+		in.ColorTransfer = i.Transfer
+	}
+	if fill("primaries", in.ColorPrimaries == pixfmts.ColorPrimariesUnspecified,
+		i.Primaries != pixfmts.ColorPrimariesUnspecified) {
+		in.ColorPrimaries = i.Primaries
+	}
+	if fill("range", in.ColorRange == pixfmts.ColorRangeUnspecified,
+		i.Range != pixfmts.ColorRangeUnspecified) {
+		in.ColorRange = i.Range
+	}
+	if fill("chroma location",
+		in.ChromaLocation == pixfmts.ChromaLocationUnspecified,
+		i.ChromaLocation != pixfmts.ChromaLocationUnspecified) {
+		in.ChromaLocation = i.ChromaLocation
+	}
+
+	if len(missing) > 0 {
+		return in, nil, fmt.Errorf("%w: %v not tagged and no assumption was "+
+			"given", ErrUnspecified, missing)
+	}
+
+	return in, assumed, nil
+}
@@ -0,0 +1,112 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+var (
+	ErrMismatch = errors.New("sources have different color properties")
+)
+
+// Mismatch is a color attribute that differs between the reference and the
+// distortion.
+type Mismatch struct {
+	Attribute             string
+	Reference, Distortion string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: reference %s, distortion %s", m.Attribute,
+		m.Reference, m.Distortion)
+}
+
+// Mismatches is the result of CompareProperties.
+type Mismatches []Mismatch
+
+// Err returns nil if there are no mismatches, otherwise an error wrapping
+// ErrMismatch that lists them.
+func (ms Mismatches) Err() error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	parts := make([]string, len(ms))
+	for i, m := range ms {
+		parts[i] = m.String()
+	}
+
+	return fmt.Errorf("%w: %s", ErrMismatch, strings.Join(parts, "; "))
+}
+
+// CompareProperties compares the matrix, transfer, primaries, range and chroma
+// subsampling of two sources. Differences here usually mean one side was
+// converted without retagging, or that the comparison measures color
+// conversion loss alongside encoding loss.
+//
+// Pass Plan.Resolved for each source so that assumed values are compared
+// rather than unspecified ones.
+func CompareProperties(ref, dist video.ColorProperties) (Mismatches, error) {
+	var ms Mismatches
+
+	add := func(attribute, a, b string) {
+		if a != b {
+			ms = append(ms, Mismatch{attribute, a, b})
+		}
+	}
+
+	add("matrix", MatrixName(ref.ColorSpace), MatrixName(dist.ColorSpace))
+	add("transfer", TransferName(ref.ColorTransfer),
+		TransferName(dist.ColorTransfer))
+	add("primaries", PrimariesName(ref.ColorPrimaries),
+		PrimariesName(dist.ColorPrimaries))
+	add("range", RangeName(ref.ColorRange), RangeName(dist.ColorRange))
+
+	refSubsampling, err := subsamplingName(ref.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+	distSubsampling, err := subsamplingName(dist.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+	add("subsampling", refSubsampling, distSubsampling)
+
+	return ms, nil
+}
+
+// subsamplingName returns the J:a:b notation of a pixel format's chroma
+// subsampling, or "rgb" for RGB formats.
+func subsamplingName(pf pixfmts.PixelFormat) (string, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(pf)
+	if err != nil {
+		return "", err
+	}
+
+	if pixFmtDesc.Flags()&uint64(pixfmts.PixFmtFlagRGB) != 0 {
+		return "rgb", nil
+	}
+	if pixFmtDesc.NbComponents() < 3 {
+		return "gray", nil
+	}
+
+	switch [2]int{pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH()} {
+	case [2]int{0, 0}:
+		return "4:4:4", nil
+	case [2]int{1, 0}:
+		return "4:2:2", nil
+	case [2]int{1, 1}:
+		return "4:2:0", nil
+	case [2]int{2, 0}:
+		return "4:1:1", nil
+	case [2]int{2, 2}:
+		return "4:1:0", nil
+	default:
+		return fmt.Sprintf("%dx%d", 1<<pixFmtDesc.Log2ChromaW(),
+			1<<pixFmtDesc.Log2ChromaH()), nil
+	}
+}
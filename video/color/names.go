@@ -0,0 +1,173 @@
+package color
+
+import (
+	"fmt"
+	"strings"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// Short names used in plans, error messages and encoder arguments. They follow
+// FFmpeg's naming so they can be matched against ffprobe output. libavutil's
+// own name lookup is not available without cgo.
+
+var matrixNames = map[pixfmts.ColorSpace]string{
+	pixfmts.ColorSpaceRGB:         "gbr",
+	pixfmts.ColorSpaceBT709:       "bt709",
+	pixfmts.ColorSpaceUnspecified: "unknown",
+	pixfmts.ColorSpaceFCC:         "fcc",
+	pixfmts.ColorSpaceBT470BG:     "bt470bg",
+	pixfmts.ColorSpaceSMPTE170M:   "smpte170m",
+	pixfmts.ColorSpaceSMPTE240M:   "smpte240m",
+	pixfmts.ColorSpaceYCGCO:       "ycgco",
+	pixfmts.ColorSpaceBT2020_NCL:  "bt2020nc",
+	pixfmts.ColorSpaceBT2020_CL:   "bt2020c",
+	pixfmts.ColorSpaceICTCP:       "ictcp",
+}
+
+var transferNames = map[pixfmts.ColorTransferCharacteristic]string{
+	pixfmts.ColorTransferCharacteristicBT709:        "bt709",
+	pixfmts.ColorTransferCharacteristicUnspecified:  "unknown",
+	pixfmts.ColorTransferCharacteristicGamma22:      "bt470m",
+	pixfmts.ColorTransferCharacteristicGamma28:      "bt470bg",
+	pixfmts.ColorTransferCharacteristicSMPTE170M:    "smpte170m",
+	pixfmts.ColorTransferCharacteristicSMPTE240M:    "smpte240m",
+	pixfmts.ColorTransferCharacteristicLinear:       "linear",
+	pixfmts.ColorTransferCharacteristicLog:          "log100",
+	pixfmts.ColorTransferCharacteristicLogSqrt:      "log316",
+	pixfmts.ColorTransferCharacteristicIEC61966_2_4: "iec61966-2-4",
+	pixfmts.ColorTransferCharacteristicBT1361_ECG:   "bt1361e",
+	pixfmts.ColorTransferCharacteristicIEC61966_2_1: "iec61966-2-1",
+	pixfmts.ColorTransferCharacteristicBT2020_10:    "bt2020-10",
+	pixfmts.ColorTransferCharacteristicBT2020_12:    "bt2020-12",
+	pixfmts.ColorTransferCharacteristicSMPTE2084:    "smpte2084",
+	pixfmts.ColorTransferCharacteristicSMPTE428:     "smpte428",
+	pixfmts.ColorTransferCharacteristicARIB_STD_B67: "arib-std-b67",
+}
+
+var primariesNames = map[pixfmts.ColorPrimaries]string{
+	pixfmts.ColorPrimariesBT709:       "bt709",
+	pixfmts.ColorPrimariesUnspecified: "unknown",
+	pixfmts.ColorPrimariesBT470M:      "bt470m",
+	pixfmts.ColorPrimariesBT470BG:     "bt470bg",
+	pixfmts.ColorPrimariesSMPTE170M:   "smpte170m",
+	pixfmts.ColorPrimariesSMPTE240M:   "smpte240m",
+	pixfmts.ColorPrimariesFilm:        "film",
+	pixfmts.ColorPrimariesBT2020:      "bt2020",
+	pixfmts.ColorPrimariesSMPTE428:    "smpte428",
+	pixfmts.ColorPrimariesSMPTE431:    "smpte431",
+	pixfmts.ColorPrimariesSMPTE432:    "smpte432",
+	pixfmts.ColorPrimariesEBU3213:     "ebu3213",
+}
+
+var rangeNames = map[pixfmts.ColorRange]string{
+	pixfmts.ColorRangeUnspecified: "unknown",
+	pixfmts.ColorRangeMPEG:        "tv",
+	pixfmts.ColorRangeJPEG:        "pc",
+}
+
+var chromaLocationNames = map[pixfmts.ChromaLocation]string{
+	pixfmts.ChromaLocationUnspecified: "unspecified",
+	pixfmts.ChromaLocationLeft:        "left",
+	pixfmts.ChromaLocationCenter:      "center",
+	pixfmts.ChromaLocationTopLeft:     "topleft",
+	pixfmts.ChromaLocationTop:         "top",
+	pixfmts.ChromaLocationBottomleft:  "bottomleft",
+	pixfmts.ChromaLocationBottom:      "bottom",
+}
+
+func lookupName[T comparable](names map[T]string, v T) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// MatrixName returns the FFmpeg name of a matrix, e.g. "bt709".
+func MatrixName(v pixfmts.ColorSpace) string { return lookupName(matrixNames, v) }
+
+// TransferName returns the FFmpeg name of a transfer, e.g. "smpte2084".
+func TransferName(v pixfmts.ColorTransferCharacteristic) string {
+	return lookupName(transferNames, v)
+}
+
+// PrimariesName returns the FFmpeg name of a set of primaries, e.g. "bt2020".
+func PrimariesName(v pixfmts.ColorPrimaries) string {
+	return lookupName(primariesNames, v)
+}
+
+// RangeName returns the FFmpeg name of a range, "tv" or "pc".
+func RangeName(v pixfmts.ColorRange) string { return lookupName(rangeNames, v) }
+
+// ChromaLocationName returns the FFmpeg name of a chroma location, e.g.
+// "left".
+func ChromaLocationName(v pixfmts.ChromaLocation) string {
+	return lookupName(chromaLocationNames, v)
+}
+
+// Extra names accepted when parsing user input, on top of the FFmpeg names.
+var (
+	matrixAliases = map[string]pixfmts.ColorSpace{
+		"rgb":    pixfmts.ColorSpaceRGB,
+		"bt601":  pixfmts.ColorSpaceSMPTE170M,
+		"bt2020": pixfmts.ColorSpaceBT2020_NCL,
+	}
+	transferAliases = map[string]pixfmts.ColorTransferCharacteristic{
+		"bt601": pixfmts.ColorTransferCharacteristicSMPTE170M,
+		"srgb":  pixfmts.ColorTransferCharacteristicIEC61966_2_1,
+		"pq":    pixfmts.ColorTransferCharacteristicSMPTE2084,
+		"hlg":   pixfmts.ColorTransferCharacteristicARIB_STD_B67,
+	}
+	primariesAliases = map[string]pixfmts.ColorPrimaries{
+		"bt601":      pixfmts.ColorPrimariesSMPTE170M,
+		"display-p3": pixfmts.ColorPrimariesSMPTE432,
+	}
+	rangeAliases = map[string]pixfmts.ColorRange{
+		"limited": pixfmts.ColorRangeMPEG,
+		"mpeg":    pixfmts.ColorRangeMPEG,
+		"full":    pixfmts.ColorRangeJPEG,
+		"jpeg":    pixfmts.ColorRangeJPEG,
+	}
+)
+
+func parseName[T comparable](kind, name string, names map[T]string,
+	aliases map[string]T) (T, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if v, ok := aliases[name]; ok {
+		return v, nil
+	}
+	for v, n := range names {
+		if n == name && n != "unknown" && n != "unspecified" {
+			return v, nil
+		}
+	}
+
+	var zero T
+	return zero, fmt.Errorf("unknown %s %q", kind, name)
+}
+
+// ParseMatrix returns the matrix for an FFmpeg style name such as "bt709" or
+// "bt470bg". "bt601", "bt2020" and "rgb" are also accepted.
+func ParseMatrix(name string) (pixfmts.ColorSpace, error) {
+	return parseName("matrix", name, matrixNames, matrixAliases)
+}
+
+// ParseTransfer returns the transfer characteristic for an FFmpeg style name
+// such as "bt709" or "smpte2084". "bt601", "srgb", "pq" and "hlg" are also
+// accepted.
+func ParseTransfer(name string) (pixfmts.ColorTransferCharacteristic, error) {
+	return parseName("transfer", name, transferNames, transferAliases)
+}
+
+// ParsePrimaries returns the primaries for an FFmpeg style name such as
+// "bt709" or "bt2020". "bt601" and "display-p3" are also accepted.
+func ParsePrimaries(name string) (pixfmts.ColorPrimaries, error) {
+	return parseName("primaries", name, primariesNames, primariesAliases)
+}
+
+// ParseRange returns the color range for "tv" or "pc". "limited", "mpeg",
+// "full" and "jpeg" are also accepted.
+func ParseRange(name string) (pixfmts.ColorRange, error) {
+	return parseName("range", name, rangeNames, rangeAliases)
+}
@@ -0,0 +1,455 @@
+package color
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Backend describes which color properties a metric backend can consume
+// natively. Anything outside of these sets has to be converted on the CPU
+// before the backend sees it.
+type Backend struct {
+	Name            string
+	Matrices        []pixfmts.ColorSpace
+	Transfers       []pixfmts.ColorTransferCharacteristic
+	Primaries       []pixfmts.ColorPrimaries
+	ChromaLocations []pixfmts.ChromaLocation
+	BitDepths       []int
+}
+
+// VshipBackend is the set of color properties vship converts on the GPU.
+var VshipBackend = Backend{
+	Name: "vship",
+	Matrices: []pixfmts.ColorSpace{
+		pixfmts.ColorSpaceRGB,
+		pixfmts.ColorSpaceBT709,
+		pixfmts.ColorSpaceBT470BG,
+		pixfmts.ColorSpaceSMPTE170M,
+		pixfmts.ColorSpaceBT2020_NCL,
+		pixfmts.ColorSpaceBT2020_CL,
+		pixfmts.ColorSpaceICTCP,
+	},
+	Transfers: []pixfmts.ColorTransferCharacteristic{
+		pixfmts.ColorTransferCharacteristicBT709,
+		pixfmts.ColorTransferCharacteristicGamma22,
+		pixfmts.ColorTransferCharacteristicGamma28,
+		pixfmts.ColorTransferCharacteristicSMPTE170M,
+		pixfmts.ColorTransferCharacteristicLinear,
+		pixfmts.ColorTransferCharacteristicIEC61966_2_1,
+		pixfmts.ColorTransferCharacteristicSMPTE2084,
+		pixfmts.ColorTransferCharacteristicSMPTE428,
+		pixfmts.ColorTransferCharacteristicARIB_STD_B67,
+	},
+	Primaries: []pixfmts.ColorPrimaries{
+		pixfmts.ColorPrimariesBT709,
+		pixfmts.ColorPrimariesBT470M,
+		pixfmts.ColorPrimariesBT470BG,
+		pixfmts.ColorPrimariesBT2020,
+	},
+	ChromaLocations: []pixfmts.ChromaLocation{
+		pixfmts.ChromaLocationLeft,
+		pixfmts.ChromaLocationCenter,
+		pixfmts.ChromaLocationTopLeft,
+		pixfmts.ChromaLocationTop,
+	},
+	BitDepths: []int{8, 9, 10, 12, 14, 16},
+}
+
+// Action is what a Plan does with a single color attribute.
+type Action int
+
+const (
+	// ActionPassthrough hands the value to the backend unchanged.
+	ActionPassthrough Action = iota
+	// ActionAssume replaces an unspecified value with a default.
+	ActionAssume
+	// ActionRelabel replaces the value with an equivalent one the backend
+	// supports. Pixel data is unchanged.
+	ActionRelabel
+	// ActionConvert converts the pixel data on the CPU.
+	ActionConvert
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionPassthrough:
+		return "passthrough"
+	case ActionAssume:
+		return "assumed"
+	case ActionRelabel:
+		return "relabeled"
+	case ActionConvert:
+		return "cpu"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+// Step records how a Plan maps one color attribute from the source to the
+// backend.
+type Step struct {
+	// The attribute name, e.g. "matrix" or "transfer".
+	Attribute string
+	Action    Action
+	// Human readable names of the source and backend values.
+	From, To string
+}
+
+func (s Step) String() string {
+	if s.Action == ActionPassthrough {
+		return fmt.Sprintf("%s: %s", s.Attribute, s.From)
+	}
+	return fmt.Sprintf("%s: %s -> %s (%s)", s.Attribute, s.From, s.To, s.Action)
+}
+
+// Plan is an explicit conversion graph from a source's color properties to the
+// properties a metric backend receives. Build one with NewPlan.
+type Plan struct {
+	Backend string
+	// Input is the source's color properties as reported.
+	Input video.ColorProperties
+	// Output is what the backend receives after every step is applied.
+	Output video.ColorProperties
+	// Steps lists one entry per attribute: matrix, transfer, primaries, range
+	// and chroma location.
+	Steps []Step
+
+	// resolved is Input with unspecified attributes filled in.
+	resolved video.ColorProperties
+	assumed  map[string]bool
+}
+
+// Resolved returns Input with unspecified attributes replaced by the values
+// the plan assumed for them.
+func (p *Plan) Resolved() video.ColorProperties { return p.resolved }
+
+// NeedsCPU returns true if any step converts pixel data on the CPU.
+func (p *Plan) NeedsCPU() bool {
+	return slices.ContainsFunc(p.Steps, func(s Step) bool {
+		return s.Action == ActionConvert
+	})
+}
+
+func (p *Plan) String() string {
+	parts := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		parts[i] = step.String()
+	}
+	return fmt.Sprintf("%s [%s]", p.Backend, strings.Join(parts, ", "))
+}
+
+// equivalentTransfers lists transfer characteristics that share a curve with
+// another one, so they can be relabeled instead of converted.
+var equivalentTransfers = map[pixfmts.ColorTransferCharacteristic][]pixfmts.ColorTransferCharacteristic{
+	pixfmts.ColorTransferCharacteristicBT2020_10: {
+		pixfmts.ColorTransferCharacteristicBT709,
+		pixfmts.ColorTransferCharacteristicSMPTE170M},
+	pixfmts.ColorTransferCharacteristicBT2020_12: {
+		pixfmts.ColorTransferCharacteristicBT709,
+		pixfmts.ColorTransferCharacteristicSMPTE170M},
+	pixfmts.ColorTransferCharacteristicSMPTE170M: {
+		pixfmts.ColorTransferCharacteristicBT709},
+	pixfmts.ColorTransferCharacteristicBT1361_ECG: {
+		pixfmts.ColorTransferCharacteristicBT709},
+	pixfmts.ColorTransferCharacteristicIEC61966_2_4: {
+		pixfmts.ColorTransferCharacteristicBT709},
+}
+
+// equivalentMatrices lists YUV matrices that share coefficients.
+var equivalentMatrices = map[pixfmts.ColorSpace][]pixfmts.ColorSpace{
+	pixfmts.ColorSpaceSMPTE170M: {pixfmts.ColorSpaceBT470BG},
+	pixfmts.ColorSpaceBT470BG:   {pixfmts.ColorSpaceSMPTE170M},
+}
+
+// NewPlan builds the conversion plan from in to what backend supports.
+//
+// Unspecified attributes are filled in from infer, or reported as
+// ErrUnspecified when infer has no assumption for them. Attributes the backend does not support are relabeled to an
+// equivalent value when one exists, converted on the CPU when possible, and
+// otherwise reported as an error naming the unsupported combination.
+func NewPlan(in video.ColorProperties, backend Backend, infer Inference) (
+	*Plan, error) {
+	rgb, err := isRGB(in.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, assumed, err := infer.resolve(in, rgb)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{Backend: backend.Name, Input: in, Output: resolved,
+		resolved: resolved, assumed: assumed}
+
+	depth, err := in.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(backend.BitDepths, depth) {
+		return nil, fmt.Errorf("%s does not support %d-bit input", backend.Name,
+			depth)
+	}
+
+	if err := p.planMatrix(backend, rgb); err != nil {
+		return nil, err
+	}
+	if err := p.planTransfer(backend); err != nil {
+		return nil, err
+	}
+	if err := p.planPrimaries(backend); err != nil {
+		return nil, err
+	}
+	p.planRange()
+	if err := p.planChromaLocation(backend); err != nil {
+		return nil, err
+	}
+
+	if p.NeedsCPU() {
+		if rgb {
+			return nil, fmt.Errorf("%s: CPU conversion of RGB formats is not "+
+				"supported", p)
+		}
+		if resolved.ColorSpace == pixfmts.ColorSpaceICTCP {
+			return nil, fmt.Errorf("%s: CPU conversion of ICtCp is not "+
+				"supported", p)
+		}
+	}
+
+	return p, nil
+}
+
+func isRGB(pf pixfmts.PixelFormat) (bool, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(pf)
+	if err != nil {
+		return false, err
+	}
+	return pixFmtDesc.Flags()&uint64(pixfmts.PixFmtFlagRGB) != 0, nil
+}
+
+func (p *Plan) addStep(attribute string, action Action, from, to string) {
+	if p.assumed[attribute] {
+		if action == ActionPassthrough {
+			action, from = ActionAssume, "unknown"
+		} else {
+			from = "unknown, assumed " + from
+		}
+	}
+	p.Steps = append(p.Steps, Step{attribute, action, from, to})
+}
+
+func (p *Plan) planMatrix(backend Backend, rgb bool) error {
+	in := p.resolved.ColorSpace
+	from := MatrixName(in)
+
+	switch {
+	case rgb && in == pixfmts.ColorSpaceRGB:
+		p.addStep("matrix", ActionPassthrough, from, from)
+		return nil
+	case rgb:
+		// The matrix of an RGB pixel format is meaningless, it is only ever
+		// mislabeled.
+		p.Output.ColorSpace = pixfmts.ColorSpaceRGB
+		p.addStep("matrix", ActionRelabel, from, MatrixName(p.Output.ColorSpace))
+		return nil
+	case slices.Contains(backend.Matrices, in):
+		p.addStep("matrix", ActionPassthrough, from, from)
+		return nil
+	}
+
+	for _, equivalent := range equivalentMatrices[in] {
+		if slices.Contains(backend.Matrices, equivalent) {
+			p.Output.ColorSpace = equivalent
+			p.addStep("matrix", ActionRelabel, from, MatrixName(equivalent))
+			return nil
+		}
+	}
+
+	if _, _, err := lumaCoefficients(in); err == nil &&
+		slices.Contains(backend.Matrices, pixfmts.ColorSpaceBT709) {
+		p.Output.ColorSpace = pixfmts.ColorSpaceBT709
+		p.addStep("matrix", ActionConvert, from, MatrixName(p.Output.ColorSpace))
+		return nil
+	}
+
+	return fmt.Errorf("%s does not support matrix %s and it cannot be "+
+		"converted on the CPU", backend.Name, from)
+}
+
+func (p *Plan) planTransfer(backend Backend) error {
+	in := p.resolved.ColorTransfer
+	from := TransferName(in)
+
+	if slices.Contains(backend.Transfers, in) {
+		p.addStep("transfer", ActionPassthrough, from, from)
+		return nil
+	}
+
+	for _, equivalent := range equivalentTransfers[in] {
+		if slices.Contains(backend.Transfers, equivalent) {
+			p.Output.ColorTransfer = equivalent
+			p.addStep("transfer", ActionRelabel, from, TransferName(equivalent))
+			return nil
+		}
+	}
+
+	if _, ok := transferFuncs[in]; ok && slices.Contains(backend.Transfers,
+		pixfmts.ColorTransferCharacteristicBT709) {
+		p.Output.ColorTransfer = pixfmts.ColorTransferCharacteristicBT709
+		p.addStep("transfer", ActionConvert, from,
+			TransferName(p.Output.ColorTransfer))
+		return nil
+	}
+
+	return fmt.Errorf("%s does not support transfer %s and it cannot be "+
+		"converted on the CPU", backend.Name, from)
+}
+
+func (p *Plan) planPrimaries(backend Backend) error {
+	in := p.resolved.ColorPrimaries
+	from := PrimariesName(in)
+
+	if slices.Contains(backend.Primaries, in) {
+		p.addStep("primaries", ActionPassthrough, from, from)
+		return nil
+	}
+
+	if _, ok := primariesTable[in]; !ok {
+		return fmt.Errorf("%s does not support primaries %s and they cannot "+
+			"be converted on the CPU", backend.Name, from)
+	}
+
+	// Converting linear light needs the input transfer, and the result is
+	// re-encoded with the output transfer.
+	if _, ok := transferFuncs[p.resolved.ColorTransfer]; !ok {
+		return fmt.Errorf("%s does not support primaries %s and they cannot "+
+			"be converted on the CPU from transfer %s", backend.Name, from,
+			TransferName(p.resolved.ColorTransfer))
+	}
+
+	// Prefer the smallest supported gamut that contains the input so
+	// nothing is clipped.
+	targets := []pixfmts.ColorPrimaries{pixfmts.ColorPrimariesBT709,
+		pixfmts.ColorPrimariesBT2020}
+	if in == pixfmts.ColorPrimariesSMPTE432 {
+		targets = targets[1:]
+	}
+
+	for _, target := range targets {
+		if slices.Contains(backend.Primaries, target) {
+			p.Output.ColorPrimaries = target
+			p.addStep("primaries", ActionConvert, from, PrimariesName(target))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s does not support primaries %s or any gamut they "+
+		"can be converted to", backend.Name, from)
+}
+
+func (p *Plan) planRange() {
+	in := p.resolved.ColorRange
+	from := RangeName(in)
+
+	p.addStep("range", ActionPassthrough, from, from)
+}
+
+func (p *Plan) planChromaLocation(backend Backend) error {
+	in := p.resolved.ChromaLocation
+	from := ChromaLocationName(in)
+
+	if slices.Contains(backend.ChromaLocations, in) {
+		p.addStep("chroma location", ActionPassthrough, from, from)
+		return nil
+	}
+
+	return fmt.Errorf("%s does not support chroma location %s and chroma "+
+		"cannot be resampled on the CPU", backend.Name, from)
+}
+
+// Apply returns a source that delivers frames matching p.Output. If the plan
+// has no CPU steps, source is returned unchanged as the backend handles
+// everything itself.
+func (p *Plan) Apply(source video.Source) (video.Source, error) {
+	if !p.NeedsCPU() {
+		return source, nil
+	}
+
+	converter, err := p.newConverter()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConvertedSource(source, converter)
+}
+
+// newConverter builds the CPU pipeline for the plan's convert steps: decode
+// with the input matrix, and if the transfer or primaries change, linearize,
+// apply the gamut matrix and re-encode.
+func (p *Plan) newConverter() (*yuvConverter, error) {
+	in := p.resolved
+
+	inKr, inKb, err := lumaCoefficients(in.ColorSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	linearize := in.ColorTransfer != p.Output.ColorTransfer ||
+		in.ColorPrimaries != p.Output.ColorPrimaries
+
+	var toLinear, fromLinear lut
+	var gamut *mat3
+
+	if linearize {
+		inTransfer, ok := transferFuncs[in.ColorTransfer]
+		if !ok {
+			inTransfer = transferFuncs[p.Output.ColorTransfer]
+		}
+		outTransfer := transferFuncs[p.Output.ColorTransfer]
+		toLinear = newLUT(inTransfer.toLinear)
+		fromLinear = newLUT(outTransfer.fromLinear)
+
+		if _, ok := primariesTable[in.ColorPrimaries]; ok &&
+			in.ColorPrimaries != p.Output.ColorPrimaries {
+			m := primariesMatrix(in.ColorPrimaries, p.Output.ColorPrimaries)
+			gamut = &m
+		}
+	}
+
+	pixel := func(y, cb, cr float64) (r, g, b float64) {
+		r, g, b = decodeYUV(y, cb, cr, inKr, inKb)
+		if !linearize {
+			return r, g, b
+		}
+
+		rgb := [3]float64{toLinear.at(r), toLinear.at(g), toLinear.at(b)}
+		if gamut != nil {
+			rgb = gamut.apply(rgb)
+		}
+
+		return fromLinear.at(rgb[0]), fromLinear.at(rgb[1]), fromLinear.at(rgb[2])
+	}
+
+	return newYUVConverter(in, p.Output, pixel)
+}
+
+// Prepare builds a plan for source against backend and applies it. The
+// returned source delivers frames matching plan.Output.
+func Prepare(source video.Source, backend Backend, infer Inference) (
+	video.Source, *Plan, error) {
+	plan, err := NewPlan(*source.GetColorProps(), backend, infer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err = plan.Apply(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, plan, nil
+}
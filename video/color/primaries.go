@@ -0,0 +1,96 @@
+package color
+
+import (
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+type mat3 [3][3]float64
+
+func (m mat3) apply(v [3]float64) [3]float64 {
+	var out [3]float64
+	for i, row := range m {
+		out[i] = row[0]*v[0] + row[1]*v[1] + row[2]*v[2]
+	}
+	return out
+}
+
+func (a mat3) mul(b mat3) mat3 {
+	var out mat3
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				out[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return out
+}
+
+func (m mat3) inverse() mat3 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	return mat3{
+		{(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det,
+			(m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det,
+			(m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det},
+		{(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det,
+			(m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det,
+			(m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det},
+		{(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det,
+			(m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det,
+			(m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det},
+	}
+}
+
+// chromaticities holds the CIE xy coordinates of the red, green and blue
+// primaries.
+type chromaticities [3][2]float64
+
+// d65 is the white point shared by every entry of primariesTable. Primaries
+// with a different white point would need chromatic adaptation and are left
+// to the backend.
+var d65 = [2]float64{0.3127, 0.3290}
+
+var primariesTable = map[pixfmts.ColorPrimaries]chromaticities{
+	pixfmts.ColorPrimariesBT709:     {{0.640, 0.330}, {0.300, 0.600}, {0.150, 0.060}},
+	pixfmts.ColorPrimariesBT470BG:   {{0.640, 0.330}, {0.290, 0.600}, {0.150, 0.060}},
+	pixfmts.ColorPrimariesSMPTE170M: {{0.630, 0.340}, {0.310, 0.595}, {0.155, 0.070}},
+	pixfmts.ColorPrimariesSMPTE240M: {{0.630, 0.340}, {0.310, 0.595}, {0.155, 0.070}},
+	pixfmts.ColorPrimariesBT2020:    {{0.708, 0.292}, {0.170, 0.797}, {0.131, 0.046}},
+	pixfmts.ColorPrimariesSMPTE432:  {{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}},
+	pixfmts.ColorPrimariesEBU3213:   {{0.630, 0.340}, {0.295, 0.605}, {0.155, 0.077}},
+}
+
+func xyToXYZ(xy [2]float64) [3]float64 {
+	return [3]float64{xy[0] / xy[1], 1, (1 - xy[0] - xy[1]) / xy[1]}
+}
+
+// rgbToXYZ returns the matrix converting linear RGB in the given primaries to
+// CIE XYZ.
+func rgbToXYZ(c chromaticities) mat3 {
+	var m mat3
+	for col, xy := range c {
+		xyz := xyToXYZ(xy)
+		for row := range 3 {
+			m[row][col] = xyz[row]
+		}
+	}
+
+	scale := m.inverse().apply(xyToXYZ(d65))
+	for row := range 3 {
+		for col := range 3 {
+			m[row][col] *= scale[col]
+		}
+	}
+
+	return m
+}
+
+// primariesMatrix returns the matrix converting linear RGB from one set of
+// primaries to another. Both must be in primariesTable.
+func primariesMatrix(from, to pixfmts.ColorPrimaries) mat3 {
+	return rgbToXYZ(primariesTable[to]).inverse().mul(
+		rgbToXYZ(primariesTable[from]))
+}
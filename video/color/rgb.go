@@ -0,0 +1,221 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Stage selects how far a Converter takes the samples of a frame.
+type Stage int
+
+const (
+	// StageRGB stops at non-linear R'G'B' in the transfer and primaries of
+	// the source, nominally in [0, 1].
+	StageRGB Stage = iota
+	// StageLinear also linearizes R'G'B' with the transfer of the source, to
+	// linear light relative to its nominal peak. 1 is reference white for
+	// SDR transfers, 10000 nits for PQ and the peak scene light for HLG.
+	StageLinear
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageRGB:
+		return "rgb"
+	case StageLinear:
+		return "linear"
+	default:
+		return fmt.Sprintf("Stage(%d)", int(s))
+	}
+}
+
+// ToLinear converts a non-linear signal in transfer to relative linear light.
+// See StageLinear for the scale of the result.
+func ToLinear(transfer pixfmts.ColorTransferCharacteristic, v float64) (
+	float64, error) {
+	fn, ok := transferFuncs[transfer]
+	if !ok {
+		return 0, fmt.Errorf("no CPU conversion for transfer %s",
+			TransferName(transfer))
+	}
+	return fn.toLinear(v), nil
+}
+
+// FromLinear is the inverse of ToLinear.
+func FromLinear(transfer pixfmts.ColorTransferCharacteristic, l float64) (
+	float64, error) {
+	fn, ok := transferFuncs[transfer]
+	if !ok {
+		return 0, fmt.Errorf("no CPU conversion for transfer %s",
+			TransferName(transfer))
+	}
+	return fn.fromLinear(l), nil
+}
+
+// Converter converts the frames of sources with one set of color properties
+// to R'G'B' or linear RGB planes on the CPU, for metrics computed outside the
+// metric backends and for checking what a backend should see. It is safe for
+// concurrent use.
+type Converter struct {
+	props video.ColorProperties
+
+	layout       video.PlaneLayout
+	wide         bool
+	log2W, log2H int
+	q            quantizer
+	kr, kb       float64
+	toLinear     func(float64) float64
+}
+
+// NewConverter returns a Converter for frames described by props. The
+// matrix, unless the frames are RGB or gray, and for StageLinear the transfer
+// must be specified and supported; Plan.Resolved returns properties with
+// unspecified values inferred. Packed formats must go through Planarize
+// first.
+func NewConverter(props video.ColorProperties, stage Stage) (*Converter,
+	error) {
+	layout, err := props.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout == video.LayoutPacked {
+		return nil, errors.New("cannot convert packed frames, planarize " +
+			"them first")
+	}
+
+	desc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Converter{props: props, layout: layout,
+		wide: depth > 8, log2W: desc.Log2ChromaW(),
+		log2H: desc.Log2ChromaH(), q: newQuantizer(depth, props.ColorRange)}
+
+	if layout == video.LayoutYUV || layout == video.LayoutYUVA {
+		if c.kr, c.kb, err = lumaCoefficients(props.ColorSpace); err != nil {
+			return nil, err
+		}
+	}
+
+	switch stage {
+	case StageRGB:
+	case StageLinear:
+		fn, ok := transferFuncs[props.ColorTransfer]
+		if !ok {
+			return nil, fmt.Errorf("no CPU conversion for transfer %s",
+				TransferName(props.ColorTransfer))
+		}
+		c.toLinear = fn.toLinear
+	default:
+		return nil, fmt.Errorf("unknown conversion stage %v", stage)
+	}
+
+	return c, nil
+}
+
+// Sample converts one pixel given by its integer codes. For RGB frames the
+// codes are R, G and B, and for gray frames only y is used.
+func (c *Converter) Sample(y, cb, cr int) (r, g, b float64) {
+	switch c.layout {
+	case video.LayoutRGB, video.LayoutRGBA:
+		r, g, b = c.q.luma(y), c.q.luma(cb), c.q.luma(cr)
+	case video.LayoutGray:
+		r = c.q.luma(y)
+		g, b = r, r
+	default:
+		r, g, b = decodeYUV(c.q.luma(y), c.q.chroma(cb), c.q.chroma(cr), c.kr,
+			c.kb)
+	}
+
+	if c.toLinear != nil {
+		r, g, b = c.toLinear(r), c.toLinear(g), c.toLinear(b)
+	}
+	return r, g, b
+}
+
+// Convert converts frame into the R, G and B planes of dst, each holding
+// Width*Height samples row by row. Subsampled chroma is replicated over the
+// luma samples it covers. Unlike the conversions of a Plan, which go through
+// lookup tables, every sample is converted exactly.
+func (c *Converter) Convert(dst [3][]float32, frame *video.Frame) error {
+	size := c.props.Width * c.props.Height
+	for i, plane := range dst {
+		if len(plane) < size {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"samples, have %d", i, size, len(plane))
+		}
+	}
+
+	for y := range c.props.Height {
+		row := c.rowPlanes(frame, y)
+		for x := range c.props.Width {
+			r, g, b := c.pixel(row, x)
+			if c.toLinear != nil {
+				r, g, b = c.toLinear(r), c.toLinear(g), c.toLinear(b)
+			}
+
+			i := y*c.props.Width + x
+			dst[0][i], dst[1][i], dst[2][i] = float32(r), float32(g),
+				float32(b)
+		}
+	}
+	return nil
+}
+
+// rowPlanes returns the rows of the first three planes of frame covering
+// luma row y.
+func (c *Converter) rowPlanes(frame *video.Frame, y int) [3][]byte {
+	switch c.layout {
+	case video.LayoutGray:
+		row := frame.Row(0, y)
+		return [3][]byte{row, row, row}
+	case video.LayoutRGB, video.LayoutRGBA:
+		return [3][]byte{frame.Row(0, y), frame.Row(1, y), frame.Row(2, y)}
+	default:
+		return [3][]byte{frame.Row(0, y), frame.Row(1, y>>c.log2H),
+			frame.Row(2, y>>c.log2H)}
+	}
+}
+
+// pixel converts sample x of the rows of rowPlanes to non-linear R'G'B'.
+func (c *Converter) pixel(row [3][]byte, x int) (r, g, b float64) {
+	switch c.layout {
+	case video.LayoutGray:
+		r = c.q.luma(readSample(row[0], x, c.wide))
+		return r, r, r
+	case video.LayoutRGB, video.LayoutRGBA:
+		// Planar RGB is stored as G, B and R.
+		return c.q.luma(readSample(row[2], x, c.wide)),
+			c.q.luma(readSample(row[0], x, c.wide)),
+			c.q.luma(readSample(row[1], x, c.wide))
+	default:
+		cx := x >> c.log2W
+		return decodeYUV(c.q.luma(readSample(row[0], x, c.wide)),
+			c.q.chroma(readSample(row[1], cx, c.wide)),
+			c.q.chroma(readSample(row[2], cx, c.wide)), c.kr, c.kb)
+	}
+}
+
+// Convert converts frame, described by props, to newly allocated R, G and B
+// planes at stage. See Converter.Convert.
+func Convert(frame *video.Frame, props video.ColorProperties, stage Stage) (
+	[3][]float32, error) {
+	c, err := NewConverter(props, stage)
+	if err != nil {
+		return [3][]float32{}, err
+	}
+
+	var planes [3][]float32
+	for i := range planes {
+		planes[i] = make([]float32, props.Width*props.Height)
+	}
+	return planes, c.Convert(planes, frame)
+}
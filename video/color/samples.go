@@ -0,0 +1,80 @@
+package color
+
+import (
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// quantizer converts between integer samples and normalized values for one
+// bit depth and range. Normalized luma is in [0, 1] and chroma in
+// [-0.5, 0.5].
+type quantizer struct {
+	yOffset, yScale, cOffset, cScale float64
+	maxCode                          float64
+}
+
+func newQuantizer(depth int, colorRange pixfmts.ColorRange) quantizer {
+	maxCode := float64(int(1)<<depth - 1)
+	shift := float64(int(1) << (depth - 8))
+
+	if colorRange == pixfmts.ColorRangeJPEG {
+		return quantizer{0, maxCode, float64(int(1) << (depth - 1)), maxCode,
+			maxCode}
+	}
+
+	return quantizer{16 * shift, 219 * shift, 128 * shift, 224 * shift, maxCode}
+}
+
+func (q quantizer) luma(code int) float64   { return (float64(code) - q.yOffset) / q.yScale }
+func (q quantizer) chroma(code int) float64 { return (float64(code) - q.cOffset) / q.cScale }
+
+func (q quantizer) lumaCode(v float64) int {
+	return int(min(max(math.Round(v*q.yScale+q.yOffset), 0), q.maxCode))
+}
+
+func (q quantizer) chromaCode(v float64) int {
+	return int(min(max(math.Round(v*q.cScale+q.cOffset), 0), q.maxCode))
+}
+
+// readSample reads the offset-th sample of a plane. Samples wider than 8 bits
+// are stored as little-endian 16-bit words.
+func readSample(plane []byte, offset int, wide bool) int {
+	if !wide {
+		return int(plane[offset])
+	}
+	return int(plane[2*offset]) | int(plane[2*offset+1])<<8
+}
+
+// writeSample is the inverse of readSample.
+func writeSample(plane []byte, offset int, wide bool, v int) {
+	if !wide {
+		plane[offset] = byte(v)
+		return
+	}
+	plane[2*offset], plane[2*offset+1] = byte(v), byte(v>>8)
+}
+
+// lut is a uniformly sampled lookup table over [0, 1] with linear
+// interpolation between entries.
+type lut []float64
+
+const lutSize = 4096
+
+func newLUT(fn func(float64) float64) lut {
+	table := make(lut, lutSize+1)
+	for i := range table {
+		table[i] = fn(float64(i) / lutSize)
+	}
+	return table
+}
+
+func (l lut) at(x float64) float64 {
+	x = min(max(x, 0), 1) * lutSize
+	i := int(x)
+	if i >= lutSize {
+		return l[lutSize]
+	}
+	frac := x - float64(i)
+	return l[i] + (l[i+1]-l[i])*frac
+}
@@ -0,0 +1,50 @@
+package color
+
+import (
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// convertedSource wraps a video.Source and runs every frame it reads through
+// a yuvConverter on the CPU.
+type convertedSource struct {
+	sources.Passthrough
+	converter *yuvConverter
+	props     video.ColorProperties
+	// scratch receives the untouched frame from source before it is
+	// converted into the caller's frame.
+	scratch video.Frame
+}
+
+func newConvertedSource(source video.Source, converter *yuvConverter) (
+	video.Source, error) {
+	scratch, err := video.NewFrameFor(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &convertedSource{Passthrough: sources.Passthrough{Source: source},
+		converter: converter, props: converter.out, scratch: scratch}, nil
+}
+
+func (s *convertedSource) GetFrame(frame video.Frame) error {
+	if err := s.Source.GetFrame(s.scratch); err != nil {
+		return err
+	}
+
+	s.converter.convert(&frame, &s.scratch)
+	frame.CopyMetadataFrom(&s.scratch)
+	return nil
+}
+
+func (s *convertedSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *convertedSource) GetNumFrames() int                     { return s.Source.GetNumFrames() }
+func (s *convertedSource) GetFrameRate() float32                 { return s.Source.GetFrameRate() }
+
+func (s *convertedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.Source.GetPlaneSizes()
+}
+
+// Close closes the wrapped source.
+func (s *convertedSource) Close() error { return s.Source.Close() }
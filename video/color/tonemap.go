@@ -0,0 +1,235 @@
+package color
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+var (
+	ErrNotHDR = errors.New("source is not HDR (transfer is neither PQ nor HLG)")
+)
+
+// ToneMapOptions configures the BT.2390 tone-mapping applied to an HDR source
+// so it can be compared against an SDR one.
+type ToneMapOptions struct {
+	// The peak luminance of the HDR source in nits. Content brighter than
+	// this is clipped before tone-mapping. For HLG this is also the nominal
+	// display peak used by the OOTF. Defaults to 1000.
+	SourcePeak float64
+	// The peak luminance of the SDR target in nits. The tone-mapped result is
+	// normalized so TargetPeak maps to SDR white. Defaults to 100.
+	TargetPeak float64
+}
+
+// DefaultToneMapOptions returns the options used when a zero value
+// ToneMapOptions is passed.
+func DefaultToneMapOptions() ToneMapOptions {
+	return ToneMapOptions{SourcePeak: 1000, TargetPeak: 100}
+}
+
+func (o *ToneMapOptions) setDefaults() {
+	defaults := DefaultToneMapOptions()
+	if o.SourcePeak <= 0 {
+		o.SourcePeak = defaults.SourcePeak
+	}
+	if o.TargetPeak <= 0 {
+		o.TargetPeak = defaults.TargetPeak
+	}
+}
+
+// IsHDR returns true if the color properties describe PQ or HLG content.
+func IsHDR(cp *video.ColorProperties) bool {
+	switch cp.ColorTransfer {
+	case pixfmts.ColorTransferCharacteristicSMPTE2084,
+		pixfmts.ColorTransferCharacteristicARIB_STD_B67:
+		return true
+	default:
+		return false
+	}
+}
+
+// ToneMapIfNeeded checks whether exactly one of the two sources is HDR, and if
+// so wraps it in a tone-mapping source so both are SDR BT.709. Sources that
+// are both SDR or both HDR are returned unchanged, as comparing them is
+// already well defined.
+func ToneMapIfNeeded(a, b video.Source, opts ToneMapOptions) (video.Source,
+	video.Source, error) {
+	hdrA, hdrB := IsHDR(a.GetColorProps()), IsHDR(b.GetColorProps())
+
+	var err error
+
+	switch {
+	case hdrA && !hdrB:
+		a, err = NewToneMappedSource(a, opts)
+	case hdrB && !hdrA:
+		b, err = NewToneMappedSource(b, opts)
+	}
+
+	return a, b, err
+}
+
+// NewToneMappedSource wraps source, which must be PQ or HLG, so that it
+// returns SDR BT.709 frames with the same pixel format and dimensions. The
+// returned source reports the tone-mapped color properties and passes seeking
+// and keyframe lookup through to source when it supports them.
+//
+// Tone-mapping runs on the CPU, one frame at a time, in the calling reader
+// goroutine.
+func NewToneMappedSource(source video.Source, opts ToneMapOptions) (
+	video.Source, error) {
+	mapper, err := newToneMapper(*source.GetColorProps(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConvertedSource(source, mapper.yuvConverter)
+}
+
+// eetf is the BT.2390 EETF: a hermite spline roll-off applied in the PQ domain
+// that compresses highlights above the knee into the target's range while
+// leaving everything below it untouched.
+type eetf struct {
+	// The PQ encoded source peak.
+	sourcePeak float64
+	// The target peak normalized to the source peak in the PQ domain.
+	maxLum float64
+	// The start of the roll-off.
+	kneeStart float64
+}
+
+func newEETF(sourcePeak, targetPeak float64) eetf {
+	pqSource := pqInverseEOTF(sourcePeak)
+	maxLum := pqInverseEOTF(targetPeak) / pqSource
+	return eetf{sourcePeak: pqSource, maxLum: maxLum,
+		kneeStart: 1.5*maxLum - 0.5}
+}
+
+// apply maps a PQ signal through the EETF and returns a PQ signal.
+func (t eetf) apply(e float64) float64 {
+	e1 := min(max(e/t.sourcePeak, 0), 1)
+	if e1 < t.kneeStart || t.kneeStart >= 1 {
+		return e1 * t.sourcePeak
+	}
+
+	ks := t.kneeStart
+	x := (e1 - ks) / (1 - ks)
+	x2, x3 := x*x, x*x*x
+	e2 := (2*x3-3*x2+1)*ks + (x3-2*x2+x)*(1-ks) + (-2*x3+3*x2)*t.maxLum
+
+	return e2 * t.sourcePeak
+}
+
+// toneMapper converts HDR frames into SDR BT.709 frames.
+type toneMapper struct {
+	*yuvConverter
+
+	// Input YUV matrix coefficients.
+	inKr, inKb float64
+	// Whether the input primaries are BT.2020 and need gamut mapping.
+	gamutMap bool
+	hlg      bool
+	// hlgGamma and hlgPeak parameterize the HLG OOTF.
+	hlgGamma, hlgPeak float64
+
+	// toLinear maps a PQ signal to tone-mapped linear light, where 1 is SDR
+	// white. For HLG input the signal is first converted to PQ.
+	toLinear lut
+	// hlgToScene maps an HLG signal to normalized scene light.
+	hlgToScene lut
+	// toGamma applies the inverse BT.1886 EOTF.
+	toGamma lut
+	// gamut converts linear BT.2020 RGB to linear BT.709 RGB.
+	gamut mat3
+}
+
+func newToneMapper(in video.ColorProperties, opts ToneMapOptions) (
+	*toneMapper, error) {
+	if !IsHDR(&in) {
+		return nil, ErrNotHDR
+	}
+
+	opts.setDefaults()
+
+	out := in
+	out.ColorSpace = pixfmts.ColorSpaceBT709
+	out.ColorTransfer = pixfmts.ColorTransferCharacteristicBT709
+	out.ColorPrimaries = pixfmts.ColorPrimariesBT709
+	if out.ColorRange == pixfmts.ColorRangeUnspecified {
+		out.ColorRange = pixfmts.ColorRangeMPEG
+	}
+	// The HDR metadata describes the source grade, not the SDR output.
+	out.HDR = video.HDRMetadata{}
+
+	t := &toneMapper{}
+
+	var err error
+	if t.yuvConverter, err = newYUVConverter(in, out, t.pixelToSDR); err != nil {
+		return nil, err
+	}
+
+	inMatrix := in.ColorSpace
+	if inMatrix == pixfmts.ColorSpaceUnspecified {
+		inMatrix = pixfmts.ColorSpaceBT2020_NCL
+	}
+	if t.inKr, t.inKb, err = lumaCoefficients(inMatrix); err != nil {
+		return nil, err
+	}
+
+	switch in.ColorPrimaries {
+	case pixfmts.ColorPrimariesBT2020, pixfmts.ColorPrimariesUnspecified:
+		t.gamutMap = true
+		t.gamut = primariesMatrix(pixfmts.ColorPrimariesBT2020,
+			pixfmts.ColorPrimariesBT709)
+	case pixfmts.ColorPrimariesBT709:
+	default:
+		return nil, fmt.Errorf("tone-mapping does not support primaries %s",
+			PrimariesName(in.ColorPrimaries))
+	}
+
+	curve := newEETF(opts.SourcePeak, opts.TargetPeak)
+	t.toLinear = newLUT(func(e float64) float64 {
+		return pqEOTF(curve.apply(e)) / opts.TargetPeak
+	})
+	t.toGamma = newLUT(func(l float64) float64 { return math.Pow(l, 1/2.4) })
+
+	if in.ColorTransfer == pixfmts.ColorTransferCharacteristicARIB_STD_B67 {
+		t.hlg = true
+		t.hlgPeak = opts.SourcePeak
+		t.hlgGamma = 1.2 + 0.42*math.Log10(opts.SourcePeak/1000)
+		t.hlgToScene = newLUT(hlgInverseOETF)
+	}
+
+	return t, nil
+}
+
+// pixelToSDR tone-maps a single normalized Y'CbCr sample into SDR BT.709
+// R'G'B'.
+func (t *toneMapper) pixelToSDR(y, cb, cr float64) (r, g, b float64) {
+	r, g, b = decodeYUV(y, cb, cr, t.inKr, t.inKb)
+	rgb := [3]float64{r, g, b}
+
+	if t.hlg {
+		for i := range rgb {
+			rgb[i] = t.hlgToScene.at(rgb[i])
+		}
+		ys := 0.2627*rgb[0] + 0.6780*rgb[1] + 0.0593*rgb[2]
+		scale := t.hlgPeak * math.Pow(max(ys, 1e-6), t.hlgGamma-1)
+		for i := range rgb {
+			rgb[i] = pqInverseEOTF(rgb[i] * scale)
+		}
+	}
+
+	for i := range rgb {
+		rgb[i] = t.toLinear.at(rgb[i])
+	}
+
+	if t.gamutMap {
+		rgb = t.gamut.apply(rgb)
+	}
+
+	return t.toGamma.at(rgb[0]), t.toGamma.at(rgb[1]), t.toGamma.at(rgb[2])
+}
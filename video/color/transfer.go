@@ -0,0 +1,148 @@
+package color
+
+import (
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// transferFunc converts between a non-linear signal and relative linear light,
+// both nominally in [0, 1].
+type transferFunc struct {
+	toLinear, fromLinear func(float64) float64
+}
+
+// transferFuncs holds every transfer characteristic the CPU converter can
+// linearize. PQ is normalized so 1 is 10000 nits.
+var transferFuncs = map[pixfmts.ColorTransferCharacteristic]transferFunc{
+	pixfmts.ColorTransferCharacteristicBT709:        {bt709ToLinear, bt709FromLinear},
+	pixfmts.ColorTransferCharacteristicSMPTE170M:    {bt709ToLinear, bt709FromLinear},
+	pixfmts.ColorTransferCharacteristicBT2020_10:    {bt709ToLinear, bt709FromLinear},
+	pixfmts.ColorTransferCharacteristicBT2020_12:    {bt709ToLinear, bt709FromLinear},
+	pixfmts.ColorTransferCharacteristicSMPTE240M:    {smpte240MToLinear, smpte240MFromLinear},
+	pixfmts.ColorTransferCharacteristicGamma22:      {gamma(2.2), gamma(1 / 2.2)},
+	pixfmts.ColorTransferCharacteristicGamma28:      {gamma(2.8), gamma(1 / 2.8)},
+	pixfmts.ColorTransferCharacteristicLinear:       {identity, identity},
+	pixfmts.ColorTransferCharacteristicIEC61966_2_1: {srgbToLinear, srgbFromLinear},
+	pixfmts.ColorTransferCharacteristicLog:          {logToLinear(2), logFromLinear(2)},
+	pixfmts.ColorTransferCharacteristicLogSqrt:      {logToLinear(2.5), logFromLinear(2.5)},
+	pixfmts.ColorTransferCharacteristicSMPTE2084: {
+		func(e float64) float64 { return pqEOTF(e) / pqPeak },
+		func(l float64) float64 { return pqInverseEOTF(l * pqPeak) }},
+	pixfmts.ColorTransferCharacteristicARIB_STD_B67: {hlgInverseOETF, hlgOETF},
+}
+
+func identity(v float64) float64 { return v }
+
+func gamma(g float64) func(float64) float64 {
+	return func(v float64) float64 { return math.Pow(max(v, 0), g) }
+}
+
+func bt709ToLinear(v float64) float64 {
+	if v < 0.081 {
+		return v / 4.5
+	}
+	return math.Pow((v+0.099)/1.099, 1/0.45)
+}
+
+func bt709FromLinear(l float64) float64 {
+	if l < 0.018 {
+		return l * 4.5
+	}
+	return 1.099*math.Pow(l, 0.45) - 0.099
+}
+
+func smpte240MToLinear(v float64) float64 {
+	if v < 0.0913 {
+		return v / 4
+	}
+	return math.Pow((v+0.1115)/1.1115, 1/0.45)
+}
+
+func smpte240MFromLinear(l float64) float64 {
+	if l < 0.0228 {
+		return l * 4
+	}
+	return 1.1115*math.Pow(l, 0.45) - 0.1115
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func srgbFromLinear(l float64) float64 {
+	if l <= 0.0031308 {
+		return l * 12.92
+	}
+	return 1.055*math.Pow(l, 1/2.4) - 0.055
+}
+
+// logToLinear returns the inverse of the logarithmic transfer characteristics
+// with a range of 10^decades : 1.
+func logToLinear(decades float64) func(float64) float64 {
+	return func(v float64) float64 {
+		if v <= 0 {
+			return 0
+		}
+		return math.Pow(10, (v-1)*decades)
+	}
+}
+
+func logFromLinear(decades float64) func(float64) float64 {
+	floor := math.Pow(10, -decades)
+	return func(l float64) float64 {
+		if l < floor {
+			return 0
+		}
+		return 1 + math.Log10(l)/decades
+	}
+}
+
+// Constants of the SMPTE ST 2084 (PQ) transfer function.
+const (
+	pqM1 = 2610.0 / 16384
+	pqM2 = 2523.0 / 4096 * 128
+	pqC1 = 3424.0 / 4096
+	pqC2 = 2413.0 / 4096 * 32
+	pqC3 = 2392.0 / 4096 * 32
+
+	pqPeak = 10000.0
+)
+
+// pqEOTF converts a PQ signal in [0, 1] to linear light in nits.
+func pqEOTF(e float64) float64 {
+	p := math.Pow(max(e, 0), 1/pqM2)
+	return pqPeak * math.Pow(max(p-pqC1, 0)/(pqC2-pqC3*p), 1/pqM1)
+}
+
+// pqInverseEOTF converts linear light in nits to a PQ signal in [0, 1].
+func pqInverseEOTF(nits float64) float64 {
+	y := math.Pow(max(nits, 0)/pqPeak, pqM1)
+	return math.Pow((pqC1+pqC2*y)/(1+pqC3*y), pqM2)
+}
+
+// Constants of the ARIB STD-B67 (HLG) transfer function.
+const (
+	hlgA = 0.17883277
+	hlgB = 0.28466892
+	hlgC = 0.55991073
+)
+
+// hlgInverseOETF converts an HLG signal in [0, 1] to normalized scene light.
+func hlgInverseOETF(e float64) float64 {
+	if e <= 0.5 {
+		return e * e / 3
+	}
+	return (math.Exp((e-hlgC)/hlgA) + hlgB) / 12
+}
+
+// hlgOETF converts normalized scene light to an HLG signal in [0, 1].
+func hlgOETF(l float64) float64 {
+	if l <= 1.0/12 {
+		return math.Sqrt(3 * max(l, 0))
+	}
+	return hlgA*math.Log(12*l-hlgB) + hlgC
+}
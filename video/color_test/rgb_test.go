@@ -0,0 +1,186 @@
+package color_test
+
+import (
+	"math"
+	"testing"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/color"
+)
+
+func near(a, b, tolerance float64) bool { return math.Abs(a-b) <= tolerance }
+
+func Test_ToLinear(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer pixfmts.ColorTransferCharacteristic
+		signal   float64
+		linear   float64
+	}{
+		{"bt709 black", pixfmts.ColorTransferCharacteristicBT709, 0, 0},
+		{"bt709 white", pixfmts.ColorTransferCharacteristicBT709, 1, 1},
+		{"bt709 mid", pixfmts.ColorTransferCharacteristicBT709, 0.5,
+			0.2595894},
+		{"bt709 toe", pixfmts.ColorTransferCharacteristicBT709, 0.045, 0.01},
+		{"srgb mid", pixfmts.ColorTransferCharacteristicIEC61966_2_1, 0.5,
+			0.2140411},
+		{"srgb toe", pixfmts.ColorTransferCharacteristicIEC61966_2_1,
+			0.02, 0.02 / 12.92},
+		// 100 and 1000 nits, relative to the 10000 nit PQ peak.
+		{"pq 100 nits", pixfmts.ColorTransferCharacteristicSMPTE2084,
+			0.5080784, 0.01},
+		{"pq 1000 nits", pixfmts.ColorTransferCharacteristicSMPTE2084,
+			0.7518271, 0.1},
+		{"hlg knee", pixfmts.ColorTransferCharacteristicARIB_STD_B67, 0.5,
+			1.0 / 12},
+		{"hlg peak", pixfmts.ColorTransferCharacteristicARIB_STD_B67, 1, 1},
+		{"linear", pixfmts.ColorTransferCharacteristicLinear, 0.3, 0.3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			linear, err := color.ToLinear(test.transfer, test.signal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(linear, test.linear, 1e-6) {
+				t.Errorf("ToLinear(%v) = %v, want %v", test.signal, linear,
+					test.linear)
+			}
+
+			signal, err := color.FromLinear(test.transfer, linear)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(signal, test.signal, 1e-6) {
+				t.Errorf("FromLinear(%v) = %v, want %v", linear, signal,
+					test.signal)
+			}
+		})
+	}
+}
+
+func Test_ToLinear_Unsupported(t *testing.T) {
+	_, err := color.ToLinear(pixfmts.ColorTransferCharacteristicUnspecified,
+		0.5)
+	if err == nil {
+		t.Fatal("expected an error for an unspecified transfer")
+	}
+}
+
+func Test_Converter_Sample(t *testing.T) {
+	tests := []struct {
+		name       string
+		matrix     pixfmts.ColorSpace
+		colorRange pixfmts.ColorRange
+		y, cb, cr  int
+		r, g, b    float64
+	}{
+		{"bt709 white", pixfmts.ColorSpaceBT709, pixfmts.ColorRangeMPEG,
+			235, 128, 128, 1, 1, 1},
+		{"bt709 black", pixfmts.ColorSpaceBT709, pixfmts.ColorRangeMPEG,
+			16, 128, 128, 0, 0, 0},
+		{"bt709 red", pixfmts.ColorSpaceBT709, pixfmts.ColorRangeMPEG,
+			63, 102, 240, 1, 0, 0},
+		{"bt601 red", pixfmts.ColorSpaceSMPTE170M, pixfmts.ColorRangeMPEG,
+			81, 90, 240, 1, 0, 0},
+		{"bt601 full range gray", pixfmts.ColorSpaceSMPTE170M,
+			pixfmts.ColorRangeJPEG, 255, 128, 128, 1, 1, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := color.NewConverter(video.ColorProperties{Width: 2,
+				Height: 2, PixelFormat: pixfmts.PixFmtYUV420P,
+				ColorRange: test.colorRange, ColorSpace: test.matrix},
+				color.StageRGB)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 8-bit codes are only accurate to about 1/219.
+			r, g, b := c.Sample(test.y, test.cb, test.cr)
+			if !near(r, test.r, 0.01) || !near(g, test.g, 0.01) ||
+				!near(b, test.b, 0.01) {
+				t.Errorf("Sample(%d, %d, %d) = %v %v %v, want %v %v %v",
+					test.y, test.cb, test.cr, r, g, b, test.r, test.g,
+					test.b)
+			}
+		})
+	}
+}
+
+func Test_Convert_YUV420P10(t *testing.T) {
+	// A 2x2 frame with one chroma sample: limited range 10-bit BT.709 mid
+	// gray on the top row and white on the bottom row. Samples are little
+	// endian: luma codes 502 and 940, chroma code 512.
+	luma := []byte{0xf6, 0x01, 0xf6, 0x01, 0xac, 0x03, 0xac, 0x03}
+
+	frame, err := video.NewFrame([video.MaxPlanes][]byte{luma,
+		{0x00, 0x02}, {0x00, 0x02}}, [video.MaxPlanes]int{4, 2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := video.ColorProperties{Width: 2, Height: 2,
+		PixelFormat:   pixfmts.PixFmtYUV420P10LE,
+		ColorRange:    pixfmts.ColorRangeMPEG,
+		ColorSpace:    pixfmts.ColorSpaceBT709,
+		ColorTransfer: pixfmts.ColorTransferCharacteristicBT709}
+
+	planes, err := color.Convert(&frame, props, color.StageLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Code 502 is the signal (502-64)/876 = 0.5, code 940 is 1.
+	want := []float64{0.2595894, 0.2595894, 1, 1}
+	for i, w := range want {
+		for c, plane := range planes {
+			if !near(float64(plane[i]), w, 1e-5) {
+				t.Errorf("plane %d sample %d = %v, want %v", c, i, plane[i],
+					w)
+			}
+		}
+	}
+}
+
+func Test_Convert_GBRP(t *testing.T) {
+	// One pixel of full range planar RGB, stored as G, B and R.
+	frame, err := video.NewFrame([video.MaxPlanes][]byte{{51}, {102}, {255}},
+		[video.MaxPlanes]int{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := video.ColorProperties{Width: 1, Height: 1,
+		PixelFormat: pixfmts.PixFmtGBRP, ColorRange: pixfmts.ColorRangeJPEG}
+
+	planes, err := color.Convert(&frame, props, color.StageRGB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for c, want := range []float64{1, 0.2, 0.4} {
+		if !near(float64(planes[c][0]), want, 1e-6) {
+			t.Errorf("plane %d = %v, want %v", c, planes[c][0], want)
+		}
+	}
+}
+
+func Test_NewConverter_Unsupported(t *testing.T) {
+	props := video.ColorProperties{Width: 2, Height: 2,
+		PixelFormat: pixfmts.PixFmtYUV420P,
+		ColorSpace:  pixfmts.ColorSpaceUnspecified}
+
+	if _, err := color.NewConverter(props, color.StageRGB); err == nil {
+		t.Fatal("expected an error for an unspecified matrix")
+	}
+
+	props.ColorSpace = pixfmts.ColorSpaceBT709
+	props.ColorTransfer = pixfmts.ColorTransferCharacteristicUnspecified
+	if _, err := color.NewConverter(props, color.StageLinear); err == nil {
+		t.Fatal("expected an error for an unspecified transfer")
+	}
+}
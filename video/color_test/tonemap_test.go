@@ -0,0 +1,306 @@
+package color_test
+
+import (
+	"math"
+	"testing"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// pq returns the PQ signal of a luminance in nits.
+func pq(t *testing.T, nits float64) float64 {
+	signal, err := color.FromLinear(
+		pixfmts.ColorTransferCharacteristicSMPTE2084, nits/10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signal
+}
+
+// toneMapGray tone-maps a row of limited range 10-bit 4:4:4 PQ gray pixels
+// with the given signals and returns the BT.709 signal of every output
+// pixel.
+func toneMapGray(t *testing.T, signals []float64,
+	opts color.ToneMapOptions) []float64 {
+	luma := make([]byte, 2*len(signals))
+	chroma := make([]byte, 2*len(signals))
+	for i, signal := range signals {
+		code := 64 + int(math.Round(876*signal))
+		luma[2*i], luma[2*i+1] = byte(code), byte(code>>8)
+		chroma[2*i], chroma[2*i+1] = 0x00, 0x02
+	}
+
+	props := video.ColorProperties{Width: len(signals), Height: 1,
+		PixelFormat:    pixfmts.PixFmtYUV444P10LE,
+		ColorRange:     pixfmts.ColorRangeMPEG,
+		ColorSpace:     pixfmts.ColorSpaceBT2020_NCL,
+		ColorTransfer:  pixfmts.ColorTransferCharacteristicSMPTE2084,
+		ColorPrimaries: pixfmts.ColorPrimariesBT2020}
+	hdr, err := sources.NewMemorySource([][3][]byte{{luma, chroma, chroma}},
+		props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := color.NewToneMappedSource(hdr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]float64, len(signals))
+	row := frame.PlaneData(0)
+	for i := range out {
+		code := int(row[2*i]) | int(row[2*i+1])<<8
+		out[i] = float64(code-64) / 876
+	}
+	return out
+}
+
+// Test_ToneMapEETF checks the BT.2390 EETF from a 1000 nit source to a 100
+// nit target at its knee and clip points: luminance below the knee keeps its
+// level, the source peak and everything above it map to SDR white, and the
+// roll-off between them compresses without reversing.
+func Test_ToneMapEETF(t *testing.T) {
+	sourcePeak, targetPeak := pq(t, 1000), pq(t, 100)
+	maxLum := targetPeak / sourcePeak
+	knee := (1.5*maxLum - 0.5) * sourcePeak
+	kneeNits, err := color.ToLinear(
+		pixfmts.ColorTransferCharacteristicSMPTE2084, knee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kneeNits *= 10000
+
+	half := (knee + sourcePeak) / 2
+	signals := []float64{0, pq(t, 5), pq(t, 10), knee, half, sourcePeak,
+		pq(t, 4000)}
+	out := toneMapGray(t, signals, color.ToneMapOptions{SourcePeak: 1000,
+		TargetPeak: 100})
+
+	// The output is BT.1886 gamma of the luminance relative to the target,
+	// quantized to 10 bits.
+	sdr := func(nits float64) float64 { return math.Pow(nits/100, 1/2.4) }
+	want := []float64{0, sdr(5), sdr(10), sdr(kneeNits), -1, 1, 1}
+	for i, w := range want {
+		if w >= 0 && !near(out[i], w, 0.005) {
+			t.Errorf("PQ %.4f: got %.4f, want %.4f", signals[i], out[i], w)
+		}
+	}
+
+	// Halfway along the roll-off the signal is compressed, but stays
+	// between the knee and white.
+	halfNits, _ := color.ToLinear(
+		pixfmts.ColorTransferCharacteristicSMPTE2084, half)
+	if out[4] <= out[3] || out[4] >= 1 || out[4] >= sdr(halfNits*10000) {
+		t.Errorf("roll-off: got %.4f, want within (%.4f, %.4f)", out[4],
+			out[3], min(1, sdr(halfNits*10000)))
+	}
+}
+
+// Test_ToneMapEETFNoRollOff maps a source no brighter than the target, whose
+// knee lies at or above its peak, so the curve is the identity.
+func Test_ToneMapEETFNoRollOff(t *testing.T) {
+	signals := []float64{pq(t, 20), pq(t, 80), pq(t, 100)}
+	out := toneMapGray(t, signals, color.ToneMapOptions{SourcePeak: 100,
+		TargetPeak: 100})
+
+	for i, nits := range []float64{20, 80, 100} {
+		if w := math.Pow(nits/100, 1/2.4); !near(out[i], w, 0.005) {
+			t.Errorf("%g nits: got %.4f, want %.4f", nits, out[i], w)
+		}
+	}
+}
+
+func Test_ToneMapIfNeeded(t *testing.T) {
+	sdr := video.ColorProperties{Width: 2, Height: 2,
+		PixelFormat:   pixfmts.PixFmtYUV420P10LE,
+		ColorRange:    pixfmts.ColorRangeMPEG,
+		ColorSpace:    pixfmts.ColorSpaceBT709,
+		ColorTransfer: pixfmts.ColorTransferCharacteristicBT709}
+	hdr := sdr
+	hdr.ColorSpace = pixfmts.ColorSpaceBT2020_NCL
+	hdr.ColorTransfer = pixfmts.ColorTransferCharacteristicSMPTE2084
+	hdr.ColorPrimaries = pixfmts.ColorPrimariesBT2020
+
+	frames := [][3][]byte{{make([]byte, 8), make([]byte, 2),
+		make([]byte, 2)}}
+	open := func(props video.ColorProperties) video.Source {
+		source, err := sources.NewMemorySource(frames, props, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return source
+	}
+
+	a, b := open(hdr), open(sdr)
+	mappedA, mappedB, err := color.ToneMapIfNeeded(a, b,
+		color.ToneMapOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if color.IsHDR(mappedA.GetColorProps()) || mappedB != b {
+		t.Error("HDR vs SDR: the HDR source was not tone-mapped alone")
+	}
+
+	if _, ok := video.As[video.SeekableSource](mappedA); !ok {
+		t.Error("HDR vs SDR: the tone-mapped source cannot seek")
+	}
+
+	a, b = open(hdr), open(hdr)
+	mappedA, mappedB, err = color.ToneMapIfNeeded(a, b,
+		color.ToneMapOptions{})
+	if err != nil || mappedA != a || mappedB != b {
+		t.Error("HDR vs HDR: sources were changed")
+	}
+
+	a, b = open(sdr), open(sdr)
+	mappedA, mappedB, err = color.ToneMapIfNeeded(a, b,
+		color.ToneMapOptions{})
+	if err != nil || mappedA != a || mappedB != b {
+		t.Error("SDR vs SDR: sources were changed")
+	}
+}
+
+// toneMapCodes tone-maps a 2 row frame of gray pixels, whose luma codes are
+// codes in both rows, with chroma subsampled by 1<<log2Sub both ways. It
+// returns the luma codes of the first output row and the codes of its first
+// chroma row.
+func toneMapCodes(t *testing.T, props video.ColorProperties, log2Sub int,
+	codes []int, opts color.ToneMapOptions) (luma, chroma []int) {
+	depth, err := props.BitDepth()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampleSize := 1
+	if depth > 8 {
+		sampleSize = 2
+	}
+	put := func(plane []byte, i, code int) {
+		plane[i*sampleSize] = byte(code)
+		if sampleSize == 2 {
+			plane[i*sampleSize+1] = byte(code >> 8)
+		}
+	}
+	get := func(plane []byte, i int) int {
+		code := int(plane[i*sampleSize])
+		if sampleSize == 2 {
+			code |= int(plane[i*sampleSize+1]) << 8
+		}
+		return code
+	}
+
+	width, chromaWidth := len(codes), len(codes)>>log2Sub
+	chromaRows := 2 >> log2Sub
+	neutral := 1 << (depth - 1)
+	y := make([]byte, 2*width*sampleSize)
+	u := make([]byte, chromaRows*chromaWidth*sampleSize)
+	for i, code := range codes {
+		put(y, i, code)
+		put(y, width+i, code)
+	}
+	for i := range chromaRows * chromaWidth {
+		put(u, i, neutral)
+	}
+
+	props.Width, props.Height = width, 2
+	hdr, err := sources.NewMemorySource([][3][]byte{{y, u, u}}, props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := color.NewToneMappedSource(hdr, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range width {
+		luma = append(luma, get(frame.PlaneData(0), i))
+	}
+	for i := range chromaWidth {
+		chroma = append(chroma, get(frame.PlaneData(1), i),
+			get(frame.PlaneData(2), i))
+	}
+	return luma, chroma
+}
+
+// Test_ToneMapCodeValues tone-maps limited range gray code values of PQ and
+// HLG, at 8 and 10 bits and with and without chroma subsampling, to BT.709
+// codes worked out from BT.2100 and BT.2390. Gray stays neutral, also where
+// the averaged chroma of a subsampled frame covers different luma.
+func Test_ToneMapCodeValues(t *testing.T) {
+	pqProps := video.ColorProperties{ColorRange: pixfmts.ColorRangeMPEG,
+		ColorSpace:     pixfmts.ColorSpaceBT2020_NCL,
+		ColorTransfer:  pixfmts.ColorTransferCharacteristicSMPTE2084,
+		ColorPrimaries: pixfmts.ColorPrimariesBT2020}
+	hlgProps := pqProps
+	hlgProps.ColorTransfer = pixfmts.ColorTransferCharacteristicARIB_STD_B67
+	at := func(props video.ColorProperties,
+		pf pixfmts.PixelFormat) video.ColorProperties {
+		props.PixelFormat = pf
+		return props
+	}
+	// With equal peaks the EETF is the identity, so 100 nits is SDR white.
+	flat := color.ToneMapOptions{SourcePeak: 100, TargetPeak: 100}
+
+	tests := []struct {
+		name    string
+		props   video.ColorProperties
+		log2Sub int
+		opts    color.ToneMapOptions
+		codes   []int
+		want    []int
+	}{
+		{"PQ 10-bit 4:4:4", at(pqProps, pixfmts.PixFmtYUV444P10LE), 0, flat,
+			[]int{64, 509, 940}, []int{64, 940, 940}},
+		{"PQ 8-bit 4:2:0", at(pqProps, pixfmts.PixFmtYUV420P), 1, flat,
+			[]int{16, 127}, []int{16, 234}},
+		// 75% HLG is the 203 nit reference white, rolled off towards the
+		// 100 nit target by the default options.
+		{"HLG 10-bit 4:4:4", at(hlgProps, pixfmts.PixFmtYUV444P10LE), 0,
+			color.ToneMapOptions{}, []int{64, 300, 721, 940},
+			[]int{64, 420, 896, 940}},
+		{"HLG 8-bit 4:2:0", at(hlgProps, pixfmts.PixFmtYUV420P), 1,
+			color.ToneMapOptions{}, []int{16, 100, 180, 235},
+			[]int{16, 143, 224, 235}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			luma, chroma := toneMapCodes(t, tt.props, tt.log2Sub, tt.codes,
+				tt.opts)
+			for i, want := range tt.want {
+				if d := luma[i] - want; d < -1 || d > 1 {
+					t.Errorf("code %d: got %d, want %d", tt.codes[i],
+						luma[i], want)
+				}
+			}
+
+			depth, _ := tt.props.BitDepth()
+			for i, code := range chroma {
+				if code != 1<<(depth-1) {
+					t.Errorf("chroma sample %d: got %d, want neutral", i/2,
+						code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package comparator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AbortKind is what an AbortCondition tests.
+type AbortKind int
+
+const (
+	// AbortMeanBelow aborts when the running mean falls below the threshold.
+	AbortMeanBelow AbortKind = iota
+	// AbortMeanAbove aborts when the running mean rises above the threshold.
+	AbortMeanAbove
+	// AbortFrameBelow aborts when any frame scores below the threshold.
+	AbortFrameBelow
+	// AbortFrameAbove aborts when any frame scores above the threshold.
+	AbortFrameAbove
+)
+
+// AbortCondition stops a Run early once a score shows the comparison has
+// failed, e.g. a running mean SSIMULACRA2 below 40 after 500 frames, to save
+// the GPU time of scoring the rest of an obviously bad encode.
+type AbortCondition struct {
+	// Metric is the score key tested, e.g. metrics.SSIMulacra2Name.
+	Metric string
+	Kind   AbortKind
+	// Threshold the scores are compared against.
+	Threshold float64
+	// MinFrames is the number of frames of Metric that must be scored before
+	// the condition is tested, so a few early frames cannot trigger a mean
+	// condition.
+	MinFrames int
+}
+
+func (a AbortCondition) String() string {
+	var test string
+	switch a.Kind {
+	case AbortMeanBelow:
+		test = "mean < "
+	case AbortMeanAbove:
+		test = "mean > "
+	case AbortFrameBelow:
+		test = "frame < "
+	case AbortFrameAbove:
+		test = "frame > "
+	}
+	return fmt.Sprintf("%s %s%g after %d frames", a.Metric, test,
+		a.Threshold, a.MinFrames)
+}
+
+// ErrAborted is wrapped by the AbortError returned by Run when an abort
+// condition was met.
+var ErrAborted = errors.New("run aborted")
+
+// AbortError is returned by Run when an AbortCondition was met. Run still
+// returns the scores of the frame pairs before the first pair that had not
+// completed when the run stopped.
+type AbortError struct {
+	Condition AbortCondition
+	// Index is the frame pair whose scores met the condition.
+	Index int
+	// Value is the running mean or the frame score that met the condition.
+	Value float64
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("%s: %s met at frame pair %d with %g", ErrAborted,
+		e.Condition, e.Index, e.Value)
+}
+
+func (e *AbortError) Unwrap() error { return ErrAborted }
+
+// SetAbortConditions registers conditions that abort the Run as soon as any
+// of them is met, see AbortCondition. Must be called before Run(). Pass no
+// conditions to clear.
+func (c *Comparator) SetAbortConditions(conditions ...AbortCondition) {
+	c.abortConditions = conditions
+}
+
+// runningScore is the running count and sum of one score key.
+type runningScore struct {
+	frames int
+	sum    float64
+}
+
+// checkAbort updates the running scores with the scores of pair index and
+// returns an AbortError if a condition is met.
+func (c *Comparator) checkAbort(index int, scores map[string]float64) error {
+	for name, score := range scores {
+		running := c.running[name]
+		running.frames++
+		running.sum += score
+		c.running[name] = running
+	}
+
+	for _, condition := range c.abortConditions {
+		score, ok := scores[condition.Metric]
+		running := c.running[condition.Metric]
+		if !ok || running.frames < condition.MinFrames {
+			continue
+		}
+
+		mean := running.sum / float64(running.frames)
+		value, met := score, false
+		switch condition.Kind {
+		case AbortMeanBelow:
+			value, met = mean, mean < condition.Threshold
+		case AbortMeanAbove:
+			value, met = mean, mean > condition.Threshold
+		case AbortFrameBelow:
+			met = score < condition.Threshold
+		case AbortFrameAbove:
+			met = score > condition.Threshold
+		}
+
+		if met {
+			return &AbortError{Condition: condition, Index: index,
+				Value: value}
+		}
+	}
+	return nil
+}
+
+// completedPrefix returns the number of leading frame pairs that completed.
+func (c *Comparator) completedPrefix() int {
+	for i, done := range c.completed {
+		if !done {
+			return i
+		}
+	}
+	return len(c.completed)
+}
+
+// truncateScores cuts every score array down to the first n frame pairs.
+func (c *Comparator) truncateScores(n int) {
+	for name, values := range c.finalScores {
+		c.finalScores[name] = values[:min(n, len(values))]
+	}
+}
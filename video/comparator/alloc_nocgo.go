@@ -0,0 +1,13 @@
+//go:build nocgo || !cgo
+
+package comparator
+
+// allocPlane allocates a frame plane buffer of size bytes. Without cgo there
+// is no GPU backend to pin memory for, so this is a regular Go allocation.
+func allocPlane(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+// freePlane releases a buffer returned by allocPlane, which the garbage
+// collector does without cgo.
+func freePlane([]byte) error { return nil }
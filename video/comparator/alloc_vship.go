@@ -0,0 +1,29 @@
+//go:build cgo && !nocgo
+
+package comparator
+
+import (
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+)
+
+// allocPlane allocates a frame plane buffer of size bytes in pinned memory so
+// vship can upload it to the GPU without an extra staging copy. Frames cannot
+// stay on the GPU from decode to scoring, as ffms2 decodes into host memory
+// and vship only takes host planes, so this upload is the one copy left.
+func allocPlane(size int) ([]byte, error) {
+	buffer, code := vship.PinnedMalloc(size)
+	if !code.IsNone() {
+		return nil, code.GetError()
+	}
+	addPinned(int64(len(buffer)))
+	return buffer, nil
+}
+
+// freePlane releases a pinned buffer returned by allocPlane.
+func freePlane(buffer []byte) error {
+	if code := vship.PinnedFree(buffer); !code.IsNone() {
+		return code.GetError()
+	}
+	addPinned(-int64(len(buffer)))
+	return nil
+}
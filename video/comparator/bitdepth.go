@@ -0,0 +1,51 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// validateBitDepths makes sure every metric can handle the bit depths of the
+// two sources, see video.Capabilities. Mixed depth comparisons are only
+// allowed when every metric accepts them, through video.BitDepthChecker or
+// its capabilities, as a metric that reads raw samples would otherwise
+// silently mis-score them.
+func (c *Comparator) validateBitDepths() error {
+	depthA, err := c.videoA.GetColorProps().BitDepth()
+	if err != nil {
+		return fmt.Errorf("video a: %w", err)
+	}
+
+	depthB, err := c.videoB.GetColorProps().BitDepth()
+	if err != nil {
+		return fmt.Errorf("video b: %w", err)
+	}
+
+	for _, metric := range c.metrics {
+		capabilities := video.MetricCapabilities(metric)
+		for i, depth := range [2]int{depthA, depthB} {
+			if !capabilities.SupportsBitDepth(depth) {
+				return fmt.Errorf("%s cannot read the %d-bit video %c, "+
+					"it supports %v", metric.Name(), depth, 'a'+i,
+					capabilities.BitDepths)
+			}
+		}
+
+		checker, ok := metric.(video.BitDepthChecker)
+		if ok {
+			if err := checker.CheckBitDepths(depthA, depthB); err != nil {
+				return fmt.Errorf("%s: %w", metric.Name(), err)
+			}
+			continue
+		}
+
+		if depthA != depthB && !capabilities.MixedBitDepths {
+			return fmt.Errorf("%s cannot compare a %d-bit video a against a "+
+				"%d-bit video b, convert one of them first", metric.Name(),
+				depthA, depthB)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Option configures a Comparator at construction, see NewComparator.
+type Option func(*Comparator)
+
+// WithMemoryBudget caps the memory held by the comparator's frame buffers at
+// bytes. The frame pools and the depth of the channels between the pipeline
+// stages are sized to fit: the frame pair queue is shortened first, then the
+// number of frame threads is lowered. A budget of 0 or less is no budget.
+//
+// NewComparator fails if the budget cannot hold one frame pair for each of the
+// reader, pairing and metric stages. Memory allocated by sources and metrics
+// themselves is not counted.
+func WithMemoryBudget(bytes int64) Option {
+	return func(c *Comparator) { c.memoryBudget = bytes }
+}
+
+// minFramePairs is the number of frame pairs the pipeline needs to make
+// progress, one for each of the reader, pairing and metric stages.
+const minFramePairs = 3
+
+// framePairBytes returns the size of the buffers of one frame pair.
+func (c *Comparator) framePairBytes() int64 {
+	videoAPlaneSizes, _ := c.videoA.GetPlaneSizes()
+	videoBPlaneSizes, _ := c.videoB.GetPlaneSizes()
+
+	var total int64
+	for i := range video.MaxPlanes {
+		total += int64(videoAPlaneSizes[i]) + int64(videoBPlaneSizes[i])
+	}
+	return total
+}
+
+// fitMemoryBudget lowers pairDepth and then frameThreads until the frame
+// buffers of the pipeline fit in memoryBudget.
+func (c *Comparator) fitMemoryBudget() error {
+	pairBytes := c.framePairBytes()
+	maxPairs := c.memoryBudget / max(pairBytes, 1)
+
+	if maxPairs < minFramePairs {
+		return fmt.Errorf("memory budget of %d bytes is too small: one "+
+			"frame pair takes %d bytes and the pipeline needs at least %d "+
+			"in flight (reader, pairing and metric stage), i.e. %d bytes",
+			c.memoryBudget, pairBytes, minFramePairs,
+			minFramePairs*pairBytes)
+	}
+
+	for int64(c.framePairBuffers()) > maxPairs {
+		if c.pairDepth > 0 {
+			c.pairDepth--
+			continue
+		}
+		c.frameThreads--
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+package comparator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"golang.org/x/sync/errgroup"
+)
+
+// PairOpener opens a fresh reference and distortion source pair with their
+// own decoders. It is called once to plan the chunks and then once per chunk
+// pipeline. The pairs of the pipelines are closed once their chunk is scored,
+// while the first pair is left open, so an opener may hand out sources its
+// caller keeps using.
+type PairOpener func() (a, b video.Source, err error)
+
+// MetricsFactory creates the metrics for one chunk. Metrics are created per
+// chunk so temporal state never leaks between chunks, and are closed once the
+// chunk is scored.
+type MetricsFactory func(a, b video.Source) ([]video.Metric, error)
+
+// ChunkedOptions configures RunChunked.
+type ChunkedOptions struct {
+	Open       PairOpener
+	NewMetrics MetricsFactory
+
+	// The number of chunk pipelines run concurrently, each with its own pair
+	// of decoders. Defaults to 2.
+	Parallel int
+	// Frame threads of each chunk's Comparator. Defaults to the largest
+	// worker count declared by the chunk's metrics, see NewComparator.
+	FrameThreads int
+	// The number of frames to compare. Defaults to all frames of video A.
+	NumFrames int
+	// Chunks to compare. Defaults to SceneChunks of video A's keyframes, or
+	// fixed size chunks if it cannot report them.
+	Chunks []video.FrameRange
+	// The smallest chunk SceneChunks produces. Defaults to 240 frames.
+	MinChunkFrames int
+	// Called after every compared frame with the total across all chunks.
+	// Calls are serialized.
+	Progress ProgressCallback
+	// Skip scoring bit-identical frame pairs, see
+	// Comparator.SetIdentityShortCircuit.
+	SkipIdentical bool
+	// Impute the scores of frame pairs with at least this PSNR, see
+	// Comparator.SetQuickReject. 0 disables it.
+	QuickRejectPSNR float64
+	// Check every frame before scoring it, see Comparator.SetFrameChecks.
+	CheckFrames bool
+	// Reuse and add scores to this cache, see Comparator.SetScoreCache. The
+	// chunks share it.
+	ScoreCache *ScoreCache
+	// Caps the frame buffers of all chunk pipelines together at this many
+	// bytes, split evenly between them, see WithMemoryBudget. 0 means no
+	// budget.
+	MemoryBudget int64
+}
+
+func (o *ChunkedOptions) setDefaults() {
+	if o.Parallel < 1 {
+		o.Parallel = 2
+	}
+	if o.FrameThreads < 0 {
+		o.FrameThreads = 0
+	}
+	if o.MinChunkFrames < 1 {
+		o.MinChunkFrames = 240
+	}
+}
+
+// RunChunked splits the comparison into scene based chunks and compares them
+// with independent Comparator pipelines, opts.Parallel at a time. This scales
+// past the throughput of a single decoder on many-core machines.
+//
+// Both sources must be seekable. Temporal metrics see every chunk boundary as
+// a scene cut, which is why chunks follow keyframes by default.
+//
+// Returns per-metric arrays of per-frame scores in frame order, the same as
+// Comparator.Run.
+func RunChunked(ctx context.Context, opts ChunkedOptions) (
+	map[string][]float64, error) {
+	if opts.Open == nil || opts.NewMetrics == nil {
+		return nil, errors.New("chunked comparison needs a source opener and " +
+			"a metrics factory")
+	}
+	opts.setDefaults()
+
+	chunks, numFrames, err := planChunks(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	results := make(map[string][]float64)
+	var done int
+
+	progress := func() {
+		if opts.Progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		opts.Progress(done, numFrames)
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(opts.Parallel)
+
+	for _, chunk := range chunks {
+		group.Go(func() error {
+			scores, err := runChunk(ctx, &opts, chunk, progress)
+			if err != nil {
+				return fmt.Errorf("chunk [%d, %d): %w", chunk.Start,
+					chunk.End(), err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for name, values := range scores {
+				if results[name] == nil {
+					results[name] = make([]float64, numFrames)
+				}
+				copy(results[name][chunk.Start:], values)
+			}
+			return nil
+		})
+	}
+
+	return results, group.Wait()
+}
+
+// planChunks returns the chunks to compare and the total number of frames
+// they cover.
+func planChunks(opts *ChunkedOptions) ([]video.FrameRange, int, error) {
+	a, _, err := opts.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	numFrames := opts.NumFrames
+	if numFrames <= 0 || numFrames > a.GetNumFrames() {
+		numFrames = a.GetNumFrames()
+	}
+
+	if len(opts.Chunks) > 0 {
+		for _, chunk := range opts.Chunks {
+			if chunk.Start < 0 || chunk.Count <= 0 || chunk.End() > numFrames {
+				return nil, 0, fmt.Errorf("chunk [%d, %d) is outside the %d "+
+					"compared frames", chunk.Start, chunk.End(), numFrames)
+			}
+		}
+		return opts.Chunks, numFrames, nil
+	}
+
+	var keyFrames []int
+	if keyFrameSource, ok := video.As[video.KeyFrameSource](a); ok {
+		if keyFrames, err = keyFrameSource.GetKeyFrames(); err != nil {
+			return nil, 0, fmt.Errorf("failed to get keyframes: %w", err)
+		}
+	}
+
+	return SceneChunks(keyFrames, numFrames, opts.MinChunkFrames), numFrames,
+		nil
+}
+
+// SceneChunks splits numFrames frames into chunks that start on keyframes,
+// merging scenes until each chunk has at least minFrames frames. The last
+// chunk may be shorter. Without keyframes the frames are split into chunks of
+// exactly minFrames.
+func SceneChunks(keyFrames []int, numFrames, minFrames int) []video.FrameRange {
+	var chunks []video.FrameRange
+	start := 0
+
+	for _, keyFrame := range keyFrames {
+		if keyFrame >= numFrames {
+			break
+		}
+		if keyFrame-start >= minFrames {
+			chunks = append(chunks, video.FrameRange{Start: start,
+				Count: keyFrame - start})
+			start = keyFrame
+		}
+	}
+
+	if len(keyFrames) == 0 {
+		for ; numFrames-start > minFrames; start += minFrames {
+			chunks = append(chunks, video.FrameRange{Start: start,
+				Count: minFrames})
+		}
+	}
+
+	if start < numFrames {
+		chunks = append(chunks, video.FrameRange{Start: start,
+			Count: numFrames - start})
+	}
+
+	return chunks
+}
+
+// runChunk opens a fresh source pair, compares chunk with its own Comparator
+// and returns the per-frame scores relative to the chunk start.
+func runChunk(ctx context.Context, opts *ChunkedOptions,
+	chunk video.FrameRange, progress func()) (map[string][]float64, error) {
+	a, b, err := opts.Open()
+	if err != nil {
+		return nil, err
+	}
+	// The comparator gets slices, which do not own a and b.
+	defer a.Close()
+	defer b.Close()
+
+	if a, err = sources.Slice(a, chunk.Start, chunk.Count); err != nil {
+		return nil, fmt.Errorf("video a: %w", err)
+	}
+	if b, err = sources.Slice(b, chunk.Start, chunk.Count); err != nil {
+		return nil, fmt.Errorf("video b: %w", err)
+	}
+
+	metrics, err := opts.NewMetrics(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	comp, err := NewComparator(a, b, metrics, opts.FrameThreads, chunk.Count,
+		WithMemoryBudget(opts.MemoryBudget/int64(opts.Parallel)))
+	if err != nil {
+		for _, metric := range metrics {
+			metric.Close()
+		}
+		return nil, err
+	}
+	defer comp.Close()
+	comp.SetProgressCallback(func(int, int) { progress() })
+
+	if err = comp.SetIdentityShortCircuit(opts.SkipIdentical); err != nil {
+		return nil, err
+	}
+	if err = comp.SetQuickReject(opts.QuickRejectPSNR); err != nil {
+		return nil, err
+	}
+	if err = comp.SetFrameChecks(opts.CheckFrames); err != nil {
+		return nil, err
+	}
+	if err = comp.SetScoreCache(opts.ScoreCache); err != nil {
+		return nil, err
+	}
+
+	return comp.Run(ctx)
+}
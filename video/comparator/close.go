@@ -0,0 +1,64 @@
+package comparator
+
+import (
+	"errors"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
+)
+
+var (
+	// ErrAlreadyRun is returned by Run when the Comparator has already run
+	// and was not Reset since.
+	ErrAlreadyRun = errors.New("comparator has already run, call Reset " +
+		"to compare again")
+	// ErrClosed is returned by Run and Reset after Close.
+	ErrClosed = errors.New("comparator is closed")
+)
+
+// openComparators is the kind Comparators that are not closed yet are counted
+// under in video.DebugLeakReport.
+const openComparators = "comparators"
+
+// WithSharedSources keeps the sources open when the Comparator is closed, for
+// callers that still use them after the comparison. By default Close closes
+// both sources.
+func WithSharedSources() Option {
+	return func(c *Comparator) { c.sharedSources = true }
+}
+
+// Close releases everything the Comparator holds: the pinned frame buffers
+// are freed, every metric is closed and so are both sources, unless the
+// Comparator was created WithSharedSources. The scores returned by Run stay
+// valid. Must not be called while Run is in progress. Calling Close more than
+// once does nothing.
+func (c *Comparator) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	accounting.Add(openComparators, -1)
+
+	err := c.freeFrameBuffers()
+
+	for _, metric := range c.metrics {
+		metric.Close()
+	}
+
+	if !c.sharedSources {
+		err = errors.Join(err, c.videoA.Close(), c.videoB.Close())
+	}
+	return err
+}
+
+// freeFrameBuffers frees the planes of every frame buffer allocated for the
+// pools.
+func (c *Comparator) freeFrameBuffers() error {
+	var errs []error
+	for _, frame := range append(c.framesA, c.framesB...) {
+		for plane := range frame.NumPlanes() {
+			errs = append(errs, freePlane(frame.PlaneData(plane)))
+		}
+	}
+	c.framesA, c.framesB = nil, nil
+	return errors.Join(errs...)
+}
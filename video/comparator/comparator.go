@@ -9,46 +9,29 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/GreatValueCreamSoda/gometrics/blockingpool"
-	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
 	"github.com/GreatValueCreamSoda/gometrics/video"
 	"golang.org/x/sync/errgroup"
 )
 
 type ProgressCallback func(done int, total int)
 
-type Source interface {
-	GetFrame(*Frame) error
-	GetColorspace() *vship.Colorspace
-	GetNumFrames() int
-	GetPlaneSizes() ([3]int, [3]int)
-	GetFrameRate() float32
-}
-
-// Frame represents a single video Frame's data. It holds the pixel data for
-// the three color planes (typically Y, U, V in YUV format) and the line sizes
-// (stride) for each plane.
-type Frame struct {
-	data     [3][]byte // Pixel data for each of the three planes.
-	lineSize [3]int64  // Line size (stride) for each plane, in bytes.
-}
-
-func (f *Frame) Write(data [3][]byte, lineSize [3]int64) error {
-	for i := range f.data {
-		if len(f.data[i]) != len(data[i]) {
-			return errors.New("failed to write frame data. data plane sizes " +
-				"do not match")
-		}
-	}
-
-	for p := 0; p < 3; p++ {
-		copy(f.data[p], data[p])
-		f.lineSize[p] = int64(lineSize[p])
-	}
+// ScoresCallback is called with the scores of every compared frame pair as
+// soon as they are aggregated. Pairs complete in any order when there is
+// more than one frame thread. index is the position of the pair in the
+// per-frame scores, see FrameIndices. The scores map is not used by the
+// Comparator afterwards. Returning an error aborts the Run with that error.
+type ScoresCallback func(index int, scores map[string]float64) error
 
-	return nil
-}
+// FrameObserver is called with every compared frame pair before its metrics
+// run. index is the position of the pair in the per-frame scores. It is called
+// from the metric threads, concurrently for different pairs, and must not
+// keep the frames after it returns.
+type FrameObserver func(index int, a, b *video.Frame)
 
 // metricResult holds the computed metric scores for a specific frame pair.
 // The scores are a map of metric names to their float64 values.
@@ -63,6 +46,10 @@ type metricResult struct {
 type framePair struct {
 	index int
 	a, b  video.Frame
+	// refA and refB are the pair's references to its pooled frames. Stages
+	// that hand the frames to more than one consumer retain them, so each
+	// consumer releases its own reference.
+	refA, refB *blockingpool.Ref[video.Frame]
 }
 
 // Comparator orchestrates the concurrent comparison of two video sources using
@@ -83,18 +70,70 @@ type Comparator struct {
 	// not the number of metric threads as each metric will be called
 	// concurrently on each frame.
 	frameThreads int // Number of concurrent metric workers.
+	// limits holds a semaphore for every metric that may compute fewer frame
+	// pairs at once than there are frame threads, keyed by metric name.
+	limits map[string]chan struct{}
+	// pairDepth is the capacity of fPairChan. It defaults to half the frame
+	// threads and may be lowered to fit the memory budget.
+	pairDepth int
+	// memoryBudget caps the bytes of all frame buffers, see WithMemoryBudget.
+	// 0 means no budget.
+	memoryBudget int64
 	// A pool of reusable frames buffers that reader threads will pull from,
 	// copy the frame data to, and that metric threads will return.
 	framePoolA, framePoolB blockingpool.BlockingPool[video.Frame]
+	// framesA and framesB hold every frame buffer allocated for the pools, so
+	// Reset can refill them whatever state a previous Run left them in.
+	framesA, framesB []video.Frame
 	// The total number of frames that will be compared between video A and B.
 	numFrames int
+	// sourceFrames is the numFrames requested at construction, before any
+	// sampling mode reduced it.
+	sourceFrames int
+	// frameIndices maps each compared frame pair to the frame number read from
+	// both sources. A nil slice means frames are read sequentially from 0.
+	frameIndices []int
+
+	// hasherA and hasherB are set when frame hashing is enabled. The hashes of
+	// every compared frame pair are stored in hashesA and hashesB by the
+	// metric threads.
+	hasherA, hasherB *video.FrameHasher
+	hashesA, hashesB []video.FrameHash
+	// shortCircuit skips metrics on bit-identical frame pairs. sameProps is
+	// set when both sources share their ColorProperties, without which equal
+	// bytes do not mean equal pictures.
+	shortCircuit, sameProps bool
+
+	// differ is set when the quick reject is enabled, see SetQuickReject.
+	// rejected marks the compared frame pairs whose scores were imputed.
+	differ   *video.FrameDiffer
+	minPSNR  float64
+	rejected []bool
+
+	// checkerA and checkerB are set when frame checks are enabled, see
+	// SetFrameChecks.
+	checkerA, checkerB *video.FrameChecker
+
+	// scoreCache is set when scores are cached, see SetScoreCache.
+	// cachePrefixes holds the key prefix of every cached metric by name, and
+	// cacheHits counts the results taken from the cache.
+	scoreCache    *ScoreCache
+	cachePrefixes map[string]string
+	cacheHits     *atomic.Int64
+
+	// mask returns the areas of a pair left out of the comparison, see
+	// SetFrameMask. maskerA and maskerB blank them, and masked marks the
+	// compared frame pairs that had any.
+	mask             FrameMask
+	maskerA, maskerB *video.FrameMasker
+	masked           []bool
 
 	// Internal channels for the pipeline stages.
 
 	// videoAFrameChan and videoBFrameChan as the name implies are two channels
 	// frame reader thread A and B will write frames squentially to. These are
 	// then consumed by the frame pair goroutine.
-	videoAFrameChan, videoBFrameChan chan video.Frame
+	videoAFrameChan, videoBFrameChan chan *blockingpool.Ref[video.Frame]
 
 	// fPairChan is the channel all metric threads will read from. Each
 	// framePair will contain one frame from video A and one frame from video B
@@ -123,6 +162,30 @@ type Comparator struct {
 	// callback might be called with a earlier "total" than before, or for a
 	// frame before previous frames are done if frame threads is greater than 1
 	progress ProgressCallback
+
+	// scoresCallback receives the scores of every pair as it is aggregated.
+	scoresCallback ScoresCallback
+
+	// abortConditions stop the Run early, see SetAbortConditions. running
+	// holds the running score of every key and completed marks the pairs
+	// aggregated so far.
+	abortConditions []AbortCondition
+	running         map[string]runningScore
+	completed       []bool
+
+	// observer is called with every frame pair before its metrics run.
+	observer FrameObserver
+
+	// counters accumulates the stats of every metric, keyed by name, see
+	// MetricStats. started is when the current Run began.
+	counters      map[string]*metricCounters
+	started       time.Time
+	statsCallback StatsCallback
+
+	// ran is set by Run and cleared by Reset, closed is set by Close.
+	ran, closed bool
+	// sharedSources keeps the sources open on Close, see WithSharedSources.
+	sharedSources bool
 }
 
 // NewComparator creates a new Comparator instance.
@@ -130,27 +193,54 @@ type Comparator struct {
 // Validates inputs, preallocates reusable frame buffers, and initializes
 // channels.
 //
-// frameThreads controls how many frame pairs are processed concurrently. If
-// any metric requires strict sequential processing, set frameThreads = 1.
+// frameThreads controls how many frame pairs are processed concurrently. Pass
+// 0 to use the largest worker count declared by the metrics, see
+// video.ConcurrencyLimiter. Each metric is held to its own limit whatever
+// frameThreads is. Metrics that require strict sequential processing should
+// implement video.SequentialMetric; they are then run in frame order on a
+// dedicated lane.
 //
 // numFrames specifies how many frame pairs to compare (must not exceed the
 // available frames in either source).
+//
+// opts are applied before any buffer is allocated, see WithMemoryBudget.
 func NewComparator(videoA, videoB video.Source, metrics []video.Metric, frameThreads,
-	numFrames int) (Comparator, error) {
+	numFrames int, opts ...Option) (Comparator, error) {
 	c := Comparator{
 		videoA:       videoA,
 		videoB:       videoB,
 		metrics:      metrics,
 		frameThreads: frameThreads,
 		numFrames:    numFrames,
+		sourceFrames: numFrames,
 		finalScores:  make(map[string][]float64),
+		counters:     newMetricCounters(metrics),
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if c.frameThreads == 0 {
+		c.frameThreads = autoFrameThreads(metrics)
 	}
 
 	if err := c.validateArguments(); err != nil {
 		return Comparator{}, err
 	}
 
-	totalBuffers := c.calculateTotalNumberOfFrameBuffers()
+	c.pairDepth = c.frameThreads / 2
+	if c.memoryBudget > 0 {
+		if err := c.fitMemoryBudget(); err != nil {
+			return Comparator{}, err
+		}
+	}
+
+	c.limits = metricLimits(c.metrics, c.frameThreads)
+
+	c.makeChannels()
+
+	totalBuffers := c.framePairBuffers()
 
 	c.framePoolA = blockingpool.NewBlockingPool[video.Frame](totalBuffers)
 	c.framePoolB = blockingpool.NewBlockingPool[video.Frame](totalBuffers)
@@ -158,15 +248,24 @@ func NewComparator(videoA, videoB video.Source, metrics []video.Metric, frameThr
 	for range totalBuffers {
 		err := c.allocateFrameBuffer()
 		if err != nil {
+			_ = c.freeFrameBuffers()
 			return Comparator{}, err
 		}
 	}
 
-	c.scoresChan = make(chan metricResult, frameThreads)
-
+	accounting.Add(openComparators, 1)
 	return c, nil
 }
 
+// makeChannels creates the channels between the pipeline stages. Run closes
+// them, so they are made anew for every run.
+func (c *Comparator) makeChannels() {
+	c.videoAFrameChan = make(chan *blockingpool.Ref[video.Frame], 1)
+	c.videoBFrameChan = make(chan *blockingpool.Ref[video.Frame], 1)
+	c.fPairChan = make(chan framePair, c.pairDepth)
+	c.scoresChan = make(chan metricResult, c.frameThreads)
+}
+
 func (c *Comparator) validateArguments() error {
 	if c.videoA == nil || c.videoB == nil {
 		return errors.New("either video a or video b was passed as a nil ptr")
@@ -190,25 +289,23 @@ func (c *Comparator) validateArguments() error {
 			" be compared")
 	}
 
-	return nil
-}
-
-// calculateTotalNumberOfFrameBuffers returns conservative estimate of needed
-// buffers accounting for pipeline stages and worker concurrency.
-func (c *Comparator) calculateTotalNumberOfFrameBuffers() int {
-	c.videoBFrameChan = make(chan video.Frame, 1)
-	c.videoAFrameChan = make(chan video.Frame, 1)
-	var totalFrameBuffers int = 1
+	if err := c.validateLayouts(); err != nil {
+		return err
+	}
 
-	c.fPairChan = make(chan framePair, c.frameThreads/2)
-	totalFrameBuffers = totalFrameBuffers + (c.frameThreads/2 + 1) +
-		c.frameThreads
+	return c.validateBitDepths()
+}
 
-	return totalFrameBuffers
+// framePairBuffers returns conservative estimate of needed buffers accounting
+// for pipeline stages and worker concurrency: one frame being read, one queued
+// for the frame pair thread, pairDepth queued for the metric threads and one
+// per frame thread.
+func (c *Comparator) framePairBuffers() int {
+	return 1 + 1 + c.pairDepth + c.frameThreads
 }
 
-// allocateFrameBuffer allocates pinned memory buffers for all three planes of
-// both input videos (reference and distorted) and initializes the
+// allocateFrameBuffer allocates pinned memory buffers for every plane of both
+// input videos (reference and distorted) and initializes the
 // corresponding Frame objects in their respective frame pools.
 //
 // This method is intended to be called exactly once during Comparator
@@ -218,29 +315,33 @@ func (c *Comparator) allocateFrameBuffer() error {
 	videoAPlaneSizes, videoALineSizes := c.videoA.GetPlaneSizes()
 	videoBPlaneSizes, videoBLineSizes := c.videoB.GetPlaneSizes()
 
-	var code vship.ExceptionCode
-	var sourceBuffers, distortedBuffers [3][]byte
+	var sourceBuffers, distortedBuffers [video.MaxPlanes][]byte
 	var planeIndex int = 0
+	var err error
 
 	// AUTISM I HATE INTENDETD FOR LOOPS GET OVER IT.
 
 allocPlanes:
-	if planeIndex >= 3 {
+	if planeIndex >= video.MaxPlanes {
 		goto createFrames
 	}
 
-	// Allocate reference (source) plane
-	sourceBuffers[planeIndex], code = vship.PinnedMalloc(
-		videoAPlaneSizes[planeIndex])
-	if !code.IsNone() {
-		return code.GetError()
+	// Allocate reference (source) plane, if the source has it
+	if videoAPlaneSizes[planeIndex] > 0 {
+		sourceBuffers[planeIndex], err = allocPlane(
+			videoAPlaneSizes[planeIndex])
+		if err != nil {
+			return err
+		}
 	}
 
-	// Allocate distorted plane
-	distortedBuffers[planeIndex], code = vship.PinnedMalloc(
-		videoBPlaneSizes[planeIndex])
-	if !code.IsNone() {
-		return code.GetError()
+	// Allocate distorted plane, if the source has it
+	if videoBPlaneSizes[planeIndex] > 0 {
+		distortedBuffers[planeIndex], err = allocPlane(
+			videoBPlaneSizes[planeIndex])
+		if err != nil {
+			return err
+		}
 	}
 
 	planeIndex++
@@ -252,22 +353,51 @@ createFrames:
 		return err
 	}
 	c.framePoolA.Put(frameA)
+	c.framesA = append(c.framesA, frameA)
 
 	frameB, err := video.NewFrame(distortedBuffers, videoBLineSizes)
 	if err != nil {
 		return err
 	}
 	c.framePoolB.Put(frameB)
+	c.framesB = append(c.framesB, frameB)
 
 	return nil
 }
 
 // Run executes the full comparison pipeline and blocks until completion.
 // Returns per-metric arrays of per-frame scores.
+//
+// When an abort condition is met, see SetAbortConditions, Run returns an
+// AbortError with the scores of the leading frame pairs that completed. A
+// metric panicking is returned as a MetricPanicError.
+//
+// Run may be called once. Further calls return ErrAlreadyRun until Reset
+// prepares another comparison, and calls after Close return ErrClosed.
 func (c *Comparator) Run(parentCtx context.Context) (
 	map[string][]float64, error) {
+	switch {
+	case c.closed:
+		return nil, ErrClosed
+	case c.ran:
+		return nil, ErrAlreadyRun
+	}
+	c.ran = true
+
 	group, ctx := errgroup.WithContext(parentCtx)
 	c.ctx = ctx
+	c.started = time.Now()
+
+	if c.hasherA != nil {
+		c.hashesA = make([]video.FrameHash, c.numFrames)
+		c.hashesB = make([]video.FrameHash, c.numFrames)
+	}
+	if c.differ != nil {
+		c.rejected = make([]bool, c.numFrames)
+	}
+	if c.mask != nil {
+		c.masked = make([]bool, c.numFrames)
+	}
 
 	group.Go(func() error {
 		defer close(c.videoAFrameChan)
@@ -287,7 +417,12 @@ func (c *Comparator) Run(parentCtx context.Context) (
 
 	group.Go(c.aggregateResults)
 
-	return c.finalScores, group.Wait()
+	err := group.Wait()
+	var abort *AbortError
+	if errors.As(err, &abort) {
+		c.truncateScores(c.completedPrefix())
+	}
+	return c.finalScores, err
 }
 
 // SetProgressCallback registers an optional progress callback. Must be called
@@ -296,6 +431,19 @@ func (c *Comparator) SetProgressCallback(cb ProgressCallback) {
 	c.progress = cb
 }
 
+// SetScoresCallback registers an optional callback receiving the scores of
+// every frame pair as it completes, e.g. to stream results or stop early.
+// Must be called before Run(). Pass nil to clear.
+func (c *Comparator) SetScoresCallback(cb ScoresCallback) {
+	c.scoresCallback = cb
+}
+
+// SetFrameObserver registers an optional frame observer. Must be called before
+// Run(). Pass nil to clear.
+func (c *Comparator) SetFrameObserver(observer FrameObserver) {
+	c.observer = observer
+}
+
 // ----------------------------------------------------------------------------
 // Reader Threads
 // ----------------------------------------------------------------------------
@@ -307,11 +455,11 @@ func (c *Comparator) spawnReaderThreads() error {
 	group, ctx := errgroup.WithContext(c.ctx)
 
 	group.Go(func() error {
-		return c.readerThread(ctx, c.videoA,
+		return c.readerThread(ctx, c.videoA, c.checkerA, "video a",
 			c.videoAFrameChan, c.framePoolA)
 	})
 	group.Go(func() error {
-		return c.readerThread(ctx, c.videoB,
+		return c.readerThread(ctx, c.videoB, c.checkerB, "video b",
 			c.videoBFrameChan, c.framePoolB)
 	})
 
@@ -320,26 +468,40 @@ func (c *Comparator) spawnReaderThreads() error {
 }
 
 // readerThread reads from the supplied video source and sends them to the
-// frameChan till the total number of frames is read or the context is canceled
+// frameChan till the total number of frames is read or the context is canceled.
+// Frames are checked by checker if it is set, with name identifying the source
+// in errors.
 func (c *Comparator) readerThread(ctx context.Context, source video.Source,
-	frameChan chan video.Frame, framePool blockingpool.BlockingPool[video.Frame]) error {
+	checker *video.FrameChecker, name string,
+	frameChan chan *blockingpool.Ref[video.Frame],
+	framePool blockingpool.BlockingPool[video.Frame]) error {
 
 	for i := 0; i < c.numFrames; i++ {
-		var frame video.Frame
+		buffer, err := framePool.GetCtx(ctx)
+		if err != nil {
+			return err
+		}
+		frame := framePool.NewRef(buffer)
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			frame = framePool.Get()
+		if err := c.seekSource(source, i); err != nil {
+			frame.Release()
+			return err
 		}
 
-		if err := source.GetFrame(frame); err != nil {
+		if err := source.GetFrame(frame.Value()); err != nil {
+			frame.Release()
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		value := frame.Value()
+		if err := c.checkFrame(checker, name, i, &value); err != nil {
+			frame.Release()
 			return err
 		}
 
 		select {
 		case <-ctx.Done():
+			frame.Release()
 			return ctx.Err()
 		case frameChan <- frame:
 		}
@@ -360,7 +522,7 @@ func (c *Comparator) readerThread(ctx context.Context, source video.Source,
 // If any error occures exectuion is terminated early and the error is returned
 func (c *Comparator) spawnFramePairThreads() error {
 	for i := range make([]struct{}, c.numFrames) {
-		var a, b video.Frame
+		var a, b *blockingpool.Ref[video.Frame]
 
 		select {
 		case <-c.ctx.Done():
@@ -377,7 +539,7 @@ func (c *Comparator) spawnFramePairThreads() error {
 		select {
 		case <-c.ctx.Done():
 			return c.ctx.Err()
-		case c.fPairChan <- framePair{i, a, b}:
+		case c.fPairChan <- framePair{i, a.Value(), b.Value(), a, b}:
 		}
 	}
 	return nil
@@ -388,7 +550,9 @@ func (c *Comparator) spawnFramePairThreads() error {
 // ----------------------------------------------------------------------------
 
 // spawnMetricsThreads starts metricThreads goroutines that each run
-// metricThread, consuming frame pairs and producing metricResult values.
+// metricThread, consuming frame pairs and producing metricResult values. If
+// any metric is sequential an ordered lane is started next to them, see
+// orderedLane.
 //
 // When fPairChan closes, scoresChan is closed.
 //
@@ -396,8 +560,31 @@ func (c *Comparator) spawnFramePairThreads() error {
 func (c *Comparator) spawnMetricsThreads() error {
 	group, ctx := errgroup.WithContext(c.ctx)
 
+	parallel, sequential := splitSequential(c.metrics)
+
+	var ordered chan orderedPair
+	if len(sequential) > 0 {
+		ordered = make(chan orderedPair, c.frameThreads)
+		group.Go(func() error {
+			return c.orderedLane(ctx, sequential, ordered)
+		})
+	}
+
+	var workers sync.WaitGroup
 	for range c.frameThreads {
-		group.Go(func() error { return c.metricThread(ctx) })
+		workers.Add(1)
+		group.Go(func() error {
+			defer workers.Done()
+			return c.metricThread(ctx, parallel, ordered)
+		})
+	}
+
+	if ordered != nil {
+		group.Go(func() error {
+			workers.Wait()
+			close(ordered)
+			return nil
+		})
 	}
 
 	err := group.Wait()
@@ -406,15 +593,31 @@ func (c *Comparator) spawnMetricsThreads() error {
 
 // metricThread consumes frame pairs from fPairChan, computes all requested
 // metrics for each pair in parallel, and sends a metricResult on scoresChan.
+// If ordered is not nil the pair and its scores are passed on to the ordered
+// lane instead, which finishes the pair.
 //
 // If any error occures exectuion is terminated early and the error is returned
-func (c *Comparator) metricThread(ctx context.Context) error {
+func (c *Comparator) metricThread(ctx context.Context, metrics []video.Metric,
+	ordered chan<- orderedPair) error {
 	for pair := range withContext(ctx, c.fPairChan) {
-		scores, err := c.computeFrameMetrics(pair, c.metrics)
+		scores, err := c.computeFrameMetrics(pair, metrics)
 		if err != nil {
+			c.releaseFramePair(pair)
 			return err
 		}
 
+		if ordered != nil {
+			select {
+			case <-ctx.Done():
+				c.releaseFramePair(pair)
+				return ctx.Err()
+			case ordered <- orderedPair{pair, scores}:
+			}
+			continue
+		}
+
+		c.releaseFramePair(pair)
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -424,19 +627,47 @@ func (c *Comparator) metricThread(ctx context.Context) error {
 	return nil
 }
 
-// computeFrameMetrics runs all metrics in parallel for one frame pair. Returns
-// frames to pools on exit (via defer).
+// releaseFramePair drops the references of pair to its frames, returning
+// them to their pools once no other consumer holds them.
+func (c *Comparator) releaseFramePair(pair framePair) {
+	pair.refA.Release()
+	pair.refB.Release()
+}
+
+// computeFrameMetrics runs all metrics in parallel for one frame pair. The
+// frames are not returned to their pools.
 func (c *Comparator) computeFrameMetrics(pair framePair, metrics []video.Metric) (
 	map[string]float64, error) {
-	defer c.framePoolA.Put(pair.a)
-	defer c.framePoolB.Put(pair.b)
-
-	if len(metrics) == 0 {
-		return map[string]float64{}, nil
+	if c.observer != nil {
+		c.observer(pair.index, &pair.a, &pair.b)
+	}
+	if c.mask != nil {
+		c.maskFramePair(pair)
 	}
 
 	result := make(map[string]float64, len(metrics)*3)
 
+	if c.hasherA != nil {
+		metrics = c.hashFramePair(pair, metrics, result)
+	}
+	if c.differ != nil {
+		metrics = c.quickReject(pair, metrics, result)
+	}
+	if c.scoreCache != nil {
+		metrics = c.cachedScores(pair, metrics, result)
+	}
+
+	return result, c.runMetrics(pair, metrics, result)
+}
+
+// runMetrics runs metrics in parallel on pair and merges their scores into
+// result.
+func (c *Comparator) runMetrics(pair framePair, metrics []video.Metric,
+	result map[string]float64) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
 	// We let each metric within a fram run in parallel instead of one at a
 	// time. This on my machine with ssimu2 + butter increased fps from 85-87
 	// to a consistent 90 fps with 1 worker. Should give small gains when
@@ -456,18 +687,39 @@ func (c *Comparator) computeFrameMetrics(pair framePair, metrics []video.Metric)
 		})
 	}
 
-	return result, group.Wait()
+	return group.Wait()
 }
 
-// computeFrameMetric invokes a single Metric's Compute method and merges its
-// results into the result map, returning an error on failure or duplicate
-// keys.
-func (Comparator) computeFrameMetric(pair framePair, res map[string]float64,
-	metric video.Metric, mu *sync.Mutex) error {
-	scores, err := metric.Compute(pair.a, pair.b)
+// computeFrameMetric invokes a single Metric's Compute method, once the metric
+// is within its concurrency limit, and merges its results into the result
+// map, returning an error on failure or duplicate keys.
+func (c *Comparator) computeFrameMetric(pair framePair,
+	res map[string]float64, metric video.Metric, mu *sync.Mutex) error {
+	release, err := c.acquireMetric(c.ctx, metric)
 	if err != nil {
+		return err
+	}
+	// Compute cannot be interrupted once started, so do not start one after
+	// the run was cancelled.
+	if err = c.ctx.Err(); err != nil {
+		release()
+		return err
+	}
+	start := time.Now()
+	scores, err := c.compute(metric, pair)
+	c.recordCompute(metric, time.Since(start))
+	release()
+	switch {
+	case errors.Is(err, ErrMetricPanic):
+		return err
+	case err != nil:
 		return fmt.Errorf("%s computation failed: %w", metric.Name(), err)
 	}
+	if c.scoreCache != nil {
+		if err := c.cacheScores(pair, metric, scores); err != nil {
+			return err
+		}
+	}
 	mu.Lock()
 	defer mu.Unlock()
 	for k, v := range scores {
@@ -488,20 +740,35 @@ func (Comparator) computeFrameMetric(pair framePair, res map[string]float64,
 // accumulates them into the Comparator's finalScores map.
 func (c *Comparator) aggregateResults() error {
 	completed := 0
+	c.running = make(map[string]runningScore)
+	c.completed = make([]bool, c.numFrames)
+
 	for res := range withContext(c.ctx, c.scoresChan) {
+		if res.index < 0 || res.index >= c.numFrames {
+			return errors.New("aggergated index outside of numframe")
+		}
 		for name, val := range res.scores {
-			if res.index < 0 || res.index >= c.numFrames {
-				return errors.New("aggergated index outside of numframe")
-			}
 			if c.finalScores[name] == nil {
 				c.finalScores[name] = make([]float64, c.numFrames)
 			}
 			c.finalScores[name][res.index] = val
 		}
+		c.completed[res.index] = true
+		if c.scoresCallback != nil {
+			if err := c.scoresCallback(res.index, res.scores); err != nil {
+				return err
+			}
+		}
+		if err := c.checkAbort(res.index, res.scores); err != nil {
+			return err
+		}
 		completed++
 		if c.progress != nil {
 			c.progress(completed, c.numFrames)
 		}
+		if c.statsCallback != nil {
+			c.statsCallback(completed, c.numFrames, c.MetricStats())
+		}
 	}
 	return nil
 }
@@ -0,0 +1,59 @@
+package comparator
+
+import (
+	"context"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// autoFrameThreads returns the number of frame threads that keeps every metric
+// busy: the largest limit of the metrics running on the frame threads, see
+// video.ConcurrencyLimiter. Metrics without a limit count as one, and
+// sequential metrics are left out as they run on the ordered lane.
+func autoFrameThreads(metrics []video.Metric) int {
+	parallel, _ := splitSequential(metrics)
+
+	threads := 1
+	for _, metric := range parallel {
+		if limiter, ok := metric.(video.ConcurrencyLimiter); ok {
+			threads = max(threads, limiter.MaxConcurrency())
+		}
+	}
+	return threads
+}
+
+// metricLimits returns a semaphore for every metric whose limit is below
+// frameThreads, keyed by metric name. Metrics without an entry may run on
+// every frame thread at once.
+func metricLimits(metrics []video.Metric,
+	frameThreads int) map[string]chan struct{} {
+	limits := make(map[string]chan struct{})
+
+	for _, metric := range metrics {
+		limiter, ok := metric.(video.ConcurrencyLimiter)
+		if !ok {
+			continue
+		}
+		if n := limiter.MaxConcurrency(); n > 0 && n < frameThreads {
+			limits[metric.Name()] = make(chan struct{}, n)
+		}
+	}
+	return limits
+}
+
+// acquireMetric waits until metric may compute another frame pair and returns
+// the function releasing its slot.
+func (c *Comparator) acquireMetric(ctx context.Context,
+	metric video.Metric) (func(), error) {
+	slots, ok := c.limits[metric.Name()]
+	if !ok {
+		return func() {}, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	}
+}
@@ -0,0 +1,53 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SetFrameChecks enables checking that every frame read from both sources is
+// plausible before its metrics run, see video.FrameChecker. A frame failing
+// the checks ends the Run with an error naming the source and frame instead
+// of handing a corrupt buffer to a metric backend. Must be called before
+// Run().
+//
+// The checks read every sample of high bit depth and float formats, so they
+// cost some CPU time on the reader threads.
+func (c *Comparator) SetFrameChecks(enabled bool) error {
+	if !enabled {
+		c.checkerA, c.checkerB = nil, nil
+		return nil
+	}
+
+	checkerA, err := video.NewFrameChecker(c.videoA.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video a: %w", err)
+	}
+
+	checkerB, err := video.NewFrameChecker(c.videoB.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video b: %w", err)
+	}
+
+	c.checkerA, c.checkerB = checkerA, checkerB
+	return nil
+}
+
+// checkFrame checks frame i read from the named source, if checks are
+// enabled.
+func (c *Comparator) checkFrame(checker *video.FrameChecker, name string,
+	i int, frame *video.Frame) error {
+	if checker == nil {
+		return nil
+	}
+
+	if err := checker.Check(frame); err != nil {
+		number := i
+		if c.frameIndices != nil {
+			number = c.frameIndices[i]
+		}
+		return fmt.Errorf("%s frame %d: %w", name, number, err)
+	}
+	return nil
+}
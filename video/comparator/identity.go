@@ -0,0 +1,112 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SetFrameHashing enables hashing every compared frame of both sources with
+// xxhash. The hashes are available from FrameHashes after Run. Must be called
+// before Run().
+func (c *Comparator) SetFrameHashing(enabled bool) error {
+	if !enabled {
+		c.hasherA, c.hasherB, c.shortCircuit = nil, nil, false
+		return nil
+	}
+
+	hasherA, err := video.NewFrameHasher(c.videoA.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video a: %w", err)
+	}
+
+	hasherB, err := video.NewFrameHasher(c.videoB.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video b: %w", err)
+	}
+
+	c.hasherA, c.hasherB = hasherA, hasherB
+	c.sameProps = *c.videoA.GetColorProps() == *c.videoB.GetColorProps()
+	return nil
+}
+
+// SetIdentityShortCircuit enables frame hashing and skips scoring frame pairs
+// whose frames are bit-identical. Metrics implementing video.IdentityScorer
+// are given their perfect scores for such pairs, other metrics still run.
+// Must be called before Run().
+//
+// Frames only count as identical if both sources also share the same
+// ColorProperties, as equal bytes describe different pictures otherwise. The
+// hashes are 64 bits per plane, so a collision is not a practical concern.
+func (c *Comparator) SetIdentityShortCircuit(enabled bool) error {
+	if err := c.SetFrameHashing(enabled); err != nil {
+		return err
+	}
+	c.shortCircuit = enabled
+	return nil
+}
+
+// FrameHashes returns the hashes of every compared frame of video A and B, in
+// the same order as the per-frame scores returned by Run. Both are nil unless
+// frame hashing was enabled.
+func (c *Comparator) FrameHashes() (a, b []video.FrameHash) {
+	return c.hashesA, c.hashesB
+}
+
+// IdenticalFrames returns the number of compared frame pairs that were
+// bit-identical. It is only meaningful after Run with frame hashing enabled.
+func (c *Comparator) IdenticalFrames() int {
+	if !c.sameProps {
+		return 0
+	}
+
+	var identical int
+	for i := range c.hashesA {
+		if c.hashesA[i] == c.hashesB[i] {
+			identical++
+		}
+	}
+	return identical
+}
+
+// hashFramePair stores the hashes of pair and, if short-circuiting applies to
+// it, writes the identity scores into result. Returns the metrics that still
+// have to be computed.
+func (c *Comparator) hashFramePair(pair framePair, metrics []video.Metric,
+	result map[string]float64) []video.Metric {
+	hashA, hashB := c.hasherA.Hash(&pair.a), c.hasherB.Hash(&pair.b)
+	c.hashesA[pair.index], c.hashesB[pair.index] = hashA, hashB
+
+	if !c.shortCircuit || !c.sameProps || hashA != hashB {
+		return metrics
+	}
+
+	return identityScores(metrics, result)
+}
+
+// identityScores writes the identity scores of every metric implementing
+// video.IdentityScorer into result and returns the metrics that still have
+// to be computed.
+func identityScores(metrics []video.Metric,
+	result map[string]float64) []video.Metric {
+	remaining := make([]video.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		scorer, ok := metric.(video.IdentityScorer)
+		if !ok {
+			remaining = append(remaining, metric)
+			continue
+		}
+
+		scores, ok := scorer.IdentityScores()
+		if !ok {
+			remaining = append(remaining, metric)
+			continue
+		}
+
+		for name, value := range scores {
+			result[name] = value
+		}
+	}
+
+	return remaining
+}
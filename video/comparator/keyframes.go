@@ -0,0 +1,166 @@
+package comparator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// KeyFrameMode selects which frames of the sources are compared. Anything
+// other than KeyFrameModeOff trades completeness for speed and is intended
+// for quick smoke tests of long content.
+type KeyFrameMode int
+
+const (
+	// KeyFrameModeOff compares every frame sequentially. This is the default.
+	KeyFrameModeOff KeyFrameMode = iota
+	// KeyFrameModeKeyFrames compares only the keyframes of video A.
+	KeyFrameModeKeyFrames
+	// KeyFrameModeGOP compares one frame per GOP of video A, taken halfway
+	// between two keyframes. Mid-GOP frames are usually predicted frames and
+	// are more representative of the encode than the keyframes themselves.
+	KeyFrameModeGOP
+)
+
+// SetKeyFrameMode restricts the comparison to the keyframes (or one frame per
+// GOP) of video A. Must be called before Run().
+//
+// Keyframes are taken from video A, which must implement
+// video.KeyFrameSource. Both sources must implement video.SeekableSource as
+// the frames are no longer read sequentially. The same frame numbers are read
+// from both sources.
+//
+// After a successful call, the per-frame score slices returned by Run hold one
+// entry per sampled frame. Use FrameIndices to map them back to source frame
+// numbers.
+func (c *Comparator) SetKeyFrameMode(mode KeyFrameMode) error {
+	if mode == KeyFrameModeOff {
+		c.frameIndices, c.numFrames = nil, c.sourceFrames
+		return nil
+	}
+
+	keyFrameSource, ok := video.As[video.KeyFrameSource](c.videoA)
+	if !ok {
+		return errors.New("video a does not support keyframe lookup")
+	}
+
+	if err := c.checkSeekable(); err != nil {
+		return fmt.Errorf("keyframe mode: %w", err)
+	}
+
+	keyFrames, err := keyFrameSource.GetKeyFrames()
+	if err != nil {
+		return fmt.Errorf("failed to get keyframes: %w", err)
+	}
+
+	indices := sampleKeyFrames(keyFrames, c.sourceFrames, mode)
+	if len(indices) == 0 {
+		return errors.New("no keyframes found within the compared frames")
+	}
+
+	c.frameIndices, c.numFrames = indices, len(indices)
+	return nil
+}
+
+// SetFrameIndices restricts the comparison to the given frame numbers, which
+// must be increasing and below the numFrames the Comparator was constructed
+// with. Must be called before Run(). Pass nil to clear. It replaces any
+// keyframe mode, and both sources must implement video.SeekableSource.
+//
+// After a successful call, the per-frame score slices returned by Run hold one
+// entry per given frame, in the same order.
+func (c *Comparator) SetFrameIndices(indices []int) error {
+	if indices == nil {
+		c.frameIndices, c.numFrames = nil, c.sourceFrames
+		return nil
+	}
+	if len(indices) == 0 {
+		return errors.New("no frames to compare")
+	}
+
+	if err := c.checkSeekable(); err != nil {
+		return err
+	}
+
+	for i, index := range indices {
+		if index < 0 || index >= c.sourceFrames {
+			return fmt.Errorf("frame %d is outside of the %d compared "+
+				"frames", index, c.sourceFrames)
+		}
+		if i > 0 && index <= indices[i-1] {
+			return fmt.Errorf("frame %d does not follow frame %d", index,
+				indices[i-1])
+		}
+	}
+
+	c.frameIndices, c.numFrames = slices.Clone(indices), len(indices)
+	return nil
+}
+
+// checkSeekable returns an error unless both sources can seek, as sampling
+// modes read the frames out of order.
+func (c *Comparator) checkSeekable() error {
+	_, seekA := video.As[video.SeekableSource](c.videoA)
+	_, seekB := video.As[video.SeekableSource](c.videoB)
+	if !seekA || !seekB {
+		return errors.New("both sources must be seekable")
+	}
+	return nil
+}
+
+// FrameIndices returns the source frame number of every compared frame pair,
+// in the same order as the per-frame scores returned by Run.
+func (c *Comparator) FrameIndices() []int {
+	if c.frameIndices != nil {
+		return c.frameIndices
+	}
+
+	indices := make([]int, c.numFrames)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// sampleKeyFrames turns a sorted list of keyframes into the list of frame
+// numbers to compare for the given mode, ignoring anything at or past
+// numFrames.
+func sampleKeyFrames(keyFrames []int, numFrames int, mode KeyFrameMode) []int {
+	var indices []int
+
+	for i, keyFrame := range keyFrames {
+		if keyFrame >= numFrames {
+			break
+		}
+
+		if mode != KeyFrameModeGOP {
+			indices = append(indices, keyFrame)
+			continue
+		}
+
+		gopEnd := numFrames
+		if i+1 < len(keyFrames) && keyFrames[i+1] < numFrames {
+			gopEnd = keyFrames[i+1]
+		}
+		indices = append(indices, keyFrame+(gopEnd-keyFrame)/2)
+	}
+
+	return indices
+}
+
+// seekSource positions source at the frame number of the i-th compared pair
+// when a sampling mode is active. Sequential reads need no seeking.
+func (c *Comparator) seekSource(source video.Source, i int) error {
+	if c.frameIndices == nil {
+		return nil
+	}
+
+	seekable, ok := video.As[video.SeekableSource](source)
+	if !ok {
+		return errors.New("source does not support seeking")
+	}
+
+	return seekable.SeekFrame(c.frameIndices[i])
+}
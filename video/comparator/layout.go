@@ -0,0 +1,57 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// validateLayouts makes sure every metric can read the plane layouts of the
+// two sources, and that each source provides as many planes as its layout
+// needs.
+func (c *Comparator) validateLayouts() error {
+	layoutA, err := sourceLayout(c.videoA)
+	if err != nil {
+		return fmt.Errorf("video a: %w", err)
+	}
+
+	layoutB, err := sourceLayout(c.videoB)
+	if err != nil {
+		return fmt.Errorf("video b: %w", err)
+	}
+
+	for _, metric := range c.metrics {
+		for _, layout := range [2]video.PlaneLayout{layoutA, layoutB} {
+			if !metricSupportsLayout(metric, layout) {
+				return fmt.Errorf("%s does not support %s frames", metric.Name(),
+					layout)
+			}
+		}
+	}
+
+	return nil
+}
+
+func sourceLayout(source video.Source) (video.PlaneLayout, error) {
+	layout, err := source.GetColorProps().Layout()
+	if err != nil {
+		return video.LayoutUnknown, err
+	}
+
+	planeSizes, _ := source.GetPlaneSizes()
+	for i := range layout.NumPlanes() {
+		if planeSizes[i] == 0 {
+			return video.LayoutUnknown, fmt.Errorf("%s layout needs %d planes "+
+				"but plane %d is empty", layout, layout.NumPlanes(), i)
+		}
+	}
+
+	return layout, nil
+}
+
+func metricSupportsLayout(metric video.Metric, layout video.PlaneLayout) bool {
+	if checker, ok := metric.(video.LayoutChecker); ok {
+		return checker.SupportsLayout(layout)
+	}
+	return layout == video.LayoutYUV || layout == video.LayoutRGB
+}
@@ -0,0 +1,71 @@
+package comparator
+
+import (
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// FrameMask returns the areas of a frame pair to leave out of the comparison,
+// in luma samples of video A. frame is the source frame number of the pair.
+// It is called from the metric threads, concurrently for different pairs, and
+// must not keep the frames after it returns.
+type FrameMask func(frame int, a, b *video.Frame) []video.Rect
+
+// SetFrameMask blanks the areas mask returns in both frames of every pair
+// before the metrics run, so that differences there, such as subtitles burned
+// into only one of the sources, are not scored. The rects are scaled to the
+// size of video B. Must be called before Run(). Pass nil to clear.
+//
+// The frame observer sees the frames before they are masked, while frame
+// hashing and the quick reject see them masked. Masked reports which pairs
+// had any area blanked.
+func (c *Comparator) SetFrameMask(mask FrameMask) error {
+	if mask == nil {
+		c.mask, c.maskerA, c.maskerB = nil, nil, nil
+		return nil
+	}
+
+	maskerA, err := video.NewFrameMasker(c.videoA.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video a: %w", err)
+	}
+	maskerB, err := video.NewFrameMasker(c.videoB.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("video b: %w", err)
+	}
+
+	c.mask, c.maskerA, c.maskerB = mask, maskerA, maskerB
+	return nil
+}
+
+// Masked returns, for every compared frame pair in the order of the per-frame
+// scores returned by Run, whether any of its area was masked. It is nil unless
+// a frame mask was set.
+func (c *Comparator) Masked() []bool {
+	return c.masked
+}
+
+// maskFramePair blanks the areas the frame mask returns for pair.
+func (c *Comparator) maskFramePair(pair framePair) {
+	frame := pair.index
+	if c.frameIndices != nil {
+		frame = c.frameIndices[pair.index]
+	}
+
+	rects := c.mask(frame, &pair.a, &pair.b)
+	if len(rects) == 0 {
+		return
+	}
+
+	propsA, propsB := c.videoA.GetColorProps(), c.videoB.GetColorProps()
+	c.maskerA.Fill(&pair.a, rects)
+
+	scaled := make([]video.Rect, len(rects))
+	for i, rect := range rects {
+		scaled[i] = rect.Scale(propsA.Width, propsA.Height, propsB.Width,
+			propsB.Height)
+	}
+	c.maskerB.Fill(&pair.b, scaled)
+	c.masked[pair.index] = true
+}
@@ -0,0 +1,193 @@
+package comparator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+	"golang.org/x/sync/errgroup"
+)
+
+// Reference is one of the references of RunMultiReference.
+type Reference struct {
+	// Label marks the scores against the reference, see ReferenceKey.
+	Label  string
+	Source video.Source
+}
+
+// ReferenceKey returns the key a score against the reference with the given
+// label has, e.g. "Ssimulacra2@pre-grade".
+func ReferenceKey(key, label string) string { return key + "@" + label }
+
+// ReferenceMetricsFactory creates the metrics comparing the reference with
+// the given label to the distortion. The metrics are closed once the
+// reference is compared.
+type ReferenceMetricsFactory func(label string, reference,
+	distortion video.Source) ([]video.Metric, error)
+
+// MultiReferenceOptions configures RunMultiReference.
+type MultiReferenceOptions struct {
+	References []Reference
+	Distortion video.Source
+	NewMetrics ReferenceMetricsFactory
+
+	// Frame threads of each reference's Comparator. Defaults to the largest
+	// worker count declared by its metrics, see NewComparator.
+	FrameThreads int
+	// The most frames a comparison may run ahead of the slowest one, each
+	// held in memory until every comparison has read it. Defaults to 8.
+	Ahead int
+	// Called after every compared frame with the total across all
+	// references. Calls are serialized.
+	Progress ProgressCallback
+	// Skip scoring bit-identical frame pairs, see
+	// Comparator.SetIdentityShortCircuit.
+	SkipIdentical bool
+	// Check every frame before scoring it, see Comparator.SetFrameChecks.
+	CheckFrames bool
+	// Reuse and add scores to this cache, see Comparator.SetScoreCache.
+	ScoreCache *ScoreCache
+}
+
+// RunMultiReference compares one distortion against several references at
+// once, e.g. a delivery against the masters before and after grading to tell
+// which one it was derived from. The distortion is decoded once and shared
+// by one Comparator per reference, see sources.Tee, so the references are
+// compared concurrently and at most opts.Ahead frames apart.
+//
+// Frames are paired by number, each reference up to the shorter of it and
+// the distortion. Returns per-frame scores in frame order like
+// Comparator.Run, every key marked with the label of its reference by
+// ReferenceKey. The distortion is closed, the references are left open.
+func RunMultiReference(ctx context.Context, opts MultiReferenceOptions) (
+	map[string][]float64, error) {
+	if opts.Distortion == nil || opts.NewMetrics == nil {
+		return nil, errors.New("multi-reference comparison needs a " +
+			"distortion and a metrics factory")
+	}
+	if err := checkReferences(opts.References); err != nil {
+		opts.Distortion.Close()
+		return nil, err
+	}
+	if opts.Ahead < 1 {
+		opts.Ahead = 8
+	}
+	if opts.FrameThreads < 0 {
+		opts.FrameThreads = 0
+	}
+
+	readers, err := sources.Tee(opts.Distortion, len(opts.References),
+		opts.Ahead)
+	if err != nil {
+		opts.Distortion.Close()
+		return nil, err
+	}
+
+	var total int
+	for _, reference := range opts.References {
+		total += min(reference.Source.GetNumFrames(),
+			opts.Distortion.GetNumFrames())
+	}
+
+	var mu sync.Mutex
+	results := make(map[string][]float64)
+	var done int
+
+	progress := func() {
+		if opts.Progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		opts.Progress(done, total)
+	}
+
+	group, ctx := errgroup.WithContext(ctx)
+
+	for i, reference := range opts.References {
+		group.Go(func() error {
+			// Closing the reader lets the other comparisons run past the
+			// frames this one has not read, however it ends.
+			defer readers[i].Close()
+
+			scores, err := runReference(ctx, &opts, reference, readers[i],
+				progress)
+			if err != nil {
+				return fmt.Errorf("reference %s: %w", reference.Label, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for name, values := range scores {
+				results[ReferenceKey(name, reference.Label)] = values
+			}
+			return nil
+		})
+	}
+
+	return results, group.Wait()
+}
+
+// checkReferences returns why references cannot be compared together.
+func checkReferences(references []Reference) error {
+	if len(references) == 0 {
+		return errors.New("multi-reference comparison needs at least one " +
+			"reference")
+	}
+
+	labels := make(map[string]bool, len(references))
+	for _, reference := range references {
+		if reference.Label == "" {
+			return errors.New("every reference needs a label")
+		}
+		if labels[reference.Label] {
+			return fmt.Errorf("reference label %q is used twice",
+				reference.Label)
+		}
+		if reference.Source == nil {
+			return fmt.Errorf("reference %s has no source", reference.Label)
+		}
+		labels[reference.Label] = true
+	}
+	return nil
+}
+
+// runReference compares reference to the distortion frames of reader with
+// its own Comparator.
+func runReference(ctx context.Context, opts *MultiReferenceOptions,
+	reference Reference, reader video.Source, progress func()) (
+	map[string][]float64, error) {
+	metrics, err := opts.NewMetrics(reference.Label, reference.Source,
+		reader)
+	if err != nil {
+		return nil, err
+	}
+
+	numFrames := min(reference.Source.GetNumFrames(), reader.GetNumFrames())
+	comp, err := NewComparator(reference.Source, reader, metrics,
+		opts.FrameThreads, numFrames, WithSharedSources())
+	if err != nil {
+		for _, metric := range metrics {
+			metric.Close()
+		}
+		return nil, err
+	}
+	defer comp.Close()
+	comp.SetProgressCallback(func(int, int) { progress() })
+
+	if err = comp.SetIdentityShortCircuit(opts.SkipIdentical); err != nil {
+		return nil, err
+	}
+	if err = comp.SetFrameChecks(opts.CheckFrames); err != nil {
+		return nil, err
+	}
+	if err = comp.SetScoreCache(opts.ScoreCache); err != nil {
+		return nil, err
+	}
+
+	return comp.Run(ctx)
+}
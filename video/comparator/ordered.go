@@ -0,0 +1,78 @@
+package comparator
+
+import (
+	"context"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// orderedPair is a frame pair whose parallel metrics are done, waiting for its
+// sequential metrics in the ordered lane.
+type orderedPair struct {
+	pair   framePair
+	scores map[string]float64
+}
+
+// splitSequential separates the metrics that need every frame pair in order,
+// see video.SequentialMetric, from those that can run on any frame thread.
+func splitSequential(metrics []video.Metric) (parallel,
+	sequential []video.Metric) {
+	for _, metric := range metrics {
+		if s, ok := metric.(video.SequentialMetric); ok && s.Sequential() {
+			sequential = append(sequential, metric)
+			continue
+		}
+		parallel = append(parallel, metric)
+	}
+	return parallel, sequential
+}
+
+// orderedLane runs the sequential metrics on every frame pair in frame order.
+// Pairs arrive from the metric threads in whatever order their parallel
+// metrics finish and are held until every earlier pair has been through the
+// lane. The lane then merges the scores, returns the frames to their pools and
+// sends the metricResult on scoresChan.
+//
+// Held pairs keep their frame buffers, so a slow pair stalls the readers
+// rather than growing memory. It cannot deadlock, as the pair the lane waits
+// for was handed out before any pair being held.
+//
+// If any error occures exectuion is terminated early and the error is returned
+func (c *Comparator) orderedLane(ctx context.Context,
+	metrics []video.Metric, ordered <-chan orderedPair) error {
+	pending := make(map[int]orderedPair)
+	next := 0
+
+	defer func() {
+		for _, held := range pending {
+			c.releaseFramePair(held.pair)
+		}
+	}()
+
+	for item := range withContext(ctx, ordered) {
+		pending[item.pair.index] = item
+
+		for {
+			item, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			err := c.runMetrics(item.pair, metrics, item.scores)
+			c.releaseFramePair(item.pair)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case c.scoresChan <- metricResult{item.pair.index, item.scores}:
+			}
+		}
+	}
+
+	return ctx.Err()
+}
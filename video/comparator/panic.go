@@ -0,0 +1,60 @@
+package comparator
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// ErrMetricPanic is wrapped by every MetricPanicError.
+var ErrMetricPanic = errors.New("metric panicked")
+
+// MetricPanicError is returned by Run when a metric panicked while scoring a
+// frame pair. The panic is recovered so that one bad frame fails the Run
+// instead of the process, which matters to services running many
+// comparisons. Crashes inside C code, such as segmentation faults in a
+// metric backend, are not panics and still end the process.
+//
+// A metric that panicked may be left in an inconsistent state and should be
+// closed rather than used for another Run.
+type MetricPanicError struct {
+	// Metric is the name of the metric that panicked.
+	Metric string
+	// Index is the position of the frame pair in the per-frame scores and
+	// Frame the frame number read from both sources, see FrameIndices.
+	Index, Frame int
+	// Value is the value passed to panic and Stack the stack of the
+	// panicking goroutine.
+	Value any
+	Stack []byte
+}
+
+func (e *MetricPanicError) Error() string {
+	return fmt.Sprintf("%s: %s on frame %d: %v", ErrMetricPanic, e.Metric,
+		e.Frame, e.Value)
+}
+
+func (e *MetricPanicError) Unwrap() error { return ErrMetricPanic }
+
+// compute runs metric on pair, converting a panic into a MetricPanicError.
+func (c *Comparator) compute(metric video.Metric, pair framePair) (
+	scores map[string]float64, err error) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+
+		frame := pair.index
+		if c.frameIndices != nil {
+			frame = c.frameIndices[pair.index]
+		}
+		scores, err = nil, &MetricPanicError{Metric: metric.Name(),
+			Index: pair.index, Frame: frame, Value: value,
+			Stack: debug.Stack()}
+	}()
+
+	return metric.Compute(pair.a, pair.b)
+}
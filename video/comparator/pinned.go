@@ -0,0 +1,25 @@
+package comparator
+
+import "sync/atomic"
+
+// pinnedBytes and peakPinnedBytes track the frame buffers allocPlane holds in
+// pinned memory across every Comparator of the process.
+var pinnedBytes, peakPinnedBytes atomic.Int64
+
+// PinnedMemory returns the bytes of pinned host memory the frame buffers of
+// all comparators hold now, and the most they held at once since the process
+// started. Both are 0 without cgo, where frame buffers are not pinned.
+func PinnedMemory() (inUse, peak int64) {
+	return pinnedBytes.Load(), peakPinnedBytes.Load()
+}
+
+// addPinned records a change of the pinned memory held by frame buffers.
+func addPinned(delta int64) {
+	inUse := pinnedBytes.Add(delta)
+	for {
+		peak := peakPinnedBytes.Load()
+		if inUse <= peak || peakPinnedBytes.CompareAndSwap(peak, inUse) {
+			return
+		}
+	}
+}
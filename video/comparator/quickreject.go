@@ -0,0 +1,62 @@
+package comparator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SetQuickReject skips the metrics of frame pairs that are trivially
+// near-identical, as found by a cheap CPU PSNR of the frames. Pairs whose PSNR
+// reaches minPSNR dB are imputed the perfect scores of metrics implementing
+// video.IdentityScorer instead of running them, other metrics still run. Must
+// be called before Run(). Pass 0 to disable.
+//
+// This speeds up comparisons of high bitrate, near lossless encodes, where
+// most frames score close to perfect anyway, at the cost of small errors in
+// the imputed scores. Both sources must share the same ColorProperties.
+// QuickRejected reports which pairs were imputed.
+func (c *Comparator) SetQuickReject(minPSNR float64) error {
+	if minPSNR == 0 {
+		c.differ, c.minPSNR = nil, 0
+		return nil
+	}
+	if minPSNR < 0 {
+		return fmt.Errorf("quick reject PSNR must be positive, got %g",
+			minPSNR)
+	}
+
+	if *c.videoA.GetColorProps() != *c.videoB.GetColorProps() {
+		return errors.New("quick reject needs both sources to share their " +
+			"color properties")
+	}
+
+	differ, err := video.NewFrameDiffer(c.videoA.GetColorProps())
+	if err != nil {
+		return fmt.Errorf("quick reject: %w", err)
+	}
+
+	c.differ, c.minPSNR = differ, minPSNR
+	return nil
+}
+
+// QuickRejected returns, for every compared frame pair in the order of the
+// per-frame scores returned by Run, whether its scores were imputed by the
+// quick reject. It is nil unless the quick reject was enabled.
+func (c *Comparator) QuickRejected() []bool {
+	return c.rejected
+}
+
+// quickReject imputes the identity scores of pair into result if its frames
+// are near-identical. Returns the metrics that still have to be computed.
+func (c *Comparator) quickReject(pair framePair, metrics []video.Metric,
+	result map[string]float64) []video.Metric {
+	if len(metrics) == 0 || c.differ.PSNR(&pair.a, &pair.b) < c.minPSNR {
+		return metrics
+	}
+
+	remaining := identityScores(metrics, result)
+	c.rejected[pair.index] = len(remaining) < len(metrics)
+	return remaining
+}
@@ -0,0 +1,141 @@
+package comparator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/blockingpool"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Reset prepares the Comparator to compare a new pair of sources, reusing
+// its frame buffers and metrics. This skips the pinned memory and metric
+// setup of NewComparator when comparing many files in one process. Must not
+// be called while Run is in progress.
+//
+// The new sources must have the same plane sizes and line sizes as the
+// sources the Comparator was constructed with, and the color properties the
+// metrics were created for. numFrames is validated the same as by
+// NewComparator. Metrics implementing video.ResettableMetric are reset.
+//
+// Frame hashing, the identity short circuit, the quick reject, the frame
+// checks, the frame mask and the score cache stay enabled if they were. The
+// keyframe mode or frame indices are cleared and must be set again with
+// SetKeyFrameMode or SetFrameIndices. The progress, stats and scores
+// callbacks and the frame observer are kept, while the metric stats start
+// over. The scores, frame hashes, quick rejected and masked pairs returned
+// for the previous run are left untouched.
+//
+// Unless the Comparator was created WithSharedSources, previous sources that
+// were replaced are closed once the new ones are in place. An error closing
+// them leaves the Comparator ready to Run.
+func (c *Comparator) Reset(videoA, videoB video.Source, numFrames int) error {
+	if c.closed {
+		return ErrClosed
+	}
+
+	next := *c
+	next.videoA, next.videoB, next.numFrames = videoA, videoB, numFrames
+
+	if err := next.validateArguments(); err != nil {
+		return err
+	}
+
+	if err := checkSameBuffers("video a", c.videoA, videoA); err != nil {
+		return err
+	}
+	if err := checkSameBuffers("video b", c.videoB, videoB); err != nil {
+		return err
+	}
+
+	if c.hasherA != nil {
+		shortCircuit := next.shortCircuit
+		if err := next.SetFrameHashing(true); err != nil {
+			return err
+		}
+		next.shortCircuit = shortCircuit
+	}
+
+	if c.differ != nil {
+		if err := next.SetQuickReject(c.minPSNR); err != nil {
+			return err
+		}
+	}
+	if c.checkerA != nil {
+		if err := next.SetFrameChecks(true); err != nil {
+			return err
+		}
+	}
+	if c.mask != nil {
+		if err := next.SetFrameMask(c.mask); err != nil {
+			return err
+		}
+	}
+	if c.scoreCache != nil {
+		if err := next.SetScoreCache(c.scoreCache); err != nil {
+			return err
+		}
+	}
+
+	for _, metric := range c.metrics {
+		resettable, ok := metric.(video.ResettableMetric)
+		if !ok {
+			continue
+		}
+		if err := resettable.Reset(); err != nil {
+			return fmt.Errorf("%s reset failed: %w", metric.Name(), err)
+		}
+	}
+
+	next.sourceFrames, next.frameIndices = numFrames, nil
+	next.hashesA, next.hashesB, next.rejected = nil, nil, nil
+	next.masked = nil
+	next.finalScores = make(map[string][]float64)
+	next.counters = newMetricCounters(c.metrics)
+	next.ctx, next.ctxCancel = nil, nil
+	next.ran = false
+
+	next.makeChannels()
+	next.framePoolA = refillPool(c.framesA)
+	next.framePoolB = refillPool(c.framesB)
+
+	previousA, previousB := c.videoA, c.videoB
+	*c = next
+
+	if c.sharedSources {
+		return nil
+	}
+
+	var errs []error
+	if previousA != videoA {
+		errs = append(errs, previousA.Close())
+	}
+	if previousB != videoB {
+		errs = append(errs, previousB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// checkSameBuffers returns an error if the frame buffers allocated for
+// previous cannot hold the frames of source.
+func checkSameBuffers(name string, previous, source video.Source) error {
+	previousSizes, previousLineSizes := previous.GetPlaneSizes()
+	sizes, lineSizes := source.GetPlaneSizes()
+
+	if sizes != previousSizes || lineSizes != previousLineSizes {
+		return fmt.Errorf("%s: plane sizes %v and line sizes %v differ from "+
+			"the %v and %v the frame buffers were allocated for", name,
+			sizes, lineSizes, previousSizes, previousLineSizes)
+	}
+	return nil
+}
+
+// refillPool returns a new pool holding every frame in frames, as a Run that
+// failed may have left some of them outside of the old pool.
+func refillPool(frames []video.Frame) blockingpool.BlockingPool[video.Frame] {
+	pool := blockingpool.NewBlockingPool[video.Frame](len(frames))
+	for _, frame := range frames {
+		pool.Put(frame)
+	}
+	return pool
+}
@@ -0,0 +1,233 @@
+package comparator
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// scoreKey identifies the scores of one metric with one configuration on one
+// frame pair.
+type scoreKey [sha256.Size]byte
+
+// scoreCacheEntry is one line of a score cache file.
+type scoreCacheEntry struct {
+	Key    string             `json:"key"`
+	Scores map[string]float64 `json:"scores"`
+}
+
+// ScoreCache is a file of metric scores keyed by the hashes of the frame
+// pairs they were computed on and the settings of the metric, so re-running a
+// comparison, e.g. after changing only reporting options or adding a metric,
+// reuses the scores computed before. Only metrics implementing
+// video.CacheableMetric are cached.
+//
+// The file holds one JSON entry per line and is read whole when opened, new
+// scores are appended. A line left incomplete by a crash is skipped. It is
+// safe for concurrent use, but not by several processes at once.
+type ScoreCache struct {
+	mu     sync.Mutex
+	scores map[scoreKey]map[string]float64
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// OpenScoreCache opens the score cache at path, creating it if needed. Close
+// must be called to write the scores added.
+func OpenScoreCache(path string) (*ScoreCache, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("score cache: %w", err)
+	}
+
+	c := &ScoreCache{scores: make(map[scoreKey]map[string]float64),
+		file: file, writer: bufio.NewWriter(file)}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry scoreCacheEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		var key scoreKey
+		if n, err := hex.Decode(key[:], []byte(entry.Key)); err != nil ||
+			n != len(key) {
+			continue
+		}
+		c.scores[key] = entry.Scores
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("score cache: %w", err)
+	}
+
+	return c, nil
+}
+
+// Len returns the number of cached metric results.
+func (c *ScoreCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.scores)
+}
+
+// Close writes the scores added and closes the file. Calling Close more than
+// once does nothing.
+func (c *ScoreCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := errors.Join(c.writer.Flush(), c.file.Close())
+	c.file = nil
+	if err != nil {
+		return fmt.Errorf("score cache: %w", err)
+	}
+	return nil
+}
+
+func (c *ScoreCache) get(key scoreKey) (map[string]float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scores, ok := c.scores[key]
+	return scores, ok
+}
+
+// put adds scores under key. Scores that are not finite cannot be stored in
+// JSON and are left out of the cache.
+func (c *ScoreCache) put(key scoreKey, scores map[string]float64) error {
+	line, err := json.Marshal(scoreCacheEntry{
+		Key: hex.EncodeToString(key[:]), Scores: scores})
+	var unsupported *json.UnsupportedValueError
+	if errors.As(err, &unsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("score cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return errors.New("score cache: closed")
+	}
+	c.scores[key] = scores
+	if _, err := c.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("score cache: %w", err)
+	}
+	return nil
+}
+
+// SetScoreCache reuses the scores cache holds for frame pairs it has seen
+// with the same metric settings and adds the scores computed. Only metrics
+// implementing video.CacheableMetric with a non-empty key are cached. Frame
+// hashing is enabled, as pairs are identified by their hashes and the color
+// properties of both sources. Must be called before Run(). Pass nil to
+// disable.
+//
+// The cache stays open after the Comparator is closed, as several
+// comparisons may share it.
+func (c *Comparator) SetScoreCache(cache *ScoreCache) error {
+	c.scoreCache, c.cachePrefixes, c.cacheHits = nil, nil, nil
+	if cache == nil {
+		return nil
+	}
+
+	if c.hasherA == nil {
+		if err := c.SetFrameHashing(true); err != nil {
+			return err
+		}
+	}
+
+	props := fmt.Sprintf("%+v\x00%+v", *c.videoA.GetColorProps(),
+		*c.videoB.GetColorProps())
+	prefixes := make(map[string]string)
+	for _, metric := range c.metrics {
+		cacheable, ok := metric.(video.CacheableMetric)
+		if !ok || cacheable.CacheKey() == "" {
+			continue
+		}
+		prefixes[metric.Name()] = metric.Name() + "\x00" +
+			cacheable.CacheKey() + "\x00" + props
+	}
+
+	c.scoreCache, c.cachePrefixes = cache, prefixes
+	c.cacheHits = new(atomic.Int64)
+	return nil
+}
+
+// CachedScores returns the number of metric results of the last Run taken
+// from the score cache.
+func (c *Comparator) CachedScores() int {
+	if c.cacheHits == nil {
+		return 0
+	}
+	return int(c.cacheHits.Load())
+}
+
+// pairScoreKey returns the key of the scores of metric on pair, or false if
+// metric is not cached.
+func (c *Comparator) pairScoreKey(pair framePair, metric video.Metric) (
+	scoreKey, bool) {
+	prefix, ok := c.cachePrefixes[metric.Name()]
+	if !ok {
+		return scoreKey{}, false
+	}
+
+	digest := sha256.New()
+	digest.Write([]byte(prefix))
+	binary.Write(digest, binary.LittleEndian, c.hashesA[pair.index])
+	binary.Write(digest, binary.LittleEndian, c.hashesB[pair.index])
+
+	var key scoreKey
+	digest.Sum(key[:0])
+	return key, true
+}
+
+// cachedScores writes the cached scores of pair into result and returns the
+// metrics that still have to be computed.
+func (c *Comparator) cachedScores(pair framePair, metrics []video.Metric,
+	result map[string]float64) []video.Metric {
+	remaining := make([]video.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		key, ok := c.pairScoreKey(pair, metric)
+		if !ok {
+			remaining = append(remaining, metric)
+			continue
+		}
+		scores, ok := c.scoreCache.get(key)
+		if !ok {
+			remaining = append(remaining, metric)
+			continue
+		}
+
+		for name, value := range scores {
+			result[name] = value
+		}
+		c.cacheHits.Add(1)
+	}
+	return remaining
+}
+
+// cacheScores adds the scores metric computed for pair to the score cache.
+func (c *Comparator) cacheScores(pair framePair, metric video.Metric,
+	scores map[string]float64) error {
+	key, ok := c.pairScoreKey(pair, metric)
+	if !ok {
+		return nil
+	}
+	return c.scoreCache.put(key, scores)
+}
@@ -0,0 +1,108 @@
+package comparator
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/blockingpool"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// MetricStats is the throughput of one metric during a Run.
+type MetricStats struct {
+	Name string
+	// Frames is the number of frame pairs the metric computed. Pairs skipped
+	// as bit-identical are not counted.
+	Frames int
+	// Busy is the time spent in the metric's Compute, summed over concurrent
+	// calls. For the GPU metrics this is the GPU time, including the upload
+	// of the frames. The metric with the largest Busy dominates the runtime.
+	Busy time.Duration
+	// FPS is Frames divided by the wall time since Run started.
+	FPS float64
+	// GPU sums up the calls of metrics implementing video.GPUStatsReporter
+	// into their GPU library, nil for other metrics. Metrics reused across
+	// runs report the calls of every run.
+	GPU *video.GPUStats
+}
+
+// StatsCallback is called with the progress like ProgressCallback, along with
+// a snapshot of the stats of every metric in the order they were passed to
+// NewComparator.
+type StatsCallback func(done, total int, stats []MetricStats)
+
+// metricCounters accumulates the stats of one metric. It is updated from the
+// metric threads concurrently.
+type metricCounters struct {
+	frames atomic.Int64
+	busy   atomic.Int64
+}
+
+// newMetricCounters returns zeroed counters for every metric, keyed by name.
+func newMetricCounters(metrics []video.Metric) map[string]*metricCounters {
+	counters := make(map[string]*metricCounters, len(metrics))
+	for _, metric := range metrics {
+		counters[metric.Name()] = &metricCounters{}
+	}
+	return counters
+}
+
+// recordCompute adds one Compute call of metric that took elapsed.
+func (c *Comparator) recordCompute(metric video.Metric,
+	elapsed time.Duration) {
+	counters, ok := c.counters[metric.Name()]
+	if !ok {
+		return
+	}
+	counters.frames.Add(1)
+	counters.busy.Add(int64(elapsed))
+}
+
+// SetStatsCallback registers an optional callback receiving per-metric stats
+// with every progress update. Must be called before Run(). Pass nil to clear.
+func (c *Comparator) SetStatsCallback(cb StatsCallback) {
+	c.statsCallback = cb
+}
+
+// MetricStats returns the stats of every metric in the order they were passed
+// to NewComparator. It may be called during and after Run.
+func (c *Comparator) MetricStats() []MetricStats {
+	wall := time.Since(c.started).Seconds()
+
+	stats := make([]MetricStats, 0, len(c.metrics))
+	for _, metric := range c.metrics {
+		counters := c.counters[metric.Name()]
+		s := MetricStats{Name: metric.Name(),
+			Frames: int(counters.frames.Load()),
+			Busy:   time.Duration(counters.busy.Load())}
+		if wall > 0 {
+			s.FPS = float64(s.Frames) / wall
+		}
+		if reporter, ok := metric.(video.GPUStatsReporter); ok {
+			gpu := reporter.GPUStats()
+			s.GPU = &gpu
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// PoolStats is the usage of one of the comparator's frame pools during a Run.
+type PoolStats struct {
+	// Name is "reference" for the pool of video A and "distortion" for the
+	// pool of video B.
+	Name string
+	blockingpool.Stats
+}
+
+// PoolStats returns the stats of the frame pools of video A and B. Each
+// reader takes a free frame buffer from its pool before decoding a frame, and
+// buffers return to the pool once the metrics are done with them. Readers
+// that spend much of the run waiting in GetWait are held back by the metrics,
+// so the metrics and not decoding limit throughput. A HighWater below the
+// Capacity means the pools hold more buffers than the run needed. It may be
+// called during and after Run.
+func (c *Comparator) PoolStats() []PoolStats {
+	return []PoolStats{{"reference", c.framePoolA.Stats()},
+		{"distortion", c.framePoolB.Stats()}}
+}
@@ -0,0 +1,90 @@
+package comparator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+func Test_Comparator_Abort(t *testing.T) {
+	// Frame 5 is 40 off, every other frame is 1 off.
+	lumaA, lumaB := countUp(16), countUp(16)
+	for i := range lumaB {
+		lumaB[i]++
+	}
+	lumaB[5] += 39
+	a, b := memoryPair(t, lumaA, lumaB)
+
+	// A single frame thread completes the pairs in order, so the run stops
+	// with exactly the pairs up to frame 5.
+	comp, err := comparator.NewComparator(a, b, []video.Metric{lumaDiff{}},
+		1, len(lumaA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+	comp.SetAbortConditions(comparator.AbortCondition{Metric: "LumaDiff",
+		Kind: comparator.AbortFrameAbove, Threshold: 10})
+
+	scores, err := comp.Run(context.Background())
+	var abort *comparator.AbortError
+	if !errors.As(err, &abort) || !errors.Is(err, comparator.ErrAborted) {
+		t.Fatalf("got %v, want an AbortError", err)
+	}
+	if abort.Index != 5 || abort.Value != 40 {
+		t.Errorf("aborted at pair %d with %v, want pair 5 with 40",
+			abort.Index, abort.Value)
+	}
+
+	want := []float64{1, 1, 1, 1, 1, 40}
+	got := scores["LumaDiff"]
+	if len(got) != len(want) {
+		t.Fatalf("got %d scores, want the %d up to the abort", len(got),
+			len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("frame %d scored %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// Test_Comparator_AbortPrefix aborts on several frame threads, where later
+// pairs may complete first, and checks the scores are a prefix of the run.
+func Test_Comparator_AbortPrefix(t *testing.T) {
+	const numFrames = 64
+	lumaA, lumaB := countUp(numFrames), countUp(numFrames)
+	lumaB[20] += 50
+	a, b := memoryPair(t, lumaA, lumaB)
+
+	comp, err := comparator.NewComparator(a, b,
+		[]video.Metric{jittered{}}, 4, numFrames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+	comp.SetAbortConditions(comparator.AbortCondition{Metric: "LumaDiff",
+		Kind: comparator.AbortFrameAbove, Threshold: 10})
+
+	scores, err := comp.Run(context.Background())
+	if !errors.Is(err, comparator.ErrAborted) {
+		t.Fatalf("got %v, want %v", err, comparator.ErrAborted)
+	}
+
+	got := scores["LumaDiff"]
+	if len(got) == numFrames {
+		t.Fatal("every pair was scored after the abort")
+	}
+	for i, score := range got {
+		want := 0.0
+		if i == 20 {
+			want = 50
+		}
+		if score != want {
+			t.Errorf("frame %d scored %v, want %v", i, score, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package comparator_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// lumaDiff scores a frame pair by the mean absolute difference of their
+// luma.
+type lumaDiff struct{}
+
+func (lumaDiff) Name() string { return "LumaDiff" }
+func (lumaDiff) Close()       {}
+
+func (lumaDiff) Compute(a, b video.Frame) (map[string]float64, error) {
+	lumaA, lumaB := a.PlaneData(0), b.PlaneData(0)
+
+	var sum float64
+	for i := range lumaA {
+		sum += max(float64(lumaA[i])-float64(lumaB[i]),
+			float64(lumaB[i])-float64(lumaA[i]))
+	}
+	return map[string]float64{"LumaDiff": sum / float64(len(lumaA))}, nil
+}
+
+// grayFrames returns 4x2 8-bit 4:2:0 frames with the given luma values.
+func grayFrames(luma ...byte) [][3][]byte {
+	frames := make([][3][]byte, len(luma))
+	for i, y := range luma {
+		frames[i] = [3][]byte{bytes.Repeat([]byte{y}, 8), {128, 128},
+			{128, 128}}
+	}
+	return frames
+}
+
+// countUp returns n luma values counting up from 0, so a frame shows its own
+// frame number.
+func countUp(n int) []byte {
+	luma := make([]byte, n)
+	for i := range luma {
+		luma[i] = byte(i)
+	}
+	return luma
+}
+
+// memoryPair returns memory sources of the 4x2 gray frames of lumaA and
+// lumaB.
+func memoryPair(t *testing.T, lumaA, lumaB []byte) (video.Source,
+	video.Source) {
+	t.Helper()
+	props := video.ColorProperties{Width: 4, Height: 2,
+		PixelFormat: pixfmts.PixFmtYUV420P}
+
+	a, err := sources.NewMemorySource(grayFrames(lumaA...), props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := sources.NewMemorySource(grayFrames(lumaB...), props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func Test_Comparator_MemorySources(t *testing.T) {
+	props := video.ColorProperties{Width: 4, Height: 2,
+		PixelFormat: pixfmts.PixFmtYUV420P}
+
+	reference, err := sources.NewMemorySource(grayFrames(16, 100, 200, 235),
+		props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	distortion, err := sources.NewMemorySource(grayFrames(16, 90, 220, 235),
+		props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp, err := comparator.NewComparator(reference, distortion,
+		[]video.Metric{lumaDiff{}}, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	scores, err := comp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float64{0, 10, 20, 0}
+	got := scores["LumaDiff"]
+	if len(got) != len(want) {
+		t.Fatalf("got %d scores, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("frame %d scored %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// cachedLumaDiff is a lumaDiff whose scores may be cached.
+type cachedLumaDiff struct{ lumaDiff }
+
+func (cachedLumaDiff) CacheKey() string { return "v1" }
+
+func Test_Comparator_ScoreCache(t *testing.T) {
+	props := video.ColorProperties{Width: 4, Height: 2,
+		PixelFormat: pixfmts.PixFmtYUV420P}
+	path := filepath.Join(t.TempDir(), "scores.jsonl")
+
+	run := func() ([]float64, int) {
+		cache, err := comparator.OpenScoreCache(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cache.Close()
+
+		reference, err := sources.NewMemorySource(
+			grayFrames(16, 100, 200, 235), props, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+		distortion, err := sources.NewMemorySource(
+			grayFrames(16, 90, 220, 235), props, 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		comp, err := comparator.NewComparator(reference, distortion,
+			[]video.Metric{cachedLumaDiff{}}, 2, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer comp.Close()
+
+		if err = comp.SetScoreCache(cache); err != nil {
+			t.Fatal(err)
+		}
+		scores, err := comp.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scores["LumaDiff"], comp.CachedScores()
+	}
+
+	first, cached := run()
+	if cached != 0 {
+		t.Fatalf("first run took %d scores from an empty cache", cached)
+	}
+	second, cached := run()
+	if cached != len(first) {
+		t.Fatalf("second run took %d scores from the cache, want %d", cached,
+			len(first))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("frame %d scored %v from the cache, want %v", i,
+				second[i], first[i])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package comparator_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// limited is a lumaDiff that computes at most limit pairs at once and
+// records the most it was handed at once.
+type limited struct {
+	lumaDiff
+	limit          int
+	inFlight, peak atomic.Int32
+}
+
+func (m *limited) MaxConcurrency() int { return m.limit }
+
+func (m *limited) Compute(a, b video.Frame) (map[string]float64, error) {
+	n := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	for {
+		peak := m.peak.Load()
+		if n <= peak || m.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	return m.lumaDiff.Compute(a, b)
+}
+
+func Test_Comparator_ConcurrencyLimit(t *testing.T) {
+	const numFrames = 32
+	a, b := memoryPair(t, countUp(numFrames), countUp(numFrames))
+	metric := &limited{limit: 2}
+
+	comp, err := comparator.NewComparator(a, b, []video.Metric{metric}, 8,
+		numFrames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	scores, err := comp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scores["LumaDiff"]) != numFrames {
+		t.Errorf("got %d scores, want %d", len(scores["LumaDiff"]),
+			numFrames)
+	}
+	if peak := metric.peak.Load(); peak > 2 {
+		t.Errorf("metric computed %d pairs at once, its limit is 2", peak)
+	}
+}
@@ -0,0 +1,84 @@
+package comparator_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// frameOrder is a sequential metric recording the frame number, the luma of
+// countUp frames, of every pair it computes and how many it computed at once.
+type frameOrder struct {
+	mu       sync.Mutex
+	frames   []int
+	inFlight atomic.Int32
+	overlap  atomic.Bool
+}
+
+func (m *frameOrder) Name() string     { return "FrameOrder" }
+func (m *frameOrder) Close()           {}
+func (m *frameOrder) Sequential() bool { return true }
+
+func (m *frameOrder) Compute(a, b video.Frame) (map[string]float64, error) {
+	if m.inFlight.Add(1) > 1 {
+		m.overlap.Store(true)
+	}
+	defer m.inFlight.Add(-1)
+
+	frame := int(a.PlaneData(0)[0])
+	m.mu.Lock()
+	m.frames = append(m.frames, frame)
+	m.mu.Unlock()
+	return map[string]float64{"FrameOrder": float64(frame)}, nil
+}
+
+// jittered is a lumaDiff taking a frame dependent time, so the frame
+// threads finish their pairs out of order.
+type jittered struct{ lumaDiff }
+
+func (m jittered) Compute(a, b video.Frame) (map[string]float64, error) {
+	time.Sleep(time.Duration(a.PlaneData(0)[0]%5) * time.Millisecond)
+	return m.lumaDiff.Compute(a, b)
+}
+
+func Test_Comparator_SequentialMetric(t *testing.T) {
+	const numFrames = 48
+	a, b := memoryPair(t, countUp(numFrames), countUp(numFrames))
+	order := &frameOrder{}
+
+	comp, err := comparator.NewComparator(a, b,
+		[]video.Metric{jittered{}, order}, 4, numFrames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	scores, err := comp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(order.frames) != numFrames {
+		t.Fatalf("sequential metric saw %d frames, want %d",
+			len(order.frames), numFrames)
+	}
+	for i, frame := range order.frames {
+		if frame != i {
+			t.Fatalf("sequential metric saw frame %d as pair %d, want "+
+				"increasing frames", frame, i)
+		}
+	}
+	if order.overlap.Load() {
+		t.Error("sequential metric computed several pairs at once")
+	}
+	for i, score := range scores["FrameOrder"] {
+		if score != float64(i) {
+			t.Errorf("pair %d scored %v, want %d", i, score, i)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package comparator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// panicOn is a lumaDiff panicking on pairs whose first frame has luma bad.
+type panicOn struct {
+	lumaDiff
+	bad byte
+}
+
+func (m panicOn) Compute(a, b video.Frame) (map[string]float64, error) {
+	if a.PlaneData(0)[0] == m.bad {
+		panic("bad frame")
+	}
+	return m.lumaDiff.Compute(a, b)
+}
+
+func Test_Comparator_MetricPanic(t *testing.T) {
+	a, b := memoryPair(t, countUp(12), countUp(12))
+
+	comp, err := comparator.NewComparator(a, b,
+		[]video.Metric{panicOn{bad: 7}}, 2, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	// Frame 7 is the third compared pair.
+	if err := comp.SetFrameIndices([]int{1, 4, 7, 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = comp.Run(context.Background())
+	var panicErr *comparator.MetricPanicError
+	if !errors.As(err, &panicErr) ||
+		!errors.Is(err, comparator.ErrMetricPanic) {
+		t.Fatalf("got %v, want a MetricPanicError", err)
+	}
+	if panicErr.Metric != "LumaDiff" || panicErr.Index != 2 ||
+		panicErr.Frame != 7 {
+		t.Errorf("got %s at pair %d, frame %d, want LumaDiff at pair 2, "+
+			"frame 7", panicErr.Metric, panicErr.Index, panicErr.Frame)
+	}
+	if panicErr.Value != "bad frame" || len(panicErr.Stack) == 0 {
+		t.Errorf("got value %v and a %d byte stack, want the panic value "+
+			"and its stack", panicErr.Value, len(panicErr.Stack))
+	}
+}
@@ -0,0 +1,80 @@
+package comparator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+)
+
+// errBadFrame is returned by failOn for the frames it fails.
+var errBadFrame = errors.New("bad frame")
+
+// failOn is a lumaDiff failing pairs whose first frame has luma bad.
+type failOn struct {
+	lumaDiff
+	bad byte
+}
+
+func (m failOn) Compute(a, b video.Frame) (map[string]float64, error) {
+	if a.PlaneData(0)[0] == m.bad {
+		return nil, errBadFrame
+	}
+	return m.lumaDiff.Compute(a, b)
+}
+
+// Test_Comparator_ResetAfterFailure resets a Comparator whose Run failed or
+// was cancelled, and checks the next Run scores every frame of the new
+// sources.
+func Test_Comparator_ResetAfterFailure(t *testing.T) {
+	const numFrames = 16
+	a, b := memoryPair(t, countUp(numFrames), countUp(numFrames))
+
+	comp, err := comparator.NewComparator(a, b,
+		[]video.Metric{failOn{bad: 5}}, 4, numFrames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer comp.Close()
+
+	if _, err := comp.Run(context.Background()); !errors.Is(err,
+		errBadFrame) {
+		t.Fatalf("failing run: got %v, want %v", err, errBadFrame)
+	}
+
+	// A cancelled run, on sources that would score fine.
+	a, b = memoryPair(t, countUp(numFrames)[6:], countUp(numFrames)[6:])
+	if err := comp.Reset(a, b, numFrames-6); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := comp.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled run: got %v, want %v", err, context.Canceled)
+	}
+
+	distorted := countUp(numFrames)[6:]
+	for i := range distorted {
+		distorted[i] += 3
+	}
+	a, b = memoryPair(t, countUp(numFrames)[6:], distorted)
+	if err := comp.Reset(a, b, numFrames-6); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := comp.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := scores["LumaDiff"]
+	if len(got) != numFrames-6 {
+		t.Fatalf("got %d scores, want %d", len(got), numFrames-6)
+	}
+	for i, score := range got {
+		if score != 3 {
+			t.Errorf("frame %d scored %v, want 3", i, score)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Package dataset exports paired reference and distorted patches with their
+// scores, for building training data for learned metrics from a comparison.
+//
+// Patches are written as webdataset shards: plain tar files in which every
+// sample is a group of files sharing a key. A sample holds the reference crop
+// (key.ref.raw), the distorted crop (key.dist.raw) and a JSON description
+// (key.json) with the frame, crop position, pixel layout and the scores of the
+// frame. Crops keep the pixel format of the compared frames, planes stored one
+// after the other without padding, and samples wider than 8 bits in little
+// endian 16-bit words.
+package dataset
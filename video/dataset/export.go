@@ -0,0 +1,405 @@
+package dataset
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/internal/pixops"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Selection picks the frames and crops to export.
+type Selection int
+
+const (
+	// SelectRandom takes crops at random positions of random frames.
+	SelectRandom Selection = iota
+	// SelectWorst takes the frames scoring worst on Options.Metric and, in
+	// each, the crops that differ most between reference and distortion.
+	SelectWorst
+)
+
+// ParseSelection returns the Selection named "random" or "worst".
+func ParseSelection(name string) (Selection, error) {
+	switch name {
+	case "random":
+		return SelectRandom, nil
+	case "worst":
+		return SelectWorst, nil
+	default:
+		return 0, fmt.Errorf("unknown patch selection %q, expected random "+
+			"or worst", name)
+	}
+}
+
+// Options configures Export.
+type Options struct {
+	// Directory the shards are written to, created if missing.
+	Dir string
+	// Width and height of a crop in luma samples. Rounded down to the chroma
+	// subsampling and clamped to the frame. Defaults to 256.
+	Size int
+	// Number of frames to take crops from. Defaults to 100.
+	Frames int
+	// Number of crops per frame. Defaults to 4.
+	PatchesPerFrame int
+	// Samples per shard before a new one is started. Defaults to 1000.
+	ShardSamples int
+
+	Selection Selection
+	// Score key ranking frames for SelectWorst.
+	Metric string
+	// HigherIsWorse is set for metrics scoring distances, such as
+	// Butteraugli, where the highest scores are the worst.
+	HigherIsWorse bool
+	// Seed of the random selection, so exports can be reproduced.
+	Seed uint64
+}
+
+func (o *Options) setDefaults() {
+	if o.Size <= 0 {
+		o.Size = 256
+	}
+	if o.Frames <= 0 {
+		o.Frames = 100
+	}
+	if o.PatchesPerFrame <= 0 {
+		o.PatchesPerFrame = 4
+	}
+	if o.ShardSamples <= 0 {
+		o.ShardSamples = 1000
+	}
+}
+
+func (o *Options) validate(scores map[string][]float64) error {
+	switch {
+	case o.Dir == "":
+		return errors.New("no output directory was given")
+	case o.Selection == SelectWorst && o.Metric == "":
+		return errors.New("selecting the worst patches needs a metric")
+	}
+
+	if _, ok := scores[o.Metric]; o.Selection == SelectWorst && !ok {
+		return fmt.Errorf("no scores for metric %s", o.Metric)
+	}
+	return nil
+}
+
+// Export writes patches of the compared frames of reference and distortion
+// into shards in opts.Dir and returns the number of samples written.
+//
+// scores are the per-frame scores of a comparison, as returned by
+// comparator.Comparator.Run, and frames holds the source frame number of every
+// score. A nil frames means score i belongs to frame i. Both sources must
+// deliver frames of the same size and pixel format, the ones the metrics saw,
+// and are read again, so they are left at an arbitrary position.
+func Export(ctx context.Context, reference, distortion video.SeekableSource,
+	scores map[string][]float64, frames []int, opts Options) (int, error) {
+	opts.setDefaults()
+	if err := opts.validate(scores); err != nil {
+		return 0, err
+	}
+
+	layout, err := newPatchLayout(reference.GetColorProps(),
+		distortion.GetColorProps(), opts.Size)
+	if err != nil {
+		return 0, err
+	}
+
+	numScores := 0
+	for _, values := range scores {
+		numScores = max(numScores, len(values))
+	}
+	if frames == nil {
+		frames = make([]int, numScores)
+		for i := range frames {
+			frames[i] = i
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	picked := pickFrames(rng, scores, numScores, opts)
+
+	bufferA, err := video.NewFrameFor(reference)
+	if err != nil {
+		return 0, err
+	}
+	bufferB, err := video.NewFrameFor(distortion)
+	if err != nil {
+		return 0, err
+	}
+
+	writer, err := newShardWriter(opts.Dir, opts.ShardSamples)
+	if err != nil {
+		return 0, err
+	}
+	defer writer.Close()
+
+	for _, i := range picked {
+		if err := ctx.Err(); err != nil {
+			return writer.samples, err
+		}
+
+		frame := frames[i]
+		if err := readFrame(reference, frame, bufferA); err != nil {
+			return writer.samples, fmt.Errorf("reference frame %d: %w",
+				frame, err)
+		}
+		if err := readFrame(distortion, frame, bufferB); err != nil {
+			return writer.samples, fmt.Errorf("distortion frame %d: %w",
+				frame, err)
+		}
+
+		frameScores := make(map[string]float64, len(scores))
+		for key, values := range scores {
+			if i < len(values) {
+				frameScores[key] = values[i]
+			}
+		}
+
+		for _, crop := range layout.pickCrops(rng, &bufferA, &bufferB,
+			opts.PatchesPerFrame, opts.Selection) {
+			err := writer.write(layout.sample(frame, crop, frameScores),
+				layout.extract(&bufferA, crop), layout.extract(&bufferB, crop))
+			if err != nil {
+				return writer.samples, err
+			}
+		}
+	}
+
+	return writer.samples, writer.Close()
+}
+
+// pickFrames returns the positions in scores of the frames to export, in
+// increasing order so the sources are read forwards.
+func pickFrames(rng *rand.Rand, scores map[string][]float64, numScores int,
+	opts Options) []int {
+	count := min(opts.Frames, numScores)
+
+	var picked []int
+	if opts.Selection == SelectWorst {
+		values := scores[opts.Metric]
+		picked = make([]int, len(values))
+		for i := range picked {
+			picked[i] = i
+		}
+		slices.SortStableFunc(picked, func(a, b int) int {
+			c := cmp.Compare(values[a], values[b])
+			if opts.HigherIsWorse {
+				return -c
+			}
+			return c
+		})
+		// NaN scores are unusable for ranking and sort first.
+		picked = slices.DeleteFunc(picked, func(i int) bool {
+			return math.IsNaN(values[i])
+		})
+		picked = picked[:min(count, len(picked))]
+	} else {
+		picked = rng.Perm(numScores)[:count]
+	}
+
+	slices.Sort(picked)
+	return picked
+}
+
+func readFrame(source video.SeekableSource, n int, frame video.Frame) error {
+	if err := source.SeekFrame(n); err != nil {
+		return err
+	}
+	return source.GetFrame(frame)
+}
+
+// crop is the top left corner of a crop in luma samples.
+type crop struct {
+	x, y int
+}
+
+// patchLayout describes how crops are cut from the planes of a frame.
+type patchLayout struct {
+	props video.ColorProperties
+	name  string
+	depth int
+	// size is the crop width and height in luma samples.
+	size int
+	// alignX and alignY are the chroma subsampling factors crops are
+	// aligned to.
+	alignX, alignY int
+	numPlanes      int
+	// shiftX, shiftY and sampleBytes describe every plane.
+	shiftX, shiftY, sampleBytes [video.MaxPlanes]int
+}
+
+func newPatchLayout(a, b *video.ColorProperties, size int) (*patchLayout,
+	error) {
+	if a.Width != b.Width || a.Height != b.Height ||
+		a.PixelFormat != b.PixelFormat {
+		return nil, fmt.Errorf("sources must match in size and pixel "+
+			"format to export patches, got %dx%d %s and %dx%d %s", a.Width,
+			a.Height, pixfmts.GetPixFmtName(a.PixelFormat), b.Width, b.Height,
+			pixfmts.GetPixFmtName(b.PixelFormat))
+	}
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(a.PixelFormat)
+	if err != nil {
+		return nil, fmt.Errorf("pixel format %d: %w", a.PixelFormat, err)
+	}
+	depth, err := a.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &patchLayout{props: *a, name: pixFmtDesc.Name(), depth: depth,
+		alignX: 1 << pixFmtDesc.Log2ChromaW(),
+		alignY: 1 << pixFmtDesc.Log2ChromaH()}
+
+	if _, _, l.numPlanes, err = a.PlaneSizes(); err != nil {
+		return nil, err
+	}
+
+	for i := range pixFmtDesc.NbComponents() {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return nil, err
+		}
+		if comp.Plane < i && l.sampleBytes[comp.Plane] != 0 {
+			return nil, fmt.Errorf("patch export requires a planar pixel "+
+				"format, got %s", l.name)
+		}
+
+		l.shiftX[comp.Plane], l.shiftY[comp.Plane], err =
+			a.PlaneSubsampling(comp.Plane)
+		if err != nil {
+			return nil, err
+		}
+		l.sampleBytes[comp.Plane] = comp.Step
+	}
+
+	l.size = min(size, a.Width, a.Height)
+	l.size -= l.size % max(l.alignX, l.alignY)
+	if l.size <= 0 {
+		return nil, fmt.Errorf("frames of %dx%d are too small for patches",
+			a.Width, a.Height)
+	}
+
+	return l, nil
+}
+
+// pickCrops returns count crops of the frame pair, at random or where the
+// pair differs most.
+func (l *patchLayout) pickCrops(rng *rand.Rand, a, b *video.Frame, count int,
+	selection Selection) []crop {
+	maxX := (l.props.Width - l.size) / l.alignX
+	maxY := (l.props.Height - l.size) / l.alignY
+
+	if selection == SelectRandom {
+		crops := make([]crop, count)
+		for i := range crops {
+			crops[i] = crop{rng.IntN(maxX+1) * l.alignX,
+				rng.IntN(maxY+1) * l.alignY}
+		}
+		return crops
+	}
+
+	// Candidates tile the frame without overlap, the last row and column
+	// moved in to end at the frame edge.
+	var candidates []crop
+	for y := 0; y < l.props.Height; y += l.size {
+		for x := 0; x < l.props.Width; x += l.size {
+			candidates = append(candidates, crop{min(x, maxX*l.alignX),
+				min(y, maxY*l.alignY)})
+		}
+	}
+
+	differences := make(map[crop]float64, len(candidates))
+	for _, c := range candidates {
+		differences[c] = l.difference(a, b, c)
+	}
+	slices.SortStableFunc(candidates, func(p, q crop) int {
+		return cmp.Compare(differences[q], differences[p])
+	})
+
+	return candidates[:min(count, len(candidates))]
+}
+
+// difference returns the mean absolute luma difference of a crop, sampling
+// every fourth row.
+func (l *patchLayout) difference(a, b *video.Frame, c crop) float64 {
+	const step = 4
+
+	planeA, planeB := a.PlaneData(0), b.PlaneData(0)
+	strideA, strideB := a.PlaneLineSize(0), b.PlaneLineSize(0)
+	rowBytes := l.size * l.sampleBytes[0]
+	sad := pixops.SAD
+	if l.sampleBytes[0] == 2 {
+		sad = pixops.SADWide
+	}
+
+	var sum uint64
+	var count int
+	for y := c.y; y < c.y+l.size; y += step {
+		offsetA := y*strideA + c.x*l.sampleBytes[0]
+		offsetB := y*strideB + c.x*l.sampleBytes[0]
+		sum += sad(planeA[offsetA:offsetA+rowBytes],
+			planeB[offsetB:offsetB+rowBytes])
+		count += l.size
+	}
+
+	return float64(sum) / float64(max(count, 1))
+}
+
+// extract returns the samples of crop c of frame, plane after plane.
+func (l *patchLayout) extract(frame *video.Frame, c crop) []byte {
+	var out []byte
+	for plane := range l.numPlanes {
+		data, stride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+		bytesPerSample := l.sampleBytes[plane]
+		x, y := c.x>>l.shiftX[plane], c.y>>l.shiftY[plane]
+		width, height := l.size>>l.shiftX[plane], l.size>>l.shiftY[plane]
+
+		for row := y; row < y+height; row++ {
+			start := row*stride + x*bytesPerSample
+			out = append(out, data[start:start+width*bytesPerSample]...)
+		}
+	}
+	return out
+}
+
+// planeSize is the size of one plane of a crop.
+type planeSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// sampleInfo is the JSON description of a sample.
+type sampleInfo struct {
+	Frame int `json:"frame"`
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	// Width and height of the crop in luma samples.
+	Width       int         `json:"width"`
+	Height      int         `json:"height"`
+	PixelFormat string      `json:"pixel_format"`
+	BitDepth    int         `json:"bit_depth"`
+	Planes      []planeSize `json:"planes"`
+	// Scores of the whole frame the crop was taken from.
+	Scores map[string]float64 `json:"scores"`
+}
+
+func (l *patchLayout) sample(frame int, c crop,
+	scores map[string]float64) sampleInfo {
+	info := sampleInfo{Frame: frame, X: c.x, Y: c.y, Width: l.size,
+		Height: l.size, PixelFormat: l.name, BitDepth: l.depth,
+		Scores: scores}
+	for plane := range l.numPlanes {
+		info.Planes = append(info.Planes, planeSize{
+			l.size >> l.shiftX[plane], l.size >> l.shiftY[plane]})
+	}
+	return info
+}
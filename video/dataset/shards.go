@@ -0,0 +1,98 @@
+package dataset
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// shardWriter writes samples into numbered tar shards of a directory.
+type shardWriter struct {
+	dir          string
+	shardSamples int
+
+	file *os.File
+	tw   *tar.Writer
+	// shard is the number of the next shard to open and samples the total
+	// written so far.
+	shard, samples int
+}
+
+func newShardWriter(dir string, shardSamples int) (*shardWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &shardWriter{dir: dir, shardSamples: shardSamples}, nil
+}
+
+// write adds one sample, starting a new shard when the current one is full.
+func (w *shardWriter) write(info sampleInfo, reference,
+	distortion []byte) error {
+	if w.tw == nil || w.samples%w.shardSamples == 0 {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+
+	description, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%09d", w.samples)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{key + ".ref.raw", reference},
+		{key + ".dist.raw", distortion},
+		{key + ".json", description},
+	}
+
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0o644,
+			Size: int64(len(f.data)), Typeflag: tar.TypeReg}
+		if err := w.tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := w.tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	w.samples++
+	return nil
+}
+
+// next closes the current shard and opens the following one.
+func (w *shardWriter) next() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(w.dir, fmt.Sprintf("patches-%06d.tar", w.shard))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w.file, w.tw = file, tar.NewWriter(file)
+	w.shard++
+	return nil
+}
+
+// Close finishes the current shard. It may be called more than once.
+func (w *shardWriter) Close() error {
+	if w.tw == nil {
+		return nil
+	}
+
+	err := w.tw.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file, w.tw = nil, nil
+	return err
+}
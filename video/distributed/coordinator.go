@@ -0,0 +1,260 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Coordinator sends the chunks of a comparison to remote workers and merges
+// their scores.
+type Coordinator struct {
+	// Base URLs of the workers, e.g. "http://10.0.0.2:7878". A missing scheme
+	// defaults to http.
+	Workers []string
+	// The number of chunks sent to each worker at once. Should match the
+	// worker's slots. Defaults to 1.
+	SlotsPerWorker int
+	// How often a chunk is tried before the comparison fails. Defaults to 3.
+	MaxAttempts int
+	// The client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Called after every finished chunk with the number of frames scored so
+	// far. Calls are serialized.
+	Progress func(done, total int)
+	// Deterministic pins chunk i to worker i modulo len(Workers) and retries
+	// it only there, so repeated runs score every chunk on the same machine.
+	// The comparison fails if a worker cannot be reached.
+	Deterministic bool
+}
+
+func (c *Coordinator) setDefaults() {
+	if c.SlotsPerWorker < 1 {
+		c.SlotsPerWorker = 1
+	}
+	if c.MaxAttempts < 1 {
+		c.MaxAttempts = 3
+	}
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+}
+
+// task is a chunk waiting to be sent along with how often it already failed.
+type task struct {
+	chunk    video.FrameRange
+	attempts int
+}
+
+// outcome is what a worker slot reports back after trying a task.
+type outcome struct {
+	task task
+	// The index of the worker that tried the task.
+	worker int
+	scores map[string][]float64
+	err    error
+	// Set when the worker could not be reached, after which the slot stops
+	// taking tasks.
+	retired bool
+}
+
+// Run compares chunks, which must cover frames of [0, numFrames), on the
+// workers. Every chunk is sent as a copy of job with its Range replaced.
+// A failed chunk is retried, possibly on another worker, and a worker that
+// cannot be reached is dropped. Run fails once a chunk runs out of attempts or
+// no workers are left.
+//
+// Returns per-metric arrays of numFrames per-frame scores in frame order, the
+// same as Comparator.Run.
+func (c *Coordinator) Run(ctx context.Context, job Job,
+	chunks []video.FrameRange, numFrames int) (map[string][]float64, error) {
+	if len(c.Workers) == 0 {
+		return nil, ErrNoWorkers
+	}
+	c.setDefaults()
+
+	for _, chunk := range chunks {
+		if chunk.Start < 0 || chunk.Count <= 0 || chunk.End() > numFrames {
+			return nil, fmt.Errorf("chunk [%d, %d) is outside the %d "+
+				"compared frames", chunk.Start, chunk.End(), numFrames)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// Every worker reads from its own queue in deterministic mode and from a
+	// shared one otherwise. Queues are buffered for every chunk so requeueing
+	// a failed task never blocks.
+	queues := make([]chan task, len(c.Workers))
+	shared := make(chan task, len(chunks))
+	for i := range queues {
+		queues[i] = shared
+		if c.Deterministic {
+			queues[i] = make(chan task, len(chunks))
+		}
+	}
+	for i, chunk := range chunks {
+		queues[i%len(queues)] <- task{chunk: chunk}
+	}
+
+	outcomes := make(chan outcome)
+	live := make([]int, len(c.Workers))
+	totalLive := 0
+
+	for i, worker := range c.Workers {
+		worker = normalizeURL(worker)
+		for range c.SlotsPerWorker {
+			live[i]++
+			totalLive++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.serveSlot(ctx, i, worker, job, queues[i], outcomes)
+			}()
+		}
+	}
+
+	results := make(map[string][]float64)
+	remaining, done := len(chunks), 0
+
+	for remaining > 0 {
+		var o outcome
+		select {
+		case o = <-outcomes:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if o.retired {
+			live[o.worker]--
+			totalLive--
+		}
+
+		if o.err != nil {
+			o.task.attempts++
+			if o.task.attempts >= c.MaxAttempts {
+				return nil, fmt.Errorf("chunk [%d, %d): %w", o.task.chunk.Start,
+					o.task.chunk.End(), o.err)
+			}
+			if totalLive == 0 || (c.Deterministic && live[o.worker] == 0) {
+				return nil, fmt.Errorf("%w: %w", ErrNoWorkers, o.err)
+			}
+			queues[o.worker] <- o.task
+			continue
+		}
+
+		for name, values := range o.scores {
+			if results[name] == nil {
+				results[name] = make([]float64, numFrames)
+			}
+			copy(results[name][o.task.chunk.Start:], values)
+		}
+
+		remaining--
+		done += o.task.chunk.Count
+		if c.Progress != nil {
+			c.Progress(done, numFrames)
+		}
+	}
+
+	return results, nil
+}
+
+// serveSlot sends tasks to worker one at a time until ctx is cancelled or the
+// worker cannot be reached.
+func (c *Coordinator) serveSlot(ctx context.Context, index int, worker string,
+	job Job, tasks chan task, outcomes chan<- outcome) {
+	for {
+		var t task
+		select {
+		case t = <-tasks:
+		case <-ctx.Done():
+			return
+		}
+
+		job.Range = t.chunk
+		scores, err := c.send(ctx, worker, job)
+
+		var unreachable *unreachableError
+		o := outcome{task: t, worker: index, scores: scores, err: err,
+			retired: errors.As(err, &unreachable)}
+
+		select {
+		case outcomes <- o:
+		case <-ctx.Done():
+			return
+		}
+
+		if o.retired {
+			return
+		}
+	}
+}
+
+// unreachableError wraps transport errors, as opposed to errors reported by
+// a worker that is up.
+type unreachableError struct{ err error }
+
+func (e *unreachableError) Error() string { return e.err.Error() }
+func (e *unreachableError) Unwrap() error { return e.err }
+
+// send runs a single job on worker.
+func (c *Coordinator) send(ctx context.Context, worker string, job Job) (
+	map[string][]float64, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		worker+"/chunk", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, &unreachableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("worker %s: %s", worker,
+			strings.TrimSpace(string(message)))
+	}
+
+	var res result
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("%w from %s: %w", ErrBadResponse, worker, err)
+	}
+
+	for name, values := range res.Scores {
+		if len(values) != job.Range.Count {
+			return nil, fmt.Errorf("%w from %s: %d %s scores for %d frames",
+				ErrBadResponse, worker, len(values), name, job.Range.Count)
+		}
+	}
+
+	return res.Scores, nil
+}
+
+func normalizeURL(worker string) string {
+	worker = strings.TrimRight(worker, "/")
+	if !strings.Contains(worker, "://") {
+		worker = "http://" + worker
+	}
+	return worker
+}
@@ -0,0 +1,10 @@
+// Package distributed farms chunks of a comparison out to remote gometrics
+// workers over HTTP and merges the returned scores, so a long comparison can
+// be spread across several machines.
+//
+// A Worker is an http.Handler that scores one chunk per request with a
+// caller supplied Runner. A Coordinator splits the frames into chunks, sends
+// them to its workers and reassembles the per-frame scores in frame order.
+// Workers open the videos themselves, so the paths in a Job must resolve to
+// the same files on every worker, e.g. through a shared mount.
+package distributed
@@ -0,0 +1,53 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+var (
+	ErrNoWorkers   = errors.New("no workers available")
+	ErrInvalidJob  = errors.New("invalid job")
+	ErrBadResponse = errors.New("malformed worker response")
+)
+
+// Job is one chunk of a comparison as sent to a worker.
+type Job struct {
+	// Paths of the reference and distorted videos as seen by the worker.
+	Reference  string `json:"reference"`
+	Distortion string `json:"distortion"`
+	// The frames to compare.
+	Range video.FrameRange `json:"range"`
+	// Names of the metrics to compute.
+	Metrics []string `json:"metrics"`
+	// The frame rate used for temporal metrics. The coordinator resolves it
+	// once so every chunk uses the same value.
+	FrameRate float32 `json:"frame_rate"`
+	// Deterministic asks the worker to score frames strictly in order so the
+	// result does not depend on scheduling.
+	Deterministic bool `json:"deterministic"`
+}
+
+// validate reports whether a job can be run at all.
+func (j *Job) validate() error {
+	switch {
+	case j.Reference == "" || j.Distortion == "":
+		return errors.Join(ErrInvalidJob, errors.New("missing video path"))
+	case j.Range.Start < 0 || j.Range.Count <= 0:
+		return errors.Join(ErrInvalidJob, errors.New("empty frame range"))
+	case len(j.Metrics) == 0:
+		return errors.Join(ErrInvalidJob, errors.New("no metrics"))
+	}
+	return nil
+}
+
+// Runner scores the frames of a job. It returns per-metric arrays of
+// job.Range.Count per-frame scores, relative to the start of the range.
+type Runner func(ctx context.Context, job Job) (map[string][]float64, error)
+
+// result is the body of a successful chunk response.
+type result struct {
+	Scores map[string][]float64 `json:"scores"`
+}
@@ -0,0 +1,74 @@
+package distributed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Worker serves chunk requests from a Coordinator. It exposes two endpoints:
+//
+//	POST /chunk   runs a JSON encoded Job and replies with its scores
+//	GET  /health  replies 200 with the number of free slots
+//
+// Requests beyond the worker's slots wait for a free one. A request whose
+// connection is closed is cancelled.
+type Worker struct {
+	run   Runner
+	slots chan struct{}
+	mux   *http.ServeMux
+}
+
+// NewWorker returns a Worker that scores chunks with run, at most slots at a
+// time. slots defaults to 1.
+func NewWorker(run Runner, slots int) *Worker {
+	w := &Worker{run: run, slots: make(chan struct{}, max(slots, 1)),
+		mux: http.NewServeMux()}
+
+	w.mux.HandleFunc("POST /chunk", w.handleChunk)
+	w.mux.HandleFunc("GET /health", w.handleHealth)
+
+	return w
+}
+
+// ServeHTTP implements http.Handler.
+func (w *Worker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mux.ServeHTTP(rw, r)
+}
+
+func (w *Worker) handleChunk(rw http.ResponseWriter, r *http.Request) {
+	var job Job
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(rw, fmt.Sprintf("%v: %v", ErrInvalidJob, err),
+			http.StatusBadRequest)
+		return
+	}
+	if err := job.validate(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		defer func() { <-w.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	scores, err := w.run(r.Context(), job)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(result{Scores: scores})
+}
+
+func (w *Worker) handleHealth(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]int{
+		"slots": cap(w.slots),
+		"free":  cap(w.slots) - len(w.slots),
+	})
+}
@@ -0,0 +1,4 @@
+// Package encoder implements video.Encoder by piping a video.Source as Y4M into
+// an external encoder process, either FFmpeg or a standalone encoder such as
+// SvtAv1EncApp. The encoder binaries are found on PATH unless a path is given.
+package encoder
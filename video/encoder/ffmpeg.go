@@ -0,0 +1,74 @@
+package encoder
+
+import (
+	"context"
+	"strconv"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/color"
+)
+
+// FFmpeg encodes through an ffmpeg binary with any of its video encoders.
+type FFmpeg struct {
+	// Path to the ffmpeg binary. Defaults to "ffmpeg" on PATH.
+	Path string
+	// The ffmpeg encoder name, e.g. "libsvtav1", "libx265" or "libaom-av1".
+	Codec string
+	// The flag Quality is passed with. Defaults to "-crf".
+	QualityFlag string
+}
+
+// NewFFmpeg returns an FFmpeg encoder for codec using ffmpeg from PATH.
+func NewFFmpeg(codec string) *FFmpeg {
+	return &FFmpeg{Codec: codec}
+}
+
+func (e *FFmpeg) Name() string { return "ffmpeg " + e.Codec }
+
+// Encode encodes settings.Source with ffmpeg. The source's color properties
+// are passed on so the output is tagged like the input.
+func (e *FFmpeg) Encode(ctx context.Context,
+	settings video.EncoderSettings) error {
+	path, qualityFlag := e.Path, e.QualityFlag
+	if path == "" {
+		path = "ffmpeg"
+	}
+	if qualityFlag == "" {
+		qualityFlag = "-crf"
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-f", "yuv4mpegpipe", "-i", "-",
+		"-c:v", e.Codec, qualityFlag, strconv.Itoa(settings.Quality)}
+	args = append(args, ffmpegColorArgs(settings.Source.GetColorProps())...)
+	args = append(args, settings.Settings...)
+	args = append(args, "-y", settings.Output)
+
+	return runPiped(ctx, e.Name(), path, args, settings)
+}
+
+// ffmpegColorArgs tags the output with the specified color properties of cp.
+func ffmpegColorArgs(cp *video.ColorProperties) []string {
+	var args []string
+
+	if cp.ColorRange != pixfmts.ColorRangeUnspecified {
+		args = append(args, "-color_range", color.RangeName(cp.ColorRange))
+	}
+	if cp.ColorSpace != pixfmts.ColorSpaceUnspecified {
+		args = append(args, "-colorspace", color.MatrixName(cp.ColorSpace))
+	}
+	if cp.ColorTransfer != pixfmts.ColorTransferCharacteristicUnspecified {
+		args = append(args, "-color_trc", color.TransferName(cp.ColorTransfer))
+	}
+	if cp.ColorPrimaries != pixfmts.ColorPrimariesUnspecified {
+		args = append(args, "-color_primaries",
+			color.PrimariesName(cp.ColorPrimaries))
+	}
+	if cp.ChromaLocation != pixfmts.ChromaLocationUnspecified {
+		args = append(args, "-chroma_sample_location",
+			color.ChromaLocationName(cp.ChromaLocation))
+	}
+
+	return args
+}
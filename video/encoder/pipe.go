@@ -0,0 +1,122 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// maxStderrTail is how much of an encoder's stderr is kept for error messages.
+const maxStderrTail = 4096
+
+// tailBuffer keeps the last maxStderrTail bytes written to it.
+type tailBuffer struct {
+	bytes.Buffer
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n, _ := t.Buffer.Write(p)
+	if extra := t.Len() - maxStderrTail; extra > 0 {
+		t.Next(extra)
+	}
+	return n, nil
+}
+
+// runPiped starts the encoder described by name, path and args, streams the
+// frames selected by settings into its stdin as Y4M and waits for it to exit.
+func runPiped(ctx context.Context, name, path string, args []string,
+	settings video.EncoderSettings) error {
+	if settings.Source == nil {
+		return errors.New("encoder settings have no source")
+	}
+	if settings.Output == "" {
+		return errors.New("encoder settings have no output path")
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+
+	var stderr tailBuffer
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	writeErr := writeSource(ctx, stdin, settings)
+	closeErr := stdin.Close()
+	waitErr := cmd.Wait()
+
+	// A failing encoder usually surfaces as a broken pipe while writing, so
+	// its exit status and output are the more useful error.
+	switch {
+	case waitErr != nil:
+		return fmt.Errorf("%s failed: %w: %s", name, waitErr,
+			bytes.TrimSpace(stderr.Bytes()))
+	case writeErr != nil:
+		return writeErr
+	default:
+		return closeErr
+	}
+}
+
+// writeSource writes the frames of settings.Source selected by StartFrame and
+// NumFrames to w as Y4M.
+func writeSource(ctx context.Context, w io.Writer,
+	settings video.EncoderSettings) error {
+	source := settings.Source
+
+	start, count := settings.StartFrame, settings.NumFrames
+	if count <= 0 {
+		count = source.GetNumFrames() - start
+	}
+	if start < 0 || count <= 0 || start+count > source.GetNumFrames() {
+		return fmt.Errorf("frame range [%d, %d) is outside the source's %d "+
+			"frames", start, start+count, source.GetNumFrames())
+	}
+
+	if start > 0 {
+		seekable, ok := video.As[video.SeekableSource](source)
+		if !ok {
+			return errors.New("source does not support seeking, cannot " +
+				"start encoding past frame 0")
+		}
+		if err := seekable.SeekFrame(start); err != nil {
+			return err
+		}
+	}
+
+	y4m, err := newY4MWriter(w, *source.GetColorProps(),
+		source.GetFrameRate())
+	if err != nil {
+		return err
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		return err
+	}
+
+	for range count {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := source.GetFrame(frame); err != nil {
+			return err
+		}
+		if err := y4m.WriteFrame(&frame); err != nil {
+			return err
+		}
+	}
+
+	return y4m.Flush()
+}
@@ -1 +1,63 @@
 package encoder
+
+import (
+	"context"
+	"strconv"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// SvtAv1EncApp encodes with the standalone SVT-AV1 encoder app, which skips
+// ffmpeg and exposes every SVT-AV1 parameter. Output is IVF.
+type SvtAv1EncApp struct {
+	// Path to the SvtAv1EncApp binary. Defaults to "SvtAv1EncApp" on PATH.
+	Path string
+}
+
+func (e *SvtAv1EncApp) Name() string { return "SvtAv1EncApp" }
+
+// Encode encodes settings.Source with settings.Quality as --crf. The source's
+// color properties are passed on as their H.273 code points.
+func (e *SvtAv1EncApp) Encode(ctx context.Context,
+	settings video.EncoderSettings) error {
+	path := e.Path
+	if path == "" {
+		path = "SvtAv1EncApp"
+	}
+
+	args := []string{"-i", "stdin", "--crf", strconv.Itoa(settings.Quality),
+		"--progress", "0"}
+	args = append(args, svtColorArgs(settings.Source.GetColorProps())...)
+	args = append(args, settings.Settings...)
+	args = append(args, "-b", settings.Output)
+
+	return runPiped(ctx, e.Name(), path, args, settings)
+}
+
+// svtColorArgs tags the output with the specified color properties of cp.
+// libav's enums use the H.273 code points SVT-AV1 expects.
+func svtColorArgs(cp *video.ColorProperties) []string {
+	var args []string
+
+	switch cp.ColorRange {
+	case pixfmts.ColorRangeMPEG:
+		args = append(args, "--color-range", "0")
+	case pixfmts.ColorRangeJPEG:
+		args = append(args, "--color-range", "1")
+	}
+	if cp.ColorSpace != pixfmts.ColorSpaceUnspecified {
+		args = append(args, "--matrix-coefficients",
+			strconv.Itoa(int(cp.ColorSpace)))
+	}
+	if cp.ColorTransfer != pixfmts.ColorTransferCharacteristicUnspecified {
+		args = append(args, "--transfer-characteristics",
+			strconv.Itoa(int(cp.ColorTransfer)))
+	}
+	if cp.ColorPrimaries != pixfmts.ColorPrimariesUnspecified {
+		args = append(args, "--color-primaries",
+			strconv.Itoa(int(cp.ColorPrimaries)))
+	}
+
+	return args
+}
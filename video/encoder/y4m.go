@@ -0,0 +1,181 @@
+package encoder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// y4mWriter writes frames as a YUV4MPEG2 stream. Plane padding is stripped, so
+// every row is written tightly packed.
+type y4mWriter struct {
+	w *bufio.Writer
+
+	numPlanes  int
+	sampleSize int
+	// The width and height of each plane in samples.
+	planeWidths, planeHeights [3]int
+}
+
+func newY4MWriter(w io.Writer, props video.ColorProperties, fps float32) (
+	*y4mWriter, error) {
+	tag, err := y4mColorspace(props)
+	if err != nil {
+		return nil, err
+	}
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	y := &y4mWriter{w: bufio.NewWriterSize(w, 1<<20), numPlanes: 3,
+		sampleSize: 1}
+	if depth > 8 {
+		y.sampleSize = 2
+	}
+	if pixFmtDesc.NbComponents() == 1 {
+		y.numPlanes = 1
+	}
+
+	log2W, log2H := pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH()
+	for i := range y.numPlanes {
+		y.planeWidths[i], y.planeHeights[i] = props.Width, props.Height
+		if i > 0 {
+			y.planeWidths[i] = (props.Width + 1<<log2W - 1) >> log2W
+			y.planeHeights[i] = (props.Height + 1<<log2H - 1) >> log2H
+		}
+	}
+
+	num, den := frameRateRational(fps)
+	header := fmt.Sprintf("YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C%s", props.Width,
+		props.Height, num, den, tag)
+	if props.ColorRange == pixfmts.ColorRangeJPEG {
+		header += " XCOLORRANGE=FULL"
+	} else if props.ColorRange == pixfmts.ColorRangeMPEG {
+		header += " XCOLORRANGE=LIMITED"
+	}
+
+	if _, err := y.w.WriteString(header + "\n"); err != nil {
+		return nil, err
+	}
+
+	return y, nil
+}
+
+// y4mColorspace returns the value of the Y4M C header tag for props.
+func y4mColorspace(props video.ColorProperties) (string, error) {
+	layout, err := props.Layout()
+	if err != nil {
+		return "", err
+	}
+
+	depth, err := props.BitDepth()
+	if err != nil {
+		return "", err
+	}
+
+	if layout == video.LayoutGray {
+		switch depth {
+		case 8:
+			return "mono", nil
+		case 16:
+			return "mono16", nil
+		default:
+			return "", fmt.Errorf("y4m has no %d-bit gray format", depth)
+		}
+	}
+
+	if layout != video.LayoutYUV {
+		return "", fmt.Errorf("y4m cannot carry %s frames", layout)
+	}
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return "", err
+	}
+
+	var subsampling string
+	switch [2]int{pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH()} {
+	case [2]int{1, 1}:
+		subsampling = "420"
+	case [2]int{1, 0}:
+		subsampling = "422"
+	case [2]int{0, 0}:
+		subsampling = "444"
+	default:
+		return "", fmt.Errorf("y4m has no tag for the chroma subsampling of "+
+			"%s", pixFmtDesc.Name())
+	}
+
+	switch depth {
+	case 8:
+		if subsampling != "420" {
+			return subsampling, nil
+		}
+		// 8-bit 4:2:0 tags carry the chroma siting instead of the depth.
+		switch props.ChromaLocation {
+		case pixfmts.ChromaLocationCenter:
+			return "420jpeg", nil
+		case pixfmts.ChromaLocationTopLeft:
+			return "420paldv", nil
+		default:
+			return "420mpeg2", nil
+		}
+	case 9, 10, 12, 14, 16:
+		return fmt.Sprintf("%sp%d", subsampling, depth), nil
+	default:
+		return "", fmt.Errorf("y4m has no %d-bit formats", depth)
+	}
+}
+
+// frameRateRational converts fps to a rational, recognizing the NTSC
+// x/1.001 rates.
+func frameRateRational(fps float32) (num, den int) {
+	ntsc := float64(fps) * 1.001
+	if rounded := math.Round(ntsc); rounded > 0 &&
+		math.Abs(ntsc-rounded) < 0.001 && math.Abs(float64(fps)-rounded) > 0.001 {
+		return int(rounded) * 1000, 1001
+	}
+
+	return int(math.Round(float64(fps) * 1000)), 1000
+}
+
+// WriteFrame writes a single frame.
+func (y *y4mWriter) WriteFrame(frame *video.Frame) error {
+	if _, err := y.w.WriteString("FRAME\n"); err != nil {
+		return err
+	}
+
+	for plane := range y.numPlanes {
+		data, stride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+		rowSize := y.planeWidths[plane] * y.sampleSize
+
+		for row := range y.planeHeights[plane] {
+			offset := row * stride
+			if offset+rowSize > len(data) {
+				return fmt.Errorf("plane %d is too small for %d rows of %d "+
+					"bytes", plane, y.planeHeights[plane], rowSize)
+			}
+			if _, err := y.w.Write(data[offset : offset+rowSize]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (y *y4mWriter) Flush() error {
+	return y.w.Flush()
+}
@@ -0,0 +1,38 @@
+package video
+
+import (
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// FrameFormat is the size and pixel format of a decoded frame.
+type FrameFormat struct {
+	Width, Height int
+	PixelFormat   pixfmts.PixelFormat
+}
+
+func (f FrameFormat) String() string {
+	name := pixfmts.GetPixFmtName(f.PixelFormat)
+	if name == "" {
+		name = fmt.Sprintf("pixel format %d", f.PixelFormat)
+	}
+	return fmt.Sprintf("%dx%d %s", f.Width, f.Height, name)
+}
+
+// FormatChangeError is returned by sources whose frames change size or pixel
+// format mid-stream, as adaptive bitrate captures do. Sources describe
+// themselves by their first frame and frame buffers and metrics are set up
+// for it, so the frame cannot be compared.
+type FormatChangeError struct {
+	// Frame is the first frame of the new format.
+	Frame int
+	// Want is the format of the source, Got that of the frame.
+	Want, Got FrameFormat
+}
+
+func (e *FormatChangeError) Error() string {
+	return fmt.Sprintf("frame %d changes format from %v to %v, streams "+
+		"changing resolution mid-file must be split at the change",
+		e.Frame, e.Want, e.Got)
+}
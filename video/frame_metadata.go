@@ -0,0 +1,52 @@
+package video
+
+import "maps"
+
+// FrameMetadata holds per-frame properties reported by a source, keyed by
+// name. Sources fill it in GetFrame, and sources wrapping another pass the
+// metadata of the wrapped frame on. Keys a source cannot provide are absent,
+// values have the type documented with the key.
+//
+// The map belongs to the Frame and is reused with it, so consumers that keep
+// metadata past the frame's lifetime must copy it.
+type FrameMetadata map[string]any
+
+const (
+	// MetaPTS is the presentation timestamp in seconds, a float64.
+	MetaPTS = "pts"
+	// MetaPictType is the coding type of the frame, a string such as "I",
+	// "P" or "B".
+	MetaPictType = "pict_type"
+	// MetaKeyFrame is true for keyframes, a bool.
+	MetaKeyFrame = "keyframe"
+	// MetaQP is the average quantizer of the frame, a float64. ffms2 does not
+	// export quantizers, so its sources leave it out.
+	MetaQP = "qp"
+	// MetaRepeatFields is the number of extra fields the frame is displayed
+	// for, an int, see FieldRepeatSource.
+	MetaRepeatFields = "repeat_fields"
+	// MetaInterlaced is true for frames coded as interlaced, a bool.
+	MetaInterlaced = "interlaced"
+	// MetaTopFieldFirst is true if the top field of an interlaced frame is
+	// displayed first, a bool.
+	MetaTopFieldFirst = "top_field_first"
+	// MetaSeekFallback names the safer seek mode, a string such as "linear",
+	// that decoded the frame after the seek mode the source was opened with
+	// failed. Frames decoded without a fallback leave it out.
+	MetaSeekFallback = "seek_fallback"
+)
+
+// Metadata returns the metadata of the frame. Writes to the returned map are
+// seen by every copy of the Frame.
+func (f *Frame) Metadata() FrameMetadata {
+	return f.metadata
+}
+
+// CopyMetadataFrom replaces the metadata of the receiver with that of src.
+func (dst *Frame) CopyMetadataFrom(src *Frame) {
+	if dst.metadata == nil {
+		return
+	}
+	clear(dst.metadata)
+	maps.Copy(dst.metadata, src.metadata)
+}
@@ -0,0 +1,183 @@
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// FrameCheckError describes why a FrameChecker rejected a frame.
+type FrameCheckError struct {
+	// Plane is the plane the problem was found in, -1 for the frame as a
+	// whole.
+	Plane int
+	// Problem describes what is wrong.
+	Problem string
+}
+
+func (e *FrameCheckError) Error() string {
+	if e.Plane < 0 {
+		return "implausible frame: " + e.Problem
+	}
+	return fmt.Sprintf("implausible frame: plane %d %s", e.Plane, e.Problem)
+}
+
+// checkComponent is where FrameChecker finds the samples of one component.
+type checkComponent struct {
+	plane, step, offset, shift, depth int
+	width, height                     int
+}
+
+// FrameChecker verifies that decoded frames are plausible for their
+// ColorProperties before they are handed to metric backends, which read
+// planes by their geometry and may crash the process on a short plane, a
+// stray line size or garbage samples. It is safe for concurrent use.
+//
+// Check verifies that every plane holds its visible rows at a line size of
+// at least one row, that samples of formats stored in more bits than they
+// use have no stray high bits set, and that float formats hold no NaN or
+// infinite samples.
+type FrameChecker struct {
+	name      string
+	numPlanes int
+	// The bytes of one visible row and the rows of each plane.
+	rowBytes, rows [MaxPlanes]int
+	// components lists the components whose samples Check reads, those of
+	// float formats and of integer formats with unused high bits.
+	components []checkComponent
+	bigEndian  bool
+	float      bool
+}
+
+// NewFrameChecker returns a FrameChecker for frames described by props.
+func NewFrameChecker(props *ColorProperties) (*FrameChecker, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, fmt.Errorf("pixel format %d: %w", props.PixelFormat, err)
+	}
+
+	rowBytes, rows, numPlanes, err := props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+	widths, _, _, err := props.PlaneSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := pixfmts.PixFmtFlag(pixFmtDesc.Flags())
+	c := FrameChecker{name: pixFmtDesc.Name(), numPlanes: numPlanes,
+		rowBytes: rowBytes, rows: rows,
+		bigEndian: flags&pixfmts.PixFmtFlagBigEndian != 0,
+		float:     flags&pixfmts.PixFmtFlagFloat != 0}
+
+	for i := range pixFmtDesc.NbComponents() {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only samples alone in a 16-bit word can have stray high bits,
+		// those of packed formats share their word with other components.
+		stray := !c.float && comp.Step == 2 && comp.Depth+comp.Shift < 16
+		if !c.float && !stray {
+			continue
+		}
+		if c.float && comp.Depth != 16 && comp.Depth != 32 {
+			return nil, fmt.Errorf("pixel format %s has %d-bit float "+
+				"samples", c.name, comp.Depth)
+		}
+
+		c.components = append(c.components, checkComponent{
+			plane: comp.Plane, step: comp.Step, offset: comp.Offset,
+			shift: comp.Shift, depth: comp.Depth,
+			width: widths[comp.Plane], height: rows[comp.Plane],
+		})
+	}
+
+	return &c, nil
+}
+
+// Check returns a *FrameCheckError describing the first problem found in
+// frame, or nil if it is plausible.
+func (c *FrameChecker) Check(frame *Frame) error {
+	if frame.NumPlanes() < c.numPlanes {
+		return &FrameCheckError{Plane: -1, Problem: fmt.Sprintf("has %d "+
+			"planes, pixel format %s needs %d", frame.NumPlanes(), c.name,
+			c.numPlanes)}
+	}
+
+	for plane := range c.numPlanes {
+		stride, size := frame.PlaneLineSize(plane), len(frame.PlaneData(plane))
+		if stride < c.rowBytes[plane] {
+			return &FrameCheckError{Plane: plane, Problem: fmt.Sprintf(
+				"has line size %d, shorter than its rows of %d bytes",
+				stride, c.rowBytes[plane])}
+		}
+
+		need := stride*(c.rows[plane]-1) + c.rowBytes[plane]
+		if size < need {
+			return &FrameCheckError{Plane: plane, Problem: fmt.Sprintf(
+				"holds %d bytes, %d rows at line size %d need %d", size,
+				c.rows[plane], stride, need)}
+		}
+	}
+
+	for i := range c.components {
+		if err := c.checkSamples(&c.components[i], frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSamples checks every visible sample of one component.
+func (c *FrameChecker) checkSamples(comp *checkComponent,
+	frame *Frame) error {
+	data, stride := frame.PlaneData(comp.plane), frame.PlaneLineSize(comp.plane)
+	for y := range comp.height {
+		row := y*stride + comp.offset
+		for x := range comp.width {
+			problem := c.sampleProblem(data[row+x*comp.step:], comp)
+			if problem != "" {
+				return &FrameCheckError{Plane: comp.plane,
+					Problem: fmt.Sprintf("sample (%d, %d) %s", x, y, problem)}
+			}
+		}
+	}
+	return nil
+}
+
+// sampleProblem describes what is wrong with the sample at the start of
+// data, or returns "" if nothing is.
+func (c *FrameChecker) sampleProblem(data []byte,
+	comp *checkComponent) string {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if c.bigEndian {
+		order = binary.BigEndian
+	}
+
+	if !c.float {
+		value := order.Uint16(data)
+		if value>>(comp.depth+comp.shift) != 0 {
+			return fmt.Sprintf("is 0x%04x, which sets bits above a %d-bit "+
+				"sample", value, comp.depth)
+		}
+		return ""
+	}
+
+	if comp.depth == 16 {
+		// Half floats are NaN or infinite when their exponent is all ones.
+		if order.Uint16(data)&0x7c00 == 0x7c00 {
+			return "is NaN or infinite"
+		}
+		return ""
+	}
+	value := float64(math.Float32frombits(order.Uint32(data)))
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Sprintf("is %v", value)
+	}
+	return ""
+}
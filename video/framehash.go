@@ -0,0 +1,52 @@
+package video
+
+import "github.com/cespare/xxhash/v2"
+
+// FrameHash holds one xxhash64 digest per plane of a frame. Planes past the
+// frame's NumPlanes are zero.
+type FrameHash [MaxPlanes]uint64
+
+// FrameHasher hashes the visible samples of frames with a given set of
+// ColorProperties. Row padding is skipped, so two frames with the same picture
+// hash the same even if their line sizes differ. It is safe for concurrent
+// use.
+type FrameHasher struct {
+	// rowBytes and rows are the visible size of each plane.
+	rowBytes, rows [MaxPlanes]int
+	numPlanes      int
+}
+
+// NewFrameHasher returns a FrameHasher for frames described by props, which
+// must use a planar pixel format.
+func NewFrameHasher(props *ColorProperties) (*FrameHasher, error) {
+	rowBytes, rows, numPlanes, err := props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+	return &FrameHasher{rowBytes: rowBytes, rows: rows,
+		numPlanes: numPlanes}, nil
+}
+
+// Hash returns the per-plane digests of frame.
+func (h *FrameHasher) Hash(frame *Frame) FrameHash {
+	var hash FrameHash
+	digest := xxhash.New()
+
+	for plane := range min(h.numPlanes, frame.NumPlanes()) {
+		data, stride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+		digest.Reset()
+
+		for row := range h.rows[plane] {
+			start := row * stride
+			end := min(start+h.rowBytes[plane], len(data))
+			if start >= end {
+				break
+			}
+			_, _ = digest.Write(data[start:end])
+		}
+
+		hash[plane] = digest.Sum64()
+	}
+
+	return hash
+}
@@ -0,0 +1,40 @@
+package video
+
+import "time"
+
+// GPUStats sums up the calls a GPU metric made into its library. Every call
+// uploads a frame pair, runs the kernels and downloads the results back to
+// back, and the libraries do not time the steps apart, so only their total
+// is known. The bytes moved tell calls whose time follows the transfers from
+// those the kernels dominate, see Bandwidth.
+type GPUStats struct {
+	Calls int64
+	// Time is the time spent in the calls, summed over concurrent ones.
+	Time time.Duration
+	// UploadBytes counts the bytes of the frame planes passed to the GPU and
+	// DownloadBytes those of the distortion maps read back.
+	UploadBytes, DownloadBytes int64
+}
+
+// Add returns the sum of s and other.
+func (s GPUStats) Add(other GPUStats) GPUStats {
+	return GPUStats{Calls: s.Calls + other.Calls, Time: s.Time + other.Time,
+		UploadBytes:   s.UploadBytes + other.UploadBytes,
+		DownloadBytes: s.DownloadBytes + other.DownloadBytes}
+}
+
+// Bandwidth returns the bytes moved per second of call time, 0 without any.
+// A bandwidth close to that of the link to the GPU means the calls spent
+// most of their time on transfers.
+func (s GPUStats) Bandwidth() float64 {
+	if s.Time <= 0 {
+		return 0
+	}
+	return float64(s.UploadBytes+s.DownloadBytes) / s.Time.Seconds()
+}
+
+// GPUStatsReporter is implemented by GPU metrics that report the GPUStats of
+// the calls they made so far. It may be called while the metric computes.
+type GPUStatsReporter interface {
+	GPUStats() GPUStats
+}
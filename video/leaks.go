@@ -0,0 +1,53 @@
+package video
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/internal/accounting"
+)
+
+// LeakReport counts the native resources the process holds by kind, such as
+// the pinned frame buffers of comparators, metric backend handlers and
+// decoder handles, none of which the garbage collector sees.
+type LeakReport map[string]int64
+
+// DebugLeakReport returns the native resources the process holds now.
+// Services running many comparisons can take one between comparisons and
+// check with Since that each comparison released everything it took.
+func DebugLeakReport() LeakReport {
+	return LeakReport(accounting.Snapshot())
+}
+
+// Since returns the kinds whose count changed since baseline, with the
+// change. It is empty if r and baseline hold the same resources.
+func (r LeakReport) Since(baseline LeakReport) LeakReport {
+	changed := make(LeakReport)
+	for kind, count := range r {
+		if delta := count - baseline[kind]; delta != 0 {
+			changed[kind] = delta
+		}
+	}
+	for kind, count := range baseline {
+		if _, ok := r[kind]; !ok && count != 0 {
+			changed[kind] = -count
+		}
+	}
+	return changed
+}
+
+// String lists the kinds in order, one "kind: count" per line.
+func (r LeakReport) String() string {
+	kinds := make([]string, 0, len(r))
+	for kind := range r {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+
+	var b strings.Builder
+	for _, kind := range kinds {
+		fmt.Fprintf(&b, "%s: %d\n", kind, r[kind])
+	}
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+)
+
+// Rect is a rectangle of a frame in luma samples.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Scale maps r from a frame of fromWidth x fromHeight to one of toWidth x
+// toHeight, rounding outwards so the result covers at least the same area.
+func (r Rect) Scale(fromWidth, fromHeight, toWidth, toHeight int) Rect {
+	if fromWidth == toWidth && fromHeight == toHeight {
+		return r
+	}
+
+	x0 := r.X * toWidth / fromWidth
+	y0 := r.Y * toHeight / fromHeight
+	x1 := ((r.X+r.Width)*toWidth + fromWidth - 1) / fromWidth
+	y1 := ((r.Y+r.Height)*toHeight + fromHeight - 1) / fromHeight
+	return Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}
+}
+
+// maskPlane is how FrameMasker fills one plane.
+type maskPlane struct {
+	plane, step   int
+	log2W, log2H  int
+	width, height int
+	// pixel is one filled pixel of the plane, step bytes long.
+	pixel []byte
+}
+
+// FrameMasker blanks rectangles of frames with the same ColorProperties, so
+// that areas which must not be compared, such as burned-in subtitles, look
+// the same in both frames of a pair. Luma, or every RGB component, is set to
+// black, chroma to neutral and alpha to opaque. It is safe for concurrent
+// use.
+type FrameMasker struct {
+	planes        []maskPlane
+	width, height int
+}
+
+// NewFrameMasker returns a FrameMasker for frames described by props.
+func NewFrameMasker(props *ColorProperties) (*FrameMasker, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, fmt.Errorf("pixel format %d: %w", props.PixelFormat, err)
+	}
+
+	flags := pixfmts.PixFmtFlag(pixFmtDesc.Flags())
+	if flags&(pixfmts.PixFmtFlagBitstream|pixfmts.PixFmtFlagPAL|
+		pixfmts.PixFmtFlagFloat) != 0 {
+		return nil, fmt.Errorf("cannot mask %s frames", pixFmtDesc.Name())
+	}
+	rgb := flags&pixfmts.PixFmtFlagRGB != 0
+	bigEndian := flags&pixfmts.PixFmtFlagBigEndian != 0
+	limited := !rgb && props.ColorRange != pixfmts.ColorRangeJPEG
+
+	widths, heights, _, err := props.PlaneSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	m := FrameMasker{width: props.Width, height: props.Height}
+	planes := make(map[int]*maskPlane)
+
+	numComponents := pixFmtDesc.NbComponents()
+	for i := range numComponents {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return nil, err
+		}
+
+		p, ok := planes[comp.Plane]
+		if !ok {
+			p = &maskPlane{plane: comp.Plane, step: comp.Step,
+				width: widths[comp.Plane], height: heights[comp.Plane],
+				pixel: make([]byte, comp.Step)}
+			p.log2W, p.log2H, err = props.PlaneSubsampling(comp.Plane)
+			if err != nil {
+				return nil, err
+			}
+			planes[comp.Plane] = p
+		} else if comp.Step != p.step {
+			// Such as the macropixels of YUYV.
+			return nil, fmt.Errorf("cannot mask %s frames",
+				pixFmtDesc.Name())
+		}
+
+		var value uint64
+		hasAlpha := flags&pixfmts.PixFmtFlagAlpha != 0
+		switch {
+		case hasAlpha && i == numComponents-1:
+			value = 1<<comp.Depth - 1
+		case !rgb && (i == 1 || i == 2) && numComponents > 2:
+			value = 1 << (comp.Depth - 1)
+		case limited:
+			value = 16 << (comp.Depth - 8)
+		}
+
+		if comp.Depth+comp.Shift > 8 {
+			order := binary.ByteOrder(binary.LittleEndian)
+			if bigEndian {
+				order = binary.BigEndian
+			}
+			sample := p.pixel[comp.Offset : comp.Offset+2]
+			order.PutUint16(sample, order.Uint16(sample)|
+				uint16(value<<comp.Shift))
+		} else {
+			p.pixel[comp.Offset] |= byte(value << comp.Shift)
+		}
+	}
+
+	for plane := range MaxPlanes {
+		if p, ok := planes[plane]; ok {
+			m.planes = append(m.planes, *p)
+		}
+	}
+	return &m, nil
+}
+
+// Fill blanks the rects of frame, which are clipped to the frame. Chroma is
+// blanked over every sample that touches a rect.
+func (m *FrameMasker) Fill(frame *Frame, rects []Rect) {
+	for _, r := range rects {
+		x0, y0 := max(r.X, 0), max(r.Y, 0)
+		x1, y1 := min(r.X+r.Width, m.width), min(r.Y+r.Height, m.height)
+		if x0 >= x1 || y0 >= y1 {
+			continue
+		}
+
+		for _, p := range m.planes {
+			if p.plane >= frame.NumPlanes() {
+				continue
+			}
+
+			data, stride := frame.PlaneData(p.plane),
+				frame.PlaneLineSize(p.plane)
+			px0, py0 := x0>>p.log2W, y0>>p.log2H
+			px1 := min(-((-x1) >> p.log2W), p.width)
+			py1 := min(-((-y1) >> p.log2H), p.height)
+
+			for y := py0; y < py1; y++ {
+				row := data[y*stride+px0*p.step : y*stride+px1*p.step]
+				for x := 0; x < len(row); x += p.step {
+					copy(row[x:], p.pixel)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// autoRangeSamples caps how many values of a map are sorted to find its
+// percentile, so large maps are subsampled.
+const autoRangeSamples = 1 << 16
+
+// AutoRange configures the auto-ranging of a HeatmapWriter, see
+// HeatmapWriter.EnableAutoRange.
+type AutoRange struct {
+	// Percentile of the values of every map that is taken as its range, in
+	// (0, 100]. Defaults to 99, so a few outliers do not wash out the map.
+	Percentile float64
+	// Window is the number of maps whose ranges are pooled by their maximum,
+	// so the scale follows the content without flickering from frame to
+	// frame. 1 scales every map on its own. Defaults to 48.
+	Window int
+}
+
+func (o *AutoRange) setDefaults() {
+	if o.Percentile == 0 {
+		o.Percentile = 99
+	}
+	if o.Window == 0 {
+		o.Window = 48
+	}
+}
+
+func (o *AutoRange) validate() error {
+	switch {
+	case o.Percentile <= 0 || o.Percentile > 100:
+		return fmt.Errorf("auto-range percentile must be in (0, 100], got %g",
+			o.Percentile)
+	case o.Window < 1:
+		return fmt.Errorf("auto-range window must be at least 1, got %d",
+			o.Window)
+	}
+	return nil
+}
+
+// autoRanger tracks the percentiles of the most recent maps.
+type autoRanger struct {
+	opts AutoRange
+	// ranges is a ring of the percentiles of the last Window maps, next is
+	// where the percentile of the next map goes.
+	ranges  []float32
+	next    int
+	samples []float32
+}
+
+// clipValue records the percentile of input and returns the largest
+// percentile of the window, or fallback if every value in the window is 0.
+func (a *autoRanger) clipValue(input []float32, fallback float32) float32 {
+	if len(a.ranges) < a.opts.Window {
+		a.ranges = append(a.ranges, 0)
+	}
+	a.ranges[a.next] = a.percentile(input)
+	a.next = (a.next + 1) % a.opts.Window
+
+	clip := slices.Max(a.ranges)
+	if clip <= 0 {
+		return fallback
+	}
+	return clip
+}
+
+// percentile returns the configured percentile of input, subsampled to at
+// most autoRangeSamples values. NaN values are skipped.
+func (a *autoRanger) percentile(input []float32) float32 {
+	step := max((len(input)+autoRangeSamples-1)/autoRangeSamples, 1)
+
+	a.samples = a.samples[:0]
+	for i := 0; i < len(input); i += step {
+		if !math.IsNaN(float64(input[i])) {
+			a.samples = append(a.samples, input[i])
+		}
+	}
+	if len(a.samples) == 0 {
+		return 0
+	}
+
+	slices.Sort(a.samples)
+	rank := int(math.Ceil(a.opts.Percentile/100*float64(len(a.samples)))) - 1
+	return a.samples[min(max(rank, 0), len(a.samples)-1)]
+}
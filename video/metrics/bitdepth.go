@@ -0,0 +1,34 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"fmt"
+	"slices"
+)
+
+// checkVshipBitDepths reports whether vship can compare a depthA-bit source
+// against a depthB-bit one. Each side is converted to linear float from its
+// own Colorspace before scoring, so mixed depths are fine as long as vship
+// has a sampling format for both.
+func checkVshipBitDepths(depthA, depthB int) error {
+	for _, depth := range [2]int{depthA, depthB} {
+		if !slices.Contains(vshipBitDepths, depth) {
+			return fmt.Errorf("vship does not support %d-bit input", depth)
+		}
+	}
+
+	return nil
+}
+
+func (h *ButterHandler) CheckBitDepths(depthA, depthB int) error {
+	return checkVshipBitDepths(depthA, depthB)
+}
+
+func (h *CVVDPHandler) CheckBitDepths(depthA, depthB int) error {
+	return checkVshipBitDepths(depthA, depthB)
+}
+
+func (h *Ssimu2Handler) CheckBitDepths(depthA, depthB int) error {
+	return checkVshipBitDepths(depthA, depthB)
+}
@@ -0,0 +1,84 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"fmt"
+	"math"
+)
+
+// glyphWidth and glyphHeight are the size of a burnInFont glyph in font
+// pixels.
+const glyphWidth, glyphHeight = 3, 5
+
+// burnInFont holds a 3x5 bitmap of every character a burn-in label uses. Each
+// row is three bits, the most significant bit being the leftmost pixel.
+// Characters without a glyph are drawn as blanks.
+var burnInFont = map[rune][glyphHeight]uint8{
+	'0': {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1': {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2': {0b111, 0b001, 0b111, 0b100, 0b111},
+	'3': {0b111, 0b001, 0b111, 0b001, 0b111},
+	'4': {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5': {0b111, 0b100, 0b111, 0b001, 0b111},
+	'6': {0b111, 0b100, 0b111, 0b101, 0b111},
+	'7': {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8': {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9': {0b111, 0b101, 0b111, 0b001, 0b111},
+	'.': {0b000, 0b000, 0b000, 0b000, 0b010},
+	':': {0b000, 0b010, 0b000, 0b010, 0b000},
+	'-': {0b000, 0b000, 0b111, 0b000, 0b000},
+	'#': {0b101, 0b111, 0b101, 0b111, 0b101},
+	'N': {0b101, 0b111, 0b111, 0b111, 0b101},
+	'a': {0b000, 0b011, 0b101, 0b101, 0b011},
+}
+
+// burnIn draws the label of one heat map onto its normalized values.
+type burnIn struct {
+	frameRate     float32
+	width, height int
+}
+
+// label returns the text burnt into the index-th heat map.
+func (b burnIn) label(index int, score float64) string {
+	millis := int64(math.Round(float64(index) / float64(b.frameRate) * 1000))
+	return fmt.Sprintf("#%d %d:%02d:%02d.%03d %.4f", index, millis/3600000,
+		millis/60000%60, millis/1000%60, millis%1000, score)
+}
+
+// draw writes text at the top left corner of values, a width*height map in
+// [0, 1], as full scale glyphs on a box of zeros. Glyphs are scaled up by one
+// font pixel per 180 rows of the map, and text past the right edge is cut.
+func (b burnIn) draw(values []float32, text string) {
+	scale := max(b.height/180, 1)
+	advance := (glyphWidth + 1) * scale
+
+	boxWidth := min(len(text)*advance+scale, b.width)
+	boxHeight := min((glyphHeight+2)*scale, b.height)
+	for y := range boxHeight {
+		clear(values[y*b.width : y*b.width+boxWidth])
+	}
+
+	for i, char := range []rune(text) {
+		glyph := burnInFont[char]
+		left := scale + i*advance
+
+		for row := range glyphHeight {
+			for col := range glyphWidth {
+				if glyph[row]&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				b.fill(values, left+col*scale, (row+1)*scale, scale)
+			}
+		}
+	}
+}
+
+// fill sets the size*size square at x, y to 1, clipped to the map.
+func (b burnIn) fill(values []float32, x, y, size int) {
+	for row := y; row < min(y+size, b.height); row++ {
+		for col := x; col < min(x+size, b.width); col++ {
+			values[row*b.width+col] = 1
+		}
+	}
+}
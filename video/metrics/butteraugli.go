@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package metrics
 
 import (
@@ -37,32 +39,97 @@ type ButterHandler struct {
 	callback DistortionMapCallback
 
 	numWorkers int
+	// cacheKey describes the settings of the handler, see CacheKey.
+	cacheKey string
 }
 
 func (h *ButterHandler) Name() string { return ButteraugliName }
 
-// NewButterHandler constructs a ButterHandler with the requested number of
-// worker instances and configuration parameters.
-//
-// colorA and colorB define the colorspaces of the reference and test images.
-// qNorm specified the p-norm that will be stored in the qnrom score result.
+// CacheKey describes the settings that affect the scores, or returns "" once
+// a distortion map callback is set, as it must see every frame pair.
+func (h *ButterHandler) CacheKey() string {
+	if h.callback != nil {
+		return ""
+	}
+	return h.cacheKey
+}
+
+// ButteraugliOptions configures a ButterHandler.
+type ButteraugliOptions struct {
+	// The number of worker instances frames are scored on concurrently.
+	// Defaults to 1.
+	Workers int
+	// The colorspaces of the reference and the distorted frames.
+	Reference, Distortion *vship.Colorspace
+	// The p-norm reported as the Butteraugli_<QNorm>Norm score. Defaults to
+	// 5.
+	QNorm int
+	// The luminance of the display in nits. Defaults to 203.
+	DisplayIntensity float32
+}
+
+// DefaultButteraugliOptions returns the options used for the zero values of
+// ButteraugliOptions.
+func DefaultButteraugliOptions() ButteraugliOptions {
+	return ButteraugliOptions{Workers: 1, QNorm: 5, DisplayIntensity: 203}
+}
+
+func (o *ButteraugliOptions) setDefaults() {
+	defaults := DefaultButteraugliOptions()
+	if o.Workers == 0 {
+		o.Workers = defaults.Workers
+	}
+	if o.QNorm == 0 {
+		o.QNorm = defaults.QNorm
+	}
+	if o.DisplayIntensity == 0 {
+		o.DisplayIntensity = defaults.DisplayIntensity
+	}
+}
+
+// Validate returns an error describing the first invalid option. Zero values
+// are valid where a default applies.
+func (o *ButteraugliOptions) Validate() error {
+	switch {
+	case o.Reference == nil || o.Distortion == nil:
+		return errors.New("butteraugli needs the colorspaces of both videos")
+	case o.Workers < 0:
+		return fmt.Errorf("invalid number of butteraugli workers %d",
+			o.Workers)
+	case o.QNorm < 0:
+		return fmt.Errorf("invalid butteraugli qnorm %d", o.QNorm)
+	case o.DisplayIntensity < 0:
+		return fmt.Errorf("invalid butteraugli display intensity %g",
+			o.DisplayIntensity)
+	}
+	return nil
+}
+
+// NewButterHandler constructs a ButterHandler with one worker instance per
+// opts.Workers.
 //
-// If retrieveDistortionMap is true, a per-pixel distortion map will be
-// computed and stored internally. Only a single worker is allowed when
-// retrieveDistortionMap is enabled.
-func NewButterHandler(numWorkers int, colorA, colorB *vship.Colorspace,
-	qNorm int, displayIntensity float32) (MetricWithDistortionMap, error) {
+// Distortion maps can only be retrieved from a single worker.
+func NewButterHandler(opts ButteraugliOptions) (MetricWithDistortionMap,
+	error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
 	var handler ButterHandler
 	var err error
 
 	handler.pool = blockingpool.NewBlockingPool[*vship.ButteraugliHandler](
-		numWorkers)
-	handler.dstWidth = int(colorA.TargetWidth)
-	handler.dstHeight = int(colorA.TargetHeight)
-	handler.numWorkers = numWorkers
+		opts.Workers)
+	handler.dstWidth = int(opts.Reference.TargetWidth)
+	handler.dstHeight = int(opts.Reference.TargetHeight)
+	handler.numWorkers = opts.Workers
+	handler.cacheKey = vshipCacheKey(fmt.Sprintf("qnorm %d intensity %g",
+		opts.QNorm, opts.DisplayIntensity), opts.Reference, opts.Distortion)
 
-	for range numWorkers {
-		err = handler.createWorker(colorA, colorB, qNorm, displayIntensity)
+	for range opts.Workers {
+		err = handler.createWorker(opts.Reference, opts.Distortion,
+			opts.QNorm, opts.DisplayIntensity)
 		if err == nil {
 			continue
 		}
@@ -135,15 +202,16 @@ func (h *ButterHandler) Compute(a, b video.Frame) (map[string]float64,
 	dstptr, dstStride := h.getDistortionBufferAndSize()
 
 	var score vship.ButteraugliScore
-	exception := handler.ComputeScore(&score, dstptr, dstStride, a.Data(),
-		b.Data(), a.LineSizes(), b.LineSizes())
+	exception := handler.ComputeScore(&score, dstptr, dstStride,
+		a.ColorPlanes(), b.ColorPlanes(), a.ColorLineSizes(),
+		b.ColorLineSizes())
 	if !exception.IsNone() {
 		return nil, fmt.Errorf("%s failed to compute score with error: %w",
 			ButteraugliName, exception.GetError())
 	}
 
 	if h.callback != nil {
-		err := h.callback(h.distortionBuffer)
+		err := h.callback(h.distortionBuffer, score.NormQ)
 		if err != nil {
 			return nil, err
 		}
@@ -170,6 +238,15 @@ func (h *ButterHandler) GetDistMapResolution() (int, int, error) {
 	return h.dstWidth, h.dstHeight, nil
 }
 
+// GPUStats sums the vship calls of every worker.
+func (h *ButterHandler) GPUStats() video.GPUStats {
+	var stats video.GPUStats
+	for _, handler := range h.handlerList {
+		stats = stats.Add(gpuStats(handler.Stats()))
+	}
+	return stats
+}
+
 // Close releases all underlying Butteraugli handlers.
 //
 // After calling Close, the ButterHandler should be considered unusable. This
@@ -0,0 +1,18 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"fmt"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+)
+
+// vshipCacheKey returns the video.CacheableMetric key of a vship metric with
+// the given settings scoring frames of the reference and distortion
+// colorspaces.
+func vshipCacheKey(settings string, reference,
+	distortion *vship.Colorspace) string {
+	return fmt.Sprintf("vship %+v %s reference %+v distortion %+v",
+		vship.GetVersion(), settings, *reference, *distortion)
+}
@@ -0,0 +1,165 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// The score formats of the built-in metrics, and of normalized scores on
+// their 0 to 100 scale.
+var (
+	butteraugliFormat = video.ScoreFormat{Decimals: 4}
+	ssimu2Format      = video.ScoreFormat{Decimals: 3}
+	cvvdpFormat       = video.ScoreFormat{Unit: "JOD", Decimals: 4,
+		Scale: video.ScaleJOD}
+	normalizedFormat = video.ScoreFormat{Decimals: 2}
+)
+
+// vshipBitDepths are the bit depths vship has a sampling format for.
+var vshipBitDepths = []int{8, 9, 10, 12, 14, 16}
+
+// ButteraugliCapabilities describes Butteraugli, scored as a distance from 0
+// for identical frames.
+func ButteraugliCapabilities() video.Capabilities {
+	return video.Capabilities{
+		DistortionMap:  true,
+		BitDepths:      slices.Clone(vshipBitDepths),
+		MixedBitDepths: true,
+		GPU:            true,
+		MinScore:       0,
+		MaxScore:       math.Inf(1),
+		Direction:      video.LowerIsBetter,
+		Format:         butteraugliFormat,
+	}
+}
+
+// SSIMU2Capabilities describes SSIMULACRA2, scored up to 100 for identical
+// frames and below 0 for heavy distortion.
+func SSIMU2Capabilities() video.Capabilities {
+	return video.Capabilities{
+		BitDepths:      slices.Clone(vshipBitDepths),
+		MixedBitDepths: true,
+		GPU:            true,
+		MinScore:       math.Inf(-1),
+		MaxScore:       100,
+		Direction:      video.HigherIsBetter,
+		Format:         ssimu2Format,
+	}
+}
+
+// CVVDPCapabilities describes CVVDP, scored in JOD up to 10 for identical
+// frames.
+func CVVDPCapabilities() video.Capabilities {
+	return video.Capabilities{
+		DistortionMap:  true,
+		BitDepths:      slices.Clone(vshipBitDepths),
+		MixedBitDepths: true,
+		GPU:            true,
+		MinScore:       math.Inf(-1),
+		MaxScore:       10,
+		Direction:      video.HigherIsBetter,
+		Format:         cvvdpFormat,
+	}
+}
+
+// BuiltinCapabilities returns the capabilities of the built-in metric with
+// the given name, and false for any other name.
+func BuiltinCapabilities(name string) (video.Capabilities, bool) {
+	switch name {
+	case ButteraugliName:
+		return ButteraugliCapabilities(), true
+	case SSIMulacra2Name:
+		return SSIMU2Capabilities(), true
+	case CVVDPName:
+		return CVVDPCapabilities(), true
+	case BlockinessName:
+		return BlockinessCapabilities(), true
+	case BandingName:
+		return BandingCapabilities(), true
+	default:
+		return video.Capabilities{}, false
+	}
+}
+
+// ScoreDirection returns the direction of the scores under key of a built-in
+// metric, including the keys of sweeps, multi-resolution metrics and
+// normalized scores, and video.DirectionUnknown for any other key.
+func ScoreDirection(key string) video.ScoreDirection {
+	if strings.HasSuffix(key, NormSuffix) {
+		return video.HigherIsBetter
+	}
+
+	key, _, _ = strings.Cut(key, "@")
+	switch {
+	case strings.HasPrefix(key, ButteraugliName):
+		return ButteraugliCapabilities().Direction
+	case key == SSIMulacra2Name:
+		return SSIMU2Capabilities().Direction
+	case key == CVVDPName:
+		return CVVDPCapabilities().Direction
+	case key == BlockinessName, key == BandingName:
+		return video.LowerIsBetter
+	default:
+		return video.DirectionUnknown
+	}
+}
+
+// ScoreFormat returns the format of the scores under key of a built-in
+// metric, including the keys of sweeps, multi-resolution metrics and
+// normalized scores, and the zero video.ScoreFormat for any other key.
+func ScoreFormat(key string) video.ScoreFormat {
+	if strings.HasSuffix(key, NormSuffix) {
+		return normalizedFormat
+	}
+
+	key, _, _ = strings.Cut(key, "@")
+	switch {
+	case strings.HasPrefix(key, ButteraugliName):
+		return butteraugliFormat
+	case key == SSIMulacra2Name:
+		return ssimu2Format
+	case key == CVVDPName:
+		return cvvdpFormat
+	case key == BlockinessName:
+		return blockinessFormat
+	case key == BandingName:
+		return bandingFormat
+	default:
+		return video.ScoreFormat{}
+	}
+}
+
+// Capabilities returns ButteraugliCapabilities, sequential while a
+// distortion map is being written.
+func (h *ButterHandler) Capabilities() video.Capabilities {
+	capabilities := ButteraugliCapabilities()
+	capabilities.Sequential = h.Sequential()
+	return capabilities
+}
+
+// Capabilities returns SSIMU2Capabilities.
+func (h *Ssimu2Handler) Capabilities() video.Capabilities {
+	return SSIMU2Capabilities()
+}
+
+// Capabilities returns CVVDPCapabilities, sequential with temporal weighting
+// or while a distortion map is being written.
+func (h *CVVDPHandler) Capabilities() video.Capabilities {
+	capabilities := CVVDPCapabilities()
+	capabilities.Sequential = h.Sequential()
+	return capabilities
+}
+
+// Capabilities returns CVVDPCapabilities without distortion maps, which a
+// sweep does not write.
+func (s *CVVDPSweep) Capabilities() video.Capabilities {
+	capabilities := CVVDPCapabilities()
+	capabilities.DistortionMap = false
+	capabilities.Sequential = s.Sequential()
+	return capabilities
+}
@@ -0,0 +1,12 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+// MaxConcurrency returns the number of SSIMULACRA2 workers.
+func (h *Ssimu2Handler) MaxConcurrency() int { return len(h.handlerList) }
+
+// MaxConcurrency returns the number of Butteraugli workers.
+func (h *ButterHandler) MaxConcurrency() int { return h.numWorkers }
+
+// MaxConcurrency returns the number of CVVDP workers.
+func (h *CVVDPHandler) MaxConcurrency() int { return h.numWorkers }
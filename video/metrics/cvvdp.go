@@ -1,3 +1,5 @@
+//go:build cgo && !nocgo
+
 package metrics
 
 import (
@@ -38,47 +40,97 @@ type CVVDPHandler struct {
 	callback DistortionMapCallback
 
 	numWorkers int
+	// cacheKey describes the settings of the handler, see CacheKey.
+	cacheKey string
 }
 
 // Name returns the metric identifier used as the score key.
 func (h *CVVDPHandler) Name() string { return CVVDPName }
 
-// NewCVVDPHandler constructs a CVVDPHandler with the requested number of
-// worker instances and configuration parameters.
-//
-// colorA and colorB define the colorspaces of the reference and test images.
-//
-// useTemporal defines if temporal weighting will be used for score
-// calculations.
-//
-// resizeToDisplay defines if the content will be resized to the displays
-// resolution defined in displayModel
-//
-// displayModel defines the properties of the final display the
-// distorted content will be displayed on.
-//
-// fps is the fps of the source content being compared. This effects vram
-// usage heavily.
+// CacheKey describes the settings that affect the scores, or returns "" with
+// temporal weighting, which scores every frame pair by its predecessors, and
+// once a distortion map callback is set, as it must see every frame pair.
+func (h *CVVDPHandler) CacheKey() string {
+	if h.useTemporal || h.callback != nil {
+		return ""
+	}
+	return h.cacheKey
+}
+
+// CVVDPOptions configures a CVVDPHandler.
+type CVVDPOptions struct {
+	// The number of worker instances frames are scored on concurrently.
+	// Defaults to 1.
+	Workers int
+	// The colorspaces of the reference and the distorted frames.
+	Reference, Distortion *vship.Colorspace
+	// Weight scores over time. Temporal weighting keeps the history of
+	// previous frames in the worker, so only a single worker is allowed and
+	// the handler reports itself as a video.SequentialMetric.
+	UseTemporal bool
+	// Resize the frames to the resolution of Display before scoring.
+	ResizeToDisplay bool
+	// The display and viewing conditions the distorted video is watched
+	// under. Defaults to vship.DisplayModelPresetStandard4K.
+	Display vship.DisplayModel
+	// The frame rate of the videos, which heavily affects VRAM usage. It
+	// has no default.
+	FrameRate float32
+}
+
+func (o *CVVDPOptions) setDefaults() {
+	if o.Workers == 0 {
+		o.Workers = 1
+	}
+	if o.Display == (vship.DisplayModel{}) {
+		o.Display = vship.DisplayModelPresetStandard4K
+	}
+}
+
+// Validate returns an error describing the first invalid option. Zero values
+// are valid where a default applies.
+func (o *CVVDPOptions) Validate() error {
+	switch {
+	case o.Reference == nil || o.Distortion == nil:
+		return errors.New("cvvdp needs the colorspaces of both videos")
+	case o.Workers < 0:
+		return fmt.Errorf("invalid number of cvvdp workers %d", o.Workers)
+	case o.UseTemporal && o.Workers > 1:
+		return errors.New("cannot request more than 1 worker when " +
+			"using temporal weighting")
+	case o.FrameRate <= 0:
+		return fmt.Errorf("invalid cvvdp frame rate %g", o.FrameRate)
+	}
+	return nil
+}
+
+// NewCVVDPHandler constructs a CVVDPHandler with one worker instance per
+// opts.Workers.
 //
-// If retrieveDistortionMap is true, a per-pixel distortion map will be
-// computed and stored internally. Only a single worker is allowed when
-// retrieveDistortionMap is enabled.
-func NewCVVDPHandler(numWorkers int, a, colorB *vship.Colorspace,
-	useTemporal, resizeToDisplay bool, distM vship.DisplayModel, fps float32) (
-	MetricWithDistortionMap, error) {
+// Distortion maps can only be retrieved from a single worker.
+func NewCVVDPHandler(opts CVVDPOptions) (MetricWithDistortionMap, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
 
 	var h CVVDPHandler
 
-	h.pool = blockingpool.NewBlockingPool[*vship.CVVDPHandler](numWorkers)
-	h.useTemporal, h.resizeToDisplay = useTemporal, resizeToDisplay
+	h.pool = blockingpool.NewBlockingPool[*vship.CVVDPHandler](opts.Workers)
+	h.useTemporal, h.resizeToDisplay = opts.UseTemporal, opts.ResizeToDisplay
 
 	if !h.resizeToDisplay {
-		h.dstWidth, h.dstHeight = int(a.TargetWidth), int(a.TargetHeight)
+		h.dstWidth = int(opts.Reference.TargetWidth)
+		h.dstHeight = int(opts.Reference.TargetHeight)
 	} else {
-		h.dstWidth, h.dstHeight = distM.DisplayWidth, distM.DisplayHeight
+		h.dstWidth = opts.Display.DisplayWidth
+		h.dstHeight = opts.Display.DisplayHeight
 	}
 
-	h.numWorkers = numWorkers
+	h.numWorkers = opts.Workers
+	h.cacheKey = vshipCacheKey(fmt.Sprintf("resize %t display %+v fps %g",
+		opts.ResizeToDisplay, opts.Display, opts.FrameRate), opts.Reference,
+		opts.Distortion)
 
 	tmp, e := os.CreateTemp("", "")
 	if e != nil {
@@ -86,9 +138,10 @@ func NewCVVDPHandler(numWorkers int, a, colorB *vship.Colorspace,
 	}
 	defer tmp.Close()
 
-	distM.Name = "Custom"
+	display := opts.Display
+	display.Name = "Custom"
 
-	e = vship.DisplayModelsToCVVDPJSONFile([]vship.DisplayModel{distM},
+	e = vship.DisplayModelsToCVVDPJSONFile([]vship.DisplayModel{display},
 		tmp.Name())
 	if e != nil {
 		return nil, e
@@ -96,8 +149,9 @@ func NewCVVDPHandler(numWorkers int, a, colorB *vship.Colorspace,
 
 	// defer os.Remove(tmp.Name())
 
-	for range numWorkers {
-		err := h.createWorker(a, colorB, tmp.Name(), fps)
+	for range opts.Workers {
+		err := h.createWorker(opts.Reference, opts.Distortion, tmp.Name(),
+			opts.FrameRate)
 		if err != nil {
 			defer h.Close()
 			return nil, err
@@ -188,11 +242,11 @@ func (h *CVVDPHandler) Compute(a, b video.Frame) (map[string]float64,
 	}
 
 SKIP_TEMPORAL_RESET:
-	s, code = handler.ComputeScore(dstptr, dstStride, a.Data(), b.Data(),
-		a.LineSizes(), b.LineSizes())
+	s, code = handler.ComputeScore(dstptr, dstStride, a.ColorPlanes(),
+		b.ColorPlanes(), a.ColorLineSizes(), b.ColorLineSizes())
 
 	if h.callback != nil {
-		if err := h.callback(h.distortionBuffer); err != nil {
+		if err := h.callback(h.distortionBuffer, s); err != nil {
 			return nil, err
 		}
 	}
@@ -213,6 +267,15 @@ func (h *CVVDPHandler) GetDistMapResolution() (int, int, error) {
 	return h.dstWidth, h.dstHeight, nil
 }
 
+// GPUStats sums the vship calls of every worker.
+func (h *CVVDPHandler) GPUStats() video.GPUStats {
+	var stats video.GPUStats
+	for _, handler := range h.handlerList {
+		stats = stats.Add(gpuStats(handler.Stats()))
+	}
+	return stats
+}
+
 // Close releases all underlying CVVDP workers.
 func (h *CVVDPHandler) Close() {
 	for _, handler := range h.handlerList {
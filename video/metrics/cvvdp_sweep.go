@@ -0,0 +1,160 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// ViewingCondition is one display and viewing setup a CVVDPSweep scores
+// under.
+type ViewingCondition struct {
+	// Label identifies the condition in the score key, which is
+	// CVVDPSweepKey(Label).
+	Label   string
+	Display vship.DisplayModel
+}
+
+// CVVDPSweepKey returns the score key of the condition with the given label,
+// e.g. "CVVDP@250lux".
+func CVVDPSweepKey(label string) string { return CVVDPName + "@" + label }
+
+// AmbientSweep returns one condition per ambient light level in lux, each
+// otherwise equal to display. Labels are of the form "250lux".
+func AmbientSweep(display vship.DisplayModel, lux []int) []ViewingCondition {
+	conditions := make([]ViewingCondition, len(lux))
+	for i, level := range lux {
+		conditions[i].Label = strconv.Itoa(level) + "lux"
+		conditions[i].Display = display
+		conditions[i].Display.AmbientLightLevel = level
+	}
+	return conditions
+}
+
+// DistanceSweep returns one condition per viewing distance in meters, each
+// otherwise equal to display. Labels are of the form "0.75m".
+func DistanceSweep(display vship.DisplayModel,
+	meters []float32) []ViewingCondition {
+	conditions := make([]ViewingCondition, len(meters))
+	for i, distance := range meters {
+		conditions[i].Label = strconv.FormatFloat(float64(distance), 'g', -1,
+			32) + "m"
+		conditions[i].Display = display
+		conditions[i].Display.ViewingDistanceMeters = distance
+	}
+	return conditions
+}
+
+// CVVDPSweep scores every frame pair with CVVDP under several viewing
+// conditions at once, so content can be checked for e.g. both dark-room and
+// bright-room viewing in one run. It returns one score per condition, keyed by
+// CVVDPSweepKey.
+//
+// Each condition has its own CVVDPHandler with its own workers. The
+// conditions of a frame pair are computed one after the other.
+type CVVDPSweep struct {
+	handlers []*CVVDPHandler
+	keys     []string
+
+	numWorkers  int
+	useTemporal bool
+}
+
+// Name returns the metric identifier.
+func (s *CVVDPSweep) Name() string { return CVVDPName + "Sweep" }
+
+// NewCVVDPSweep constructs a CVVDPSweep with one CVVDPHandler per condition,
+// each configured by opts with the display taken from its condition. Labels
+// must be unique.
+func NewCVVDPSweep(opts CVVDPOptions, conditions []ViewingCondition) (
+	*CVVDPSweep, error) {
+	if len(conditions) == 0 {
+		return nil, errors.New("a CVVDP sweep needs at least one condition")
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
+	s := &CVVDPSweep{numWorkers: opts.Workers, useTemporal: opts.UseTemporal}
+	seen := make(map[string]bool, len(conditions))
+
+	for _, condition := range conditions {
+		key := CVVDPSweepKey(condition.Label)
+		if seen[key] {
+			s.Close()
+			return nil, fmt.Errorf("duplicate viewing condition %q",
+				condition.Label)
+		}
+		seen[key] = true
+
+		opts.Display = condition.Display
+		handler, err := NewCVVDPHandler(opts)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("condition %s: %w", condition.Label, err)
+		}
+
+		s.handlers = append(s.handlers, handler.(*CVVDPHandler))
+		s.keys = append(s.keys, key)
+	}
+
+	return s, nil
+}
+
+// Compute scores the frame pair under every condition.
+func (s *CVVDPSweep) Compute(a, b video.Frame) (map[string]float64, error) {
+	scores := make(map[string]float64, len(s.handlers))
+
+	for i, handler := range s.handlers {
+		result, err := handler.Compute(a, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.keys[i], err)
+		}
+		scores[s.keys[i]] = result[CVVDPName]
+	}
+
+	return scores, nil
+}
+
+// Sequential returns true with temporal weighting, see CVVDPHandler.
+func (s *CVVDPSweep) Sequential() bool { return s.useTemporal }
+
+// MaxConcurrency returns the number of workers of each condition.
+func (s *CVVDPSweep) MaxConcurrency() int { return s.numWorkers }
+
+// IdentityScores returns the maximum of 10 JOD for every condition, unless
+// temporal weighting needs every frame.
+func (s *CVVDPSweep) IdentityScores() (map[string]float64, bool) {
+	if s.useTemporal {
+		return nil, false
+	}
+
+	scores := make(map[string]float64, len(s.keys))
+	for _, key := range s.keys {
+		scores[key] = 10
+	}
+	return scores, true
+}
+
+// GPUStats sums the vship calls of every condition.
+func (s *CVVDPSweep) GPUStats() video.GPUStats {
+	var stats video.GPUStats
+	for _, handler := range s.handlers {
+		stats = stats.Add(handler.GPUStats())
+	}
+	return stats
+}
+
+// Close releases the workers of every condition.
+func (s *CVVDPSweep) Close() {
+	for _, handler := range s.handlers {
+		handler.Close()
+	}
+	s.handlers = nil
+}
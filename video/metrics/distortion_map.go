@@ -1,15 +1,11 @@
+//go:build cgo && !nocgo
+
 package metrics
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"strconv"
 	"sync"
-	"unsafe"
 
 	"github.com/GreatValueCreamSoda/gometrics/video"
 )
@@ -22,147 +18,151 @@ type MetricWithDistortionMap interface {
 	video.Metric
 }
 
-type DistortionMapCallback func([]float32) error
+// DistortionMapCallback receives the distortion map of every computed frame
+// with the frame's main score: the NormQ distance for Butteraugli and the JOD
+// for CVVDP. The map is only valid during the call.
+type DistortionMapCallback func(distMap []float32, score float64) error
 
+// HeatmapWriter normalizes the distortion maps of a metric and hands them to
+// a HeatmapSink.
 type HeatmapWriter struct {
-	cmd  *exec.Cmd
-	pipe io.WriteCloser
+	sink HeatmapSink
 
 	maxValue float32
 
 	normalized []float32
-	byteBuf    []byte
+
+	width, height int
+	// burnIn is set by EnableBurnIn, written counts the maps written so far.
+	burnIn  *burnIn
+	written int
+	// autoRange is set by EnableAutoRange.
+	autoRange *autoRanger
 
 	closeOnce sync.Once
+	closeErr  error
 }
 
-func WriteDistMapToVideo(metric MetricWithDistortionMap, frameRate float32,
-	settings []string, path string, maxValue float32) (*HeatmapWriter,
-	error) {
-
+// NewHeatmapWriter writes the distortion maps of metric to sink. Values are
+// clipped at maxValue and scaled to [0, 1]. The writer owns sink and closes
+// it with Close, also when NewHeatmapWriter fails.
+func NewHeatmapWriter(metric MetricWithDistortionMap, sink HeatmapSink,
+	maxValue float32) (*HeatmapWriter, error) {
 	if maxValue <= 0 {
+		_ = sink.Close()
 		return nil, fmt.Errorf("maxValue must be > 0")
 	}
 
 	width, height, err := metric.GetDistMapResolution()
 	if err != nil {
+		_ = sink.Close()
 		return nil, err
 	}
-	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("invalid resolution: %dx%d", width, height)
-	}
 
-	cmd, pipe, err := startFFmpeg(width, height, frameRate, settings, path)
-	if err != nil {
+	writer := &HeatmapWriter{sink: sink, maxValue: maxValue, width: width,
+		height: height}
+
+	if err := metric.SetDistMapCallback(writer.WriteDistortion); err != nil {
+		_ = writer.Close()
 		return nil, err
 	}
 
-	writer := &HeatmapWriter{
-		cmd:      cmd,
-		pipe:     pipe,
-		maxValue: maxValue,
+	return writer, nil
+}
+
+// WriteDistMapToVideo encodes the distortion maps of metric into a heat map
+// video at path with ffmpeg, see NewFFmpegHeatmapSink.
+func WriteDistMapToVideo(metric MetricWithDistortionMap, frameRate float32,
+	opts FFmpegOptions, path string, maxValue float32) (*HeatmapWriter,
+	error) {
+
+	if maxValue <= 0 {
+		return nil, fmt.Errorf("maxValue must be > 0")
 	}
 
-	if err := cmd.Start(); err != nil {
-		pipe.Close()
-		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	width, height, err := metric.GetDistMapResolution()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := metric.SetDistMapCallback(writer.WriteDistortion); err != nil {
-		_ = writer.Close()
+	sink, err := NewFFmpegHeatmapSink(width, height, frameRate, opts, path)
+	if err != nil {
 		return nil, err
 	}
 
-	return writer, nil
+	return NewHeatmapWriter(metric, sink, maxValue)
 }
 
-func startFFmpeg(width int, height int, frameRate float32, settings []string,
-	outputPath string) (*exec.Cmd, io.WriteCloser, error) {
-
-	frameRateStr := strconv.FormatFloat(float64(frameRate), 'f', -1, 64)
-	resolution := fmt.Sprintf("%dx%d", width, height)
+// EnableBurnIn overlays the frame index, the timestamp at frameRate and the
+// score of every frame as text at the top left corner of its heat map. The
+// text is drawn at full scale on a box of zeros. Must be called before the
+// first map is written.
+func (h *HeatmapWriter) EnableBurnIn(frameRate float32) error {
+	if frameRate <= 0 {
+		return fmt.Errorf("burn-in needs a frame rate > 0, got %g", frameRate)
+	}
 
-	filter := "format=rgb24,pseudocolor=p=heat"
+	h.burnIn = &burnIn{frameRate: frameRate, width: h.width,
+		height: h.height}
+	return nil
+}
 
-	if settings == nil {
-		settings = []string{"-c:v", "libx264", "-preset", "fast", "-crf", "18"}
+// EnableAutoRange scales every map by a percentile of the recent maps
+// instead of the fixed maxValue, as a good clipping value differs widely
+// between metrics and content. maxValue is still used while the recent maps
+// are all zero. Must be called before the first map is written.
+func (h *HeatmapWriter) EnableAutoRange(opts AutoRange) error {
+	opts.setDefaults()
+	if err := opts.validate(); err != nil {
+		return err
 	}
 
-	args := append([]string{
-		"-y",
-		"-f", "rawvideo",
-		"-pixel_format", "grayf32le",
-		"-s", resolution,
-		"-r", frameRateStr,
-		"-i", "-",
-		"-vf", filter,
-		"-pix_fmt", "yuv420p",
-	}, append(settings, outputPath)...)
-
-	cmd := exec.Command("ffmpeg", args...)
-
-	cmd.Stderr = os.Stderr
-
-	if pipe, err := cmd.StdinPipe(); err != nil {
-		return nil, nil, fmt.Errorf("failed to get ffmpeg stdin pipe: %w", err)
-	} else {
-		return cmd, pipe, nil
-	}
+	h.autoRange = &autoRanger{opts: opts}
+	return nil
 }
 
-func (h *HeatmapWriter) WriteDistortion(input []float32) error {
+func (h *HeatmapWriter) WriteDistortion(input []float32, score float64) error {
 	if len(input) == 0 {
 		return nil
 	}
 
+	clip := h.maxValue
+	if h.autoRange != nil {
+		clip = h.autoRange.clipValue(input, h.maxValue)
+	}
+
 	h.ensureBuffers(len(input))
-	h.normalize(input)
-	return h.writeFloats()
+	h.normalize(input, clip)
+
+	if h.burnIn != nil && len(input) == h.width*h.height {
+		h.burnIn.draw(h.normalized, h.burnIn.label(h.written, score))
+	}
+	h.written++
+
+	return h.sink.WriteFrame(h.normalized)
 }
 
 func (h *HeatmapWriter) ensureBuffers(n int) {
 	if cap(h.normalized) < n {
 		h.normalized = make([]float32, n)
-		h.byteBuf = make([]byte, n*4)
 		return
 	}
 
 	h.normalized = h.normalized[:n]
-	h.byteBuf = h.byteBuf[:n*4]
 }
 
-func (h *HeatmapWriter) normalize(input []float32) {
-	scale := float32(1.0) / h.maxValue
+func (h *HeatmapWriter) normalize(input []float32, clip float32) {
+	scale := float32(1.0) / clip
 
 	for i, v := range input {
-		if v > h.maxValue {
-			v = h.maxValue
+		if v > clip {
+			v = clip
 		}
 		h.normalized[i] = v * scale
 	}
 }
 
-func (h *HeatmapWriter) writeFloats() error {
-	for i, v := range h.normalized {
-		binary.LittleEndian.PutUint32(
-			h.byteBuf[i*4:],
-			binary.LittleEndian.Uint32((*[4]byte)(unsafe.Pointer(&v))[:]),
-		)
-	}
-	_, err := h.pipe.Write(h.byteBuf)
-	return err
-}
-
 func (h *HeatmapWriter) Close() error {
-	var waitErr error
-
-	h.closeOnce.Do(func() {
-		_ = h.pipe.Close()
-		waitErr = h.cmd.Wait()
-	})
-
-	if waitErr != nil {
-		return fmt.Errorf("ffmpeg failed: %w", waitErr)
-	}
-	return nil
+	h.closeOnce.Do(func() { h.closeErr = h.sink.Close() })
+	return h.closeErr
 }
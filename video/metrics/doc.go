@@ -0,0 +1,7 @@
+// Package metrics implements video.Metric for the quality metrics supported by
+// gometrics.
+//
+// The vship backed GPU metrics require cgo and are excluded from builds using
+// the nocgo tag. The no-reference metrics Blockiness and Banding run on the
+// CPU and score the distortion alone.
+package metrics
@@ -0,0 +1,14 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// gpuStats converts the call stats of a vship handler.
+func gpuStats(stats vship.CallStats) video.GPUStats {
+	return video.GPUStats{Calls: stats.Calls, Time: stats.Time,
+		UploadBytes: stats.UploadBytes, DownloadBytes: stats.DownloadBytes}
+}
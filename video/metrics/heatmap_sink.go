@@ -0,0 +1,377 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HeatmapSink receives the heat maps of a HeatmapWriter, one per compared
+// frame in frame order. Every map is width*height values in [0, 1], row by
+// row, and is only valid during the call.
+type HeatmapSink interface {
+	WriteFrame(values []float32) error
+	// Close flushes and releases the sink.
+	Close() error
+}
+
+// FFmpegOptions configures the ffmpeg process encoding heat maps, see
+// NewFFmpegHeatmapSink.
+type FFmpegOptions struct {
+	// Path of the ffmpeg executable. Defaults to ffmpeg from PATH.
+	Binary string
+	// Encoder is the video encoder, e.g. libx264 or h264_nvenc. libx264 and
+	// the nvenc encoders come with quality settings, others run with their
+	// defaults unless EncoderArgs is set. Defaults to libx264.
+	Encoder string
+	// EncoderArgs replaces the encoder options chosen by Encoder, e.g.
+	// {"-c:v", "libx265", "-crf", "20"}.
+	EncoderArgs []string
+	// OutputArgs replaces every argument after the input, i.e. the filter,
+	// pixel format, encoder options and output, for full control. Each
+	// argument is a template in which {output}, {width}, {height} and {fps}
+	// are replaced.
+	OutputArgs []string
+	// Stderr receives the log of ffmpeg as well. The end of the log is
+	// always captured and added to the error when ffmpeg fails.
+	Stderr io.Writer
+}
+
+func (o *FFmpegOptions) setDefaults() {
+	if o.Binary == "" {
+		o.Binary = "ffmpeg"
+	}
+	if o.Encoder == "" {
+		o.Encoder = "libx264"
+	}
+}
+
+// encoderArgs returns the encoder options of EncoderArgs or Encoder.
+func (o *FFmpegOptions) encoderArgs() []string {
+	if o.EncoderArgs != nil {
+		return o.EncoderArgs
+	}
+
+	switch o.Encoder {
+	case "libx264":
+		return []string{"-c:v", "libx264", "-preset", "fast", "-crf", "18"}
+	case "h264_nvenc", "hevc_nvenc", "av1_nvenc":
+		return []string{"-c:v", o.Encoder, "-preset", "p4", "-rc", "vbr",
+			"-cq", "18"}
+	default:
+		return []string{"-c:v", o.Encoder}
+	}
+}
+
+// ffmpegLogTail is how much of the end of the ffmpeg log is kept for errors.
+const ffmpegLogTail = 4096
+
+// ffmpegSink pipes heat maps into an ffmpeg process that colors and encodes
+// them into a video.
+type ffmpegSink struct {
+	cmd  *exec.Cmd
+	pipe io.WriteCloser
+	log  *tailBuffer
+	buf  []byte
+}
+
+// NewFFmpegHeatmapSink starts ffmpeg encoding heat maps of width by height
+// into a pseudocolored video at path.
+func NewFFmpegHeatmapSink(width, height int, frameRate float32,
+	opts FFmpegOptions, path string) (HeatmapSink, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %dx%d", width, height)
+	}
+	opts.setDefaults()
+
+	cmd, pipe, err := startFFmpeg(width, height, frameRate, opts, path)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &ffmpegSink{cmd: cmd, pipe: pipe,
+		log: &tailBuffer{limit: ffmpegLogTail}}
+	cmd.Stderr = sink.log
+	if opts.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(sink.log, opts.Stderr)
+	}
+
+	if err := cmd.Start(); err != nil {
+		pipe.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", opts.Binary, err)
+	}
+
+	return sink, nil
+}
+
+func startFFmpeg(width int, height int, frameRate float32, opts FFmpegOptions,
+	outputPath string) (*exec.Cmd, io.WriteCloser, error) {
+
+	frameRateStr := strconv.FormatFloat(float64(frameRate), 'f', -1, 64)
+	resolution := fmt.Sprintf("%dx%d", width, height)
+
+	filter := "format=rgb24,pseudocolor=p=heat"
+
+	args := []string{
+		"-y",
+		"-f", "rawvideo",
+		"-pixel_format", "grayf32le",
+		"-s", resolution,
+		"-r", frameRateStr,
+		"-i", "-",
+	}
+
+	if opts.OutputArgs != nil {
+		replacer := strings.NewReplacer("{output}", outputPath,
+			"{width}", strconv.Itoa(width), "{height}", strconv.Itoa(height),
+			"{fps}", frameRateStr)
+		for _, arg := range opts.OutputArgs {
+			args = append(args, replacer.Replace(arg))
+		}
+	} else {
+		args = append(args, "-vf", filter, "-pix_fmt", "yuv420p")
+		args = append(args, opts.encoderArgs()...)
+		args = append(args, outputPath)
+	}
+
+	cmd := exec.Command(opts.Binary, args...)
+
+	if pipe, err := cmd.StdinPipe(); err != nil {
+		return nil, nil, fmt.Errorf("failed to get ffmpeg stdin pipe: %w", err)
+	} else {
+		return cmd, pipe, nil
+	}
+}
+
+func (s *ffmpegSink) WriteFrame(values []float32) error {
+	s.buf = appendFloats(s.buf[:0], values)
+	if _, err := s.pipe.Write(s.buf); err != nil {
+		return fmt.Errorf("failed to write to ffmpeg, see the error on "+
+			"close: %w", err)
+	}
+	return nil
+}
+
+func (s *ffmpegSink) Close() error {
+	_ = s.pipe.Close()
+	if err := s.cmd.Wait(); err != nil {
+		if tail := strings.TrimSpace(s.log.String()); tail != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, tail)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// tailBuffer is an io.Writer keeping the last limit bytes written to it.
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if excess := len(b.data) - b.limit; excess > 0 {
+		b.data = append(b.data[:0], b.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string { return string(b.data) }
+
+// rawSink writes heat maps as a headerless stream of little endian float32,
+// the ffmpeg grayf32le format.
+type rawSink struct {
+	w   io.WriteCloser
+	buf []byte
+}
+
+// NewRawHeatmapSink writes heat maps to w as a headerless stream of little
+// endian float32 values, frame after frame, e.g. to a socket feeding another
+// analysis tool. The reader must know the resolution, see
+// MetricWithDistortionMap.GetDistMapResolution. Closing the sink closes w.
+func NewRawHeatmapSink(w io.WriteCloser) HeatmapSink {
+	return &rawSink{w: w}
+}
+
+func (s *rawSink) WriteFrame(values []float32) error {
+	s.buf = appendFloats(s.buf[:0], values)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *rawSink) Close() error { return s.w.Close() }
+
+// y4mSink writes heat maps as a 16-bit grayscale Y4M stream.
+type y4mSink struct {
+	file io.WriteCloser
+	w    *bufio.Writer
+	buf  []byte
+}
+
+// NewY4MHeatmapSink writes heat maps of width by height to w as a 16-bit
+// grayscale (Cmono16) Y4M stream, full range, with 0 mapped to black and 1 to
+// white. Closing the sink closes w.
+func NewY4MHeatmapSink(w io.WriteCloser, width, height int,
+	frameRate float32) (HeatmapSink, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %dx%d", width, height)
+	}
+
+	s := &y4mSink{file: w, w: bufio.NewWriterSize(w, 1<<20)}
+
+	num, den := int(math.Round(float64(frameRate)*1000)), 1000
+	header := fmt.Sprintf("YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 Cmono16 "+
+		"XCOLORRANGE=FULL\n", width, height, num, den)
+	if _, err := s.w.WriteString(header); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *y4mSink) WriteFrame(values []float32) error {
+	if _, err := s.w.WriteString("FRAME\n"); err != nil {
+		return err
+	}
+
+	s.buf = s.buf[:0]
+	for _, v := range values {
+		code := uint16(math.Round(float64(min(max(v, 0), 1)) * 65535))
+		s.buf = binary.LittleEndian.AppendUint16(s.buf, code)
+	}
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+func (s *y4mSink) Close() error {
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// appendFloats appends values to dst as little endian float32.
+func appendFloats(dst []byte, values []float32) []byte {
+	for _, v := range values {
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(v))
+	}
+	return dst
+}
+
+// aggregateSink accumulates heat maps into their per-pixel maximum and mean
+// and writes both as PNG images on Close.
+type aggregateSink struct {
+	width, height     int
+	maxPath, meanPath string
+
+	maximum []float32
+	sum     []float64
+	frames  int
+}
+
+// NewAggregateHeatmapSink accumulates every heat map of width by height into
+// a max projection written to maxPath and a mean projection written to
+// meanPath, both 16-bit grayscale PNG images with 0 as black and 1 as white.
+// The images are written on Close. Areas that are damaged throughout the run,
+// such as a poorly coded logo or frame edge, stand out in the mean, while the
+// max shows everywhere that was damaged at least once.
+func NewAggregateHeatmapSink(width, height int, maxPath,
+	meanPath string) (HeatmapSink, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %dx%d", width, height)
+	}
+
+	return &aggregateSink{width: width, height: height, maxPath: maxPath,
+		meanPath: meanPath, maximum: make([]float32, width*height),
+		sum: make([]float64, width*height)}, nil
+}
+
+func (s *aggregateSink) WriteFrame(values []float32) error {
+	if len(values) != len(s.sum) {
+		return fmt.Errorf("heat map of %d values does not match %dx%d",
+			len(values), s.width, s.height)
+	}
+
+	for i, v := range values {
+		s.maximum[i] = max(s.maximum[i], v)
+		s.sum[i] += float64(v)
+	}
+	s.frames++
+	return nil
+}
+
+func (s *aggregateSink) Close() error {
+	mean := make([]float32, len(s.sum))
+	for i, sum := range s.sum {
+		mean[i] = float32(sum / float64(max(s.frames, 1)))
+	}
+
+	if err := writeGrayPNG(s.maxPath, s.width, s.height, s.maximum); err != nil {
+		return err
+	}
+	return writeGrayPNG(s.meanPath, s.width, s.height, mean)
+}
+
+// pngSink writes the heat map of a single frame as a PNG image.
+type pngSink struct {
+	width, height int
+	path          string
+	written       bool
+}
+
+// NewPNGHeatmapSink writes the heat map of width by height of a comparison
+// of a single frame pair, such as two still images, to path as a 16-bit
+// grayscale PNG image with 0 as black and 1 as white. More than one map is an
+// error, see NewAggregateHeatmapSink for videos.
+func NewPNGHeatmapSink(width, height int, path string) (HeatmapSink, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid resolution: %dx%d", width, height)
+	}
+	return &pngSink{width: width, height: height, path: path}, nil
+}
+
+func (s *pngSink) WriteFrame(values []float32) error {
+	if s.written {
+		return fmt.Errorf("%s holds the heat map of a single frame", s.path)
+	}
+	if len(values) != s.width*s.height {
+		return fmt.Errorf("heat map of %d values does not match %dx%d",
+			len(values), s.width, s.height)
+	}
+
+	s.written = true
+	return writeGrayPNG(s.path, s.width, s.height, values)
+}
+
+func (s *pngSink) Close() error { return nil }
+
+// writeGrayPNG writes values in [0, 1] to path as a 16-bit grayscale PNG.
+func writeGrayPNG(path string, width, height int, values []float32) error {
+	img := image.NewGray16(image.Rect(0, 0, width, height))
+	for i, v := range values {
+		code := uint16(math.Round(float64(min(max(v, 0), 1)) * 65535))
+		binary.BigEndian.PutUint16(img.Pix[2*i:], code)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,30 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+// IdentityScores returns a perfect SSIMULACRA2 score of 100.
+func (h *Ssimu2Handler) IdentityScores() (map[string]float64, bool) {
+	return map[string]float64{SSIMulacra2Name: 100}, true
+}
+
+// IdentityScores returns zero for every Butteraugli norm, unless a distortion
+// map is being written and every frame must be computed.
+func (h *ButterHandler) IdentityScores() (map[string]float64, bool) {
+	if h.callback != nil {
+		return nil, false
+	}
+	return map[string]float64{ButteraugliName + "NormQ": 0,
+		ButteraugliName + "Norm3": 0,
+		ButteraugliName + "Inf":   0,
+	}, true
+}
+
+// IdentityScores returns the maximum of 10 JOD. Temporal scoring needs every
+// frame in its history and a distortion map needs every frame written, so
+// neither can be skipped.
+func (h *CVVDPHandler) IdentityScores() (map[string]float64, bool) {
+	if h.useTemporal || h.callback != nil {
+		return nil, false
+	}
+	return map[string]float64{CVVDPName: 10}, true
+}
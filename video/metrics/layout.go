@@ -0,0 +1,29 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import "github.com/GreatValueCreamSoda/gometrics/video"
+
+// vshipSupportsLayout reports whether vship can read frames with the given
+// layout. vship only reads the three color planes, so an alpha plane is
+// ignored rather than composited.
+func vshipSupportsLayout(layout video.PlaneLayout) bool {
+	switch layout {
+	case video.LayoutYUV, video.LayoutYUVA, video.LayoutRGB, video.LayoutRGBA:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *ButterHandler) SupportsLayout(layout video.PlaneLayout) bool {
+	return vshipSupportsLayout(layout)
+}
+
+func (h *CVVDPHandler) SupportsLayout(layout video.PlaneLayout) bool {
+	return vshipSupportsLayout(layout)
+}
+
+func (h *Ssimu2Handler) SupportsLayout(layout video.PlaneLayout) bool {
+	return vshipSupportsLayout(layout)
+}
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Resolution is one analysis resolution a MultiResolution scores at.
+type Resolution struct {
+	// Label identifies the resolution in the score keys, which are
+	// MultiResolutionKey(key, Label).
+	Label string
+	// Width and Height are the resolution frames are scaled to before
+	// scoring, both 0 for the resolution of the sources.
+	Width, Height int
+}
+
+// MultiResolutionKey returns the key a score of a metric has at the
+// resolution with the given label, e.g. "Ssimulacra2@540p".
+func MultiResolutionKey(key, label string) string { return key + "@" + label }
+
+// MultiResolution scores every frame pair with the same metric at several
+// analysis resolutions, from one decode, to study how stable a metric is
+// across viewing scales. Every score of the wrapped metrics is returned keyed
+// by MultiResolutionKey.
+//
+// Each resolution has its own metric, computed one after the other for every
+// frame pair.
+type MultiResolution struct {
+	name    string
+	metrics []video.Metric
+	labels  []string
+}
+
+// NewMultiResolution constructs a MultiResolution with one metric per
+// resolution, made by newMetric. Labels must be unique.
+func NewMultiResolution(resolutions []Resolution,
+	newMetric func(Resolution) (video.Metric, error)) (*MultiResolution,
+	error) {
+	if len(resolutions) == 0 {
+		return nil, errors.New("a multi-resolution metric needs at least " +
+			"one resolution")
+	}
+
+	m := &MultiResolution{}
+	seen := make(map[string]bool, len(resolutions))
+
+	for _, resolution := range resolutions {
+		if seen[resolution.Label] {
+			m.Close()
+			return nil, fmt.Errorf("duplicate resolution %q",
+				resolution.Label)
+		}
+		seen[resolution.Label] = true
+
+		metric, err := newMetric(resolution)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("resolution %s: %w", resolution.Label, err)
+		}
+
+		m.metrics = append(m.metrics, metric)
+		m.labels = append(m.labels, resolution.Label)
+	}
+
+	m.name = m.metrics[0].Name() + "MultiResolution"
+	return m, nil
+}
+
+// Name returns the metric identifier.
+func (m *MultiResolution) Name() string { return m.name }
+
+// Compute scores the frame pair at every resolution.
+func (m *MultiResolution) Compute(a, b video.Frame) (map[string]float64,
+	error) {
+	scores := make(map[string]float64, len(m.metrics))
+
+	for i, metric := range m.metrics {
+		result, err := metric.Compute(a, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.labels[i], err)
+		}
+		for key, score := range result {
+			scores[MultiResolutionKey(key, m.labels[i])] = score
+		}
+	}
+
+	return scores, nil
+}
+
+// CheckBitDepths accepts the bit depths every resolution accepts.
+func (m *MultiResolution) CheckBitDepths(depthA, depthB int) error {
+	for i, metric := range m.metrics {
+		checker, ok := metric.(video.BitDepthChecker)
+		if !ok {
+			if depthA != depthB {
+				return fmt.Errorf("%s cannot compare a %d-bit video a "+
+					"against a %d-bit video b", metric.Name(), depthA,
+					depthB)
+			}
+			continue
+		}
+		if err := checker.CheckBitDepths(depthA, depthB); err != nil {
+			return fmt.Errorf("%s: %w", m.labels[i], err)
+		}
+	}
+	return nil
+}
+
+// SupportsLayout reports whether every resolution supports layout.
+func (m *MultiResolution) SupportsLayout(layout video.PlaneLayout) bool {
+	for _, metric := range m.metrics {
+		checker, ok := metric.(video.LayoutChecker)
+		if ok && !checker.SupportsLayout(layout) || !ok &&
+			layout != video.LayoutYUV && layout != video.LayoutRGB {
+			return false
+		}
+	}
+	return true
+}
+
+// GPUStats sums the GPU calls of every resolution that reports them.
+func (m *MultiResolution) GPUStats() video.GPUStats {
+	var stats video.GPUStats
+	for _, metric := range m.metrics {
+		if reporter, ok := metric.(video.GPUStatsReporter); ok {
+			stats = stats.Add(reporter.GPUStats())
+		}
+	}
+	return stats
+}
+
+// IdentityScores returns the identity scores of every resolution, or false
+// if any of them must see the frame.
+func (m *MultiResolution) IdentityScores() (map[string]float64, bool) {
+	scores := make(map[string]float64)
+	for i, metric := range m.metrics {
+		scorer, ok := metric.(video.IdentityScorer)
+		if !ok {
+			return nil, false
+		}
+		result, ok := scorer.IdentityScores()
+		if !ok {
+			return nil, false
+		}
+		for key, score := range result {
+			scores[MultiResolutionKey(key, m.labels[i])] = score
+		}
+	}
+	return scores, true
+}
+
+// Capabilities returns the capabilities of the wrapped metric, without
+// distortion maps, which a MultiResolution does not write.
+func (m *MultiResolution) Capabilities() video.Capabilities {
+	capabilities := video.MetricCapabilities(m.metrics[0])
+	capabilities.DistortionMap = false
+	capabilities.Sequential = m.Sequential()
+	return capabilities
+}
+
+// Sequential returns true if any resolution keeps state between frames.
+func (m *MultiResolution) Sequential() bool {
+	for _, metric := range m.metrics {
+		if sequential, ok := metric.(video.SequentialMetric); ok &&
+			sequential.Sequential() {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxConcurrency returns the lowest limit of any resolution, or 0 if none
+// limits it.
+func (m *MultiResolution) MaxConcurrency() int {
+	var limit int
+	for _, metric := range m.metrics {
+		limiter, ok := metric.(video.ConcurrencyLimiter)
+		if !ok || limiter.MaxConcurrency() <= 0 {
+			continue
+		}
+		if limit == 0 || limiter.MaxConcurrency() < limit {
+			limit = limiter.MaxConcurrency()
+		}
+	}
+	return limit
+}
+
+// Reset discards the temporal history of every resolution.
+func (m *MultiResolution) Reset() error {
+	for i, metric := range m.metrics {
+		resettable, ok := metric.(video.ResettableMetric)
+		if !ok {
+			continue
+		}
+		if err := resettable.Reset(); err != nil {
+			return fmt.Errorf("%s: %w", m.labels[i], err)
+		}
+	}
+	return nil
+}
+
+// Close closes the metric of every resolution.
+func (m *MultiResolution) Close() {
+	for _, metric := range m.metrics {
+		metric.Close()
+	}
+	m.metrics = nil
+}
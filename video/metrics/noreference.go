@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// The canonical names of the no-reference metrics, which score the frames of
+// the distortion alone.
+const (
+	BlockinessName = "Blockiness"
+	BandingName    = "Banding"
+)
+
+// The score formats of the no-reference metrics.
+var (
+	blockinessFormat = video.ScoreFormat{Decimals: 3}
+	bandingFormat    = video.ScoreFormat{Unit: "%", Decimals: 3}
+)
+
+const (
+	// blockSize is the block grid blockiness is measured on, that of the
+	// 8x8 transform of MPEG-2 and H.264 and the smallest of later codecs.
+	blockSize = 8
+	// blockinessFloor is added to both mean differences of blockiness, in
+	// 8-bit code values, so flat pictures score 1 rather than dividing by
+	// zero.
+	blockinessFloor = 0.5
+	// bandStepMin and bandStepMax bound the luma difference between
+	// neighbouring samples that makes a band edge, in 8-bit code values.
+	// Smaller differences are flat, larger ones real edges.
+	bandStepMin, bandStepMax = 0.5, 3.0
+	// bandWidth is how many flat samples a band edge needs on either side.
+	bandWidth = 8
+)
+
+// BlockinessCapabilities describes Blockiness, scored 1 when block
+// boundaries differ no more than the rest of the picture.
+func BlockinessCapabilities() video.Capabilities {
+	return video.Capabilities{
+		MixedBitDepths: true,
+		NoReference:    true,
+		MinScore:       0,
+		MaxScore:       math.Inf(1),
+		Direction:      video.LowerIsBetter,
+		Format:         blockinessFormat,
+	}
+}
+
+// BandingCapabilities describes Banding, scored as a percentage.
+func BandingCapabilities() video.Capabilities {
+	return video.Capabilities{
+		MixedBitDepths: true,
+		NoReference:    true,
+		MinScore:       0,
+		MaxScore:       100,
+		Direction:      video.LowerIsBetter,
+		Format:         bandingFormat,
+	}
+}
+
+// lumaPlane reads the first plane of frames, luma or for planar RGB green, in
+// 8-bit code values.
+type lumaPlane struct {
+	width, height int
+	wide          bool
+	// scale maps sample codes to 8-bit code values.
+	scale float32
+	pool  sync.Pool
+}
+
+func newLumaPlane(props *video.ColorProperties) (*lumaPlane, error) {
+	layout, err := props.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout == video.LayoutPacked {
+		return nil, errors.New("packed frames must be planarized first")
+	}
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+	if depth < 8 {
+		return nil, fmt.Errorf("unsupported bit depth %d", depth)
+	}
+	if props.Width < 2 || props.Height < 2 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", props.Width,
+			props.Height)
+	}
+
+	p := &lumaPlane{width: props.Width, height: props.Height,
+		wide: depth > 8, scale: 1 / float32(int(1)<<(depth-8))}
+	p.pool.New = func() any {
+		return make([]float32, p.width*p.height)
+	}
+	return p, nil
+}
+
+// load returns the samples of the first plane of frame, row by row. The
+// caller hands them back with release.
+func (p *lumaPlane) load(frame *video.Frame) []float32 {
+	samples := p.pool.Get().([]float32)
+	plane, stride := frame.PlaneData(0), frame.PlaneLineSize(0)
+
+	for y := range p.height {
+		row := samples[y*p.width : (y+1)*p.width]
+		if p.wide {
+			for x := range row {
+				offset := y*stride + 2*x
+				code := int(plane[offset]) | int(plane[offset+1])<<8
+				row[x] = float32(code) * p.scale
+			}
+			continue
+		}
+		for x, code := range plane[y*stride : y*stride+p.width] {
+			row[x] = float32(code)
+		}
+	}
+	return samples
+}
+
+func (p *lumaPlane) release(samples []float32) { p.pool.Put(samples) }
+
+// Blockiness scores the visibility of the block grid of block based codecs
+// in the distortion, as the mean luma difference across the boundaries of
+// 8x8 blocks over the mean difference between the other neighbouring
+// samples. Unblocked pictures score about 1, visible blocking well above.
+// Grids not aligned to the frame, e.g. after cropping or scaling, are not
+// detected. It needs no reference, the reference frame is ignored.
+type Blockiness struct {
+	luma *lumaPlane
+}
+
+// NewBlockiness returns Blockiness for frames described by props, which must
+// use a planar pixel format.
+func NewBlockiness(props *video.ColorProperties) (*Blockiness, error) {
+	luma, err := newLumaPlane(props)
+	if err != nil {
+		return nil, fmt.Errorf("blockiness: %w", err)
+	}
+	return &Blockiness{luma: luma}, nil
+}
+
+func (b *Blockiness) Name() string { return BlockinessName }
+func (b *Blockiness) Close()       {}
+
+// Capabilities returns BlockinessCapabilities.
+func (b *Blockiness) Capabilities() video.Capabilities {
+	return BlockinessCapabilities()
+}
+
+// SupportsLayout reports whether frames with layout have a planar first
+// plane.
+func (b *Blockiness) SupportsLayout(layout video.PlaneLayout) bool {
+	return supportsNoReference(layout)
+}
+
+// CheckBitDepths accepts any bit depths, as only the distortion is read.
+func (b *Blockiness) CheckBitDepths(_, _ int) error { return nil }
+
+// Compute scores the blockiness of the distorted frame.
+func (b *Blockiness) Compute(_, frame video.Frame) (map[string]float64,
+	error) {
+	samples := b.luma.load(&frame)
+	defer b.luma.release(samples)
+
+	width, height := b.luma.width, b.luma.height
+	var boundary, interior float64
+	var boundaries, interiors int
+
+	for y := range height {
+		row := samples[y*width : (y+1)*width]
+		for x := 1; x < width; x++ {
+			d := math.Abs(float64(row[x] - row[x-1]))
+			if x%blockSize == 0 {
+				boundary += d
+				boundaries++
+			} else {
+				interior += d
+				interiors++
+			}
+		}
+
+		if y == 0 {
+			continue
+		}
+		above := samples[(y-1)*width : y*width]
+		for x := range row {
+			d := math.Abs(float64(row[x] - above[x]))
+			if y%blockSize == 0 {
+				boundary += d
+				boundaries++
+			} else {
+				interior += d
+				interiors++
+			}
+		}
+	}
+
+	score := 1.0
+	if boundaries > 0 && interiors > 0 {
+		score = (boundary/float64(boundaries) + blockinessFloor) /
+			(interior/float64(interiors) + blockinessFloor)
+	}
+	return map[string]float64{BlockinessName: score}, nil
+}
+
+// Banding scores the false contours that too few code values or heavy
+// quantization leave in smooth gradients such as skies, as the percentage of
+// the neighbouring samples in smooth areas that step by a few 8-bit code
+// values between flat bands. Steps are counted along rows and columns, so
+// bands of any orientation count. It needs no reference, the reference frame
+// is ignored.
+type Banding struct {
+	luma *lumaPlane
+}
+
+// NewBanding returns Banding for frames described by props, which must use a
+// planar pixel format.
+func NewBanding(props *video.ColorProperties) (*Banding, error) {
+	luma, err := newLumaPlane(props)
+	if err != nil {
+		return nil, fmt.Errorf("banding: %w", err)
+	}
+	return &Banding{luma: luma}, nil
+}
+
+func (b *Banding) Name() string { return BandingName }
+func (b *Banding) Close()       {}
+
+// Capabilities returns BandingCapabilities.
+func (b *Banding) Capabilities() video.Capabilities {
+	return BandingCapabilities()
+}
+
+// SupportsLayout reports whether frames with layout have a planar first
+// plane.
+func (b *Banding) SupportsLayout(layout video.PlaneLayout) bool {
+	return supportsNoReference(layout)
+}
+
+// CheckBitDepths accepts any bit depths, as only the distortion is read.
+func (b *Banding) CheckBitDepths(_, _ int) error { return nil }
+
+// Compute scores the banding of the distorted frame.
+func (b *Banding) Compute(_, frame video.Frame) (map[string]float64,
+	error) {
+	samples := b.luma.load(&frame)
+	defer b.luma.release(samples)
+
+	width, height := b.luma.width, b.luma.height
+	var edges, smooth int
+	diffs := make([]float32, max(width, height))
+
+	for y := range height {
+		e, s := countBands(samples[y*width:], 1, width, diffs)
+		edges, smooth = edges+e, smooth+s
+	}
+	for x := range width {
+		e, s := countBands(samples[x:], width, height, diffs)
+		edges, smooth = edges+e, smooth+s
+	}
+
+	score := 0.0
+	if smooth > 0 {
+		score = 100 * float64(edges) / float64(smooth)
+	}
+	return map[string]float64{BandingName: score}, nil
+}
+
+// countBands returns the band edges and the smooth positions among the n
+// samples of line, step apart. A position between two samples is smooth when
+// no difference within bandWidth of it exceeds bandStepMax, and a band edge
+// when it is smooth, its own difference at least bandStepMin and every other
+// difference within bandWidth below it.
+func countBands(line []float32, step, n int, diffs []float32) (edges,
+	smooth int) {
+	diffs = diffs[:n-1]
+	for i := range diffs {
+		diffs[i] = float32(math.Abs(float64(line[(i+1)*step] -
+			line[i*step])))
+	}
+
+	for i := bandWidth; i < len(diffs)-bandWidth; i++ {
+		isSmooth, flat := true, true
+		for j := i - bandWidth; j <= i+bandWidth; j++ {
+			if diffs[j] > bandStepMax {
+				isSmooth = false
+				break
+			}
+			if j != i && diffs[j] >= bandStepMin {
+				flat = false
+			}
+		}
+		if !isSmooth {
+			continue
+		}
+		smooth++
+		if flat && diffs[i] >= bandStepMin {
+			edges++
+		}
+	}
+	return edges, smooth
+}
+
+// supportsNoReference reports whether the no-reference metrics read frames
+// with layout.
+func supportsNoReference(layout video.PlaneLayout) bool {
+	return layout != video.LayoutPacked && layout != video.LayoutUnknown
+}
@@ -0,0 +1,92 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import (
+	"math"
+	"strings"
+)
+
+// NormSuffix is appended to a score key to form the key of its normalized
+// score, e.g. "Ssimulacra2_norm".
+const NormSuffix = "_norm"
+
+// Normalize maps a score onto a common 0-100 quality scale where 100 is a
+// perfect match, so metrics can share an axis on dashboards. It returns false
+// for keys without a known transform. Scores keyed by MultiResolutionKey are
+// normalized like those of their metric. The transforms are:
+//
+//   - SSIMULACRA2 is already a 0-100 quality score and is clamped to that
+//     range, as heavy distortion can score below 0.
+//   - Butteraugli distances of every norm are mapped through the inverse of
+//     cjxl's quality to distance mapping, distance = 0.1 + (100-q)*0.09 for
+//     q >= 30 and 53/3000*q^2 - 23/20*q + 25 below. A distance of 1, about
+//     one just noticeable difference, becomes 90 and 6.4 becomes 30.
+//   - CVVDP scores in JOD, including those of a CVVDPSweep, are scaled by 10
+//     so the perfect 10 JOD becomes 100, and clamped at 0.
+//
+// The scales only line up roughly. Each transform keeps the order of scores
+// within its metric, but the same value from two metrics does not promise the
+// same visual quality.
+func Normalize(key string, score float64) (float64, bool) {
+	switch {
+	case key == SSIMulacra2Name ||
+		strings.HasPrefix(key, MultiResolutionKey(SSIMulacra2Name, "")):
+		return clampQuality(score), true
+	case strings.HasPrefix(key, ButteraugliName):
+		return butteraugliQuality(score), true
+	case key == CVVDPName || strings.HasPrefix(key, CVVDPSweepKey("")):
+		return clampQuality(score * 10), true
+	default:
+		return 0, false
+	}
+}
+
+// AddNormalizedScores adds the normalized per-frame scores of every key of
+// scores with a known transform under key+NormSuffix, see Normalize. Keys that
+// already end in NormSuffix are skipped.
+func AddNormalizedScores(scores map[string][]float64) {
+	normalized := make(map[string][]float64)
+
+	for key, values := range scores {
+		if strings.HasSuffix(key, NormSuffix) {
+			continue
+		}
+		if _, ok := Normalize(key, 0); !ok {
+			continue
+		}
+
+		out := make([]float64, len(values))
+		for i, v := range values {
+			out[i], _ = Normalize(key, v)
+		}
+		normalized[key+NormSuffix] = out
+	}
+
+	for key, values := range normalized {
+		scores[key] = values
+	}
+}
+
+// butteraugliQuality inverts cjxl's quality to distance mapping.
+func butteraugliQuality(distance float64) float64 {
+	const a, b = 53.0 / 3000.0, -23.0 / 20.0
+
+	switch {
+	case distance <= 0.1:
+		return 100
+	case distance <= 6.4:
+		return 100 - (distance-0.1)/0.09
+	case distance >= 25:
+		return 0
+	}
+
+	// The smaller root of a*q^2 + b*q + 25 - distance, the branch of the
+	// parabola that decreases from 25 at q = 0 to 6.4 at q = 30.
+	return (-b - math.Sqrt(b*b-4*a*(25-distance))) / (2 * a)
+}
+
+// clampQuality clamps a score to the range [0, 100].
+func clampQuality(score float64) float64 {
+	return min(max(score, 0), 100)
+}
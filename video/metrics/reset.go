@@ -0,0 +1,27 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+import "fmt"
+
+// Reset discards the temporal history of every worker, so the next frame
+// pair is scored as the start of a new video.
+func (h *CVVDPHandler) Reset() error {
+	for _, handler := range h.handlerList {
+		if code := handler.Reset(); !code.IsNone() {
+			return fmt.Errorf("%s temporal reset failed: %w", CVVDPName,
+				code.GetError())
+		}
+	}
+	return nil
+}
+
+// Reset discards the temporal history of every condition.
+func (s *CVVDPSweep) Reset() error {
+	for i, handler := range s.handlers {
+		if err := handler.Reset(); err != nil {
+			return fmt.Errorf("%s: %w", s.keys[i], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build cgo && !nocgo
+
+package metrics
+
+// Sequential returns true while a distortion map is being written, as the
+// maps must reach the callback in frame order.
+func (h *ButterHandler) Sequential() bool { return h.callback != nil }
+
+// Sequential returns true with temporal weighting, which keeps the history of
+// previous frames in its worker, or while a distortion map is being written.
+func (h *CVVDPHandler) Sequential() bool {
+	return h.useTemporal || h.callback != nil
+}
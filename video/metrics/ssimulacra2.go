@@ -1,6 +1,9 @@
+//go:build cgo && !nocgo
+
 package metrics
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/GreatValueCreamSoda/gometrics/blockingpool"
@@ -23,22 +26,58 @@ var SSIMulacra2Name string = "Ssimulacra2"
 type Ssimu2Handler struct {
 	pool        blockingpool.BlockingPool[*vship.SSIMU2Handler]
 	handlerList []*vship.SSIMU2Handler
+	// cacheKey describes the settings of the handler, see CacheKey.
+	cacheKey string
 }
 
 // Name returns the metric identifier used as the score key.
 func (h *Ssimu2Handler) Name() string { return SSIMulacra2Name }
 
-// NewSSIMU2Handler constructs a Ssimu2Handler with the requested number of
-// worker instances.
-//
-// colorA and colorB define the colorspaces of the reference and test images.
-func NewSSIMU2Handler(numWorkers int, colorA, colorB *vship.Colorspace) (
-	video.Metric, error) {
+// CacheKey describes the settings that affect the scores.
+func (h *Ssimu2Handler) CacheKey() string { return h.cacheKey }
+
+// SSIMU2Options configures a Ssimu2Handler.
+type SSIMU2Options struct {
+	// The number of worker instances frames are scored on concurrently.
+	// Defaults to 1.
+	Workers int
+	// The colorspaces of the reference and the distorted frames.
+	Reference, Distortion *vship.Colorspace
+}
+
+func (o *SSIMU2Options) setDefaults() {
+	if o.Workers == 0 {
+		o.Workers = 1
+	}
+}
+
+// Validate returns an error describing the first invalid option. Zero values
+// are valid where a default applies.
+func (o *SSIMU2Options) Validate() error {
+	switch {
+	case o.Reference == nil || o.Distortion == nil:
+		return errors.New("ssimulacra2 needs the colorspaces of both videos")
+	case o.Workers < 0:
+		return fmt.Errorf("invalid number of ssimulacra2 workers %d",
+			o.Workers)
+	}
+	return nil
+}
+
+// NewSSIMU2Handler constructs a Ssimu2Handler with one worker instance per
+// opts.Workers.
+func NewSSIMU2Handler(opts SSIMU2Options) (video.Metric, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults()
+
 	var h Ssimu2Handler
-	h.pool = blockingpool.NewBlockingPool[*vship.SSIMU2Handler](numWorkers)
+	h.pool = blockingpool.NewBlockingPool[*vship.SSIMU2Handler](opts.Workers)
+	h.cacheKey = vshipCacheKey("", opts.Reference, opts.Distortion)
 
-	for range numWorkers {
-		err := h.createWorker(colorA, colorB)
+	for range opts.Workers {
+		err := h.createWorker(opts.Reference, opts.Distortion)
 		if err == nil {
 			continue
 		}
@@ -71,6 +110,15 @@ func (h *Ssimu2Handler) DistortionMap() ([]float32, int, int, error) {
 	return nil, 0, 0, ErrDistortionMapUnsupported
 }
 
+// GPUStats sums the vship calls of every worker.
+func (h *Ssimu2Handler) GPUStats() video.GPUStats {
+	var stats video.GPUStats
+	for _, handler := range h.handlerList {
+		stats = stats.Add(gpuStats(handler.Stats()))
+	}
+	return stats
+}
+
 // Close releases all underlying SSIMULACRA2 handlers.
 //
 // After calling Close, the Ssimu2Handler should be considered unusable. This
@@ -96,8 +144,8 @@ func (h *Ssimu2Handler) Compute(a, b video.Frame) (map[string]float64,
 	handler := h.pool.Get()
 	defer h.pool.Put(handler)
 
-	score, code := handler.ComputeScore(a.Data(), b.Data(), a.LineSizes(),
-		b.LineSizes())
+	score, code := handler.ComputeScore(a.ColorPlanes(), b.ColorPlanes(),
+		a.ColorLineSizes(), b.ColorLineSizes())
 
 	if !code.IsNone() {
 		return nil, fmt.Errorf("%s computation failed: %v", SSIMulacra2Name,
@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// handshakeTimeout is how long Open waits for a plugin to answer the
+// handshake before killing it.
+const handshakeTimeout = 10 * time.Second
+
+// Client is a running plugin. It implements video.Metric,
+// video.ConcurrencyLimiter and video.CapabilityReporter by forwarding every
+// call to the plugin process, and is safe for concurrent use.
+type Client struct {
+	info   Info
+	cmd    *exec.Cmd
+	client *rpc.Client
+
+	closeOnce sync.Once
+}
+
+// Open launches the plugin executable at path and initializes its metric for
+// sources with the color properties a and b. The plugin's standard error is
+// passed through to os.Stderr. Close the Client to stop the plugin.
+func Open(path string, a, b *video.ColorProperties) (*Client, error) {
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), cookieKey+"="+cookieValue)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", path, err)
+	}
+
+	c := &Client{cmd: cmd}
+
+	// Killing a plugin that does not answer in time ends the read.
+	timer := time.AfterFunc(handshakeTimeout, func() { cmd.Process.Kill() })
+	reader := bufio.NewReader(stdout)
+	line, err := reader.ReadString('\n')
+	if !timer.Stop() {
+		c.kill()
+		return nil, fmt.Errorf("%w: %s did not answer within %v",
+			ErrHandshake, path, handshakeTimeout)
+	}
+	if err != nil || line != handshake(ProtocolVersion) {
+		c.kill()
+		return nil, fmt.Errorf("%w: %s answered %q, expected protocol "+
+			"version %d", ErrHandshake, path, line, ProtocolVersion)
+	}
+
+	c.client = rpc.NewClient(pipeConn{reader, stdin})
+
+	if err = c.client.Call(serviceName+".Info", Empty{}, &c.info); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+
+	err = c.client.Call(serviceName+".Init", InitArgs{*a, *b}, &Empty{})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("plugin %s: init failed: %w", c.info.Name,
+			err)
+	}
+
+	return c, nil
+}
+
+// pipeConn joins the standard output and input pipes of a plugin into one
+// connection.
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// Name returns the name the plugin reported for its metric.
+func (c *Client) Name() string { return c.info.Name }
+
+// MaxConcurrency returns the limit the plugin reported, see
+// video.ConcurrencyLimiter.
+func (c *Client) MaxConcurrency() int { return c.info.MaxConcurrency }
+
+// Capabilities returns the capabilities the plugin reported, or unbounded
+// scores of unknown direction if it reported none, see
+// video.CapabilityReporter.
+func (c *Client) Capabilities() video.Capabilities {
+	if c.info.Capabilities != nil {
+		return *c.info.Capabilities
+	}
+	return video.Capabilities{MinScore: math.Inf(-1), MaxScore: math.Inf(1)}
+}
+
+// Compute sends the frame pair to the plugin and returns its scores.
+func (c *Client) Compute(a, b video.Frame) (map[string]float64, error) {
+	var reply ComputeReply
+	err := c.client.Call(serviceName+".Compute",
+		ComputeArgs{newWireFrame(&a), newWireFrame(&b)}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Scores, nil
+}
+
+// Close closes the plugin's metric and waits for the plugin to exit. Calling
+// Close more than once does nothing.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		err := c.client.Call(serviceName+".Close", Empty{}, &Empty{})
+		// Closing the connection closes the plugin's standard input, which
+		// ends Serve.
+		c.client.Close()
+		if err != nil {
+			c.kill()
+			return
+		}
+		c.cmd.Wait()
+	})
+}
+
+// kill stops the plugin process without waiting for it to finish its work.
+func (c *Client) kill() {
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+}
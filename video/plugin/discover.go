@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Discover returns the path of every plugin executable in dirs keyed by its
+// metric name, the file name without ExecutablePrefix. A plugin found in an
+// earlier directory hides plugins of the same name in later ones.
+// Directories that do not exist are skipped.
+func Discover(dirs ...string) (map[string]string, error) {
+	plugins := make(map[string]string)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			name, ok := metricName(entry)
+			if !ok {
+				continue
+			}
+			if _, found := plugins[name]; !found {
+				plugins[name] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+
+	return plugins, nil
+}
+
+// metricName returns the metric name of entry if it is a plugin executable.
+func metricName(entry fs.DirEntry) (string, bool) {
+	name, ok := strings.CutPrefix(entry.Name(), ExecutablePrefix)
+	if !ok || name == "" || entry.IsDir() {
+		return "", false
+	}
+
+	if runtime.GOOS == "windows" {
+		return strings.CutSuffix(name, ".exe")
+	}
+
+	info, err := entry.Info()
+	if err != nil || info.Mode()&0o111 == 0 {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,20 @@
+// Package plugin lets third parties ship metrics as separate executables that
+// gometrics discovers and launches at runtime, so a metric can bring its own
+// dependencies, including cgo or a GPU runtime, without them being linked
+// into gometrics and the other way around.
+//
+// A plugin is an executable named ExecutablePrefix followed by the metric
+// name, e.g. gometrics-metric-psnr, that calls Serve from its main function.
+// The host starts it with Open, which checks the handshake and returns a
+// Client implementing video.Metric. Discover finds the plugins of a set of
+// directories.
+//
+// The host and the plugin talk net/rpc with gob encoding over the plugin's
+// standard input and output, so the plugin must not write anything else to
+// standard output. Its standard error is passed through to the host. Every
+// change to the RPC methods or their arguments bumps ProtocolVersion, and the
+// host refuses plugins built against another version.
+//
+// Plugins built with the nocgo build tag only depend on the pure Go parts of
+// this module.
+package plugin
@@ -0,0 +1,97 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// ProtocolVersion is the version of the RPC protocol between the host and
+// its plugins. The host refuses plugins reporting any other version.
+const ProtocolVersion = 1
+
+// ExecutablePrefix starts the file name of every plugin executable. The rest
+// of the name is the metric name the plugin is selected by.
+const ExecutablePrefix = "gometrics-metric-"
+
+// cookieKey and cookieValue are set in the environment of plugins launched by
+// the host. Serve refuses to run without them, which catches plugins started
+// by hand.
+const (
+	cookieKey   = "GOMETRICS_PLUGIN_COOKIE"
+	cookieValue = "9d3c5e0a7b2f4186"
+)
+
+// serviceName is the name the plugin registers its RPC service under.
+const serviceName = "Plugin"
+
+var (
+	// ErrHandshake is returned by Open when the executable does not answer
+	// like a plugin of this ProtocolVersion, or not in time.
+	ErrHandshake = errors.New("plugin handshake failed")
+	// ErrNotLaunched is returned by Serve when the executable was not
+	// launched by a host.
+	ErrNotLaunched = errors.New("plugins are launched by gometrics, not " +
+		"run directly")
+)
+
+// Info describes the metric of a plugin.
+type Info struct {
+	// The name of the metric, as returned by video.Metric.Name.
+	Name string
+	// The most frame pairs the plugin computes at once, see
+	// video.ConcurrencyLimiter. Below 1 means no limit.
+	MaxConcurrency int
+	// The capabilities of the metric if it implements
+	// video.CapabilityReporter, nil otherwise. Plugins built before it was
+	// added leave it nil.
+	Capabilities *video.Capabilities
+}
+
+// InitArgs are the color properties of the compared sources, sent once
+// before any frame.
+type InitArgs struct {
+	A, B video.ColorProperties
+}
+
+// WireFrame is the encoding of a video.Frame on the wire. Frame metadata is
+// not sent.
+type WireFrame struct {
+	Data      [video.MaxPlanes][]byte
+	LineSizes [video.MaxPlanes]int
+}
+
+func newWireFrame(frame *video.Frame) WireFrame {
+	w := WireFrame{LineSizes: frame.LineSizes()}
+	for i := range frame.NumPlanes() {
+		w.Data[i] = frame.PlaneData(i)
+	}
+	return w
+}
+
+func (w *WireFrame) frame() (video.Frame, error) {
+	frame, err := video.NewFrame(w.Data, w.LineSizes)
+	if err != nil {
+		return video.Frame{}, fmt.Errorf("malformed frame: %w", err)
+	}
+	return frame, nil
+}
+
+// ComputeArgs and ComputeReply are the arguments and result of one Compute
+// call.
+type ComputeArgs struct {
+	A, B WireFrame
+}
+
+type ComputeReply struct {
+	Scores map[string]float64
+}
+
+// Empty is the argument or reply of calls without one.
+type Empty struct{}
+
+// handshake is the first line a plugin writes to its standard output.
+func handshake(version int) string {
+	return fmt.Sprintf("gometrics-plugin %d\n", version)
+}
@@ -0,0 +1,93 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Metric is the metric a plugin serves. Compute is called concurrently for
+// different frame pairs, up to the limit of video.ConcurrencyLimiter if the
+// metric implements it.
+type Metric interface {
+	video.Metric
+	// Init is called once with the color properties of the compared
+	// sources, before any frame pair.
+	Init(a, b *video.ColorProperties) error
+}
+
+// Serve serves metric to the host that launched the executable over its
+// standard input and output. It returns once the host closes the plugin,
+// after closing metric. Call it from the plugin's main function.
+//
+// Serve returns ErrNotLaunched if the executable was not started by a host.
+func Serve(metric Metric) error {
+	if os.Getenv(cookieKey) != cookieValue {
+		return ErrNotLaunched
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName,
+		&service{metric: metric}); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(os.Stdout,
+		handshake(ProtocolVersion)); err != nil {
+		return err
+	}
+
+	server.ServeConn(stdio{os.Stdin, os.Stdout})
+	return nil
+}
+
+// stdio joins the standard input and output of the plugin into one
+// connection.
+type stdio struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// service holds the RPC methods of a plugin. Every method is part of the
+// protocol, see ProtocolVersion.
+type service struct {
+	metric Metric
+}
+
+func (s *service) Info(_ Empty, info *Info) error {
+	info.Name = s.metric.Name()
+	if limiter, ok := s.metric.(video.ConcurrencyLimiter); ok {
+		info.MaxConcurrency = limiter.MaxConcurrency()
+	}
+	if reporter, ok := s.metric.(video.CapabilityReporter); ok {
+		capabilities := reporter.Capabilities()
+		info.Capabilities = &capabilities
+	}
+	return nil
+}
+
+func (s *service) Init(args InitArgs, _ *Empty) error {
+	return s.metric.Init(&args.A, &args.B)
+}
+
+func (s *service) Compute(args ComputeArgs, reply *ComputeReply) error {
+	a, err := args.A.frame()
+	if err != nil {
+		return fmt.Errorf("frame a: %w", err)
+	}
+	b, err := args.B.frame()
+	if err != nil {
+		return fmt.Errorf("frame b: %w", err)
+	}
+
+	reply.Scores, err = s.metric.Compute(a, b)
+	return err
+}
+
+func (s *service) Close(_ Empty, _ *Empty) error {
+	s.metric.Close()
+	return nil
+}
@@ -0,0 +1,179 @@
+package plugin_test
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/plugin"
+)
+
+// The test binary runs itself as the plugin. modeKey selects what the
+// launched copy does instead of running the tests, and closedKey names the
+// file it creates when its metric is closed.
+const (
+	modeKey   = "GOMETRICS_PLUGIN_TEST_MODE"
+	closedKey = "GOMETRICS_PLUGIN_TEST_CLOSED"
+)
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(modeKey) {
+	case "serve":
+		if err := plugin.Serve(&checksum{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "garbage":
+		fmt.Println("not a plugin")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// checksum scores a frame pair by the CRC of the bytes and line sizes of
+// either frame, so the test sees whether they survived the wire.
+type checksum struct {
+	width int
+}
+
+func (c *checksum) Name() string        { return "Checksum" }
+func (c *checksum) MaxConcurrency() int { return 3 }
+
+func (c *checksum) Capabilities() video.Capabilities {
+	return video.Capabilities{MinScore: 0, MaxScore: 1 << 32,
+		Direction: video.LowerIsBetter}
+}
+
+func (c *checksum) Init(a, b *video.ColorProperties) error {
+	if a.Width != b.Width {
+		return errors.New("frame widths differ")
+	}
+	c.width = a.Width
+	return nil
+}
+
+func (c *checksum) Compute(a, b video.Frame) (map[string]float64, error) {
+	return map[string]float64{"A": frameCRC(&a), "B": frameCRC(&b),
+		"Width": float64(c.width)}, nil
+}
+
+func (c *checksum) Close() {
+	if path := os.Getenv(closedKey); path != "" {
+		os.WriteFile(path, nil, 0o644)
+	}
+}
+
+func frameCRC(frame *video.Frame) float64 {
+	crc := crc32.NewIEEE()
+	for i := range frame.NumPlanes() {
+		crc.Write(frame.PlaneData(i))
+		fmt.Fprint(crc, frame.PlaneLineSize(i))
+	}
+	return float64(crc.Sum32())
+}
+
+var yuv420Props = video.ColorProperties{Width: 4, Height: 2,
+	PixelFormat: pixfmts.PixFmtYUV420P}
+
+// testFrame returns a 4x2 4:2:0 frame whose samples count up from first.
+func testFrame(t *testing.T, first byte) video.Frame {
+	var data [video.MaxPlanes][]byte
+	sizes := [3]int{8, 2, 2}
+	for i, size := range sizes {
+		data[i] = make([]byte, size)
+		for j := range data[i] {
+			data[i][j] = first
+			first++
+		}
+	}
+
+	frame, err := video.NewFrame(data, [video.MaxPlanes]int{4, 2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// testBinary returns the path of the running test binary, to launch as the
+// plugin in the given mode.
+func testBinary(t *testing.T, mode string) string {
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(modeKey, mode)
+	return path
+}
+
+func Test_Client(t *testing.T) {
+	closed := filepath.Join(t.TempDir(), "closed")
+	t.Setenv(closedKey, closed)
+
+	client, err := plugin.Open(testBinary(t, "serve"), &yuv420Props,
+		&yuv420Props)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := client.Name(); name != "Checksum" {
+		t.Errorf("Name() = %q, want Checksum", name)
+	}
+	if n := client.MaxConcurrency(); n != 3 {
+		t.Errorf("MaxConcurrency() = %d, want 3", n)
+	}
+	if got := client.Capabilities(); got.MaxScore != 1<<32 ||
+		got.Direction != video.LowerIsBetter {
+		t.Errorf("Capabilities() = %+v, want those of the plugin", got)
+	}
+
+	a, b := testFrame(t, 0), testFrame(t, 100)
+	scores, err := client.Compute(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"A": frameCRC(&a), "B": frameCRC(&b),
+		"Width": 4}
+	for key, score := range want {
+		if scores[key] != score {
+			t.Errorf("score %s = %v, want %v", key, scores[key], score)
+		}
+	}
+
+	client.Close()
+	if _, err := os.Stat(closed); err != nil {
+		t.Errorf("plugin metric was not closed: %v", err)
+	}
+	// Closing again does nothing.
+	client.Close()
+}
+
+func Test_OpenInitError(t *testing.T) {
+	other := yuv420Props
+	other.Width = 8
+
+	_, err := plugin.Open(testBinary(t, "serve"), &yuv420Props, &other)
+	if err == nil {
+		t.Fatal("Open succeeded although Init failed")
+	}
+}
+
+func Test_OpenHandshake(t *testing.T) {
+	_, err := plugin.Open(testBinary(t, "garbage"), &yuv420Props,
+		&yuv420Props)
+	if !errors.Is(err, plugin.ErrHandshake) {
+		t.Errorf("got %v, want %v", err, plugin.ErrHandshake)
+	}
+}
+
+func Test_ServeNotLaunched(t *testing.T) {
+	if err := plugin.Serve(&checksum{}); !errors.Is(err,
+		plugin.ErrNotLaunched) {
+		t.Errorf("got %v, want %v", err, plugin.ErrNotLaunched)
+	}
+}
@@ -0,0 +1,10 @@
+// Package results is the data model of the scores of a comparison, so
+// programs building on gometrics exchange typed results instead of maps of
+// score slices.
+//
+// A Run holds the scores of every compared frame pair as FrameScores, with
+// the sources they came from. Summaries and SceneSummaries pool them per
+// metric, over the run and per scene, into MetricSummaries. File is the JSON
+// results file the gometrics command writes with --output, NewFile encodes a
+// Run into one and Read loads one back into a Run.
+package results
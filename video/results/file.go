@@ -0,0 +1,268 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/analysis"
+)
+
+// File is the JSON results file the gometrics command writes to --output.
+// Besides the scores it records everything needed to tell what was compared
+// and to reproduce the run.
+//
+// gometrics has no randomized steps, so there are no seeds to record. With
+// --deterministic the file has no timestamp or resource usage and is
+// byte-identical across runs on the same inputs, flags, libraries and GPU.
+type File struct {
+	// Left out with --deterministic.
+	Created       *time.Time `json:"created,omitempty"`
+	Deterministic bool       `json:"deterministic"`
+	// The command line as given and the effective value of every flag.
+	Args    []string          `json:"args"`
+	Options map[string]string `json:"options"`
+
+	Libraries Libraries `json:"libraries"`
+	// The library implementing each metric, with its version.
+	MetricVersions map[string]string `json:"metric_versions"`
+
+	// Left out with --no-reference.
+	Reference  *Source `json:"reference,omitempty"`
+	Distortion Source  `json:"distortion"`
+
+	// The resolution --proxy scored at. The Width and Height of the sources
+	// are those of their frames after halving them towards it.
+	Proxy *Proxy `json:"proxy,omitempty"`
+	// The start offset --start-offset auto compensated by skipping frames.
+	StartOffset *StartOffset `json:"start_offset,omitempty"`
+
+	// What the run cost. Left out with --deterministic.
+	Resources *Resources `json:"resources,omitempty"`
+
+	// Per-frame scores of every metric in frame order.
+	Scores map[string][]float64 `json:"scores"`
+	// The scores of every metric pooled over the run, leaving out the
+	// ExcludedFrames, and per scene of --scene-list.
+	Summaries []MetricSummary `json:"summaries"`
+	Scenes    []SceneSummary  `json:"scenes,omitempty"`
+	// The reference frame of every score when --two-pass left frames
+	// unscored or --frame-map reordered them.
+	Frames []int `json:"frames,omitempty"`
+	// The regions --two-pass scored densely, ranked by RegionMetric.
+	Regions      []analysis.Region `json:"regions,omitempty"`
+	RegionMetric string            `json:"region_metric,omitempty"`
+	// Events found by --detect-cadence and --black-freeze.
+	Events []analysis.Event `json:"events,omitempty"`
+	// The audio/video sync estimated by --av-sync, one point per window.
+	Sync []analysis.SyncPoint `json:"sync,omitempty"`
+	// Per-frame complexity of the reference from --complexity, in frame
+	// order.
+	Complexity []analysis.Complexity `json:"complexity,omitempty"`
+	// Per-frame metadata of both sources from --frame-metadata, in frame
+	// order.
+	FrameMetadata *FrameMetadata `json:"frame_metadata,omitempty"`
+	// Statistics of every metric by picture type of the distortion from
+	// --frame-metadata, keyed by metric and then picture type.
+	ScoresByPictType map[string]map[string]Stats `json:"scores_by_pict_type,omitempty"`
+	// Scores of every metric per GOP of the distortion from --worst-gops,
+	// in frame order.
+	GOPs map[string][]analysis.GOP `json:"gops,omitempty"`
+	// The --worst-segments of every metric, in the order of the durations.
+	WorstSegments map[string][]analysis.Segment `json:"worst_segments,omitempty"`
+	// Scores of every metric per chapter from --chapters, in chapter order.
+	Chapters map[string][]analysis.ChapterScore `json:"chapters,omitempty"`
+	// The loudness and clipping of every audio stream of both files from
+	// --audio-qc, paired by stream order.
+	Audio []analysis.StreamLoudness `json:"audio,omitempty"`
+	// Frames left out of the summary by --black-freeze exclude. Scores still
+	// hold every frame.
+	ExcludedFrames []int `json:"excluded_frames,omitempty"`
+	// Frames given a perfect score by --quick-reject-psnr instead of running
+	// the metrics.
+	ImputedFrames []int `json:"imputed_frames,omitempty"`
+	// Frames that had subtitles masked by --subtitle-mask before scoring.
+	MaskedFrames []int `json:"masked_frames,omitempty"`
+	// Frames of both files --frame-map left out of the comparison.
+	UnmappedFrames *UnmappedFrames `json:"unmapped_frames,omitempty"`
+}
+
+// Libraries holds the versions of the libraries a run used.
+type Libraries struct {
+	Vship        string `json:"vship"`
+	VshipBackend string `json:"vship_backend"`
+	FFms2        string `json:"ffms2"`
+	// The name of the GPU vship runs on.
+	Device string `json:"device"`
+}
+
+// Proxy records the --proxy resolution the sources were scored at.
+type Proxy struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Scale is the proxy width over the reference width.
+	Scale float64 `json:"scale"`
+}
+
+// StartOffset is the difference in start time of the sources that
+// --start-offset auto compensated.
+type StartOffset struct {
+	// Seconds the distortion starts after the reference, negative if it
+	// starts first.
+	Seconds float64 `json:"seconds"`
+	// Frames skipped at the start of the video that starts first.
+	Frames int `json:"frames"`
+}
+
+// Resources is what a run cost, for capacity planning and spotting memory
+// regressions between versions. Times are in seconds and sizes in bytes.
+type Resources struct {
+	WallTime float64 `json:"wall_time"`
+	// CPU time of the process in user and kernel mode, over all threads.
+	UserTime   float64 `json:"user_time"`
+	SystemTime float64 `json:"system_time"`
+	// The largest resident set size of the process.
+	PeakRSS int64 `json:"peak_rss"`
+	// The most pinned host memory the frame buffers held at once.
+	PeakPinned int64 `json:"peak_pinned"`
+	// The most VRAM the process used in the samples nvidia-smi took. Left
+	// out when it is not available, as for the HIP backend.
+	PeakVRAM *int64 `json:"peak_vram,omitempty"`
+	// The throughput, clocks and temperature of the GPU sampled with
+	// nvidia-smi or rocm-smi, and the stretches it spent throttled. Left out
+	// when neither is available.
+	Timeline   []analysis.GPUSample     `json:"timeline,omitempty"`
+	Throttling []analysis.ThrottleEvent `json:"throttling,omitempty"`
+}
+
+// FrameMetadata holds the metadata of every compared frame of both sources.
+type FrameMetadata struct {
+	Reference  []video.FrameMetadata `json:"reference"`
+	Distortion []video.FrameMetadata `json:"distortion"`
+}
+
+// Stats are the summary statistics of a metric over a set of frames, in the
+// space the metric is displayed in.
+type Stats struct {
+	Frames  int     `json:"frames"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Average float64 `json:"average"`
+	Median  float64 `json:"median"`
+	StdDev  float64 `json:"stddev"`
+	// Direction is the direction of the scores and Worst the worst score in
+	// that direction, both unset if the direction is unknown.
+	Direction string   `json:"direction,omitempty"`
+	Worst     *float64 `json:"worst,omitempty"`
+}
+
+// UnmappedFrames lists the frames --frame-map left out of the comparison.
+type UnmappedFrames struct {
+	Reference  []int `json:"reference"`
+	Distortion []int `json:"distortion"`
+}
+
+// NewFile returns the results file of run. The fields a Run does not hold,
+// such as the summaries, are left for the caller to fill in.
+func NewFile(run *Run) *File {
+	file := &File{
+		Created:        run.Created,
+		Options:        run.Options,
+		MetricVersions: run.MetricVersions,
+		Reference:      run.Reference,
+		Distortion:     run.Distortion,
+		Scores:         run.Scores(),
+		ExcludedFrames: run.Excluded,
+	}
+
+	// Frames is only written when the scores are not of frames 0 to n-1.
+	for i, frame := range run.FrameNumbers() {
+		if frame != i {
+			file.Frames = run.FrameNumbers()
+			break
+		}
+	}
+	return file
+}
+
+// Run returns the run the file holds. Fields a Run does not hold, such as
+// events or GOP scores, are left out.
+func (f *File) Run() (*Run, error) {
+	if len(f.Scores) == 0 {
+		return nil, errors.New("results hold no scores")
+	}
+	for key, values := range f.Scores {
+		if f.Frames != nil && len(values) != len(f.Frames) {
+			return nil, fmt.Errorf("%s has %d scores for %d frames", key,
+				len(values), len(f.Frames))
+		}
+	}
+
+	run := NewRun(f.Scores, f.Frames)
+	run.Created = f.Created
+	run.Options = f.Options
+	run.MetricVersions = f.MetricVersions
+	run.Reference, run.Distortion = f.Reference, f.Distortion
+	run.Excluded = f.ExcludedFrames
+	return run, nil
+}
+
+// Encode writes f as indented JSON.
+func (f *File) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(f)
+}
+
+// Decode reads a results file written by the gometrics command with
+// --output.
+func Decode(r io.Reader) (*File, error) {
+	var file File
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// DecodeFile reads the results file at path, see Decode.
+func DecodeFile(path string) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	results, err := Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// Read reads the run of a results file written by the gometrics command
+// with --output.
+func Read(r io.Reader) (*Run, error) {
+	file, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return file.Run()
+}
+
+// ReadFile reads the run of the results file at path, see Read.
+func ReadFile(path string) (*Run, error) {
+	file, err := DecodeFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := file.Run()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return run, nil
+}
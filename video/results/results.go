@@ -0,0 +1,185 @@
+package results
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Run is the outcome of one comparison.
+type Run struct {
+	// When the comparison ran, nil if unknown, e.g. for deterministic
+	// results files.
+	Created *time.Time `json:"created,omitempty"`
+	// The effective value of every option of the run, keyed by flag name.
+	Options map[string]string `json:"options,omitempty"`
+	// The library implementing each score key, with its version.
+	MetricVersions map[string]string `json:"metric_versions,omitempty"`
+
+	// Reference is nil for runs without one, e.g. with --no-reference.
+	Reference  *Source `json:"reference,omitempty"`
+	Distortion Source  `json:"distortion"`
+
+	// Frames holds the scored frame pairs in frame order.
+	Frames []FrameScore `json:"frames"`
+	// Excluded lists frames scored but left out of summaries, e.g. black
+	// or frozen frames.
+	Excluded []int `json:"excluded,omitempty"`
+}
+
+// Source describes one of the compared videos.
+type Source struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Empty for capture devices, which have no file to hash.
+	SHA256 string `json:"sha256"`
+
+	Container string `json:"container"`
+	Codec     string `json:"codec"`
+
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	PixelFormat string  `json:"pixel_format"`
+	Frames      int     `json:"frames"`
+	FrameRate   float32 `json:"frame_rate"`
+	Orientation string  `json:"orientation"`
+	// The rect of the decoded frames compared after --auto-crop, in which
+	// case Width and Height are those of the crop.
+	Crop *video.Rect `json:"crop,omitempty"`
+	// The bit depth conversion --requantize applied before scoring, in
+	// which case PixelFormat is that of the converted frames.
+	Requantized *Requantization `json:"requantized,omitempty"`
+
+	// The color tags of the source as handed to the color pipeline, and the
+	// plan describing how they reached the metrics.
+	Matrix         string `json:"matrix"`
+	Transfer       string `json:"transfer"`
+	Primaries      string `json:"primaries"`
+	Range          string `json:"range"`
+	ChromaLocation string `json:"chroma_location"`
+	ColorPlan      string `json:"color_plan"`
+	// Static HDR metadata, left out for streams without any.
+	HDR *HDR `json:"hdr,omitempty"`
+}
+
+// Requantization records how --requantize lowered the bit depth of a
+// source.
+type Requantization struct {
+	FromDepth int    `json:"from_depth"`
+	ToDepth   int    `json:"to_depth"`
+	Dither    string `json:"dither"`
+}
+
+// HDR is the JSON form of video.HDRMetadata. Groups the stream does not
+// carry are left out.
+type HDR struct {
+	// CIE 1931 xy of the red, green and blue primaries and the white point.
+	MasteringPrimaries  *[3][2]float64 `json:"mastering_primaries,omitempty"`
+	MasteringWhitePoint *[2]float64    `json:"mastering_white_point,omitempty"`
+	// In cd/m².
+	MasteringMinLuminance *float64 `json:"mastering_min_luminance,omitempty"`
+	MasteringMaxLuminance *float64 `json:"mastering_max_luminance,omitempty"`
+	MaxCLL                *int     `json:"max_cll,omitempty"`
+	MaxFALL               *int     `json:"max_fall,omitempty"`
+}
+
+// NewHDR returns the JSON form of m, nil if m holds no metadata.
+func NewHDR(m video.HDRMetadata) *HDR {
+	if m.IsZero() {
+		return nil
+	}
+
+	var out HDR
+	if m.HasMasteringPrimaries {
+		out.MasteringPrimaries = &m.MasteringPrimaries
+		out.MasteringWhitePoint = &m.MasteringWhitePoint
+	}
+	if m.HasMasteringLuminance {
+		out.MasteringMinLuminance = &m.MasteringMinLuminance
+		out.MasteringMaxLuminance = &m.MasteringMaxLuminance
+	}
+	if m.HasContentLightLevel {
+		out.MaxCLL, out.MaxFALL = &m.MaxCLL, &m.MaxFALL
+	}
+	return &out
+}
+
+// FrameScore holds the scores of one frame pair, keyed by score key.
+type FrameScore struct {
+	// Frame is the reference frame number.
+	Frame  int                `json:"frame"`
+	Scores map[string]float64 `json:"scores"`
+}
+
+// NewRun returns a Run holding scores as returned by comparator.Run, where
+// score i of every key belongs to frames[i], or to frame i if frames is nil.
+// NaN scores, which mark frames a metric left unscored, are left out of the
+// frame scores.
+func NewRun(scores map[string][]float64, frames []int) *Run {
+	numFrames := len(frames)
+	if frames == nil {
+		for _, values := range scores {
+			numFrames = max(numFrames, len(values))
+		}
+	}
+
+	run := &Run{Frames: make([]FrameScore, numFrames)}
+	for i := range run.Frames {
+		frame := i
+		if frames != nil {
+			frame = frames[i]
+		}
+		run.Frames[i] = FrameScore{Frame: frame,
+			Scores: make(map[string]float64, len(scores))}
+	}
+
+	for key, values := range scores {
+		for i, value := range values[:min(len(values), numFrames)] {
+			if !math.IsNaN(value) {
+				run.Frames[i].Scores[key] = value
+			}
+		}
+	}
+	return run
+}
+
+// Keys returns the score keys of the run in sorted order.
+func (r *Run) Keys() []string {
+	keys := make(map[string]struct{})
+	for _, frame := range r.Frames {
+		for key := range frame.Scores {
+			keys[key] = struct{}{}
+		}
+	}
+	return slices.Sorted(maps.Keys(keys))
+}
+
+// Scores returns the scores of every key in frame order, the form
+// comparator.Run returns them in. Frames a key has no score for are NaN.
+func (r *Run) Scores() map[string][]float64 {
+	scores := make(map[string][]float64)
+	for _, key := range r.Keys() {
+		values := make([]float64, len(r.Frames))
+		for i, frame := range r.Frames {
+			value, ok := frame.Scores[key]
+			if !ok {
+				value = math.NaN()
+			}
+			values[i] = value
+		}
+		scores[key] = values
+	}
+	return scores
+}
+
+// FrameNumbers returns the reference frame of every score of Scores.
+func (r *Run) FrameNumbers() []int {
+	frames := make([]int, len(r.Frames))
+	for i, frame := range r.Frames {
+		frames[i] = frame.Frame
+	}
+	return frames
+}
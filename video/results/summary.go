@@ -0,0 +1,166 @@
+package results
+
+import (
+	"math"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// MetricSummary pools the scores of one score key.
+type MetricSummary struct {
+	Key string `json:"key"`
+	// Frames is the number of frames with a score. The statistics are 0
+	// when it is 0.
+	Frames int     `json:"frames"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"stddev"`
+	// The 5th and 95th percentile.
+	P5  float64 `json:"p5"`
+	P95 float64 `json:"p95"`
+	// Worst is the worst score in the direction of the key, nil if the
+	// direction is unknown.
+	Worst *float64 `json:"worst,omitempty"`
+}
+
+// SceneSummary pools the scores of the frames of one scene.
+type SceneSummary struct {
+	// First reference frame of the scene and its length in frames.
+	Start     int             `json:"start"`
+	Frames    int             `json:"frames"`
+	Summaries []MetricSummary `json:"summaries"`
+}
+
+// DirectionFunc returns the direction of the scores under a score key, e.g.
+// metrics.ScoreDirection.
+type DirectionFunc func(key string) video.ScoreDirection
+
+// Summarize pools values of key, skipping NaN values. direction may be nil,
+// in which case Worst is left unset.
+func Summarize(key string, values []float64,
+	direction DirectionFunc) MetricSummary {
+	summary := MetricSummary{Key: key}
+
+	sorted := make([]float64, 0, len(values))
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			sorted = append(sorted, value)
+		}
+	}
+	if len(sorted) == 0 {
+		return summary
+	}
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	n := float64(len(sorted))
+	mean := sum / n
+
+	var squares float64
+	for _, value := range sorted {
+		squares += (value - mean) * (value - mean)
+	}
+
+	summary.Frames = len(sorted)
+	summary.Min, summary.Max = sorted[0], sorted[len(sorted)-1]
+	summary.Mean = mean
+	summary.Median = percentile(sorted, 50)
+	summary.StdDev = math.Sqrt(squares / n)
+	summary.P5, summary.P95 = percentile(sorted, 5), percentile(sorted, 95)
+
+	if direction != nil {
+		var worst float64
+		switch direction(key) {
+		case video.HigherIsBetter:
+			worst = summary.Min
+			summary.Worst = &worst
+		case video.LowerIsBetter:
+			worst = summary.Max
+			summary.Worst = &worst
+		}
+	}
+	return summary
+}
+
+// percentile returns the p-th percentile of sorted by linear interpolation
+// between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := min(lower+1, len(sorted)-1)
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+// Summaries pools the scores of every key of the run, in key order. Excluded
+// frames are left out.
+func (r *Run) Summaries(direction DirectionFunc) []MetricSummary {
+	return r.summarize(r.Frames, direction)
+}
+
+// SceneSummaries pools the scores of every scene, the frames from one of
+// cuts up to the next, in scene order. cuts are reference frame numbers in
+// increasing order. Frames before the first cut form a scene of their own and
+// scenes without scored frames are left out.
+func (r *Run) SceneSummaries(cuts []int,
+	direction DirectionFunc) []SceneSummary {
+	starts := []int{0}
+	for _, cut := range cuts {
+		if cut > starts[len(starts)-1] {
+			starts = append(starts, cut)
+		}
+	}
+
+	sceneFrames := make([][]FrameScore, len(starts))
+	last := 0
+	for _, frame := range r.Frames {
+		i, found := slices.BinarySearch(starts, frame.Frame)
+		if !found {
+			i--
+		}
+		sceneFrames[i] = append(sceneFrames[i], frame)
+		last = max(last, frame.Frame)
+	}
+
+	var scenes []SceneSummary
+	for i, frames := range sceneFrames {
+		if len(frames) == 0 {
+			continue
+		}
+		end := last + 1
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		scenes = append(scenes, SceneSummary{Start: starts[i],
+			Frames: end - starts[i], Summaries: r.summarize(frames, direction)})
+	}
+	return scenes
+}
+
+// summarize pools the scores of every key over frames, leaving out the
+// excluded ones.
+func (r *Run) summarize(frames []FrameScore,
+	direction DirectionFunc) []MetricSummary {
+	excluded := make(map[int]bool, len(r.Excluded))
+	for _, frame := range r.Excluded {
+		excluded[frame] = true
+	}
+
+	keys := r.Keys()
+	summaries := make([]MetricSummary, len(keys))
+	for i, key := range keys {
+		values := make([]float64, 0, len(frames))
+		for _, frame := range frames {
+			if value, ok := frame.Scores[key]; ok && !excluded[frame.Frame] {
+				values = append(values, value)
+			}
+		}
+		summaries[i] = Summarize(key, values, direction)
+	}
+	return summaries
+}
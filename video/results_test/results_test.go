@@ -0,0 +1,253 @@
+package results_test
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/results"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) <= 1e-9 }
+
+func direction(key string) video.ScoreDirection {
+	if key == "SSIMULACRA2" {
+		return video.HigherIsBetter
+	}
+	return video.LowerIsBetter
+}
+
+func Test_NewRun(t *testing.T) {
+	scores := map[string][]float64{
+		"SSIMULACRA2": {90, 80, math.NaN()},
+		"Butteraugli": {0.5, 1.5, 2.5},
+	}
+	run := results.NewRun(scores, []int{10, 12, 14})
+
+	if keys := run.Keys(); !slices.Equal(keys,
+		[]string{"Butteraugli", "SSIMULACRA2"}) {
+		t.Errorf("Keys() = %v", keys)
+	}
+	if frames := run.FrameNumbers(); !slices.Equal(frames,
+		[]int{10, 12, 14}) {
+		t.Errorf("FrameNumbers() = %v", frames)
+	}
+
+	// The NaN score is left out of its frame.
+	last := run.Frames[2]
+	if _, ok := last.Scores["SSIMULACRA2"]; ok || last.Frame != 14 ||
+		last.Scores["Butteraugli"] != 2.5 {
+		t.Errorf("frame 14: got %+v", last)
+	}
+
+	// Scores restores the NaN.
+	back := run.Scores()
+	if !math.IsNaN(back["SSIMULACRA2"][2]) || back["SSIMULACRA2"][1] != 80 ||
+		!slices.Equal(back["Butteraugli"], scores["Butteraugli"]) {
+		t.Errorf("Scores() = %v", back)
+	}
+}
+
+func Test_NewRunWithoutFrames(t *testing.T) {
+	// Without frame numbers, frames count from 0 up to the longest key.
+	run := results.NewRun(map[string][]float64{"A": {1}, "B": {1, 2, 3}},
+		nil)
+	if frames := run.FrameNumbers(); !slices.Equal(frames, []int{0, 1, 2}) {
+		t.Errorf("FrameNumbers() = %v", frames)
+	}
+	if _, ok := run.Frames[1].Scores["A"]; ok {
+		t.Error("frame 1 has a score of A")
+	}
+}
+
+func Test_Summaries(t *testing.T) {
+	run := results.NewRun(map[string][]float64{
+		"Butteraugli": {1, 2, 3, 4, 5, 100},
+		"SSIMULACRA2": {50, 60, 70, 80, 90, 0},
+	}, nil)
+	// The last frame, e.g. a black one, is left out.
+	run.Excluded = []int{5}
+
+	summaries := run.Summaries(direction)
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+
+	b := summaries[0]
+	if b.Key != "Butteraugli" || b.Frames != 5 || b.Min != 1 || b.Max != 5 ||
+		b.Mean != 3 || b.Median != 3 || !near(b.StdDev, math.Sqrt(2)) ||
+		!near(b.P5, 1.2) || !near(b.P95, 4.8) {
+		t.Errorf("Butteraugli: got %+v", b)
+	}
+	if b.Worst == nil || *b.Worst != 5 {
+		t.Errorf("Butteraugli: worst %v, want 5", b.Worst)
+	}
+
+	s := summaries[1]
+	if s.Key != "SSIMULACRA2" || s.Mean != 70 || s.Worst == nil ||
+		*s.Worst != 50 {
+		t.Errorf("SSIMULACRA2: got %+v", s)
+	}
+
+	if unknown := run.Summaries(nil); unknown[0].Worst != nil {
+		t.Error("worst set without a direction")
+	}
+}
+
+func Test_SceneSummaries(t *testing.T) {
+	run := results.NewRun(map[string][]float64{
+		"Butteraugli": {1, 1, 2, 2, 2, 6},
+	}, []int{0, 1, 4, 5, 6, 9})
+
+	scenes := run.SceneSummaries([]int{4, 9}, direction)
+	want := []struct{ start, frames, scored int }{{0, 4, 2}, {4, 5, 3},
+		{9, 1, 1}}
+	if len(scenes) != len(want) {
+		t.Fatalf("got %d scenes, want %d", len(scenes), len(want))
+	}
+	for i, w := range want {
+		scene := scenes[i]
+		if scene.Start != w.start || scene.Frames != w.frames ||
+			scene.Summaries[0].Frames != w.scored {
+			t.Errorf("scene %d: got start %d, %d frames, %d scored, want "+
+				"%d, %d, %d", i, scene.Start, scene.Frames,
+				scene.Summaries[0].Frames, w.start, w.frames, w.scored)
+		}
+	}
+}
+
+// resultsFile is a results file as the gometrics command writes it, with
+// fields a Run does not hold.
+const resultsFile = `{
+  "created": "2026-01-02T03:04:05Z",
+  "deterministic": false,
+  "args": ["-r", "ref.mkv", "-d", "dist.mkv"],
+  "options": {"metrics": "SSIMULACRA2"},
+  "libraries": {"vship": "4.0.0", "ffms2": "5.0"},
+  "metric_versions": {"SSIMULACRA2": "vship 4.0.0"},
+  "reference": {"path": "ref.mkv", "sha256": "ab", "width": 1920,
+    "height": 1080, "frames": 4, "frame_rate": 24, "codec": "h264"},
+  "distortion": {"path": "dist.mkv", "width": 1920, "height": 1080,
+    "frames": 4, "frame_rate": 24},
+  "scores": {"SSIMULACRA2": [80, 85, 90]},
+  "summaries": [{"key": "SSIMULACRA2", "frames": 3}],
+  "frames": [0, 2, 3],
+  "events": [{"kind": "frozen", "frame": 1}],
+  "excluded_frames": [3]
+}`
+
+func Test_ReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(resultsFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := results.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if run.Created == nil || run.Created.Year() != 2026 {
+		t.Errorf("Created = %v", run.Created)
+	}
+	if run.Options["metrics"] != "SSIMULACRA2" ||
+		run.MetricVersions["SSIMULACRA2"] != "vship 4.0.0" {
+		t.Errorf("options %v, versions %v", run.Options,
+			run.MetricVersions)
+	}
+	if run.Reference.Path != "ref.mkv" || run.Reference.SHA256 != "ab" ||
+		run.Reference.Width != 1920 || run.Distortion.Frames != 4 ||
+		run.Distortion.FrameRate != 24 {
+		t.Errorf("sources %+v, %+v", run.Reference, run.Distortion)
+	}
+	if frames := run.FrameNumbers(); !slices.Equal(frames, []int{0, 2, 3}) {
+		t.Errorf("FrameNumbers() = %v", frames)
+	}
+	if run.Frames[1].Scores["SSIMULACRA2"] != 85 {
+		t.Errorf("frame 2: got %v", run.Frames[1].Scores)
+	}
+	if !slices.Equal(run.Excluded, []int{3}) {
+		t.Errorf("Excluded = %v", run.Excluded)
+	}
+
+	// The excluded frame 3 is left out of the summary.
+	if summary := run.Summaries(direction)[0]; summary.Frames != 2 ||
+		summary.Mean != 82.5 {
+		t.Errorf("summary %+v", summary)
+	}
+}
+
+func Test_ReadErrors(t *testing.T) {
+	for _, file := range []string{
+		`{"scores": {}}`,
+		`{"scores": {"A": [1, 2]}, "frames": [0]}`,
+		`{"scores": `,
+	} {
+		if _, err := results.Read(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: no error", file)
+		}
+	}
+
+	_, err := results.ReadFile(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil {
+		t.Error("missing file: no error")
+	}
+}
+
+func Test_FileRoundTrip(t *testing.T) {
+	run := results.NewRun(map[string][]float64{
+		"SSIMULACRA2": {80, 85, 90},
+	}, []int{0, 2, 3})
+	run.Options = map[string]string{"metrics": "SSIMULACRA2"}
+	run.Reference = &results.Source{Path: "ref.mkv", SHA256: "ab"}
+	run.Distortion = results.Source{Path: "dist.mkv", Frames: 4}
+	run.Excluded = []int{3}
+
+	file := results.NewFile(run)
+	file.Summaries = run.Summaries(direction)
+	var buffer bytes.Buffer
+	if err := file.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := results.Read(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := read.FrameNumbers(); !slices.Equal(frames, []int{0, 2, 3}) {
+		t.Errorf("FrameNumbers() = %v", frames)
+	}
+	if read.Frames[2].Scores["SSIMULACRA2"] != 90 {
+		t.Errorf("frame 3: got %v", read.Frames[2].Scores)
+	}
+	if read.Reference == nil || read.Reference.Path != "ref.mkv" ||
+		read.Distortion.Frames != 4 || read.Options["metrics"] == "" {
+		t.Errorf("sources %+v, %+v, options %v", read.Reference,
+			read.Distortion, read.Options)
+	}
+	if !slices.Equal(read.Excluded, []int{3}) {
+		t.Errorf("Excluded = %v", read.Excluded)
+	}
+}
+
+func Test_NewFileFrames(t *testing.T) {
+	scores := map[string][]float64{"PSNR": {30, 31}}
+	file := results.NewFile(results.NewRun(scores, nil))
+	if file.Frames != nil {
+		t.Errorf("frames 0 and 1: Frames = %v", file.Frames)
+	}
+
+	// A run without a reference leaves it out of the file.
+	file = results.NewFile(results.NewRun(scores, []int{1, 0}))
+	if !slices.Equal(file.Frames, []int{1, 0}) {
+		t.Errorf("frames 1 and 0: Frames = %v", file.Frames)
+	}
+	if file.Reference != nil {
+		t.Errorf("Reference = %+v", file.Reference)
+	}
+}
@@ -0,0 +1,88 @@
+package video
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ScoreScale is the scale the scores of a metric are pooled on, e.g. averaged
+// over frames.
+type ScoreScale int
+
+const (
+	// ScaleLinear pools scores as they are.
+	ScaleLinear ScoreScale = iota
+	// ScaleJOD pools JOD scores, as CVVDP reports them, on the linear
+	// distortion scale they are mapped from, as CVVDP pools frames itself.
+	// A mean of JOD scores would overstate the quality of clips with a few
+	// bad frames.
+	ScaleJOD
+)
+
+func (s ScoreScale) String() string {
+	switch s {
+	case ScaleLinear:
+		return "linear"
+	case ScaleJOD:
+		return "jod"
+	default:
+		return fmt.Sprintf("ScoreScale(%d)", int(s))
+	}
+}
+
+// The parameters of the JOD mapping of CVVDP, JOD = 10 - a*d^exp for a
+// distortion d.
+const (
+	jodA   = 0.0439569391310215
+	jodExp = 0.9302042722702026
+)
+
+// ToPooling maps a score to the scale it is pooled on.
+func (s ScoreScale) ToPooling(score float64) float64 {
+	if s == ScaleJOD {
+		return math.Pow((10-score)/jodA, 1/jodExp)
+	}
+	return score
+}
+
+// FromPooling maps a pooled value back to a score, the inverse of ToPooling.
+func (s ScoreScale) FromPooling(value float64) float64 {
+	if s == ScaleJOD {
+		return 10 - jodA*math.Pow(value, jodExp)
+	}
+	return value
+}
+
+// ScoreFormat tells how the scores of a metric are pooled and displayed. The
+// zero ScoreFormat shows 6 decimal places of unitless, linearly pooled
+// scores.
+type ScoreFormat struct {
+	// Unit is shown after scores, e.g. "JOD" or "dB", and is empty for
+	// unitless scores.
+	Unit string
+	// Decimals is the number of decimal places shown.
+	Decimals int
+	Scale    ScoreScale
+}
+
+// defaultDecimals are the decimal places of the zero ScoreFormat.
+const defaultDecimals = 6
+
+// FormatValue formats score with the decimal places of f, without the unit,
+// for tables whose header names it.
+func (f ScoreFormat) FormatValue(score float64) string {
+	decimals := f.Decimals
+	if f == (ScoreFormat{}) {
+		decimals = defaultDecimals
+	}
+	return strconv.FormatFloat(score, 'f', decimals, 64)
+}
+
+// Format formats score with the decimal places and unit of f.
+func (f ScoreFormat) Format(score float64) string {
+	if f.Unit == "" {
+		return f.FormatValue(score)
+	}
+	return f.FormatValue(score) + " " + f.Unit
+}
@@ -0,0 +1,9 @@
+// Package snapshot writes frame pairs of a comparison as PNG images, the
+// reference on the left and the distortion on the right, so the frames a
+// report flags can be looked at.
+//
+// Frames are converted on the CPU to 8-bit R'G'B' in the transfer and
+// primaries of the source, without tone mapping, so HDR frames look flat on
+// an SDR viewer. Unspecified color properties are inferred as the metrics
+// would infer them.
+package snapshot
@@ -0,0 +1,217 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	vcolor "github.com/GreatValueCreamSoda/gometrics/video/color"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// Options configures Extract.
+type Options struct {
+	// Directory the images are written to, created if missing.
+	Dir string
+	// Frames to write, by frame number of the sources.
+	Frames []int
+	// Frames to write, by time in seconds from the first frame. Each selects
+	// the frame shown at that time, see FrameAt.
+	Times []float64
+	// Pixels of black between the reference and the distortion.
+	Gap int
+}
+
+// Extract writes frame n of reference and of distortion side by side to
+// frame_<n>.png in opts.Dir for every frame selected by opts, and returns the
+// paths written in frame order. Frames selected twice are written once.
+// Frames of different sizes are aligned at the top.
+//
+// Both sources must be seekable. Their read position is left at an
+// unspecified frame.
+func Extract(reference, distortion video.Source, opts Options) ([]string,
+	error) {
+	frames := slices.Clone(opts.Frames)
+	for _, seconds := range opts.Times {
+		n, err := FrameAt(reference, seconds)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, n)
+	}
+	slices.Sort(frames)
+	frames = slices.Compact(frames)
+	if len(frames) == 0 {
+		return nil, errors.New("no frames to extract")
+	}
+
+	numFrames := min(reference.GetNumFrames(), distortion.GetNumFrames())
+	if frames[0] < 0 || frames[len(frames)-1] >= numFrames {
+		return nil, fmt.Errorf("frames must be in [0, %d)", numFrames)
+	}
+
+	readers := [2]*frameReader{}
+	for i, source := range []video.Source{reference, distortion} {
+		reader, err := newFrameReader(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sourceNames[i], err)
+		}
+		readers[i] = reader
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(frames))
+	for _, n := range frames {
+		var images [2]*image.NRGBA
+		for i, reader := range readers {
+			img, err := reader.read(n)
+			if err != nil {
+				return paths, fmt.Errorf("%s frame %d: %w", sourceNames[i],
+					n, err)
+			}
+			images[i] = img
+		}
+
+		path := filepath.Join(opts.Dir, fmt.Sprintf("frame_%06d.png", n))
+		if err := writePNG(path, sideBySide(images, opts.Gap)); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+var sourceNames = [2]string{"reference", "distortion"}
+
+// FrameAt returns the frame of source shown seconds after its first frame,
+// by its frame rate.
+func FrameAt(source video.Source, seconds float64) (int, error) {
+	frameRate := float64(source.GetFrameRate())
+	if frameRate <= 0 {
+		return 0, errors.New("source has no frame rate")
+	}
+	if seconds < 0 {
+		return 0, fmt.Errorf("time %gs is before the first frame", seconds)
+	}
+
+	// The tolerance keeps times printed at the start of a frame, e.g.
+	// 1.001 at 1000/1001 fps, from landing on the frame before.
+	n := int(math.Floor(seconds*frameRate + 1e-6))
+	if n >= source.GetNumFrames() {
+		return 0, fmt.Errorf("time %gs is past the end of the %d frame "+
+			"source", seconds, source.GetNumFrames())
+	}
+	return n, nil
+}
+
+// frameReader reads frames of a source as 8-bit RGB images.
+type frameReader struct {
+	source    video.SeekableSource
+	converter *vcolor.Converter
+	width     int
+	height    int
+	frame     video.Frame
+	rgb       [3][]float32
+}
+
+func newFrameReader(source video.Source) (*frameReader, error) {
+	planar, err := sources.Planarize(source)
+	if err != nil {
+		return nil, err
+	}
+	seekable, ok := video.As[video.SeekableSource](planar)
+	if !ok {
+		return nil, errors.New("source cannot seek")
+	}
+
+	props := planar.GetColorProps()
+	plan, err := vcolor.NewPlan(*props, vcolor.VshipBackend,
+		vcolor.PermissiveInference())
+	if err != nil {
+		return nil, err
+	}
+	converter, err := vcolor.NewConverter(plan.Resolved(), vcolor.StageRGB)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := video.NewFrameFor(planar)
+	if err != nil {
+		return nil, err
+	}
+
+	size := props.Width * props.Height
+	return &frameReader{source: seekable, converter: converter,
+		width: props.Width, height: props.Height, frame: frame,
+		rgb: [3][]float32{make([]float32, size), make([]float32, size),
+			make([]float32, size)}}, nil
+}
+
+// read returns frame n as an image.
+func (r *frameReader) read(n int) (*image.NRGBA, error) {
+	if err := r.source.SeekFrame(n); err != nil {
+		return nil, err
+	}
+	if err := r.source.GetFrame(r.frame); err != nil {
+		return nil, err
+	}
+	if err := r.converter.Convert(r.rgb, &r.frame); err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, r.width, r.height))
+	for i := range r.rgb[0] {
+		for c := range 3 {
+			img.Pix[4*i+c] = to8Bit(r.rgb[c][i])
+		}
+		img.Pix[4*i+3] = 0xff
+	}
+	return img, nil
+}
+
+// to8Bit quantizes a sample nominally in [0, 1] to 8 bits.
+func to8Bit(v float32) uint8 {
+	return uint8(math.Round(float64(min(max(v, 0), 1)) * 255))
+}
+
+// sideBySide places images next to each other on a black canvas, gap pixels
+// apart.
+func sideBySide(images [2]*image.NRGBA, gap int) *image.NRGBA {
+	left, right := images[0].Rect, images[1].Rect
+	canvas := image.NewNRGBA(image.Rect(0, 0, left.Dx()+gap+right.Dx(),
+		max(left.Dy(), right.Dy())))
+	for i := 3; i < len(canvas.Pix); i += 4 {
+		canvas.Pix[i] = 0xff
+	}
+
+	offset := 0
+	for _, img := range images {
+		for y := range img.Rect.Dy() {
+			row := img.Pix[y*img.Stride : y*img.Stride+4*img.Rect.Dx()]
+			copy(canvas.Pix[y*canvas.Stride+4*offset:], row)
+		}
+		offset += img.Rect.Dx() + gap
+	}
+	return canvas
+}
+
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
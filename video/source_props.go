@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
-	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
 )
 
 type ColorProperties struct {
@@ -15,132 +14,282 @@ type ColorProperties struct {
 	ColorTransfer  pixfmts.ColorTransferCharacteristic
 	ColorPrimaries pixfmts.ColorPrimaries
 	ChromaLocation pixfmts.ChromaLocation
+	// Orientation is the display transform the container asks for. Frames are
+	// delivered as stored, see sources.Orient to apply it.
+	Orientation Orientation
+	// HDR is the static HDR metadata of the stream, if it carries any.
+	HDR HDRMetadata
 }
 
-func (cp *ColorProperties) ToVsHipColorspace(cs *vship.Colorspace) error {
-	cs.Width, cs.Height = cp.Width, cp.Height
+// HDRMetadata is the static HDR metadata of a stream: the mastering display
+// color volume (SMPTE ST 2086) and the content light levels (CTA-861.3). Each
+// group is only valid when its Has field is set.
+type HDRMetadata struct {
+	HasMasteringPrimaries bool
+	// CIE 1931 xy chromaticities of the red, green and blue primaries and the
+	// white point of the mastering display.
+	MasteringPrimaries  [3][2]float64
+	MasteringWhitePoint [2]float64
 
+	HasMasteringLuminance bool
+	// Luminance range of the mastering display in cd/m².
+	MasteringMinLuminance, MasteringMaxLuminance float64
+
+	HasContentLightLevel bool
+	// MaxCLL is the brightest pixel and MaxFALL the brightest frame average
+	// of the stream, in cd/m².
+	MaxCLL, MaxFALL int
+}
+
+// IsZero returns true if the stream carries no HDR metadata.
+func (m HDRMetadata) IsZero() bool {
+	return !m.HasMasteringPrimaries && !m.HasMasteringLuminance &&
+		!m.HasContentLightLevel
+}
+
+// Orientation describes how decoded frames must be transformed to be
+// displayed upright. The flip is applied before the rotation.
+type Orientation struct {
+	// Rotation in degrees clockwise, normalized to [0, 360).
+	Rotation       int
+	FlipHorizontal bool
+	FlipVertical   bool
+}
+
+// NewOrientation builds an Orientation from a rotation in degrees clockwise and
+// an ffms2 style flip, which is 0 for none, >0 for horizontal and <0 for
+// vertical.
+func NewOrientation(rotation, flip int) Orientation {
+	return Orientation{Rotation: (rotation%360 + 360) % 360,
+		FlipHorizontal: flip > 0, FlipVertical: flip < 0}
+}
+
+// IsIdentity returns true if the orientation leaves frames unchanged.
+func (o Orientation) IsIdentity() bool {
+	return o == Orientation{}
+}
+
+func (o Orientation) String() string {
+	s := fmt.Sprintf("rotate %d", o.Rotation)
+	if o.FlipHorizontal {
+		s += ", flip horizontal"
+	}
+	if o.FlipVertical {
+		s += ", flip vertical"
+	}
+	return s
+}
+
+// BitDepth returns the effective bit depth of the source, i.e. the number of
+// significant bits per sample rather than the storage size. A 10-bit format is
+// stored in 16-bit words but reports 10.
+//
+// Returns an error if the pixel format is unknown or if its components do not
+// all share the same depth, as nothing in the pipeline can handle those.
+func (cp *ColorProperties) BitDepth() (int, error) {
 	pixFmtDesc, err := pixfmts.PixFmtDescGet(cp.PixelFormat)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("pixel format %d: %w", cp.PixelFormat, err)
 	}
 
 	comp, err := pixFmtDesc.Component(0)
 	if err != nil {
-		return err
-	}
-
-	var pixFmtSamplingFormat vship.SamplingFormat
-
-	switch comp.Depth {
-	case 8:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt8
-	case 9:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt9
-	case 10:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt10
-	case 12:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt12
-	case 14:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt14
-	case 16:
-		pixFmtSamplingFormat = vship.SamplingFormatUInt16
-	default:
-		return fmt.Errorf("unknown pixel format %s", pixFmtDesc.Name())
+		return 0, err
 	}
 
-	cs.SamplingFormat = pixFmtSamplingFormat
+	for i := 1; i < pixFmtDesc.NbComponents(); i++ {
+		other, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return 0, err
+		}
+		if other.Depth != comp.Depth {
+			return 0, fmt.Errorf("pixel format %s mixes %d-bit and %d-bit "+
+				"components", pixFmtDesc.Name(), comp.Depth, other.Depth)
+		}
+	}
 
-	switch cp.ColorRange {
-	case pixfmts.ColorRangeMPEG:
-		cs.ColorRange = vship.ColorRangeLimited
-	case pixfmts.ColorRangeJPEG:
-		cs.ColorRange = vship.ColorRangeFull
-	default:
-		// return fmt.Errorf("color range is not specified in source properties")
-		cs.ColorRange = vship.ColorRangeLimited
-	}
-
-	cs.ChromaSubsamplingHeight = pixFmtDesc.Log2ChromaH()
-	cs.ChromaSubsamplingWidth = pixFmtDesc.Log2ChromaW()
-
-	switch cp.ChromaLocation {
-	case pixfmts.ChromaLocationLeft:
-		cs.ChromaLocation = vship.ChromaLocationLeft
-	case pixfmts.ChromaLocationCenter:
-		cs.ChromaLocation = vship.ChromaLocationCenter
-	case pixfmts.ChromaLocationTopLeft:
-		cs.ChromaLocation = vship.ChromaLocationTopLeft
-	case pixfmts.ChromaLocationTop:
-		cs.ChromaLocation = vship.ChromaLocationTop
-	default:
-		// return fmt.Errorf("chroma location in source props is not supported")
-		cs.ChromaLocation = vship.ChromaLocationLeft
-	}
-
-	if pixFmtDesc.Flags()&uint64(pixfmts.PixFmtFlagRGB) == 0 {
-		cs.ColorFamily = vship.ColorFamilyYUV
-	} else {
-		cs.ColorFamily = vship.ColorFamilyRGB
-	}
-
-	switch cp.ColorSpace {
-	case pixfmts.ColorSpaceRGB:
-		cs.ColorMatrix = vship.ColorMatrixRGB
-	case pixfmts.ColorSpaceBT709:
-		cs.ColorMatrix = vship.ColorMatrixBT709
-	case pixfmts.ColorSpaceBT470BG:
-		cs.ColorMatrix = vship.ColorMatrixBT470BG
-	case pixfmts.ColorSpaceSMPTE170M:
-		cs.ColorMatrix = vship.ColorMatrixST170M
-	case pixfmts.ColorSpaceBT2020_NCL:
-		cs.ColorMatrix = vship.ColorMatrixBT2020NCL
-	case pixfmts.ColorSpaceBT2020_CL:
-		cs.ColorMatrix = vship.ColorMatrixBT2020CL
-	case pixfmts.ColorSpaceICTCP:
-		cs.ColorMatrix = vship.ColorMatrixBT2100ICTCP
-	default:
-		// return fmt.Errorf("chroma matrix in source propeties is not supported")
-		cs.ColorMatrix = vship.ColorMatrixBT709
-	}
-
-	switch cp.ColorTransfer {
-	case pixfmts.ColorTransferCharacteristicBT709:
-		cs.ColorTransfer = vship.ColorTransferTRCBT709
-	case pixfmts.ColorTransferCharacteristicGamma22:
-		cs.ColorTransfer = vship.ColorTransferTRCBT470_M
-	case pixfmts.ColorTransferCharacteristicGamma28:
-		cs.ColorTransfer = vship.ColorTransferTRCBT470_BG
-	case pixfmts.ColorTransferCharacteristicSMPTE170M:
-		cs.ColorTransfer = vship.ColorTransferTRCBT601
-	case pixfmts.ColorTransferCharacteristicLinear:
-		cs.ColorTransfer = vship.ColorTransferTRCLinear
-	case pixfmts.ColorTransferCharacteristicIEC61966_2_1:
-		cs.ColorTransfer = vship.ColorTransferTRCSRGB
-	case pixfmts.ColorTransferCharacteristicSMPTE2084:
-		cs.ColorTransfer = vship.ColorTransferTRCPQ
-	case pixfmts.ColorTransferCharacteristicSMPTE428:
-		cs.ColorTransfer = vship.ColorTransferTRCST428
-	case pixfmts.ColorTransferCharacteristicARIB_STD_B67:
-		cs.ColorTransfer = vship.ColorTransferTRCHLG
+	return comp.Depth, nil
+}
+
+// VisiblePlanes returns the number of planes of the source's pixel format
+// and the size of the visible picture in each: the bytes of one row without
+// padding and the number of rows.
+func (cp *ColorProperties) VisiblePlanes() (rowBytes, rows [MaxPlanes]int,
+	numPlanes int, err error) {
+	widths, rows, numPlanes, err := cp.PlaneSizes()
+	if err != nil {
+		return rowBytes, rows, 0, err
+	}
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(cp.PixelFormat)
+	if err != nil {
+		return rowBytes, rows, 0, err
+	}
+	for i := range pixFmtDesc.NbComponents() {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return rowBytes, rows, 0, err
+		}
+		rowBytes[comp.Plane] = max(rowBytes[comp.Plane],
+			widths[comp.Plane]*comp.Step)
+	}
+
+	return rowBytes, rows, numPlanes, nil
+}
+
+// PlaneSizes returns the number of planes of the source's pixel format and
+// the visible width and height of each in pixels.
+func (cp *ColorProperties) PlaneSizes() (widths, heights [MaxPlanes]int,
+	numPlanes int, err error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(cp.PixelFormat)
+	if err != nil {
+		return widths, heights, 0, fmt.Errorf("pixel format %d: %w",
+			cp.PixelFormat, err)
+	}
+
+	for i := range pixFmtDesc.NbComponents() {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return widths, heights, 0, err
+		}
+		if comp.Plane >= MaxPlanes {
+			return widths, heights, 0, fmt.Errorf("pixel format %s uses "+
+				"plane %d", pixFmtDesc.Name(), comp.Plane)
+		}
+
+		log2W, log2H := planeSubsampling(pixFmtDesc, comp.Plane)
+		widths[comp.Plane] = -((-cp.Width) >> log2W)
+		heights[comp.Plane] = -((-cp.Height) >> log2H)
+		numPlanes = max(numPlanes, comp.Plane+1)
+	}
+
+	return widths, heights, numPlanes, nil
+}
+
+// PlaneSubsampling returns log2 of how many times narrower and shorter than
+// the picture plane is in the source's pixel format.
+func (cp *ColorProperties) PlaneSubsampling(plane int) (log2W, log2H int,
+	err error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(cp.PixelFormat)
+	if err != nil {
+		return 0, 0, fmt.Errorf("pixel format %d: %w", cp.PixelFormat, err)
+	}
+	log2W, log2H = planeSubsampling(pixFmtDesc, plane)
+	return log2W, log2H, nil
+}
+
+// planeSubsampling returns the subsampling of plane in the pixel format
+// described by pixFmtDesc. Like libav, only the second and third planes are
+// subsampled.
+func planeSubsampling(pixFmtDesc *pixfmts.PixFmtDescRef, plane int) (log2W,
+	log2H int) {
+	if plane == 1 || plane == 2 {
+		return pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH()
+	}
+	return 0, 0
+}
+
+// PlaneLayout describes how a pixel format arranges its components in a
+// Frame's planes.
+type PlaneLayout int
+
+const (
+	LayoutUnknown PlaneLayout = iota
+	// LayoutGray is a single luma plane, optionally followed by alpha.
+	LayoutGray
+	// LayoutYUV is three planar Y, U and V planes.
+	LayoutYUV
+	// LayoutYUVA is LayoutYUV followed by an alpha plane.
+	LayoutYUVA
+	// LayoutRGB is three planar G, B and R planes.
+	LayoutRGB
+	// LayoutRGBA is LayoutRGB followed by an alpha plane.
+	LayoutRGBA
+	// LayoutPacked interleaves every component in a single plane.
+	LayoutPacked
+)
+
+func (l PlaneLayout) String() string {
+	switch l {
+	case LayoutGray:
+		return "gray"
+	case LayoutYUV:
+		return "yuv"
+	case LayoutYUVA:
+		return "yuva"
+	case LayoutRGB:
+		return "rgb"
+	case LayoutRGBA:
+		return "rgba"
+	case LayoutPacked:
+		return "packed"
 	default:
-		// return fmt.Errorf("chroma transfer in source props is not supported")
-		cs.ColorTransfer = vship.ColorTransferTRCBT709
-	}
-
-	switch cp.ColorPrimaries {
-	case pixfmts.ColorPrimariesBT709:
-		cs.ColorPrimaries = vship.ColorPrimariesBT709
-	case pixfmts.ColorPrimariesBT470M:
-		cs.ColorPrimaries = vship.ColorPrimariesBT470_M
-	case pixfmts.ColorPrimariesBT470BG:
-		cs.ColorPrimaries = vship.ColorPrimariesBT470_BG
-	case pixfmts.ColorPrimariesBT2020:
-		cs.ColorPrimaries = vship.ColorPrimariesBT2020
+		return "unknown"
+	}
+}
+
+// NumPlanes returns the number of Frame planes the layout uses.
+func (l PlaneLayout) NumPlanes() int {
+	switch l {
+	case LayoutGray, LayoutPacked:
+		return 1
+	case LayoutYUV, LayoutRGB:
+		return 3
+	case LayoutYUVA, LayoutRGBA:
+		return 4
 	default:
-		// return fmt.Errorf("chroma primaries in source props is not supported")
-		cs.ColorPrimaries = vship.ColorPrimariesBT709
+		return 0
 	}
+}
+
+// HasAlpha returns true if the layout carries an alpha plane.
+func (l PlaneLayout) HasAlpha() bool {
+	return l == LayoutYUVA || l == LayoutRGBA
+}
+
+// Layout returns the plane layout of the source's pixel format.
+//
+// Returns an error if the pixel format is unknown or is not a layout the
+// pipeline can carry, e.g. palette, bitstream or semi-planar formats.
+func (cp *ColorProperties) Layout() (PlaneLayout, error) {
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(cp.PixelFormat)
+	if err != nil {
+		return LayoutUnknown, fmt.Errorf("pixel format %d: %w", cp.PixelFormat,
+			err)
+	}
+
+	flags := pixfmts.PixFmtFlag(pixFmtDesc.Flags())
+	components := pixFmtDesc.NbComponents()
+	alpha := flags&pixfmts.PixFmtFlagAlpha != 0
 
-	return nil
+	switch {
+	case flags&(pixfmts.PixFmtFlagPAL|pixfmts.PixFmtFlagBitstream|
+		pixfmts.PixFmtFlagHWAccel|pixfmts.PixFmtFlagBayer) != 0:
+		return LayoutUnknown, fmt.Errorf("pixel format %s has no supported "+
+			"plane layout", pixFmtDesc.Name())
+	case components <= 2:
+		return LayoutGray, nil
+	case flags&pixfmts.PixFmtFlagPlanar == 0:
+		return LayoutPacked, nil
+	}
+
+	planes, err := pixfmts.PixFmtCountPlanes(cp.PixelFormat)
+	if err != nil {
+		return LayoutUnknown, err
+	}
+	if planes != components {
+		return LayoutUnknown, fmt.Errorf("semi-planar pixel format %s is not "+
+			"supported", pixFmtDesc.Name())
+	}
+
+	switch {
+	case flags&pixfmts.PixFmtFlagRGB != 0 && alpha:
+		return LayoutRGBA, nil
+	case flags&pixfmts.PixFmtFlagRGB != 0:
+		return LayoutRGB, nil
+	case alpha:
+		return LayoutYUVA, nil
+	default:
+		return LayoutYUV, nil
+	}
 }
@@ -0,0 +1,345 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+)
+
+var (
+	ErrNoAudioTrack = errors.New("file has no audio track")
+)
+
+// audioBlockSamples is how many samples decodeAudio decodes per call into
+// ffms2.
+const audioBlockSamples = 1 << 16
+
+// ReadAudioEnvelope decodes the first audio track of the file at path and
+// returns its loudness envelope: the RMS level of all channels over
+// consecutive windows of 1/rate seconds, so the envelope has rate values per
+// second.
+//
+// Sample 0 is placed at the first frame of the first video track, so envelope
+// value i covers the audio playing from i/rate seconds after the first frame
+// is shown. Audio starting before the first frame is dropped and audio
+// starting after it is padded with silence.
+//
+// Indexing and decoding stop soon after ctx is done, returning ctx.Err().
+func ReadAudioEnvelope(ctx context.Context, path string, rate float64) (
+	[]float32, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid envelope rate %g", rate)
+	}
+
+	indexer, _, err := ffms.CreateIndexer(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = indexer.TrackTypeIndexSettings(ffms.TypeAudio, true); err != nil {
+		indexer.Close()
+		return nil, err
+	}
+
+	index, _, err := indexer.DoIndexingContext(ctx, ffms.IEHAbort)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	track, _, err := index.GetFirstTrackOfType(ffms.TypeAudio)
+	if err != nil || track < 0 {
+		return nil, ErrNoAudioTrack
+	}
+
+	audio, _, err := ffms.CreateAudioSource(path, index, track,
+		ffms.DelayFirstVideoTrack)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	props, err := audio.GetAudioProperties()
+	if err != nil {
+		return nil, err
+	}
+	if props.SampleRate <= 0 || props.Channels <= 0 {
+		return nil, fmt.Errorf("invalid audio properties: %d Hz, %d channels",
+			props.SampleRate, props.Channels)
+	}
+
+	envelope := newEnvelopeBuilder(float64(props.SampleRate), rate,
+		props.Channels)
+
+	err = decodeAudio(ctx, audio, &props, func(samples []float64) {
+		for _, v := range samples {
+			envelope.add(v)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return envelope.finish(), nil
+}
+
+// AudioStream describes an audio track of a file, see DecodeAudioStreams.
+type AudioStream struct {
+	// The position of the track among the audio tracks of the file,
+	// starting at 0.
+	Stream     int
+	SampleRate int
+	Channels   int
+	// The FFmpeg name of every channel in sample order, such as "FL", "FR"
+	// or "LFE". nil if the stream has no channel layout.
+	ChannelNames []string
+	// The length of the stream in seconds.
+	Duration float64
+}
+
+// channelNames are the FFmpeg names of the channels of a layout, in the order
+// their samples are interleaved.
+var channelNames = []struct {
+	channel ffms.AudioChannel
+	name    string
+}{
+	{ffms.ChannelFrontLeft, "FL"},
+	{ffms.ChannelFrontRight, "FR"},
+	{ffms.ChannelCenter, "FC"},
+	{ffms.ChannelLowFrequency, "LFE"},
+	{ffms.ChannelBackLeft, "BL"},
+	{ffms.ChannelBackRight, "BR"},
+	{ffms.ChannelFrontLeftOfCenter, "FLC"},
+	{ffms.ChannelFrontRightOfCenter, "FRC"},
+	{ffms.ChannelBackCenter, "BC"},
+	{ffms.ChannelSideLeft, "SL"},
+	{ffms.ChannelSideRight, "SR"},
+	{ffms.ChannelTopCenter, "TC"},
+	{ffms.ChannelTopFrontLeft, "TFL"},
+	{ffms.ChannelTopFrontCenter, "TFC"},
+	{ffms.ChannelTopFrontRight, "TFR"},
+	{ffms.ChannelTopBackLeft, "TBL"},
+	{ffms.ChannelTopBackCenter, "TBC"},
+	{ffms.ChannelTopBackRight, "TBR"},
+	{ffms.ChannelStereoLeft, "DL"},
+	{ffms.ChannelStereoRight, "DR"},
+}
+
+// layoutChannels returns the names of the channels of layout, or nil if they
+// do not add up to channels.
+func layoutChannels(layout int64, channels int) []string {
+	var names []string
+	for _, c := range channelNames {
+		if layout&int64(c.channel) != 0 {
+			names = append(names, c.name)
+		}
+	}
+	if len(names) != channels {
+		return nil
+	}
+	return names
+}
+
+// DecodeAudioStreams decodes every audio track of the file at path, in track
+// order. newSink is called with the description of each track and the
+// function it returns receives all of its samples in blocks, interleaved and
+// normalized to [-1, 1]. The block is reused once the function returns.
+//
+// Returns ErrNoAudioTrack if the file has no audio. Indexing and decoding
+// stop soon after ctx is done, returning ctx.Err().
+func DecodeAudioStreams(ctx context.Context, path string,
+	newSink func(AudioStream) func(samples []float64)) error {
+	indexer, _, err := ffms.CreateIndexer(path)
+	if err != nil {
+		return err
+	}
+	if err = indexer.TrackTypeIndexSettings(ffms.TypeAudio, true); err != nil {
+		indexer.Close()
+		return err
+	}
+
+	index, _, err := indexer.DoIndexingContext(ctx, ffms.IEHAbort)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+
+	numTracks, err := index.GetNumTracks()
+	if err != nil {
+		return err
+	}
+
+	var stream int
+	for track := range numTracks {
+		info, err := index.GetTrack(track)
+		if err != nil {
+			return err
+		}
+		if trackType, err := info.GetType(); err != nil {
+			return err
+		} else if trackType != ffms.TypeAudio {
+			continue
+		}
+
+		if err := decodeAudioStream(ctx, path, index, track, stream,
+			newSink); err != nil {
+			return fmt.Errorf("audio stream %d: %w", stream, err)
+		}
+		stream++
+	}
+
+	if stream == 0 {
+		return ErrNoAudioTrack
+	}
+	return nil
+}
+
+// decodeAudioStream decodes one audio track for DecodeAudioStreams.
+func decodeAudioStream(ctx context.Context, path string, index *ffms.Index,
+	track, stream int, newSink func(AudioStream) func([]float64)) error {
+	audio, _, err := ffms.CreateAudioSource(path, index, track,
+		ffms.DelayNoShift)
+	if err != nil {
+		return err
+	}
+	defer audio.Close()
+
+	props, err := audio.GetAudioProperties()
+	if err != nil {
+		return err
+	}
+	if props.SampleRate <= 0 || props.Channels <= 0 {
+		return fmt.Errorf("invalid audio properties: %d Hz, %d channels",
+			props.SampleRate, props.Channels)
+	}
+
+	sink := newSink(AudioStream{
+		Stream:       stream,
+		SampleRate:   props.SampleRate,
+		Channels:     props.Channels,
+		ChannelNames: layoutChannels(props.ChannelLayout, props.Channels),
+		Duration:     float64(props.NumSamples) / float64(props.SampleRate),
+	})
+
+	return decodeAudio(ctx, audio, &props, sink)
+}
+
+// decodeAudio decodes every sample of audio and passes them to sink in
+// blocks of up to audioBlockSamples samples per channel.
+func decodeAudio(ctx context.Context, audio *ffms.AudioSource,
+	props *ffms.AudioProperties, sink func([]float64)) error {
+	decode, err := sampleDecoder(ffms.SampleFormat(props.SampleFormat))
+	if err != nil {
+		return err
+	}
+
+	sampleSize, err := audio.SampleSize()
+	if err != nil {
+		return err
+	}
+
+	var block []float64
+	for start := int64(0); start < props.NumSamples; start +=
+		audioBlockSamples {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		count := min(audioBlockSamples, props.NumSamples-start)
+		data, _, err := audio.GetAudio(start, count)
+		if err != nil {
+			return fmt.Errorf("audio samples %d-%d: %w", start,
+				start+count, err)
+		}
+
+		block = block[:0]
+		for offset := 0; offset < len(data); offset += sampleSize {
+			block = append(block, decode(data[offset:]))
+		}
+		sink(block)
+	}
+
+	return nil
+}
+
+// sampleDecoder returns a function reading one native endian sample of the
+// given format, normalized to [-1, 1].
+func sampleDecoder(format ffms.SampleFormat) (func([]byte) float64, error) {
+	switch format {
+	case ffms.FmtU8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 },
+			nil
+	case ffms.FmtS16:
+		return func(b []byte) float64 {
+			return float64(int16(binary.NativeEndian.Uint16(b))) / (1 << 15)
+		}, nil
+	case ffms.FmtS32:
+		return func(b []byte) float64 {
+			return float64(int32(binary.NativeEndian.Uint32(b))) / (1 << 31)
+		}, nil
+	case ffms.FmtFlt:
+		return func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.NativeEndian.Uint32(b)))
+		}, nil
+	case ffms.FmtDbl:
+		return func(b []byte) float64 {
+			return math.Float64frombits(binary.NativeEndian.Uint64(b))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audio sample format %d", format)
+	}
+}
+
+// envelopeBuilder accumulates interleaved samples into RMS windows. Window
+// boundaries are kept in samples as floats so rates that do not divide the
+// sample rate do not drift.
+type envelopeBuilder struct {
+	samplesPerValue float64
+	channels        int
+
+	// The number of interleaved values and whole samples seen so far.
+	values, samples int64
+	sum             float64
+	count           int
+	envelope        []float32
+}
+
+func newEnvelopeBuilder(sampleRate, rate float64,
+	channels int) *envelopeBuilder {
+	return &envelopeBuilder{samplesPerValue: sampleRate / rate,
+		channels: channels}
+}
+
+func (e *envelopeBuilder) add(v float64) {
+	e.sum += v * v
+	e.count++
+	e.values++
+
+	if e.values%int64(e.channels) != 0 {
+		return
+	}
+
+	e.samples++
+	end := float64(len(e.envelope)+1) * e.samplesPerValue
+	if float64(e.samples) >= end {
+		e.flush()
+	}
+}
+
+func (e *envelopeBuilder) flush() {
+	e.envelope = append(e.envelope, float32(math.Sqrt(e.sum/
+		float64(e.count))))
+	e.sum, e.count = 0, 0
+}
+
+func (e *envelopeBuilder) finish() []float32 {
+	if e.count > 0 {
+		e.flush()
+	}
+	return e.envelope
+}
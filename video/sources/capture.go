@@ -0,0 +1,275 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// captureAPIs are the ffmpeg input devices a capture path may name.
+var captureAPIs = []string{"v4l2", "decklink"}
+
+// captureLogTail is how much of the end of the ffmpeg log is kept for errors.
+const captureLogTail = 4096
+
+// ParseCapturePath splits a capture path, "v4l2:/dev/video0" or
+// "decklink:DeckLink Duo (1)", into the ffmpeg input device and the name of
+// the device. It returns false for paths naming files.
+func ParseCapturePath(path string) (api, device string, ok bool) {
+	api, device, found := strings.Cut(path, ":")
+	if !found || device == "" {
+		return "", "", false
+	}
+	for _, captureAPI := range captureAPIs {
+		if api == captureAPI {
+			return api, device, true
+		}
+	}
+	return "", "", false
+}
+
+// CaptureOptions configures OpenCapture.
+type CaptureOptions struct {
+	// Path of the ffmpeg executable. Defaults to ffmpeg from PATH.
+	Binary string
+	// Props are the size, pixel format and color description of the frames
+	// delivered. ffmpeg scales and converts the captured picture to the size
+	// and pixel format, the color description is taken as is. Size and pixel
+	// format are required.
+	Props video.ColorProperties
+	// FrameRate of the frames delivered. ffmpeg drops or repeats captured
+	// frames to keep it, so two captures of the same feed stay in step.
+	// Required.
+	FrameRate float32
+	// Frames is how many frames are delivered, as sources have a length.
+	// Required.
+	Frames int
+	// Skip is how many captured frames are dropped before the first frame
+	// delivered, e.g. to delay the program feed by the latency of the
+	// encoder whose output it is compared with.
+	Skip int
+	// InputArgs are passed to ffmpeg before the device, e.g.
+	// {"-format_code", "Hp59"} for DeckLink or {"-input_format", "mjpeg"}
+	// for V4L2.
+	InputArgs []string
+	// Stderr receives the log of ffmpeg as well. The end of the log is
+	// always captured and added to the error when ffmpeg fails.
+	Stderr io.Writer
+}
+
+func (o *CaptureOptions) setDefaults() {
+	if o.Binary == "" {
+		o.Binary = "ffmpeg"
+	}
+}
+
+// captureSource reads the raw frames ffmpeg captures from a device.
+type captureSource struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	log    *captureLog
+
+	props        video.ColorProperties
+	frameRate    float32
+	numFrames    int
+	numPlanes    int
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// skip is how many frames are still to be dropped before the first read.
+	skip int
+	// pos is the next frame to read.
+	pos    int
+	closed bool
+}
+
+// OpenCapture starts ffmpeg capturing from device through api, one of the
+// inputs ParseCapturePath accepts, and returns a source delivering its frames
+// as they arrive, e.g. to compare the output of a live encoder captured over
+// SDI or HDMI with the program feed it encodes. The source cannot seek, and a
+// reader that falls behind the device makes ffmpeg or the driver drop
+// frames. Two captures are only as much in step as their start times, which
+// Skip compensates at frame granularity.
+func OpenCapture(api, device string, opts CaptureOptions) (video.Source,
+	error) {
+	opts.setDefaults()
+	if _, _, ok := ParseCapturePath(api + ":" + device); !ok {
+		return nil, fmt.Errorf("unsupported capture device %s:%s, expected "+
+			"one of %v", api, device, captureAPIs)
+	}
+	switch {
+	case opts.Props.Width <= 0 || opts.Props.Height <= 0:
+		return nil, fmt.Errorf("invalid capture size %dx%d",
+			opts.Props.Width, opts.Props.Height)
+	case opts.FrameRate <= 0:
+		return nil, fmt.Errorf("capture frame rate must be positive, got %g",
+			opts.FrameRate)
+	case opts.Frames <= 0:
+		return nil, fmt.Errorf("capture length must be positive, got %d "+
+			"frames", opts.Frames)
+	case opts.Skip < 0:
+		return nil, fmt.Errorf("cannot skip %d captured frames", opts.Skip)
+	}
+
+	pixelFormat := pixfmts.GetPixFmtName(opts.Props.PixelFormat)
+	if pixelFormat == "" {
+		return nil, fmt.Errorf("unknown capture pixel format %d",
+			opts.Props.PixelFormat)
+	}
+	rowBytes, rows, numPlanes, err := opts.Props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &captureSource{props: opts.Props, frameRate: opts.FrameRate,
+		numFrames: opts.Frames, numPlanes: numPlanes, skip: opts.Skip,
+		log: &captureLog{}}
+	for i := range numPlanes {
+		s.planeStrides[i] = rowBytes[i]
+		s.planeSizes[i] = rowBytes[i] * rows[i]
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-f", api}
+	if api == "v4l2" {
+		args = append(args, "-framerate", formatFrameRate(opts.FrameRate))
+	}
+	args = append(args, opts.InputArgs...)
+	args = append(args, "-i", device, "-an",
+		"-frames:v", strconv.Itoa(opts.Skip+opts.Frames),
+		"-r", formatFrameRate(opts.FrameRate),
+		"-s", fmt.Sprintf("%dx%d", opts.Props.Width, opts.Props.Height),
+		"-pix_fmt", pixelFormat, "-f", "rawvideo", "-")
+
+	s.cmd = exec.Command(opts.Binary, args...)
+	s.cmd.Stderr = s.log
+	if opts.Stderr != nil {
+		s.cmd.Stderr = io.MultiWriter(s.log, opts.Stderr)
+	}
+	if s.stdout, err = s.cmd.StdoutPipe(); err != nil {
+		return nil, fmt.Errorf("failed to get ffmpeg stdout pipe: %w", err)
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	return s, nil
+}
+
+// formatFrameRate formats fps for the ffmpeg command line.
+func formatFrameRate(fps float32) string {
+	return strconv.FormatFloat(float64(fps), 'f', -1, 32)
+}
+
+func (s *captureSource) GetFrame(frame video.Frame) error {
+	if s.pos >= s.numFrames {
+		return fmt.Errorf("frame %d is past the end of the %d frame capture",
+			s.pos, s.numFrames)
+	}
+
+	for ; s.skip > 0; s.skip-- {
+		if err := s.readFrame(frame); err != nil {
+			return err
+		}
+	}
+	if err := s.readFrame(frame); err != nil {
+		return err
+	}
+
+	if metadata := frame.Metadata(); metadata != nil {
+		clear(metadata)
+		metadata[video.MetaPTS] = float64(s.pos) / float64(s.frameRate)
+	}
+
+	s.pos++
+	return nil
+}
+
+// readFrame reads the next captured frame into frame.
+func (s *captureSource) readFrame(frame video.Frame) error {
+	for i := range s.numPlanes {
+		dst := frame.PlaneData(i)
+		if len(dst) < s.planeSizes[i] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", i, s.planeSizes[i], len(dst))
+		}
+		if _, err := io.ReadFull(s.stdout, dst[:s.planeSizes[i]]); err != nil {
+			return s.captureError(err)
+		}
+	}
+	return nil
+}
+
+// captureError returns the error of a capture that stopped delivering frames,
+// with the reason ffmpeg gives for it.
+func (s *captureSource) captureError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = errors.New("ffmpeg stopped delivering frames")
+	}
+	if tail := strings.TrimSpace(s.log.String()); tail != "" {
+		return fmt.Errorf("capture ended at frame %d: %w: %s", s.pos, err,
+			tail)
+	}
+	return fmt.Errorf("capture ended at frame %d: %w", s.pos, err)
+}
+
+func (s *captureSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *captureSource) GetNumFrames() int                     { return s.numFrames }
+func (s *captureSource) GetFrameRate() float32                 { return s.frameRate }
+
+func (s *captureSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close stops ffmpeg. A capture closed before its last frame was read is
+// killed, as devices deliver frames for as long as they are read.
+func (s *captureSource) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	finished := s.pos >= s.numFrames
+	if !finished {
+		s.cmd.Process.Kill()
+	}
+	s.stdout.Close()
+	err := s.cmd.Wait()
+	if err == nil || !finished {
+		return nil
+	}
+	if tail := strings.TrimSpace(s.log.String()); tail != "" {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, tail)
+	}
+	return fmt.Errorf("ffmpeg failed: %w", err)
+}
+
+// captureLog keeps the last captureLogTail bytes of the ffmpeg log. ffmpeg
+// writes it from another goroutine than the reader of the source.
+type captureLog struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (l *captureLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data = append(l.data, p...)
+	if excess := len(l.data) - captureLogTail; excess > 0 {
+		l.data = append(l.data[:0], l.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+func (l *captureLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return string(l.data)
+}
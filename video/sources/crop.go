@@ -0,0 +1,172 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/internal/pixops"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// croppedSource wraps a planar source and delivers a rectangle of every
+// frame.
+type croppedSource struct {
+	Passthrough
+	props video.ColorProperties
+
+	numPlanes int
+	// The offset of the rectangle in bytes and rows, and its size in bytes
+	// per row and rows, in each plane.
+	offsets, firstRows [video.MaxPlanes]int
+	rowBytes, rows     [video.MaxPlanes]int
+
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// scratch receives the uncropped frame from source.
+	scratch video.Frame
+}
+
+// Crop returns a source delivering rect of every frame of source, after
+// aligning rect with AlignCrop. source is returned unchanged if that covers
+// the whole frame.
+//
+// Returns an error for packed formats, which must go through Planarize
+// first, and for rects outside of the frame.
+func Crop(source video.Source, rect video.Rect) (video.Source, error) {
+	props := *source.GetColorProps()
+
+	rect, err := AlignCrop(rect, &props)
+	if err != nil {
+		return nil, err
+	}
+	if rect == (video.Rect{Width: props.Width, Height: props.Height}) {
+		return source, nil
+	}
+
+	layout, log2W, log2H, err := cropLayout(&props)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	sampleSize := 1
+	if depth > 8 {
+		sampleSize = 2
+	}
+
+	s := &croppedSource{Passthrough: Passthrough{Source: source},
+		numPlanes: layout.NumPlanes()}
+	for i := range s.numPlanes {
+		x, y, width, height := rect.X, rect.Y, rect.Width, rect.Height
+		// Planes 1 and 2 are chroma for YUV layouts, alpha is full size.
+		if i == 1 || i == 2 {
+			x, y = x>>log2W, y>>log2H
+			width, height = -((-width) >> log2W), -((-height) >> log2H)
+		}
+
+		s.offsets[i], s.firstRows[i] = x*sampleSize, y
+		s.rowBytes[i], s.rows[i] = width*sampleSize, height
+		s.planeStrides[i] = s.rowBytes[i]
+		s.planeSizes[i] = s.rowBytes[i] * height
+	}
+
+	props.Width, props.Height = rect.Width, rect.Height
+	s.props = props
+
+	if s.scratch, err = video.NewFrameFor(source); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// AlignCrop clips rect to the frames described by props and grows it to the
+// chroma sampling grid, so that every plane is cropped by whole samples. It
+// returns the rect Crop delivers.
+func AlignCrop(rect video.Rect, props *video.ColorProperties) (video.Rect,
+	error) {
+	_, log2W, log2H, err := cropLayout(props)
+	if err != nil {
+		return video.Rect{}, err
+	}
+
+	x0, y0 := max(rect.X, 0), max(rect.Y, 0)
+	x1 := min(rect.X+rect.Width, props.Width)
+	y1 := min(rect.Y+rect.Height, props.Height)
+	if x0 >= x1 || y0 >= y1 {
+		return video.Rect{}, fmt.Errorf("crop %dx%d+%d+%d is outside of the "+
+			"%dx%d frame", rect.Width, rect.Height, rect.X, rect.Y,
+			props.Width, props.Height)
+	}
+
+	x0, y0 = x0>>log2W<<log2W, y0>>log2H<<log2H
+	x1 = min(-((-x1)>>log2W)<<log2W, props.Width)
+	y1 = min(-((-y1)>>log2H)<<log2H, props.Height)
+
+	return video.Rect{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0}, nil
+}
+
+// cropLayout returns the plane layout of props and the chroma subsampling of
+// its planes 1 and 2, which is 0 for layouts without chroma planes.
+func cropLayout(props *video.ColorProperties) (layout video.PlaneLayout,
+	log2W, log2H int, err error) {
+	if layout, err = props.Layout(); err != nil {
+		return layout, 0, 0, err
+	}
+
+	switch layout {
+	case video.LayoutPacked:
+		return layout, 0, 0, errors.New("cannot crop packed frames, " +
+			"planarize them first")
+	case video.LayoutYUV, video.LayoutYUVA:
+		pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+		if err != nil {
+			return layout, 0, 0, err
+		}
+		return layout, pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH(),
+			nil
+	default:
+		return layout, 0, 0, nil
+	}
+}
+
+func (s *croppedSource) GetFrame(frame video.Frame) error {
+	if err := s.Source.GetFrame(s.scratch); err != nil {
+		return err
+	}
+
+	for plane := range s.numPlanes {
+		src, srcStride := s.scratch.PlaneData(plane),
+			s.scratch.PlaneLineSize(plane)
+		dst, dstStride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+
+		if len(dst) < s.planeSizes[plane] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", plane, s.planeSizes[plane], len(dst))
+		}
+
+		first := s.firstRows[plane]*srcStride + s.offsets[plane]
+		pixops.CopyPlane(dst, dstStride, src[first:], srcStride,
+			s.rowBytes[plane], s.rows[plane])
+	}
+
+	frame.CopyMetadataFrom(&s.scratch)
+	return nil
+}
+
+func (s *croppedSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *croppedSource) GetNumFrames() int                     { return s.Source.GetNumFrames() }
+func (s *croppedSource) GetFrameRate() float32                 { return s.Source.GetFrameRate() }
+
+func (s *croppedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close closes the wrapped source.
+func (s *croppedSource) Close() error { return s.Source.Close() }
@@ -0,0 +1,11 @@
+// Package sources implements video.Source for the input formats supported by
+// gometrics.
+//
+// The FFMS2 backed source requires cgo and is excluded from builds using the
+// nocgo tag. NewMemorySource delivers frames held in memory, for tests and
+// generated content, without any file or cgo dependency, and OpenImage reads
+// PNG and JPEG still images into one. An IndexCache keeps the ffms2 indexes
+// of files across runs. Tee shares the frames of one decoder between several
+// readers, and Pace delivers frames at the rate of a simulated live feed.
+// OpenCapture reads a live feed from a V4L2 or DeckLink device through ffmpeg.
+package sources
@@ -0,0 +1,116 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// fallbackSeekModes lists the seek modes frames are retried with, from the
+// least to the most reliable. Linear seeking decodes from the start of the
+// file when it has to and only fails on frames that cannot be decoded at all.
+var fallbackSeekModes = []ffms.SeekMode{ffms.SeekUnsafe, ffms.SeekNormal,
+	ffms.SeekLinear}
+
+// seekFallback retries frames that failed to decode with handles opened in
+// progressively safer seek modes, as containers with broken seek points make
+// ffms2 fail mid stream where decoding up to the frame still works. Handles
+// are opened on the first frame that needs them and shared by every handle of
+// a source, so retries are serialized.
+type seekFallback struct {
+	path           string
+	index          *ffms.Index
+	track, threads int
+
+	mu sync.Mutex
+	// modes are the seek modes safer than that of the source, handles[i]
+	// the handle opened in modes[i] or nil.
+	modes   []ffms.SeekMode
+	handles []*ffms.VideoSource
+}
+
+// newSeekFallback returns the fallback for handles of track opened in mode,
+// or nil if no seek mode is safer. The fallback takes ownership of index.
+func newSeekFallback(path string, index *ffms.Index, track, threads int,
+	mode ffms.SeekMode) *seekFallback {
+	var modes []ffms.SeekMode
+	for _, fallback := range fallbackSeekModes {
+		if fallback < mode {
+			modes = append(modes, fallback)
+		}
+	}
+	if len(modes) == 0 {
+		return nil
+	}
+
+	return &seekFallback{path: path, index: index, track: track,
+		threads: threads, modes: modes,
+		handles: make([]*ffms.VideoSource, len(modes))}
+}
+
+// decodeInto decodes frame frameNumber of handle into buffer, retrying with
+// the safer seek modes of f if it fails for another reason than a format
+// change, see decodeInto. Frames decoded by a fallback are marked with
+// video.MetaSeekFallback. A nil f only decodes with handle.
+func (f *seekFallback) decodeInto(handle *ffms.VideoSource,
+	props *video.ColorProperties, frameNumber int, buffer video.Frame) error {
+	err := decodeInto(handle, props, frameNumber, buffer)
+	var formatChange *video.FormatChangeError
+	if err == nil || f == nil || errors.As(err, &formatChange) {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	errs := []error{err}
+	for i, mode := range f.modes {
+		if f.handles[i] == nil {
+			f.handles[i], _, err = ffms.CreateVideoSource(f.path, f.index,
+				f.track, f.threads, mode)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("seek mode %s: %w", mode, err))
+				continue
+			}
+		}
+
+		err = decodeInto(f.handles[i], props, frameNumber, buffer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("seek mode %s: %w", mode, err))
+			continue
+		}
+		if metadata := buffer.Metadata(); metadata != nil {
+			metadata[video.MetaSeekFallback] = mode.String()
+		}
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// close destroys the fallback handles and the index.
+func (f *seekFallback) close() error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var errs []error
+	for _, handle := range f.handles {
+		if handle != nil {
+			errs = append(errs, handle.Close())
+		}
+	}
+	clear(f.handles)
+	if f.index != nil {
+		errs = append(errs, f.index.Close())
+		f.index = nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"fmt"
+
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+)
+
+// SeekMode selects how ffms2 reaches a requested frame, trading speed for
+// robustness against containers with broken seek points.
+type SeekMode int
+
+const (
+	// SeekDefault is SeekNormal.
+	SeekDefault SeekMode = iota
+	// SeekLinearNoRewind decodes linearly and fails on frames before the
+	// last one decoded. Only suited to strictly sequential reads.
+	SeekLinearNoRewind
+	// SeekLinear decodes linearly, from the start of the file when seeking
+	// backwards. Slow but as reliable as decoding gets.
+	SeekLinear
+	// SeekNormal seeks to the keyframes reported by the container and
+	// decodes from there, failing if it cannot tell where it landed.
+	SeekNormal
+	// SeekUnsafe seeks like SeekNormal but guesses where it landed instead of
+	// failing. Faster on files whose seek points are unreliable, at the risk
+	// of returning the wrong frame.
+	SeekUnsafe
+	// SeekAggressive seeks like SeekUnsafe and does so more eagerly. Fastest
+	// on sparse reads, least reliable.
+	SeekAggressive
+)
+
+var seekModeNames = []string{"default", "linear-no-rewind", "linear",
+	"normal", "unsafe", "aggressive"}
+
+func (m SeekMode) String() string {
+	if m < 0 || int(m) >= len(seekModeNames) {
+		return fmt.Sprintf("SeekMode(%d)", int(m))
+	}
+	return seekModeNames[m]
+}
+
+// ParseSeekMode returns the SeekMode named name, as returned by String.
+func ParseSeekMode(name string) (SeekMode, error) {
+	for i, modeName := range seekModeNames {
+		if name == modeName {
+			return SeekMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown seek mode %q, expected one of %v", name,
+		seekModeNames)
+}
+
+// ffms returns the ffms2 seek mode of m.
+func (m SeekMode) ffms() (ffms.SeekMode, error) {
+	switch m {
+	case SeekDefault, SeekNormal:
+		return ffms.SeekNormal, nil
+	case SeekLinearNoRewind:
+		return ffms.SeekLinearNoRw, nil
+	case SeekLinear:
+		return ffms.SeekLinear, nil
+	case SeekUnsafe:
+		return ffms.SeekUnsafe, nil
+	case SeekAggressive:
+		return ffms.SeekAggressive, nil
+	default:
+		return 0, fmt.Errorf("invalid seek mode %d", int(m))
+	}
+}
+
+// IndexErrorMode selects what indexing does about a track it cannot decode.
+type IndexErrorMode int
+
+const (
+	// IndexErrorAbort fails indexing.
+	IndexErrorAbort IndexErrorMode = iota
+	// IndexErrorClearTrack indexes no frames of the track, so opening it
+	// fails later.
+	IndexErrorClearTrack
+	// IndexErrorStopTrack keeps the frames indexed up to the error, so the
+	// track ends early.
+	IndexErrorStopTrack
+	// IndexErrorIgnore skips the undecodable packets and keeps indexing.
+	IndexErrorIgnore
+)
+
+var indexErrorModeNames = []string{"abort", "clear-track", "stop-track",
+	"ignore"}
+
+func (m IndexErrorMode) String() string {
+	if m < 0 || int(m) >= len(indexErrorModeNames) {
+		return fmt.Sprintf("IndexErrorMode(%d)", int(m))
+	}
+	return indexErrorModeNames[m]
+}
+
+// ParseIndexErrorMode returns the IndexErrorMode named name, as returned by
+// String.
+func ParseIndexErrorMode(name string) (IndexErrorMode, error) {
+	for i, modeName := range indexErrorModeNames {
+		if name == modeName {
+			return IndexErrorMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown index error mode %q, expected one of %v",
+		name, indexErrorModeNames)
+}
+
+// ffms returns the ffms2 index error handling of m.
+func (m IndexErrorMode) ffms() (ffms.IndexErrorHandling, error) {
+	switch m {
+	case IndexErrorAbort:
+		return ffms.IEHAbort, nil
+	case IndexErrorClearTrack:
+		return ffms.IEHClearTrack, nil
+	case IndexErrorStopTrack:
+		return ffms.IEHStopTrack, nil
+	case IndexErrorIgnore:
+		return ffms.IEHIgnore, nil
+	default:
+		return 0, fmt.Errorf("invalid index error mode %d", int(m))
+	}
+}
@@ -0,0 +1,219 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// FieldOrder tells which field of a frame SeparateFields delivers first.
+type FieldOrder int
+
+const (
+	// FieldOrderAuto takes the order of every frame from its
+	// video.MetaTopFieldFirst metadata, top field first if it has none.
+	FieldOrderAuto FieldOrder = iota
+	// TopFieldFirst delivers the top field of every frame first.
+	TopFieldFirst
+	// BottomFieldFirst delivers the bottom field of every frame first.
+	BottomFieldFirst
+)
+
+func (o FieldOrder) String() string {
+	switch o {
+	case TopFieldFirst:
+		return "tff"
+	case BottomFieldFirst:
+		return "bff"
+	default:
+		return "auto"
+	}
+}
+
+// fieldSource delivers the fields of a source as frames of half its height.
+type fieldSource struct {
+	source video.Source
+	order  FieldOrder
+	props  video.ColorProperties
+
+	numPlanes int
+	// The rows of a field in each plane.
+	rows [video.MaxPlanes]int
+
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// held is the last frame read from source and heldIndex its index, -1
+	// for none.
+	held      video.Frame
+	heldIndex int
+	// next is the frame the wrapped source delivers next.
+	next int
+	// pos is the next field to deliver.
+	pos int
+}
+
+// SeparateFields returns a source delivering the two fields of every frame of
+// source as separate frames in display order, at twice the frame rate and
+// half the height. Comparing fields shows the combing and blending of poor
+// deinterlacing, which comparing whole frames hides.
+//
+// The height of source must be a multiple of twice the vertical chroma
+// subsampling, so every field has whole chroma rows, and source must have a
+// planar layout. Reading the fields in any order but sequentially needs
+// source to be seekable. Closing the returned source closes source.
+func SeparateFields(source video.Source, order FieldOrder) (video.Source,
+	error) {
+	props := *source.GetColorProps()
+
+	layout, _, log2H, err := cropLayout(&props)
+	if err != nil {
+		return nil, err
+	}
+	if props.Height%(2<<log2H) != 0 {
+		return nil, fmt.Errorf("cannot separate the fields of %d rows with "+
+			"chroma subsampled by %d", props.Height, 1<<log2H)
+	}
+
+	s := &fieldSource{source: source, order: order,
+		numPlanes: layout.NumPlanes(), heldIndex: -1}
+
+	_, rows, _, err := props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+	_, strides := source.GetPlaneSizes()
+	for i := range s.numPlanes {
+		s.rows[i] = rows[i] / 2
+		s.planeStrides[i] = strides[i]
+		s.planeSizes[i] = strides[i] * s.rows[i]
+	}
+
+	props.Height /= 2
+	s.props = props
+
+	if s.held, err = video.NewFrameFor(source); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fieldSource) GetFrame(frame video.Frame) error {
+	if s.pos >= s.GetNumFrames() {
+		return fmt.Errorf("frame %d out of range [0, %d)", s.pos,
+			s.GetNumFrames())
+	}
+
+	if i := s.pos / 2; i != s.heldIndex {
+		if err := s.read(i); err != nil {
+			return err
+		}
+	}
+
+	second := s.pos%2 == 1
+	// The bottom field starts at row 1.
+	first := 0
+	if second == s.topFieldFirst() {
+		first = 1
+	}
+
+	for plane := range s.numPlanes {
+		dst, stride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+		if len(dst) < s.planeSizes[plane] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", plane, s.planeSizes[plane], len(dst))
+		}
+
+		for y := range s.rows[plane] {
+			copy(dst[y*stride:(y+1)*stride], s.held.Row(plane, 2*y+first))
+		}
+	}
+
+	frame.CopyMetadataFrom(&s.held)
+	if meta := frame.Metadata(); second && meta != nil {
+		if pts, ok := meta[video.MetaPTS].(float64); ok {
+			meta[video.MetaPTS] = pts + 1/float64(s.GetFrameRate())
+		}
+	}
+
+	s.pos++
+	return nil
+}
+
+// topFieldFirst reports whether the held frame shows its top field first.
+func (s *fieldSource) topFieldFirst() bool {
+	switch s.order {
+	case TopFieldFirst:
+		return true
+	case BottomFieldFirst:
+		return false
+	}
+	tff, ok := s.held.Metadata()[video.MetaTopFieldFirst].(bool)
+	return tff || !ok
+}
+
+// read decodes source frame i into held.
+func (s *fieldSource) read(i int) error {
+	if s.next != i {
+		seekable, ok := s.source.(video.SeekableSource)
+		if !ok {
+			return errors.New("wrapped source does not support seeking")
+		}
+		if err := seekable.SeekFrame(i); err != nil {
+			return err
+		}
+		s.next = i
+	}
+
+	// Forget the frame first, so a failed read does not leave a stale one.
+	s.heldIndex = -1
+	if err := s.source.GetFrame(s.held); err != nil {
+		return err
+	}
+	s.heldIndex = i
+	s.next = i + 1
+	return nil
+}
+
+func (s *fieldSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *fieldSource) GetNumFrames() int                     { return 2 * s.source.GetNumFrames() }
+func (s *fieldSource) GetFrameRate() float32                 { return 2 * s.source.GetFrameRate() }
+
+func (s *fieldSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// field n.
+func (s *fieldSource) SeekFrame(n int) error {
+	if n < 0 || n >= s.GetNumFrames() {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			s.GetNumFrames())
+	}
+	s.pos = n
+	return nil
+}
+
+// GetKeyFrames returns the first field of every keyframe of the wrapped
+// source, if it supports keyframe lookup.
+func (s *fieldSource) GetKeyFrames() ([]int, error) {
+	keyFrameSource, ok := s.source.(video.KeyFrameSource)
+	if !ok {
+		return nil, errors.New("wrapped source does not support keyframe lookup")
+	}
+	keyFrames, err := keyFrameSource.GetKeyFrames()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]int, len(keyFrames))
+	for i, frame := range keyFrames {
+		fields[i] = 2 * frame
+	}
+	return fields, nil
+}
+
+// Close closes the wrapped source.
+func (s *fieldSource) Close() error { return s.source.Close() }
@@ -0,0 +1,95 @@
+package sources
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Registers the JPEG decoder with image.Decode.
+	_ "image/png"  // Registers the PNG decoder with image.Decode.
+	"os"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// OpenImage opens the PNG or JPEG still image at path as a one frame source,
+// see NewImageSource.
+func OpenImage(path string) (video.Source, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	source, err := NewImageSource(img)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return source, nil
+}
+
+// NewImageSource returns a one frame source holding img as full range sRGB
+// planar GBR, 16-bit for images with 16-bit samples and 8-bit otherwise. The
+// alpha channel is dropped without compositing, so transparent areas compare
+// by their color where the image stores it. The source runs at one frame per second.
+func NewImageSource(img image.Image) (video.Source, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	props := video.ColorProperties{Width: width, Height: height,
+		PixelFormat:    pixfmts.PixFmtGBRP,
+		ColorRange:     pixfmts.ColorRangeJPEG,
+		ColorSpace:     pixfmts.ColorSpaceRGB,
+		ColorTransfer:  pixfmts.ColorTransferCharacteristicIEC61966_2_1,
+		ColorPrimaries: pixfmts.ColorPrimariesBT709}
+
+	sampleBytes := 1
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		props.PixelFormat = pixfmts.PixFmtGBRP16LE
+		sampleBytes = 2
+	}
+
+	var planes [3][]byte
+	for i := range planes {
+		planes[i] = make([]byte, width*height*sampleBytes)
+	}
+
+	for y := range height {
+		for x := range width {
+			c := nrgba64At(img, bounds.Min.X+x, bounds.Min.Y+y)
+			offset := (y*width + x) * sampleBytes
+			// GBR plane order.
+			for i, sample := range [3]uint16{c.G, c.B, c.R} {
+				if sampleBytes == 2 {
+					binary.LittleEndian.PutUint16(planes[i][offset:], sample)
+				} else {
+					planes[i][offset] = byte(sample >> 8)
+				}
+			}
+		}
+	}
+
+	return NewMemorySource([][3][]byte{planes}, props, 1)
+}
+
+// nrgba64At returns the color of the pixel of img at x, y without alpha
+// premultiplied. Images storing premultiplied colors lose the color of fully
+// transparent pixels.
+func nrgba64At(img image.Image, x, y int) color.NRGBA64 {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		c := img.NRGBAAt(x, y)
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101,
+			B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	case *image.NRGBA64:
+		return img.NRGBA64At(x, y)
+	default:
+		return color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+	}
+}
@@ -0,0 +1,214 @@
+package sources
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// indexCacheExt is the extension of the index files of an IndexCache.
+const indexCacheExt = ".ffindex"
+
+// The content key of a file hashes its size and indexKeySamples evenly spread
+// samples of indexKeySampleSize bytes, which tells files apart as reliably as
+// hashing all of it without reading gigabytes on every run.
+const (
+	indexKeySamples    = 16
+	indexKeySampleSize = 64 << 10
+)
+
+// IndexCache is a directory of ffms2 indexes keyed by the content of the file
+// they index, so that comparisons against the same references never index
+// them twice, even when the files are renamed or copied. The cache may be
+// shared by concurrent runs and processes: indexes are written to a temporary
+// file and renamed into place, so readers see a whole index or none.
+//
+// Once the indexes exceed the size limit the least recently used are removed.
+// Reading an index marks it used by updating its modification time.
+type IndexCache struct {
+	dir      string
+	maxBytes int64
+}
+
+// OpenIndexCache opens the cache in dir, creating the directory if needed.
+// maxBytes limits the total size of the indexes, 0 for no limit.
+func OpenIndexCache(dir string, maxBytes int64) (*IndexCache, error) {
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("index cache size must not be negative, "+
+			"got %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("index cache: %w", err)
+	}
+	return &IndexCache{dir: dir, maxBytes: maxBytes}, nil
+}
+
+// Dir returns the directory of the cache.
+func (c *IndexCache) Dir() string { return c.dir }
+
+// Key returns the content key the index of the file at path is cached under.
+func (c *IndexCache) Key(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, info.Size())
+
+	sample := make([]byte, indexKeySampleSize)
+	step := max(info.Size()-indexKeySampleSize, 0) / (indexKeySamples - 1)
+	for i := range int64(indexKeySamples) {
+		n, err := file.ReadAt(sample, i*step)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		hash.Write(sample[:n])
+		if step == 0 {
+			break
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Get returns the cached index with the given key, or false if there is none.
+func (c *IndexCache) Get(key string) ([]byte, bool, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("index cache: %w", err)
+	}
+
+	// The index may have been evicted by another process in the meantime,
+	// which only costs it its place in the LRU order.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true, nil
+}
+
+// Put stores an index under key, replacing any index stored before, and
+// evicts the least recently used indexes beyond the size limit.
+func (c *IndexCache) Put(key string, index []byte) error {
+	temp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("index cache: %w", err)
+	}
+	_, err = temp.Write(index)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("index cache: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Remove deletes the index stored under key, e.g. because it was found to be
+// unreadable.
+func (c *IndexCache) Remove(key string) error {
+	err := os.Remove(c.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("index cache: %w", err)
+	}
+	return nil
+}
+
+// Size returns the total size of the cached indexes in bytes.
+func (c *IndexCache) Size() (int64, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size()
+	}
+	return total, nil
+}
+
+// evict removes the least recently used indexes until the cache fits its
+// size limit.
+func (c *IndexCache) evict() error {
+	if c.maxBytes == 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size()
+	}
+
+	slices.SortFunc(entries, func(a, b fs.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+	for _, entry := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+		// Another process may be evicting the same index.
+		err := os.Remove(filepath.Join(c.dir, entry.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("index cache: %w", err)
+		}
+		total -= entry.Size()
+	}
+	return nil
+}
+
+// entries returns the index files of the cache.
+func (c *IndexCache) entries() ([]fs.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("index cache: %w", err)
+	}
+
+	var entries []fs.FileInfo
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() ||
+			!strings.HasSuffix(dirEntry.Name(), indexCacheExt) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("index cache: %w", err)
+		}
+		entries = append(entries, info)
+	}
+	return entries, nil
+}
+
+func (c *IndexCache) path(key string) string {
+	return filepath.Join(c.dir, key+indexCacheExt)
+}
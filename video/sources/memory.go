@@ -0,0 +1,122 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// memorySource delivers frames held in memory.
+type memorySource struct {
+	frames    [][3][]byte
+	props     video.ColorProperties
+	frameRate float32
+	numPlanes int
+
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// pos is the next frame to read.
+	pos int
+}
+
+// NewMemorySource returns a seekable source delivering frames, each holding
+// the planes of one picture described by props, at frameRate frames per
+// second. Planes are tightly packed, their line size being the bytes of one
+// visible row, and planes the pixel format does not have are left empty.
+// Frames report their presentation timestamp as video.MetaPTS.
+//
+// The source reads the frames without copying them, so they must not be
+// modified while it is in use. It needs no file or cgo and is deterministic,
+// which makes it the source for tests and for frames generated in code.
+// Formats with an alpha plane are not supported.
+func NewMemorySource(frames [][3][]byte, props video.ColorProperties,
+	frameRate float32) (video.Source, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("a memory source needs at least one frame")
+	}
+	if frameRate <= 0 {
+		return nil, fmt.Errorf("frame rate must be positive, got %g",
+			frameRate)
+	}
+	if props.Width <= 0 || props.Height <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d", props.Width,
+			props.Height)
+	}
+
+	rowBytes, rows, numPlanes, err := props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+	if numPlanes > len(frames[0]) {
+		return nil, fmt.Errorf("pixel format has %d planes, a memory "+
+			"source holds at most %d", numPlanes, len(frames[0]))
+	}
+
+	s := &memorySource{frames: frames, props: props, frameRate: frameRate,
+		numPlanes: numPlanes}
+	for i := range numPlanes {
+		s.planeStrides[i] = rowBytes[i]
+		s.planeSizes[i] = rowBytes[i] * rows[i]
+	}
+
+	for n, frame := range frames {
+		for i, plane := range frame {
+			if len(plane) != s.planeSizes[i] {
+				return nil, fmt.Errorf("frame %d plane %d has %d bytes, "+
+					"want %d", n, i, len(plane), s.planeSizes[i])
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func (s *memorySource) GetFrame(frame video.Frame) error {
+	if s.pos >= len(s.frames) {
+		return fmt.Errorf("frame %d is past the end of the %d frame source",
+			s.pos, len(s.frames))
+	}
+
+	for i := range s.numPlanes {
+		dst := frame.PlaneData(i)
+		if len(dst) < s.planeSizes[i] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", i, s.planeSizes[i], len(dst))
+		}
+		copy(dst, s.frames[s.pos][i])
+	}
+
+	if metadata := frame.Metadata(); metadata != nil {
+		clear(metadata)
+		metadata[video.MetaPTS] = float64(s.pos) / float64(s.frameRate)
+	}
+
+	s.pos++
+	return nil
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n.
+func (s *memorySource) SeekFrame(n int) error {
+	if n < 0 || n >= len(s.frames) {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			len(s.frames))
+	}
+
+	s.pos = n
+	return nil
+}
+
+func (s *memorySource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *memorySource) GetNumFrames() int                     { return len(s.frames) }
+func (s *memorySource) GetFrameRate() float32                 { return s.frameRate }
+
+func (s *memorySource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close does nothing, as the frames belong to the caller.
+func (s *memorySource) Close() error { return nil }
@@ -0,0 +1,199 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+var (
+	ErrOrientationMismatch = errors.New("sources have different orientation " +
+		"metadata")
+)
+
+// CheckOrientation returns an error wrapping ErrOrientationMismatch if a and b
+// would be displayed with different orientations. Comparing them as stored
+// would compare misaligned frames.
+func CheckOrientation(a, b video.Source) error {
+	orientationA := a.GetColorProps().Orientation
+	orientationB := b.GetColorProps().Orientation
+
+	if orientationA != orientationB {
+		return fmt.Errorf("%w: %s vs %s", ErrOrientationMismatch,
+			orientationA, orientationB)
+	}
+
+	return nil
+}
+
+// orientedSource wraps a planar source and applies its orientation metadata
+// to every frame on the CPU.
+type orientedSource struct {
+	Passthrough
+	orientation video.Orientation
+	props       video.ColorProperties
+
+	numPlanes  int
+	sampleSize int
+	// The source width and height of each plane in samples.
+	planeWidths, planeHeights [video.MaxPlanes]int
+
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// scratch receives the untransformed frame from source.
+	scratch video.Frame
+}
+
+// Orient returns source unchanged if it has no orientation metadata, otherwise
+// it is wrapped so that it delivers upright frames and reports an identity
+// orientation. Rotations by 90 or 270 degrees swap the width and height.
+//
+// Returns an error for rotations that are not a multiple of 90 degrees, for
+// packed formats, which must go through Planarize first, and for 90 or 270
+// degree rotations of formats with different horizontal and vertical chroma
+// subsampling, such as 4:2:2, as the result has no matching pixel format.
+func Orient(source video.Source) (video.Source, error) {
+	props := *source.GetColorProps()
+	orientation := props.Orientation
+
+	if orientation.IsIdentity() {
+		return source, nil
+	}
+
+	if orientation.Rotation%90 != 0 {
+		return nil, fmt.Errorf("rotation by %d degrees is not supported",
+			orientation.Rotation)
+	}
+
+	layout, err := props.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout == video.LayoutPacked {
+		return nil, errors.New("cannot orient packed frames, planarize them " +
+			"first")
+	}
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	log2W, log2H := pixFmtDesc.Log2ChromaW(), pixFmtDesc.Log2ChromaH()
+	transposed := orientation.Rotation == 90 || orientation.Rotation == 270
+	if transposed && log2W != log2H {
+		return nil, fmt.Errorf("cannot rotate %s by %d degrees, its chroma "+
+			"subsampling is not square", pixFmtDesc.Name(),
+			orientation.Rotation)
+	}
+
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &orientedSource{Passthrough: Passthrough{Source: source},
+		orientation: orientation, numPlanes: layout.NumPlanes(),
+		sampleSize: 1}
+	if depth > 8 {
+		s.sampleSize = 2
+	}
+
+	for i := range s.numPlanes {
+		s.planeWidths[i], s.planeHeights[i] = props.Width, props.Height
+		// Planes 1 and 2 are chroma for YUV layouts, alpha is full size.
+		if (i == 1 || i == 2) && layout != video.LayoutRGB &&
+			layout != video.LayoutRGBA {
+			s.planeWidths[i] = (props.Width + 1<<log2W - 1) >> log2W
+			s.planeHeights[i] = (props.Height + 1<<log2H - 1) >> log2H
+		}
+
+		outWidth, outHeight := s.planeWidths[i], s.planeHeights[i]
+		if transposed {
+			outWidth, outHeight = outHeight, outWidth
+		}
+		s.planeStrides[i] = outWidth * s.sampleSize
+		s.planeSizes[i] = s.planeStrides[i] * outHeight
+	}
+
+	if transposed {
+		props.Width, props.Height = props.Height, props.Width
+	}
+	props.Orientation = video.Orientation{}
+	s.props = props
+
+	if s.scratch, err = video.NewFrameFor(source); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// transform maps a source sample position to its oriented position. The flip
+// is applied first, then the clockwise rotation.
+func (s *orientedSource) transform(x, y, width, height int) (int, int) {
+	if s.orientation.FlipHorizontal {
+		x = width - 1 - x
+	}
+	if s.orientation.FlipVertical {
+		y = height - 1 - y
+	}
+
+	switch s.orientation.Rotation {
+	case 90:
+		return height - 1 - y, x
+	case 180:
+		return width - 1 - x, height - 1 - y
+	case 270:
+		return y, width - 1 - x
+	default:
+		return x, y
+	}
+}
+
+func (s *orientedSource) GetFrame(frame video.Frame) error {
+	if err := s.Source.GetFrame(s.scratch); err != nil {
+		return err
+	}
+
+	for plane := range s.numPlanes {
+		src, srcStride := s.scratch.PlaneData(plane),
+			s.scratch.PlaneLineSize(plane)
+		dst, dstStride := frame.PlaneData(plane), frame.PlaneLineSize(plane)
+
+		if len(dst) < s.planeSizes[plane] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", plane, s.planeSizes[plane], len(dst))
+		}
+
+		width, height := s.planeWidths[plane], s.planeHeights[plane]
+
+		for y := range height {
+			for x := range width {
+				outX, outY := s.transform(x, y, width, height)
+				srcOffset := y*srcStride + x*s.sampleSize
+				dstOffset := outY*dstStride + outX*s.sampleSize
+				copy(dst[dstOffset:dstOffset+s.sampleSize],
+					src[srcOffset:srcOffset+s.sampleSize])
+			}
+		}
+	}
+
+	frame.CopyMetadataFrom(&s.scratch)
+	return nil
+}
+
+func (s *orientedSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *orientedSource) GetNumFrames() int                     { return s.Source.GetNumFrames() }
+func (s *orientedSource) GetFrameRate() float32                 { return s.Source.GetFrameRate() }
+
+func (s *orientedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close closes the wrapped source.
+func (s *orientedSource) Close() error { return s.Source.Close() }
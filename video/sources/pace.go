@@ -0,0 +1,106 @@
+package sources
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Pacer is the clock of a simulated live feed, in which frame n arrives n
+// frame durations after the first frame is read. Sources paced by the same
+// Pacer deliver frames in step, like the two inputs of a live monitor. It is
+// safe for concurrent use.
+type Pacer struct {
+	frameDuration time.Duration
+
+	mu sync.Mutex
+	// start is when the first frame was read, zero before.
+	start  time.Time
+	maxLag time.Duration
+}
+
+// NewPacer returns a Pacer delivering frameRate frames per second.
+func NewPacer(frameRate float64) (*Pacer, error) {
+	if frameRate <= 0 {
+		return nil, fmt.Errorf("cannot pace frames at %g fps", frameRate)
+	}
+	return &Pacer{frameDuration: time.Duration(float64(time.Second) /
+		frameRate)}, nil
+}
+
+// Due returns the time frame n arrives, the zero time before any frame is
+// read.
+func (p *Pacer) Due(n int) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.start.IsZero() {
+		return time.Time{}
+	}
+	return p.start.Add(time.Duration(n) * p.frameDuration)
+}
+
+// MaxLag returns the longest a paced source was read after its frame
+// arrived, which grows when the reader cannot keep up with the feed.
+func (p *Pacer) MaxLag() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.maxLag
+}
+
+// wait blocks until frame n arrives, starting the clock on the first frame.
+func (p *Pacer) wait(n int) {
+	p.mu.Lock()
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	due := p.start.Add(time.Duration(n) * p.frameDuration)
+	lag := time.Since(due)
+	if lag > 0 {
+		p.maxLag = max(p.maxLag, lag)
+	}
+	p.mu.Unlock()
+
+	if lag < 0 {
+		time.Sleep(-lag)
+	}
+}
+
+// pacedSource delivers the frames of a source no earlier than its Pacer.
+type pacedSource struct {
+	source video.Source
+	pacer  *Pacer
+	// next is the frame delivered next.
+	next int
+}
+
+// Pace returns a source delivering the frames of source in order, each no
+// earlier than pacer says it arrives, to find out whether a comparison keeps
+// up with a live feed. The returned source cannot seek. Closing it closes
+// source.
+func Pace(source video.Source, pacer *Pacer) video.Source {
+	return &pacedSource{source: source, pacer: pacer}
+}
+
+func (s *pacedSource) GetFrame(frame video.Frame) error {
+	s.pacer.wait(s.next)
+	if err := s.source.GetFrame(frame); err != nil {
+		return err
+	}
+	s.next++
+	return nil
+}
+
+func (s *pacedSource) GetColorProps() *video.ColorProperties {
+	return s.source.GetColorProps()
+}
+func (s *pacedSource) GetNumFrames() int     { return s.source.GetNumFrames() }
+func (s *pacedSource) GetFrameRate() float32 { return s.source.GetFrameRate() }
+
+func (s *pacedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.source.GetPlaneSizes()
+}
+
+func (s *pacedSource) Close() error { return s.source.Close() }
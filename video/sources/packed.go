@@ -0,0 +1,163 @@
+package sources
+
+import (
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// rgbComponentPlanes maps libav's RGB component order (R, G, B, A) to the
+// planes of the GBR(A) planar formats.
+var rgbComponentPlanes = [4]int{2, 0, 1, 3}
+
+// packedRGBSource wraps a source with a packed RGB pixel format and converts
+// every frame into the equivalent planar GBR or GBRA format.
+type packedRGBSource struct {
+	Passthrough
+	props video.ColorProperties
+
+	comps        []pixfmts.ComponentDescriptor
+	wide         bool
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
+
+	// scratch receives the packed frame from source before it is split into
+	// the caller's frame.
+	scratch video.Frame
+}
+
+// Planarize returns source unchanged unless it delivers packed RGB frames, in
+// which case it is wrapped so it delivers planar GBR frames instead, or GBRA if
+// the packed format has alpha. vship and the CPU color conversion only read
+// planar frames.
+//
+// Supported packed formats are 8-bit RGB24, BGR24, RGBA, BGRA, ARGB and ABGR,
+// and 16-bit little-endian RGB48 and RGBA64. Returns an error for any other
+// packed format.
+func Planarize(source video.Source) (video.Source, error) {
+	layout, err := source.GetColorProps().Layout()
+	if err != nil {
+		return nil, err
+	}
+
+	if layout != video.LayoutPacked {
+		return source, nil
+	}
+
+	return newPackedRGBSource(source)
+}
+
+func newPackedRGBSource(source video.Source) (*packedRGBSource, error) {
+	props := *source.GetColorProps()
+
+	pixFmtDesc, err := pixfmts.PixFmtDescGet(props.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := pixfmts.PixFmtFlag(pixFmtDesc.Flags())
+	if flags&pixfmts.PixFmtFlagRGB == 0 {
+		return nil, fmt.Errorf("packed YUV pixel format %s is not supported",
+			pixFmtDesc.Name())
+	}
+	if flags&(pixfmts.PixFmtFlagBigEndian|pixfmts.PixFmtFlagFloat) != 0 {
+		return nil, fmt.Errorf("packed pixel format %s is not supported, "+
+			"only little-endian integer formats are", pixFmtDesc.Name())
+	}
+
+	s := &packedRGBSource{Passthrough: Passthrough{Source: source}}
+
+	for i := range pixFmtDesc.NbComponents() {
+		comp, err := pixFmtDesc.Component(i)
+		if err != nil {
+			return nil, err
+		}
+		if comp.Shift != 0 || (comp.Depth != 8 && comp.Depth != 16) {
+			return nil, fmt.Errorf("packed pixel format %s is not supported, "+
+				"components must be whole 8 or 16-bit samples",
+				pixFmtDesc.Name())
+		}
+		s.comps = append(s.comps, comp)
+	}
+
+	s.wide = s.comps[0].Depth == 16
+	alpha := len(s.comps) == 4
+
+	switch {
+	case !s.wide && !alpha:
+		props.PixelFormat = pixfmts.PixFmtGBRP
+	case !s.wide && alpha:
+		props.PixelFormat = pixfmts.PixFmtGBRAP
+	case s.wide && !alpha:
+		props.PixelFormat = pixfmts.PixFmtGBRP16LE
+	default:
+		props.PixelFormat = pixfmts.PixFmtGBRAP16LE
+	}
+	// Packed RGB formats have no YUV matrix, whatever the container claims.
+	props.ColorSpace = pixfmts.ColorSpaceRGB
+	s.props = props
+
+	sampleSize := 1
+	if s.wide {
+		sampleSize = 2
+	}
+	for i := range s.comps {
+		s.planeStrides[i] = props.Width * sampleSize
+		s.planeSizes[i] = s.planeStrides[i] * props.Height
+	}
+
+	if s.scratch, err = video.NewFrameFor(source); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *packedRGBSource) GetFrame(frame video.Frame) error {
+	if err := s.Source.GetFrame(s.scratch); err != nil {
+		return err
+	}
+
+	src, srcStride := s.scratch.PlaneData(0), s.scratch.PlaneLineSize(0)
+
+	for i, comp := range s.comps {
+		dst := frame.PlaneData(rgbComponentPlanes[i])
+		dstStride := frame.PlaneLineSize(rgbComponentPlanes[i])
+		if len(dst) < s.planeSizes[rgbComponentPlanes[i]] {
+			return fmt.Errorf("destination plane %d too small: need %d "+
+				"bytes, have %d", rgbComponentPlanes[i],
+				s.planeSizes[rgbComponentPlanes[i]], len(dst))
+		}
+
+		for y := range s.props.Height {
+			srcRow := src[y*srcStride:]
+			dstRow := dst[y*dstStride:]
+
+			for x := range s.props.Width {
+				offset := x*comp.Step + comp.Offset
+				if s.wide {
+					dstRow[2*x], dstRow[2*x+1] = srcRow[offset],
+						srcRow[offset+1]
+				} else {
+					dstRow[x] = srcRow[offset]
+				}
+			}
+		}
+	}
+
+	frame.CopyMetadataFrom(&s.scratch)
+	return nil
+}
+
+func (s *packedRGBSource) GetColorProps() *video.ColorProperties { return &s.props }
+func (s *packedRGBSource) GetNumFrames() int                     { return s.Source.GetNumFrames() }
+func (s *packedRGBSource) GetFrameRate() float32                 { return s.Source.GetFrameRate() }
+
+func (s *packedRGBSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.planeSizes, s.planeStrides
+}
+
+// Close closes the wrapped source.
+func (s *packedRGBSource) Close() error { return s.Source.Close() }
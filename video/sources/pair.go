@@ -0,0 +1,99 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// OpenPair opens the first video tracks of the reference and distorted files
+// with NewFFms2ReaderContext, indexing both at the same time, which roughly
+// halves the startup time of a comparison. If either fails to open or the
+// pair cannot be compared, both are closed and the error names the source.
+//
+// The sources are only checked for what no later stage can reconcile: both
+// must have frames and a frame rate. Differences in resolution, frame rate or
+// length are left to the scaling and frame matching of the caller.
+func OpenPair(ctx context.Context, referencePath, distortedPath string,
+	opts FFms2Options) (reference, distorted video.Source, err error) {
+	// Indexing the other file is pointless once one fails to open.
+	openCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var referenceErr, distortedErr error
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		reference, referenceErr = NewFFms2ReaderContext(openCtx,
+			referencePath, opts)
+		if referenceErr != nil {
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		distorted, distortedErr = NewFFms2ReaderContext(openCtx,
+			distortedPath, opts)
+		if distortedErr != nil {
+			cancel()
+		}
+	}()
+	wg.Wait()
+
+	if referenceErr == nil {
+		referenceErr = checkPairSource(reference)
+	}
+	if distortedErr == nil {
+		distortedErr = checkPairSource(distorted)
+	}
+	if referenceErr == nil && distortedErr == nil {
+		return reference, distorted, nil
+	}
+
+	if reference != nil {
+		reference.Close()
+	}
+	if distorted != nil {
+		distorted.Close()
+	}
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	// A source cancelled because the other failed has nothing to report.
+	if errors.Is(referenceErr, context.Canceled) && distortedErr != nil {
+		referenceErr = nil
+	}
+	if errors.Is(distortedErr, context.Canceled) && referenceErr != nil {
+		distortedErr = nil
+	}
+	err = errors.Join(pairError("reference", referenceErr),
+		pairError("distorted", distortedErr))
+	return nil, nil, err
+}
+
+// checkPairSource returns why source cannot take part in a comparison.
+func checkPairSource(source video.Source) error {
+	if source.GetNumFrames() < 1 {
+		return errors.New("has no frames")
+	}
+	if source.GetFrameRate() <= 0 {
+		return fmt.Errorf("has invalid frame rate %g", source.GetFrameRate())
+	}
+	return nil
+}
+
+// pairError prefixes err with the source it came from.
+func pairError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s video: %w", name, err)
+}
@@ -0,0 +1,56 @@
+package sources
+
+import (
+	"errors"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// Passthrough forwards seeking, keyframe lookup, repeat field flags and the
+// start time to Source. Wrappers that keep the frame numbering of their
+// source embed it.
+type Passthrough struct {
+	Source video.Source
+}
+
+// Unwrap returns Source, so video.As sees whether the optional interfaces
+// are actually supported.
+func (p Passthrough) Unwrap() video.Source { return p.Source }
+
+// SeekFrame passes through to Source if it is seekable.
+func (p Passthrough) SeekFrame(n int) error {
+	seekable, ok := p.Source.(video.SeekableSource)
+	if !ok {
+		return errors.New("wrapped source does not support seeking")
+	}
+	return seekable.SeekFrame(n)
+}
+
+// GetKeyFrames passes through to Source if it supports keyframe lookup.
+func (p Passthrough) GetKeyFrames() ([]int, error) {
+	keyFrameSource, ok := p.Source.(video.KeyFrameSource)
+	if !ok {
+		return nil, errors.New("wrapped source does not support keyframe " +
+			"lookup")
+	}
+	return keyFrameSource.GetKeyFrames()
+}
+
+// GetRepeatFields passes through to Source if it reports repeat field flags.
+func (p Passthrough) GetRepeatFields() ([]int, error) {
+	repeatSource, ok := p.Source.(video.FieldRepeatSource)
+	if !ok {
+		return nil, errors.New("wrapped source does not report repeated " +
+			"fields")
+	}
+	return repeatSource.GetRepeatFields()
+}
+
+// GetStartTime passes through to Source if it reports its start time.
+func (p Passthrough) GetStartTime() (float64, error) {
+	startTimeSource, ok := p.Source.(video.StartTimeSource)
+	if !ok {
+		return 0, errors.New("wrapped source does not report its start time")
+	}
+	return startTimeSource.GetStartTime()
+}
@@ -0,0 +1,52 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"errors"
+
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+)
+
+// FileInfo describes the container and codec of a video file.
+type FileInfo struct {
+	// FFmpeg's long name of the container format, e.g. "Matroska / WebM".
+	Format string
+	// FFmpeg's long name of the first video track's codec.
+	Codec string
+}
+
+// ProbeFile reads the container and codec names of the file at path. It only
+// opens the file, without indexing it.
+func ProbeFile(path string) (FileInfo, error) {
+	indexer, _, err := ffms.CreateIndexer(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer indexer.Close()
+
+	var info FileInfo
+	if info.Format, err = indexer.GetFormatName(); err != nil {
+		return FileInfo{}, err
+	}
+
+	numTracks, err := indexer.GetNumTracks()
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	for track := range numTracks {
+		trackType, err := indexer.GetTrackType(ffms.TrackType(track))
+		if err != nil {
+			return FileInfo{}, err
+		}
+		if ffms.TrackType(trackType) != ffms.TypeVideo {
+			continue
+		}
+
+		info.Codec, err = indexer.GetCodecName(track)
+		return info, err
+	}
+
+	return FileInfo{}, errors.New("file has no video track")
+}
@@ -0,0 +1,148 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// remappedSource shows the frames of a source in a different order.
+type remappedSource struct {
+	source video.Source
+	// frames holds the source frame every frame shows.
+	frames    []int
+	frameRate float32
+
+	// last holds the source frame delivered last and lastIndex its index,
+	// -1 for none, so repeated frames are not decoded again.
+	last      video.Frame
+	lastIndex int
+	// next is the frame the wrapped source delivers next.
+	next int
+	// pos is the next frame to deliver.
+	pos int
+}
+
+// Remap returns a source whose frame k is frame frames[k] of source, for
+// comparing edited or trimmed copies against their master. Frames may repeat
+// and come in any order, at the cost of seeking.
+//
+// Reading frames out of the order of source needs source to be seekable.
+// Closing the returned source closes source.
+func Remap(source video.Source, frames []int) (video.Source, error) {
+	return newRemappedSource(source, frames, source.GetFrameRate())
+}
+
+// Retime returns a source showing source over the same duration in numFrames
+// frames, repeating or dropping frames evenly. Frame k of the returned source
+// is the frame of source displayed at the middle of frame k, and its frame
+// rate is scaled by numFrames over the frame count of source. This maps the
+// frames of an encode at another frame rate onto those of its reference.
+//
+// Reading the frames in any order but sequentially needs source to be
+// seekable. Closing the returned source closes source.
+func Retime(source video.Source, numFrames int) (video.Source, error) {
+	n := source.GetNumFrames()
+	if numFrames < 1 || n < 1 {
+		return nil, fmt.Errorf("cannot retime %d frames to %d", n, numFrames)
+	}
+
+	frames := make([]int, numFrames)
+	for k := range frames {
+		frames[k] = (2*k + 1) * n / (2 * numFrames)
+	}
+
+	return newRemappedSource(source, frames,
+		source.GetFrameRate()*float32(numFrames)/float32(n))
+}
+
+func newRemappedSource(source video.Source, frames []int,
+	frameRate float32) (*remappedSource, error) {
+	if len(frames) == 0 {
+		return nil, errors.New("cannot remap to no frames")
+	}
+	for _, frame := range frames {
+		if frame < 0 || frame >= source.GetNumFrames() {
+			return nil, fmt.Errorf("frame %d out of range [0, %d)", frame,
+				source.GetNumFrames())
+		}
+	}
+
+	last, err := video.NewFrameFor(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &remappedSource{source: source, frames: frames,
+		frameRate: frameRate, last: last, lastIndex: -1}, nil
+}
+
+func (s *remappedSource) GetFrame(frame video.Frame) error {
+	if s.pos >= len(s.frames) {
+		return fmt.Errorf("frame %d out of range [0, %d)", s.pos,
+			len(s.frames))
+	}
+
+	if i := s.frames[s.pos]; i != s.lastIndex {
+		if err := s.read(i); err != nil {
+			return err
+		}
+	}
+
+	if err := frame.SafeCopyFrom(&s.last); err != nil {
+		return err
+	}
+	s.pos++
+	return nil
+}
+
+// read decodes source frame i into last. Frames ahead of the wrapped source
+// are reached by skipping if it cannot seek.
+func (s *remappedSource) read(i int) error {
+	if s.next != i {
+		seekable, ok := s.source.(video.SeekableSource)
+		switch {
+		case ok:
+			if err := seekable.SeekFrame(i); err != nil {
+				return err
+			}
+			s.next = i
+		case i < s.next:
+			return errors.New("wrapped source does not support seeking")
+		}
+	}
+
+	// Forget the frame first, so a failed read does not leave a stale one.
+	s.lastIndex = -1
+	for ; s.next <= i; s.next++ {
+		if err := s.source.GetFrame(s.last); err != nil {
+			return err
+		}
+	}
+	s.lastIndex = i
+	return nil
+}
+
+func (s *remappedSource) GetColorProps() *video.ColorProperties { return s.source.GetColorProps() }
+func (s *remappedSource) GetNumFrames() int                     { return len(s.frames) }
+func (s *remappedSource) GetFrameRate() float32                 { return s.frameRate }
+
+func (s *remappedSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.source.GetPlaneSizes()
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n.
+func (s *remappedSource) SeekFrame(n int) error {
+	if n < 0 || n >= len(s.frames) {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			len(s.frames))
+	}
+	s.pos = n
+	return nil
+}
+
+// Close closes the wrapped source.
+func (s *remappedSource) Close() error { return s.source.Close() }
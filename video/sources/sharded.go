@@ -0,0 +1,316 @@
+//go:build cgo && !nocgo
+
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	ffms "github.com/GreatValueCreamSoda/gometrics/c/libffms2"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// shardedSource decodes one video track with several ffms2 VideoSource
+// handles at once, as a single handle decoding sequentially is the bottleneck
+// in front of fast GPU metrics.
+//
+// The frames from the read position on are split into ranges starting at
+// keyframes, and the ranges are dealt out to the handles in turn. Every handle
+// decodes its ranges in order into its own queue on its own goroutine, and
+// GetFrame takes the frames from the queues in frame order. As ranges start at
+// keyframes, no handle decodes frames of another handle's range to reach its
+// own.
+//
+// Decoding starts on the first GetFrame. Seeking anywhere but the next frame
+// stops the decode, so the source suits sequential reads and Slice, not
+// sampling individual frames.
+type shardedSource struct {
+	*ffmsSource
+
+	handles []*ffms.VideoSource
+	// starts holds the first frame of every range in increasing order,
+	// beginning with 0.
+	starts    []int
+	lookahead int
+
+	// run is the decode in progress, nil until the next GetFrame starts one.
+	run *shardRun
+	// cleanup stops run if the source is garbage collected mid decode.
+	cleanup runtime.Cleanup
+}
+
+func newShardedSource(source *ffmsSource, handles []*ffms.VideoSource,
+	opts FFms2Options) (*shardedSource, error) {
+	keyFrames, err := source.GetKeyFrames()
+	if err != nil {
+		return nil, fmt.Errorf("sharded decoding needs keyframes: %w", err)
+	}
+
+	starts := []int{0}
+	for _, keyFrame := range keyFrames {
+		if keyFrame < source.numFrame &&
+			keyFrame-starts[len(starts)-1] >= opts.MinShardFrames {
+			starts = append(starts, keyFrame)
+		}
+	}
+
+	return &shardedSource{ffmsSource: source, handles: handles,
+		starts: starts, lookahead: opts.ShardLookahead}, nil
+}
+
+func (s *shardedSource) GetFrame(frame video.Frame) error {
+	if s.currentIndex >= s.numFrame {
+		return fmt.Errorf("read of frame %d past the last frame %d",
+			s.currentIndex, s.numFrame-1)
+	}
+
+	if s.run == nil {
+		if err := s.startRun(s.currentIndex); err != nil {
+			return err
+		}
+	}
+
+	decoded, err := s.run.next()
+	if err != nil {
+		s.stopRun()
+		return err
+	}
+
+	err = frame.SafeCopyFrom(&decoded)
+	s.run.release(decoded)
+	if err != nil {
+		return fmt.Errorf("failed to safely copy frame data: %w", err)
+	}
+
+	s.currentIndex++
+	return nil
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n. Seeking to the next frame keeps the decode running.
+func (s *shardedSource) SeekFrame(n int) error {
+	if n < 0 || n >= s.numFrame {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			s.numFrame)
+	}
+
+	if n != s.currentIndex {
+		s.stopRun()
+		s.currentIndex = n
+	}
+	return nil
+}
+
+// startRun starts decoding every frame from position on.
+func (s *shardedSource) startRun(position int) error {
+	ranges := s.rangesFrom(position)
+
+	run := &shardRun{ranges: ranges, fallback: s.fallback,
+		props: &s.colorspace, stopCh: make(chan struct{})}
+	for _, handle := range s.handles {
+		shard, err := newShard(handle, s.planeSizes, s.planeStrides,
+			s.lookahead)
+		if err != nil {
+			run.stop()
+			return err
+		}
+		run.shards = append(run.shards, shard)
+	}
+
+	for i, shard := range run.shards {
+		run.wg.Add(1)
+		go run.decode(i, shard)
+	}
+
+	s.run = run
+	s.cleanup = runtime.AddCleanup(s, func(r *shardRun) { r.stop() }, run)
+	return nil
+}
+
+func (s *shardedSource) stopRun() {
+	if s.run == nil {
+		return
+	}
+	s.cleanup.Stop()
+	s.run.stop()
+	s.run = nil
+}
+
+// Close stops the decode in progress and destroys every VideoSource handle.
+// Calling Close more than once does nothing.
+func (s *shardedSource) Close() error {
+	s.stopRun()
+
+	var errs []error
+	for _, handle := range s.handles[1:] {
+		errs = append(errs, handle.Close())
+	}
+	s.handles = s.handles[:1]
+
+	return errors.Join(append(errs, s.ffmsSource.Close())...)
+}
+
+// rangesFrom returns the ranges covering every frame from position on. The
+// first range starts at position, the others at keyframes.
+func (s *shardedSource) rangesFrom(position int) []video.FrameRange {
+	var ranges []video.FrameRange
+	start := position
+
+	for _, next := range append(s.starts[1:], s.numFrame) {
+		if next <= start {
+			continue
+		}
+		ranges = append(ranges, video.FrameRange{Start: start,
+			Count: next - start})
+		start = next
+	}
+
+	return ranges
+}
+
+// shard is one VideoSource handle with its queue of decoded frames and the
+// buffers free to decode into.
+type shard struct {
+	handle *ffms.VideoSource
+	queue  chan shardFrame
+	free   chan video.Frame
+}
+
+// shardFrame is a decoded frame, or the error that stopped a shard.
+type shardFrame struct {
+	frame video.Frame
+	err   error
+}
+
+func newShard(handle *ffms.VideoSource, planeSizes,
+	planeStrides [video.MaxPlanes]int, lookahead int) (*shard, error) {
+	sh := &shard{handle: handle, queue: make(chan shardFrame, lookahead),
+		free: make(chan video.Frame, lookahead)}
+
+	for range lookahead {
+		frame, err := video.NewFrameSized(planeSizes, planeStrides)
+		if err != nil {
+			return nil, err
+		}
+		sh.free <- frame
+	}
+
+	return sh, nil
+}
+
+// shardRun is one decode from a read position to the end of the source.
+// Range i is decoded by shard i % len(shards).
+type shardRun struct {
+	shards []*shard
+	ranges []video.FrameRange
+	// fallback is the seek fallback of the source, shared by every shard.
+	fallback *seekFallback
+	// props describes the frames of the source.
+	props *video.ColorProperties
+
+	// current is the range being read and read the frames of it already
+	// returned by next.
+	current, read int
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// decode runs on its own goroutine and decodes every range of shard i.
+func (r *shardRun) decode(i int, sh *shard) {
+	defer r.wg.Done()
+
+	for n := i; n < len(r.ranges); n += len(r.shards) {
+		for frameNumber := r.ranges[n].Start; frameNumber < r.ranges[n].End(); frameNumber++ {
+			var buffer video.Frame
+			select {
+			case <-r.stopCh:
+				return
+			case buffer = <-sh.free:
+			}
+
+			item := shardFrame{frame: buffer}
+			err := r.fallback.decodeInto(sh.handle, r.props, frameNumber,
+				buffer)
+			if err != nil {
+				item.err = fmt.Errorf("frame %d: %w", frameNumber, err)
+			}
+
+			select {
+			case <-r.stopCh:
+				return
+			case sh.queue <- item:
+			}
+
+			if item.err != nil {
+				return
+			}
+		}
+	}
+}
+
+// decodeInto decodes frame frameNumber of handle into buffer. Frames whose
+// size or pixel format differs from props fail with a
+// *video.FormatChangeError, as buffer is laid out for props.
+func decodeInto(handle *ffms.VideoSource, props *video.ColorProperties,
+	frameNumber int, buffer video.Frame) error {
+	ffmsFrame, _, err := handle.GetFrame(frameNumber)
+	if err != nil {
+		return err
+	}
+
+	want := video.FrameFormat{Width: props.Width, Height: props.Height,
+		PixelFormat: props.PixelFormat}
+	got := video.FrameFormat{Width: ffmsFrame.EncodedWidth,
+		Height:      ffmsFrame.EncodedHeight,
+		PixelFormat: pixfmts.PixelFormat(ffmsFrame.EncodedPixelFormat)}
+	if got != want {
+		return &video.FormatChangeError{Frame: frameNumber, Want: want,
+			Got: got}
+	}
+
+	decoded, err := video.NewFrame(ffmsFrame.Data, ffmsFrame.Linesize)
+	if err != nil {
+		return err
+	}
+	setFrameMetadata(decoded.Metadata(), handle, frameNumber, &ffmsFrame)
+
+	return buffer.SafeCopyFrom(&decoded)
+}
+
+// next returns the next frame in frame order. The frame must be handed back
+// with release once it has been copied.
+func (r *shardRun) next() (video.Frame, error) {
+	if r.current >= len(r.ranges) {
+		return video.Frame{}, fmt.Errorf("read past the last decoded frame")
+	}
+
+	item := <-r.shards[r.current%len(r.shards)].queue
+	if item.err != nil {
+		return video.Frame{}, item.err
+	}
+
+	return item.frame, nil
+}
+
+// release hands the frame returned by next back to its shard and advances
+// to the following frame.
+func (r *shardRun) release(frame video.Frame) {
+	r.shards[r.current%len(r.shards)].free <- frame
+
+	r.read++
+	if r.read == r.ranges[r.current].Count {
+		r.current++
+		r.read = 0
+	}
+}
+
+// stop ends every decode goroutine and waits for them to return.
+func (r *shardRun) stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
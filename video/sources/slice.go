@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// sliceSource exposes a contiguous frame range of a seekable source as a
+// source of its own, starting at frame 0.
+type sliceSource struct {
+	source       video.SeekableSource
+	start, count int
+	// pos is the next frame to read relative to start.
+	pos int
+	// needsSeek is set until the underlying source is positioned at pos.
+	needsSeek bool
+}
+
+// Slice returns a source that delivers count frames of source starting at
+// start. Frame 0 of the returned source is frame start of source.
+//
+// The slice seeks source before its first read, so several slices of the same
+// source can be created up front and read one after another. Reading two
+// slices of one source concurrently is not supported. As slices share their
+// source, closing a slice does nothing and source must be closed by the
+// caller.
+func Slice(source video.Source, start, count int) (video.Source, error) {
+	seekable, ok := video.As[video.SeekableSource](source)
+	if !ok {
+		return nil, errors.New("source does not support seeking, it cannot " +
+			"be sliced")
+	}
+
+	if start < 0 || count <= 0 || start+count > source.GetNumFrames() {
+		return nil, fmt.Errorf("slice [%d, %d) is outside the source's %d "+
+			"frames", start, start+count, source.GetNumFrames())
+	}
+
+	return &sliceSource{source: seekable, start: start, count: count,
+		needsSeek: true}, nil
+}
+
+func (s *sliceSource) GetFrame(frame video.Frame) error {
+	if s.pos >= s.count {
+		return fmt.Errorf("frame %d is past the end of the %d frame slice",
+			s.pos, s.count)
+	}
+
+	if s.needsSeek {
+		if err := s.source.SeekFrame(s.start + s.pos); err != nil {
+			return err
+		}
+		s.needsSeek = false
+	}
+
+	if err := s.source.GetFrame(frame); err != nil {
+		return err
+	}
+
+	s.pos++
+	return nil
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n of the slice.
+func (s *sliceSource) SeekFrame(n int) error {
+	if n < 0 || n >= s.count {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n, s.count)
+	}
+
+	s.pos, s.needsSeek = n, true
+	return nil
+}
+
+// GetKeyFrames returns the keyframes of the wrapped source that fall inside
+// the slice, relative to its start.
+func (s *sliceSource) GetKeyFrames() ([]int, error) {
+	keyFrameSource, ok := s.source.(video.KeyFrameSource)
+	if !ok {
+		return nil, errors.New("wrapped source does not support keyframe lookup")
+	}
+
+	keyFrames, err := keyFrameSource.GetKeyFrames()
+	if err != nil {
+		return nil, err
+	}
+
+	var inSlice []int
+	for _, keyFrame := range keyFrames {
+		if keyFrame >= s.start && keyFrame < s.start+s.count {
+			inSlice = append(inSlice, keyFrame-s.start)
+		}
+	}
+
+	return inSlice, nil
+}
+
+// GetRepeatFields returns the repeat field flags of the frames of the wrapped
+// source inside the slice.
+func (s *sliceSource) GetRepeatFields() ([]int, error) {
+	repeatSource, ok := s.source.(video.FieldRepeatSource)
+	if !ok {
+		return nil, errors.New("wrapped source does not report repeated " +
+			"fields")
+	}
+
+	repeats, err := repeatSource.GetRepeatFields()
+	if err != nil {
+		return nil, err
+	}
+	if len(repeats) < s.start+s.count {
+		return nil, fmt.Errorf("wrapped source reported %d repeat flags "+
+			"for %d frames", len(repeats), s.source.GetNumFrames())
+	}
+	return repeats[s.start : s.start+s.count], nil
+}
+
+func (s *sliceSource) GetColorProps() *video.ColorProperties { return s.source.GetColorProps() }
+func (s *sliceSource) GetNumFrames() int                     { return s.count }
+func (s *sliceSource) GetFrameRate() float32                 { return s.source.GetFrameRate() }
+
+func (s *sliceSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.source.GetPlaneSizes()
+}
+
+// Close does nothing, as the slice does not own its source, see Slice.
+func (s *sliceSource) Close() error { return nil }
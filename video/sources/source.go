@@ -1,6 +1,10 @@
+//go:build cgo && !nocgo
+
 package sources
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"runtime"
 
@@ -14,43 +18,120 @@ type ffmsSource struct {
 	video        *ffms.VideoSource
 	numFrame     int
 	colorspace   video.ColorProperties
-	planeSizes   [3]int
-	planeStrides [3]int
+	planeSizes   [video.MaxPlanes]int
+	planeStrides [video.MaxPlanes]int
 	frameRate    float32
+	// fallback retries frames that fail to decode, nil if the source is
+	// opened in the safest seek mode.
+	fallback *seekFallback
+}
+
+// FFms2Options configures NewFFms2ReaderWithOptions.
+type FFms2Options struct {
+	// Decoder threads of every VideoSource handle. Defaults to the number of
+	// CPUs divided by DecodeShards.
+	DecodeThreads int
+	// How every VideoSource handle seeks. Frames that fail to decode are
+	// retried in the safer modes, see seekFallback. Defaults to SeekNormal.
+	SeekMode SeekMode
+	// What indexing does about undecodable packets. Defaults to
+	// IndexErrorAbort.
+	IndexErrors IndexErrorMode
+	// The number of VideoSource handles opened on the index to decode
+	// disjoint frame ranges in parallel, see shardedSource. Defaults to 1,
+	// which decodes sequentially on a single handle.
+	DecodeShards int
+	// How many decoded frames each shard may hold ahead of the reader. Shards
+	// only run in parallel while they have room, so this should be at least
+	// the GOP length. Memory use grows with DecodeShards * ShardLookahead
+	// frames. Defaults to 64.
+	ShardLookahead int
+	// The smallest frame range given to a shard. Ranges always start at a
+	// keyframe. Defaults to 1, i.e. every GOP is its own range.
+	MinShardFrames int
+	// IndexCache, if set, is where the index of the file is read from and,
+	// once indexed, stored, so a file is only indexed the first time any run
+	// opens it.
+	IndexCache *IndexCache
+}
+
+func (o *FFms2Options) setDefaults() {
+	if o.DecodeShards < 1 {
+		o.DecodeShards = 1
+	}
+	if o.DecodeThreads < 1 {
+		o.DecodeThreads = max(runtime.NumCPU()/o.DecodeShards, 1)
+	}
+	if o.ShardLookahead < 1 {
+		o.ShardLookahead = 64
+	}
+	if o.MinShardFrames < 1 {
+		o.MinShardFrames = 1
+	}
 }
 
+// NewFFms2Reader opens the first video track of the file at path with the
+// default FFms2Options.
 func NewFFms2Reader(path string) (video.Source, error) {
-	var err error
+	return NewFFms2ReaderWithOptions(path, FFms2Options{})
+}
 
-	var indexer *ffms.Indexer
-	if indexer, _, err = ffms.CreateIndexer(path); err != nil {
+// NewFFms2ReaderWithOptions opens the first video track of the file at path.
+func NewFFms2ReaderWithOptions(path string, opts FFms2Options) (video.Source,
+	error) {
+	return NewFFms2ReaderContext(context.Background(), path, opts)
+}
+
+// NewFFms2ReaderContext opens the first video track of the file at path.
+// Indexing, which takes the bulk of the time on large files, is cancelled
+// soon after ctx is done and ctx.Err() is returned.
+func NewFFms2ReaderContext(ctx context.Context, path string,
+	opts FFms2Options) (video.Source, error) {
+	opts.setDefaults()
+
+	seekMode, err := opts.SeekMode.ffms()
+	if err != nil {
+		return nil, err
+	}
+	indexErrors, err := opts.IndexErrors.ffms()
+	if err != nil {
 		return nil, err
 	}
 
-	var index *ffms.Index
-	if index, _, err = indexer.DoIndexing(ffms.IEHAbort); err != nil {
+	index, err := loadIndex(ctx, path, indexErrors, opts.IndexCache)
+	if err != nil {
 		return nil, err
 	}
+	// Every VideoSource keeps its own copy of the index, so it is only
+	// needed while the handles are created, and by the seek fallback which
+	// creates more handles on demand and then owns it.
+	var fallback *seekFallback
+	defer func() {
+		if fallback == nil {
+			index.Close()
+		}
+	}()
 
 	track, _, err := index.GetFirstTrackOfType(ffms.TypeVideo)
 	if err != nil {
 		return nil, err
 	}
 
-	var decThreads int = runtime.NumCPU()
-	source, _, err := ffms.CreateVideoSource(path, index, track, decThreads,
-		ffms.SeekNormal)
+	source, _, err := ffms.CreateVideoSource(path, index, track,
+		opts.DecodeThreads, seekMode)
 	if err != nil {
 		return nil, err
 	}
 
 	props, err := source.GetVideoProperties()
 	if err != nil {
+		source.Close()
 		return nil, err
 	}
 
 	ff, _, err := source.GetFrame(0)
 	if err != nil {
+		source.Close()
 		return nil, err
 	}
 
@@ -64,9 +145,9 @@ func NewFFms2Reader(path string) (video.Source, error) {
 	// 	return nil, err
 	// }
 
-	var planeSizes, planeStrides [3]int
+	var planeSizes, planeStrides [video.MaxPlanes]int
 
-	for i := range 3 {
+	for i := range video.MaxPlanes {
 		planeSizes[i] = len(ff.Data[i])
 		planeStrides[i] = ff.Linesize[i]
 	}
@@ -80,29 +161,122 @@ func NewFFms2Reader(path string) (video.Source, error) {
 		ColorTransfer:  pixfmts.ColorTransferCharacteristic(ff.TransferCharateristics),
 		ColorPrimaries: pixfmts.ColorPrimaries(ff.ColorPrimaries),
 		ChromaLocation: pixfmts.ChromaLocation(ff.ChromaLocation),
+		Orientation:    video.NewOrientation(props.Rotation, props.Flip),
+		HDR:            hdrMetadata(&props, &ff),
+	}
+
+	fallback = newSeekFallback(path, index, track, opts.DecodeThreads,
+		seekMode)
+	ffmsSrc := &ffmsSource{0, source, props.NumFrames, colorProps,
+		planeSizes, planeStrides,
+		float32(props.FPSNumerator) / float32(props.FPSDenominator),
+		fallback}
+
+	if opts.DecodeShards == 1 {
+		return planarizeOrClose(ffmsSrc)
+	}
+
+	handles := []*ffms.VideoSource{source}
+	for range opts.DecodeShards - 1 {
+		handle, _, err := ffms.CreateVideoSource(path, index, track,
+			opts.DecodeThreads, seekMode)
+		if err != nil {
+			closeHandles(handles[1:])
+			ffmsSrc.Close()
+			return nil, err
+		}
+		handles = append(handles, handle)
+	}
+
+	sharded, err := newShardedSource(ffmsSrc, handles, opts)
+	if err != nil {
+		closeHandles(handles[1:])
+		ffmsSrc.Close()
+		return nil, err
 	}
 
-	return &ffmsSource{0, source, props.NumFrames, colorProps, planeSizes,
-		planeStrides, float32(props.FPSNumerator) / float32(props.FPSDenominator)}, nil
+	return planarizeOrClose(sharded)
 }
 
-func (s *ffmsSource) GetFrame(frame video.Frame) error {
-	ffmsFrame, _, err := s.video.GetFrame(s.currentIndex)
+// loadIndex returns the index of the file at path, read from cache if it
+// holds one and indexed otherwise. A cached index that cannot be read or does
+// not belong to the file is replaced. Failing to store an index only costs
+// indexing again next time, so it is not an error. Indexes made with error
+// handling other than aborting are cached apart, as they may be incomplete.
+func loadIndex(ctx context.Context, path string,
+	indexErrors ffms.IndexErrorHandling, cache *IndexCache) (*ffms.Index,
+	error) {
+	var key string
+	if cache != nil {
+		var err error
+		if key, err = cache.Key(path); err != nil {
+			return nil, err
+		}
+		if indexErrors != ffms.IEHAbort {
+			key = fmt.Sprintf("%s-ieh%d", key, indexErrors)
+		}
+		if index := readCachedIndex(cache, key, path); index != nil {
+			return index, nil
+		}
+	}
+
+	indexer, _, err := ffms.CreateIndexer(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tempFrame, err := video.NewFrame(
-		[3][]byte{ffmsFrame.Data[0], ffmsFrame.Data[1], ffmsFrame.Data[2]},
-		[3]int{ffmsFrame.Linesize[0], ffmsFrame.Linesize[1],
-			ffmsFrame.Linesize[2]})
+	index, _, err := indexer.DoIndexingContext(ctx, indexErrors)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if cache != nil {
+		data, res, _, err := index.WriteIndexToByteBuffer()
+		if err == nil && res == 0 {
+			cache.Put(key, data)
+		}
+	}
+	return index, nil
+}
+
+// readCachedIndex returns the index cached under key if it can be read and
+// belongs to the file at path, or nil, removing the unusable index.
+func readCachedIndex(cache *IndexCache, key, path string) *ffms.Index {
+	data, ok, err := cache.Get(key)
+	if err != nil || !ok {
+		return nil
+	}
+
+	index, _, err := ffms.ReadIndexFromBuffer(data)
+	if err != nil {
+		cache.Remove(key)
+		return nil
 	}
+	if res, _, err := index.BelongsToFile(path); err != nil || res != 0 {
+		index.Close()
+		cache.Remove(key)
+		return nil
+	}
+	return index
+}
 
-	// This is the safe, protected operation
-	if err := frame.SafeCopyFrom(&tempFrame); err != nil {
-		return fmt.Errorf("failed to safely copy frame data: %w", err)
+// planarizeOrClose returns Planarize(source), closing source if it fails.
+func planarizeOrClose(source video.Source) (video.Source, error) {
+	planar, err := Planarize(source)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
+	return planar, nil
+}
+
+// GetFrame decodes the next frame into frame. Frames that fail to decode are
+// retried in safer seek modes, see seekFallback.
+func (s *ffmsSource) GetFrame(frame video.Frame) error {
+	err := s.fallback.decodeInto(s.video, &s.colorspace, s.currentIndex,
+		frame)
+	if err != nil {
+		return err
 	}
 
 	s.currentIndex++
@@ -113,6 +287,173 @@ func (s *ffmsSource) GetColorProps() *video.ColorProperties { return &s.colorspa
 func (s *ffmsSource) GetNumFrames() int                     { return s.numFrame }
 func (s *ffmsSource) GetFrameRate() float32                 { return s.frameRate }
 
-func (c *ffmsSource) GetPlaneSizes() ([3]int, [3]int) {
+func (c *ffmsSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
 	return c.planeSizes, c.planeStrides
 }
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n.
+func (s *ffmsSource) SeekFrame(n int) error {
+	if n < 0 || n >= s.numFrame {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			s.numFrame)
+	}
+
+	s.currentIndex = n
+	return nil
+}
+
+// Close destroys the ffms2 VideoSource and the handles of its seek fallback.
+// Calling Close more than once does nothing.
+func (s *ffmsSource) Close() error {
+	if s.video == nil {
+		return nil
+	}
+	err := errors.Join(s.video.Close(), s.fallback.close())
+	s.video = nil
+	return err
+}
+
+// GetKeyFrames returns the indices of every keyframe in the video track using
+// the index's FrameInfo.
+func (s *ffmsSource) GetKeyFrames() ([]int, error) {
+	track, err := s.video.GetTrack()
+	if err != nil {
+		return nil, err
+	}
+
+	return track.GetKeyFrames()
+}
+
+// GetStartTime returns the presentation time of the first frame in seconds,
+// the timestamp the index holds for it after libavformat applied edit lists.
+func (s *ffmsSource) GetStartTime() (float64, error) {
+	track, err := s.video.GetTrack()
+	if err != nil {
+		return 0, err
+	}
+	info, err := track.GetFrameInfo(0)
+	if err != nil {
+		return 0, err
+	}
+	timeBase, err := track.GetTimeBase()
+	if err != nil {
+		return 0, err
+	}
+	if timeBase.Den == 0 {
+		return 0, errors.New("the video track has no time base")
+	}
+
+	// PTS times the time base is in milliseconds.
+	return float64(info.PTS) * float64(timeBase.Num) / float64(timeBase.Den) /
+		1000, nil
+}
+
+// GetRepeatFields returns the repeat first field flag of every frame in the
+// video track using the index's FrameInfo.
+func (s *ffmsSource) GetRepeatFields() ([]int, error) {
+	track, err := s.video.GetTrack()
+	if err != nil {
+		return nil, err
+	}
+
+	repeats := make([]int, s.numFrame)
+	for i := range repeats {
+		info, err := track.GetFrameInfo(i)
+		if err != nil {
+			return nil, err
+		}
+		repeats[i] = info.RepeatPict
+	}
+	return repeats, nil
+}
+
+// closeHandles destroys every handle, for cleaning up after a failed open.
+func closeHandles(handles []*ffms.VideoSource) {
+	for _, handle := range handles {
+		handle.Close()
+	}
+}
+
+// setFrameMetadata fills meta with the metadata of frame n as decoded by
+// handle. The timestamp is left out if the track cannot be read.
+func setFrameMetadata(meta video.FrameMetadata, handle *ffms.VideoSource,
+	n int, frame *ffms.Frame) {
+	meta[video.MetaKeyFrame] = frame.KeyFrame != 0
+	meta[video.MetaRepeatFields] = frame.RepeatPict
+	meta[video.MetaInterlaced] = frame.InterlacedFrame != 0
+	meta[video.MetaTopFieldFirst] = frame.TopFieldFirst != 0
+	if frame.PictType != 0 {
+		meta[video.MetaPictType] = string(rune(frame.PictType))
+	}
+
+	track, err := handle.GetTrack()
+	if err != nil {
+		return
+	}
+	info, err := track.GetFrameInfo(n)
+	if err != nil {
+		return
+	}
+	timeBase, err := track.GetTimeBase()
+	if err != nil || timeBase.Den == 0 {
+		return
+	}
+
+	// PTS times the time base is in milliseconds.
+	meta[video.MetaPTS] = float64(info.PTS) * float64(timeBase.Num) /
+		float64(timeBase.Den) / 1000
+}
+
+// hdrMetadata returns the HDR metadata of the stream. Containers that do not
+// carry it at stream level may still have it in the bitstream, so each group
+// missing from props is taken from the first frame.
+func hdrMetadata(props *ffms.VideoProperties, first *ffms.Frame) video.HDRMetadata {
+	var m video.HDRMetadata
+
+	switch {
+	case props.HasMasteringDisplayPrimaries != 0:
+		m.HasMasteringPrimaries = true
+		for i := range 3 {
+			m.MasteringPrimaries[i] = [2]float64{
+				props.MasteringDisplayPrimariesX[i],
+				props.MasteringDisplayPrimariesY[i]}
+		}
+		m.MasteringWhitePoint = [2]float64{props.MasteringDisplayWhitePointX,
+			props.MasteringDisplayWhitePointY}
+	case first.HasMasteringDisplayPrimaries != 0:
+		m.HasMasteringPrimaries = true
+		for i := range 3 {
+			m.MasteringPrimaries[i] = [2]float64{
+				first.MasteringDisplayPrimariesX[i],
+				first.MasteringDisplayPrimariesY[i]}
+		}
+		m.MasteringWhitePoint = [2]float64{first.MasteringDisplayWhitePointX,
+			first.MasteringDisplayWhitePointY}
+	}
+
+	switch {
+	case props.HasMasteringDisplayLuminance != 0:
+		m.HasMasteringLuminance = true
+		m.MasteringMinLuminance = props.MasteringDisplayMinLuminance
+		m.MasteringMaxLuminance = props.MasteringDisplayMaxLuminance
+	case first.HasMasteringDisplayLuminance != 0:
+		m.HasMasteringLuminance = true
+		m.MasteringMinLuminance = first.MasteringDisplayMinLuminance
+		m.MasteringMaxLuminance = first.MasteringDisplayMaxLuminance
+	}
+
+	switch {
+	case props.HasContentLightLevel != 0:
+		m.HasContentLightLevel = true
+		m.MaxCLL = int(props.ContentLightLevelMax)
+		m.MaxFALL = int(props.ContentLightLevelAverage)
+	case first.HasContentLightLevel != 0:
+		m.HasContentLightLevel = true
+		m.MaxCLL = int(first.ContentLightLevelMax)
+		m.MaxFALL = int(first.ContentLightLevelAverage)
+	}
+
+	return m
+}
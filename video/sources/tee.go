@@ -0,0 +1,193 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// teeSource decodes a source once for several readers, keeping the frames
+// decoded until every reader has read them.
+type teeSource struct {
+	source video.Source
+	ahead  int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// frames holds the decoded frames some reader has yet to read, frame
+	// first+i at frames[i]. free holds buffers for reuse.
+	frames []video.Frame
+	first  int
+	free   []video.Frame
+	// positions holds the next frame of every reader, -1 once it is closed.
+	positions []int
+	open      int
+	// decoding is set while a reader decodes the next frame outside the
+	// lock. err is the error decoding it failed with, returned to every
+	// reader reaching that frame.
+	decoding bool
+	err      error
+}
+
+// Tee returns n sources reading the frames of source in order, decoding each
+// frame once however many of them read it, e.g. to compare one distortion
+// against several references. Readers run at their own pace, except that
+// none gets more than ahead frames past the slowest open one, which bounds
+// the frames held in memory.
+//
+// The readers are safe for concurrent use with each other but cannot seek.
+// Closing a reader lets the others run past it. Closing the last one closes
+// source.
+func Tee(source video.Source, n, ahead int) ([]video.Source, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("cannot tee a source into %d readers", n)
+	}
+	if ahead < 1 {
+		return nil, fmt.Errorf("readers must be allowed at least 1 frame "+
+			"ahead, got %d", ahead)
+	}
+
+	t := &teeSource{source: source, ahead: ahead,
+		positions: make([]int, n), open: n}
+	t.cond = sync.NewCond(&t.mu)
+
+	readers := make([]video.Source, n)
+	for i := range readers {
+		readers[i] = &teeReader{tee: t, index: i}
+	}
+	return readers, nil
+}
+
+// read copies the next frame of reader i into dst, decoding it if no reader
+// has yet.
+func (t *teeSource) read(i int, dst video.Frame) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.positions[i]
+	for n >= t.first+len(t.frames) {
+		if t.err != nil {
+			return t.err
+		}
+		if t.decoding || len(t.frames) >= t.ahead {
+			t.cond.Wait()
+			continue
+		}
+		if err := t.decodeNext(); err != nil {
+			return err
+		}
+	}
+
+	if err := dst.SafeCopyFrom(&t.frames[n-t.first]); err != nil {
+		return err
+	}
+	t.positions[i]++
+	t.release()
+	return nil
+}
+
+// decodeNext decodes the frame after the last one held, unlocking t while
+// the source decodes.
+func (t *teeSource) decodeNext() error {
+	var frame video.Frame
+	if len(t.free) > 0 {
+		frame, t.free = t.free[len(t.free)-1], t.free[:len(t.free)-1]
+	} else {
+		var err error
+		if frame, err = video.NewFrameFor(t.source); err != nil {
+			return err
+		}
+	}
+
+	t.decoding = true
+	t.mu.Unlock()
+	err := t.source.GetFrame(frame)
+	t.mu.Lock()
+	t.decoding = false
+	defer t.cond.Broadcast()
+
+	if err != nil {
+		t.free = append(t.free, frame)
+		t.err = fmt.Errorf("frame %d: %w", t.first+len(t.frames), err)
+		return t.err
+	}
+	t.frames = append(t.frames, frame)
+	return nil
+}
+
+// release recycles the frames every open reader has read.
+func (t *teeSource) release() {
+	slowest := -1
+	for _, position := range t.positions {
+		if position >= 0 && (slowest < 0 || position < slowest) {
+			slowest = position
+		}
+	}
+
+	done := len(t.frames)
+	if slowest >= 0 {
+		done = min(slowest-t.first, len(t.frames))
+	}
+	if done <= 0 {
+		return
+	}
+
+	t.free = append(t.free, t.frames[:done]...)
+	t.frames = append(t.frames[:0], t.frames[done:]...)
+	t.first += done
+	t.cond.Broadcast()
+}
+
+// close closes reader i, and source once every reader is closed.
+func (t *teeSource) close(i int) error {
+	t.mu.Lock()
+	t.positions[i] = -1
+	t.open--
+	last := t.open == 0
+	t.release()
+	t.cond.Broadcast()
+	t.mu.Unlock()
+
+	if last {
+		return t.source.Close()
+	}
+	return nil
+}
+
+// teeReader is one reader of a teeSource.
+type teeReader struct {
+	tee   *teeSource
+	index int
+	// closed is only touched by the reader's own goroutine.
+	closed bool
+}
+
+func (r *teeReader) GetFrame(frame video.Frame) error {
+	if r.closed {
+		return errors.New("read from a closed tee reader")
+	}
+	return r.tee.read(r.index, frame)
+}
+
+func (r *teeReader) GetColorProps() *video.ColorProperties {
+	return r.tee.source.GetColorProps()
+}
+func (r *teeReader) GetNumFrames() int     { return r.tee.source.GetNumFrames() }
+func (r *teeReader) GetFrameRate() float32 { return r.tee.source.GetFrameRate() }
+
+func (r *teeReader) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return r.tee.source.GetPlaneSizes()
+}
+
+// Close closes the reader, and the teed source if it is the last one open.
+// Calling Close more than once does nothing.
+func (r *teeReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.tee.close(r.index)
+}
@@ -0,0 +1,312 @@
+package sources
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// telecineCycle is the number of telecined frames showing the 4 film frames
+// of one 3:2 pulldown cycle.
+const telecineCycle = 5
+
+// heldFrame is a decoded frame of the wrapped source and its index, -1 for
+// none.
+type heldFrame struct {
+	index int
+	frame video.Frame
+}
+
+// telecineSource undoes hard 3:2 pulldown by field matching and decimation.
+type telecineSource struct {
+	source    video.Source
+	numFrames int
+	frameRate float32
+	differ    *video.FrameDiffer
+
+	numPlanes  int
+	rows       [video.MaxPlanes]int
+	width      int
+	sampleSize int
+	// threshold is how far a luma sample must differ from both rows around
+	// it, in the same direction, to count as combed.
+	threshold int
+
+	// held holds the source frames around the cycle being read.
+	held []heldFrame
+	// next is the frame the wrapped source delivers next.
+	next int
+
+	// cycle is the loaded cycle, -1 for none. matched holds its field
+	// matched frames, preceded by that of the frame before the cycle, and
+	// keep the indices into matched it delivers.
+	cycle   int
+	matched [telecineCycle + 1]video.Frame
+	keep    []int
+	// pos is the next frame to deliver.
+	pos int
+}
+
+// InverseTelecine returns a source undoing hard 3:2 pulldown of source, so
+// that telecined 29.97 fps video shows the 23.976 fps film frames it was made
+// from. Every frame keeps its top field and takes the bottom field of the
+// frame before, itself or the frame after, whichever combs least, which
+// rebuilds the film frames whatever the field order. Of every 5 frames, the
+// one closest to its predecessor is then dropped as the duplicate.
+//
+// source must have a planar layout. Reading the frames in any order but
+// sequentially needs source to be seekable. Closing the returned source
+// closes source.
+func InverseTelecine(source video.Source) (video.Source, error) {
+	props := source.GetColorProps()
+
+	layout, err := props.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout == video.LayoutPacked {
+		return nil, errors.New("cannot inverse telecine packed frames, " +
+			"planarize them first")
+	}
+
+	depth, err := props.BitDepth()
+	if err != nil {
+		return nil, err
+	}
+	differ, err := video.NewFrameDiffer(props)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, numPlanes, err := props.VisiblePlanes()
+	if err != nil {
+		return nil, err
+	}
+
+	n := source.GetNumFrames()
+	s := &telecineSource{
+		source: source,
+		numFrames: n/telecineCycle*(telecineCycle-1) +
+			min(n%telecineCycle, telecineCycle-1),
+		frameRate: source.GetFrameRate() * (telecineCycle - 1) /
+			telecineCycle,
+		differ:     differ,
+		numPlanes:  numPlanes,
+		rows:       rows,
+		width:      props.Width,
+		sampleSize: 1,
+		threshold:  12 << max(depth-8, 0),
+		held:       make([]heldFrame, telecineCycle+3),
+		cycle:      -1,
+	}
+	if depth > 8 {
+		s.sampleSize = 2
+	}
+
+	for i := range s.held {
+		s.held[i].index = -1
+		if s.held[i].frame, err = video.NewFrameFor(source); err != nil {
+			return nil, err
+		}
+	}
+	for i := range s.matched {
+		if s.matched[i], err = video.NewFrameFor(source); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *telecineSource) GetFrame(frame video.Frame) error {
+	if s.pos >= s.numFrames {
+		return fmt.Errorf("frame %d out of range [0, %d)", s.pos,
+			s.numFrames)
+	}
+
+	cycle := s.pos / (telecineCycle - 1)
+	if cycle != s.cycle {
+		if err := s.loadCycle(cycle); err != nil {
+			return err
+		}
+	}
+
+	err := frame.SafeCopyFrom(&s.matched[s.keep[s.pos%(telecineCycle-1)]])
+	if err != nil {
+		return err
+	}
+	s.pos++
+	return nil
+}
+
+// loadCycle field matches the frames of cycle and picks those to deliver.
+func (s *telecineSource) loadCycle(cycle int) error {
+	n := s.source.GetNumFrames()
+	first := cycle * telecineCycle
+	last := min(first+telecineCycle, n) - 1
+
+	// Matching the frame before the cycle, for decimation, reaches one more
+	// frame back, and the last frame of the cycle one frame ahead.
+	lo, hi := max(first-2, 0), min(last+1, n-1)
+	for i := lo; i <= hi; i++ {
+		if err := s.hold(i, lo, hi); err != nil {
+			return err
+		}
+	}
+
+	for i := max(first-1, 0); i <= last; i++ {
+		s.match(i, &s.matched[i-first+1])
+	}
+
+	s.keep = s.keep[:0]
+	if last-first+1 < telecineCycle {
+		for j := 1; j <= last-first+1; j++ {
+			s.keep = append(s.keep, j)
+		}
+		s.cycle = cycle
+		return nil
+	}
+
+	drop, closest := 0, -1.0
+	for j := 1; j <= telecineCycle; j++ {
+		if j == 1 && first == 0 {
+			continue
+		}
+		if psnr := s.differ.PSNR(&s.matched[j],
+			&s.matched[j-1]); psnr > closest {
+			drop, closest = j, psnr
+		}
+	}
+	for j := 1; j <= telecineCycle; j++ {
+		if j != drop {
+			s.keep = append(s.keep, j)
+		}
+	}
+
+	s.cycle = cycle
+	return nil
+}
+
+// hold decodes source frame i into held, unless it already is, replacing a
+// frame outside [lo, hi].
+func (s *telecineSource) hold(i, lo, hi int) error {
+	slot := -1
+	for j, held := range s.held {
+		if held.index == i {
+			return nil
+		}
+		if held.index < lo || held.index > hi {
+			slot = j
+		}
+	}
+
+	if s.next != i {
+		seekable, ok := s.source.(video.SeekableSource)
+		if !ok {
+			return errors.New("wrapped source does not support seeking")
+		}
+		if err := seekable.SeekFrame(i); err != nil {
+			return err
+		}
+		s.next = i
+	}
+
+	// Forget the frame first, so a failed read does not leave a stale one.
+	s.held[slot].index = -1
+	if err := s.source.GetFrame(s.held[slot].frame); err != nil {
+		return err
+	}
+	s.held[slot].index = i
+	s.next = i + 1
+	return nil
+}
+
+// frame returns held source frame i, or nil if it is not held.
+func (s *telecineSource) frame(i int) *video.Frame {
+	for j := range s.held {
+		if s.held[j].index == i {
+			return &s.held[j].frame
+		}
+	}
+	return nil
+}
+
+// match weaves the top field of source frame i with the bottom field that
+// combs least with it into dst.
+func (s *telecineSource) match(i int, dst *video.Frame) {
+	top := s.frame(i)
+
+	bottom, fewest := top, s.combed(top, top)
+	for _, j := range []int{i - 1, i + 1} {
+		other := s.frame(j)
+		if other == nil {
+			continue
+		}
+		if combed := s.combed(top, other); combed < fewest {
+			bottom, fewest = other, combed
+		}
+	}
+
+	for plane := range s.numPlanes {
+		for y := range s.rows[plane] {
+			src := top
+			if y%2 == 1 {
+				src = bottom
+			}
+			copy(dst.Row(plane, y), src.Row(plane, y))
+		}
+	}
+	dst.CopyMetadataFrom(top)
+}
+
+// combed counts the luma samples of the bottom field that stick out of the
+// top field around them when the two are woven together.
+func (s *telecineSource) combed(top, bottom *video.Frame) int {
+	var count int
+	for y := 1; y+1 < s.rows[0]; y += 2 {
+		above, row, below := top.Row(0, y-1), bottom.Row(0, y),
+			top.Row(0, y+1)
+		for x := range s.width {
+			value := s.sample(row, x)
+			up, down := value-s.sample(above, x), value-s.sample(below, x)
+			if up > s.threshold && down > s.threshold ||
+				up < -s.threshold && down < -s.threshold {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// sample reads sample x of a row of native little endian samples.
+func (s *telecineSource) sample(row []byte, x int) int {
+	if s.sampleSize == 1 {
+		return int(row[x])
+	}
+	return int(binary.LittleEndian.Uint16(row[2*x:]))
+}
+
+func (s *telecineSource) GetColorProps() *video.ColorProperties { return s.source.GetColorProps() }
+func (s *telecineSource) GetNumFrames() int                     { return s.numFrames }
+func (s *telecineSource) GetFrameRate() float32                 { return s.frameRate }
+
+func (s *telecineSource) GetPlaneSizes() ([video.MaxPlanes]int,
+	[video.MaxPlanes]int) {
+	return s.source.GetPlaneSizes()
+}
+
+// SeekFrame moves the read position so that the next call to GetFrame returns
+// frame n. Frames outside the loaded cycle are decoded by seeking the wrapped
+// source.
+func (s *telecineSource) SeekFrame(n int) error {
+	if n < 0 || n >= s.numFrames {
+		return fmt.Errorf("seek to frame %d out of range [0, %d)", n,
+			s.numFrames)
+	}
+	s.pos = n
+	return nil
+}
+
+// Close closes the wrapped source.
+func (s *telecineSource) Close() error { return s.source.Close() }
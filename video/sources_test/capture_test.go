@@ -0,0 +1,106 @@
+package sources_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+func Test_ParseCapturePath(t *testing.T) {
+	tests := []struct {
+		path, api, device string
+		ok                bool
+	}{
+		{"v4l2:/dev/video0", "v4l2", "/dev/video0", true},
+		{"decklink:DeckLink Duo (1)", "decklink", "DeckLink Duo (1)", true},
+		{"C:/videos/reference.mkv", "", "", false},
+		{"reference.mkv", "", "", false},
+		{"v4l2:", "", "", false},
+	}
+
+	for _, tt := range tests {
+		api, device, ok := sources.ParseCapturePath(tt.path)
+		if api != tt.api || device != tt.device || ok != tt.ok {
+			t.Errorf("%q: got %q, %q, %v, want %q, %q, %v", tt.path, api,
+				device, ok, tt.api, tt.device, tt.ok)
+		}
+	}
+}
+
+// fakeCapture writes a script printing the given number of 4x2 4:2:0 frames
+// whose samples are the frame number, standing in for ffmpeg, and returns its
+// path.
+func fakeCapture(t *testing.T, frames int) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ffmpeg is a shell script")
+	}
+
+	script := "#!/bin/sh\n"
+	for i := range frames {
+		script += fmt.Sprintf("printf '%s'\n",
+			strings.Repeat(fmt.Sprintf(`\%03o`, i), 12))
+	}
+
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_OpenCapture(t *testing.T) {
+	source, err := sources.OpenCapture("v4l2", "/dev/video0",
+		sources.CaptureOptions{Binary: fakeCapture(t, 5), Props: yuv420Props,
+			FrameRate: 25, Frames: 3, Skip: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first 2 frames are skipped.
+	for i := range 3 {
+		if err := source.GetFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		if got := frame.PlaneData(0)[0]; got != byte(i+2) {
+			t.Errorf("frame %d: got luma %d, want %d", i, got, i+2)
+		}
+	}
+	if err := source.GetFrame(frame); err == nil {
+		t.Error("read past the end of the capture")
+	}
+	if err := source.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_OpenCapture_Ended(t *testing.T) {
+	source, err := sources.OpenCapture("decklink", "DeckLink Mini Recorder",
+		sources.CaptureOptions{Binary: fakeCapture(t, 1), Props: yuv420Props,
+			FrameRate: 25, Frames: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err == nil {
+		t.Error("a capture that stopped delivering frames did not fail")
+	}
+}
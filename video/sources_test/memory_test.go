@@ -0,0 +1,282 @@
+package sources_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// yuv420Frames returns n 4x2 8-bit 4:2:0 frames whose luma is the frame
+// number.
+func yuv420Frames(n int) [][3][]byte {
+	frames := make([][3][]byte, n)
+	for i := range frames {
+		frames[i] = [3][]byte{bytes.Repeat([]byte{byte(i)}, 8),
+			{128, 128}, {128, 128}}
+	}
+	return frames
+}
+
+var yuv420Props = video.ColorProperties{Width: 4, Height: 2,
+	PixelFormat: pixfmts.PixFmtYUV420P}
+
+func Test_NewMemorySource(t *testing.T) {
+	source, err := sources.NewMemorySource(yuv420Frames(3), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if n := source.GetNumFrames(); n != 3 {
+		t.Errorf("GetNumFrames() = %d, want 3", n)
+	}
+	if rate := source.GetFrameRate(); rate != 25 {
+		t.Errorf("GetFrameRate() = %v, want 25", rate)
+	}
+
+	sizes, strides := source.GetPlaneSizes()
+	if sizes != [video.MaxPlanes]int{8, 2, 2} ||
+		strides != [video.MaxPlanes]int{4, 2, 2} {
+		t.Errorf("GetPlaneSizes() = %v %v, want [8 2 2 0] [4 2 2 0]", sizes,
+			strides)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := source.GetFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		if luma := frame.PlaneData(0)[0]; luma != byte(i) {
+			t.Errorf("frame %d has luma %d", i, luma)
+		}
+		if pts := frame.Metadata()[video.MetaPTS]; pts != float64(i)/25 {
+			t.Errorf("frame %d has pts %v, want %v", i, pts,
+				float64(i)/25)
+		}
+	}
+
+	if err := source.GetFrame(frame); err == nil {
+		t.Error("expected an error reading past the last frame")
+	}
+}
+
+func Test_NewMemorySource_Seek(t *testing.T) {
+	source, err := sources.NewMemorySource(yuv420Frames(4), yuv420Props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seekable, ok := source.(video.SeekableSource)
+	if !ok {
+		t.Fatal("memory source is not seekable")
+	}
+	if err := seekable.SeekFrame(2); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if luma := frame.PlaneData(0)[0]; luma != 2 {
+		t.Errorf("frame after seeking to 2 has luma %d", luma)
+	}
+
+	if err := seekable.SeekFrame(4); err == nil {
+		t.Error("expected an error seeking past the last frame")
+	}
+}
+
+func Test_NewMemorySource_Invalid(t *testing.T) {
+	short := yuv420Frames(2)
+	short[1][1] = []byte{128}
+
+	tests := []struct {
+		name      string
+		frames    [][3][]byte
+		props     video.ColorProperties
+		frameRate float32
+	}{
+		{"no frames", nil, yuv420Props, 25},
+		{"zero frame rate", yuv420Frames(1), yuv420Props, 0},
+		{"short plane", short, yuv420Props, 25},
+		{"empty size", yuv420Frames(1), video.ColorProperties{
+			PixelFormat: pixfmts.PixFmtYUV420P}, 25},
+		{"alpha", yuv420Frames(1), video.ColorProperties{Width: 4,
+			Height: 2, PixelFormat: pixfmts.PixFmtYUVA420P}, 25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := sources.NewMemorySource(test.frames, test.props,
+				test.frameRate)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func Test_NewImageSource(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 40, G: 50, B: 60, A: 0})
+
+	source, err := sources.NewImageSource(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	props := source.GetColorProps()
+	if props.PixelFormat != pixfmts.PixFmtGBRP ||
+		props.ColorSpace != pixfmts.ColorSpaceRGB ||
+		props.ColorRange != pixfmts.ColorRangeJPEG {
+		t.Errorf("GetColorProps() = %+v, want full range RGB GBRP", props)
+	}
+	if n := source.GetNumFrames(); n != 1 {
+		t.Errorf("GetNumFrames() = %d, want 1", n)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	// Planes are G, B, R and the alpha of the second pixel is dropped.
+	want := [3][]byte{{20, 50}, {30, 60}, {10, 40}}
+	for i, plane := range want {
+		if got := frame.PlaneData(i)[:2]; !bytes.Equal(got, plane) {
+			t.Errorf("plane %d = %v, want %v", i, got, plane)
+		}
+	}
+}
+
+func Test_NewImageSource_16Bit(t *testing.T) {
+	img := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+	img.SetRGBA64(0, 0, color.RGBA64{R: 0x1234, G: 0x5678, B: 0x9abc,
+		A: 0xffff})
+
+	source, err := sources.NewImageSource(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if format := source.GetColorProps().PixelFormat; format !=
+		pixfmts.PixFmtGBRP16LE {
+		t.Fatalf("PixelFormat = %v, want GBRP16LE", format)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.GetFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if g := frame.PlaneData(0)[:2]; !bytes.Equal(g, []byte{0x78, 0x56}) {
+		t.Errorf("green plane = %x, want 7856", g)
+	}
+}
+
+func Test_Tee(t *testing.T) {
+	source, err := sources.NewMemorySource(yuv420Frames(20), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers, err := sources.Tee(source, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last reader stops after 5 frames, which must not keep the others
+	// from reading past it.
+	var wg sync.WaitGroup
+	errs := make([]error, len(readers))
+	for i, reader := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer reader.Close()
+
+			frame, err := video.NewFrameFor(reader)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			n := 20
+			if i == len(readers)-1 {
+				n = 5
+			}
+			for j := range n {
+				if err := reader.GetFrame(frame); err != nil {
+					errs[i] = err
+					return
+				}
+				if luma := frame.PlaneData(0)[0]; luma != byte(j) {
+					errs[i] = fmt.Errorf("frame %d has luma %d", j, luma)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("reader %d: %v", i, err)
+		}
+	}
+}
+
+func Test_Pace(t *testing.T) {
+	source, err := sources.NewMemorySource(yuv420Frames(5), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pacer, err := sources.NewPacer(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pacer.Due(0).IsZero() {
+		t.Error("the pacer started before any frame was read")
+	}
+
+	paced := sources.Pace(source, pacer)
+	defer paced.Close()
+
+	frame, err := video.NewFrameFor(paced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if err := paced.GetFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Frame 4 arrives 40ms after the first was read.
+	if elapsed := time.Since(pacer.Due(0)); elapsed < 40*time.Millisecond {
+		t.Errorf("5 frames at 100 fps were read in %v", elapsed)
+	}
+}
@@ -0,0 +1,42 @@
+package sources_test
+
+import (
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// wrapper forwards everything to the source it wraps, the optional
+// interfaces through sources.Passthrough.
+type wrapper struct {
+	sources.Passthrough
+	video.Source
+}
+
+func wrap(source video.Source) video.Source {
+	return wrapper{sources.Passthrough{Source: source}, source}
+}
+
+func Test_AsPassthrough(t *testing.T) {
+	memory, err := sources.NewMemorySource(yuv420Frames(3), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := video.As[video.SeekableSource](wrap(wrap(memory))); !ok {
+		t.Error("wrapped memory source: not seekable")
+	}
+
+	// The wrappers implement SeekFrame and GetStartTime whatever they wrap.
+	hidden := wrap(wrap(forwardOnly{memory}))
+	if _, ok := hidden.(video.SeekableSource); !ok {
+		t.Fatal("wrapper does not implement video.SeekableSource")
+	}
+	if _, ok := video.As[video.SeekableSource](hidden); ok {
+		t.Error("wrapped forward only source: seekable")
+	}
+	if _, ok := video.As[video.StartTimeSource](wrap(memory)); ok {
+		t.Error("wrapped memory source: reports its start time")
+	}
+}
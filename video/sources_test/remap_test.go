@@ -0,0 +1,135 @@
+package sources_test
+
+import (
+	"testing"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// forwardOnly hides the SeekFrame method of a source.
+type forwardOnly struct{ video.Source }
+
+// readLuma reads the remaining frames of source and returns their luma, the
+// source frame numbers of yuv420Frames.
+func readLuma(t *testing.T, source video.Source, n int) []int {
+	t.Helper()
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	luma := make([]int, n)
+	for i := range luma {
+		if err := source.GetFrame(frame); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		luma[i] = int(frame.PlaneData(0)[0])
+	}
+	return luma
+}
+
+func Test_Remap(t *testing.T) {
+	memory, err := sources.NewMemorySource(yuv420Frames(6), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames 0 and 5 repeat, 1 and 4 are dropped and 3 comes before 2.
+	frames := []int{0, 0, 3, 2, 5, 5}
+	source, err := sources.Remap(memory, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if n := source.GetNumFrames(); n != len(frames) {
+		t.Errorf("GetNumFrames() = %d, want %d", n, len(frames))
+	}
+	if rate := source.GetFrameRate(); rate != 25 {
+		t.Errorf("GetFrameRate() = %g, want 25", rate)
+	}
+
+	got := readLuma(t, source, len(frames))
+	for i, want := range frames {
+		if got[i] != want {
+			t.Errorf("frame %d shows source frame %d, want %d", i, got[i],
+				want)
+		}
+	}
+
+	frame, _ := video.NewFrameFor(source)
+	if err := source.GetFrame(frame); err == nil {
+		t.Error("read past the end of the remapped frames")
+	}
+
+	if err := source.(video.SeekableSource).SeekFrame(2); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLuma(t, source, 1); got[0] != 3 {
+		t.Errorf("after seeking to frame 2: source frame %d, want 3", got[0])
+	}
+}
+
+func Test_RemapOutOfRange(t *testing.T) {
+	memory, err := sources.NewMemorySource(yuv420Frames(3), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, frames := range [][]int{nil, {}, {0, 3}, {-1, 0}} {
+		if _, err := sources.Remap(memory, frames); err == nil {
+			t.Errorf("%v: no error", frames)
+		}
+	}
+}
+
+func Test_RemapForwardOnly(t *testing.T) {
+	memory, err := sources.NewMemorySource(yuv420Frames(5), yuv420Props, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames ahead are reached by reading past the dropped ones.
+	source, err := sources.Remap(forwardOnly{memory}, []int{1, 1, 4, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readLuma(t, source, 3); got[0] != 1 || got[1] != 1 ||
+		got[2] != 4 {
+		t.Errorf("got source frames %v, want [1 1 4]", got)
+	}
+
+	// Going back needs seeking.
+	frame, _ := video.NewFrameFor(source)
+	if err := source.GetFrame(frame); err == nil {
+		t.Error("read frame 2 back without seeking")
+	}
+}
+
+func Test_Retime(t *testing.T) {
+	memory, err := sources.NewMemorySource(yuv420Frames(4), yuv420Props, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 frames over 5 repeat the frame shown at the middle of frame 3.
+	source, err := sources.Retime(memory, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate := source.GetFrameRate(); rate != 30 {
+		t.Errorf("GetFrameRate() = %g, want 30", rate)
+	}
+	got := readLuma(t, source, 5)
+	for i, want := range []int{0, 1, 2, 2, 3} {
+		if got[i] != want {
+			t.Errorf("frame %d shows source frame %d, want %d", i, got[i],
+				want)
+		}
+	}
+
+	if _, err := sources.Retime(memory, 0); err == nil {
+		t.Error("retiming to 0 frames: no error")
+	}
+}
@@ -0,0 +1,85 @@
+package sources_test
+
+import (
+	"testing"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+// wovenFrame returns a 4x6 8-bit 4:2:0 frame whose top field rows have luma
+// top and bottom field rows luma bottom.
+func wovenFrame(top, bottom byte) [3][]byte {
+	luma := make([]byte, 0, 24)
+	for y := range 6 {
+		value := top
+		if y%2 == 1 {
+			value = bottom
+		}
+		luma = append(luma, value, value, value, value)
+	}
+	chroma := []byte{128, 128, 128, 128, 128, 128}
+	return [3][]byte{luma, chroma, chroma}
+}
+
+// film is the luma of film frame i.
+func film(i int) byte { return byte(20 * (i + 1)) }
+
+func Test_InverseTelecine(t *testing.T) {
+	// Two 3:2 pulldown cycles of film frames A to H, AA BB BC CD DD, then
+	// the start of a third showing I and J.
+	fields := [][2]int{{0, 0}, {1, 1}, {1, 2}, {2, 3}, {3, 3},
+		{4, 4}, {5, 5}, {5, 6}, {6, 7}, {7, 7}, {8, 8}, {9, 9}}
+	frames := make([][3][]byte, len(fields))
+	for i, f := range fields {
+		frames[i] = wovenFrame(film(f[0]), film(f[1]))
+	}
+
+	props := video.ColorProperties{Width: 4, Height: 6,
+		PixelFormat: pixfmts.PixFmtYUV420P}
+	telecined, err := sources.NewMemorySource(frames, props, 29.97)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := sources.InverseTelecine(telecined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if n := source.GetNumFrames(); n != 10 {
+		t.Fatalf("GetNumFrames() = %d, want 10", n)
+	}
+	if rate := source.GetFrameRate(); rate < 23.975 || rate > 23.977 {
+		t.Errorf("GetFrameRate() = %g, want 23.976", rate)
+	}
+
+	frame, err := video.NewFrameFor(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(i int) {
+		t.Helper()
+		if err := source.GetFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+		for y := range 6 {
+			if got := frame.Row(0, y)[0]; got != film(i) {
+				t.Errorf("frame %d row %d: luma %d, want film frame %d "+
+					"(%d)", i, y, got, i, film(i))
+			}
+		}
+	}
+
+	for i := range 10 {
+		check(i)
+	}
+
+	// Seeking into the first cycle again matches its fields the same way.
+	if err := source.(video.SeekableSource).SeekFrame(2); err != nil {
+		t.Fatal(err)
+	}
+	check(2)
+	check(3)
+}
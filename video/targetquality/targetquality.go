@@ -0,0 +1,264 @@
+// Package targetquality searches for the encoder quality setting that reaches
+// a target metric score, in the spirit of ab-av1's crf-search. Short probes
+// of the source are encoded at candidate quality values, scored against the
+// reference with a chosen metric, and the highest quality value (i.e. the
+// smallest file) that still reaches the target is returned.
+package targetquality
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+	"github.com/GreatValueCreamSoda/gometrics/video/comparator"
+	"github.com/GreatValueCreamSoda/gometrics/video/sources"
+)
+
+var (
+	ErrTargetUnreachable = errors.New("no quality value in range reaches " +
+		"the target score")
+)
+
+// Options configures a Search.
+type Options struct {
+	// The encoder probes are encoded with.
+	Encoder video.Encoder
+	// Extra encoder arguments passed to every probe encode.
+	Settings []string
+	// Open opens an encoded probe file as a source.
+	Open func(path string) (video.Source, error)
+	// NewMetric returns the metric a probe is scored with. It is called once
+	// per probe range with the reference slice and the encoded probe, and
+	// the metric is closed once the probe is scored.
+	NewMetric func(reference, probe video.Source) (video.Metric, error)
+
+	// The score to reach.
+	Target float64
+	// Set for metrics where lower scores are better, such as Butteraugli.
+	LowerIsBetter bool
+
+	// The inclusive range of quality values to search. Lower values must
+	// mean higher quality, as with CRF and QP.
+	MinQuality, MaxQuality int
+
+	// The frame ranges encoded for every candidate. Defaults to
+	// DefaultProbes(numFrames, 4, 48).
+	Probes []video.FrameRange
+	// Frame threads for each probe's comparator. Defaults to 1.
+	FrameThreads int
+	// Directory probe encodes are written to. Defaults to os.TempDir().
+	TempDir string
+	// Extension of probe files, which picks the container. Defaults to
+	// ".mkv".
+	Extension string
+	// Aggregate reduces the per-frame scores of every probe range to a single
+	// score. Defaults to the mean.
+	Aggregate func(scores []float64) float64
+}
+
+func (o *Options) setDefaults(numFrames int) {
+	if len(o.Probes) == 0 {
+		o.Probes = DefaultProbes(numFrames, 4, 48)
+	}
+	if o.FrameThreads < 1 {
+		o.FrameThreads = 1
+	}
+	if o.Extension == "" {
+		o.Extension = ".mkv"
+	}
+	if o.Aggregate == nil {
+		o.Aggregate = mean
+	}
+}
+
+func (o *Options) validate() error {
+	switch {
+	case o.Encoder == nil:
+		return errors.New("no encoder was given")
+	case o.Open == nil:
+		return errors.New("no function to open probes was given")
+	case o.NewMetric == nil:
+		return errors.New("no metric constructor was given")
+	case o.MinQuality > o.MaxQuality:
+		return fmt.Errorf("quality range [%d, %d] is empty", o.MinQuality,
+			o.MaxQuality)
+	}
+	return nil
+}
+
+// Probe is the aggregated score of one candidate quality value.
+type Probe struct {
+	Quality int
+	Score   float64
+}
+
+// Result is the outcome of a Search.
+type Result struct {
+	// The highest quality value that reaches the target, and its score.
+	Quality int
+	Score   float64
+	// Every candidate that was probed, in the order they were probed.
+	Probes []Probe
+}
+
+// DefaultProbes returns n ranges of length frames spread evenly over a source
+// with numFrames frames. Short sources get a single range covering all of it.
+func DefaultProbes(numFrames, n, length int) []video.FrameRange {
+	if numFrames <= n*length {
+		return []video.FrameRange{{Start: 0, Count: numFrames}}
+	}
+
+	ranges := make([]video.FrameRange, n)
+	spacing := numFrames / n
+	for i := range ranges {
+		ranges[i] = video.FrameRange{
+			Start: i*spacing + (spacing-length)/2, Count: length}
+	}
+
+	return ranges
+}
+
+// Search binary searches [MinQuality, MaxQuality] for the highest quality
+// value whose probes reach opts.Target, assuming scores get worse as the
+// quality value increases.
+//
+// reference must be seekable. Returns ErrTargetUnreachable, along with the
+// probes that were made, if even MinQuality misses the target.
+func Search(ctx context.Context, reference video.Source, opts Options) (
+	*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts.setDefaults(reference.GetNumFrames())
+
+	dir, err := os.MkdirTemp(opts.TempDir, "gometrics-probes-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	s := &searcher{reference: reference, opts: opts, dir: dir}
+
+	result := &Result{Quality: -1}
+	lo, hi := opts.MinQuality, opts.MaxQuality
+
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+
+		score, err := s.probe(ctx, mid)
+		if err != nil {
+			return nil, fmt.Errorf("probe at quality %d: %w", mid, err)
+		}
+		result.Probes = append(result.Probes, Probe{mid, score})
+
+		if s.reaches(score) {
+			result.Quality, result.Score = mid, score
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	if result.Quality < opts.MinQuality {
+		return result, fmt.Errorf("%w: %g at quality %d", ErrTargetUnreachable,
+			result.Probes[len(result.Probes)-1].Score, opts.MinQuality)
+	}
+
+	return result, nil
+}
+
+type searcher struct {
+	reference video.Source
+	opts      Options
+	dir       string
+}
+
+func (s *searcher) reaches(score float64) bool {
+	if s.opts.LowerIsBetter {
+		return score <= s.opts.Target
+	}
+	return score >= s.opts.Target
+}
+
+// probe encodes and scores every probe range at quality and returns the
+// aggregated score.
+func (s *searcher) probe(ctx context.Context, quality int) (float64, error) {
+	var scores []float64
+
+	for i, frames := range s.opts.Probes {
+		rangeScores, err := s.probeRange(ctx, quality, i, frames)
+		if err != nil {
+			return 0, err
+		}
+		scores = append(scores, rangeScores...)
+	}
+
+	return s.opts.Aggregate(scores), nil
+}
+
+func (s *searcher) probeRange(ctx context.Context, quality, i int,
+	frames video.FrameRange) ([]float64, error) {
+	path := filepath.Join(s.dir, fmt.Sprintf("q%d-%d%s", quality, i,
+		s.opts.Extension))
+
+	encodeSource, err := sources.Slice(s.reference, frames.Start, frames.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.opts.Encoder.Encode(ctx, video.EncoderSettings{
+		Source: encodeSource, Output: path, Quality: quality,
+		Settings: s.opts.Settings})
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	probe, err := s.opts.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer probe.Close()
+
+	reference, err := sources.Slice(s.reference, frames.Start, frames.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	metric, err := s.opts.NewMetric(reference, probe)
+	if err != nil {
+		return nil, err
+	}
+
+	numFrames := min(frames.Count, probe.GetNumFrames())
+	comp, err := comparator.NewComparator(reference, probe,
+		[]video.Metric{metric}, s.opts.FrameThreads, numFrames,
+		comparator.WithSharedSources())
+	if err != nil {
+		metric.Close()
+		return nil, err
+	}
+	defer comp.Close()
+
+	scores, err := comp.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return scores[metric.Name()], nil
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadMOS parses a CSV file of subjective scores into clips. The first row is
+// a header naming the columns, in any order and case:
+//
+//	distortion  path of the distorted clip (required)
+//	mos         mean opinion score of the clip (required)
+//	reference   path of the clip's reference (optional)
+//
+// Other columns are ignored. Relative paths are taken relative to dir. Without
+// a reference column, or where it is empty, Reference is left empty for the
+// caller to fill in.
+func ReadMOS(r io.Reader, dir string) ([]Clip, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading MOS header: %w", err)
+	}
+
+	columns := map[string]int{"distortion": -1, "mos": -1, "reference": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["distortion"] < 0 || columns["mos"] < 0 {
+		return nil, errors.New("MOS file needs distortion and mos columns")
+	}
+
+	var clips []Clip
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		mos, err := strconv.ParseFloat(record[columns["mos"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid mos: %w", line, err)
+		}
+
+		clip := Clip{Distortion: resolve(dir, record[columns["distortion"]]),
+			MOS: mos}
+		if i := columns["reference"]; i >= 0 && record[i] != "" {
+			clip.Reference = resolve(dir, record[i])
+		}
+		clips = append(clips, clip)
+	}
+
+	return clips, nil
+}
+
+// ReadMOSFile reads the MOS CSV file at path, see ReadMOS.
+func ReadMOSFile(path, dir string) ([]Clip, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadMOS(file, dir)
+}
+
+// resolve returns path relative to dir unless it is absolute.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,244 @@
+// Package validation benchmarks metric configurations against subjective
+// scores. Every clip of a dataset is compared against its reference, the
+// per-frame scores are pooled to one score per clip, and the pooled scores of
+// every metric are correlated with the mean opinion scores (MOS) of the
+// dataset.
+package validation
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Clip is one distorted clip of a dataset with its reference and subjective
+// score.
+type Clip struct {
+	Reference  string  `json:"reference"`
+	Distortion string  `json:"distortion"`
+	MOS        float64 `json:"mos"`
+}
+
+// CompareFunc scores a distorted clip against its reference and returns
+// per-metric arrays of per-frame scores, the same as comparator.Comparator.Run.
+type CompareFunc func(ctx context.Context, reference, distortion string) (
+	map[string][]float64, error)
+
+// Options configures Run.
+type Options struct {
+	Clips   []Clip
+	Compare CompareFunc
+	// Pool reduces the per-frame scores of a clip to a single score. Defaults
+	// to the mean.
+	Pool func(scores []float64) float64
+	// Called after every compared clip.
+	Progress func(done, total int)
+}
+
+func (o *Options) setDefaults() {
+	if o.Pool == nil {
+		o.Pool = mean
+	}
+}
+
+func (o *Options) validate() error {
+	switch {
+	case len(o.Clips) < 3:
+		return fmt.Errorf("at least 3 clips are needed to correlate scores, "+
+			"got %d", len(o.Clips))
+	case o.Compare == nil:
+		return errors.New("no compare function was given")
+	}
+
+	for _, clip := range o.Clips {
+		if clip.Reference == "" {
+			return fmt.Errorf("clip %s has no reference", clip.Distortion)
+		}
+	}
+	return nil
+}
+
+// ClipScores holds the pooled score of every metric for one clip.
+type ClipScores struct {
+	Clip
+	Scores map[string]float64 `json:"scores"`
+}
+
+// Correlation is how well the pooled scores of one metric predict the MOS.
+type Correlation struct {
+	Metric string `json:"metric"`
+	// Clips is the number of clips the metric scored.
+	Clips int `json:"clips"`
+	// PLCC is the Pearson linear correlation coefficient.
+	PLCC float64 `json:"plcc"`
+	// SROCC is the Spearman rank order correlation coefficient.
+	SROCC float64 `json:"srocc"`
+	// RMSE is the root mean square error of the MOS predicted from the score
+	// by a least squares linear fit, in MOS units.
+	RMSE float64 `json:"rmse"`
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	Clips []ClipScores `json:"clips"`
+	// Correlations of every metric, sorted by metric name.
+	Correlations []Correlation `json:"correlations"`
+}
+
+// Run compares every clip and correlates the pooled scores of every metric
+// with the MOS. Clips are compared one at a time, in order.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	opts.setDefaults()
+	if err := opts.validate(); err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Clips: make([]ClipScores, 0, len(opts.Clips))}
+
+	for i, clip := range opts.Clips {
+		scores, err := opts.Compare(ctx, clip.Reference, clip.Distortion)
+		if err != nil {
+			return Report{}, fmt.Errorf("clip %s: %w", clip.Distortion, err)
+		}
+
+		pooled := make(map[string]float64, len(scores))
+		for name, values := range scores {
+			if len(values) > 0 {
+				pooled[name] = opts.Pool(values)
+			}
+		}
+		report.Clips = append(report.Clips, ClipScores{clip, pooled})
+
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(opts.Clips))
+		}
+	}
+
+	report.Correlations = correlate(report.Clips)
+	return report, nil
+}
+
+// correlate evaluates every metric scored on at least three clips.
+func correlate(clips []ClipScores) []Correlation {
+	predicted := make(map[string][]float64)
+	mos := make(map[string][]float64)
+
+	for _, clip := range clips {
+		for name, score := range clip.Scores {
+			predicted[name] = append(predicted[name], score)
+			mos[name] = append(mos[name], clip.MOS)
+		}
+	}
+
+	var correlations []Correlation
+	for name, scores := range predicted {
+		if len(scores) < 3 {
+			continue
+		}
+		correlation := Evaluate(scores, mos[name])
+		correlation.Metric = name
+		correlations = append(correlations, correlation)
+	}
+
+	slices.SortFunc(correlations, func(a, b Correlation) int {
+		return strings.Compare(a.Metric, b.Metric)
+	})
+	return correlations
+}
+
+// Evaluate returns how well scores predict mos, which must be of the same
+// length. The Metric field is left empty.
+//
+// PLCC and SROCC keep their sign, so metrics where lower is better, such as
+// Butteraugli, correlate negatively.
+func Evaluate(scores, mos []float64) Correlation {
+	return Correlation{Clips: len(scores), PLCC: pearson(scores, mos),
+		SROCC: pearson(ranks(scores), ranks(mos)),
+		RMSE:  linearFitRMSE(scores, mos)}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func pearson(x, y []float64) float64 {
+	meanX, meanY := mean(x), mean(y)
+
+	var num, denomX, denomY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		num += dx * dy
+		denomX += dx * dx
+		denomY += dy * dy
+	}
+
+	denom := math.Sqrt(denomX * denomY)
+	if denom == 0 {
+		return 0
+	}
+	return num / denom
+}
+
+// ranks returns the rank of every value, starting at 1. Tied values share the
+// mean of their ranks.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(values[a], values[b])
+	})
+
+	out := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			out[i] = rank
+		}
+		start = end
+	}
+	return out
+}
+
+// linearFitRMSE fits y = a + b*x by least squares and returns the root mean
+// square error of the fit.
+func linearFitRMSE(x, y []float64) float64 {
+	meanX, meanY := mean(x), mean(y)
+
+	var covariance, varianceX float64
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		varianceX += (x[i] - meanX) * (x[i] - meanX)
+	}
+
+	var slope float64
+	if varianceX != 0 {
+		slope = covariance / varianceX
+	}
+	intercept := meanY - slope*meanX
+
+	var sum float64
+	for i := range x {
+		residual := y[i] - (intercept + slope*x[i])
+		sum += residual * residual
+	}
+	return math.Sqrt(sum / float64(len(x)))
+}
@@ -1,53 +1,91 @@
 package video
 
 import (
+	"context"
 	"errors"
 	"fmt"
-
-	vship "github.com/GreatValueCreamSoda/gometrics/c/libvship"
 )
 
+// MaxPlanes is the largest number of planes a Frame can hold: three color
+// planes plus alpha.
+const MaxPlanes = 4
+
 // Frame represents a single video Frame's data. It holds the pixel data for
-// the three color planes (typically Y, U, V in YUV format) and the line sizes
-// (stride) for each plane.
+// up to MaxPlanes planes (typically Y, U, V and optionally alpha) and the line
+// sizes (stride) for each plane.
+//
+// Frame is the one frame type shared by sources, the comparator and metrics.
+// A Frame value is a view: copies of it share the plane buffers and metadata,
+// so passing a Frame by value never copies pixels. Use Clone for an
+// independent copy and SafeCopyFrom to copy into existing buffers.
 type Frame struct {
-	data     [3][]byte // Pixel data for each of the three planes.
-	lineSize [3]int    // Line size (stride) for each plane, in bytes.
+	data      [MaxPlanes][]byte // Pixel data for each plane.
+	lineSize  [MaxPlanes]int    // Line size (stride) for each plane, in bytes.
+	numPlanes int               // Number of leading planes that hold data.
+	metadata  FrameMetadata     // Per-frame properties set by the source.
 }
 
 // NewFrame creates a new Frame with the given plane buffers and line sizes.
+// Planes are used in order, the first zero-length plane marks the end of the
+// frame. Unused trailing entries must be left empty.
 //
 // This is the only supported way to construct a Frame. The provided slices
 // become owned by the returned Frame. Callers must not retain references to
 // the input slices after this call unless frame lifetime is properly tracked
-func NewFrame(data [3][]byte, lineSize [3]int) (Frame, error) {
-	for i := 0; i < 3; i++ {
+func NewFrame(data [MaxPlanes][]byte, lineSize [MaxPlanes]int) (Frame, error) {
+	var numPlanes int
+	for numPlanes < MaxPlanes && len(data[numPlanes]) != 0 {
+		numPlanes++
+	}
+
+	if numPlanes == 0 {
+		return Frame{}, errors.New("plane data must not be nil or zero-length")
+	}
+
+	for i := numPlanes; i < MaxPlanes; i++ {
 		if len(data[i]) != 0 {
-			continue
+			return Frame{}, fmt.Errorf("plane %d is empty but plane %d is not",
+				numPlanes, i)
 		}
-		return Frame{}, errors.New("plane data must not be nil or zero-length")
 	}
 
-	return Frame{data: data, lineSize: lineSize}, nil
+	return Frame{data: data, lineSize: lineSize, numPlanes: numPlanes,
+		metadata: make(FrameMetadata)}, nil
 }
 
-// Data returns a copy of the array containing the three plane buffers. The
-// returned array is safe to read but MUST NOT be modified. The underlying
-// slices are still protected by the Frame's ownership.
-func (f *Frame) Data() [3][]byte {
+// NumPlanes returns the number of planes that hold data.
+func (f *Frame) NumPlanes() int {
+	return f.numPlanes
+}
+
+// Data returns a copy of the array containing the plane buffers. Entries past
+// NumPlanes are nil. The returned array is safe to read but MUST NOT be
+// modified. The underlying slices are still protected by the Frame's
+// ownership.
+func (f *Frame) Data() [MaxPlanes][]byte {
 	return f.data
 }
 
-// LineSizes returns a copy of the array containing the three line sizes
-// (strides). The returned array is safe to read and cannot be used to modify
-// the Frame.
-func (f *Frame) LineSizes() [3]int {
+// LineSizes returns a copy of the array containing the line sizes (strides).
+// The returned array is safe to read and cannot be used to modify the Frame.
+func (f *Frame) LineSizes() [MaxPlanes]int {
 	return f.lineSize
 }
 
+// ColorPlanes returns the first three planes, i.e. the color planes without
+// alpha, for consumers that only handle three plane layouts.
+func (f *Frame) ColorPlanes() [3][]byte {
+	return [3][]byte{f.data[0], f.data[1], f.data[2]}
+}
+
+// ColorLineSizes returns the line sizes of the planes returned by ColorPlanes.
+func (f *Frame) ColorLineSizes() [3]int {
+	return [3]int{f.lineSize[0], f.lineSize[1], f.lineSize[2]}
+}
+
 // PlaneData returns a read-only view of the data for the requested plane.
 func (f *Frame) PlaneData(plane int) []byte {
-	if plane < 0 || plane > 2 {
+	if plane < 0 || plane >= f.numPlanes {
 		return nil
 	}
 	return f.data[plane]
@@ -56,17 +94,19 @@ func (f *Frame) PlaneData(plane int) []byte {
 // PlaneLineSize returns the line size (stride) in bytes for the requested
 // plane.
 func (f *Frame) PlaneLineSize(plane int) int {
-	if plane < 0 || plane > 2 {
+	if plane < 0 || plane >= f.numPlanes {
 		return 0
 	}
 	return f.lineSize[plane]
 }
 
-// SafeCopyFrom copies pixel data and line sizes from the source frame into
-// the receiver frame, preserving the receiver's underlying slice allocations.
+// SafeCopyFrom copies pixel data, line sizes and metadata from the source
+// frame into the receiver frame, preserving the receiver's underlying slice
+// allocations.
 // It performs safety checks to prevent incorrect buffer sizes.
 //
-// Returns an error if any destination plane lacks sufficient capacity.
+// Returns an error if the destination has fewer planes than the source or if
+// any destination plane lacks sufficient capacity.
 func (dst *Frame) SafeCopyFrom(src *Frame) error {
 	if dst == nil {
 		return errors.New("destination frame is nil")
@@ -74,11 +114,16 @@ func (dst *Frame) SafeCopyFrom(src *Frame) error {
 	if src == nil {
 		return errors.New("source frame is nil")
 	}
+	if dst.numPlanes < src.numPlanes {
+		return fmt.Errorf("destination frame has %d planes, source has %d",
+			dst.numPlanes, src.numPlanes)
+	}
 
 	var i int
 
 planeLoop:
-	if i >= 3 {
+	if i >= src.numPlanes {
+		dst.CopyMetadataFrom(src)
 		return nil
 	}
 
@@ -99,12 +144,122 @@ ret_error:
 		i, len(srcPlane), len(dstPlane))
 }
 
+// NewFrameFor allocates a Frame with buffers sized to hold the frames of
+// source, see Source.GetPlaneSizes.
+func NewFrameFor(source Source) (Frame, error) {
+	return NewFrameSized(source.GetPlaneSizes())
+}
+
+// NewFrameSized allocates a Frame with a buffer of planeSizes bytes for every
+// plane with a non-zero size.
+func NewFrameSized(planeSizes, lineSizes [MaxPlanes]int) (Frame, error) {
+	var data [MaxPlanes][]byte
+	for i, size := range planeSizes {
+		if size > 0 {
+			data[i] = make([]byte, size)
+		}
+	}
+	return NewFrame(data, lineSizes)
+}
+
+// Clone returns a deep copy of the frame with newly allocated plane buffers
+// and its own metadata.
+func (f *Frame) Clone() Frame {
+	clone := Frame{lineSize: f.lineSize, numPlanes: f.numPlanes,
+		metadata: make(FrameMetadata, len(f.metadata))}
+	for i := range f.numPlanes {
+		clone.data[i] = append([]byte(nil), f.data[i]...)
+	}
+	clone.CopyMetadataFrom(f)
+	return clone
+}
+
+// Row returns a read-only view of row y of the requested plane, starting at
+// the first sample and running to the end of the line size. It returns nil
+// for planes or rows outside the frame.
+func (f *Frame) Row(plane, y int) []byte {
+	data, stride := f.PlaneData(plane), f.PlaneLineSize(plane)
+	if y < 0 || stride <= 0 || (y+1)*stride > len(data) {
+		return nil
+	}
+	return data[y*stride : (y+1)*stride]
+}
+
 type Source interface {
 	GetFrame(Frame) error
 	GetColorProps() *ColorProperties
 	GetNumFrames() int
-	GetPlaneSizes() ([3]int, [3]int)
+	// GetPlaneSizes returns the size in bytes and the line size of each
+	// plane. Entries for planes the source does not have are zero.
+	GetPlaneSizes() ([MaxPlanes]int, [MaxPlanes]int)
 	GetFrameRate() float32
+	// Close releases the resources of the source, such as decoder handles.
+	// Sources wrapping another close it as well. Calling Close more than
+	// once does nothing.
+	Close() error
+}
+
+// SeekableSource is a Source whose read position can be moved. The next call
+// to GetFrame after SeekFrame(n) returns frame n.
+type SeekableSource interface {
+	Source
+	SeekFrame(n int) error
+}
+
+// FrameRange is a contiguous range of source frames.
+type FrameRange struct {
+	Start, Count int
+}
+
+// End returns the frame number one past the last frame of the range.
+func (r FrameRange) End() int { return r.Start + r.Count }
+
+// KeyFrameSource is a Source that can report which of its frames are
+// keyframes, in increasing order.
+type KeyFrameSource interface {
+	Source
+	GetKeyFrames() ([]int, error)
+}
+
+// FieldRepeatSource is a Source that can report how many extra fields each of
+// its frames is displayed for, as set by the repeat first field flags of soft
+// telecined streams. A frame with 1 is shown for three fields instead of two.
+type FieldRepeatSource interface {
+	Source
+	GetRepeatFields() ([]int, error)
+}
+
+// StartTimeSource is a Source that can report the presentation time of its
+// first frame in seconds, after container edit lists and start offsets are
+// applied. Sources whose first frames differ in time show different content
+// at the same frame number.
+type StartTimeSource interface {
+	Source
+	GetStartTime() (float64, error)
+}
+
+// WrapperSource is a Source forwarding optional interfaces such as
+// SeekableSource to the source it wraps. It implements them whether or not
+// the wrapped source does, so a type assertion alone says nothing; use As.
+type WrapperSource interface {
+	Source
+	Unwrap() Source
+}
+
+// As returns source as T if it implements T and, through every
+// WrapperSource, so do the sources it wraps.
+func As[T Source](source Source) (T, bool) {
+	outer, ok := source.(T)
+	for ok {
+		wrapper, isWrapper := source.(WrapperSource)
+		if !isWrapper {
+			return outer, true
+		}
+		source = wrapper.Unwrap()
+		_, ok = source.(T)
+	}
+	var zero T
+	return zero, false
 }
 
 // Metric is the interface that every metric must implement
@@ -114,14 +269,98 @@ type Metric interface {
 	Compute(a, b Frame) (map[string]float64, error)
 }
 
+// BitDepthChecker is implemented by metrics that can only compare some
+// combinations of bit depths. CheckBitDepths returns a descriptive error if
+// the metric cannot compare a depthA-bit source against a depthB-bit one.
+//
+// Metrics that do not implement it are assumed to require both sources to
+// share the same bit depth.
+type BitDepthChecker interface {
+	CheckBitDepths(depthA, depthB int) error
+}
+
+// LayoutChecker is implemented by metrics that declare which plane layouts
+// they can compare. SupportsLayout returns true if the metric can read frames
+// with the given layout.
+//
+// Metrics that do not implement it are assumed to support only the three
+// plane LayoutYUV and LayoutRGB.
+type LayoutChecker interface {
+	SupportsLayout(layout PlaneLayout) bool
+}
+
+// IdentityScorer is implemented by metrics whose scores for two bit-identical
+// frames are known without running them. IdentityScores returns those scores,
+// keyed the same as Compute, and false if the metric must still see the frame,
+// e.g. because it keeps temporal state or writes a distortion map.
+type IdentityScorer interface {
+	IdentityScores() (map[string]float64, bool)
+}
+
+// SequentialMetric is implemented by metrics that keep state from one frame
+// pair to the next, such as CVVDP's temporal weighting, or that write
+// per-frame output that must stay in frame order. When Sequential returns true
+// the Comparator runs the metric on a dedicated lane that hands it every frame
+// pair in order, one at a time, while other metrics keep running on any
+// number of frame threads.
+type SequentialMetric interface {
+	Sequential() bool
+}
+
+// ResettableMetric is implemented by metrics that keep state from one frame
+// pair to the next. Reset discards that state so the metric can score an
+// unrelated pair of sources, see comparator.Comparator.Reset.
+type ResettableMetric interface {
+	Reset() error
+}
+
+// ConcurrencyLimiter is implemented by metrics that can only compute a
+// limited number of frame pairs at once, usually because they own a fixed
+// number of workers. MaxConcurrency returns that number, or a value below 1
+// for no limit.
+//
+// The Comparator holds every metric to its own limit, so a restricted metric
+// does not hold back the others, and derives its frame threads from the
+// limits when none are given.
+type ConcurrencyLimiter interface {
+	MaxConcurrency() int
+}
+
+// CacheableMetric is implemented by metrics whose scores of a frame pair only
+// depend on the two frames and the settings CacheKey describes, so that they
+// can be reused across runs, see comparator.SetScoreCache. CacheKey must
+// change with every setting that changes the scores, including the version of
+// the library computing them, and returns "" if the scores cannot be cached
+// with the current settings.
+//
+// Metrics keeping state between frames or doing more than scoring for every
+// frame pair, such as writing heat maps, must not implement it.
+type CacheableMetric interface {
+	Metric
+	CacheKey() string
+}
+
+// EncoderSettings describes a single encode of a Source.
 type EncoderSettings struct {
-	Source     Source
-	Output     string
-	ColorSpace vship.Colorspace
-	Quality    int
-	Settings   []string
+	Source Source
+	// The output file path. The container is chosen by the encoder, usually
+	// from the extension.
+	Output string
+	// The encoder's constant quality value, e.g. CRF for x264/x265/SVT-AV1
+	// or cq-level for libaom.
+	Quality int
+	// Extra encoder specific arguments, passed through verbatim.
+	Settings []string
+	// The first frame to encode. Non-zero values require a SeekableSource.
+	StartFrame int
+	// The number of frames to encode. Zero encodes until the end of Source.
+	NumFrames int
 }
 
+// Encoder encodes a Source to a file, so candidate settings can be encoded and
+// scored in the same program.
 type Encoder interface {
-	Encode()
+	Name() string
+	// Encode blocks until the encode finishes, fails or ctx is cancelled.
+	Encode(ctx context.Context, settings EncoderSettings) error
 }
@@ -0,0 +1,14 @@
+// Package vsbridge scores frames pushed from VapourSynth scripts, so existing
+// VapourSynth comparison scripts can use gometrics metrics in place of a
+// VapourSynth metric plugin.
+//
+// A Server is an http.Handler. A script opens a session with the formats of
+// its reference and distorted clips, pushes the planes of every frame pair
+// and receives the scores as frame property names and values, see PropName.
+// gometrics.py in this directory is a VapourSynth helper doing this through
+// std.ModifyFrame. The endpoints are:
+//
+//	POST   /sessions              opens a session, see SessionRequest
+//	POST   /sessions/{id}/frames  scores one frame pair, see Server
+//	DELETE /sessions/{id}         closes a session and its metrics
+package vsbridge
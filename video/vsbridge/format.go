@@ -0,0 +1,100 @@
+package vsbridge
+
+import (
+	"fmt"
+
+	pixfmts "github.com/GreatValueCreamSoda/gometrics/c/libavpixfmts"
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// ClipFormat describes the frames of a VapourSynth clip in VapourSynth terms.
+// Only integer sample types are supported.
+type ClipFormat struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// ColorFamily is "yuv", "rgb" or "gray".
+	ColorFamily   string `json:"color_family"`
+	BitsPerSample int    `json:"bits_per_sample"`
+	// Log2 of the chroma subsampling of YUV clips.
+	SubsamplingW int `json:"subsampling_w"`
+	SubsamplingH int `json:"subsampling_h"`
+
+	// The _Matrix, _Transfer, _Primaries, _ColorRange and _ChromaLocation
+	// frame properties of the clip. Absent properties are unspecified.
+	Matrix         *int `json:"matrix,omitempty"`
+	Transfer       *int `json:"transfer,omitempty"`
+	Primaries      *int `json:"primaries,omitempty"`
+	ColorRange     *int `json:"color_range,omitempty"`
+	ChromaLocation *int `json:"chroma_location,omitempty"`
+}
+
+// subsamplingNames maps the log2 chroma subsampling of a YUV format to the
+// FFmpeg name fragment.
+var subsamplingNames = map[[2]int]string{
+	{0, 0}: "444", {1, 0}: "422", {1, 1}: "420",
+	{2, 0}: "411", {2, 2}: "410", {0, 1}: "440",
+}
+
+// ColorProperties returns the properties of frames of the clip. The planes
+// of RGB clips must be sent in the G, B, R order of FFmpeg's planar RGB
+// formats.
+func (f *ClipFormat) ColorProperties() (video.ColorProperties, error) {
+	props := video.ColorProperties{Width: f.Width, Height: f.Height}
+	if f.Width <= 0 || f.Height <= 0 {
+		return props, fmt.Errorf("invalid clip size %dx%d", f.Width,
+			f.Height)
+	}
+
+	var name string
+	switch f.ColorFamily {
+	case "yuv":
+		subsampling, ok := subsamplingNames[[2]int{f.SubsamplingW,
+			f.SubsamplingH}]
+		if !ok {
+			return props, fmt.Errorf("unsupported chroma subsampling %d,%d",
+				f.SubsamplingW, f.SubsamplingH)
+		}
+		name = "yuv" + subsampling + "p"
+	case "rgb":
+		name = "gbrp"
+	case "gray":
+		name = "gray"
+	default:
+		return props, fmt.Errorf("unsupported color family %q",
+			f.ColorFamily)
+	}
+	if f.BitsPerSample > 8 {
+		name += fmt.Sprintf("%dle", f.BitsPerSample)
+	}
+
+	var err error
+	if props.PixelFormat, err = pixfmts.GetPixFmt(name); err != nil {
+		return props, fmt.Errorf("unsupported %d-bit %s format: %w",
+			f.BitsPerSample, f.ColorFamily, err)
+	}
+
+	// _Matrix, _Transfer and _Primaries use the H.273 codes FFmpeg uses.
+	if f.Matrix != nil {
+		props.ColorSpace = pixfmts.ColorSpace(*f.Matrix)
+	}
+	if f.Transfer != nil {
+		props.ColorTransfer = pixfmts.ColorTransferCharacteristic(
+			*f.Transfer)
+	}
+	if f.Primaries != nil {
+		props.ColorPrimaries = pixfmts.ColorPrimaries(*f.Primaries)
+	}
+	// _ColorRange is 0 for full and 1 for limited range.
+	if f.ColorRange != nil {
+		props.ColorRange = pixfmts.ColorRangeMPEG
+		if *f.ColorRange == 0 {
+			props.ColorRange = pixfmts.ColorRangeJPEG
+		}
+	}
+	// _ChromaLocation counts from left, FFmpeg from unspecified.
+	if f.ChromaLocation != nil {
+		props.ChromaLocation = pixfmts.ChromaLocation(*f.ChromaLocation + 1)
+	}
+
+	return props, nil
+}
@@ -0,0 +1,98 @@
+"""Score VapourSynth clips with a gometrics --vs-listen server.
+
+    import gometrics
+    scored = gometrics.score(reference, distorted, "http://127.0.0.1:7879")
+    for frame in scored.frames():
+        print(frame.props["_SSIMULACRA2"])
+
+score returns the distorted clip with the scores of every frame pair set as
+frame properties, named like those of the vship plugin. The clips must share
+their length and use an integer sample type. Metrics keeping temporal state
+score frames in the order they are requested.
+"""
+
+import atexit
+import json
+import urllib.request
+
+import vapoursynth as vs
+
+_FAMILIES = {vs.YUV: "yuv", vs.RGB: "rgb", vs.GRAY: "gray"}
+_PROPS = {
+    "_Matrix": "matrix",
+    "_Transfer": "transfer",
+    "_Primaries": "primaries",
+    "_ColorRange": "color_range",
+    "_ChromaLocation": "chroma_location",
+}
+
+
+def _clip_format(clip):
+    fmt = clip.format
+    if fmt is None or fmt.sample_type != vs.INTEGER:
+        raise ValueError("gometrics: clips must have a constant integer format")
+    if fmt.color_family not in _FAMILIES:
+        raise ValueError(f"gometrics: unsupported color family {fmt.color_family}")
+
+    out = {
+        "width": clip.width,
+        "height": clip.height,
+        "color_family": _FAMILIES[fmt.color_family],
+        "bits_per_sample": fmt.bits_per_sample,
+        "subsampling_w": fmt.subsampling_w,
+        "subsampling_h": fmt.subsampling_h,
+    }
+    props = clip.get_frame(0).props
+    for prop, key in _PROPS.items():
+        if prop in props:
+            out[key] = int(props[prop])
+    return out
+
+
+def _request(url, method, body=None, content_type=None):
+    request = urllib.request.Request(url, data=body, method=method)
+    if content_type:
+        request.add_header("Content-Type", content_type)
+    with urllib.request.urlopen(request) as response:
+        data = response.read()
+    return json.loads(data) if data else None
+
+
+def _planes(frame):
+    # gometrics expects planar RGB in FFmpeg's G, B, R order.
+    order = range(frame.format.num_planes)
+    if frame.format.color_family == vs.RGB:
+        order = (1, 2, 0)
+    return b"".join(memoryview(frame[p]).tobytes() for p in order)
+
+
+def score(reference, distorted, url="http://127.0.0.1:7879"):
+    """Return distorted with the gometrics scores of every frame pair."""
+    if reference.num_frames != distorted.num_frames:
+        raise ValueError("gometrics: clips differ in length")
+
+    url = url.rstrip("/")
+    session = _request(
+        url + "/sessions",
+        "POST",
+        json.dumps({
+            "reference": _clip_format(reference),
+            "distortion": _clip_format(distorted),
+            "frame_rate": float(distorted.fps) if distorted.fps else 0,
+        }).encode(),
+        "application/json",
+    )["id"]
+    frames_url = f"{url}/sessions/{session}/frames"
+    # Free the metrics of the session once the script is done.
+    atexit.register(_request, f"{url}/sessions/{session}", "DELETE")
+
+    def set_props(n, f):
+        body = _planes(f[0]) + _planes(f[1])
+        props = _request(frames_url, "POST", body,
+                         "application/octet-stream")["props"]
+        out = f[1].copy()
+        for name, value in props.items():
+            out.props[name] = value
+        return out
+
+    return distorted.std.ModifyFrame([reference, distorted], set_props)
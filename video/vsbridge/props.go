@@ -0,0 +1,34 @@
+package vsbridge
+
+import "strings"
+
+// propNames maps the score keys of the built-in metrics to the frame
+// properties the vship VapourSynth plugin sets for them, so scripts written
+// against it keep working.
+var propNames = map[string]string{
+	"Ssimulacra2":      "_SSIMULACRA2",
+	"ButteraugliNormQ": "_BUTTERAUGLI_QNorm",
+	"ButteraugliNorm3": "_BUTTERAUGLI_3Norm",
+	"ButteraugliInf":   "_BUTTERAUGLI_INFNorm",
+	"CVVDP":            "_CVVDP",
+}
+
+// PropName returns the frame property a score is returned under. Scores of
+// the built-in metrics use the names of the vship VapourSynth plugin, other
+// keys are prefixed with an underscore and have any character VapourSynth
+// does not allow in property names replaced by one.
+func PropName(key string) string {
+	if name, ok := propNames[key]; ok {
+		return name
+	}
+
+	return "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,288 @@
+package vsbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/GreatValueCreamSoda/gometrics/video"
+)
+
+// MetricFactory creates the metrics of a session for clips with the given
+// color properties and frame rate. The Server closes them with the session.
+type MetricFactory func(reference, distortion *video.ColorProperties,
+	frameRate float32) ([]video.Metric, error)
+
+// SessionRequest is the body of POST /sessions.
+type SessionRequest struct {
+	Reference  ClipFormat `json:"reference"`
+	Distortion ClipFormat `json:"distortion"`
+	// The frame rate of the clips, used by temporal metrics.
+	FrameRate float32 `json:"frame_rate"`
+}
+
+// Server scores frame pairs pushed by VapourSynth scripts.
+//
+// POST /sessions/{id}/frames takes the planes of the reference frame
+// followed by those of the distorted frame, every plane as its rows of
+// visible samples without padding in little endian, and replies with the
+// JSON object {"props": {<PropName>: <score>}}. Frame pairs of one session
+// may be pushed concurrently, but metrics keeping temporal state, see
+// video.SequentialMetric, score them in the order they arrive.
+type Server struct {
+	newMetrics MetricFactory
+	mux        *http.ServeMux
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	lastID   int
+}
+
+// NewServer returns a Server creating the metrics of every session with
+// newMetrics.
+func NewServer(newMetrics MetricFactory) *Server {
+	s := &Server{newMetrics: newMetrics, mux: http.NewServeMux(),
+		sessions: make(map[string]*session)}
+
+	s.mux.HandleFunc("POST /sessions", s.handleOpen)
+	s.mux.HandleFunc("POST /sessions/{id}/frames", s.handleFrames)
+	s.mux.HandleFunc("DELETE /sessions/{id}", s.handleClose)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(rw, r)
+}
+
+// Close closes every open session.
+func (s *Server) Close() {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close()
+	}
+}
+
+func (s *Server) handleOpen(rw http.ResponseWriter, r *http.Request) {
+	var request SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.newSession(&request)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.lastID++
+	id := strconv.Itoa(s.lastID)
+	s.sessions[id] = session
+	s.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(rw).Encode(map[string]string{"id": id})
+}
+
+func (s *Server) handleFrames(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	session, ok := s.sessions[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(rw, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	scores, err := session.score(r.Body)
+	switch {
+	case errors.Is(err, errBadFrames):
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errSessionClosed):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	props := make(map[string]float64, len(scores))
+	for key, value := range scores {
+		props[PropName(key)] = value
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]any{"props": props})
+}
+
+func (s *Server) handleClose(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	session, ok := s.sessions[r.PathValue("id")]
+	delete(s.sessions, r.PathValue("id"))
+	s.mu.Unlock()
+	if !ok {
+		http.Error(rw, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	session.close()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+var (
+	errBadFrames     = errors.New("malformed frames")
+	errSessionClosed = errors.New("session closed")
+)
+
+// session holds the metrics of one pair of clips.
+type session struct {
+	metrics []video.Metric
+	// limits holds a semaphore per metric, sized by its
+	// video.ConcurrencyLimiter or 1 for sequential metrics. nil means no
+	// limit.
+	limits []chan struct{}
+	// rowBytes and rows are the visible plane sizes of the reference and
+	// the distortion.
+	rowBytes, rows [2][video.MaxPlanes]int
+
+	// mu is held for reading while scoring and for writing to close the
+	// metrics.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (s *Server) newSession(request *SessionRequest) (*session, error) {
+	reference, err := request.Reference.ColorProperties()
+	if err != nil {
+		return nil, fmt.Errorf("reference: %w", err)
+	}
+	distortion, err := request.Distortion.ColorProperties()
+	if err != nil {
+		return nil, fmt.Errorf("distortion: %w", err)
+	}
+
+	var sess session
+	for i, props := range []*video.ColorProperties{&reference, &distortion} {
+		if sess.rowBytes[i], sess.rows[i], _, err =
+			props.VisiblePlanes(); err != nil {
+			return nil, err
+		}
+	}
+
+	sess.metrics, err = s.newMetrics(&reference, &distortion,
+		request.FrameRate)
+	if err != nil {
+		return nil, err
+	}
+
+	sess.limits = make([]chan struct{}, len(sess.metrics))
+	for i, metric := range sess.metrics {
+		limit := 0
+		if limiter, ok := metric.(video.ConcurrencyLimiter); ok {
+			limit = limiter.MaxConcurrency()
+		}
+		if sequential, ok := metric.(video.SequentialMetric); ok &&
+			sequential.Sequential() {
+			limit = 1
+		}
+		if limit > 0 {
+			sess.limits[i] = make(chan struct{}, limit)
+		}
+	}
+
+	return &sess, nil
+}
+
+// score reads a frame pair from body and returns the scores of every
+// metric.
+func (s *session) score(body io.Reader) (map[string]float64, error) {
+	a, err := s.readFrame(body, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.readFrame(body, 1)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := body.Read(make([]byte, 1)); n > 0 {
+		return nil, fmt.Errorf("%w: trailing data after the frames",
+			errBadFrames)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, errSessionClosed
+	}
+
+	scores := make(map[string]float64)
+	for i, metric := range s.metrics {
+		if s.limits[i] != nil {
+			s.limits[i] <- struct{}{}
+		}
+		values, err := metric.Compute(a, b)
+		if s.limits[i] != nil {
+			<-s.limits[i]
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s computation failed: %w",
+				metric.Name(), err)
+		}
+
+		for key, value := range values {
+			if _, exists := scores[key]; exists {
+				return nil, fmt.Errorf("duplicate metric %q from %s", key,
+					metric.Name())
+			}
+			scores[key] = value
+		}
+	}
+	return scores, nil
+}
+
+// readFrame reads the planes of the reference (i = 0) or distorted (i = 1)
+// frame from body.
+func (s *session) readFrame(body io.Reader, i int) (video.Frame, error) {
+	var sizes [video.MaxPlanes]int
+	for plane, rowBytes := range s.rowBytes[i] {
+		sizes[plane] = rowBytes * s.rows[i][plane]
+	}
+	frame, err := video.NewFrameSized(sizes, s.rowBytes[i])
+	if err != nil {
+		return video.Frame{}, err
+	}
+
+	for plane := range frame.NumPlanes() {
+		if _, err := io.ReadFull(body, frame.PlaneData(plane)); err != nil {
+			return video.Frame{}, fmt.Errorf("%w: plane %d: %v",
+				errBadFrames, plane, err)
+		}
+	}
+	return frame, nil
+}
+
+// close closes the metrics once no frame pair is being scored.
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	for _, metric := range s.metrics {
+		metric.Close()
+	}
+}